@@ -0,0 +1,65 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofrs/uuid"
+
+	"px.dev/pixie/src/api/proto/vizierpb"
+)
+
+// ColumnValue returns the value of row i of a column as a JSON friendly value. UInt128s are
+// formatted as UUIDs and times as RFC 3339 strings.
+func ColumnValue(col *vizierpb.Column, i int) (interface{}, error) {
+	switch c := col.ColData.(type) {
+	case *vizierpb.Column_BooleanData:
+		if i < len(c.BooleanData.Data) {
+			return c.BooleanData.Data[i], nil
+		}
+	case *vizierpb.Column_Int64Data:
+		if i < len(c.Int64Data.Data) {
+			return c.Int64Data.Data[i], nil
+		}
+	case *vizierpb.Column_Uint128Data:
+		if i < len(c.Uint128Data.Data) {
+			b := make([]byte, 16)
+			enc.PutUint64(b, c.Uint128Data.Data[i].High)
+			enc.PutUint64(b[8:], c.Uint128Data.Data[i].Low)
+			return uuid.FromBytesOrNil(b).String(), nil
+		}
+	case *vizierpb.Column_Float64Data:
+		if i < len(c.Float64Data.Data) {
+			return c.Float64Data.Data[i], nil
+		}
+	case *vizierpb.Column_Time64NsData:
+		if i < len(c.Time64NsData.Data) {
+			return time.Unix(0, c.Time64NsData.Data[i]).UTC().Format(time.RFC3339Nano), nil
+		}
+	case *vizierpb.Column_StringData:
+		if i < len(c.StringData.Data) {
+			return string(c.StringData.Data[i]), nil
+		}
+	default:
+		return nil, errors.New("unknown column type")
+	}
+	return nil, fmt.Errorf("column is missing row %d", i)
+}
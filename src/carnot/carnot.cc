@@ -18,6 +18,7 @@
 
 #include <memory>
 #include <string>
+#include <utility>
 
 #include "src/carnot/carnot.h"
 #include "src/carnot/carnotpb/carnot.grpc.pb.h"
@@ -452,6 +453,15 @@ Status CarnotImpl::ExecutePlan(const planpb::Plan& logical_plan, const sole::uui
   std::vector<queryresultspb::AgentExecutionStats> all_agent_stats;
   if (analyze) {
     all_agent_stats = input_agent_stats;
+  } else {
+    // Only list the incoming agents, so that the query broker can tell which agents finished their
+    // part of the query. Their counts are already included in this agent's stats.
+    for (const auto& input_stats : input_agent_stats) {
+      queryresultspb::AgentExecutionStats stats;
+      *stats.mutable_agent_id() = input_stats.agent_id();
+      stats.set_execution_time_ns(input_stats.execution_time_ns());
+      all_agent_stats.push_back(std::move(stats));
+    }
   }
 
   // Even if analyze is set to false, send the most basic exec stats (rows, etc) per agent.
@@ -53,6 +53,9 @@ void RegisterMetadataOpsOrDie(px::carnot::udf::Registry* registry) {
   registry->RegisterOrDie<PodIDToReplicaSetIDUDF>("pod_id_to_replicaset_id");
   registry->RegisterOrDie<PodIDToDeploymentNameUDF>("pod_id_to_deployment_name");
   registry->RegisterOrDie<PodIDToDeploymentIDUDF>("pod_id_to_deployment_id");
+  registry->RegisterOrDie<PodIDToOwnerKindUDF>("pod_id_to_owner_kind");
+  registry->RegisterOrDie<PodIDToOwnerNameUDF>("pod_id_to_owner_name");
+  registry->RegisterOrDie<PodIDToOwnerIDUDF>("pod_id_to_owner_id");
   registry->RegisterOrDie<PodIDToPodStartTimeUDF>("pod_id_to_start_time");
   registry->RegisterOrDie<PodIDToPodStopTimeUDF>("pod_id_to_stop_time");
   registry->RegisterOrDie<PodIDToServiceNameUDF>("pod_id_to_service_name");
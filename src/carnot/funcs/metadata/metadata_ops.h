@@ -851,6 +851,63 @@ inline std::vector<const px::md::K8sMetadataObject*> GetOwnerReferenceInfosFromM
   return owner_references;
 }
 
+/**
+ * @brief Get the object that owns others by its ID, such as a Replica Set, a Deployment, a Stateful
+ * Set or a custom owner object.
+ */
+inline const px::md::K8sMetadataObject* K8sOwnerInfoByID(const px::md::K8sMetadataState& state,
+                                                         std::string_view id) {
+  if (auto rs_info = state.ReplicaSetInfoByID(id); rs_info != nullptr) {
+    return rs_info;
+  }
+  if (auto deployment_info = state.DeploymentInfoByID(id); deployment_info != nullptr) {
+    return deployment_info;
+  }
+  return state.WorkloadInfoByID(id);
+}
+
+/**
+ * @brief Get the kind of an owner object, as used in owner references, ex. "Rollout".
+ */
+inline std::string_view K8sOwnerKind(const px::md::K8sMetadataObject* owner) {
+  if (px::md::IsWorkloadType(owner->type())) {
+    return static_cast<const px::md::WorkloadInfo*>(owner)->kind();
+  }
+  return px::md::K8sObjectTypeName(owner->type());
+}
+
+/**
+ * @brief Get the top level owner of a metadata object, by following the owner references of the
+ * object and its owners for as long as the owners are known.
+ *
+ * @param ctx function context
+ * @param metadata_object some metadata object pointer
+ * @return the top level owner, or nullptr if none of the object's owners are known
+ */
+inline const px::md::K8sMetadataObject* GetTopLevelOwnerInfo(
+    ::px::carnot::udf::FunctionContext* ctx, const md::K8sMetadataObject* metadata_object) {
+  // Bounds the walk in case the owner references form a cycle.
+  constexpr int kMaxOwnerDepth = 16;
+  const auto& state = GetMetadataState(ctx)->k8s_metadata_state();
+
+  const px::md::K8sMetadataObject* owner = nullptr;
+  for (int depth = 0; depth < kMaxOwnerDepth; ++depth) {
+    const px::md::K8sMetadataObject* next = nullptr;
+    for (const auto& owner_reference : metadata_object->owner_references()) {
+      next = K8sOwnerInfoByID(state, owner_reference.uid);
+      if (next != nullptr) {
+        break;
+      }
+    }
+    if (next == nullptr) {
+      break;
+    }
+    owner = next;
+    metadata_object = next;
+  }
+  return owner;
+}
+
 /**
  * @brief Returns the replica set id for the given replica set name.
  */
@@ -2163,6 +2220,104 @@ class PodIDToDeploymentIDUDF : public ScalarUDF {
   }
 };
 
+/**
+ * @brief Returns the kind of the top level owner of a pod ID passed in.
+ */
+class PodIDToOwnerKindUDF : public ScalarUDF {
+ public:
+  StringValue Exec(FunctionContext* ctx, StringValue pod_id) {
+    auto md = GetMetadataState(ctx);
+
+    const auto* pod_info = md->k8s_metadata_state().PodInfoByID(pod_id);
+    if (pod_info == nullptr) {
+      return "";
+    }
+
+    auto owner = GetTopLevelOwnerInfo(ctx, pod_info);
+    if (owner == nullptr) {
+      return "";
+    }
+    return std::string(K8sOwnerKind(owner));
+  }
+
+  static udf::ScalarUDFDocBuilder Doc() {
+    return udf::ScalarUDFDocBuilder("Get the kind of the top level owner of the pod with pod ID.")
+        .Details(
+            "Follows the owner references of the Pod (specified by Pod ID) and its owners, and "
+            "returns the kind of the last known owner, such as Deployment, StatefulSet or a custom "
+            "resource kind which the metadata service is configured to watch. "
+            "If the pod has no known owner, returns an empty string.")
+        .Example("df.owner_kind = px.pod_id_to_owner_kind(df.pod_id)")
+        .Arg("pod_id", "The Pod ID of the Pod to get the owner kind for.")
+        .Returns("The kind of the top level owner of the Pod with the Pod ID.");
+  }
+};
+
+/**
+ * @brief Returns the name of the top level owner of a pod ID passed in.
+ */
+class PodIDToOwnerNameUDF : public ScalarUDF {
+ public:
+  StringValue Exec(FunctionContext* ctx, StringValue pod_id) {
+    auto md = GetMetadataState(ctx);
+
+    const auto* pod_info = md->k8s_metadata_state().PodInfoByID(pod_id);
+    if (pod_info == nullptr) {
+      return "";
+    }
+
+    auto owner = GetTopLevelOwnerInfo(ctx, pod_info);
+    if (owner == nullptr) {
+      return "";
+    }
+    return absl::Substitute("$0/$1", owner->ns(), owner->name());
+  }
+
+  static udf::ScalarUDFDocBuilder Doc() {
+    return udf::ScalarUDFDocBuilder("Get the name of the top level owner of the pod with pod ID.")
+        .Details(
+            "Follows the owner references of the Pod (specified by Pod ID) and its owners, and "
+            "returns the name of the last known owner, such as a Deployment, a StatefulSet or a "
+            "custom resource which the metadata service is configured to watch. "
+            "If the pod has no known owner, returns an empty string.")
+        .Example("df.owner_name = px.pod_id_to_owner_name(df.pod_id)")
+        .Arg("pod_id", "The Pod ID of the Pod to get the owner name for.")
+        .Returns("The name of the top level owner of the Pod with the Pod ID.");
+  }
+};
+
+/**
+ * @brief Returns the ID of the top level owner of a pod ID passed in.
+ */
+class PodIDToOwnerIDUDF : public ScalarUDF {
+ public:
+  StringValue Exec(FunctionContext* ctx, StringValue pod_id) {
+    auto md = GetMetadataState(ctx);
+
+    const auto* pod_info = md->k8s_metadata_state().PodInfoByID(pod_id);
+    if (pod_info == nullptr) {
+      return "";
+    }
+
+    auto owner = GetTopLevelOwnerInfo(ctx, pod_info);
+    if (owner == nullptr) {
+      return "";
+    }
+    return owner->uid();
+  }
+
+  static udf::ScalarUDFDocBuilder Doc() {
+    return udf::ScalarUDFDocBuilder("Get the ID of the top level owner of the pod with pod ID.")
+        .Details(
+            "Follows the owner references of the Pod (specified by Pod ID) and its owners, and "
+            "returns the ID of the last known owner. "
+            "If the pod has no known owner, returns an empty string.")
+        .Example("df.owner_id = px.pod_id_to_owner_id(df.pod_id)")
+        .Arg("pod_id", "The Pod ID of the Pod to get the owner ID for.")
+        .Returns("The ID of the top level owner of the Pod with the Pod ID.");
+  }
+};
+
 /**
  * @brief Returns the ReplicaSet name of a pod name passed in.
  */
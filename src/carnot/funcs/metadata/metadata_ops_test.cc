@@ -352,6 +352,32 @@ TEST_F(MetadataOpsTest, pod_id_to_deployment_id_test) {
   udf_tester.ForInput("2_uid").Expect("terminating_deployment_uid");
 }
 
+TEST_F(MetadataOpsTest, pod_id_to_owner_test) {
+  updates_->enqueue(px::metadatapb::testutils::CreateRolloutOwnerObjectUpdatePB());
+  updates_->enqueue(px::metadatapb::testutils::CreateRolloutReplicaSetUpdatePB());
+  updates_->enqueue(px::metadatapb::testutils::CreateRolloutPodUpdatePB());
+  EXPECT_OK(px::md::ApplyK8sUpdates(11, metadata_state_.get(), &md_filter_, updates_.get()));
+
+  auto kind_tester = px::carnot::udf::UDFTester<PodIDToOwnerKindUDF>(
+      std::make_unique<FunctionContext>(metadata_state_, nullptr));
+  kind_tester.ForInput("1_uid").Expect("Deployment");
+  kind_tester.ForInput("rollout_pod_uid").Expect("Rollout");
+  // This pod is not available, should return empty.
+  kind_tester.ForInput("123_uid").Expect("");
+
+  auto name_tester = px::carnot::udf::UDFTester<PodIDToOwnerNameUDF>(
+      std::make_unique<FunctionContext>(metadata_state_, nullptr));
+  name_tester.ForInput("1_uid").Expect("pl/deployment1");
+  name_tester.ForInput("rollout_pod_uid").Expect("pl/rollout1");
+  name_tester.ForInput("123_uid").Expect("");
+
+  auto id_tester = px::carnot::udf::UDFTester<PodIDToOwnerIDUDF>(
+      std::make_unique<FunctionContext>(metadata_state_, nullptr));
+  id_tester.ForInput("1_uid").Expect("deployment_uid");
+  id_tester.ForInput("rollout_pod_uid").Expect("rollout_uid");
+  id_tester.ForInput("123_uid").Expect("");
+}
+
 TEST_F(MetadataOpsTest, upid_to_hostname_test) {
   auto function_ctx = std::make_unique<FunctionContext>(metadata_state_, nullptr);
   auto udf_tester = px::carnot::udf::UDFTester<UPIDToHostnameUDF>(std::move(function_ctx));
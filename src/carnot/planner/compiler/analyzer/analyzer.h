@@ -27,6 +27,7 @@
 #include "src/carnot/planner/compiler/analyzer/combine_consecutive_maps_rule.h"
 #include "src/carnot/planner/compiler/analyzer/convert_metadata_rule.h"
 #include "src/carnot/planner/compiler/analyzer/drop_to_map_rule.h"
+#include "src/carnot/planner/compiler/analyzer/dry_run_exports_rule.h"
 #include "src/carnot/planner/compiler/analyzer/merge_group_by_into_group_acceptor_rule.h"
 #include "src/carnot/planner/compiler/analyzer/nested_blocking_agg_fn_check_rule.h"
 #include "src/carnot/planner/compiler/analyzer/propagate_expression_annotations_rule.h"
@@ -96,6 +97,12 @@ class Analyzer : public RuleExecutor<IR> {
     intermediate_resolution_batch->AddRule<ResolveTypesRule>(compiler_state_);
     intermediate_resolution_batch->AddRule<DropToMapOperatorRule>(compiler_state_);
   }
+
+  void CreateDryRunExportsBatch() {
+    RuleBatch* dry_run_exports = CreateRuleBatch<FailOnMax>("DryRunExports", 2);
+    dry_run_exports->AddRule<DryRunExportsRule>(compiler_state_);
+  }
+
   void CreateManageColumnAccessBatch() {
     RuleBatch* manage_column_access = CreateRuleBatch<TryUntilMax>("ManageColumnAccess", 1);
     manage_column_access->AddRule<RestrictColumnsRule>(compiler_state_);
@@ -125,6 +132,7 @@ class Analyzer : public RuleExecutor<IR> {
     CreateAddLimitToBatchResultSinkBatch();
     CreateCombineConsecutiveMapsRule();
     CreateDataTypeResolutionBatch();
+    CreateDryRunExportsBatch();
     CreateManageColumnAccessBatch();
     CreateMetadataConversionBatch();
     CreateResolutionVerificationBatch();
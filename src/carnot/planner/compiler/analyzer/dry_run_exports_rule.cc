@@ -0,0 +1,67 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+#include "src/carnot/planner/compiler/analyzer/dry_run_exports_rule.h"
+#include "src/carnot/planner/ir/grpc_sink_ir.h"
+#include "src/carnot/planner/ir/limit_ir.h"
+#include "src/carnot/planner/ir/otel_export_sink_ir.h"
+
+namespace px {
+namespace carnot {
+namespace planner {
+namespace compiler {
+
+StatusOr<bool> DryRunExportsRule::Apply(IRNode* ir_node) {
+  if (!compiler_state_->dry_run_exports()) {
+    return false;
+  }
+  if (!Match(ir_node, OTelExportSink())) {
+    return false;
+  }
+
+  auto otel_sink = static_cast<OTelExportSinkIR*>(ir_node);
+  DCHECK_EQ(otel_sink->parents().size(), 1UL) << "There should be exactly one parent.";
+  OperatorIR* parent = otel_sink->parents()[0];
+  auto graph = otel_sink->graph();
+
+  // The limits of the other result sinks are added before the types are resolved, so the limit of
+  // these result sinks is added here.
+  if (compiler_state_->has_max_output_rows_per_table()) {
+    PL_ASSIGN_OR_RETURN(parent,
+                        graph->CreateNode<LimitIR>(otel_sink->ast(), parent,
+                                                   compiler_state_->max_output_rows_per_table()));
+    PL_RETURN_IF_ERROR(ResolveOperatorType(parent, compiler_state_));
+  }
+
+  ++num_exports_;
+  PL_ASSIGN_OR_RETURN(
+      auto sink, graph->CreateNode<GRPCSinkIR>(otel_sink->ast(), parent,
+                                               absl::Substitute("export_$0", num_exports_),
+                                               otel_sink->resolved_table_type()->ColumnNames()));
+  sink->SetDestinationAddress(compiler_state_->result_address());
+  sink->SetDestinationSSLTargetName(compiler_state_->result_ssl_targetname());
+  PL_RETURN_IF_ERROR(ResolveOperatorType(sink, compiler_state_));
+
+  PL_RETURN_IF_ERROR(graph->DeleteNode(otel_sink->id()));
+  return true;
+}
+
+}  // namespace compiler
+}  // namespace planner
+}  // namespace carnot
+}  // namespace px
@@ -0,0 +1,53 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+#pragma once
+
+#include "src/carnot/planner/compiler_state/compiler_state.h"
+#include "src/carnot/planner/rules/rules.h"
+
+namespace px {
+namespace carnot {
+namespace planner {
+namespace compiler {
+
+/**
+ * @brief This rule replaces OTel export sinks with result sinks when the compiler state asks for a
+ * dry run of the exports. The result sinks are named export_1, export_2, etc. in the order of the
+ * px.export calls, and output the columns which the export would have read, up to the max output
+ * rows per table.
+ *
+ * The rule must run after the types are resolved, so that the export spec is still checked against
+ * the exported DataFrame.
+ */
+class DryRunExportsRule : public Rule {
+ public:
+  explicit DryRunExportsRule(CompilerState* compiler_state)
+      : Rule(compiler_state, /*use_topo*/ false, /*reverse_topological_execution*/ false) {}
+
+ protected:
+  StatusOr<bool> Apply(IRNode* ir_node) override;
+
+ private:
+  int64_t num_exports_ = 0;
+};
+
+}  // namespace compiler
+}  // namespace planner
+}  // namespace carnot
+}  // namespace px
@@ -176,6 +176,11 @@ class CompilerState : public NotCopyable {
   PluginConfig* plugin_config() { return plugin_config_.get(); }
   const DebugInfo& debug_info() { return debug_info_; }
 
+  // Whether px.export calls should return the data which would have been exported, instead of
+  // exporting it.
+  bool dry_run_exports() const { return dry_run_exports_; }
+  void set_dry_run_exports(bool dry_run_exports) { dry_run_exports_ = dry_run_exports; }
+
  private:
   std::unique_ptr<RelationMap> relation_map_;
   SensitiveColumnMap table_names_to_sensitive_columns_;
@@ -191,6 +196,7 @@ class CompilerState : public NotCopyable {
   std::unique_ptr<planpb::OTelEndpointConfig> endpoint_config_ = nullptr;
   std::unique_ptr<PluginConfig> plugin_config_ = nullptr;
   DebugInfo debug_info_;
+  bool dry_run_exports_ = false;
 };
 
 }  // namespace planner
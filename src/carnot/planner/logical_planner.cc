@@ -97,7 +97,7 @@ StatusOr<std::unique_ptr<CompilerState>> CreateCompilerState(
     debug_info.otel_debug_attrs.push_back({debug_info_pb.name(), debug_info_pb.value()});
   }
   // Create a CompilerState obj using the relation map and grabbing the current time.
-  return std::make_unique<planner::CompilerState>(
+  auto compiler_state = std::make_unique<planner::CompilerState>(
       std::move(rel_map), sensitive_columns, registry_info, px::CurrentTimeNS(),
       max_output_rows_per_table, logical_state.result_address(),
       logical_state.result_ssl_targetname(),
@@ -105,6 +105,8 @@ StatusOr<std::unique_ptr<CompilerState>> CreateCompilerState(
       RedactionOptionsFromPb(logical_state.redaction_options()), std::move(otel_endpoint_config),
       // TODO(philkuz) propagate the otel debug attributes here.
       std::move(plugin_config), debug_info);
+  compiler_state->set_dry_run_exports(logical_state.dry_run_exports());
+  return compiler_state;
 }
 
 StatusOr<std::unique_ptr<LogicalPlanner>> LogicalPlanner::Create(const udfspb::UDFInfo& udf_info) {
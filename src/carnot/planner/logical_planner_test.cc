@@ -43,6 +43,7 @@ namespace carnot {
 namespace planner {
 using ::px::carnot::planner::testing::EqualsPlanGraph;
 using ::px::testing::proto::EqualsProto;
+using ::testing::UnorderedElementsAre;
 
 class LogicalPlannerTest : public ::testing::Test {
  protected:
@@ -693,6 +694,7 @@ TEST_F(LogicalPlannerTest, default_compiler_state_has_nullptr) {
   ASSERT_OK_AND_ASSIGN(auto compiler_state, CreateCompilerState(state, &registry_info,
                                                                 /* max_output_rows_per_table*/ 0));
   EXPECT_EQ(compiler_state->endpoint_config(), nullptr);
+  EXPECT_FALSE(compiler_state->dry_run_exports());
 }
 
 const char kOTelDebugInfo[] = R"pxl(
@@ -769,6 +771,32 @@ attributes {
 })proto"));
 }
 
+TEST_F(LogicalPlannerTest, dry_run_exports) {
+  auto state = testutils::CreateTwoPEMsOneKelvinPlannerState(testutils::kHttpEventsSchema);
+  state.set_dry_run_exports(true);
+  auto planner = LogicalPlanner::Create(info_).ConsumeValueOrDie();
+  ASSERT_OK_AND_ASSIGN(auto plan, planner->Plan(state, MakeQueryRequest(kOTelDebugInfo)));
+  ASSERT_OK_AND_ASSIGN(auto distributed_plan, plan->ToProto());
+
+  // The export is replaced by a result table of the exported columns.
+  std::vector<planpb::GRPCSinkOperator::ResultTable> output_tables;
+  for (const auto& [address, agent_plan] : distributed_plan.qb_address_to_plan()) {
+    for (const auto& planFragment : agent_plan.nodes()) {
+      for (const auto& planNode : planFragment.nodes()) {
+        EXPECT_NE(planNode.op().op_type(), planpb::OperatorType::OTEL_EXPORT_SINK_OPERATOR);
+        if (planNode.op().op_type() == planpb::OperatorType::GRPC_SINK_OPERATOR &&
+            planNode.op().grpc_sink_op().has_output_table()) {
+          output_tables.push_back(planNode.op().grpc_sink_op().output_table());
+        }
+      }
+    }
+  }
+  ASSERT_EQ(output_tables.size(), 1UL);
+  EXPECT_EQ(output_tables[0].table_name(), "export_1");
+  EXPECT_THAT(output_tables[0].column_names(),
+              UnorderedElementsAre("time_", "service", "resp_latency_ns"));
+}
+
 TEST_F(LogicalPlannerTest, GenerateOTelScript) {
   auto planner = LogicalPlanner::Create(info_).ConsumeValueOrDie();
   auto state = testutils::CreateTwoPEMsOneKelvinPlannerState(testutils::kHttpEventsSchema);
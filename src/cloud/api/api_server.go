@@ -31,6 +31,7 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"google.golang.org/grpc"
 
 	"px.dev/pixie/src/api/proto/cloudpb"
 	"px.dev/pixie/src/api/proto/vizierpb"
@@ -100,7 +101,7 @@ func main() {
 		log.WithError(err).Fatal("Failed to init config manager client")
 	}
 
-	vc, vk, err := apienv.NewVZMgrServiceClients()
+	vc, vk, vuc, err := apienv.NewVZMgrServiceClients()
 	if err != nil {
 		log.WithError(err).Fatal("Failed to init vzmgr clients")
 	}
@@ -184,6 +185,10 @@ func main() {
 			"/px.cloudapi.ConfigService/GetConfigForOperator": true,
 			"/px.cloudapi.AuthService/Login":                  true,
 		},
+		GRPCServerOpts: []grpc.ServerOption{
+			grpc.ChainUnaryInterceptor(controllers.APIKeyScopeUnaryInterceptor()),
+			grpc.ChainStreamInterceptor(controllers.APIKeyScopeStreamInterceptor()),
+		},
 	}
 
 	domainName := viper.GetString("domain_name")
@@ -204,6 +209,9 @@ func main() {
 	cis := &controllers.VizierClusterInfo{VzMgr: vc, ArtifactTrackerClient: at}
 	cloudpb.RegisterVizierClusterInfoServer(s.GRPCServer(), cis)
 
+	ucs := &controllers.VizierUpgradeCampaignServer{VzUpgradeCampaign: vuc, ArtifactTrackerClient: at}
+	cloudpb.RegisterVizierUpgradeCampaignServiceServer(s.GRPCServer(), ucs)
+
 	vdks := &controllers.VizierDeploymentKeyServer{VzDeploymentKey: vk}
 	cloudpb.RegisterVizierDeploymentKeyManagerServer(s.GRPCServer(), vdks)
 
@@ -274,7 +282,7 @@ func main() {
 	cs := &controllers.ConfigServiceServer{ConfigServiceClient: cm}
 	cloudpb.RegisterConfigServiceServer(s.GRPCServer(), cs)
 
-	pss := &controllers.PluginServiceServer{PluginServiceClient: ps, DataRetentionPluginServiceClient: drps}
+	pss := &controllers.PluginServiceServer{PluginServiceClient: ps, DataRetentionPluginServiceClient: drps, VizierPassthrough: vpt, VzMgr: vc}
 	cloudpb.RegisterPluginServiceServer(s.GRPCServer(), pss)
 
 	gqlEnv := controllers.GraphQLEnv{
@@ -289,10 +297,13 @@ func main() {
 		PluginServer:          pss,
 	}
 
-	mux.Handle("/api/graphql", controllers.WithAugmentedAuthMiddleware(env, controllers.NewGraphQLHandler(gqlEnv)))
+	mux.Handle("/api/graphql", controllers.WithAugmentedAuthMiddleware(env, controllers.WithoutRestrictedAPIKeys(controllers.NewGraphQLHandler(gqlEnv))))
 
 	mux.Handle("/api/unauthenticated/graphql", controllers.NewUnauthenticatedGraphQLHandler(gqlEnv))
 
+	scim := &controllers.SCIMHandler{AuthServiceClient: ac, ProfileServiceClient: pc, OrgServiceClient: oc}
+	mux.Handle("/api/scim/v2/", controllers.WithBearerAPIKey(controllers.WithAugmentedAuthMiddleware(env, scim)))
+
 	s.Start()
 	s.StopOnInterrupt()
 }
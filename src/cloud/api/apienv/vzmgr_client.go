@@ -32,16 +32,17 @@ func init() {
 }
 
 // NewVZMgrServiceClients creates the vzmgr RPC client stubs.
-func NewVZMgrServiceClients() (vzmgrpb.VZMgrServiceClient, vzmgrpb.VZDeploymentKeyServiceClient, error) {
+func NewVZMgrServiceClients() (vzmgrpb.VZMgrServiceClient, vzmgrpb.VZDeploymentKeyServiceClient, vzmgrpb.VZUpgradeCampaignServiceClient, error) {
 	dialOpts, err := services.GetGRPCClientDialOpts()
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
 	vzMgrChan, err := grpc.Dial(viper.GetString("vzmgr_service"), dialOpts...)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
-	return vzmgrpb.NewVZMgrServiceClient(vzMgrChan), vzmgrpb.NewVZDeploymentKeyServiceClient(vzMgrChan), nil
+	return vzmgrpb.NewVZMgrServiceClient(vzMgrChan), vzmgrpb.NewVZDeploymentKeyServiceClient(vzMgrChan),
+		vzmgrpb.NewVZUpgradeCampaignServiceClient(vzMgrChan), nil
 }
@@ -35,22 +35,30 @@ type APIKeyServer struct {
 
 func apiKeyToCloudAPI(key *authpb.APIKey) *cloudpb.APIKey {
 	return &cloudpb.APIKey{
-		ID:        key.ID,
-		OrgID:     key.OrgID,
-		UserID:    key.UserID,
-		Key:       key.Key,
-		CreatedAt: key.CreatedAt,
-		Desc:      key.Desc,
+		ID:         key.ID,
+		OrgID:      key.OrgID,
+		UserID:     key.UserID,
+		Key:        key.Key,
+		CreatedAt:  key.CreatedAt,
+		Desc:       key.Desc,
+		Scopes:     key.Scopes,
+		ExpiresAt:  key.ExpiresAt,
+		ClusterIDs: key.ClusterIDs,
+		LastUsedAt: key.LastUsedAt,
 	}
 }
 
 func apiKeyMetadataToCloudAPI(key *authpb.APIKeyMetadata) *cloudpb.APIKeyMetadata {
 	return &cloudpb.APIKeyMetadata{
-		ID:        key.ID,
-		OrgID:     key.OrgID,
-		UserID:    key.UserID,
-		CreatedAt: key.CreatedAt,
-		Desc:      key.Desc,
+		ID:         key.ID,
+		OrgID:      key.OrgID,
+		UserID:     key.UserID,
+		CreatedAt:  key.CreatedAt,
+		Desc:       key.Desc,
+		Scopes:     key.Scopes,
+		ExpiresAt:  key.ExpiresAt,
+		ClusterIDs: key.ClusterIDs,
+		LastUsedAt: key.LastUsedAt,
 	}
 }
 
@@ -61,7 +69,12 @@ func (v *APIKeyServer) Create(ctx context.Context, req *cloudpb.CreateAPIKeyRequ
 		return nil, err
 	}
 
-	resp, err := v.APIKeyClient.Create(ctx, &authpb.CreateAPIKeyRequest{Desc: req.Desc})
+	resp, err := v.APIKeyClient.Create(ctx, &authpb.CreateAPIKeyRequest{
+		Desc:       req.Desc,
+		Scopes:     req.Scopes,
+		ExpiresAt:  req.ExpiresAt,
+		ClusterIDs: req.ClusterIDs,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,173 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"px.dev/pixie/src/api/proto/uuidpb"
+	"px.dev/pixie/src/shared/services/authcontext"
+	srvutils "px.dev/pixie/src/shared/services/utils"
+	"px.dev/pixie/src/utils"
+)
+
+const clusterInfoServicePrefix = "/px.cloudapi.VizierClusterInfo/"
+
+// apiKeyMethodScopes maps a gRPC method, or a service prefix covering all of its methods, to the
+// API key scopes which allow it to be called. Methods which are not listed can't be called with a
+// scoped API key.
+var apiKeyMethodScopes = map[string][]string{
+	"/px.api.vizierpb.VizierService/ExecuteScript":      {srvutils.APIKeyScopeScriptExecute, srvutils.APIKeyScopeClusterAdmin},
+	"/px.api.vizierpb.VizierService/HealthCheck":        {srvutils.APIKeyScopeScriptExecute, srvutils.APIKeyScopeClusterAdmin},
+	"/px.api.vizierpb.VizierService/GenerateOTelScript": {srvutils.APIKeyScopeScriptExecute, srvutils.APIKeyScopeClusterAdmin},
+	"/px.api.vizierpb.VizierService/GetK8sHistory":      {srvutils.APIKeyScopeScriptExecute, srvutils.APIKeyScopeClusterAdmin},
+	clusterInfoServicePrefix + "GetClusterInfo":         {srvutils.APIKeyScopeScriptExecute, srvutils.APIKeyScopeClusterAdmin},
+	clusterInfoServicePrefix + "GetClusterConnectionInfo": {
+		srvutils.APIKeyScopeScriptExecute, srvutils.APIKeyScopeClusterAdmin,
+	},
+	clusterInfoServicePrefix + "GetClusterStatusHistory": {
+		srvutils.APIKeyScopeScriptExecute, srvutils.APIKeyScopeClusterAdmin,
+	},
+	"/px.cloudapi.ScriptMgr/":           {srvutils.APIKeyScopeScriptExecute, srvutils.APIKeyScopeClusterAdmin},
+	"/px.cloudapi.AutocompleteService/": {srvutils.APIKeyScopeScriptExecute, srvutils.APIKeyScopeClusterAdmin},
+	"/px.cloudapi.ArtifactTracker/":     {srvutils.APIKeyScopeScriptExecute, srvutils.APIKeyScopeClusterAdmin},
+
+	clusterInfoServicePrefix:                   {srvutils.APIKeyScopeClusterAdmin},
+	"/px.api.vizierpb.VizierDebugService/":     {srvutils.APIKeyScopeClusterAdmin},
+	"/px.cloudapi.VizierImageAuthorization/":   {srvutils.APIKeyScopeClusterAdmin},
+	"/px.cloudapi.VizierDeploymentKeyManager/": {srvutils.APIKeyScopeDeployKeyAdmin},
+	"/px.cloudapi.PluginService/":              {srvutils.APIKeyScopePluginConfig},
+	// Upgrade campaigns can upgrade any cluster of the org, so keys restricted to a set of clusters are
+	// rejected by the service itself.
+	"/px.cloudapi.VizierUpgradeCampaignService/": {srvutils.APIKeyScopeClusterAdmin},
+}
+
+// scopesForMethod returns the scopes which allow the given method, preferring the entry for the
+// method over the entry for its service.
+func scopesForMethod(fullMethod string) []string {
+	if scopes, ok := apiKeyMethodScopes[fullMethod]; ok {
+		return scopes
+	}
+	if i := strings.LastIndex(fullMethod, "/"); i >= 0 {
+		return apiKeyMethodScopes[fullMethod[:i+1]]
+	}
+	return nil
+}
+
+func checkAPIKeyMethod(ctx context.Context, fullMethod string) error {
+	sCtx, err := authcontext.FromContext(ctx)
+	if err != nil || sCtx.Claims == nil {
+		// Requests without claims are only let through for methods with auth disabled.
+		return nil
+	}
+	if len(sCtx.Claims.GetUserClaims().GetAPIKeyScopes()) == 0 {
+		return nil
+	}
+	for _, scope := range scopesForMethod(fullMethod) {
+		if srvutils.HasAPIKeyScope(sCtx.Claims, scope) {
+			return nil
+		}
+	}
+	return status.Errorf(codes.PermissionDenied, "API key is not scoped to call %s", fullMethod)
+}
+
+// requestClusterID returns the ID of the cluster targeted by the request, if any.
+func requestClusterID(fullMethod string, req interface{}) string {
+	switch r := req.(type) {
+	case interface{ GetClusterID() string }:
+		return r.GetClusterID()
+	case interface{ GetClusterID() *uuidpb.UUID }:
+		return utils.UUIDFromProtoOrNil(r.GetClusterID()).String()
+	case interface{ GetID() *uuidpb.UUID }:
+		// The requests of the cluster info service are keyed by the ID of the cluster.
+		if strings.HasPrefix(fullMethod, clusterInfoServicePrefix) && r.GetID() != nil {
+			return utils.UUIDFromProtoOrNil(r.GetID()).String()
+		}
+	}
+	return ""
+}
+
+func checkAPIKeyCluster(ctx context.Context, fullMethod string, req interface{}) error {
+	sCtx, err := authcontext.FromContext(ctx)
+	if err != nil || sCtx.Claims == nil {
+		return nil
+	}
+	clusterID := requestClusterID(fullMethod, req)
+	if clusterID == "" || srvutils.CanAccessCluster(sCtx.Claims, clusterID) {
+		return nil
+	}
+	return status.Errorf(codes.PermissionDenied, "API key is not allowed to access cluster %s", clusterID)
+}
+
+// APIKeyScopeUnaryInterceptor rejects calls made with a scoped API key which are outside of its
+// scopes or cluster allowlist. It must run after the request has been authenticated.
+func APIKeyScopeUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := checkAPIKeyMethod(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		if err := checkAPIKeyCluster(ctx, info.FullMethod, req); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+type apiKeyScopeServerStream struct {
+	grpc.ServerStream
+	fullMethod string
+}
+
+func (s *apiKeyScopeServerStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return checkAPIKeyCluster(s.Context(), s.fullMethod, m)
+}
+
+// APIKeyScopeStreamInterceptor is the streaming counterpart of APIKeyScopeUnaryInterceptor. The
+// cluster allowlist is checked as each request message is received.
+func APIKeyScopeStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := checkAPIKeyMethod(stream.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, &apiKeyScopeServerStream{ServerStream: stream, fullMethod: info.FullMethod})
+	}
+}
+
+// WithoutRestrictedAPIKeys rejects requests made with API keys restricted to scopes or clusters.
+// It is used for the GraphQL API, whose resolvers span all of the scopes.
+func WithoutRestrictedAPIKeys(next http.Handler) http.Handler {
+	f := func(w http.ResponseWriter, r *http.Request) {
+		sCtx, err := authcontext.FromContext(r.Context())
+		if err == nil && sCtx.Claims != nil && srvutils.IsRestrictedAPIKey(sCtx.Claims) {
+			http.Error(w, "scoped API keys cannot be used with the GraphQL API", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(f)
+}
@@ -0,0 +1,188 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package controllers_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"px.dev/pixie/src/api/proto/cloudpb"
+	"px.dev/pixie/src/api/proto/vizierpb"
+	"px.dev/pixie/src/cloud/api/controllers"
+	"px.dev/pixie/src/shared/services/authcontext"
+	svcutils "px.dev/pixie/src/shared/services/utils"
+	"px.dev/pixie/src/utils"
+)
+
+const (
+	testAllowedClusterID = "7ba7b810-9dad-11d1-80b4-00c04fd430c8"
+	testOtherClusterID   = "8ba7b810-9dad-11d1-80b4-00c04fd430c8"
+)
+
+func createScopedAPIUserTestContext(scopes []string, clusterIDs []string) context.Context {
+	sCtx := authcontext.New()
+	sCtx.Claims = svcutils.GenerateJWTForAPIUser("6ba7b810-9dad-11d1-80b4-00c04fd430c9", "6ba7b810-9dad-11d1-80b4-00c04fd430c8", time.Now(), "pixie")
+	sCtx.Claims.GetUserClaims().APIKeyScopes = scopes
+	sCtx.Claims.GetUserClaims().AllowedClusterIDs = clusterIDs
+	return authcontext.NewContext(context.Background(), sCtx)
+}
+
+func TestAPIKeyScopeUnaryInterceptor(t *testing.T) {
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		method string
+		req    interface{}
+		code   codes.Code
+	}{
+		{
+			name:   "unscoped key",
+			ctx:    CreateAPIUserTestContext(),
+			method: "/px.cloudapi.APIKeyManager/Create",
+			req:    &cloudpb.CreateAPIKeyRequest{},
+			code:   codes.OK,
+		},
+		{
+			name:   "method in scope",
+			ctx:    createScopedAPIUserTestContext([]string{svcutils.APIKeyScopeScriptExecute}, nil),
+			method: "/px.cloudapi.VizierClusterInfo/GetClusterInfo",
+			req:    &cloudpb.GetClusterInfoRequest{},
+			code:   codes.OK,
+		},
+		{
+			name:   "status history in script execute scope",
+			ctx:    createScopedAPIUserTestContext([]string{svcutils.APIKeyScopeScriptExecute}, nil),
+			method: "/px.cloudapi.VizierClusterInfo/GetClusterStatusHistory",
+			req:    &cloudpb.GetClusterStatusHistoryRequest{},
+			code:   codes.OK,
+		},
+		{
+			name:   "service in scope",
+			ctx:    createScopedAPIUserTestContext([]string{svcutils.APIKeyScopeDeployKeyAdmin}, nil),
+			method: "/px.cloudapi.VizierDeploymentKeyManager/List",
+			req:    &cloudpb.ListDeploymentKeyRequest{},
+			code:   codes.OK,
+		},
+		{
+			name:   "upgrade campaigns in cluster admin scope",
+			ctx:    createScopedAPIUserTestContext([]string{svcutils.APIKeyScopeClusterAdmin}, nil),
+			method: "/px.cloudapi.VizierUpgradeCampaignService/CreateUpgradeCampaign",
+			req:    &cloudpb.CreateUpgradeCampaignRequest{},
+			code:   codes.OK,
+		},
+		{
+			name:   "method out of scope",
+			ctx:    createScopedAPIUserTestContext([]string{svcutils.APIKeyScopeScriptExecute}, nil),
+			method: "/px.cloudapi.VizierClusterInfo/UpdateOrInstallCluster",
+			req:    &cloudpb.UpdateOrInstallClusterRequest{},
+			code:   codes.PermissionDenied,
+		},
+		{
+			name:   "unlisted method",
+			ctx:    createScopedAPIUserTestContext(svcutils.APIKeyScopes, nil),
+			method: "/px.cloudapi.APIKeyManager/Create",
+			req:    &cloudpb.CreateAPIKeyRequest{},
+			code:   codes.PermissionDenied,
+		},
+		{
+			name:   "allowed cluster",
+			ctx:    createScopedAPIUserTestContext(nil, []string{testAllowedClusterID}),
+			method: "/px.cloudapi.VizierClusterInfo/GetClusterConnectionInfo",
+			req:    &cloudpb.GetClusterConnectionInfoRequest{ID: utils.ProtoFromUUIDStrOrNil(testAllowedClusterID)},
+			code:   codes.OK,
+		},
+		{
+			name:   "disallowed cluster",
+			ctx:    createScopedAPIUserTestContext(nil, []string{testAllowedClusterID}),
+			method: "/px.cloudapi.VizierClusterInfo/UpdateOrInstallCluster",
+			req:    &cloudpb.UpdateOrInstallClusterRequest{ClusterID: utils.ProtoFromUUIDStrOrNil(testOtherClusterID)},
+			code:   codes.PermissionDenied,
+		},
+	}
+
+	interceptor := controllers.APIKeyScopeUnaryInterceptor()
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return req, nil
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := interceptor(test.ctx, test.req, &grpc.UnaryServerInfo{FullMethod: test.method}, handler)
+			assert.Equal(t, test.code, status.Code(err))
+		})
+	}
+}
+
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+	req *vizierpb.ExecuteScriptRequest
+}
+
+func (s *fakeServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeServerStream) RecvMsg(m interface{}) error {
+	*m.(*vizierpb.ExecuteScriptRequest) = *s.req
+	return nil
+}
+
+func TestAPIKeyScopeStreamInterceptor(t *testing.T) {
+	interceptor := controllers.APIKeyScopeStreamInterceptor()
+	info := &grpc.StreamServerInfo{FullMethod: "/px.api.vizierpb.VizierService/ExecuteScript"}
+	handler := func(srv interface{}, stream grpc.ServerStream) error {
+		return stream.RecvMsg(&vizierpb.ExecuteScriptRequest{})
+	}
+	ctx := createScopedAPIUserTestContext([]string{svcutils.APIKeyScopeScriptExecute}, []string{testAllowedClusterID})
+
+	err := interceptor(nil, &fakeServerStream{ctx: ctx, req: &vizierpb.ExecuteScriptRequest{ClusterID: testAllowedClusterID}}, info, handler)
+	assert.NoError(t, err)
+
+	err = interceptor(nil, &fakeServerStream{ctx: ctx, req: &vizierpb.ExecuteScriptRequest{ClusterID: testOtherClusterID}}, info, handler)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	pluginCtx := createScopedAPIUserTestContext([]string{svcutils.APIKeyScopePluginConfig}, nil)
+	err = interceptor(nil, &fakeServerStream{ctx: pluginCtx, req: &vizierpb.ExecuteScriptRequest{}}, info, handler)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestWithoutRestrictedAPIKeys(t *testing.T) {
+	h := controllers.WithoutRestrictedAPIKeys(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest("POST", "/api/graphql", nil).WithContext(CreateAPIUserTestContext())
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	ctx := createScopedAPIUserTestContext([]string{svcutils.APIKeyScopeScriptExecute}, nil)
+	req = httptest.NewRequest("POST", "/api/graphql", nil).WithContext(ctx)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
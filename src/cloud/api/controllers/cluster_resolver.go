@@ -40,6 +40,10 @@ type clusterArgs struct {
 	ID graphql.ID
 }
 
+type clustersArgs struct {
+	Selector *string
+}
+
 func timestampProtoToMillis(ts *types.Timestamp) float64 {
 	return float64(ts.Seconds*NanosPerSecond+int64(ts.Nanos)) / 1e6
 }
@@ -156,6 +160,13 @@ type ClusterInfoResolver struct {
 	NumInstrumentedNodes          int32
 	PreviousStatus                *string
 	PreviousStatusTimeMs          *float64
+	Tags                          []ClusterTagResolver
+}
+
+// ClusterTagResolver is the resolver responsible for a cluster tag.
+type ClusterTagResolver struct {
+	Key   string
+	Value string
 }
 
 // ID returns cluster ID.
@@ -179,6 +190,18 @@ func mapPodStatusArray(inputStatuses map[string]*cloudpb.PodStatus) []PodStatusR
 	return podStatuses
 }
 
+// Helper to map proto tags to GQL tags, sorted by key.
+func mapClusterTags(tags map[string]string) []ClusterTagResolver {
+	res := make([]ClusterTagResolver, 0, len(tags))
+	for k, v := range tags {
+		res = append(res, ClusterTagResolver{Key: k, Value: v})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Key < res[j].Key
+	})
+	return res
+}
+
 func clusterInfoToResolver(cluster *cloudpb.ClusterInfo) (*ClusterInfoResolver, error) {
 	clusterID, err := utils.UUIDFromProto(cluster.ID)
 	if err != nil {
@@ -200,6 +223,7 @@ func clusterInfoToResolver(cluster *cloudpb.ClusterInfo) (*ClusterInfoResolver,
 		UnhealthyDataPlanePodStatuses: mapPodStatusArray(cluster.UnhealthyDataPlanePodStatuses),
 		NumNodes:                      cluster.NumNodes,
 		NumInstrumentedNodes:          cluster.NumInstrumentedNodes,
+		Tags:                          mapClusterTags(cluster.Tags),
 	}
 
 	if cluster.PreviousStatusTime != nil {
@@ -212,10 +236,14 @@ func clusterInfoToResolver(cluster *cloudpb.ClusterInfo) (*ClusterInfoResolver,
 	return resolver, nil
 }
 
-// Clusters lists all of the clusters.
-func (q *QueryResolver) Clusters(ctx context.Context) ([]*ClusterInfoResolver, error) {
+// Clusters lists all of the clusters, filtered by a selector on their tags if specified.
+func (q *QueryResolver) Clusters(ctx context.Context, args clustersArgs) ([]*ClusterInfoResolver, error) {
+	req := &cloudpb.GetClusterInfoRequest{}
+	if args.Selector != nil {
+		req.Selector = *args.Selector
+	}
 	grpcAPI := q.Env.VizierClusterInfo
-	resp, err := grpcAPI.GetClusterInfo(ctx, &cloudpb.GetClusterInfoRequest{})
+	resp, err := grpcAPI.GetClusterInfo(ctx, req)
 	if err != nil {
 		return nil, rpcErrorHelper(err)
 	}
@@ -40,6 +40,7 @@ import (
 func CreateTestContext() context.Context {
 	sCtx := authcontext.New()
 	sCtx.Claims = svcutils.GenerateJWTForUser("6ba7b810-9dad-11d1-80b4-00c04fd430c9", "6ba7b810-9dad-11d1-80b4-00c04fd430c8", "test@test.com", time.Now(), "pixie")
+	sCtx.Claims.GetUserClaims().OrgRole = svcutils.OrgRoleOwner
 	return authcontext.NewContext(context.Background(), sCtx)
 }
 
@@ -52,6 +53,7 @@ func CreateTestContextNoOrg() context.Context {
 func CreateAPIUserTestContext() context.Context {
 	sCtx := authcontext.New()
 	sCtx.Claims = svcutils.GenerateJWTForAPIUser("6ba7b810-9dad-11d1-80b4-00c04fd430c9", "6ba7b810-9dad-11d1-80b4-00c04fd430c8", time.Now(), "pixie")
+	sCtx.Claims.GetUserClaims().OrgRole = svcutils.OrgRoleOwner
 	return authcontext.NewContext(context.Background(), sCtx)
 }
 
@@ -103,6 +105,7 @@ func TestClusterInfo(t *testing.T) {
 				PreviousStatus:          cloudpb.CS_UNHEALTHY,
 				PreviousStatusTime:      types.TimestampNow(),
 				ControlPlanePodStatuses: ctrlPlane,
+				Tags:                    map[string]string{"region": "us", "env": "prod"},
 			}
 
 			mockClients.MockVizierClusterInfo.EXPECT().
@@ -136,6 +139,10 @@ func TestClusterInfo(t *testing.T) {
 								clusterUID
 								statusMessage
 								previousStatus
+								tags {
+									key
+									value
+								}
 							}
 						}
 					`,
@@ -156,7 +163,11 @@ func TestClusterInfo(t *testing.T) {
 								"clusterName": "clusterName",
 								"clusterUID": "clusterUID",
 								"statusMessage": "Everything is running",
-								"previousStatus": "CS_UNHEALTHY"
+								"previousStatus": "CS_UNHEALTHY",
+								"tags": [
+									{"key": "env", "value": "prod"},
+									{"key": "region", "value": "us"}
+								]
 							}
 						}
 					`,
@@ -255,3 +266,51 @@ func TestClusterInfoByName(t *testing.T) {
 		})
 	}
 }
+
+func TestClustersWithSelector(t *testing.T) {
+	gqlEnv, mockClients, cleanup := testutils.CreateTestGraphQLEnv(t)
+	defer cleanup()
+	ctx := CreateTestContext()
+
+	clusterInfo := &cloudpb.ClusterInfo{
+		ID:          utils.ProtoFromUUIDStrOrNil("7ba7b810-9dad-11d1-80b4-00c04fd430c8"),
+		Status:      cloudpb.CS_HEALTHY,
+		ClusterName: "clusterName",
+		Tags:        map[string]string{"env": "prod"},
+	}
+
+	mockClients.MockVizierClusterInfo.EXPECT().
+		GetClusterInfo(gomock.Any(), &cloudpb.GetClusterInfoRequest{Selector: "env=prod"}).
+		Return(&cloudpb.GetClusterInfoResponse{
+			Clusters: []*cloudpb.ClusterInfo{clusterInfo},
+		}, nil)
+
+	gqlSchema := LoadSchema(gqlEnv)
+	gqltesting.RunTests(t, []*gqltesting.Test{
+		{
+			Schema:  gqlSchema,
+			Context: ctx,
+			Query: `
+				query {
+					clusters(selector: "env=prod") {
+						id
+						clusterName
+						tags {
+							key
+							value
+						}
+					}
+				}
+			`,
+			ExpectedResult: `
+				{
+					"clusters": [{
+						"id": "7ba7b810-9dad-11d1-80b4-00c04fd430c8",
+						"clusterName": "clusterName",
+						"tags": [{"key": "env", "value": "prod"}]
+					}]
+				}
+			`,
+		},
+	})
+}
@@ -30,6 +30,7 @@ import (
 	"px.dev/pixie/src/api/proto/uuidpb"
 	"px.dev/pixie/src/cloud/vzmgr/vzmgrpb"
 	"px.dev/pixie/src/shared/services/authcontext"
+	srvutils "px.dev/pixie/src/shared/services/utils"
 	"px.dev/pixie/src/utils"
 )
 
@@ -61,6 +62,9 @@ func deployKeyMetadataToCloudAPI(key *vzmgrpb.DeploymentKeyMetadata) *cloudpb.De
 
 // Create creates a new deploy key in vzmgr.
 func (v *VizierDeploymentKeyServer) Create(ctx context.Context, req *cloudpb.CreateDeploymentKeyRequest) (*cloudpb.DeploymentKey, error) {
+	if err := requireOrgRole(ctx, srvutils.OrgRoleAdmin); err != nil {
+		return nil, err
+	}
 	ctx, err := contextWithAuthToken(ctx)
 	if err != nil {
 		return nil, err
@@ -90,6 +94,9 @@ func (v *VizierDeploymentKeyServer) Create(ctx context.Context, req *cloudpb.Cre
 
 // List lists all of the deploy keys in vzmgr.
 func (v *VizierDeploymentKeyServer) List(ctx context.Context, req *cloudpb.ListDeploymentKeyRequest) (*cloudpb.ListDeploymentKeyResponse, error) {
+	if err := requireOrgRole(ctx, srvutils.OrgRoleAdmin); err != nil {
+		return nil, err
+	}
 	ctx, err := contextWithAuthToken(ctx)
 	if err != nil {
 		return nil, err
@@ -120,6 +127,9 @@ func (v *VizierDeploymentKeyServer) List(ctx context.Context, req *cloudpb.ListD
 
 // Get fetches a specific deploy key in vzmgr.
 func (v *VizierDeploymentKeyServer) Get(ctx context.Context, req *cloudpb.GetDeploymentKeyRequest) (*cloudpb.GetDeploymentKeyResponse, error) {
+	if err := requireOrgRole(ctx, srvutils.OrgRoleAdmin); err != nil {
+		return nil, err
+	}
 	ctx, err := contextWithAuthToken(ctx)
 	if err != nil {
 		return nil, err
@@ -147,6 +157,9 @@ func (v *VizierDeploymentKeyServer) Get(ctx context.Context, req *cloudpb.GetDep
 
 // Delete deletes a specific deploy key in vzmgr.
 func (v *VizierDeploymentKeyServer) Delete(ctx context.Context, uuid *uuidpb.UUID) (*types.Empty, error) {
+	if err := requireOrgRole(ctx, srvutils.OrgRoleAdmin); err != nil {
+		return nil, err
+	}
 	ctx, err := contextWithAuthToken(ctx)
 	if err != nil {
 		return nil, err
@@ -171,6 +184,9 @@ func (v *VizierDeploymentKeyServer) Delete(ctx context.Context, uuid *uuidpb.UUI
 
 // LookupDeploymentKey gets the complete API key information using just the Key.
 func (v *VizierDeploymentKeyServer) LookupDeploymentKey(ctx context.Context, req *cloudpb.LookupDeploymentKeyRequest) (*cloudpb.LookupDeploymentKeyResponse, error) {
+	if err := requireOrgRole(ctx, srvutils.OrgRoleAdmin); err != nil {
+		return nil, err
+	}
 	ctx, err := contextWithAuthToken(ctx)
 	if err != nil {
 		return nil, err
@@ -33,6 +33,7 @@ import (
 	"px.dev/pixie/src/cloud/profile/profilepb"
 	"px.dev/pixie/src/shared/services/authcontext"
 	"px.dev/pixie/src/shared/services/events"
+	srvutils "px.dev/pixie/src/shared/services/utils"
 	"px.dev/pixie/src/utils"
 )
 
@@ -45,6 +46,9 @@ type OrganizationServiceServer struct {
 
 // InviteUser creates and returns an invite link for the org for the specified user info.
 func (o *OrganizationServiceServer) InviteUser(ctx context.Context, externalReq *cloudpb.InviteUserRequest) (*cloudpb.InviteUserResponse, error) {
+	if err := requireOrgRole(ctx, srvutils.OrgRoleAdmin); err != nil {
+		return nil, err
+	}
 	ctx, err := contextWithAuthToken(ctx)
 	if err != nil {
 		return nil, err
@@ -144,8 +148,9 @@ func (o *OrganizationServiceServer) CreateOrg(ctx context.Context, req *cloudpb.
 			Set("org_id", utils.ProtoToUUIDStr(orgID)),
 	})
 	_, err = o.ProfileServiceClient.UpdateUser(ctx, &profilepb.UpdateUserRequest{
-		ID:    utils.ProtoFromUUIDStrOrNil(sCtx.Claims.GetUserClaims().UserID),
-		OrgID: orgID,
+		ID:      utils.ProtoFromUUIDStrOrNil(sCtx.Claims.GetUserClaims().UserID),
+		OrgID:   orgID,
+		OrgRole: &types.StringValue{Value: srvutils.OrgRoleOwner},
 	})
 	if err != nil {
 		return nil, err
@@ -155,6 +160,9 @@ func (o *OrganizationServiceServer) CreateOrg(ctx context.Context, req *cloudpb.
 
 // UpdateOrg will update org approval details.
 func (o *OrganizationServiceServer) UpdateOrg(ctx context.Context, req *cloudpb.UpdateOrgRequest) (*cloudpb.OrgInfo, error) {
+	if err := requireOrgRole(ctx, srvutils.OrgRoleAdmin); err != nil {
+		return nil, err
+	}
 	ctx, err := contextWithAuthToken(ctx)
 	if err != nil {
 		return nil, err
@@ -218,6 +226,7 @@ func (o *OrganizationServiceServer) GetUsersInOrg(ctx context.Context, req *clou
 			Email:          user.Email,
 			ProfilePicture: user.ProfilePicture,
 			IsApproved:     user.IsApproved,
+			OrgRole:        user.OrgRole,
 		}
 	}
 
@@ -228,6 +237,9 @@ func (o *OrganizationServiceServer) GetUsersInOrg(ctx context.Context, req *clou
 
 // RemoveUserFromOrg will remove the given user from this org.
 func (o *OrganizationServiceServer) RemoveUserFromOrg(ctx context.Context, req *cloudpb.RemoveUserFromOrgRequest) (*cloudpb.RemoveUserFromOrgResponse, error) {
+	if err := requireOrgRole(ctx, srvutils.OrgRoleAdmin); err != nil {
+		return nil, err
+	}
 	ctx, err := contextWithAuthToken(ctx)
 	if err != nil {
 		return nil, err
@@ -259,6 +271,9 @@ func (o *OrganizationServiceServer) RemoveUserFromOrg(ctx context.Context, req *
 
 // AddOrgIDEConfig adds the IDE config for the given org.
 func (o *OrganizationServiceServer) AddOrgIDEConfig(ctx context.Context, req *cloudpb.AddOrgIDEConfigRequest) (*cloudpb.AddOrgIDEConfigResponse, error) {
+	if err := requireOrgRole(ctx, srvutils.OrgRoleAdmin); err != nil {
+		return nil, err
+	}
 	ctx, err := contextWithAuthToken(ctx)
 	if err != nil {
 		return nil, err
@@ -293,6 +308,9 @@ func (o *OrganizationServiceServer) AddOrgIDEConfig(ctx context.Context, req *cl
 
 // DeleteOrgIDEConfig deletes the IDE config from the given org.
 func (o *OrganizationServiceServer) DeleteOrgIDEConfig(ctx context.Context, req *cloudpb.DeleteOrgIDEConfigRequest) (*cloudpb.DeleteOrgIDEConfigResponse, error) {
+	if err := requireOrgRole(ctx, srvutils.OrgRoleAdmin); err != nil {
+		return nil, err
+	}
 	ctx, err := contextWithAuthToken(ctx)
 	if err != nil {
 		return nil, err
@@ -355,6 +373,9 @@ func (o *OrganizationServiceServer) GetOrgIDEConfigs(ctx context.Context, req *c
 
 // CreateInviteToken creates a signed invite JWT for the given org with an expiration of 1 week.
 func (o *OrganizationServiceServer) CreateInviteToken(ctx context.Context, req *cloudpb.CreateInviteTokenRequest) (*cloudpb.InviteToken, error) {
+	if err := requireOrgRole(ctx, srvutils.OrgRoleAdmin); err != nil {
+		return nil, err
+	}
 	ctx, err := contextWithAuthToken(ctx)
 	if err != nil {
 		return nil, err
@@ -380,6 +401,9 @@ func (o *OrganizationServiceServer) CreateInviteToken(ctx context.Context, req *
 
 // RevokeAllInviteTokens revokes all pending invited for the given org by rotating the JWT signing key.
 func (o *OrganizationServiceServer) RevokeAllInviteTokens(ctx context.Context, req *uuidpb.UUID) (*types.Empty, error) {
+	if err := requireOrgRole(ctx, srvutils.OrgRoleAdmin); err != nil {
+		return nil, err
+	}
 	ctx, err := contextWithAuthToken(ctx)
 	if err != nil {
 		return nil, err
@@ -406,3 +430,51 @@ func (o *OrganizationServiceServer) VerifyInviteToken(ctx context.Context, req *
 	}
 	return &cloudpb.VerifyInviteTokenResponse{Valid: resp.Valid}, nil
 }
+
+// GetAuditEvents gets the audit trail of administrative actions taken in the org.
+func (o *OrganizationServiceServer) GetAuditEvents(ctx context.Context, req *cloudpb.GetAuditEventsRequest) (*cloudpb.GetAuditEventsResponse, error) {
+	if err := requireOrgRole(ctx, srvutils.OrgRoleAdmin); err != nil {
+		return nil, err
+	}
+	ctx, err := contextWithAuthToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sCtx, err := authcontext.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if uuid.FromStringOrNil(sCtx.Claims.GetUserClaims().OrgID) != utils.UUIDFromProtoOrNil(req.OrgID) {
+		return nil, status.Errorf(codes.PermissionDenied, "Could not get audit events for org")
+	}
+
+	resp, err := o.OrgServiceClient.GetAuditEvents(ctx, &profilepb.GetAuditEventsRequest{
+		OrgID:        req.OrgID,
+		StartTime:    req.StartTime,
+		EndTime:      req.EndTime,
+		ActorID:      req.ActorID,
+		ResourceType: req.ResourceType,
+		ResourceID:   req.ResourceID,
+		Limit:        req.Limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]*cloudpb.AuditEvent, len(resp.Events))
+	for i, e := range resp.Events {
+		events[i] = &cloudpb.AuditEvent{
+			ID:           e.ID,
+			Time:         e.Time,
+			ActorType:    e.ActorType,
+			ActorID:      e.ActorID,
+			Action:       e.Action,
+			ResourceType: e.ResourceType,
+			ResourceID:   e.ResourceID,
+			Before:       e.Before,
+			After:        e.After,
+		}
+	}
+	return &cloudpb.GetAuditEventsResponse{Events: events}, nil
+}
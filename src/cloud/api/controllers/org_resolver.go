@@ -87,6 +87,7 @@ func (q *QueryResolver) OrgUsers(ctx context.Context) ([]*UserInfoResolver, erro
 			Email:          user.Email,
 			ProfilePicture: user.ProfilePicture,
 			IsApproved:     user.IsApproved,
+			OrgRole:        user.OrgRole,
 		}}
 	}
 
@@ -0,0 +1,62 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package controllers
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"px.dev/pixie/src/shared/services/authcontext"
+	srvutils "px.dev/pixie/src/shared/services/utils"
+)
+
+// requireOrgRole returns a PermissionDenied error unless the caller holds at least minRole in their
+// org. The check lives in the handlers, rather than in an interceptor, so that it also covers the
+// GraphQL resolvers which call the handlers directly.
+func requireOrgRole(ctx context.Context, minRole string) error {
+	sCtx, err := authcontext.FromContext(ctx)
+	if err != nil {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+	if srvutils.GetClaimsType(sCtx.Claims) != srvutils.UserClaimType {
+		return nil
+	}
+	if !srvutils.HasOrgRole(sCtx.Claims, minRole) {
+		return status.Errorf(codes.PermissionDenied, "the %s role is required for this action", minRole)
+	}
+	return nil
+}
+
+// checkCanAssignOrgRole checks that the caller may change the role of a user from currentRole to
+// newRole. Admins manage members and viewers, while only owners may grant or take away the admin
+// and owner roles.
+func checkCanAssignOrgRole(ctx context.Context, currentRole string, newRole string) error {
+	if !srvutils.IsValidOrgRole(newRole) {
+		return status.Errorf(codes.InvalidArgument, "invalid org role %q", newRole)
+	}
+	if err := requireOrgRole(ctx, srvutils.OrgRoleAdmin); err != nil {
+		return err
+	}
+	if srvutils.OrgRoleAtLeast(newRole, srvutils.OrgRoleAdmin) || srvutils.OrgRoleAtLeast(currentRole, srvutils.OrgRoleAdmin) {
+		return requireOrgRole(ctx, srvutils.OrgRoleOwner)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package controllers_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/types"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"px.dev/pixie/src/api/proto/cloudpb"
+	"px.dev/pixie/src/cloud/api/controllers"
+	"px.dev/pixie/src/cloud/api/controllers/testutils"
+	"px.dev/pixie/src/cloud/profile/profilepb"
+	"px.dev/pixie/src/shared/services/authcontext"
+	svcutils "px.dev/pixie/src/shared/services/utils"
+	"px.dev/pixie/src/utils"
+)
+
+const testOtherUserID = "7ba7b810-9dad-11d1-80b4-00c04fd430c9"
+
+func createOrgRoleTestContext(role string) context.Context {
+	sCtx := authcontext.New()
+	sCtx.Claims = svcutils.GenerateJWTForUser("6ba7b810-9dad-11d1-80b4-00c04fd430c9", "6ba7b810-9dad-11d1-80b4-00c04fd430c8", "test@test.com", time.Now(), "pixie")
+	sCtx.Claims.GetUserClaims().OrgRole = role
+	return authcontext.NewContext(context.Background(), sCtx)
+}
+
+func TestOrgRoles_AdminOnlyActions(t *testing.T) {
+	_, mockClients, cleanup := testutils.CreateTestAPIEnv(t)
+	defer cleanup()
+
+	deployKeyServer := &controllers.VizierDeploymentKeyServer{VzDeploymentKey: mockClients.MockVzDeployKey}
+	_, err := deployKeyServer.Create(createOrgRoleTestContext(svcutils.OrgRoleMember), &cloudpb.CreateDeploymentKeyRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	pluginServer := &controllers.PluginServiceServer{}
+	_, err = pluginServer.UpdateRetentionPluginConfig(createOrgRoleTestContext(svcutils.OrgRoleViewer), &cloudpb.UpdateRetentionPluginConfigRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = pluginServer.RegisterRetentionPlugin(createOrgRoleTestContext(svcutils.OrgRoleMember), &cloudpb.RegisterRetentionPluginRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	clusterServer := &controllers.VizierClusterInfo{VzMgr: mockClients.MockVzMgr}
+	_, err = clusterServer.UpdateClusterTags(createOrgRoleTestContext(svcutils.OrgRoleMember), &cloudpb.UpdateClusterTagsRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	campaignServer := &controllers.VizierUpgradeCampaignServer{}
+	_, err = campaignServer.CreateUpgradeCampaign(createOrgRoleTestContext(svcutils.OrgRoleMember), &cloudpb.CreateUpgradeCampaignRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = campaignServer.SetUpgradeCampaignState(createOrgRoleTestContext(svcutils.OrgRoleMember), &cloudpb.SetUpgradeCampaignStateRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	orgServer := &controllers.OrganizationServiceServer{}
+	_, err = orgServer.RevokeAllInviteTokens(createOrgRoleTestContext(svcutils.OrgRoleMember), utils.ProtoFromUUIDStrOrNil("6ba7b810-9dad-11d1-80b4-00c04fd430c8"))
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestServer_UpdateUser_OrgRole(t *testing.T) {
+	tests := []struct {
+		name        string
+		callerRole  string
+		currentRole string
+		newRole     string
+		code        codes.Code
+	}{
+		{
+			name:        "admin promotes viewer to member",
+			callerRole:  svcutils.OrgRoleAdmin,
+			currentRole: svcutils.OrgRoleViewer,
+			newRole:     svcutils.OrgRoleMember,
+			code:        codes.OK,
+		},
+		{
+			name:        "member cannot change roles",
+			callerRole:  svcutils.OrgRoleMember,
+			currentRole: svcutils.OrgRoleViewer,
+			newRole:     svcutils.OrgRoleMember,
+			code:        codes.PermissionDenied,
+		},
+		{
+			name:        "admin cannot grant admin",
+			callerRole:  svcutils.OrgRoleAdmin,
+			currentRole: svcutils.OrgRoleMember,
+			newRole:     svcutils.OrgRoleAdmin,
+			code:        codes.PermissionDenied,
+		},
+		{
+			name:        "admin cannot demote owner",
+			callerRole:  svcutils.OrgRoleAdmin,
+			currentRole: svcutils.OrgRoleOwner,
+			newRole:     svcutils.OrgRoleMember,
+			code:        codes.PermissionDenied,
+		},
+		{
+			name:        "owner grants owner",
+			callerRole:  svcutils.OrgRoleOwner,
+			currentRole: svcutils.OrgRoleAdmin,
+			newRole:     svcutils.OrgRoleOwner,
+			code:        codes.OK,
+		},
+		{
+			name:        "invalid role",
+			callerRole:  svcutils.OrgRoleOwner,
+			currentRole: svcutils.OrgRoleMember,
+			newRole:     "superuser",
+			code:        codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, mockClients, cleanup := testutils.CreateTestAPIEnv(t)
+			defer cleanup()
+
+			userID := utils.ProtoFromUUIDStrOrNil(testOtherUserID)
+			orgID := utils.ProtoFromUUIDStrOrNil("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+			mockClients.MockProfile.EXPECT().GetUser(gomock.Any(), userID).
+				Return(&profilepb.UserInfo{ID: userID, OrgID: orgID, OrgRole: tc.currentRole}, nil)
+			if tc.code == codes.OK {
+				mockClients.MockProfile.EXPECT().UpdateUser(gomock.Any(), &profilepb.UpdateUserRequest{
+					ID:      userID,
+					OrgRole: &types.StringValue{Value: tc.newRole},
+				}).Return(&profilepb.UserInfo{ID: userID, OrgID: orgID, OrgRole: tc.newRole}, nil)
+			}
+
+			userServer := &controllers.UserServiceServer{ProfileServiceClient: mockClients.MockProfile, OrgServiceClient: mockClients.MockOrg}
+			resp, err := userServer.UpdateUser(createOrgRoleTestContext(tc.callerRole), &cloudpb.UpdateUserRequest{
+				ID:      userID,
+				OrgRole: &types.StringValue{Value: tc.newRole},
+			})
+			require.Equal(t, tc.code, status.Code(err))
+			if tc.code == codes.OK {
+				assert.Equal(t, tc.newRole, resp.OrgRole)
+			}
+		})
+	}
+}
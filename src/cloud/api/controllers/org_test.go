@@ -36,6 +36,7 @@ import (
 	"px.dev/pixie/src/cloud/api/controllers/testutils"
 	"px.dev/pixie/src/cloud/auth/authpb"
 	"px.dev/pixie/src/cloud/profile/profilepb"
+	svcutils "px.dev/pixie/src/shared/services/utils"
 	"px.dev/pixie/src/utils"
 )
 
@@ -122,8 +123,9 @@ func TestOrganizationServiceServer_CreateOrg_UserWithNoOrg(t *testing.T) {
 	}).Return(orgID, nil)
 
 	mockClients.MockProfile.EXPECT().UpdateUser(gomock.Any(), &profilepb.UpdateUserRequest{
-		ID:    utils.ProtoFromUUIDStrOrNil("6ba7b810-9dad-11d1-80b4-00c04fd430c9"),
-		OrgID: orgID,
+		ID:      utils.ProtoFromUUIDStrOrNil("6ba7b810-9dad-11d1-80b4-00c04fd430c9"),
+		OrgID:   orgID,
+		OrgRole: &types.StringValue{Value: "owner"},
 	}).Return(&profilepb.UserInfo{
 		ID:    utils.ProtoFromUUIDStrOrNil("6ba7b810-9dad-11d1-80b4-00c04fd430c9"),
 		OrgID: orgID,
@@ -334,6 +336,10 @@ func (*fakeOrg) VerifyInviteToken(ctx context.Context, _ *profilepb.InviteToken,
 	return &profilepb.VerifyInviteTokenResponse{}, nil
 }
 
+func (*fakeOrg) GetAuditEvents(ctx context.Context, _ *profilepb.GetAuditEventsRequest, _ ...grpc.CallOption) (*profilepb.GetAuditEventsResponse, error) {
+	return &profilepb.GetAuditEventsResponse{}, nil
+}
+
 func TestOrganizationServiceServer_CorrectOrgPermissions(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -408,6 +414,15 @@ func TestOrganizationServiceServer_CorrectOrgPermissions(t *testing.T) {
 				return err
 			},
 		},
+		{
+			name: "GetAuditEvents",
+			funcCall: func(ctx context.Context, os *controllers.OrganizationServiceServer, id *uuidpb.UUID) error {
+				_, err := os.GetAuditEvents(ctx, &cloudpb.GetAuditEventsRequest{
+					OrgID: id,
+				})
+				return err
+			},
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -471,3 +486,53 @@ func TestOrganizationServiceServer_GetOrgIDEConfigs(t *testing.T) {
 		},
 	}, resp.Configs)
 }
+
+func TestOrganizationServiceServer_GetAuditEvents(t *testing.T) {
+	_, mockClients, cleanup := testutils.CreateTestAPIEnv(t)
+	defer cleanup()
+
+	orgID := utils.ProtoFromUUIDStrOrNil("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+	eventID := utils.ProtoFromUUIDStrOrNil("8ba7b810-9dad-11d1-80b4-00c04fd430c8")
+	mockClients.MockOrg.EXPECT().GetAuditEvents(gomock.Any(), &profilepb.GetAuditEventsRequest{
+		OrgID:        orgID,
+		ResourceType: "api_key",
+		Limit:        10,
+	}).Return(&profilepb.GetAuditEventsResponse{
+		Events: []*profilepb.AuditEvent{
+			{
+				ID:           eventID,
+				OrgID:        orgID,
+				ActorType:    "user",
+				ActorID:      "6ba7b810-9dad-11d1-80b4-00c04fd430c9",
+				Action:       "api_key.create",
+				ResourceType: "api_key",
+				ResourceID:   "9ba7b810-9dad-11d1-80b4-00c04fd430c8",
+				After:        `{"desc": "test"}`,
+			},
+		},
+	}, nil)
+
+	os := &controllers.OrganizationServiceServer{mockClients.MockProfile, mockClients.MockAuth, mockClients.MockOrg}
+
+	req := &cloudpb.GetAuditEventsRequest{
+		OrgID:        orgID,
+		ResourceType: "api_key",
+		Limit:        10,
+	}
+	resp, err := os.GetAuditEvents(createOrgRoleTestContext(svcutils.OrgRoleAdmin), req)
+	require.NoError(t, err)
+	assert.Equal(t, []*cloudpb.AuditEvent{
+		{
+			ID:           eventID,
+			ActorType:    "user",
+			ActorID:      "6ba7b810-9dad-11d1-80b4-00c04fd430c9",
+			Action:       "api_key.create",
+			ResourceType: "api_key",
+			ResourceID:   "9ba7b810-9dad-11d1-80b4-00c04fd430c8",
+			After:        `{"desc": "test"}`,
+		},
+	}, resp.Events)
+
+	_, err = os.GetAuditEvents(createOrgRoleTestContext(svcutils.OrgRoleMember), req)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
@@ -20,17 +20,49 @@ package controllers
 
 import (
 	"context"
+	"time"
+
+	"github.com/blang/semver"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"px.dev/pixie/src/api/proto/cloudpb"
+	"px.dev/pixie/src/api/proto/uuidpb"
+	"px.dev/pixie/src/api/proto/vizierpb"
 	"px.dev/pixie/src/cloud/plugin/pluginpb"
+	"px.dev/pixie/src/cloud/vzmgr/vzmgrpb"
 	"px.dev/pixie/src/shared/services/authcontext"
+	srvutils "px.dev/pixie/src/shared/services/utils"
 	"px.dev/pixie/src/utils"
 )
 
+const (
+	defaultDryRunWindow     = 30 * time.Second
+	maxDryRunWindow         = time.Hour
+	defaultDryRunSampleSize = 10
+	maxDryRunSampleSize     = 100
+	// dryRunTimeout is how long a retention script is given to run during validation.
+	dryRunTimeout = time.Minute
+	// dryRunPlaceholderURL is the default export endpoint given to the Vizier when the script's
+	// export URL is unknown. No data is exported to it during a dry run.
+	dryRunPlaceholderURL = "localhost:4317"
+	// minDryRunVizierVersion is the first Vizier release which can dry run exports. Older Viziers
+	// ignore the dry run flag, and would export the script's data.
+	minDryRunVizierVersion = "0.14.0"
+)
+
+// VizierScriptExecutor executes scripts on a Vizier, such as through the passthrough proxy.
+type VizierScriptExecutor interface {
+	ExecuteScript(req *vizierpb.ExecuteScriptRequest, srv vizierpb.VizierService_ExecuteScriptServer) error
+}
+
 // PluginServiceServer is used to manage and configure plugins.
 type PluginServiceServer struct {
 	PluginServiceClient              pluginpb.PluginServiceClient
 	DataRetentionPluginServiceClient pluginpb.DataRetentionPluginServiceClient
+	VizierPassthrough                VizierScriptExecutor
+	VzMgr                            vzmgrpb.VZMgrServiceClient
 }
 
 func kindCloudProtoToPluginProto(kind cloudpb.PluginKind) pluginpb.PluginKind {
@@ -57,7 +89,8 @@ func (p *PluginServiceServer) GetPlugins(ctx context.Context, req *cloudpb.GetPl
 	}
 
 	pluginsResp, err := p.PluginServiceClient.GetPlugins(ctx, &pluginpb.GetPluginsRequest{
-		Kind: kindCloudProtoToPluginProto(req.Kind),
+		Kind:  kindCloudProtoToPluginProto(req.Kind),
+		OrgID: orgID,
 	})
 	if err != nil {
 		return nil, err
@@ -84,6 +117,7 @@ func (p *PluginServiceServer) GetPlugins(ctx context.Context, req *cloudpb.GetPl
 			Logo:               p.Logo,
 			LatestVersion:      p.LatestVersion,
 			RetentionSupported: p.RetentionEnabled,
+			Custom:             p.Custom,
 		}
 
 		if v, ok := enabledPlugins[p.ID]; ok {
@@ -128,7 +162,13 @@ func (p *PluginServiceServer) GetOrgRetentionPluginConfig(ctx context.Context, r
 
 // GetRetentionPluginInfo gets the retention plugin info for a particular plugin release.
 func (p *PluginServiceServer) GetRetentionPluginInfo(ctx context.Context, req *cloudpb.GetRetentionPluginInfoRequest) (*cloudpb.GetRetentionPluginInfoResponse, error) {
-	var err error
+	sCtx, err := authcontext.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	orgIDstr := sCtx.Claims.GetUserClaims().OrgID
+	orgID := utils.ProtoFromUUIDStrOrNil(orgIDstr)
+
 	ctx, err = contextWithAuthToken(ctx)
 	if err != nil {
 		return nil, err
@@ -137,6 +177,7 @@ func (p *PluginServiceServer) GetRetentionPluginInfo(ctx context.Context, req *c
 	configResp, err := p.PluginServiceClient.GetRetentionPluginConfig(ctx, &pluginpb.GetRetentionPluginConfigRequest{
 		ID:      req.PluginId,
 		Version: req.Version,
+		OrgID:   orgID,
 	})
 	if err != nil {
 		return nil, err
@@ -147,11 +188,15 @@ func (p *PluginServiceServer) GetRetentionPluginInfo(ctx context.Context, req *c
 		AllowCustomExportURL: configResp.AllowCustomExportURL,
 		AllowInsecureTLS:     configResp.AllowInsecureTLS,
 		DefaultExportURL:     configResp.DefaultExportURL,
+		RequiredConfigs:      configResp.RequiredConfigurations,
 	}, nil
 }
 
 // UpdateRetentionPluginConfig updates the retention plugin config for a plugin.
 func (p *PluginServiceServer) UpdateRetentionPluginConfig(ctx context.Context, req *cloudpb.UpdateRetentionPluginConfigRequest) (*cloudpb.UpdateRetentionPluginConfigResponse, error) {
+	if err := requireOrgRole(ctx, srvutils.OrgRoleAdmin); err != nil {
+		return nil, err
+	}
 	sCtx, err := authcontext.FromContext(ctx)
 	if err != nil {
 		return nil, err
@@ -261,6 +306,9 @@ func (p *PluginServiceServer) GetRetentionScript(ctx context.Context, req *cloud
 
 // UpdateRetentionScript updates a specific retention script.
 func (p *PluginServiceServer) UpdateRetentionScript(ctx context.Context, req *cloudpb.UpdateRetentionScriptRequest) (*cloudpb.UpdateRetentionScriptResponse, error) {
+	if err := requireOrgRole(ctx, srvutils.OrgRoleAdmin); err != nil {
+		return nil, err
+	}
 	var err error
 	ctx, err = contextWithAuthToken(ctx)
 	if err != nil {
@@ -286,6 +334,9 @@ func (p *PluginServiceServer) UpdateRetentionScript(ctx context.Context, req *cl
 
 // CreateRetentionScript creates a retention script.
 func (p *PluginServiceServer) CreateRetentionScript(ctx context.Context, req *cloudpb.CreateRetentionScriptRequest) (*cloudpb.CreateRetentionScriptResponse, error) {
+	if err := requireOrgRole(ctx, srvutils.OrgRoleAdmin); err != nil {
+		return nil, err
+	}
 	sCtx, err := authcontext.FromContext(ctx)
 	if err != nil {
 		return nil, err
@@ -323,6 +374,9 @@ func (p *PluginServiceServer) CreateRetentionScript(ctx context.Context, req *cl
 
 // DeleteRetentionScript deletes a specific retention script.
 func (p *PluginServiceServer) DeleteRetentionScript(ctx context.Context, req *cloudpb.DeleteRetentionScriptRequest) (*cloudpb.DeleteRetentionScriptResponse, error) {
+	if err := requireOrgRole(ctx, srvutils.OrgRoleAdmin); err != nil {
+		return nil, err
+	}
 	sCtx, err := authcontext.FromContext(ctx)
 	if err != nil {
 		return nil, err
@@ -345,3 +399,238 @@ func (p *PluginServiceServer) DeleteRetentionScript(ctx context.Context, req *cl
 
 	return &cloudpb.DeleteRetentionScriptResponse{}, nil
 }
+
+// RegisterRetentionPlugin registers a new version of a custom retention plugin for the org.
+func (p *PluginServiceServer) RegisterRetentionPlugin(ctx context.Context, req *cloudpb.RegisterRetentionPluginRequest) (*cloudpb.RegisterRetentionPluginResponse, error) {
+	if err := requireOrgRole(ctx, srvutils.OrgRoleAdmin); err != nil {
+		return nil, err
+	}
+	sCtx, err := authcontext.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	orgIDstr := sCtx.Claims.GetUserClaims().OrgID
+	orgID := utils.ProtoFromUUIDStrOrNil(orgIDstr)
+
+	ctx, err = contextWithAuthToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	presetScripts := make([]*pluginpb.GetRetentionPluginConfigResponse_PresetScript, len(req.PresetScripts))
+	for i, s := range req.PresetScripts {
+		presetScripts[i] = &pluginpb.GetRetentionPluginConfigResponse_PresetScript{
+			Name:              s.Name,
+			Description:       s.Description,
+			DefaultFrequencyS: s.DefaultFrequencyS,
+			Script:            s.Script,
+			DefaultDisabled:   s.DefaultDisabled,
+		}
+	}
+
+	resp, err := p.PluginServiceClient.RegisterRetentionPlugin(ctx, &pluginpb.RegisterRetentionPluginRequest{
+		OrgID:                  orgID,
+		ID:                     req.ID,
+		Name:                   req.Name,
+		Description:            req.Description,
+		Logo:                   req.Logo,
+		Version:                req.Version,
+		Configurations:         req.Configs,
+		RequiredConfigurations: req.RequiredConfigs,
+		PresetScripts:          presetScripts,
+		DocumentationURL:       req.DocumentationURL,
+		ExportURLTemplate:      req.ExportURLTemplate,
+		AllowCustomExportURL:   req.AllowCustomExportURL,
+		AllowInsecureTLS:       req.AllowInsecureTLS,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &cloudpb.RegisterRetentionPluginResponse{ID: resp.ID}, nil
+}
+
+// GetRetentionPluginVersions gets the released versions of a retention plugin.
+func (p *PluginServiceServer) GetRetentionPluginVersions(ctx context.Context, req *cloudpb.GetRetentionPluginVersionsRequest) (*cloudpb.GetRetentionPluginVersionsResponse, error) {
+	sCtx, err := authcontext.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	orgIDstr := sCtx.Claims.GetUserClaims().OrgID
+	orgID := utils.ProtoFromUUIDStrOrNil(orgIDstr)
+
+	ctx, err = contextWithAuthToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.PluginServiceClient.GetPluginVersions(ctx, &pluginpb.GetPluginVersionsRequest{
+		ID:    req.PluginId,
+		OrgID: orgID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &cloudpb.GetRetentionPluginVersionsResponse{Versions: resp.Versions}, nil
+}
+
+// ValidateRetentionScript compiles a retention script against a cluster and runs it once over a small
+// window. The script is run as is, with the Vizier's dry run flag set, so that its exports return
+// the data which would have been exported instead of exporting it. The number of rows and a sample
+// of the data are returned, along with whether the Vizier can reach the plugin's export endpoint.
+func (p *PluginServiceServer) ValidateRetentionScript(ctx context.Context, req *cloudpb.ValidateRetentionScriptRequest) (*cloudpb.ValidateRetentionScriptResponse, error) {
+	if utils.IsNilUUIDProto(req.ClusterID) {
+		return nil, status.Error(codes.InvalidArgument, "must specify a cluster")
+	}
+	if req.Contents == "" {
+		return nil, status.Error(codes.InvalidArgument, "must specify the script contents")
+	}
+	if req.WindowS < 0 || req.WindowS > int64(maxDryRunWindow.Seconds()) {
+		return nil, status.Errorf(codes.InvalidArgument, "window must be between 1 and %d seconds, or 0 for the default", int64(maxDryRunWindow.Seconds()))
+	}
+	if req.SampleSize < 0 || req.SampleSize > maxDryRunSampleSize {
+		return nil, status.Errorf(codes.InvalidArgument, "sample size must be between 1 and %d, or 0 for the default", maxDryRunSampleSize)
+	}
+	window := defaultDryRunWindow
+	if req.WindowS != 0 {
+		window = time.Duration(req.WindowS) * time.Second
+	}
+	sampleSize := int64(defaultDryRunSampleSize)
+	if req.SampleSize != 0 {
+		sampleSize = req.SampleSize
+	}
+
+	if err := p.checkVizierCanDryRun(ctx, req.ClusterID); err != nil {
+		return nil, err
+	}
+
+	// Only the export URL of the org's configuration is checked, so that the Vizier can't be used
+	// to probe arbitrary addresses.
+	exportURL := ""
+	insecureTLS := false
+	if req.PluginId != "" {
+		var err error
+		exportURL, insecureTLS, err = p.getOrgExportURL(ctx, req.PluginId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	resp := &cloudpb.ValidateRetentionScriptResponse{
+		Valid:     true,
+		ExportURL: exportURL,
+	}
+
+	dryRunCtx, cancel := context.WithTimeout(ctx, dryRunTimeout)
+	defer cancel()
+
+	// The endpoint is passed for scripts which export to the default endpoint. No data is exported
+	// to it during a dry run, but the Vizier checks that it can be reached.
+	endpointURL := exportURL
+	if endpointURL == "" {
+		endpointURL = dryRunPlaceholderURL
+	}
+	endTime := time.Now()
+	startTime := endTime.Add(-window)
+	srv := newDryRunStream(dryRunCtx, int(sampleSize))
+	err := p.VizierPassthrough.ExecuteScript(&vizierpb.ExecuteScriptRequest{
+		QueryStr:  req.Contents,
+		ClusterID: utils.UUIDFromProtoOrNil(req.ClusterID).String(),
+		Configs: &vizierpb.Configs{
+			OTelEndpointConfig: &vizierpb.Configs_OTelEndpointConfig{
+				URL:      endpointURL,
+				Insecure: insecureTLS,
+			},
+			PluginConfig: &vizierpb.Configs_PluginConfig{
+				StartTimeNs: startTime.UnixNano(),
+				EndTimeNs:   endTime.UnixNano(),
+			},
+			DryRunExports: true,
+		},
+		QueryName: "retention_script_dry_run",
+	}, srv)
+	// Errors which happen before the script reaches the cluster, such as the cluster being
+	// unavailable, are returned as is. Any later errors are failures of the script.
+	if err != nil && !srv.received {
+		return nil, err
+	}
+
+	if exportURL != "" {
+		resp.EndpointReachable = srv.endpointStatus.GetReachable()
+	}
+	resp.Tables = srv.tables
+	for _, t := range resp.Tables {
+		t.Export = exportTableRegex.MatchString(t.Name)
+	}
+	if srv.status != nil {
+		resp.Valid = false
+		resp.ErrorMessage = srv.status.Message
+		for _, d := range srv.status.ErrorDetails {
+			if ce := d.GetCompilerError(); ce != nil {
+				resp.CompilerErrors = append(resp.CompilerErrors, &cloudpb.ValidateRetentionScriptResponse_CompilerError{
+					Line:    ce.Line,
+					Column:  ce.Column,
+					Message: ce.Message,
+				})
+			}
+		}
+	} else if err != nil {
+		resp.Valid = false
+		resp.ErrorMessage = status.Convert(err).Message()
+	}
+	return resp, nil
+}
+
+// checkVizierCanDryRun returns a FailedPrecondition error if the cluster's Vizier is too old to dry
+// run exports.
+func (p *PluginServiceServer) checkVizierCanDryRun(ctx context.Context, clusterID *uuidpb.UUID) error {
+	ctx, err := contextWithAuthToken(ctx)
+	if err != nil {
+		return err
+	}
+	info, err := p.VzMgr.GetVizierInfo(ctx, clusterID)
+	if err != nil {
+		return err
+	}
+
+	version, err := semver.Parse(info.VizierVersion)
+	if err != nil {
+		log.WithError(err).WithField("version", info.VizierVersion).Error("Invalid Vizier version")
+		return status.Error(codes.FailedPrecondition, "could not determine the cluster's Vizier version")
+	}
+	devVersionRange, _ := semver.ParseRange("<=0.0.0")
+	if devVersionRange(version) {
+		return nil
+	}
+	// Pre-releases of the minimum version can dry run as well.
+	version.Pre = nil
+	if version.LT(semver.MustParse(minDryRunVizierVersion)) {
+		return status.Errorf(codes.FailedPrecondition, "validating retention scripts requires Vizier %s or newer, but the cluster is running %s", minDryRunVizierVersion, info.VizierVersion)
+	}
+	return nil
+}
+
+// getOrgExportURL gets the URL which the org's scripts for a plugin export to by default, and
+// whether TLS verification is skipped for it.
+func (p *PluginServiceServer) getOrgExportURL(ctx context.Context, pluginID string) (string, bool, error) {
+	sCtx, err := authcontext.FromContext(ctx)
+	if err != nil {
+		return "", false, err
+	}
+	orgID := utils.ProtoFromUUIDStrOrNil(sCtx.Claims.GetUserClaims().OrgID)
+
+	ctx, err = contextWithAuthToken(ctx)
+	if err != nil {
+		return "", false, err
+	}
+
+	resp, err := p.DataRetentionPluginServiceClient.GetOrgRetentionPluginConfig(ctx, &pluginpb.GetOrgRetentionPluginConfigRequest{
+		PluginID: pluginID,
+		OrgID:    orgID,
+	})
+	if err != nil {
+		return "", false, err
+	}
+	return resp.ExportURL, resp.InsecureTLS, nil
+}
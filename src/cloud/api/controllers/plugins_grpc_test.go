@@ -21,17 +21,22 @@ package controllers_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/gogo/protobuf/types"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"px.dev/pixie/src/api/proto/cloudpb"
 	"px.dev/pixie/src/api/proto/uuidpb"
+	"px.dev/pixie/src/api/proto/vizierpb"
 	"px.dev/pixie/src/cloud/api/controllers"
 	"px.dev/pixie/src/cloud/api/controllers/testutils"
 	"px.dev/pixie/src/cloud/plugin/pluginpb"
+	"px.dev/pixie/src/shared/cvmsgspb"
 	"px.dev/pixie/src/utils"
 )
 
@@ -54,6 +59,7 @@ func TestGetPlugins(t *testing.T) {
 					Logo:             "",
 					LatestVersion:    "2.0.0",
 					RetentionEnabled: true,
+					Custom:           true,
 				},
 				&pluginpb.Plugin{
 					Name:             "Another Plugin",
@@ -87,6 +93,7 @@ func TestGetPlugins(t *testing.T) {
 					RetentionSupported: true,
 					RetentionEnabled:   false,
 					EnabledVersion:     "",
+					Custom:             true,
 				},
 				&cloudpb.Plugin{
 					Name:               "Another Plugin",
@@ -171,7 +178,8 @@ func TestGetPlugins(t *testing.T) {
 			orgID := utils.ProtoFromUUIDStrOrNil("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
 
 			mockReq1 := &pluginpb.GetPluginsRequest{
-				Kind: pluginpb.PLUGIN_KIND_RETENTION,
+				Kind:  pluginpb.PLUGIN_KIND_RETENTION,
+				OrgID: orgID,
 			}
 
 			mockClients.MockPlugin.EXPECT().GetPlugins(gomock.Any(), mockReq1).
@@ -188,7 +196,7 @@ func TestGetPlugins(t *testing.T) {
 					Plugins: test.orgRetentionPlugins,
 				}, nil)
 
-			pServer := &controllers.PluginServiceServer{mockClients.MockPlugin, mockClients.MockDataRetentionPlugin}
+			pServer := &controllers.PluginServiceServer{PluginServiceClient: mockClients.MockPlugin, DataRetentionPluginServiceClient: mockClients.MockDataRetentionPlugin}
 
 			resp, err := pServer.GetPlugins(ctx, &cloudpb.GetPluginsRequest{
 				Kind: cloudpb.PK_RETENTION,
@@ -224,7 +232,7 @@ func TestGetOrgRetentionPluginConfig(t *testing.T) {
 			InsecureTLS:     true,
 		}, nil)
 
-	pServer := &controllers.PluginServiceServer{mockClients.MockPlugin, mockClients.MockDataRetentionPlugin}
+	pServer := &controllers.PluginServiceServer{PluginServiceClient: mockClients.MockPlugin, DataRetentionPluginServiceClient: mockClients.MockDataRetentionPlugin}
 
 	resp, err := pServer.GetOrgRetentionPluginConfig(ctx, &cloudpb.GetOrgRetentionPluginConfigRequest{
 		PluginId: "test-plugin",
@@ -251,6 +259,7 @@ func TestGetRetentionPluginInfo(t *testing.T) {
 	mockReq := &pluginpb.GetRetentionPluginConfigRequest{
 		Version: "2.0.0",
 		ID:      "test-plugin",
+		OrgID:   utils.ProtoFromUUIDStrOrNil("6ba7b810-9dad-11d1-80b4-00c04fd430c8"),
 	}
 
 	mockClients.MockPlugin.EXPECT().GetRetentionPluginConfig(gomock.Any(), mockReq).
@@ -258,12 +267,13 @@ func TestGetRetentionPluginInfo(t *testing.T) {
 			Configurations: map[string]string{
 				"API_KEY": "This is the API key used in the product.",
 			},
-			AllowCustomExportURL: true,
-			AllowInsecureTLS:     true,
-			DefaultExportURL:     "https://test.com",
+			AllowCustomExportURL:   true,
+			AllowInsecureTLS:       true,
+			DefaultExportURL:       "https://test.com",
+			RequiredConfigurations: []string{"API_KEY"},
 		}, nil)
 
-	pServer := &controllers.PluginServiceServer{mockClients.MockPlugin, mockClients.MockDataRetentionPlugin}
+	pServer := &controllers.PluginServiceServer{PluginServiceClient: mockClients.MockPlugin, DataRetentionPluginServiceClient: mockClients.MockDataRetentionPlugin}
 
 	resp, err := pServer.GetRetentionPluginInfo(ctx, &cloudpb.GetRetentionPluginInfoRequest{
 		PluginId: "test-plugin",
@@ -278,6 +288,7 @@ func TestGetRetentionPluginInfo(t *testing.T) {
 		AllowCustomExportURL: true,
 		AllowInsecureTLS:     true,
 		DefaultExportURL:     "https://test.com",
+		RequiredConfigs:      []string{"API_KEY"},
 	}, resp)
 }
 
@@ -307,7 +318,7 @@ func TestUpdateRetentionPluginConfig(t *testing.T) {
 	mockClients.MockDataRetentionPlugin.EXPECT().UpdateOrgRetentionPluginConfig(gomock.Any(), mockReq).
 		Return(&pluginpb.UpdateOrgRetentionPluginConfigResponse{}, nil)
 
-	pServer := &controllers.PluginServiceServer{mockClients.MockPlugin, mockClients.MockDataRetentionPlugin}
+	pServer := &controllers.PluginServiceServer{PluginServiceClient: mockClients.MockPlugin, DataRetentionPluginServiceClient: mockClients.MockDataRetentionPlugin}
 
 	resp, err := pServer.UpdateRetentionPluginConfig(ctx, &cloudpb.UpdateRetentionPluginConfigRequest{
 		PluginId: "test-plugin",
@@ -325,6 +336,92 @@ func TestUpdateRetentionPluginConfig(t *testing.T) {
 	assert.Equal(t, &cloudpb.UpdateRetentionPluginConfigResponse{}, resp)
 }
 
+func TestRegisterRetentionPlugin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	_, mockClients, cleanup := testutils.CreateTestAPIEnv(t)
+	defer cleanup()
+	ctx := CreateTestContext()
+
+	mockClients.MockPlugin.EXPECT().RegisterRetentionPlugin(gomock.Any(), &pluginpb.RegisterRetentionPluginRequest{
+		OrgID:       utils.ProtoFromUUIDStrOrNil("6ba7b810-9dad-11d1-80b4-00c04fd430c8"),
+		ID:          "my-exporter",
+		Name:        "My Exporter",
+		Description: "Exports to our collector",
+		Version:     "0.1.0",
+		Configurations: map[string]string{
+			"API_KEY": "The API key for the collector",
+		},
+		RequiredConfigurations: []string{"API_KEY"},
+		PresetScripts: []*pluginpb.GetRetentionPluginConfigResponse_PresetScript{
+			{
+				Name:              "http spans",
+				Description:       "Exports HTTP spans",
+				DefaultFrequencyS: 10,
+				Script:            "import px",
+				DefaultDisabled:   true,
+			},
+		},
+		ExportURLTemplate:    "https://collector.example.com:4317",
+		AllowCustomExportURL: true,
+	}).Return(&pluginpb.RegisterRetentionPluginResponse{ID: "6ba7b810-9dad-11d1-80b4-00c04fd430c8/my-exporter"}, nil)
+
+	pServer := &controllers.PluginServiceServer{PluginServiceClient: mockClients.MockPlugin, DataRetentionPluginServiceClient: mockClients.MockDataRetentionPlugin}
+
+	resp, err := pServer.RegisterRetentionPlugin(ctx, &cloudpb.RegisterRetentionPluginRequest{
+		ID:          "my-exporter",
+		Name:        "My Exporter",
+		Description: "Exports to our collector",
+		Version:     "0.1.0",
+		Configs: map[string]string{
+			"API_KEY": "The API key for the collector",
+		},
+		RequiredConfigs: []string{"API_KEY"},
+		PresetScripts: []*cloudpb.RetentionPluginPresetScript{
+			{
+				Name:              "http spans",
+				Description:       "Exports HTTP spans",
+				DefaultFrequencyS: 10,
+				Script:            "import px",
+				DefaultDisabled:   true,
+			},
+		},
+		ExportURLTemplate:    "https://collector.example.com:4317",
+		AllowCustomExportURL: true,
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, &cloudpb.RegisterRetentionPluginResponse{ID: "6ba7b810-9dad-11d1-80b4-00c04fd430c8/my-exporter"}, resp)
+}
+
+func TestGetRetentionPluginVersions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	_, mockClients, cleanup := testutils.CreateTestAPIEnv(t)
+	defer cleanup()
+	ctx := CreateTestContext()
+
+	mockClients.MockPlugin.EXPECT().GetPluginVersions(gomock.Any(), &pluginpb.GetPluginVersionsRequest{
+		ID:    "my-exporter",
+		OrgID: utils.ProtoFromUUIDStrOrNil("6ba7b810-9dad-11d1-80b4-00c04fd430c8"),
+	}).Return(&pluginpb.GetPluginVersionsResponse{
+		Versions: []string{"0.10.0", "0.9.0"},
+	}, nil)
+
+	pServer := &controllers.PluginServiceServer{PluginServiceClient: mockClients.MockPlugin, DataRetentionPluginServiceClient: mockClients.MockDataRetentionPlugin}
+
+	resp, err := pServer.GetRetentionPluginVersions(ctx, &cloudpb.GetRetentionPluginVersionsRequest{
+		PluginId: "my-exporter",
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, &cloudpb.GetRetentionPluginVersionsResponse{
+		Versions: []string{"0.10.0", "0.9.0"},
+	}, resp)
+}
+
 func TestGetRetentionScripts(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -370,7 +467,7 @@ func TestGetRetentionScripts(t *testing.T) {
 			},
 		}, nil)
 
-	pServer := &controllers.PluginServiceServer{mockClients.MockPlugin, mockClients.MockDataRetentionPlugin}
+	pServer := &controllers.PluginServiceServer{PluginServiceClient: mockClients.MockPlugin, DataRetentionPluginServiceClient: mockClients.MockDataRetentionPlugin}
 
 	resp, err := pServer.GetRetentionScripts(ctx, &cloudpb.GetRetentionScriptsRequest{})
 
@@ -445,7 +542,7 @@ func TestGetRetentionScript(t *testing.T) {
 			},
 		}, nil)
 
-	pServer := &controllers.PluginServiceServer{mockClients.MockPlugin, mockClients.MockDataRetentionPlugin}
+	pServer := &controllers.PluginServiceServer{PluginServiceClient: mockClients.MockPlugin, DataRetentionPluginServiceClient: mockClients.MockDataRetentionPlugin}
 
 	resp, err := pServer.GetRetentionScript(ctx, &cloudpb.GetRetentionScriptRequest{
 		ID: scriptID,
@@ -500,7 +597,7 @@ func TestUpdateRetentionScript(t *testing.T) {
 	mockClients.MockDataRetentionPlugin.EXPECT().UpdateRetentionScript(gomock.Any(), mockReq).
 		Return(&pluginpb.UpdateRetentionScriptResponse{}, nil)
 
-	pServer := &controllers.PluginServiceServer{mockClients.MockPlugin, mockClients.MockDataRetentionPlugin}
+	pServer := &controllers.PluginServiceServer{PluginServiceClient: mockClients.MockPlugin, DataRetentionPluginServiceClient: mockClients.MockDataRetentionPlugin}
 
 	resp, err := pServer.UpdateRetentionScript(ctx, &cloudpb.UpdateRetentionScriptRequest{
 		ID:          scriptID,
@@ -556,7 +653,7 @@ func TestCreateRetentionScript(t *testing.T) {
 	mockClients.MockDataRetentionPlugin.EXPECT().CreateRetentionScript(gomock.Any(), mockReq).
 		Return(&pluginpb.CreateRetentionScriptResponse{ID: scriptID}, nil)
 
-	pServer := &controllers.PluginServiceServer{mockClients.MockPlugin, mockClients.MockDataRetentionPlugin}
+	pServer := &controllers.PluginServiceServer{PluginServiceClient: mockClients.MockPlugin, DataRetentionPluginServiceClient: mockClients.MockDataRetentionPlugin}
 
 	resp, err := pServer.CreateRetentionScript(ctx, &cloudpb.CreateRetentionScriptRequest{
 		ScriptName:  "Test Script",
@@ -597,7 +694,7 @@ func TestDeleteRetentionScript(t *testing.T) {
 	mockClients.MockDataRetentionPlugin.EXPECT().DeleteRetentionScript(gomock.Any(), mockReq).
 		Return(&pluginpb.DeleteRetentionScriptResponse{}, nil)
 
-	pServer := &controllers.PluginServiceServer{mockClients.MockPlugin, mockClients.MockDataRetentionPlugin}
+	pServer := &controllers.PluginServiceServer{PluginServiceClient: mockClients.MockPlugin, DataRetentionPluginServiceClient: mockClients.MockDataRetentionPlugin}
 
 	resp, err := pServer.DeleteRetentionScript(ctx, &cloudpb.DeleteRetentionScriptRequest{
 		ID: scriptID,
@@ -608,3 +705,362 @@ func TestDeleteRetentionScript(t *testing.T) {
 
 	assert.Equal(t, &cloudpb.DeleteRetentionScriptResponse{}, resp)
 }
+
+// fakeScriptExecutor records the script it is asked to execute and replies with canned responses.
+type fakeScriptExecutor struct {
+	req       *vizierpb.ExecuteScriptRequest
+	responses []*vizierpb.ExecuteScriptResponse
+	err       error
+}
+
+func (f *fakeScriptExecutor) ExecuteScript(req *vizierpb.ExecuteScriptRequest, srv vizierpb.VizierService_ExecuteScriptServer) error {
+	f.req = req
+	for _, resp := range f.responses {
+		if err := srv.Send(resp); err != nil {
+			return err
+		}
+	}
+	return f.err
+}
+
+const testRetentionScript = `import px as p
+df = p.DataFrame('http_events', start_time=p.plugin.start_time, end_time=p.plugin.end_time)
+df.service = df.ctx['service']
+p.export(df, p.otel.Data(
+  resource={
+    'service.name': df.service,
+  },
+  data=[
+    p.otel.metric.Gauge(
+      name='df.latency',
+      value=df['latency'],
+    )
+  ]
+))
+p.display(df, 'debug')
+`
+
+func TestValidateRetentionScript(t *testing.T) {
+	clusterID := "7ba7b810-9dad-11d1-80b4-00c04fd430c8"
+
+	tests := []struct {
+		name             string
+		req              *cloudpb.ValidateRetentionScriptRequest
+		orgConfig        *pluginpb.GetOrgRetentionPluginConfigResponse
+		vizierVersion    string
+		responses        []*vizierpb.ExecuteScriptResponse
+		executeErr       error
+		expectedWindow   time.Duration
+		expectedEndpoint *vizierpb.Configs_OTelEndpointConfig
+		expectedResp     *cloudpb.ValidateRetentionScriptResponse
+		expectedErrCode  codes.Code
+		expectNoDispatch bool
+	}{
+		{
+			name: "valid script",
+			req: &cloudpb.ValidateRetentionScriptRequest{
+				ClusterID:  utils.ProtoFromUUIDStrOrNil(clusterID),
+				Contents:   testRetentionScript,
+				WindowS:    60,
+				SampleSize: 2,
+			},
+			vizierVersion: "0.14.0",
+			responses: []*vizierpb.ExecuteScriptResponse{
+				{
+					Result: &vizierpb.ExecuteScriptResponse_MetaData{
+						MetaData: &vizierpb.QueryMetadata{
+							Name: "export_1",
+							ID:   "table-1",
+							Relation: &vizierpb.Relation{
+								Columns: []*vizierpb.Relation_ColumnInfo{
+									{ColumnName: "service"},
+									{ColumnName: "latency"},
+								},
+							},
+						},
+					},
+				},
+				{
+					Result: &vizierpb.ExecuteScriptResponse_MetaData{
+						MetaData: &vizierpb.QueryMetadata{
+							Name: "debug",
+							ID:   "table-2",
+							Relation: &vizierpb.Relation{
+								Columns: []*vizierpb.Relation_ColumnInfo{
+									{ColumnName: "service"},
+								},
+							},
+						},
+					},
+				},
+				{
+					Result: &vizierpb.ExecuteScriptResponse_Data{
+						Data: &vizierpb.QueryData{
+							Batch: &vizierpb.RowBatchData{
+								TableID: "table-1",
+								NumRows: 3,
+								Cols: []*vizierpb.Column{
+									{ColData: &vizierpb.Column_StringData{StringData: &vizierpb.StringColumn{Data: [][]byte{[]byte("a"), []byte("b"), []byte("c")}}}},
+									{ColData: &vizierpb.Column_Int64Data{Int64Data: &vizierpb.Int64Column{Data: []int64{1, 2, 3}}}},
+								},
+							},
+						},
+					},
+				},
+			},
+			expectedWindow:   time.Minute,
+			expectedEndpoint: &vizierpb.Configs_OTelEndpointConfig{URL: "localhost:4317"},
+			expectedResp: &cloudpb.ValidateRetentionScriptResponse{
+				Valid: true,
+				Tables: []*cloudpb.ValidateRetentionScriptResponse_Table{
+					{
+						Name:        "export_1",
+						Export:      true,
+						ColumnNames: []string{"service", "latency"},
+						NumRows:     3,
+						SampleRows:  []string{`{"latency":1,"service":"a"}`, `{"latency":2,"service":"b"}`},
+					},
+					{
+						Name:        "debug",
+						ColumnNames: []string{"service"},
+					},
+				},
+			},
+		},
+		{
+			name: "plugin export URL",
+			req: &cloudpb.ValidateRetentionScriptRequest{
+				ClusterID: utils.ProtoFromUUIDStrOrNil(clusterID),
+				Contents:  testRetentionScript,
+				PluginId:  "test-plugin",
+			},
+			orgConfig: &pluginpb.GetOrgRetentionPluginConfigResponse{
+				ExportURL:   "https://otlp.example.com",
+				InsecureTLS: true,
+			},
+			vizierVersion: "0.14.0",
+			responses: []*vizierpb.ExecuteScriptResponse{
+				{
+					ExportEndpointStatus: &vizierpb.ExportEndpointStatus{
+						URL:       "https://otlp.example.com",
+						Reachable: true,
+					},
+				},
+			},
+			expectedWindow:   30 * time.Second,
+			expectedEndpoint: &vizierpb.Configs_OTelEndpointConfig{URL: "https://otlp.example.com", Insecure: true},
+			expectedResp: &cloudpb.ValidateRetentionScriptResponse{
+				Valid:             true,
+				ExportURL:         "https://otlp.example.com",
+				EndpointReachable: true,
+			},
+		},
+		{
+			name: "unreachable endpoint",
+			req: &cloudpb.ValidateRetentionScriptRequest{
+				ClusterID: utils.ProtoFromUUIDStrOrNil(clusterID),
+				Contents:  testRetentionScript,
+				PluginId:  "test-plugin",
+			},
+			orgConfig: &pluginpb.GetOrgRetentionPluginConfigResponse{
+				ExportURL: "otlp.example.com",
+			},
+			vizierVersion: "0.14.0",
+			responses: []*vizierpb.ExecuteScriptResponse{
+				{
+					ExportEndpointStatus: &vizierpb.ExportEndpointStatus{
+						URL:       "otlp.example.com",
+						Reachable: false,
+					},
+				},
+			},
+			expectedWindow:   30 * time.Second,
+			expectedEndpoint: &vizierpb.Configs_OTelEndpointConfig{URL: "otlp.example.com"},
+			expectedResp: &cloudpb.ValidateRetentionScriptResponse{
+				Valid:     true,
+				ExportURL: "otlp.example.com",
+			},
+		},
+		{
+			name: "pre-release vizier",
+			req: &cloudpb.ValidateRetentionScriptRequest{
+				ClusterID: utils.ProtoFromUUIDStrOrNil(clusterID),
+				Contents:  "import px\n",
+			},
+			vizierVersion:    "0.14.0-pre-main.12",
+			expectedWindow:   30 * time.Second,
+			expectedEndpoint: &vizierpb.Configs_OTelEndpointConfig{URL: "localhost:4317"},
+			expectedResp:     &cloudpb.ValidateRetentionScriptResponse{Valid: true},
+		},
+		{
+			name: "dev vizier",
+			req: &cloudpb.ValidateRetentionScriptRequest{
+				ClusterID: utils.ProtoFromUUIDStrOrNil(clusterID),
+				Contents:  "import px\n",
+			},
+			vizierVersion:    "0.0.0-dev",
+			expectedWindow:   30 * time.Second,
+			expectedEndpoint: &vizierpb.Configs_OTelEndpointConfig{URL: "localhost:4317"},
+			expectedResp:     &cloudpb.ValidateRetentionScriptResponse{Valid: true},
+		},
+		{
+			name: "vizier without dry runs",
+			req: &cloudpb.ValidateRetentionScriptRequest{
+				ClusterID: utils.ProtoFromUUIDStrOrNil(clusterID),
+				Contents:  testRetentionScript,
+			},
+			vizierVersion:    "0.13.5",
+			expectedErrCode:  codes.FailedPrecondition,
+			expectNoDispatch: true,
+		},
+		{
+			name: "compiler error",
+			req: &cloudpb.ValidateRetentionScriptRequest{
+				ClusterID: utils.ProtoFromUUIDStrOrNil(clusterID),
+				Contents:  "import px\npx.export(df, px.otel.Data())\n",
+			},
+			vizierVersion: "0.14.0",
+			responses: []*vizierpb.ExecuteScriptResponse{
+				{
+					Status: &vizierpb.Status{
+						Code:    3,
+						Message: "name 'df' is not defined",
+						ErrorDetails: []*vizierpb.ErrorDetails{
+							{
+								Error: &vizierpb.ErrorDetails_CompilerError{
+									CompilerError: &vizierpb.CompilerError{Line: 2, Column: 11, Message: "name 'df' is not defined"},
+								},
+							},
+						},
+					},
+				},
+			},
+			expectedWindow:   30 * time.Second,
+			expectedEndpoint: &vizierpb.Configs_OTelEndpointConfig{URL: "localhost:4317"},
+			expectedResp: &cloudpb.ValidateRetentionScriptResponse{
+				Valid:        false,
+				ErrorMessage: "name 'df' is not defined",
+				CompilerErrors: []*cloudpb.ValidateRetentionScriptResponse_CompilerError{
+					{Line: 2, Column: 11, Message: "name 'df' is not defined"},
+				},
+			},
+		},
+		{
+			name: "execution error",
+			req: &cloudpb.ValidateRetentionScriptRequest{
+				ClusterID: utils.ProtoFromUUIDStrOrNil(clusterID),
+				Contents:  "import px\npx.display(px.DataFrame('http_events'))\n",
+			},
+			vizierVersion: "0.14.0",
+			responses: []*vizierpb.ExecuteScriptResponse{
+				{QueryID: "abcd"},
+			},
+			executeErr:       status.Error(codes.Internal, "agent failed"),
+			expectedWindow:   30 * time.Second,
+			expectedEndpoint: &vizierpb.Configs_OTelEndpointConfig{URL: "localhost:4317"},
+			expectedResp: &cloudpb.ValidateRetentionScriptResponse{
+				Valid:        false,
+				ErrorMessage: "agent failed",
+			},
+		},
+		{
+			name: "cluster unavailable",
+			req: &cloudpb.ValidateRetentionScriptRequest{
+				ClusterID: utils.ProtoFromUUIDStrOrNil(clusterID),
+				Contents:  "import px\n",
+			},
+			vizierVersion:    "0.14.0",
+			executeErr:       status.Error(codes.Unavailable, "cluster is not in a healthy state"),
+			expectedWindow:   30 * time.Second,
+			expectedEndpoint: &vizierpb.Configs_OTelEndpointConfig{URL: "localhost:4317"},
+			expectedErrCode:  codes.Unavailable,
+		},
+		{
+			name: "window too large",
+			req: &cloudpb.ValidateRetentionScriptRequest{
+				ClusterID: utils.ProtoFromUUIDStrOrNil(clusterID),
+				Contents:  "import px\n",
+				WindowS:   7200,
+			},
+			expectedErrCode:  codes.InvalidArgument,
+			expectNoDispatch: true,
+		},
+		{
+			name: "negative window",
+			req: &cloudpb.ValidateRetentionScriptRequest{
+				ClusterID: utils.ProtoFromUUIDStrOrNil(clusterID),
+				Contents:  "import px\n",
+				WindowS:   -1,
+			},
+			expectedErrCode:  codes.InvalidArgument,
+			expectNoDispatch: true,
+		},
+		{
+			name: "sample size too large",
+			req: &cloudpb.ValidateRetentionScriptRequest{
+				ClusterID:  utils.ProtoFromUUIDStrOrNil(clusterID),
+				Contents:   "import px\n",
+				SampleSize: 1000,
+			},
+			expectedErrCode:  codes.InvalidArgument,
+			expectNoDispatch: true,
+		},
+		{
+			name: "missing cluster",
+			req: &cloudpb.ValidateRetentionScriptRequest{
+				Contents: "import px\n",
+			},
+			expectedErrCode:  codes.InvalidArgument,
+			expectNoDispatch: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			_, mockClients, cleanup := testutils.CreateTestAPIEnv(t)
+			defer cleanup()
+
+			if test.orgConfig != nil {
+				mockClients.MockDataRetentionPlugin.EXPECT().GetOrgRetentionPluginConfig(gomock.Any(), &pluginpb.GetOrgRetentionPluginConfigRequest{
+					OrgID:    utils.ProtoFromUUIDStrOrNil("6ba7b810-9dad-11d1-80b4-00c04fd430c8"),
+					PluginID: test.req.PluginId,
+				}).Return(test.orgConfig, nil)
+			}
+			if test.vizierVersion != "" {
+				mockClients.MockVzMgr.EXPECT().GetVizierInfo(gomock.Any(), test.req.ClusterID).
+					Return(&cvmsgspb.VizierInfo{VizierVersion: test.vizierVersion}, nil)
+			}
+
+			executor := &fakeScriptExecutor{responses: test.responses, err: test.executeErr}
+			pServer := &controllers.PluginServiceServer{
+				DataRetentionPluginServiceClient: mockClients.MockDataRetentionPlugin,
+				VizierPassthrough:                executor,
+				VzMgr:                            mockClients.MockVzMgr,
+			}
+
+			resp, err := pServer.ValidateRetentionScript(CreateTestContext(), test.req)
+			if test.expectedErrCode != codes.OK {
+				assert.Equal(t, test.expectedErrCode, status.Code(err))
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, test.expectedResp, resp)
+			}
+
+			if test.expectNoDispatch {
+				assert.Nil(t, executor.req)
+				return
+			}
+			require.NotNil(t, executor.req)
+			// The script is run as is, with its exports turned into outputs by the Vizier.
+			assert.Equal(t, test.req.Contents, executor.req.QueryStr)
+			assert.Equal(t, clusterID, executor.req.ClusterID)
+			assert.True(t, executor.req.Configs.DryRunExports)
+			assert.Equal(t, test.expectedEndpoint, executor.req.Configs.OTelEndpointConfig)
+			pluginConfig := executor.req.Configs.PluginConfig
+			assert.Equal(t, test.expectedWindow.Nanoseconds(), pluginConfig.EndTimeNs-pluginConfig.StartTimeNs)
+		})
+	}
+}
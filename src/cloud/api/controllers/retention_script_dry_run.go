@@ -0,0 +1,127 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"google.golang.org/grpc"
+
+	apiUtils "px.dev/pixie/src/api/go/pxapi/utils"
+	"px.dev/pixie/src/api/proto/cloudpb"
+	"px.dev/pixie/src/api/proto/vizierpb"
+)
+
+// exportTableRegex matches the names of the tables which the Vizier returns in place of the
+// script's exports during a dry run.
+var exportTableRegex = regexp.MustCompile(`^export_\d+$`)
+
+// dryRunStream collects the results of a script executed through the Vizier passthrough.
+type dryRunStream struct {
+	grpc.ServerStream
+	ctx        context.Context
+	sampleSize int
+
+	// received is whether any response was received from the Vizier.
+	received bool
+	status   *vizierpb.Status
+	tables   []*cloudpb.ValidateRetentionScriptResponse_Table
+	tableID  map[string]*cloudpb.ValidateRetentionScriptResponse_Table
+	// endpointStatus is the Vizier's check of the default export endpoint.
+	endpointStatus *vizierpb.ExportEndpointStatus
+}
+
+func newDryRunStream(ctx context.Context, sampleSize int) *dryRunStream {
+	return &dryRunStream{
+		ctx:        ctx,
+		sampleSize: sampleSize,
+		tableID:    make(map[string]*cloudpb.ValidateRetentionScriptResponse_Table),
+	}
+}
+
+func (d *dryRunStream) Context() context.Context {
+	return d.ctx
+}
+
+func (d *dryRunStream) Send(resp *vizierpb.ExecuteScriptResponse) error {
+	d.received = true
+	if resp.Status != nil && resp.Status.Code != 0 {
+		d.status = resp.Status
+	}
+	if resp.ExportEndpointStatus != nil {
+		d.endpointStatus = resp.ExportEndpointStatus
+	}
+	if md := resp.GetMetaData(); md != nil {
+		t := &cloudpb.ValidateRetentionScriptResponse_Table{Name: md.Name}
+		if md.Relation != nil {
+			for _, c := range md.Relation.Columns {
+				t.ColumnNames = append(t.ColumnNames, c.ColumnName)
+			}
+		}
+		d.tables = append(d.tables, t)
+		d.tableID[md.ID] = t
+	}
+	if batch := resp.GetData().GetBatch(); batch != nil {
+		t, ok := d.tableID[batch.TableID]
+		if !ok {
+			return nil
+		}
+		t.NumRows += batch.NumRows
+		for i := 0; i < int(batch.NumRows) && len(t.SampleRows) < d.sampleSize; i++ {
+			row, err := sampleRow(t.ColumnNames, batch.Cols, i)
+			if err != nil {
+				return err
+			}
+			t.SampleRows = append(t.SampleRows, row)
+		}
+	}
+	return nil
+}
+
+func (d *dryRunStream) SendMsg(m interface{}) error {
+	resp, ok := m.(*vizierpb.ExecuteScriptResponse)
+	if !ok {
+		return fmt.Errorf("unexpected message type %T", m)
+	}
+	return d.Send(resp)
+}
+
+// sampleRow formats a row of a batch as a JSON object keyed by column name.
+func sampleRow(names []string, cols []*vizierpb.Column, i int) (string, error) {
+	row := make(map[string]interface{}, len(cols))
+	for c, col := range cols {
+		name := fmt.Sprintf("col_%d", c)
+		if c < len(names) {
+			name = names[c]
+		}
+		v, err := apiUtils.ColumnValue(col, i)
+		if err != nil {
+			return "", err
+		}
+		row[name] = v
+	}
+	b, err := json.Marshal(row)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,765 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gogo/protobuf/types"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"px.dev/pixie/src/cloud/auth/authpb"
+	"px.dev/pixie/src/cloud/profile/profilepb"
+	"px.dev/pixie/src/shared/services/authcontext"
+	"px.dev/pixie/src/shared/services/httpmiddleware"
+	srvutils "px.dev/pixie/src/shared/services/utils"
+	"px.dev/pixie/src/utils"
+)
+
+const (
+	scimUserSchema         = "urn:ietf:params:scim:schemas:core:2.0:User"
+	scimGroupSchema        = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	scimListResponseSchema = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	scimErrorSchema        = "urn:ietf:params:scim:api:messages:2.0:Error"
+
+	scimContentType = "application/scim+json"
+	scimPathPrefix  = "/api/scim/v2/"
+
+	// apiKeyPrefix is the prefix of all Pixie API keys.
+	apiKeyPrefix = "px-api-"
+)
+
+// scimGroups are the groups exposed over SCIM. Each group is one of the org roles, so that the IdP
+// can manage roles through group membership.
+var scimGroups = []string{
+	srvutils.OrgRoleOwner,
+	srvutils.OrgRoleAdmin,
+	srvutils.OrgRoleMember,
+	srvutils.OrgRoleViewer,
+}
+
+var scimUserNameFilter = regexp.MustCompile(`^(?i:userName)\s+(?i:eq)\s+"([^"]*)"$`)
+
+type scimName struct {
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+type scimEmail struct {
+	Value   string `json:"value"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type scimMember struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+}
+
+type scimMeta struct {
+	ResourceType string `json:"resourceType"`
+	Location     string `json:"location,omitempty"`
+}
+
+type scimUser struct {
+	Schemas  []string     `json:"schemas"`
+	ID       string       `json:"id,omitempty"`
+	UserName string       `json:"userName"`
+	Name     *scimName    `json:"name,omitempty"`
+	Emails   []scimEmail  `json:"emails,omitempty"`
+	Active   *bool        `json:"active,omitempty"`
+	Groups   []scimMember `json:"groups,omitempty"`
+	Meta     *scimMeta    `json:"meta,omitempty"`
+}
+
+type scimGroup struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id,omitempty"`
+	DisplayName string       `json:"displayName"`
+	Members     []scimMember `json:"members"`
+	Meta        *scimMeta    `json:"meta,omitempty"`
+}
+
+type scimListResponse struct {
+	Schemas      []string    `json:"schemas"`
+	TotalResults int         `json:"totalResults"`
+	StartIndex   int         `json:"startIndex"`
+	ItemsPerPage int         `json:"itemsPerPage"`
+	Resources    interface{} `json:"Resources"`
+}
+
+type scimPatchOp struct {
+	Operations []struct {
+		Op    string          `json:"op"`
+		Path  string          `json:"path"`
+		Value json.RawMessage `json:"value"`
+	} `json:"Operations"`
+}
+
+type scimError struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail"`
+}
+
+func (e *scimError) Error() string {
+	return e.Detail
+}
+
+func newSCIMError(code int, scimType string, format string, args ...interface{}) *scimError {
+	return &scimError{
+		Schemas:  []string{scimErrorSchema},
+		Status:   strconv.Itoa(code),
+		ScimType: scimType,
+		Detail:   fmt.Sprintf(format, args...),
+	}
+}
+
+// toSCIMError converts an error from the internal services to a SCIM error.
+func toSCIMError(err error) *scimError {
+	if e, ok := err.(*scimError); ok {
+		return e
+	}
+	switch status.Code(err) {
+	case codes.InvalidArgument, codes.FailedPrecondition:
+		return newSCIMError(http.StatusBadRequest, "", "%s", status.Convert(err).Message())
+	case codes.NotFound:
+		return newSCIMError(http.StatusNotFound, "", "%s", status.Convert(err).Message())
+	case codes.AlreadyExists:
+		return newSCIMError(http.StatusConflict, "uniqueness", "%s", status.Convert(err).Message())
+	case codes.PermissionDenied:
+		return newSCIMError(http.StatusForbidden, "", "%s", status.Convert(err).Message())
+	case codes.Unauthenticated:
+		return newSCIMError(http.StatusUnauthorized, "", "%s", status.Convert(err).Message())
+	default:
+		log.WithError(err).Error("SCIM request failed")
+		return newSCIMError(http.StatusInternalServerError, "", "internal error")
+	}
+}
+
+// WithBearerAPIKey lets clients which only support bearer auth, such as SCIM clients, send an API
+// key as the bearer token.
+func WithBearerAPIKey(next http.Handler) http.Handler {
+	f := func(w http.ResponseWriter, r *http.Request) {
+		token, ok := httpmiddleware.GetTokenFromBearer(r)
+		if ok && strings.HasPrefix(token, apiKeyPrefix) && r.Header.Get("pixie-api-key") == "" {
+			r = r.Clone(r.Context())
+			r.Header.Set("pixie-api-key", token)
+			r.Header.Del("Authorization")
+		}
+		next.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(f)
+}
+
+// SCIMHandler serves the SCIM 2.0 Users and Groups endpoints, which let an IdP provision and
+// deprovision the users of an org. Groups are the org roles.
+type SCIMHandler struct {
+	AuthServiceClient    authpb.AuthServiceClient
+	ProfileServiceClient profilepb.ProfileServiceClient
+	OrgServiceClient     profilepb.OrgServiceClient
+}
+
+// ServeHTTP dispatches the SCIM request to the handler for the resource type.
+func (s *SCIMHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	resp, code, err := s.serveSCIM(r)
+	if err != nil {
+		e := toSCIMError(err)
+		code, _ = strconv.Atoi(e.Status)
+		resp = e
+	}
+
+	w.Header().Set("Content-Type", scimContentType)
+	w.WriteHeader(code)
+	if resp != nil {
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.WithError(err).Error("Failed to write SCIM response")
+		}
+	}
+}
+
+func (s *SCIMHandler) serveSCIM(r *http.Request) (interface{}, int, error) {
+	orgID, err := checkSCIMAccess(r.Context())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, scimPathPrefix), "/"), "/")
+	if len(parts) > 2 {
+		return nil, 0, newSCIMError(http.StatusNotFound, "", "no such resource")
+	}
+	id := ""
+	if len(parts) == 2 {
+		id = parts[1]
+	}
+
+	switch parts[0] {
+	case "Users":
+		return s.serveUsers(r, orgID, id)
+	case "Groups":
+		return s.serveGroups(r, orgID, id)
+	default:
+		return nil, 0, newSCIMError(http.StatusNotFound, "", "no such resource")
+	}
+}
+
+// checkSCIMAccess makes sure that the caller is an org admin using a key which may be used for
+// SCIM, and returns their org.
+func checkSCIMAccess(ctx context.Context) (string, error) {
+	sCtx, err := authcontext.FromContext(ctx)
+	if err != nil {
+		return "", status.Error(codes.Unauthenticated, err.Error())
+	}
+	if srvutils.GetClaimsType(sCtx.Claims) != srvutils.UserClaimType {
+		return "", status.Error(codes.PermissionDenied, "SCIM requires user credentials")
+	}
+	if !srvutils.HasAPIKeyScope(sCtx.Claims, srvutils.APIKeyScopeSCIM) {
+		return "", status.Errorf(codes.PermissionDenied, "API key is missing the %s scope", srvutils.APIKeyScopeSCIM)
+	}
+	if err := requireOrgRole(ctx, srvutils.OrgRoleAdmin); err != nil {
+		return "", err
+	}
+	return sCtx.Claims.GetUserClaims().OrgID, nil
+}
+
+func (s *SCIMHandler) serveUsers(r *http.Request, orgID string, id string) (interface{}, int, error) {
+	ctx := r.Context()
+	if id == "" {
+		switch r.Method {
+		case http.MethodGet:
+			resp, err := s.listUsers(ctx, orgID, r)
+			return resp, http.StatusOK, err
+		case http.MethodPost:
+			var req scimUser
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				return nil, 0, newSCIMError(http.StatusBadRequest, "invalidSyntax", "invalid user: %v", err)
+			}
+			resp, err := s.createUser(ctx, orgID, &req)
+			return resp, http.StatusCreated, err
+		}
+		return nil, 0, newSCIMError(http.StatusMethodNotAllowed, "", "method not allowed")
+	}
+
+	user, err := s.getOrgUser(ctx, orgID, id)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		return toSCIMUser(user), http.StatusOK, nil
+	case http.MethodPut:
+		var req scimUser
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return nil, 0, newSCIMError(http.StatusBadRequest, "invalidSyntax", "invalid user: %v", err)
+		}
+		if req.UserName != "" && !strings.EqualFold(req.UserName, user.Email) {
+			return nil, 0, newSCIMError(http.StatusBadRequest, "mutability", "userName can't be changed")
+		}
+		update := &scimUserUpdate{active: req.Active}
+		if req.Name != nil {
+			update.givenName = &req.Name.GivenName
+			update.familyName = &req.Name.FamilyName
+		}
+		resp, err := s.updateUser(ctx, user, update)
+		return resp, http.StatusOK, err
+	case http.MethodPatch:
+		var req scimPatchOp
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return nil, 0, newSCIMError(http.StatusBadRequest, "invalidSyntax", "invalid patch: %v", err)
+		}
+		update, err := parseSCIMUserPatch(&req)
+		if err != nil {
+			return nil, 0, err
+		}
+		resp, err := s.updateUser(ctx, user, update)
+		return resp, http.StatusOK, err
+	case http.MethodDelete:
+		_, err := s.AuthServiceClient.DeprovisionUser(ctx, &authpb.DeprovisionUserRequest{UserID: user.ID})
+		return nil, http.StatusNoContent, err
+	}
+	return nil, 0, newSCIMError(http.StatusMethodNotAllowed, "", "method not allowed")
+}
+
+func toSCIMUser(user *profilepb.UserInfo) *scimUser {
+	active := !utils.IsNilUUIDProto(user.OrgID)
+	u := &scimUser{
+		Schemas:  []string{scimUserSchema},
+		ID:       utils.ProtoToUUIDStr(user.ID),
+		UserName: user.Email,
+		Name: &scimName{
+			GivenName:  user.FirstName,
+			FamilyName: user.LastName,
+		},
+		Emails: []scimEmail{{Value: user.Email, Primary: true}},
+		Active: &active,
+		Meta: &scimMeta{
+			ResourceType: "User",
+			Location:     scimPathPrefix + "Users/" + utils.ProtoToUUIDStr(user.ID),
+		},
+	}
+	if active && user.OrgRole != "" {
+		u.Groups = []scimMember{{Value: user.OrgRole, Display: user.OrgRole}}
+	}
+	return u
+}
+
+// getOrgUser fetches the user, and hides users outside of the caller's org.
+func (s *SCIMHandler) getOrgUser(ctx context.Context, orgID string, id string) (*profilepb.UserInfo, error) {
+	userID := utils.ProtoFromUUIDStrOrNil(id)
+	if utils.IsNilUUIDProto(userID) {
+		return nil, newSCIMError(http.StatusNotFound, "", "no such user")
+	}
+	user, err := s.ProfileServiceClient.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if utils.ProtoToUUIDStr(user.OrgID) != orgID {
+		return nil, newSCIMError(http.StatusNotFound, "", "no such user")
+	}
+	return user, nil
+}
+
+func (s *SCIMHandler) getOrgUsers(ctx context.Context, orgID string) ([]*profilepb.UserInfo, error) {
+	resp, err := s.OrgServiceClient.GetUsersInOrg(ctx, &profilepb.GetUsersInOrgRequest{
+		OrgID: utils.ProtoFromUUIDStrOrNil(orgID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Users, nil
+}
+
+func (s *SCIMHandler) listUsers(ctx context.Context, orgID string, r *http.Request) (*scimListResponse, error) {
+	users, err := s.getOrgUsers(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	if filter := r.URL.Query().Get("filter"); filter != "" {
+		m := scimUserNameFilter.FindStringSubmatch(filter)
+		if m == nil {
+			return nil, newSCIMError(http.StatusBadRequest, "invalidFilter", "only userName eq filters are supported")
+		}
+		var filtered []*profilepb.UserInfo
+		for _, u := range users {
+			if strings.EqualFold(u.Email, m[1]) {
+				filtered = append(filtered, u)
+			}
+		}
+		users = filtered
+	}
+
+	startIndex, count := 1, len(users)
+	if v, err := strconv.Atoi(r.URL.Query().Get("startIndex")); err == nil && v > 1 {
+		startIndex = v
+	}
+	if v, err := strconv.Atoi(r.URL.Query().Get("count")); err == nil && v >= 0 {
+		count = v
+	}
+
+	resources := []*scimUser{}
+	for i := startIndex - 1; i < len(users) && len(resources) < count; i++ {
+		resources = append(resources, toSCIMUser(users[i]))
+	}
+	return &scimListResponse{
+		Schemas:      []string{scimListResponseSchema},
+		TotalResults: len(users),
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}, nil
+}
+
+func primarySCIMEmail(u *scimUser) string {
+	for _, e := range u.Emails {
+		if e.Primary {
+			return e.Value
+		}
+	}
+	if u.UserName != "" || len(u.Emails) == 0 {
+		return u.UserName
+	}
+	return u.Emails[0].Value
+}
+
+func (s *SCIMHandler) createUser(ctx context.Context, orgID string, req *scimUser) (*scimUser, error) {
+	email := primarySCIMEmail(req)
+	if email == "" {
+		return nil, newSCIMError(http.StatusBadRequest, "invalidValue", "userName must not be empty")
+	}
+	provisionReq := &authpb.ProvisionUserRequest{
+		OrgID: utils.ProtoFromUUIDStrOrNil(orgID),
+		Email: email,
+	}
+	if req.Name != nil {
+		provisionReq.FirstName = req.Name.GivenName
+		provisionReq.LastName = req.Name.FamilyName
+	}
+	resp, err := s.AuthServiceClient.ProvisionUser(ctx, provisionReq)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.ProfileServiceClient.GetUser(ctx, resp.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return toSCIMUser(user), nil
+}
+
+// scimUserUpdate holds the user attributes which may be changed over SCIM.
+type scimUserUpdate struct {
+	active     *bool
+	givenName  *string
+	familyName *string
+}
+
+// parseSCIMUserPatch reads the supported attributes out of a PatchOp. Attributes may either be set
+// by path, or as a map of attributes in an operation without a path.
+func parseSCIMUserPatch(req *scimPatchOp) (*scimUserUpdate, error) {
+	update := &scimUserUpdate{}
+	for _, op := range req.Operations {
+		opName := strings.ToLower(op.Op)
+		if opName != "add" && opName != "replace" {
+			return nil, newSCIMError(http.StatusBadRequest, "invalidValue", "unsupported operation %q", op.Op)
+		}
+
+		attrs := map[string]json.RawMessage{}
+		if op.Path == "" {
+			if err := json.Unmarshal(op.Value, &attrs); err != nil {
+				return nil, newSCIMError(http.StatusBadRequest, "invalidValue", "invalid patch value: %v", err)
+			}
+		} else {
+			attrs[op.Path] = op.Value
+		}
+
+		for path, value := range attrs {
+			var err error
+			switch strings.ToLower(path) {
+			case "active":
+				update.active, err = parseSCIMBool(value)
+			case "name":
+				var name scimName
+				err = json.Unmarshal(value, &name)
+				if name.GivenName != "" {
+					update.givenName = &name.GivenName
+				}
+				if name.FamilyName != "" {
+					update.familyName = &name.FamilyName
+				}
+			case "name.givenname":
+				update.givenName = new(string)
+				err = json.Unmarshal(value, update.givenName)
+			case "name.familyname":
+				update.familyName = new(string)
+				err = json.Unmarshal(value, update.familyName)
+			default:
+				// Attributes that Pixie doesn't store, such as externalId, are ignored.
+				continue
+			}
+			if err != nil {
+				return nil, newSCIMError(http.StatusBadRequest, "invalidValue", "invalid value for %s", path)
+			}
+		}
+	}
+	return update, nil
+}
+
+// parseSCIMBool parses a boolean, which some IdPs send as a string.
+func parseSCIMBool(value json.RawMessage) (*bool, error) {
+	var b bool
+	if err := json.Unmarshal(value, &b); err == nil {
+		return &b, nil
+	}
+	var str string
+	if err := json.Unmarshal(value, &str); err != nil {
+		return nil, err
+	}
+	b, err := strconv.ParseBool(str)
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (s *SCIMHandler) updateUser(ctx context.Context, user *profilepb.UserInfo, update *scimUserUpdate) (*scimUser, error) {
+	if update.givenName != nil || update.familyName != nil {
+		req := &profilepb.UpdateUserRequest{ID: user.ID}
+		if update.givenName != nil {
+			req.FirstName = &types.StringValue{Value: *update.givenName}
+			user.FirstName = *update.givenName
+		}
+		if update.familyName != nil {
+			req.LastName = &types.StringValue{Value: *update.familyName}
+			user.LastName = *update.familyName
+		}
+		if _, err := s.ProfileServiceClient.UpdateUser(ctx, req); err != nil {
+			return nil, err
+		}
+	}
+
+	if update.active != nil && !*update.active {
+		_, err := s.AuthServiceClient.DeprovisionUser(ctx, &authpb.DeprovisionUserRequest{UserID: user.ID})
+		if err != nil {
+			return nil, err
+		}
+		user.OrgID = nil
+	}
+	return toSCIMUser(user), nil
+}
+
+func isSCIMGroup(id string) bool {
+	for _, g := range scimGroups {
+		if g == id {
+			return true
+		}
+	}
+	return false
+}
+
+func toSCIMGroup(role string, users []*profilepb.UserInfo) *scimGroup {
+	members := []scimMember{}
+	for _, u := range users {
+		if u.OrgRole == role {
+			members = append(members, scimMember{Value: utils.ProtoToUUIDStr(u.ID), Display: u.Email})
+		}
+	}
+	return &scimGroup{
+		Schemas:     []string{scimGroupSchema},
+		ID:          role,
+		DisplayName: role,
+		Members:     members,
+		Meta: &scimMeta{
+			ResourceType: "Group",
+			Location:     scimPathPrefix + "Groups/" + role,
+		},
+	}
+}
+
+func (s *SCIMHandler) serveGroups(r *http.Request, orgID string, id string) (interface{}, int, error) {
+	ctx := r.Context()
+	if id == "" {
+		switch r.Method {
+		case http.MethodGet:
+			users, err := s.getOrgUsers(ctx, orgID)
+			if err != nil {
+				return nil, 0, err
+			}
+			groups := make([]*scimGroup, len(scimGroups))
+			for i, role := range scimGroups {
+				groups[i] = toSCIMGroup(role, users)
+			}
+			return &scimListResponse{
+				Schemas:      []string{scimListResponseSchema},
+				TotalResults: len(groups),
+				StartIndex:   1,
+				ItemsPerPage: len(groups),
+				Resources:    groups,
+			}, http.StatusOK, nil
+		case http.MethodPost:
+			// Groups can't be created, but an IdP which pushes a group for one of the roles is
+			// pointed at the existing group.
+			var req scimGroup
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				return nil, 0, newSCIMError(http.StatusBadRequest, "invalidSyntax", "invalid group: %v", err)
+			}
+			if !isSCIMGroup(req.DisplayName) {
+				return nil, 0, newSCIMError(http.StatusBadRequest, "invalidValue", "groups must be one of the org roles: %s", strings.Join(scimGroups, ", "))
+			}
+			if err := s.setGroupMembers(ctx, orgID, req.DisplayName, req.Members); err != nil {
+				return nil, 0, err
+			}
+			return s.getGroup(ctx, orgID, req.DisplayName, http.StatusCreated)
+		}
+		return nil, 0, newSCIMError(http.StatusMethodNotAllowed, "", "method not allowed")
+	}
+
+	if !isSCIMGroup(id) {
+		return nil, 0, newSCIMError(http.StatusNotFound, "", "no such group")
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		return s.getGroup(ctx, orgID, id, http.StatusOK)
+	case http.MethodPut:
+		var req scimGroup
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return nil, 0, newSCIMError(http.StatusBadRequest, "invalidSyntax", "invalid group: %v", err)
+		}
+		if err := s.setGroupMembers(ctx, orgID, id, req.Members); err != nil {
+			return nil, 0, err
+		}
+		return s.getGroup(ctx, orgID, id, http.StatusOK)
+	case http.MethodPatch:
+		var req scimPatchOp
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return nil, 0, newSCIMError(http.StatusBadRequest, "invalidSyntax", "invalid patch: %v", err)
+		}
+		if err := s.patchGroup(ctx, orgID, id, &req); err != nil {
+			return nil, 0, err
+		}
+		return s.getGroup(ctx, orgID, id, http.StatusOK)
+	case http.MethodDelete:
+		return nil, 0, newSCIMError(http.StatusBadRequest, "mutability", "groups for org roles can't be deleted")
+	}
+	return nil, 0, newSCIMError(http.StatusMethodNotAllowed, "", "method not allowed")
+}
+
+func (s *SCIMHandler) getGroup(ctx context.Context, orgID string, role string, code int) (interface{}, int, error) {
+	users, err := s.getOrgUsers(ctx, orgID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return toSCIMGroup(role, users), code, nil
+}
+
+// scimMemberFilter matches the paths that IdPs use to remove a single member from a group.
+var scimMemberFilter = regexp.MustCompile(`^(?i:members)\[(?i:value)\s+(?i:eq)\s+"([^"]*)"\]$`)
+
+func (s *SCIMHandler) patchGroup(ctx context.Context, orgID string, role string, req *scimPatchOp) error {
+	for _, op := range req.Operations {
+		var members []scimMember
+		path := op.Path
+		if m := scimMemberFilter.FindStringSubmatch(path); m != nil {
+			members = []scimMember{{Value: m[1]}}
+			path = "members"
+		} else if len(op.Value) > 0 {
+			if path == "" {
+				var group scimGroup
+				if err := json.Unmarshal(op.Value, &group); err != nil {
+					return newSCIMError(http.StatusBadRequest, "invalidValue", "invalid patch value: %v", err)
+				}
+				members = group.Members
+				path = "members"
+			} else if err := json.Unmarshal(op.Value, &members); err != nil {
+				return newSCIMError(http.StatusBadRequest, "invalidValue", "invalid patch value: %v", err)
+			}
+		}
+		if !strings.EqualFold(path, "members") {
+			// The display name of a role can't change, and other attributes aren't stored.
+			continue
+		}
+
+		var err error
+		switch strings.ToLower(op.Op) {
+		case "add":
+			err = s.addGroupMembers(ctx, orgID, role, members)
+		case "remove":
+			if len(members) == 0 {
+				err = s.setGroupMembers(ctx, orgID, role, nil)
+			} else {
+				err = s.removeGroupMembers(ctx, orgID, role, members)
+			}
+		case "replace":
+			err = s.setGroupMembers(ctx, orgID, role, members)
+		default:
+			err = newSCIMError(http.StatusBadRequest, "invalidValue", "unsupported operation %q", op.Op)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removedRole is the role that a user gets when they are removed from the group for their role.
+// This is the member role that users get when they join an org, or the viewer role for users
+// removed from the member group.
+func removedRole(role string) string {
+	if role == srvutils.OrgRoleMember {
+		return srvutils.OrgRoleViewer
+	}
+	return srvutils.OrgRoleMember
+}
+
+func (s *SCIMHandler) setUserRole(ctx context.Context, user *profilepb.UserInfo, role string) error {
+	if user.OrgRole == role {
+		return nil
+	}
+	if err := checkCanAssignOrgRole(ctx, user.OrgRole, role); err != nil {
+		return err
+	}
+	_, err := s.ProfileServiceClient.UpdateUser(ctx, &profilepb.UpdateUserRequest{
+		ID:      user.ID,
+		OrgRole: &types.StringValue{Value: role},
+	})
+	return err
+}
+
+func (s *SCIMHandler) addGroupMembers(ctx context.Context, orgID string, role string, members []scimMember) error {
+	for _, m := range members {
+		user, err := s.getOrgUser(ctx, orgID, m.Value)
+		if err != nil {
+			return err
+		}
+		if err := s.setUserRole(ctx, user, role); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SCIMHandler) removeGroupMembers(ctx context.Context, orgID string, role string, members []scimMember) error {
+	for _, m := range members {
+		user, err := s.getOrgUser(ctx, orgID, m.Value)
+		if err != nil {
+			return err
+		}
+		if user.OrgRole != role {
+			continue
+		}
+		if err := s.setUserRole(ctx, user, removedRole(role)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setGroupMembers makes the given users the only members of the group.
+func (s *SCIMHandler) setGroupMembers(ctx context.Context, orgID string, role string, members []scimMember) error {
+	users, err := s.getOrgUsers(ctx, orgID)
+	if err != nil {
+		return err
+	}
+	keep := make(map[string]bool)
+	for _, m := range members {
+		keep[m.Value] = true
+	}
+
+	// Add the new members first, so that an org which swaps its owners always keeps one.
+	if err := s.addGroupMembers(ctx, orgID, role, members); err != nil {
+		return err
+	}
+	for _, u := range users {
+		if u.OrgRole == role && !keep[utils.ProtoToUUIDStr(u.ID)] {
+			if err := s.setUserRole(ctx, u, removedRole(role)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,322 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package controllers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gogo/protobuf/types"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"px.dev/pixie/src/cloud/api/controllers"
+	"px.dev/pixie/src/cloud/api/controllers/testutils"
+	"px.dev/pixie/src/cloud/auth/authpb"
+	"px.dev/pixie/src/cloud/profile/profilepb"
+	"px.dev/pixie/src/shared/services/authcontext"
+	svcutils "px.dev/pixie/src/shared/services/utils"
+	"px.dev/pixie/src/utils"
+)
+
+const (
+	scimTestOrgID   = "6ba7b810-9dad-11d1-80b4-00c04fd430c8"
+	scimTestUserID  = "7ba7b810-9dad-11d1-80b4-00c04fd430c8"
+	scimTestUserID2 = "8ba7b810-9dad-11d1-80b4-00c04fd430c8"
+)
+
+func newSCIMTestHandler(t *testing.T) (*controllers.SCIMHandler, *testutils.MockAPIClients, func()) {
+	_, mockClients, cleanup := testutils.CreateTestAPIEnv(t)
+	return &controllers.SCIMHandler{
+		AuthServiceClient:    mockClients.MockAuth,
+		ProfileServiceClient: mockClients.MockProfile,
+		OrgServiceClient:     mockClients.MockOrg,
+	}, mockClients, cleanup
+}
+
+func doSCIMRequest(ctx context.Context, h http.Handler, method string, path string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body)).WithContext(ctx)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func scimTestUser(id string, role string) *profilepb.UserInfo {
+	return &profilepb.UserInfo{
+		ID:        utils.ProtoFromUUIDStrOrNil(id),
+		OrgID:     utils.ProtoFromUUIDStrOrNil(scimTestOrgID),
+		FirstName: "first",
+		LastName:  "last",
+		Email:     id + "@test.com",
+		OrgRole:   role,
+	}
+}
+
+func TestSCIMHandler_Access(t *testing.T) {
+	scopedCtx := createOrgRoleTestContext(svcutils.OrgRoleAdmin)
+	sCtx, err := authcontext.FromContext(scopedCtx)
+	require.NoError(t, err)
+	sCtx.Claims.GetUserClaims().APIKeyScopes = []string{svcutils.APIKeyScopeScriptExecute}
+
+	tests := []struct {
+		name         string
+		ctx          context.Context
+		expectedCode int
+	}{
+		{
+			name:         "member",
+			ctx:          createOrgRoleTestContext(svcutils.OrgRoleMember),
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "key without scim scope",
+			ctx:          scopedCtx,
+			expectedCode: http.StatusForbidden,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h, _, cleanup := newSCIMTestHandler(t)
+			defer cleanup()
+
+			w := doSCIMRequest(test.ctx, h, http.MethodGet, "/api/scim/v2/Users", "")
+			assert.Equal(t, test.expectedCode, w.Code)
+			assert.Contains(t, w.Body.String(), "urn:ietf:params:scim:api:messages:2.0:Error")
+		})
+	}
+}
+
+func TestSCIMHandler_ListUsers(t *testing.T) {
+	h, mockClients, cleanup := newSCIMTestHandler(t)
+	defer cleanup()
+
+	mockClients.MockOrg.EXPECT().
+		GetUsersInOrg(gomock.Any(), &profilepb.GetUsersInOrgRequest{OrgID: utils.ProtoFromUUIDStrOrNil(scimTestOrgID)}).
+		Return(&profilepb.GetUsersInOrgResponse{
+			Users: []*profilepb.UserInfo{
+				scimTestUser(scimTestUserID, svcutils.OrgRoleAdmin),
+				scimTestUser(scimTestUserID2, svcutils.OrgRoleMember),
+			},
+		}, nil)
+
+	ctx := createOrgRoleTestContext(svcutils.OrgRoleAdmin)
+	w := doSCIMRequest(ctx, h, http.MethodGet, `/api/scim/v2/Users?filter=userName+eq+%22`+scimTestUserID2+`%40test.com%22`, "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		TotalResults int `json:"totalResults"`
+		Resources    []struct {
+			ID       string `json:"id"`
+			UserName string `json:"userName"`
+			Active   bool   `json:"active"`
+		} `json:"Resources"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 1, resp.TotalResults)
+	require.Len(t, resp.Resources, 1)
+	assert.Equal(t, scimTestUserID2, resp.Resources[0].ID)
+	assert.True(t, resp.Resources[0].Active)
+}
+
+func TestSCIMHandler_CreateUser(t *testing.T) {
+	h, mockClients, cleanup := newSCIMTestHandler(t)
+	defer cleanup()
+
+	userPb := utils.ProtoFromUUIDStrOrNil(scimTestUserID)
+	mockClients.MockAuth.EXPECT().
+		ProvisionUser(gomock.Any(), &authpb.ProvisionUserRequest{
+			OrgID:     utils.ProtoFromUUIDStrOrNil(scimTestOrgID),
+			Email:     "abc@test.com",
+			FirstName: "first",
+			LastName:  "last",
+		}).
+		Return(&authpb.ProvisionUserResponse{UserID: userPb}, nil)
+	mockClients.MockProfile.EXPECT().
+		GetUser(gomock.Any(), userPb).
+		Return(scimTestUser(scimTestUserID, svcutils.OrgRoleMember), nil)
+
+	ctx := createOrgRoleTestContext(svcutils.OrgRoleAdmin)
+	w := doSCIMRequest(ctx, h, http.MethodPost, "/api/scim/v2/Users", `{
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+		"userName": "abc@test.com",
+		"name": {"givenName": "first", "familyName": "last"},
+		"active": true
+	}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"id":"`+scimTestUserID+`"`)
+}
+
+func TestSCIMHandler_DeactivateUser(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		code   int
+	}{
+		{
+			name:   "patch without path",
+			method: http.MethodPatch,
+			body:   `{"Operations": [{"op": "replace", "value": {"active": false}}]}`,
+			code:   http.StatusOK,
+		},
+		{
+			name:   "patch with string value",
+			method: http.MethodPatch,
+			body:   `{"Operations": [{"op": "Replace", "path": "active", "value": "False"}]}`,
+			code:   http.StatusOK,
+		},
+		{
+			name:   "delete",
+			method: http.MethodDelete,
+			code:   http.StatusNoContent,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h, mockClients, cleanup := newSCIMTestHandler(t)
+			defer cleanup()
+
+			userPb := utils.ProtoFromUUIDStrOrNil(scimTestUserID)
+			mockClients.MockProfile.EXPECT().
+				GetUser(gomock.Any(), userPb).
+				Return(scimTestUser(scimTestUserID, svcutils.OrgRoleMember), nil)
+			mockClients.MockAuth.EXPECT().
+				DeprovisionUser(gomock.Any(), &authpb.DeprovisionUserRequest{UserID: userPb}).
+				Return(&authpb.DeprovisionUserResponse{}, nil)
+
+			ctx := createOrgRoleTestContext(svcutils.OrgRoleAdmin)
+			w := doSCIMRequest(ctx, h, test.method, "/api/scim/v2/Users/"+scimTestUserID, test.body)
+			require.Equal(t, test.code, w.Code)
+			if test.code == http.StatusOK {
+				assert.Contains(t, w.Body.String(), `"active":false`)
+			}
+		})
+	}
+}
+
+func TestSCIMHandler_GetUser_OtherOrg(t *testing.T) {
+	h, mockClients, cleanup := newSCIMTestHandler(t)
+	defer cleanup()
+
+	user := scimTestUser(scimTestUserID, svcutils.OrgRoleMember)
+	user.OrgID = utils.ProtoFromUUIDStrOrNil("9ba7b810-9dad-11d1-80b4-00c04fd430c8")
+	mockClients.MockProfile.EXPECT().
+		GetUser(gomock.Any(), utils.ProtoFromUUIDStrOrNil(scimTestUserID)).
+		Return(user, nil)
+
+	ctx := createOrgRoleTestContext(svcutils.OrgRoleAdmin)
+	w := doSCIMRequest(ctx, h, http.MethodGet, "/api/scim/v2/Users/"+scimTestUserID, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestSCIMHandler_PatchGroup(t *testing.T) {
+	h, mockClients, cleanup := newSCIMTestHandler(t)
+	defer cleanup()
+
+	orgUsers := &profilepb.GetUsersInOrgResponse{
+		Users: []*profilepb.UserInfo{
+			scimTestUser(scimTestUserID, svcutils.OrgRoleMember),
+			scimTestUser(scimTestUserID2, svcutils.OrgRoleViewer),
+		},
+	}
+	mockClients.MockOrg.EXPECT().
+		GetUsersInOrg(gomock.Any(), gomock.Any()).
+		Return(orgUsers, nil).
+		AnyTimes()
+
+	// Adding the viewer to the member group makes them a member.
+	mockClients.MockProfile.EXPECT().
+		GetUser(gomock.Any(), utils.ProtoFromUUIDStrOrNil(scimTestUserID2)).
+		Return(scimTestUser(scimTestUserID2, svcutils.OrgRoleViewer), nil)
+	mockClients.MockProfile.EXPECT().
+		UpdateUser(gomock.Any(), &profilepb.UpdateUserRequest{
+			ID:      utils.ProtoFromUUIDStrOrNil(scimTestUserID2),
+			OrgRole: &types.StringValue{Value: svcutils.OrgRoleMember},
+		}).
+		Return(nil, nil)
+	// Removing the member from the member group makes them a viewer.
+	mockClients.MockProfile.EXPECT().
+		GetUser(gomock.Any(), utils.ProtoFromUUIDStrOrNil(scimTestUserID)).
+		Return(scimTestUser(scimTestUserID, svcutils.OrgRoleMember), nil)
+	mockClients.MockProfile.EXPECT().
+		UpdateUser(gomock.Any(), &profilepb.UpdateUserRequest{
+			ID:      utils.ProtoFromUUIDStrOrNil(scimTestUserID),
+			OrgRole: &types.StringValue{Value: svcutils.OrgRoleViewer},
+		}).
+		Return(nil, nil)
+
+	ctx := createOrgRoleTestContext(svcutils.OrgRoleAdmin)
+	w := doSCIMRequest(ctx, h, http.MethodPatch, "/api/scim/v2/Groups/member", `{"Operations": [
+		{"op": "add", "path": "members", "value": [{"value": "`+scimTestUserID2+`"}]},
+		{"op": "remove", "path": "members[value eq \"`+scimTestUserID+`\"]"}
+	]}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"displayName":"member"`)
+}
+
+func TestSCIMHandler_PatchGroup_AdminRequiresOwner(t *testing.T) {
+	h, mockClients, cleanup := newSCIMTestHandler(t)
+	defer cleanup()
+
+	mockClients.MockProfile.EXPECT().
+		GetUser(gomock.Any(), utils.ProtoFromUUIDStrOrNil(scimTestUserID)).
+		Return(scimTestUser(scimTestUserID, svcutils.OrgRoleMember), nil)
+
+	ctx := createOrgRoleTestContext(svcutils.OrgRoleAdmin)
+	w := doSCIMRequest(ctx, h, http.MethodPatch, "/api/scim/v2/Groups/admin", `{"Operations": [
+		{"op": "add", "path": "members", "value": [{"value": "`+scimTestUserID+`"}]}
+	]}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestSCIMHandler_UnknownGroup(t *testing.T) {
+	h, _, cleanup := newSCIMTestHandler(t)
+	defer cleanup()
+
+	ctx := createOrgRoleTestContext(svcutils.OrgRoleAdmin)
+	w := doSCIMRequest(ctx, h, http.MethodPost, "/api/scim/v2/Groups", `{"displayName": "engineering"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestWithBearerAPIKey(t *testing.T) {
+	var apiKey, authHeader string
+	h := controllers.WithBearerAPIKey(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiKey = r.Header.Get("pixie-api-key")
+		authHeader = r.Header.Get("Authorization")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/scim/v2/Users", nil)
+	req.Header.Set("Authorization", "Bearer px-api-abcd")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "px-api-abcd", apiKey)
+	assert.Equal(t, "", authHeader)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/scim/v2/Users", nil)
+	req.Header.Set("Authorization", "Bearer some-jwt")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "", apiKey)
+	assert.Equal(t, "Bearer some-jwt", authHeader)
+}
@@ -0,0 +1,187 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package controllers
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"px.dev/pixie/src/api/proto/cloudpb"
+	"px.dev/pixie/src/api/proto/uuidpb"
+	"px.dev/pixie/src/cloud/artifact_tracker/artifacttrackerpb"
+	"px.dev/pixie/src/cloud/vzmgr/vzmgrpb"
+	"px.dev/pixie/src/shared/artifacts/versionspb"
+	"px.dev/pixie/src/shared/services/authcontext"
+	srvutils "px.dev/pixie/src/shared/services/utils"
+	"px.dev/pixie/src/utils"
+)
+
+// VizierUpgradeCampaignServer is the server that implements the VizierUpgradeCampaignService gRPC service.
+type VizierUpgradeCampaignServer struct {
+	VzUpgradeCampaign     vzmgrpb.VZUpgradeCampaignServiceClient
+	ArtifactTrackerClient artifacttrackerpb.ArtifactTrackerClient
+}
+
+func upgradeCampaignToCloudAPI(c *vzmgrpb.UpgradeCampaign) *cloudpb.UpgradeCampaign {
+	clusters := make([]*cloudpb.UpgradeCampaign_Cluster, len(c.Clusters))
+	for i, cc := range c.Clusters {
+		clusters[i] = &cloudpb.UpgradeCampaign_Cluster{
+			ClusterID:   cc.VizierID,
+			ClusterName: cc.ClusterName,
+			Wave:        cc.Wave,
+			State:       cloudpb.UpgradeCampaignClusterState(cc.State),
+			Message:     cc.Message,
+			UpdatedAt:   cc.UpdatedAt,
+		}
+	}
+	return &cloudpb.UpgradeCampaign{
+		ID:              c.ID,
+		Version:         c.Version,
+		Selector:        c.Selector,
+		CanaryCount:     c.CanaryCount,
+		WavePercentages: c.WavePercentages,
+		SoakPeriodS:     c.SoakPeriodS,
+		State:           cloudpb.UpgradeCampaignState(c.State),
+		CurrentWave:     c.CurrentWave,
+		NumWaves:        c.NumWaves,
+		StatusMessage:   c.StatusMessage,
+		CreatedAt:       c.CreatedAt,
+		UpdatedAt:       c.UpdatedAt,
+		Clusters:        clusters,
+	}
+}
+
+// upgradeCampaignContext returns the context to use for vzmgr along with the caller's org.
+func upgradeCampaignContext(ctx context.Context) (context.Context, *uuidpb.UUID, error) {
+	sCtx, err := authcontext.FromContext(ctx)
+	if err != nil {
+		return nil, nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	// Campaigns span all of the clusters of the org.
+	if len(sCtx.Claims.GetUserClaims().GetAllowedClusterIDs()) > 0 {
+		return nil, nil, status.Error(codes.PermissionDenied, "API keys restricted to clusters can't manage upgrade campaigns")
+	}
+	orgID := utils.ProtoFromUUIDStrOrNil(sCtx.Claims.GetUserClaims().OrgID)
+	if orgID == nil {
+		return nil, nil, status.Error(codes.Internal, "error parsing org ID as UUID")
+	}
+	ctx, err = contextWithAuthToken(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ctx, orgID, nil
+}
+
+// CreateUpgradeCampaign starts an upgrade campaign to the requested version.
+func (v *VizierUpgradeCampaignServer) CreateUpgradeCampaign(ctx context.Context, req *cloudpb.CreateUpgradeCampaignRequest) (*cloudpb.UpgradeCampaign, error) {
+	if err := requireOrgRole(ctx, srvutils.OrgRoleAdmin); err != nil {
+		return nil, err
+	}
+	ctx, orgID, err := upgradeCampaignContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.Version == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "version cannot be empty")
+	}
+
+	// Validate version.
+	atReq := &artifacttrackerpb.GetDownloadLinkRequest{
+		ArtifactName: "vizier",
+		VersionStr:   req.Version,
+		ArtifactType: versionspb.AT_CONTAINER_SET_YAMLS,
+	}
+	_, err = v.ArtifactTrackerClient.GetDownloadLink(ctx, atReq)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid version")
+	}
+
+	resp, err := v.VzUpgradeCampaign.Create(ctx, &vzmgrpb.CreateUpgradeCampaignRequest{
+		OrgID:           orgID,
+		Version:         req.Version,
+		Selector:        req.Selector,
+		CanaryCount:     req.CanaryCount,
+		WavePercentages: req.WavePercentages,
+		SoakPeriodS:     req.SoakPeriodS,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return upgradeCampaignToCloudAPI(resp), nil
+}
+
+// GetUpgradeCampaign gets the progress of an upgrade campaign.
+func (v *VizierUpgradeCampaignServer) GetUpgradeCampaign(ctx context.Context, req *cloudpb.GetUpgradeCampaignRequest) (*cloudpb.UpgradeCampaign, error) {
+	ctx, orgID, err := upgradeCampaignContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := v.VzUpgradeCampaign.Get(ctx, &vzmgrpb.GetUpgradeCampaignRequest{
+		ID:    req.ID,
+		OrgID: orgID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return upgradeCampaignToCloudAPI(resp), nil
+}
+
+// ListUpgradeCampaigns lists the upgrade campaigns of the org.
+func (v *VizierUpgradeCampaignServer) ListUpgradeCampaigns(ctx context.Context, req *cloudpb.ListUpgradeCampaignsRequest) (*cloudpb.ListUpgradeCampaignsResponse, error) {
+	ctx, orgID, err := upgradeCampaignContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := v.VzUpgradeCampaign.List(ctx, &vzmgrpb.ListUpgradeCampaignsRequest{
+		OrgID: orgID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	campaigns := make([]*cloudpb.UpgradeCampaign, len(resp.Campaigns))
+	for i, c := range resp.Campaigns {
+		campaigns[i] = upgradeCampaignToCloudAPI(c)
+	}
+	return &cloudpb.ListUpgradeCampaignsResponse{Campaigns: campaigns}, nil
+}
+
+// SetUpgradeCampaignState pauses, resumes or cancels an upgrade campaign.
+func (v *VizierUpgradeCampaignServer) SetUpgradeCampaignState(ctx context.Context, req *cloudpb.SetUpgradeCampaignStateRequest) (*cloudpb.UpgradeCampaign, error) {
+	if err := requireOrgRole(ctx, srvutils.OrgRoleAdmin); err != nil {
+		return nil, err
+	}
+	ctx, orgID, err := upgradeCampaignContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := v.VzUpgradeCampaign.SetState(ctx, &vzmgrpb.SetUpgradeCampaignStateRequest{
+		ID:    req.ID,
+		OrgID: orgID,
+		State: vzmgrpb.UpgradeCampaignState(req.State),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return upgradeCampaignToCloudAPI(resp), nil
+}
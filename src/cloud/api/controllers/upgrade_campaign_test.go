@@ -0,0 +1,180 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package controllers_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"px.dev/pixie/src/api/proto/cloudpb"
+	"px.dev/pixie/src/cloud/api/controllers"
+	"px.dev/pixie/src/cloud/api/controllers/testutils"
+	"px.dev/pixie/src/cloud/artifact_tracker/artifacttrackerpb"
+	"px.dev/pixie/src/cloud/vzmgr/vzmgrpb"
+	mock_vzmgrpb "px.dev/pixie/src/cloud/vzmgr/vzmgrpb/mock"
+	"px.dev/pixie/src/shared/artifacts/versionspb"
+	svcutils "px.dev/pixie/src/shared/services/utils"
+	"px.dev/pixie/src/utils"
+)
+
+var (
+	testCampaignID = utils.ProtoFromUUIDStrOrNil("9ba7b810-9dad-11d1-80b4-00c04fd430c8")
+	testCampaign   = &vzmgrpb.UpgradeCampaign{
+		ID:              testCampaignID,
+		OrgID:           utils.ProtoFromUUIDStrOrNil("6ba7b810-9dad-11d1-80b4-00c04fd430c8"),
+		Version:         "0.1.30",
+		Selector:        "env=prod",
+		CanaryCount:     1,
+		WavePercentages: []int32{50, 100},
+		SoakPeriodS:     600,
+		State:           vzmgrpb.UCS_RUNNING,
+		NumWaves:        3,
+		Clusters: []*vzmgrpb.UpgradeCampaign_Cluster{
+			{
+				VizierID:    utils.ProtoFromUUIDStrOrNil("7ba7b810-9dad-11d1-80b4-00c04fd430c8"),
+				ClusterName: "test_cluster",
+				State:       vzmgrpb.UCCS_UPDATING,
+			},
+		},
+	}
+)
+
+func TestVizierUpgradeCampaignServer_CreateUpgradeCampaign(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	_, mockClients, cleanup := testutils.CreateTestAPIEnv(t)
+	defer cleanup()
+	mockCampaigns := mock_vzmgrpb.NewMockVZUpgradeCampaignServiceClient(ctrl)
+
+	mockClients.MockArtifact.EXPECT().
+		GetDownloadLink(gomock.Any(), &artifacttrackerpb.GetDownloadLinkRequest{
+			ArtifactName: "vizier",
+			VersionStr:   "0.1.30",
+			ArtifactType: versionspb.AT_CONTAINER_SET_YAMLS,
+		}).
+		Return(nil, nil)
+	mockCampaigns.EXPECT().Create(gomock.Any(), &vzmgrpb.CreateUpgradeCampaignRequest{
+		OrgID:           utils.ProtoFromUUIDStrOrNil("6ba7b810-9dad-11d1-80b4-00c04fd430c8"),
+		Version:         "0.1.30",
+		Selector:        "env=prod",
+		CanaryCount:     1,
+		WavePercentages: []int32{50, 100},
+	}).Return(testCampaign, nil)
+
+	s := &controllers.VizierUpgradeCampaignServer{
+		VzUpgradeCampaign:     mockCampaigns,
+		ArtifactTrackerClient: mockClients.MockArtifact,
+	}
+	resp, err := s.CreateUpgradeCampaign(CreateTestContext(), &cloudpb.CreateUpgradeCampaignRequest{
+		Version:         "0.1.30",
+		Selector:        "env=prod",
+		CanaryCount:     1,
+		WavePercentages: []int32{50, 100},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, testCampaignID, resp.ID)
+	assert.Equal(t, cloudpb.UCS_RUNNING, resp.State)
+	assert.Equal(t, int32(3), resp.NumWaves)
+	require.Len(t, resp.Clusters, 1)
+	assert.Equal(t, testCampaign.Clusters[0].VizierID, resp.Clusters[0].ClusterID)
+	assert.Equal(t, "test_cluster", resp.Clusters[0].ClusterName)
+	assert.Equal(t, cloudpb.UCCS_UPDATING, resp.Clusters[0].State)
+}
+
+func TestVizierUpgradeCampaignServer_CreateUpgradeCampaign_InvalidVersion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	_, mockClients, cleanup := testutils.CreateTestAPIEnv(t)
+	defer cleanup()
+	mockCampaigns := mock_vzmgrpb.NewMockVZUpgradeCampaignServiceClient(ctrl)
+
+	mockClients.MockArtifact.EXPECT().
+		GetDownloadLink(gomock.Any(), gomock.Any()).
+		Return(nil, errors.New("no such version"))
+
+	s := &controllers.VizierUpgradeCampaignServer{
+		VzUpgradeCampaign:     mockCampaigns,
+		ArtifactTrackerClient: mockClients.MockArtifact,
+	}
+	_, err := s.CreateUpgradeCampaign(CreateTestContext(), &cloudpb.CreateUpgradeCampaignRequest{Version: "0.0.0"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = s.CreateUpgradeCampaign(CreateTestContext(), &cloudpb.CreateUpgradeCampaignRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestVizierUpgradeCampaignServer_ListUpgradeCampaigns(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockCampaigns := mock_vzmgrpb.NewMockVZUpgradeCampaignServiceClient(ctrl)
+	mockCampaigns.EXPECT().List(gomock.Any(), &vzmgrpb.ListUpgradeCampaignsRequest{
+		OrgID: utils.ProtoFromUUIDStrOrNil("6ba7b810-9dad-11d1-80b4-00c04fd430c8"),
+	}).Return(&vzmgrpb.ListUpgradeCampaignsResponse{
+		Campaigns: []*vzmgrpb.UpgradeCampaign{testCampaign},
+	}, nil)
+
+	s := &controllers.VizierUpgradeCampaignServer{VzUpgradeCampaign: mockCampaigns}
+	resp, err := s.ListUpgradeCampaigns(createOrgRoleTestContext(svcutils.OrgRoleViewer), &cloudpb.ListUpgradeCampaignsRequest{})
+	require.NoError(t, err)
+	require.Len(t, resp.Campaigns, 1)
+	assert.Equal(t, testCampaignID, resp.Campaigns[0].ID)
+	assert.Equal(t, "env=prod", resp.Campaigns[0].Selector)
+}
+
+func TestVizierUpgradeCampaignServer_SetUpgradeCampaignState(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	paused := *testCampaign
+	paused.State = vzmgrpb.UCS_PAUSED
+
+	mockCampaigns := mock_vzmgrpb.NewMockVZUpgradeCampaignServiceClient(ctrl)
+	mockCampaigns.EXPECT().SetState(gomock.Any(), &vzmgrpb.SetUpgradeCampaignStateRequest{
+		ID:    testCampaignID,
+		OrgID: utils.ProtoFromUUIDStrOrNil("6ba7b810-9dad-11d1-80b4-00c04fd430c8"),
+		State: vzmgrpb.UCS_PAUSED,
+	}).Return(&paused, nil)
+
+	s := &controllers.VizierUpgradeCampaignServer{VzUpgradeCampaign: mockCampaigns}
+	resp, err := s.SetUpgradeCampaignState(CreateTestContext(), &cloudpb.SetUpgradeCampaignStateRequest{
+		ID:    testCampaignID,
+		State: cloudpb.UCS_PAUSED,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, cloudpb.UCS_PAUSED, resp.State)
+}
+
+func TestVizierUpgradeCampaignServer_ClusterRestrictedAPIKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s := &controllers.VizierUpgradeCampaignServer{VzUpgradeCampaign: mock_vzmgrpb.NewMockVZUpgradeCampaignServiceClient(ctrl)}
+	ctx := createScopedAPIUserTestContext([]string{svcutils.APIKeyScopeClusterAdmin}, []string{testAllowedClusterID})
+	_, err := s.ListUpgradeCampaigns(ctx, &cloudpb.ListUpgradeCampaignsRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
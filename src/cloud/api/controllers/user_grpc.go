@@ -57,6 +57,7 @@ func (u *UserServiceServer) GetUser(ctx context.Context, req *uuidpb.UUID) (*clo
 		Email:          resp.Email,
 		ProfilePicture: resp.ProfilePicture,
 		IsApproved:     resp.IsApproved,
+		OrgRole:        resp.OrgRole,
 	}, nil
 }
 
@@ -119,8 +120,7 @@ func (u *UserServiceServer) UpdateUser(ctx context.Context, req *cloudpb.UpdateU
 	claimsUserID := uuid.FromStringOrNil(sCtx.Claims.GetUserClaims().UserID)
 
 	// Check permissions.
-	// If user is in the org, they are permitted to update any org user's info, since all
-	// users are considered admins.
+	// Users in the org may update each other's info, but approvals and roles are managed by admins.
 	userResp, err := u.ProfileServiceClient.GetUser(ctx, req.ID)
 	if err != nil {
 		return nil, err
@@ -128,10 +128,21 @@ func (u *UserServiceServer) UpdateUser(ctx context.Context, req *cloudpb.UpdateU
 	if claimsOrgID != utils.UUIDFromProtoOrNil(userResp.OrgID) {
 		return nil, errors.New("Unauthorized")
 	}
-	// A user cannot update their own "isApproved" status.
-	if req.IsApproved != nil && claimsUserID == utils.UUIDFromProtoOrNil(userResp.ID) {
+	// A user cannot update their own "isApproved" status or role.
+	isSelf := claimsUserID == utils.UUIDFromProtoOrNil(userResp.ID)
+	if (req.IsApproved != nil || req.OrgRole != nil) && isSelf {
 		return nil, errors.New("Unauthorized")
 	}
+	if req.IsApproved != nil {
+		if err := requireOrgRole(ctx, claimsutils.OrgRoleAdmin); err != nil {
+			return nil, err
+		}
+	}
+	if req.OrgRole != nil {
+		if err := checkCanAssignOrgRole(ctx, userResp.OrgRole, req.OrgRole.Value); err != nil {
+			return nil, err
+		}
+	}
 
 	ctx, err = contextWithAuthToken(ctx)
 	if err != nil {
@@ -142,6 +153,7 @@ func (u *UserServiceServer) UpdateUser(ctx context.Context, req *cloudpb.UpdateU
 		ID:             req.ID,
 		DisplayPicture: req.DisplayPicture,
 		IsApproved:     req.IsApproved,
+		OrgRole:        req.OrgRole,
 	}
 
 	resp, err := u.ProfileServiceClient.UpdateUser(ctx, in)
@@ -157,6 +169,7 @@ func (u *UserServiceServer) UpdateUser(ctx context.Context, req *cloudpb.UpdateU
 		Email:          resp.Email,
 		ProfilePicture: resp.ProfilePicture,
 		IsApproved:     resp.IsApproved,
+		OrgRole:        resp.OrgRole,
 	}, nil
 }
 
@@ -92,6 +92,11 @@ func (u *UserInfoResolver) IsApproved() bool {
 	return u.UserInfo.IsApproved
 }
 
+// OrgRole returns the user's role in their org.
+func (u *UserInfoResolver) OrgRole() string {
+	return u.UserInfo.OrgRole
+}
+
 // UserSettingsResolver resolves user settings.
 type UserSettingsResolver struct {
 	AnalyticsOptout bool
@@ -158,6 +163,7 @@ type updateUserPermissionsArgs struct {
 
 type editableUserPermissions struct {
 	IsApproved *bool
+	OrgRole    *string
 }
 
 // UpdateUserPermissions updates user permissions.
@@ -170,6 +176,9 @@ func (q *QueryResolver) UpdateUserPermissions(ctx context.Context, args *updateU
 	if args.UserPermissions.IsApproved != nil {
 		req.IsApproved = &types.BoolValue{Value: *args.UserPermissions.IsApproved}
 	}
+	if args.UserPermissions.OrgRole != nil {
+		req.OrgRole = &types.StringValue{Value: *args.UserPermissions.OrgRole}
+	}
 
 	_, err := q.Env.UserServer.UpdateUser(ctx, req)
 	if err != nil {
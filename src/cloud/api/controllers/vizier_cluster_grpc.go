@@ -35,6 +35,7 @@ import (
 	"px.dev/pixie/src/shared/cvmsgspb"
 	"px.dev/pixie/src/shared/k8s/metadatapb"
 	"px.dev/pixie/src/shared/services/authcontext"
+	srvutils "px.dev/pixie/src/shared/services/utils"
 	"px.dev/pixie/src/utils"
 )
 
@@ -79,14 +80,26 @@ func (v *VizierClusterInfo) GetClusterInfo(ctx context.Context, request *cloudpb
 	if request.ID != nil {
 		vzIDs = append(vzIDs, request.ID)
 	} else {
-		viziers, err := v.VzMgr.GetViziersByOrg(ctx, utils.ProtoFromUUID(orgID))
+		var viziers *vzmgrpb.GetViziersByOrgResponse
+		if request.Selector == "" {
+			viziers, err = v.VzMgr.GetViziersByOrg(ctx, utils.ProtoFromUUID(orgID))
+		} else {
+			viziers, err = v.VzMgr.GetViziersBySelector(ctx, &vzmgrpb.GetViziersBySelectorRequest{
+				OrgID:    utils.ProtoFromUUID(orgID),
+				Selector: request.Selector,
+			})
+		}
 		if err != nil {
 			return nil, err
 		}
-		vzIDs = viziers.VizierIDs
+		for _, id := range viziers.VizierIDs {
+			if srvutils.CanAccessCluster(sCtx.Claims, utils.UUIDFromProtoOrNil(id).String()) {
+				vzIDs = append(vzIDs, id)
+			}
+		}
 	}
 
-	return v.getClusterInfoForViziers(ctx, vzIDs)
+	return v.getClusterInfoForViziers(ctx, vzIDs, request.Selector)
 }
 
 func convertContainerState(cs metadatapb.ContainerState) cloudpb.ContainerState {
@@ -161,12 +174,13 @@ func convertPodStatuses(vzMgrStatuses map[string]*cvmsgspb.PodStatus) map[string
 	return podStatuses
 }
 
-func (v *VizierClusterInfo) getClusterInfoForViziers(ctx context.Context, ids []*uuidpb.UUID) (*cloudpb.GetClusterInfoResponse, error) {
+func (v *VizierClusterInfo) getClusterInfoForViziers(ctx context.Context, ids []*uuidpb.UUID, selector string) (*cloudpb.GetClusterInfoResponse, error) {
 	resp := &cloudpb.GetClusterInfoResponse{}
 
 	cNames := make(map[string]int)
 	vzInfoResp, err := v.VzMgr.GetVizierInfos(ctx, &vzmgrpb.GetVizierInfosRequest{
 		VizierIDs: ids,
+		Selector:  selector,
 	})
 
 	if err != nil {
@@ -208,6 +222,7 @@ func (v *VizierClusterInfo) getClusterInfoForViziers(ctx context.Context, ids []
 			NumInstrumentedNodes:          vzInfo.NumInstrumentedNodes,
 			PreviousStatus:                prevS,
 			PreviousStatusTime:            vzInfo.PreviousStatusTime,
+			Tags:                          vzInfo.Tags,
 		})
 	}
 
@@ -241,11 +256,17 @@ func (v *VizierClusterInfo) GetClusterConnectionInfo(ctx context.Context, reques
 
 // UpdateClusterVizierConfig supports updates of VizierConfig for a cluster
 func (v *VizierClusterInfo) UpdateClusterVizierConfig(ctx context.Context, req *cloudpb.UpdateClusterVizierConfigRequest) (*cloudpb.UpdateClusterVizierConfigResponse, error) {
+	if err := requireOrgRole(ctx, srvutils.OrgRoleAdmin); err != nil {
+		return nil, err
+	}
 	return &cloudpb.UpdateClusterVizierConfigResponse{}, nil
 }
 
 // UpdateOrInstallCluster updates or installs the given vizier cluster to the specified version.
 func (v *VizierClusterInfo) UpdateOrInstallCluster(ctx context.Context, req *cloudpb.UpdateOrInstallClusterRequest) (*cloudpb.UpdateOrInstallClusterResponse, error) {
+	if err := requireOrgRole(ctx, srvutils.OrgRoleAdmin); err != nil {
+		return nil, err
+	}
 	if req.Version == "" {
 		return nil, status.Errorf(codes.InvalidArgument, "version cannot be empty")
 	}
@@ -281,6 +302,61 @@ func (v *VizierClusterInfo) UpdateOrInstallCluster(ctx context.Context, req *clo
 	}, nil
 }
 
+// UpdateClusterTags adds, updates or removes the tags of the given cluster.
+func (v *VizierClusterInfo) UpdateClusterTags(ctx context.Context, req *cloudpb.UpdateClusterTagsRequest) (*cloudpb.UpdateClusterTagsResponse, error) {
+	if err := requireOrgRole(ctx, srvutils.OrgRoleAdmin); err != nil {
+		return nil, err
+	}
+
+	ctx, err := contextWithAuthToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := v.VzMgr.UpdateVizierTags(ctx, &vzmgrpb.UpdateVizierTagsRequest{
+		VizierID:    req.ID,
+		Tags:        req.Tags,
+		RemovedTags: req.RemovedTags,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &cloudpb.UpdateClusterTagsResponse{
+		Tags: resp.Tags,
+	}, nil
+}
+
+// GetClusterStatusHistory gets the status transitions of the given cluster, most recent first.
+func (v *VizierClusterInfo) GetClusterStatusHistory(ctx context.Context, req *cloudpb.GetClusterStatusHistoryRequest) (*cloudpb.GetClusterStatusHistoryResponse, error) {
+	ctx, err := contextWithAuthToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := v.VzMgr.GetVizierStatusHistory(ctx, &vzmgrpb.GetVizierStatusHistoryRequest{
+		VizierID:  req.ID,
+		StartTime: req.StartTime,
+		Limit:     req.Limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	transitions := make([]*cloudpb.ClusterStatusTransition, len(resp.Transitions))
+	for i, t := range resp.Transitions {
+		transitions[i] = &cloudpb.ClusterStatusTransition{
+			Time:                 t.Time,
+			Status:               vzStatusToClusterStatus(t.Status),
+			StatusMessage:        t.StatusMessage,
+			NumNodes:             t.NumNodes,
+			NumInstrumentedNodes: t.NumInstrumentedNodes,
+			VizierVersion:        t.VizierVersion,
+		}
+	}
+	return &cloudpb.GetClusterStatusHistoryResponse{Transitions: transitions}, nil
+}
+
 func vzStatusToClusterStatus(s cvmsgspb.VizierStatus) cloudpb.ClusterStatus {
 	switch s {
 	case cvmsgspb.VZ_ST_HEALTHY:
@@ -454,3 +454,152 @@ func TestVizierClusterInfo_UpdateOrInstallCluster(t *testing.T) {
 		})
 	}
 }
+
+func TestVizierClusterInfo_GetClusterInfo_AllowedClusters(t *testing.T) {
+	orgID := utils.ProtoFromUUIDStrOrNil("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+	allowedID := utils.ProtoFromUUIDStrOrNil(testAllowedClusterID)
+	otherID := utils.ProtoFromUUIDStrOrNil(testOtherClusterID)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	_, mockClients, cleanup := testutils.CreateTestAPIEnv(t)
+	defer cleanup()
+	ctx := createScopedAPIUserTestContext(nil, []string{testAllowedClusterID})
+
+	mockClients.MockVzMgr.EXPECT().GetViziersByOrg(gomock.Any(), orgID).Return(&vzmgrpb.GetViziersByOrgResponse{
+		VizierIDs: []*uuidpb.UUID{allowedID, otherID},
+	}, nil)
+	// Only the clusters in the allowlist of the API key are looked up.
+	mockClients.MockVzMgr.EXPECT().GetVizierInfos(gomock.Any(), &vzmgrpb.GetVizierInfosRequest{
+		VizierIDs: []*uuidpb.UUID{allowedID},
+	}).Return(&vzmgrpb.GetVizierInfosResponse{
+		VizierInfos: []*cvmsgspb.VizierInfo{{
+			VizierID: allowedID,
+			Config:   &cvmsgspb.VizierConfig{},
+		}},
+	}, nil)
+
+	vzClusterInfoServer := &controllers.VizierClusterInfo{
+		VzMgr: mockClients.MockVzMgr,
+	}
+	resp, err := vzClusterInfoServer.GetClusterInfo(ctx, &cloudpb.GetClusterInfoRequest{})
+	require.NoError(t, err)
+	require.Len(t, resp.Clusters, 1)
+	assert.Equal(t, allowedID, resp.Clusters[0].ID)
+}
+
+func TestVizierClusterInfo_GetClusterInfo_Selector(t *testing.T) {
+	orgID := utils.ProtoFromUUIDStrOrNil("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+	clusterID := utils.ProtoFromUUIDStrOrNil("7ba7b810-9dad-11d1-80b4-00c04fd430c8")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	_, mockClients, cleanup := testutils.CreateTestAPIEnv(t)
+	defer cleanup()
+
+	mockClients.MockVzMgr.EXPECT().GetViziersBySelector(gomock.Any(), &vzmgrpb.GetViziersBySelectorRequest{
+		OrgID:    orgID,
+		Selector: "env=prod",
+	}).Return(&vzmgrpb.GetViziersByOrgResponse{
+		VizierIDs: []*uuidpb.UUID{clusterID},
+	}, nil)
+	mockClients.MockVzMgr.EXPECT().GetVizierInfos(gomock.Any(), &vzmgrpb.GetVizierInfosRequest{
+		VizierIDs: []*uuidpb.UUID{clusterID},
+		Selector:  "env=prod",
+	}).Return(&vzmgrpb.GetVizierInfosResponse{
+		VizierInfos: []*cvmsgspb.VizierInfo{{
+			VizierID: clusterID,
+			Config:   &cvmsgspb.VizierConfig{},
+			Tags:     map[string]string{"env": "prod"},
+		}},
+	}, nil)
+
+	vzClusterInfoServer := &controllers.VizierClusterInfo{
+		VzMgr: mockClients.MockVzMgr,
+	}
+	resp, err := vzClusterInfoServer.GetClusterInfo(CreateTestContext(), &cloudpb.GetClusterInfoRequest{
+		Selector: "env=prod",
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Clusters, 1)
+	assert.Equal(t, map[string]string{"env": "prod"}, resp.Clusters[0].Tags)
+}
+
+func TestVizierClusterInfo_UpdateClusterTags(t *testing.T) {
+	clusterID := utils.ProtoFromUUIDStrOrNil("7ba7b810-9dad-11d1-80b4-00c04fd430c8")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	_, mockClients, cleanup := testutils.CreateTestAPIEnv(t)
+	defer cleanup()
+
+	mockClients.MockVzMgr.EXPECT().UpdateVizierTags(gomock.Any(), &vzmgrpb.UpdateVizierTagsRequest{
+		VizierID:    clusterID,
+		Tags:        map[string]string{"env": "prod"},
+		RemovedTags: []string{"team"},
+	}).Return(&vzmgrpb.UpdateVizierTagsResponse{
+		Tags: map[string]string{"env": "prod", "region": "us"},
+	}, nil)
+
+	vzClusterInfoServer := &controllers.VizierClusterInfo{
+		VzMgr: mockClients.MockVzMgr,
+	}
+	resp, err := vzClusterInfoServer.UpdateClusterTags(CreateTestContext(), &cloudpb.UpdateClusterTagsRequest{
+		ID:          clusterID,
+		Tags:        map[string]string{"env": "prod"},
+		RemovedTags: []string{"team"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"env": "prod", "region": "us"}, resp.Tags)
+}
+
+func TestVizierClusterInfo_GetClusterStatusHistory(t *testing.T) {
+	clusterID := utils.ProtoFromUUIDStrOrNil("7ba7b810-9dad-11d1-80b4-00c04fd430c8")
+	startTime := types.TimestampNow()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	_, mockClients, cleanup := testutils.CreateTestAPIEnv(t)
+	defer cleanup()
+
+	mockClients.MockVzMgr.EXPECT().GetVizierStatusHistory(gomock.Any(), &vzmgrpb.GetVizierStatusHistoryRequest{
+		VizierID:  clusterID,
+		StartTime: startTime,
+		Limit:     10,
+	}).Return(&vzmgrpb.GetVizierStatusHistoryResponse{
+		Transitions: []*vzmgrpb.VizierStatusTransition{
+			{
+				Time:                 startTime,
+				Status:               cvmsgspb.VZ_ST_DEGRADED,
+				StatusMessage:        "2 PEMs are not running",
+				NumNodes:             5,
+				NumInstrumentedNodes: 3,
+				VizierVersion:        "0.1.30",
+			},
+		},
+	}, nil)
+
+	vzClusterInfoServer := &controllers.VizierClusterInfo{
+		VzMgr: mockClients.MockVzMgr,
+	}
+	resp, err := vzClusterInfoServer.GetClusterStatusHistory(CreateTestContext(), &cloudpb.GetClusterStatusHistoryRequest{
+		ID:        clusterID,
+		StartTime: startTime,
+		Limit:     10,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []*cloudpb.ClusterStatusTransition{
+		{
+			Time:                 startTime,
+			Status:               cloudpb.CS_DEGRADED,
+			StatusMessage:        "2 PEMs are not running",
+			NumNodes:             5,
+			NumInstrumentedNodes: 3,
+			VizierVersion:        "0.1.30",
+		},
+	}, resp.Transitions)
+}
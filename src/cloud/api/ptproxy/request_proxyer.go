@@ -294,6 +294,12 @@ func (p *requestProxyer) processNatsMsg(msg *nats.Msg) error {
 			log.WithError(err).Error("Failed to send message")
 			return err
 		}
+	case *cvmsgspb.V2CAPIStreamResponse_K8sHistoryResp:
+		err = p.srv.SendMsg(parsed.K8sHistoryResp)
+		if err != nil {
+			log.WithError(err).Error("Failed to send message")
+			return err
+		}
 	case *cvmsgspb.V2CAPIStreamResponse_DebugLogResp:
 		err = p.srv.SendMsg(parsed.DebugLogResp)
 		if err != nil {
@@ -132,6 +132,41 @@ func (v *VizierPassThroughProxy) GenerateOTelScript(ctx context.Context, req *vi
 	return srv.resp, nil
 }
 
+// k8sHistoryStream is a stream fake that fits into the request proxyer interface.
+type k8sHistoryStream struct {
+	resp *vizierpb.GetK8SHistoryResponse
+	ctx  context.Context
+}
+
+func (ks *k8sHistoryStream) Context() context.Context {
+	return ks.ctx
+}
+
+func (ks *k8sHistoryStream) SendMsg(data interface{}) error {
+	ks.resp = data.(*vizierpb.GetK8SHistoryResponse)
+	return nil
+}
+
+// GetK8SHistory is the GRPC method to query the K8s update history stored in a cluster.
+func (v *VizierPassThroughProxy) GetK8SHistory(ctx context.Context, req *vizierpb.GetK8SHistoryRequest) (*vizierpb.GetK8SHistoryResponse, error) {
+	srv := &k8sHistoryStream{ctx: ctx}
+	rp, err := newRequestProxyer(v.vc, v.nc, true, req, srv)
+	if err != nil {
+		return nil, err
+	}
+	defer rp.Finish()
+	vizReq := rp.prepareVizierRequest()
+	vizReq.Msg = &cvmsgspb.C2VAPIStreamRequest_K8sHistoryReq{K8sHistoryReq: req}
+	if err := rp.sendMessageToVizier(vizReq); err != nil {
+		return nil, err
+	}
+	err = rp.Run()
+	if err != nil {
+		return nil, err
+	}
+	return srv.resp, nil
+}
+
 // DebugPods is the GRPC method to fetch the list of Vizier pods (and statuses) from a cluster.
 func (v *VizierPassThroughProxy) DebugPods(req *vizierpb.DebugPodsRequest, srv vizierpb.VizierDebugService_DebugPodsServer) error {
 	rp, err := newRequestProxyer(v.vc, v.nc, true, req, srv)
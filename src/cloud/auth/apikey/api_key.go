@@ -29,30 +29,38 @@ import (
 	"github.com/gofrs/uuid"
 	"github.com/gogo/protobuf/types"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	log "github.com/sirupsen/logrus"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
 	"px.dev/pixie/src/api/proto/uuidpb"
 	"px.dev/pixie/src/cloud/auth/authpb"
+	"px.dev/pixie/src/cloud/shared/audit"
 	"px.dev/pixie/src/shared/services/authcontext"
+	srvutils "px.dev/pixie/src/shared/services/utils"
 	"px.dev/pixie/src/utils"
 )
 
 var (
 	// ErrAPIKeyNotFound is used when the specified API key cannot be located.
 	ErrAPIKeyNotFound = errors.New("invalid API key")
+	// ErrAPIKeyExpired is used when the specified API key is past its expiry.
+	ErrAPIKeyExpired = errors.New("API key has expired")
 )
 
 const (
 	// apiKeyPrefix is applied to all api keys to make them easier to identify.
 	apiKeyPrefix = "px-api-"
+	// auditResourceType is the resource type of audit events for API keys.
+	auditResourceType = "api_key"
 )
 
 // Service is used to provision and manage API keys.
 type Service struct {
 	db    *sqlx.DB
 	dbKey string
+	audit audit.Recorder
 }
 
 // New creates a new Service.
@@ -60,22 +68,76 @@ func New(db *sqlx.DB, dbKey string) *Service {
 	return &Service{
 		db:    db,
 		dbKey: dbKey,
+		audit: audit.NewDatastore(db),
 	}
 }
 
+// apiKeyAuditState is the state of an API key recorded in audit events. It never includes the key.
+type apiKeyAuditState struct {
+	Desc       string     `json:"desc"`
+	Scopes     []string   `json:"scopes,omitempty"`
+	ClusterIDs []string   `json:"clusterIDs,omitempty"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+}
+
+// restrictionsFromRequest validates the scopes, expiry and cluster allowlist requested for a new key.
+func restrictionsFromRequest(req *authpb.CreateAPIKeyRequest) ([]string, []string, *time.Time, error) {
+	scopes := make([]string, 0, len(req.Scopes))
+	seen := make(map[string]bool)
+	for _, scope := range req.Scopes {
+		if !srvutils.IsValidAPIKeyScope(scope) {
+			return nil, nil, nil, status.Errorf(codes.InvalidArgument, "invalid API key scope %q", scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+
+	clusterIDs := make([]string, 0, len(req.ClusterIDs))
+	for _, c := range req.ClusterIDs {
+		id, err := utils.UUIDFromProto(c)
+		if err != nil {
+			return nil, nil, nil, status.Error(codes.InvalidArgument, "invalid cluster id format")
+		}
+		clusterIDs = append(clusterIDs, id.String())
+	}
+
+	if req.ExpiresAt == nil {
+		return scopes, clusterIDs, nil, nil
+	}
+	expiresAt, err := types.TimestampFromProto(req.ExpiresAt)
+	if err != nil {
+		return nil, nil, nil, status.Error(codes.InvalidArgument, "invalid expiry")
+	}
+	if !expiresAt.After(time.Now()) {
+		return nil, nil, nil, status.Error(codes.InvalidArgument, "expiry must be in the future")
+	}
+	return scopes, clusterIDs, &expiresAt, nil
+}
+
 // Create a key with the org/user as an owner.
 func (s *Service) Create(ctx context.Context, req *authpb.CreateAPIKeyRequest) (*authpb.APIKey, error) {
 	sCtx, err := authcontext.FromContext(ctx)
 	if err != nil {
 		return nil, status.Error(codes.Unauthenticated, err.Error())
 	}
+	// A restricted key could otherwise be used to mint a key without its restrictions.
+	if srvutils.IsRestrictedAPIKey(sCtx.Claims) {
+		return nil, status.Error(codes.PermissionDenied, "scoped API keys cannot create API keys")
+	}
+
+	scopes, clusterIDs, expiresAt, err := restrictionsFromRequest(req)
+	if err != nil {
+		return nil, err
+	}
 
 	var id uuid.UUID
 	var ts time.Time
 	// We store a version of the key in hashed_key that is salted using a constant salt (dbKey),
 	// to allow us to an associative lookup. This is secure since the API key is a UUID and won't collide.
-	query := `INSERT INTO api_keys(org_id, user_id, hashed_key, encrypted_key, description)
-                VALUES($1, $2, sha256($3), PGP_SYM_ENCRYPT($3::text, $4::text), $5)
+	query := `INSERT INTO api_keys(org_id, user_id, hashed_key, encrypted_key, description, scopes, cluster_ids, expires_at)
+                VALUES($1, $2, sha256($3), PGP_SYM_ENCRYPT($3::text, $4::text), $5, $6, $7, $8)
                 RETURNING id, created_at`
 	keyID, err := uuid.NewV4()
 	if err != nil {
@@ -87,18 +149,32 @@ func (s *Service) Create(ctx context.Context, req *authpb.CreateAPIKeyRequest) (
 		sCtx.Claims.GetUserClaims().UserID,
 		key,
 		s.dbKey,
-		req.Desc).
+		req.Desc,
+		pq.StringArray(scopes),
+		pq.StringArray(clusterIDs),
+		expiresAt).
 		Scan(&id, &ts)
 	if err != nil {
 		log.WithError(err).Error("Failed to insert API keys")
 		return nil, status.Error(codes.Internal, "Failed to insert API keys")
 	}
+	audit.Emit(ctx, s.audit, audit.NewEvent(ctx, uuid.FromStringOrNil(sCtx.Claims.GetUserClaims().OrgID),
+		"api_key.create", auditResourceType, id.String(), nil,
+		&apiKeyAuditState{Desc: req.Desc, Scopes: scopes, ClusterIDs: clusterIDs, ExpiresAt: expiresAt}))
 
 	tp, _ := types.TimestampProto(ts)
+	var expiresAtProto *types.Timestamp
+	if expiresAt != nil {
+		expiresAtProto, _ = types.TimestampProto(*expiresAt)
+	}
 	return &authpb.APIKey{
-		ID:        utils.ProtoFromUUID(id),
-		Key:       key,
-		CreatedAt: tp,
+		ID:         utils.ProtoFromUUID(id),
+		Key:        key,
+		CreatedAt:  tp,
+		Desc:       req.Desc,
+		Scopes:     scopes,
+		ExpiresAt:  expiresAtProto,
+		ClusterIDs: protosFromUUIDStrs(clusterIDs),
 	}, nil
 }
 
@@ -110,7 +186,7 @@ func (s *Service) List(ctx context.Context, req *authpb.ListAPIKeyRequest) (*aut
 	}
 
 	// Return all keys when the OrgID matches.
-	query := `SELECT id, org_id, user_id, created_at, description
+	query := `SELECT id, org_id, user_id, created_at, description, scopes, cluster_ids, expires_at, last_used_at
                 FROM api_keys
                 WHERE org_id=$1
                 ORDER BY created_at`
@@ -131,18 +207,23 @@ func (s *Service) List(ctx context.Context, req *authpb.ListAPIKeyRequest) (*aut
 		var userID uuid.UUID
 		var createdAt time.Time
 		var desc string
-		err = rows.Scan(&id, &orgID, &userID, &createdAt, &desc)
+		var r restrictions
+		err = rows.Scan(&id, &orgID, &userID, &createdAt, &desc, &r.scopes, &r.clusterIDs, &r.expiresAt, &r.lastUsedAt)
 		if err != nil {
 			log.WithError(err).Error("Failed to read data from postgres")
 			return nil, status.Error(codes.Internal, "failed to read data")
 		}
 		tProto, _ := types.TimestampProto(createdAt)
 		keys = append(keys, &authpb.APIKeyMetadata{
-			ID:        utils.ProtoFromUUIDStrOrNil(id),
-			OrgID:     utils.ProtoFromUUID(orgID),
-			UserID:    utils.ProtoFromUUID(userID),
-			CreatedAt: tProto,
-			Desc:      desc,
+			ID:         utils.ProtoFromUUIDStrOrNil(id),
+			OrgID:      utils.ProtoFromUUID(orgID),
+			UserID:     utils.ProtoFromUUID(userID),
+			CreatedAt:  tProto,
+			Desc:       desc,
+			Scopes:     r.scopes,
+			ExpiresAt:  timestampProtoOrNil(r.expiresAt),
+			ClusterIDs: protosFromUUIDStrs(r.clusterIDs),
+			LastUsedAt: timestampProtoOrNil(r.lastUsedAt),
 		})
 	}
 	return &authpb.ListAPIKeyResponse{
@@ -166,10 +247,13 @@ func (s *Service) Get(ctx context.Context, req *authpb.GetAPIKeyRequest) (*authp
 	var key string
 	var createdAt time.Time
 	var desc string
-	query := `SELECT CONVERT_FROM(PGP_SYM_DECRYPT(encrypted_key, $3::text)::bytea, 'UTF8'), org_id, user_id, created_at, description
+	var r restrictions
+	query := `SELECT CONVERT_FROM(PGP_SYM_DECRYPT(encrypted_key, $3::text)::bytea, 'UTF8'), org_id, user_id, created_at, description,
+                  scopes, cluster_ids, expires_at, last_used_at
                 FROM api_keys
                 WHERE org_id=$1 AND id=$2`
-	err = s.db.QueryRowxContext(ctx, query, sCtx.Claims.GetUserClaims().OrgID, tokenID, s.dbKey).Scan(&key, &orgID, &userID, &createdAt, &desc)
+	err = s.db.QueryRowxContext(ctx, query, sCtx.Claims.GetUserClaims().OrgID, tokenID, s.dbKey).
+		Scan(&key, &orgID, &userID, &createdAt, &desc, &r.scopes, &r.clusterIDs, &r.expiresAt, &r.lastUsedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, status.Error(codes.NotFound, "No such API key")
@@ -179,12 +263,16 @@ func (s *Service) Get(ctx context.Context, req *authpb.GetAPIKeyRequest) (*authp
 
 	createdAtProto, _ := types.TimestampProto(createdAt)
 	return &authpb.GetAPIKeyResponse{Key: &authpb.APIKey{
-		ID:        req.ID,
-		OrgID:     utils.ProtoFromUUID(orgID),
-		UserID:    utils.ProtoFromUUID(userID),
-		Key:       key,
-		CreatedAt: createdAtProto,
-		Desc:      desc,
+		ID:         req.ID,
+		OrgID:      utils.ProtoFromUUID(orgID),
+		UserID:     utils.ProtoFromUUID(userID),
+		Key:        key,
+		CreatedAt:  createdAtProto,
+		Desc:       desc,
+		Scopes:     r.scopes,
+		ExpiresAt:  timestampProtoOrNil(r.expiresAt),
+		ClusterIDs: protosFromUUIDStrs(r.clusterIDs),
+		LastUsedAt: timestampProtoOrNil(r.lastUsedAt),
 	}}, nil
 }
 
@@ -200,42 +288,83 @@ func (s *Service) Delete(ctx context.Context, req *uuidpb.UUID) (*types.Empty, e
 		return nil, status.Error(codes.InvalidArgument, "invalid id format")
 	}
 
+	var desc string
+	var r restrictions
 	query := `DELETE FROM api_keys
-                WHERE org_id=$1 AND id=$2`
-	res, err := s.db.ExecContext(ctx, query, sCtx.Claims.GetUserClaims().OrgID, tokenID)
+                WHERE org_id=$1 AND id=$2
+                RETURNING description, scopes, cluster_ids, expires_at`
+	err = s.db.QueryRowxContext(ctx, query, sCtx.Claims.GetUserClaims().OrgID, tokenID).
+		Scan(&desc, &r.scopes, &r.clusterIDs, &r.expiresAt)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, status.Error(codes.NotFound, "no such token to delete")
+		}
 		log.WithError(err).Error("Failed to delete API token")
 		return nil, status.Error(codes.Internal, "failed to delete API token")
 	}
 
-	c, err := res.RowsAffected()
-	if err != nil {
-		log.WithError(err).Error("Failed to delete API token")
-		return nil, status.Error(codes.Internal, "failed to delete API token")
+	before := &apiKeyAuditState{Desc: desc, Scopes: r.scopes, ClusterIDs: r.clusterIDs}
+	if r.expiresAt.Valid {
+		before.ExpiresAt = &r.expiresAt.Time
 	}
+	audit.Emit(ctx, s.audit, audit.NewEvent(ctx, uuid.FromStringOrNil(sCtx.Claims.GetUserClaims().OrgID),
+		"api_key.delete", auditResourceType, tokenID.String(), before, nil))
+
+	return &types.Empty{}, nil
+}
 
-	if c == 0 {
-		return nil, status.Error(codes.NotFound, "no such token to delete")
+// DeleteAPIKeysForUser removes all of the keys that the user has in the given org. This is used to
+// revoke a user's keys when they are deprovisioned.
+func (s *Service) DeleteAPIKeysForUser(ctx context.Context, orgID uuid.UUID, userID uuid.UUID) error {
+	query := `DELETE FROM api_keys
+                WHERE org_id=$1 AND user_id=$2
+                RETURNING id, description, scopes, cluster_ids, expires_at`
+	rows, err := s.db.QueryxContext(ctx, query, orgID, userID)
+	if err != nil {
+		log.WithError(err).Error("Failed to delete API tokens for user")
+		return status.Error(codes.Internal, "failed to delete API tokens for user")
 	}
+	defer rows.Close()
 
-	return &types.Empty{}, nil
+	for rows.Next() {
+		var id uuid.UUID
+		var desc string
+		var r restrictions
+		err = rows.Scan(&id, &desc, &r.scopes, &r.clusterIDs, &r.expiresAt)
+		if err != nil {
+			log.WithError(err).Error("Failed to read deleted API token")
+			return status.Error(codes.Internal, "failed to delete API tokens for user")
+		}
+
+		before := &apiKeyAuditState{Desc: desc, Scopes: r.scopes, ClusterIDs: r.clusterIDs}
+		if r.expiresAt.Valid {
+			before.ExpiresAt = &r.expiresAt.Time
+		}
+		audit.Emit(ctx, s.audit, audit.NewEvent(ctx, orgID, "api_key.delete", auditResourceType, id.String(), before, nil))
+	}
+	return rows.Err()
 }
 
-// FetchOrgUserIDUsingAPIKey gets the org and user ID based on the API key.
-func (s *Service) FetchOrgUserIDUsingAPIKey(ctx context.Context, key string) (uuid.UUID, uuid.UUID, error) {
+// FetchAPIKeyForAuth gets the API key which is used to authenticate a request, and records its use.
+// Expired keys are rejected with ErrAPIKeyExpired.
+func (s *Service) FetchAPIKeyForAuth(ctx context.Context, key string) (*authpb.APIKey, error) {
 	resp, err := s.fetchAPIKeyUsingKeyFromDB(ctx, key)
 	if err != nil {
-		return uuid.Nil, uuid.Nil, err
+		return nil, err
 	}
-	oid, err := utils.UUIDFromProto(resp.OrgID)
-	if err != nil {
-		return uuid.Nil, uuid.Nil, err
+	if resp.ExpiresAt != nil {
+		expiresAt, err := types.TimestampFromProto(resp.ExpiresAt)
+		if err != nil || !expiresAt.After(time.Now()) {
+			return nil, ErrAPIKeyExpired
+		}
 	}
-	uid, err := utils.UUIDFromProto(resp.UserID)
+
+	_, err = s.db.ExecContext(ctx, `UPDATE api_keys SET last_used_at=NOW() WHERE id=$1`, utils.UUIDFromProtoOrNil(resp.ID))
 	if err != nil {
-		return uuid.Nil, uuid.Nil, err
+		// Failing to track usage should not lock users out.
+		log.WithError(err).Error("Failed to update API key last used time")
 	}
-	return oid, uid, nil
+	return resp, nil
 }
 
 // LookupAPIKey gets the complete API key information using just the Key.
@@ -268,10 +397,12 @@ func (s *Service) fetchAPIKeyUsingKeyFromDB(ctx context.Context, key string) (*a
 	var userID uuid.UUID
 	var createdAt time.Time
 	var desc string
-	query := `SELECT id, org_id, user_id, created_at, description
+	var r restrictions
+	query := `SELECT id, org_id, user_id, created_at, description, scopes, cluster_ids, expires_at, last_used_at
                 FROM api_keys
                 WHERE hashed_key=sha256($1) and PGP_SYM_DECRYPT(encrypted_key::bytea, $2::text)::bytea=$1`
-	err := s.db.QueryRowxContext(ctx, query, key, s.dbKey).Scan(&id, &orgID, &userID, &createdAt, &desc)
+	err := s.db.QueryRowxContext(ctx, query, key, s.dbKey).
+		Scan(&id, &orgID, &userID, &createdAt, &desc, &r.scopes, &r.clusterIDs, &r.expiresAt, &r.lastUsedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrAPIKeyNotFound
@@ -281,11 +412,42 @@ func (s *Service) fetchAPIKeyUsingKeyFromDB(ctx context.Context, key string) (*a
 
 	createdAtProto, _ := types.TimestampProto(createdAt)
 	return &authpb.APIKey{
-		ID:        utils.ProtoFromUUID(id),
-		OrgID:     utils.ProtoFromUUID(orgID),
-		UserID:    utils.ProtoFromUUID(userID),
-		Key:       key,
-		CreatedAt: createdAtProto,
-		Desc:      desc,
+		ID:         utils.ProtoFromUUID(id),
+		OrgID:      utils.ProtoFromUUID(orgID),
+		UserID:     utils.ProtoFromUUID(userID),
+		Key:        key,
+		CreatedAt:  createdAtProto,
+		Desc:       desc,
+		Scopes:     r.scopes,
+		ExpiresAt:  timestampProtoOrNil(r.expiresAt),
+		ClusterIDs: protosFromUUIDStrs(r.clusterIDs),
+		LastUsedAt: timestampProtoOrNil(r.lastUsedAt),
 	}, nil
 }
+
+// restrictions are the columns which limit what an API key can be used for.
+type restrictions struct {
+	scopes     pq.StringArray
+	clusterIDs pq.StringArray
+	expiresAt  sql.NullTime
+	lastUsedAt sql.NullTime
+}
+
+func timestampProtoOrNil(t sql.NullTime) *types.Timestamp {
+	if !t.Valid {
+		return nil
+	}
+	tp, _ := types.TimestampProto(t.Time)
+	return tp
+}
+
+func protosFromUUIDStrs(ids []string) []*uuidpb.UUID {
+	if len(ids) == 0 {
+		return nil
+	}
+	protos := make([]*uuidpb.UUID, len(ids))
+	for i, id := range ids {
+		protos[i] = utils.ProtoFromUUIDStrOrNil(id)
+	}
+	return protos
+}
@@ -35,6 +35,7 @@ import (
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
+	"px.dev/pixie/src/api/proto/uuidpb"
 	"px.dev/pixie/src/cloud/auth/authpb"
 	"px.dev/pixie/src/cloud/auth/schema"
 	"px.dev/pixie/src/shared/services/authcontext"
@@ -146,6 +147,73 @@ func TestAPIKeyService_CreateAPIKey(t *testing.T) {
 	}
 }
 
+func TestAPIKeyService_CreateAPIKey_Restricted(t *testing.T) {
+	mustLoadTestData(db)
+
+	svc := New(db, testDBKey)
+	clusterID := uuid.Must(uuid.NewV4())
+	expiresAt, _ := types.TimestampProto(time.Now().Add(time.Hour))
+	resp, err := svc.Create(createTestContext(), &authpb.CreateAPIKeyRequest{
+		Desc:       "restricted",
+		Scopes:     []string{jwtutils.APIKeyScopeScriptExecute, jwtutils.APIKeyScopeScriptExecute},
+		ExpiresAt:  expiresAt,
+		ClusterIDs: []*uuidpb.UUID{utils.ProtoFromUUID(clusterID)},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{jwtutils.APIKeyScopeScriptExecute}, resp.Scopes)
+
+	key, err := svc.FetchAPIKeyForAuth(createTestContext(), resp.Key)
+	require.NoError(t, err)
+	assert.Equal(t, []string{jwtutils.APIKeyScopeScriptExecute}, key.Scopes)
+	assert.Equal(t, expiresAt.Seconds, key.ExpiresAt.Seconds)
+	require.Len(t, key.ClusterIDs, 1)
+	assert.Equal(t, clusterID, utils.UUIDFromProtoOrNil(key.ClusterIDs[0]))
+}
+
+func TestAPIKeyService_CreateAPIKey_Invalid(t *testing.T) {
+	mustLoadTestData(db)
+
+	past, _ := types.TimestampProto(time.Now().Add(-time.Hour))
+	scopedCtx := authcontext.New()
+	scopedCtx.Claims = jwtutils.GenerateJWTForAPIUser(testAuthUserID.String(), testAuthOrgID.String(), time.Now(), "pixie")
+	scopedCtx.Claims.GetUserClaims().APIKeyScopes = []string{jwtutils.APIKeyScopeScriptExecute}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		req  *authpb.CreateAPIKeyRequest
+		code codes.Code
+	}{
+		{
+			name: "unknown scope",
+			ctx:  createTestContext(),
+			req:  &authpb.CreateAPIKeyRequest{Scopes: []string{"everything"}},
+			code: codes.InvalidArgument,
+		},
+		{
+			name: "expiry in the past",
+			ctx:  createTestContext(),
+			req:  &authpb.CreateAPIKeyRequest{ExpiresAt: past},
+			code: codes.InvalidArgument,
+		},
+		{
+			name: "scoped api user",
+			ctx:  authcontext.NewContext(context.Background(), scopedCtx),
+			req:  &authpb.CreateAPIKeyRequest{},
+			code: codes.PermissionDenied,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			svc := New(db, testDBKey)
+			resp, err := svc.Create(test.ctx, test.req)
+			assert.Nil(t, resp)
+			assert.Equal(t, test.code, status.Code(err))
+		})
+	}
+}
+
 func TestAPIKeyService_ListAPIKeys(t *testing.T) {
 	mustLoadTestData(db)
 
@@ -356,6 +424,25 @@ func TestAPIKeyService_Delete(t *testing.T) {
 	}
 }
 
+func TestAPIKeyService_DeleteAPIKeysForUser(t *testing.T) {
+	mustLoadTestData(db)
+
+	ctx := createTestContext()
+	svc := New(db, testDBKey)
+
+	err := svc.DeleteAPIKeysForUser(ctx, testAuthOrgID, testAuthUserID)
+	require.NoError(t, err)
+
+	resp, err := svc.List(ctx, &authpb.ListAPIKeyRequest{})
+	require.NoError(t, err)
+	assert.Empty(t, resp.Keys)
+
+	// Keys of other users are untouched.
+	var count int
+	require.NoError(t, db.Get(&count, `SELECT COUNT(*) FROM api_keys WHERE id=$1`, testKey3ID))
+	assert.Equal(t, 1, count)
+}
+
 func TestAPIKeyService_Delete_UnownedKey(t *testing.T) {
 	tests := []struct {
 		name string
@@ -427,7 +514,7 @@ func TestAPIKeyService_Delete_NonExistentKey(t *testing.T) {
 	}
 }
 
-func TestService_FetchOrgUserIDUsingAPIKey(t *testing.T) {
+func TestService_FetchAPIKeyForAuth(t *testing.T) {
 	mustLoadTestData(db)
 
 	tests := []struct {
@@ -449,15 +536,34 @@ func TestService_FetchOrgUserIDUsingAPIKey(t *testing.T) {
 			ctx := test.ctx
 			svc := New(db, testDBKey)
 
-			orgID, userID, err := svc.FetchOrgUserIDUsingAPIKey(ctx, "px-api-key1")
+			key, err := svc.FetchAPIKeyForAuth(ctx, "px-api-key1")
 			require.NoError(t, err)
-			assert.Equal(t, testAuthOrgID, orgID)
-			assert.Equal(t, testAuthUserID, userID)
+			assert.Equal(t, testAuthOrgID, utils.UUIDFromProtoOrNil(key.OrgID))
+			assert.Equal(t, testAuthUserID, utils.UUIDFromProtoOrNil(key.UserID))
+
+			// Using the key records its last use.
+			resp, err := svc.Get(ctx, &authpb.GetAPIKeyRequest{ID: utils.ProtoFromUUID(testKey1ID)})
+			require.NoError(t, err)
+			assert.NotNil(t, resp.Key.LastUsedAt)
 		})
 	}
 }
 
-func TestService_FetchOrgUserIDUsingAPIKey_BadKey(t *testing.T) {
+func TestService_FetchAPIKeyForAuth_Expired(t *testing.T) {
+	mustLoadTestData(db)
+	db.MustExec(`UPDATE api_keys SET expires_at=NOW() - INTERVAL '1 hour' WHERE id=$1`, testKey1ID)
+
+	svc := New(db, testDBKey)
+	key, err := svc.FetchAPIKeyForAuth(createTestContext(), "px-api-key1")
+	assert.Equal(t, ErrAPIKeyExpired, err)
+	assert.Nil(t, key)
+
+	resp, err := svc.Get(createTestContext(), &authpb.GetAPIKeyRequest{ID: utils.ProtoFromUUID(testKey1ID)})
+	require.NoError(t, err)
+	assert.Nil(t, resp.Key.LastUsedAt)
+}
+
+func TestService_FetchAPIKeyForAuth_BadKey(t *testing.T) {
 	mustLoadTestData(db)
 	tests := []struct {
 		name string
@@ -478,11 +584,10 @@ func TestService_FetchOrgUserIDUsingAPIKey_BadKey(t *testing.T) {
 			ctx := test.ctx
 			svc := New(db, testDBKey)
 
-			orgID, userID, err := svc.FetchOrgUserIDUsingAPIKey(ctx, "some rando key that does not exist")
+			key, err := svc.FetchAPIKeyForAuth(ctx, "some rando key that does not exist")
 			assert.NotNil(t, err)
 			assert.Equal(t, ErrAPIKeyNotFound, err)
-			assert.Equal(t, uuid.Nil, orgID)
-			assert.Equal(t, uuid.Nil, userID)
+			assert.Nil(t, key)
 		})
 	}
 }
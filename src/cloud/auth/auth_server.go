@@ -33,6 +33,7 @@ import (
 	"px.dev/pixie/src/cloud/auth/authpb"
 	"px.dev/pixie/src/cloud/auth/controllers"
 	"px.dev/pixie/src/cloud/auth/schema"
+	"px.dev/pixie/src/cloud/shared/audit"
 	"px.dev/pixie/src/cloud/shared/pgmigrate"
 	"px.dev/pixie/src/shared/services"
 	"px.dev/pixie/src/shared/services/healthz"
@@ -42,7 +43,7 @@ import (
 
 func init() {
 	pflag.String("database_key", "", "The encryption key to use for the database")
-	pflag.String("oauth_provider", "auth0", "The auth provider to user. Currently support 'auth0' or 'hydra'")
+	pflag.String("oauth_provider", "auth0", "The auth provider to user. Currently support 'auth0', 'hydra' or 'oidc'")
 	pflag.String("domain_name", "dev.withpixie.dev", "The domain name of Pixie Cloud")
 }
 
@@ -53,6 +54,10 @@ func connectToPostgres() (*sqlx.DB, string) {
 	if err != nil {
 		log.WithError(err).Fatal("Failed to apply migrations")
 	}
+	err = audit.PerformMigrations(db)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to apply audit migrations")
+	}
 
 	dbKey := viper.GetString("database_key")
 	if dbKey == "" {
@@ -92,8 +97,13 @@ func main() {
 		if err != nil {
 			log.WithError(err).Fatal("Failed to initialize hydraKratosConnector")
 		}
+	case "oidc":
+		a, err = controllers.NewOIDCConnector()
+		if err != nil {
+			log.WithError(err).Fatal("Failed to initialize OIDCConnector")
+		}
 	default:
-		log.Fatalf("Cannot initialize authProvider '%s'. Only 'auth0' and 'hydra' are supported.", authProvider)
+		log.Fatalf("Cannot initialize authProvider '%s'. Only 'auth0', 'hydra' and 'oidc' are supported.", authProvider)
 	}
 
 	env, err := authenv.NewWithDefaults()
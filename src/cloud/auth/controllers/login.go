@@ -27,6 +27,7 @@ import (
 
 	"github.com/gofrs/uuid"
 	"github.com/gogo/protobuf/types"
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
@@ -111,6 +112,10 @@ func (s *Server) Login(ctx context.Context, in *authpb.LoginRequest) (*authpb.Lo
 	}
 
 	user, err := s.getUser(ctx, userInfo)
+	if err != nil || user == nil {
+		// The user might have been provisioned before their first login.
+		user, err = s.claimProvisionedUser(ctx, userInfo)
+	}
 	// If we can't find the user and aren't in auto create mode.
 	if (err != nil || user == nil) && !in.CreateUserIfNotExists {
 		return nil, status.Error(codes.NotFound, "user not found, please register.")
@@ -181,6 +186,12 @@ func (s *Server) loginSimple(ctx context.Context, userInfo *UserInfo, user *prof
 		if err != nil {
 			return nil, status.Errorf(codes.Internal, "%v", err)
 		}
+		if isManagedOrg(userInfo, orgInfo) {
+			if err := s.removeUserFromManagedOrg(ctx, user); err != nil {
+				return nil, err
+			}
+			orgInfo = nil
+		}
 	}
 
 	// We've switched over to use HostedDomain instead of email domain to determine org membership.
@@ -276,8 +287,14 @@ func (s *Server) completeUserLogin(ctx context.Context, userInfo *UserInfo, orgI
 		return nil, err
 	}
 
+	orgRole := user.OrgRole
+	if orgInfo != nil && userInfo.OrgRole != "" && userInfo.OrgRole != user.OrgRole {
+		orgRole = s.syncOrgRole(ctx, user, userInfo.OrgRole)
+	}
+
 	expiresAt := time.Now().Add(RefreshTokenValidDuration)
 	claims := srvutils.GenerateJWTForUser(utils.ProtoToUUIDStr(user.ID), orgID, userInfo.Email, expiresAt, viper.GetString("domain_name"))
+	claims.GetUserClaims().OrgRole = orgRole
 	tkn, err := srvutils.SignJWTClaims(claims, s.env.JWTSigningKey())
 	if err != nil {
 		return nil, status.Error(codes.Internal, "failed to generate token")
@@ -289,6 +306,51 @@ func (s *Server) completeUserLogin(ctx context.Context, userInfo *UserInfo, orgI
 	}, nil
 }
 
+// syncOrgRole gives the user the org role from their IdentityProvider, and returns the role that
+// the user ends up with. Failing to change the role, such as when the user is the last owner of the
+// org, keeps the current role rather than failing the login.
+func (s *Server) syncOrgRole(ctx context.Context, user *profilepb.UserInfo, role string) string {
+	_, err := s.env.ProfileClient().UpdateUser(ctx, &profilepb.UpdateUserRequest{
+		ID:      user.ID,
+		OrgRole: &types.StringValue{Value: role},
+	})
+	if err != nil {
+		log.WithError(err).WithField("user_id", utils.ProtoToUUIDStr(user.ID)).Warn("Failed to update org role from identity provider")
+		return user.OrgRole
+	}
+	return role
+}
+
+// isManagedOrg checks whether the membership of the org is managed by the IdentityProvider.
+func isManagedOrg(userInfo *UserInfo, orgInfo *profilepb.OrgInfo) bool {
+	if orgInfo.DomainName == nil {
+		return false
+	}
+	for _, org := range userInfo.ManagedOrgs {
+		if org == orgInfo.DomainName.Value {
+			return true
+		}
+	}
+	return false
+}
+
+// removeUserFromManagedOrg removes the user from their org, since they are no longer in it according
+// to the IdentityProvider. The login fails if the user can't be removed, such as when they are the
+// last owner of the org.
+func (s *Server) removeUserFromManagedOrg(ctx context.Context, user *profilepb.UserInfo) error {
+	_, err := s.env.ProfileClient().UpdateUser(ctx, &profilepb.UpdateUserRequest{
+		ID:    user.ID,
+		OrgID: &uuidpb.UUID{},
+	})
+	if err != nil {
+		log.WithError(err).WithField("user_id", utils.ProtoToUUIDStr(user.ID)).Warn("Failed to remove user from org managed by identity provider")
+		return status.Error(codes.PermissionDenied, "you are no longer a member of your org according to your identity provider")
+	}
+	user.OrgID = nil
+	user.OrgRole = ""
+	return nil
+}
+
 func (s *Server) signupUser(ctx context.Context, userInfo *UserInfo, orgInfo *profilepb.OrgInfo, newOrg bool) (*authpb.SignupReply, error) {
 	tkn, err := s.completeUserLogin(ctx, userInfo, orgInfo)
 	if err != nil {
@@ -381,6 +443,22 @@ func (s *Server) Signup(ctx context.Context, in *authpb.SignupRequest) (*authpb.
 		return nil, status.Error(codes.PermissionDenied, "user already exists, please login.")
 	}
 
+	// Case 0: User was provisioned in an org before their first login.
+	provisionedUser, err := s.claimProvisionedUser(ctx, userInfo)
+	if err != nil {
+		return nil, err
+	}
+	if provisionedUser != nil {
+		var orgInfoPb *profilepb.OrgInfo
+		if !utils.IsNilUUIDProto(provisionedUser.OrgID) {
+			orgInfoPb, err = s.env.OrgClient().GetOrg(ctx, provisionedUser.OrgID)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return s.signupUser(ctx, userInfo, orgInfoPb, false /* newOrg */)
+	}
+
 	// Case 1: User was invited to join an organization.
 	if !utils.IsNilUUIDProto(inviteOrgID) {
 		orgInfoPb, err := s.env.OrgClient().GetOrg(ctx, inviteOrgID)
@@ -487,10 +565,12 @@ func (s *Server) createUser(ctx context.Context, userInfo *UserInfo, orgID *uuid
 // GetAugmentedTokenForAPIKey produces an augmented token for the user given a API key.
 func (s *Server) GetAugmentedTokenForAPIKey(ctx context.Context, in *authpb.GetAugmentedTokenForAPIKeyRequest) (*authpb.GetAugmentedTokenForAPIKeyResponse, error) {
 	// Find the org/user associated with the token.
-	orgID, userID, err := s.apiKeyMgr.FetchOrgUserIDUsingAPIKey(ctx, in.APIKey)
+	key, err := s.apiKeyMgr.FetchAPIKeyForAuth(ctx, in.APIKey)
 	if err != nil {
 		return nil, status.Errorf(codes.Unauthenticated, "Invalid API key")
 	}
+	orgID := utils.UUIDFromProtoOrNil(key.OrgID)
+	userID := utils.UUIDFromProtoOrNil(key.UserID)
 
 	// Generate service token, so that we can make a call to the Profile service.
 	svcJWT := srvutils.GenerateJWTForService("AuthService", viper.GetString("domain_name"))
@@ -507,8 +587,27 @@ func (s *Server) GetAugmentedTokenForAPIKey(ctx context.Context, in *authpb.GetA
 		return nil, status.Errorf(codes.Internal, "Failed to generate auth token")
 	}
 
-	// Create JWT for user/org.
-	claims := srvutils.GenerateJWTForAPIUser(userID.String(), orgID.String(), time.Now().Add(AugmentedTokenValidDuration), viper.GetString("domain_name"))
+	// API keys act with the current org role of the user who created them.
+	user, err := s.env.ProfileClient().GetUser(ctxWithSvcCreds, utils.ProtoFromUUID(userID))
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to generate auth token")
+	}
+
+	// Create JWT for user/org. The token carries the restrictions of the key, and does not outlive it.
+	expiresAt := time.Now().Add(AugmentedTokenValidDuration)
+	if key.ExpiresAt != nil {
+		keyExpiresAt, err := types.TimestampFromProto(key.ExpiresAt)
+		if err == nil && keyExpiresAt.Before(expiresAt) {
+			expiresAt = keyExpiresAt
+		}
+	}
+	claims := srvutils.GenerateJWTForAPIUser(userID.String(), orgID.String(), expiresAt, viper.GetString("domain_name"))
+	claims.GetUserClaims().APIKeyScopes = key.Scopes
+	claims.GetUserClaims().OrgRole = user.OrgRole
+	claims.GetUserClaims().APIKeyID = utils.UUIDFromProtoOrNil(key.ID).String()
+	for _, id := range key.ClusterIDs {
+		claims.GetUserClaims().AllowedClusterIDs = append(claims.GetUserClaims().AllowedClusterIDs, utils.UUIDFromProtoOrNil(id).String())
+	}
 	token, err := srvutils.SignJWTClaims(claims, s.env.JWTSigningKey())
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "Failed to generate auth token")
@@ -537,6 +636,7 @@ func (s *Server) GetAugmentedToken(
 	}
 
 	// We perform extra checks for user tokens.
+	orgRole := ""
 	if srvutils.GetClaimsType(aCtx.Claims) == srvutils.UserClaimType {
 		// Check to make sure that the org and user exist in the system.
 		pc := s.env.ProfileClient()
@@ -564,6 +664,7 @@ func (s *Server) GetAugmentedToken(
 			if uuid.FromStringOrNil(orgIDstr) != utils.UUIDFromProtoOrNil(userInfo.OrgID) {
 				return nil, status.Error(codes.Unauthenticated, "Mismatched org")
 			}
+			orgRole = userInfo.OrgRole
 		}
 	}
 
@@ -571,6 +672,10 @@ func (s *Server) GetAugmentedToken(
 	claims := *aCtx.Claims
 	claims.IssuedAt = time.Now().Unix()
 	claims.ExpiresAt = time.Now().Add(AugmentedTokenValidDuration).Unix()
+	// Role changes take effect as soon as the token is augmented again, rather than at the next login.
+	if orgRole != "" {
+		claims.GetUserClaims().OrgRole = orgRole
+	}
 
 	augmentedToken, err := srvutils.SignJWTClaims(&claims, s.env.JWTSigningKey())
 	if err != nil {
@@ -714,6 +819,7 @@ func (s *Server) GetAuthConnectorToken(ctx context.Context, req *authpb.GetAuthC
 	now := time.Now()
 	expiresAt := now.Add(AuthConnectorTokenValidDuration)
 	claims := srvutils.GenerateJWTForUser(utils.UUIDFromProtoOrNil(userInfo.ID).String(), utils.UUIDFromProtoOrNil(userInfo.OrgID).String(), userInfo.Email, expiresAt, viper.GetString("domain_name"))
+	claims.GetUserClaims().OrgRole = userInfo.OrgRole
 	token, err := srvutils.ProtoToToken(claims)
 	if err != nil {
 		return nil, fmt.Errorf("unable to create authConnector token")
@@ -771,6 +877,7 @@ func (s *Server) RefetchToken(ctx context.Context, in *authpb.RefetchTokenReques
 		expiresAt,
 		viper.GetString("domain_name"),
 	)
+	claims.GetUserClaims().OrgRole = user.OrgRole
 	tkn, err := srvutils.SignJWTClaims(claims, s.env.JWTSigningKey())
 	if err != nil {
 		return nil, status.Error(codes.Internal, "failed to generate token")
@@ -33,6 +33,7 @@ import (
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
+	"px.dev/pixie/src/api/proto/uuidpb"
 	"px.dev/pixie/src/cloud/auth/authenv"
 	"px.dev/pixie/src/cloud/auth/authpb"
 	"px.dev/pixie/src/cloud/auth/controllers"
@@ -55,6 +56,13 @@ func getTestContext() context.Context {
 	return authcontext.NewContext(context.Background(), authcontext.New())
 }
 
+// expectNoProvisionedUser sets up the lookup of a user who was provisioned before their first login.
+func expectNoProvisionedUser(mockProfile *mock_profile.MockProfileServiceClient, email string) {
+	mockProfile.EXPECT().
+		GetUserByEmail(gomock.Any(), &profilepb.GetUserByEmailRequest{Email: email}).
+		Return(nil, status.Error(codes.NotFound, "no such user"))
+}
+
 func TestServer_LoginNewUser_NoOrg(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -79,6 +87,7 @@ func TestServer_LoginNewUser_NoOrg(t *testing.T) {
 	a.EXPECT().GetUserInfoFromAccessToken("tokenabc").Return(fakeUserInfo, nil)
 
 	mockProfile := mock_profile.NewMockProfileServiceClient(ctrl)
+	expectNoProvisionedUser(mockProfile, "abc@gmail.com")
 	mockOrg := mock_profile.NewMockOrgServiceClient(ctrl)
 
 	mockProfile.EXPECT().
@@ -166,6 +175,7 @@ func TestServer_LoginNewUser_ExistingOrgWithHostedDomain(t *testing.T) {
 	a.EXPECT().GetUserInfoFromAccessToken("tokenabc").Return(fakeUserInfo, nil)
 
 	mockProfile := mock_profile.NewMockProfileServiceClient(ctrl)
+	expectNoProvisionedUser(mockProfile, "abc@test.com")
 	mockOrg := mock_profile.NewMockOrgServiceClient(ctrl)
 
 	mockProfile.EXPECT().
@@ -526,6 +536,7 @@ func TestServer_LoginNewUser_NoAutoCreate(t *testing.T) {
 	a.EXPECT().GetUserInfoFromAccessToken("tokenabc").Return(fakeUserInfo, nil)
 
 	mockProfile := mock_profile.NewMockProfileServiceClient(ctrl)
+	expectNoProvisionedUser(mockProfile, "abc@gmail.com")
 	mockProfile.EXPECT().
 		GetUserByAuthProviderID(gomock.Any(), &profilepb.GetUserByAuthProviderIDRequest{
 			AuthProviderID: authProviderID,
@@ -571,6 +582,7 @@ func TestServer_LoginNewUser_OrgLookupThrowsError(t *testing.T) {
 	a.EXPECT().GetUserInfoFromAccessToken("tokenabc").Return(fakeUserInfo, nil)
 
 	mockProfile := mock_profile.NewMockProfileServiceClient(ctrl)
+	expectNoProvisionedUser(mockProfile, "abc@test.com")
 	mockOrg := mock_profile.NewMockOrgServiceClient(ctrl)
 
 	mockProfile.EXPECT().
@@ -625,6 +637,7 @@ func TestServer_LoginNewUser_CreateUserFailed(t *testing.T) {
 	a.EXPECT().GetUserInfoFromAccessToken("tokenabc").Return(fakeUserInfo, nil)
 
 	mockProfile := mock_profile.NewMockProfileServiceClient(ctrl)
+	expectNoProvisionedUser(mockProfile, "abc@test.com")
 	mockOrg := mock_profile.NewMockOrgServiceClient(ctrl)
 
 	mockProfile.EXPECT().
@@ -767,8 +780,9 @@ func TestServer_GetAugmentedToken(t *testing.T) {
 	mockProfile := mock_profile.NewMockProfileServiceClient(ctrl)
 	mockOrg := mock_profile.NewMockOrgServiceClient(ctrl)
 	mockUserInfo := &profilepb.UserInfo{
-		ID:    utils.ProtoFromUUIDStrOrNil(testingutils.TestUserID),
-		OrgID: utils.ProtoFromUUIDStrOrNil(testingutils.TestOrgID),
+		ID:      utils.ProtoFromUUIDStrOrNil(testingutils.TestUserID),
+		OrgID:   utils.ProtoFromUUIDStrOrNil(testingutils.TestOrgID),
+		OrgRole: srvutils.OrgRoleViewer,
 	}
 	mockOrgInfo := &profilepb.OrgInfo{
 		ID: utils.ProtoFromUUIDStrOrNil(testingutils.TestOrgID),
@@ -806,6 +820,11 @@ func TestServer_GetAugmentedToken(t *testing.T) {
 	assert.True(t, resp.ExpiresAt > 0)
 
 	verifyToken(t, resp.Token, testingutils.TestUserID, testingutils.TestOrgID, resp.ExpiresAt, "jwtkey")
+
+	// The augmented token picks up the current role of the user.
+	parsed, err := srvutils.ParseToken(resp.Token, "jwtkey", "withpixie.ai")
+	require.NoError(t, err)
+	assert.Equal(t, srvutils.OrgRoleViewer, srvutils.GetOrgRole(parsed))
 }
 
 func TestServer_GetAugmentedToken_Service(t *testing.T) {
@@ -1116,7 +1135,11 @@ func TestServer_GetAugmentedTokenFromAPIKey(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	a := mock_controllers.NewMockAuthProvider(ctrl)
 	apiKeyServer := mock_controllers.NewMockAPIKeyMgr(ctrl)
-	apiKeyServer.EXPECT().FetchOrgUserIDUsingAPIKey(gomock.Any(), "test_api").Return(uuid.FromStringOrNil(testingutils.TestOrgID), uuid.FromStringOrNil(testingutils.TestUserID), nil)
+	apiKeyServer.EXPECT().FetchAPIKeyForAuth(gomock.Any(), "test_api").Return(&authpb.APIKey{
+		ID:     utils.ProtoFromUUIDStrOrNil("7ba7b810-9dad-11d1-80b4-00c04fd430c8"),
+		OrgID:  utils.ProtoFromUUIDStrOrNil(testingutils.TestOrgID),
+		UserID: utils.ProtoFromUUIDStrOrNil(testingutils.TestUserID),
+	}, nil)
 
 	mockProfile := mock_profile.NewMockProfileServiceClient(ctrl)
 	mockOrg := mock_profile.NewMockOrgServiceClient(ctrl)
@@ -1126,6 +1149,13 @@ func TestServer_GetAugmentedTokenFromAPIKey(t *testing.T) {
 	mockOrg.EXPECT().
 		GetOrg(gomock.Any(), utils.ProtoFromUUIDStrOrNil(testingutils.TestOrgID)).
 		Return(mockOrgInfo, nil)
+	mockProfile.EXPECT().
+		GetUser(gomock.Any(), utils.ProtoFromUUIDStrOrNil(testingutils.TestUserID)).
+		Return(&profilepb.UserInfo{
+			ID:      utils.ProtoFromUUIDStrOrNil(testingutils.TestUserID),
+			OrgID:   utils.ProtoFromUUIDStrOrNil(testingutils.TestOrgID),
+			OrgRole: srvutils.OrgRoleAdmin,
+		}, nil)
 
 	viper.Set("jwt_signing_key", "jwtkey")
 	viper.Set("domain_name", "withpixie.ai")
@@ -1155,6 +1185,55 @@ func TestServer_GetAugmentedTokenFromAPIKey(t *testing.T) {
 	assert.Equal(t, testingutils.TestOrgID, srvutils.GetOrgID(parsed))
 	assert.Equal(t, resp.ExpiresAt, parsed.Expiration().Unix())
 	assert.True(t, srvutils.GetIsAPIUser(parsed))
+	// The key acts with the role of the user who created it.
+	assert.Equal(t, srvutils.OrgRoleAdmin, srvutils.GetOrgRole(parsed))
+	assert.Equal(t, "7ba7b810-9dad-11d1-80b4-00c04fd430c8", srvutils.GetAPIKeyID(parsed))
+}
+
+func TestServer_GetAugmentedTokenFromAPIKey_Scoped(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	a := mock_controllers.NewMockAuthProvider(ctrl)
+	apiKeyServer := mock_controllers.NewMockAPIKeyMgr(ctrl)
+	clusterID := uuid.Must(uuid.NewV4())
+	keyExpiresAt := time.Now().Add(10 * time.Minute)
+	keyExpiresAtProto, _ := types.TimestampProto(keyExpiresAt)
+	apiKeyServer.EXPECT().FetchAPIKeyForAuth(gomock.Any(), "test_api").Return(&authpb.APIKey{
+		OrgID:      utils.ProtoFromUUIDStrOrNil(testingutils.TestOrgID),
+		UserID:     utils.ProtoFromUUIDStrOrNil(testingutils.TestUserID),
+		Scopes:     []string{srvutils.APIKeyScopeScriptExecute},
+		ExpiresAt:  keyExpiresAtProto,
+		ClusterIDs: []*uuidpb.UUID{utils.ProtoFromUUID(clusterID)},
+	}, nil)
+
+	mockProfile := mock_profile.NewMockProfileServiceClient(ctrl)
+	mockOrg := mock_profile.NewMockOrgServiceClient(ctrl)
+	mockOrg.EXPECT().
+		GetOrg(gomock.Any(), utils.ProtoFromUUIDStrOrNil(testingutils.TestOrgID)).
+		Return(&profilepb.OrgInfo{ID: utils.ProtoFromUUIDStrOrNil(testingutils.TestOrgID)}, nil)
+	mockProfile.EXPECT().
+		GetUser(gomock.Any(), utils.ProtoFromUUIDStrOrNil(testingutils.TestUserID)).
+		Return(&profilepb.UserInfo{ID: utils.ProtoFromUUIDStrOrNil(testingutils.TestUserID), OrgRole: srvutils.OrgRoleMember}, nil)
+
+	viper.Set("jwt_signing_key", "jwtkey")
+	viper.Set("domain_name", "withpixie.ai")
+
+	env, err := authenv.New(mockProfile, mockOrg)
+	require.NoError(t, err)
+	s, err := controllers.NewServer(env, a, apiKeyServer)
+	require.NoError(t, err)
+
+	resp, err := s.GetAugmentedTokenForAPIKey(context.Background(), &authpb.GetAugmentedTokenForAPIKeyRequest{
+		APIKey: "test_api",
+	})
+	require.NoError(t, err)
+
+	// The token does not outlive the key.
+	assert.Equal(t, keyExpiresAt.Unix(), resp.ExpiresAt)
+
+	parsed, err := srvutils.ParseToken(resp.Token, "jwtkey", "withpixie.ai")
+	require.NoError(t, err)
+	assert.Equal(t, []string{srvutils.APIKeyScopeScriptExecute}, srvutils.GetAPIKeyScopes(parsed))
+	assert.Equal(t, []string{clusterID.String()}, srvutils.GetAllowedClusterIDs(parsed))
 }
 
 func TestServer_Signup_LookupHostedDomain(t *testing.T) {
@@ -1184,6 +1263,7 @@ func TestServer_Signup_LookupHostedDomain(t *testing.T) {
 	a.EXPECT().GetUserInfoFromAccessToken("tokenabc").Return(fakeUserInfo, nil)
 
 	mockProfile := mock_profile.NewMockProfileServiceClient(ctrl)
+	expectNoProvisionedUser(mockProfile, "abc@abcorg.com")
 	mockOrg := mock_profile.NewMockOrgServiceClient(ctrl)
 
 	mockProfile.EXPECT().
@@ -1271,6 +1351,7 @@ func TestServer_Signup_FallbackToEmailDomainLookupAfterHostedDomain(t *testing.T
 	a.EXPECT().GetUserInfoFromAccessToken("tokenabc").Return(fakeUserInfo, nil)
 
 	mockProfile := mock_profile.NewMockProfileServiceClient(ctrl)
+	expectNoProvisionedUser(mockProfile, "abc@abcorg.com")
 	mockOrg := mock_profile.NewMockOrgServiceClient(ctrl)
 
 	mockProfile.EXPECT().
@@ -1363,6 +1444,7 @@ func TestServer_Signup_ExistingOrgWithHostedDomain(t *testing.T) {
 	a.EXPECT().GetUserInfoFromAccessToken("tokenabc").Return(fakeUserInfo, nil)
 
 	mockProfile := mock_profile.NewMockProfileServiceClient(ctrl)
+	expectNoProvisionedUser(mockProfile, "asdf@asdf.com")
 	mockOrg := mock_profile.NewMockOrgServiceClient(ctrl)
 
 	mockProfile.EXPECT().
@@ -1446,6 +1528,7 @@ func TestServer_Signup_DoNotCreateOrgForEmptyHostedDomain(t *testing.T) {
 	a.EXPECT().GetUserInfoFromAccessToken("tokenabc").Return(fakeUserInfo, nil)
 
 	mockProfile := mock_profile.NewMockProfileServiceClient(ctrl)
+	expectNoProvisionedUser(mockProfile, "abc@gmail.com")
 	mockOrg := mock_profile.NewMockOrgServiceClient(ctrl)
 
 	mockProfile.EXPECT().
@@ -1516,6 +1599,7 @@ func TestServer_Signup_CreateUserOrgFailed(t *testing.T) {
 	a.EXPECT().GetUserInfoFromAccessToken("tokenabc").Return(fakeUserInfo, nil)
 
 	mockProfile := mock_profile.NewMockProfileServiceClient(ctrl)
+	expectNoProvisionedUser(mockProfile, "abc@gmail.com")
 	mockOrg := mock_profile.NewMockOrgServiceClient(ctrl)
 
 	mockProfile.EXPECT().
@@ -1623,6 +1707,7 @@ func TestServer_Signup_UserNotApproved(t *testing.T) {
 	a.EXPECT().GetUserInfoFromAccessToken("tokenabc").Return(fakeUserInfo, nil)
 
 	mockProfile := mock_profile.NewMockProfileServiceClient(ctrl)
+	expectNoProvisionedUser(mockProfile, "asdf@asdf.com")
 	mockOrg := mock_profile.NewMockOrgServiceClient(ctrl)
 
 	mockProfile.EXPECT().
@@ -2092,6 +2177,7 @@ func TestServer_Signup_WithInviteToken(t *testing.T) {
 	a.EXPECT().GetUserInfoFromAccessToken("tokenabc").Return(fakeUserInfo, nil)
 
 	mockProfile := mock_profile.NewMockProfileServiceClient(ctrl)
+	expectNoProvisionedUser(mockProfile, "abc@abcorg.com")
 	mockOrg := mock_profile.NewMockOrgServiceClient(ctrl)
 
 	mockProfile.EXPECT().
@@ -2193,6 +2279,7 @@ func TestServer_Login_WithInviteToken(t *testing.T) {
 	a.EXPECT().GetUserInfoFromAccessToken("tokenabc").Return(fakeUserInfo, nil)
 
 	mockProfile := mock_profile.NewMockProfileServiceClient(ctrl)
+	expectNoProvisionedUser(mockProfile, "abc@gmail.com")
 	mockOrg := mock_profile.NewMockOrgServiceClient(ctrl)
 
 	fakeOrgInfo := &profilepb.OrgInfo{
@@ -2754,3 +2841,159 @@ func TestServer_Login_ExistingOrglessUserWithInviteToken(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, orgID, resp.OrgInfo.OrgID)
 }
+
+func TestServer_Login_OrgRoleFromIdentityProvider(t *testing.T) {
+	tests := []struct {
+		name         string
+		currentRole  string
+		idpRole      string
+		updateErr    error
+		expectedRole string
+	}{
+		{
+			name:         "role is updated",
+			currentRole:  srvutils.OrgRoleMember,
+			idpRole:      srvutils.OrgRoleAdmin,
+			expectedRole: srvutils.OrgRoleAdmin,
+		},
+		{
+			name:         "user removed from group is demoted",
+			currentRole:  srvutils.OrgRoleAdmin,
+			idpRole:      srvutils.OrgRoleMember,
+			expectedRole: srvutils.OrgRoleMember,
+		},
+		{
+			name:         "last owner keeps their role",
+			currentRole:  srvutils.OrgRoleOwner,
+			idpRole:      srvutils.OrgRoleAdmin,
+			updateErr:    status.Error(codes.FailedPrecondition, "an org must have at least one owner"),
+			expectedRole: srvutils.OrgRoleOwner,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			orgID := "6ba7b810-9dad-11d1-80b4-00c04fd430c8"
+			orgPb := utils.ProtoFromUUIDStrOrNil(orgID)
+			userID := "7ba7b810-9dad-11d1-80b4-00c04fd430c8"
+			userPb := utils.ProtoFromUUIDStrOrNil(userID)
+			authProviderID := "oidc|abc123"
+
+			a := mock_controllers.NewMockAuthProvider(ctrl)
+			a.EXPECT().GetUserInfoFromAccessToken("tokenabc").Return(&controllers.UserInfo{
+				Email:            "abc@test.com",
+				EmailVerified:    true,
+				Picture:          "something",
+				AuthProviderID:   authProviderID,
+				IdentityProvider: "oidc",
+				HostedDomain:     "example.com",
+				OrgRole:          test.idpRole,
+			}, nil)
+
+			mockProfile := mock_profile.NewMockProfileServiceClient(ctrl)
+			mockOrg := mock_profile.NewMockOrgServiceClient(ctrl)
+
+			mockProfile.EXPECT().
+				GetUserByAuthProviderID(gomock.Any(), &profilepb.GetUserByAuthProviderIDRequest{AuthProviderID: authProviderID}).
+				Times(2).
+				Return(&profilepb.UserInfo{ID: userPb, OrgID: orgPb, OrgRole: test.currentRole}, nil)
+			mockOrg.EXPECT().
+				GetOrgByDomain(gomock.Any(), &profilepb.GetOrgByDomainRequest{DomainName: "example.com"}).
+				Return(&profilepb.OrgInfo{ID: orgPb, OrgName: "example.com"}, nil)
+			mockOrg.EXPECT().
+				UpdateOrg(gomock.Any(), &profilepb.UpdateOrgRequest{
+					ID:         orgPb,
+					DomainName: &types.StringValue{Value: "example.com"},
+				}).
+				Return(nil, nil)
+			mockProfile.EXPECT().
+				UpdateUser(gomock.Any(), &profilepb.UpdateUserRequest{
+					ID:             userPb,
+					DisplayPicture: &types.StringValue{Value: "something"},
+				}).
+				Return(nil, nil)
+			mockProfile.EXPECT().
+				UpdateUser(gomock.Any(), &profilepb.UpdateUserRequest{
+					ID:      userPb,
+					OrgRole: &types.StringValue{Value: test.idpRole},
+				}).
+				Return(nil, test.updateErr)
+
+			viper.Set("jwt_signing_key", "jwtkey")
+			viper.Set("domain_name", "withpixie.ai")
+
+			env, err := authenv.New(mockProfile, mockOrg)
+			require.NoError(t, err)
+			s, err := controllers.NewServer(env, a, nil)
+			require.NoError(t, err)
+
+			resp, err := doLoginRequest(getTestContext(), t, s)
+			require.NoError(t, err)
+			parsed, err := srvutils.ParseToken(resp.Token, "jwtkey", "withpixie.ai")
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedRole, srvutils.GetOrgRole(parsed))
+		})
+	}
+}
+
+func TestServer_Login_RemovedFromManagedOrg(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	orgPb := utils.ProtoFromUUIDStrOrNil("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+	userPb := utils.ProtoFromUUIDStrOrNil("7ba7b810-9dad-11d1-80b4-00c04fd430c8")
+	authProviderID := "oidc|abc123"
+
+	// The user no longer matches any of the org rules, so they aren't in an org according to the
+	// identity provider.
+	a := mock_controllers.NewMockAuthProvider(ctrl)
+	a.EXPECT().GetUserInfoFromAccessToken("tokenabc").Return(&controllers.UserInfo{
+		Email:            "abc@example.com",
+		EmailVerified:    true,
+		Picture:          "something",
+		AuthProviderID:   authProviderID,
+		IdentityProvider: "oidc",
+		ManagedOrgs:      []string{"example.com"},
+	}, nil)
+
+	mockProfile := mock_profile.NewMockProfileServiceClient(ctrl)
+	mockOrg := mock_profile.NewMockOrgServiceClient(ctrl)
+
+	mockProfile.EXPECT().
+		GetUserByAuthProviderID(gomock.Any(), &profilepb.GetUserByAuthProviderIDRequest{AuthProviderID: authProviderID}).
+		Times(2).
+		Return(&profilepb.UserInfo{ID: userPb, OrgID: orgPb, OrgRole: srvutils.OrgRoleAdmin}, nil)
+	mockOrg.EXPECT().
+		GetOrg(gomock.Any(), orgPb).
+		Return(&profilepb.OrgInfo{ID: orgPb, OrgName: "example.com", DomainName: &types.StringValue{Value: "example.com"}}, nil)
+	mockProfile.EXPECT().
+		UpdateUser(gomock.Any(), &profilepb.UpdateUserRequest{
+			ID:    userPb,
+			OrgID: &uuidpb.UUID{},
+		}).
+		Return(nil, nil)
+	mockProfile.EXPECT().
+		UpdateUser(gomock.Any(), &profilepb.UpdateUserRequest{
+			ID:             userPb,
+			DisplayPicture: &types.StringValue{Value: "something"},
+		}).
+		Return(nil, nil)
+
+	viper.Set("jwt_signing_key", "jwtkey")
+	viper.Set("domain_name", "withpixie.ai")
+
+	env, err := authenv.New(mockProfile, mockOrg)
+	require.NoError(t, err)
+	s, err := controllers.NewServer(env, a, nil)
+	require.NoError(t, err)
+
+	resp, err := doLoginRequest(getTestContext(), t, s)
+	require.NoError(t, err)
+	assert.Equal(t, "", resp.OrgInfo.OrgID)
+	parsed, err := srvutils.ParseToken(resp.Token, "jwtkey", "withpixie.ai")
+	require.NoError(t, err)
+	assert.Equal(t, "", srvutils.GetOrgRole(parsed))
+}
@@ -86,6 +86,9 @@ type OIDCConnector struct {
 	GoogleIdentityProvider string
 	GoogleAccessTokenClaim string
 
+	// OrgRules map the claims of users to orgs and roles.
+	OrgRules []*OIDCOrgRule
+
 	client *http.Client
 }
 
@@ -136,6 +139,14 @@ func NewOIDCConnector() (*OIDCConnector, error) {
 		return nil, errors.New("must set oidc_idprovider_claim and oidc_google_idprovider_value when setting oidc_google_access_token_claim")
 	}
 
+	var orgRules []*OIDCOrgRule
+	if path := viper.GetString("oidc_org_rules_file"); path != "" {
+		orgRules, err = LoadOIDCOrgRules(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &OIDCConnector{
 		Issuer:                 issuer,
 		ClientID:               clientID,
@@ -146,6 +157,7 @@ func NewOIDCConnector() (*OIDCConnector, error) {
 		IDProviderClaim:        idProviderClaim,
 		GoogleIdentityProvider: googleIDProvider,
 		GoogleAccessTokenClaim: googleAccessTokenClaim,
+		OrgRules:               orgRules,
 		client:                 &http.Client{},
 	}, nil
 }
@@ -206,6 +218,13 @@ func (c *OIDCConnector) GetUserInfoFromAccessToken(accessToken string) (*UserInf
 		return nil, err
 	}
 
+	// Orgs from the rules take precedence over Google hosted domains.
+	if org, role := matchOIDCOrgRules(c.OrgRules, parsed); org != "" {
+		userInfo.HostedDomain = org
+		userInfo.OrgRole = role
+	}
+	userInfo.ManagedOrgs = managedOIDCOrgs(c.OrgRules)
+
 	return userInfo, nil
 }
 
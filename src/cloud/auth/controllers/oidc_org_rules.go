@@ -0,0 +1,140 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package controllers
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v2"
+
+	srvutils "px.dev/pixie/src/shared/services/utils"
+)
+
+func init() {
+	// The rules file looks like:
+	//   rules:
+	//   - claim: groups
+	//     value: pixie-admins
+	//     org: example.com
+	//     role: admin
+	pflag.String("oidc_org_rules_file", "", "A YAML file with rules that map OIDC claims to orgs and org roles")
+}
+
+// OIDCOrgRule maps the users whose claim has the given value to an org, and optionally to a role in
+// that org.
+type OIDCOrgRule struct {
+	// Claim is the name of the claim to match, such as "groups". The claim may either be a string or
+	// a list of strings.
+	Claim string `yaml:"claim"`
+	// Value is the value that the claim must equal, or contain.
+	Value string `yaml:"value"`
+	// Org is the domain name of the org. It is used in place of a Google Workspace hosted domain, so
+	// the org is joined, or created on signup, in the same way.
+	Org string `yaml:"org"`
+	// Role is the org role of matching users. If no rule for the org sets a role, the role is managed
+	// in Pixie. Otherwise, users who don't match any of the rules which set a role get the default
+	// org role.
+	Role string `yaml:"role"`
+}
+
+// defaultOIDCOrgRole is the role of users who don't match any of the rules which set a role for their
+// org. It is the same role that users get when they join an org.
+const defaultOIDCOrgRole = srvutils.OrgRoleMember
+
+type oidcOrgRulesConfig struct {
+	Rules []*OIDCOrgRule `yaml:"rules"`
+}
+
+// LoadOIDCOrgRules reads and validates the org rules in the given YAML file.
+func LoadOIDCOrgRules(path string) ([]*OIDCOrgRule, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config oidcOrgRulesConfig
+	if err := yaml.UnmarshalStrict(b, &config); err != nil {
+		return nil, fmt.Errorf("invalid OIDC org rules: %w", err)
+	}
+	for i, r := range config.Rules {
+		if r.Claim == "" || r.Value == "" || r.Org == "" {
+			return nil, fmt.Errorf("OIDC org rule %d must set claim, value and org", i)
+		}
+		if r.Role != "" && !srvutils.IsValidOrgRole(r.Role) {
+			return nil, fmt.Errorf("OIDC org rule %d has invalid role %q", i, r.Role)
+		}
+	}
+	return config.Rules, nil
+}
+
+func (r *OIDCOrgRule) matches(claims map[string]interface{}) bool {
+	switch v := claims[r.Claim].(type) {
+	case string:
+		return v == r.Value
+	case []interface{}:
+		for _, elem := range v {
+			if s, ok := elem.(string); ok && s == r.Value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchOIDCOrgRules finds the org and role for the given claims. The first matching rule picks the
+// org, and the user gets the highest role of the matching rules for that org. If none of the matching
+// rules set a role, but other rules for the org do, the user gets the default role, so that users who
+// are removed from an IdP group lose the role which the group gave them. The org is empty if no rule
+// matches.
+func matchOIDCOrgRules(rules []*OIDCOrgRule, claims map[string]interface{}) (string, string) {
+	org, role := "", ""
+	for _, r := range rules {
+		if !r.matches(claims) || (org != "" && r.Org != org) {
+			continue
+		}
+		org = r.Org
+		if r.Role != "" && (role == "" || srvutils.OrgRoleAtLeast(r.Role, role)) {
+			role = r.Role
+		}
+	}
+	if org == "" || role != "" {
+		return org, role
+	}
+	for _, r := range rules {
+		if r.Org == org && r.Role != "" {
+			return org, defaultOIDCOrgRole
+		}
+	}
+	return org, ""
+}
+
+// managedOIDCOrgs returns the orgs in the rules. Membership of these orgs is managed by the rules, so
+// users who no longer match any rule for their org are removed from it.
+func managedOIDCOrgs(rules []*OIDCOrgRule) []string {
+	var orgs []string
+	seen := make(map[string]bool)
+	for _, r := range rules {
+		if !seen[r.Org] {
+			seen[r.Org] = true
+			orgs = append(orgs, r.Org)
+		}
+	}
+	return orgs
+}
@@ -22,6 +22,8 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/spf13/viper"
@@ -294,3 +296,175 @@ func TestOIDCConnectorImpl_GetUserInfo_BadResponse(t *testing.T) {
 	assert.Equal(t, 1, callCount)
 	assert.EqualError(t, err, "bad response from OIDC userinfo")
 }
+
+func writeOIDCOrgRules(t *testing.T, rules string) string {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(rules), 0o600))
+	return path
+}
+
+func TestLoadOIDCOrgRules(t *testing.T) {
+	tests := []struct {
+		name      string
+		rules     string
+		expectErr bool
+	}{
+		{
+			name: "valid",
+			rules: `
+rules:
+- claim: groups
+  value: pixie-admins
+  org: example.com
+  role: admin
+- claim: department
+  value: engineering
+  org: example.com
+`,
+		},
+		{
+			name: "missing org",
+			rules: `
+rules:
+- claim: groups
+  value: pixie-admins
+`,
+			expectErr: true,
+		},
+		{
+			name: "invalid role",
+			rules: `
+rules:
+- claim: groups
+  value: pixie-admins
+  org: example.com
+  role: superuser
+`,
+			expectErr: true,
+		},
+		{
+			name: "unknown field",
+			rules: `
+rules:
+- claim: groups
+  values: pixie-admins
+  org: example.com
+`,
+			expectErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rules, err := controllers.LoadOIDCOrgRules(writeOIDCOrgRules(t, test.rules))
+			if test.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, rules, 2)
+		})
+	}
+}
+
+func TestOIDCConnectorImpl_GetUserInfo_OrgRules(t *testing.T) {
+	tests := []struct {
+		name         string
+		claims       string
+		expectedOrg  string
+		expectedRole string
+	}{
+		{
+			name:         "highest role of matching rules",
+			claims:       `"groups": ["developers", "pixie-admins"]`,
+			expectedOrg:  "example.com",
+			expectedRole: "admin",
+		},
+		{
+			// Other rules for the org set roles, so users who aren't in any of their groups, such as users
+			// who were removed from the pixie-admins group, are demoted to the default role.
+			name:         "string claim",
+			claims:       `"department": "support"`,
+			expectedOrg:  "example.com",
+			expectedRole: "member",
+		},
+		{
+			name:         "org without roles",
+			claims:       `"groups": ["partners"]`,
+			expectedOrg:  "partners.example.com",
+			expectedRole: "",
+		},
+		{
+			name:         "first org wins",
+			claims:       `"groups": ["contractors", "developers"]`,
+			expectedOrg:  "contractors.example.com",
+			expectedRole: "viewer",
+		},
+		{
+			name:         "no matching rule",
+			claims:       `"groups": ["sales"]`,
+			expectedOrg:  "",
+			expectedRole: "",
+		},
+	}
+
+	rules := writeOIDCOrgRules(t, `
+rules:
+- claim: groups
+  value: contractors
+  org: contractors.example.com
+  role: viewer
+- claim: groups
+  value: developers
+  org: example.com
+  role: member
+- claim: groups
+  value: pixie-admins
+  org: example.com
+  role: admin
+- claim: department
+  value: support
+  org: example.com
+- claim: groups
+  value: partners
+  org: partners.example.com
+`)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/oauth2/userinfo", r.URL.String())
+				_, err := w.Write([]byte(fmt.Sprintf(`{
+					"email": "testuser@example.com",
+					"email_verified": true,
+					"sub": "123990813094",
+					%s
+				}`, test.claims)))
+				require.NoError(t, err)
+			}))
+			defer server.Close()
+
+			cleanup := SetupOIDCViperEnvironment(t, server.URL)
+			defer cleanup()
+			viper.Set("oidc_org_rules_file", rules)
+
+			c, err := controllers.NewOIDCConnector()
+			require.NoError(t, err)
+
+			userInfo, err := c.GetUserInfoFromAccessToken("test_token")
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedOrg, userInfo.HostedDomain)
+			assert.Equal(t, test.expectedRole, userInfo.OrgRole)
+			assert.Equal(t, []string{"contractors.example.com", "example.com", "partners.example.com"}, userInfo.ManagedOrgs)
+		})
+	}
+}
+
+func TestOIDCConnectorImpl_Init_InvalidOrgRules(t *testing.T) {
+	cleanup := SetupOIDCViperEnvironment(t, "http://test_path")
+	defer cleanup()
+	viper.Set("oidc_org_rules_file", writeOIDCOrgRules(t, "rules: [{claim: groups}]"))
+
+	_, err := controllers.NewOIDCConnector()
+	assert.Error(t, err)
+}
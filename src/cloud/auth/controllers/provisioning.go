@@ -0,0 +1,186 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gogo/protobuf/types"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"px.dev/pixie/src/api/proto/uuidpb"
+	"px.dev/pixie/src/cloud/auth/authpb"
+	"px.dev/pixie/src/cloud/profile/profilepb"
+	"px.dev/pixie/src/shared/services/authcontext"
+	srvutils "px.dev/pixie/src/shared/services/utils"
+	"px.dev/pixie/src/utils"
+)
+
+const (
+	// provisionedIdentityProvider is the identity provider of users who were provisioned ahead of
+	// their first login.
+	provisionedIdentityProvider = "scim"
+	// provisionedAuthProviderIDPrefix prefixes the placeholder AuthProviderID of provisioned users.
+	// The placeholder is replaced by the user's real AuthProviderID when they first log in.
+	provisionedAuthProviderIDPrefix = "scim|"
+)
+
+// checkCanProvisionInOrg makes sure the caller is an admin of the given org.
+func checkCanProvisionInOrg(ctx context.Context, orgID *uuidpb.UUID) error {
+	sCtx, err := authcontext.FromContext(ctx)
+	if err != nil {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+	claims := sCtx.Claims
+	if claims.GetUserClaims() == nil || claims.GetUserClaims().OrgID != utils.ProtoToUUIDStr(orgID) {
+		return status.Error(codes.PermissionDenied, "user does not have permission to provision users in this org")
+	}
+	if !srvutils.HasOrgRole(claims, srvutils.OrgRoleAdmin) {
+		return status.Error(codes.PermissionDenied, "provisioning users requires the admin role")
+	}
+	return nil
+}
+
+// ProvisionUser creates a user in the org before they have logged in. Existing accounts are never
+// moved into the org, since their owners haven't agreed to join it, and must be invited instead. The
+// only exception is a provisioned user who was removed from their org before ever logging in, who
+// has no account of their own yet.
+func (s *Server) ProvisionUser(ctx context.Context, req *authpb.ProvisionUserRequest) (*authpb.ProvisionUserResponse, error) {
+	if utils.IsNilUUIDProto(req.OrgID) {
+		return nil, status.Error(codes.InvalidArgument, "org ID must be specified")
+	}
+	if req.Email == "" {
+		return nil, status.Error(codes.InvalidArgument, "email must not be empty")
+	}
+	if err := checkCanProvisionInOrg(ctx, req.OrgID); err != nil {
+		return nil, err
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = metadata.NewOutgoingContext(ctx, md)
+	pc := s.env.ProfileClient()
+
+	var userID *uuidpb.UUID
+	user, err := pc.GetUserByEmail(ctx, &profilepb.GetUserByEmailRequest{Email: req.Email})
+	switch {
+	case err == nil && !utils.IsNilUUIDProto(user.OrgID):
+		return nil, status.Error(codes.AlreadyExists, "user already belongs to an org")
+	case err == nil && !strings.HasPrefix(user.AuthProviderID, provisionedAuthProviderIDPrefix):
+		return nil, status.Error(codes.AlreadyExists, "user already has an account, and must be invited to the org")
+	case err == nil:
+		userID = user.ID
+		_, err = pc.UpdateUser(ctx, &profilepb.UpdateUserRequest{
+			ID:    userID,
+			OrgID: req.OrgID,
+		})
+		if err != nil {
+			return nil, err
+		}
+	case status.Code(err) == codes.NotFound:
+		userID, err = pc.CreateUser(ctx, &profilepb.CreateUserRequest{
+			OrgID:            req.OrgID,
+			FirstName:        req.FirstName,
+			LastName:         req.LastName,
+			Email:            req.Email,
+			IdentityProvider: provisionedIdentityProvider,
+			AuthProviderID:   provisionedAuthProviderIDPrefix + req.Email,
+		})
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	// The IdP decides who belongs to the org, so provisioned users don't need approval.
+	_, err = pc.UpdateUser(ctx, &profilepb.UpdateUserRequest{
+		ID:         userID,
+		IsApproved: &types.BoolValue{Value: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &authpb.ProvisionUserResponse{UserID: userID}, nil
+}
+
+// DeprovisionUser removes the user from their org and deletes their API keys. Removing the user from
+// the org also stops their existing sessions from being augmented.
+func (s *Server) DeprovisionUser(ctx context.Context, req *authpb.DeprovisionUserRequest) (*authpb.DeprovisionUserResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = metadata.NewOutgoingContext(ctx, md)
+	pc := s.env.ProfileClient()
+
+	user, err := pc.GetUser(ctx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if utils.IsNilUUIDProto(user.OrgID) {
+		return nil, status.Error(codes.NotFound, "user does not belong to an org")
+	}
+	if err := checkCanProvisionInOrg(ctx, user.OrgID); err != nil {
+		return nil, err
+	}
+
+	_, err = pc.UpdateUser(ctx, &profilepb.UpdateUserRequest{
+		ID:    user.ID,
+		OrgID: &uuidpb.UUID{},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.apiKeyMgr.DeleteAPIKeysForUser(ctx, utils.UUIDFromProtoOrNil(user.OrgID), utils.UUIDFromProtoOrNil(user.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	return &authpb.DeprovisionUserResponse{}, nil
+}
+
+// claimProvisionedUser binds a provisioned user with the same email to the identity that the user
+// logged in with. It returns nil if there is no such user.
+func (s *Server) claimProvisionedUser(ctx context.Context, userInfo *UserInfo) (*profilepb.UserInfo, error) {
+	if !userInfo.EmailVerified || userInfo.Email == "" || userInfo.AuthProviderID == "" {
+		return nil, nil
+	}
+
+	pc := s.env.ProfileClient()
+	user, err := pc.GetUserByEmail(ctx, &profilepb.GetUserByEmailRequest{Email: userInfo.Email})
+	if status.Code(err) == codes.NotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(user.AuthProviderID, provisionedAuthProviderIDPrefix) {
+		return nil, nil
+	}
+
+	log.WithField("user_id", utils.ProtoToUUIDStr(user.ID)).Info("Binding provisioned user to their identity")
+	return pc.UpdateUser(ctx, &profilepb.UpdateUserRequest{
+		ID:               user.ID,
+		IdentityProvider: &types.StringValue{Value: userInfo.IdentityProvider},
+		AuthProviderID:   &types.StringValue{Value: userInfo.AuthProviderID},
+	})
+}
@@ -0,0 +1,345 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package controllers_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/gogo/protobuf/types"
+	"github.com/golang/mock/gomock"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"px.dev/pixie/src/api/proto/uuidpb"
+	"px.dev/pixie/src/cloud/auth/authenv"
+	"px.dev/pixie/src/cloud/auth/authpb"
+	"px.dev/pixie/src/cloud/auth/controllers"
+	mock_controllers "px.dev/pixie/src/cloud/auth/controllers/mock"
+	"px.dev/pixie/src/cloud/profile/profilepb"
+	mock_profile "px.dev/pixie/src/cloud/profile/profilepb/mock"
+	"px.dev/pixie/src/shared/services/authcontext"
+	srvutils "px.dev/pixie/src/shared/services/utils"
+	"px.dev/pixie/src/utils"
+)
+
+const (
+	provisionOrgID  = "6ba7b810-9dad-11d1-80b4-00c04fd430c8"
+	provisionUserID = "7ba7b810-9dad-11d1-80b4-00c04fd430c8"
+)
+
+func createProvisionTestContext(orgID string, role string) context.Context {
+	sCtx := authcontext.New()
+	sCtx.Claims = srvutils.GenerateJWTForUser("8ba7b810-9dad-11d1-80b4-00c04fd430c8", orgID, "admin@test.com", time.Now().Add(time.Hour), "pixie")
+	sCtx.Claims.GetUserClaims().OrgRole = role
+	return authcontext.NewContext(context.Background(), sCtx)
+}
+
+func TestServer_ProvisionUser_NewUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	orgPb := utils.ProtoFromUUIDStrOrNil(provisionOrgID)
+	userPb := utils.ProtoFromUUIDStrOrNil(provisionUserID)
+
+	mockProfile := mock_profile.NewMockProfileServiceClient(ctrl)
+	mockOrg := mock_profile.NewMockOrgServiceClient(ctrl)
+
+	mockProfile.EXPECT().
+		GetUserByEmail(gomock.Any(), &profilepb.GetUserByEmailRequest{Email: "abc@test.com"}).
+		Return(nil, status.Error(codes.NotFound, "no such user"))
+	mockProfile.EXPECT().
+		CreateUser(gomock.Any(), &profilepb.CreateUserRequest{
+			OrgID:            orgPb,
+			FirstName:        "first",
+			LastName:         "last",
+			Email:            "abc@test.com",
+			IdentityProvider: "scim",
+			AuthProviderID:   "scim|abc@test.com",
+		}).
+		Return(userPb, nil)
+	mockProfile.EXPECT().
+		UpdateUser(gomock.Any(), &profilepb.UpdateUserRequest{
+			ID:         userPb,
+			IsApproved: &types.BoolValue{Value: true},
+		}).
+		Return(nil, nil)
+
+	env, err := authenv.New(mockProfile, mockOrg)
+	require.NoError(t, err)
+	s, err := controllers.NewServer(env, nil, nil)
+	require.NoError(t, err)
+
+	resp, err := s.ProvisionUser(createProvisionTestContext(provisionOrgID, srvutils.OrgRoleAdmin), &authpb.ProvisionUserRequest{
+		OrgID:     orgPb,
+		Email:     "abc@test.com",
+		FirstName: "first",
+		LastName:  "last",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, userPb, resp.UserID)
+}
+
+func TestServer_ProvisionUser_ExistingUser(t *testing.T) {
+	orgPb := utils.ProtoFromUUIDStrOrNil(provisionOrgID)
+	userPb := utils.ProtoFromUUIDStrOrNil(provisionUserID)
+
+	tests := []struct {
+		name           string
+		existingOrg    *uuidpb.UUID
+		authProviderID string
+		expectedCode   codes.Code
+	}{
+		{
+			name:           "provisioned user without org",
+			existingOrg:    nil,
+			authProviderID: "scim|abc@test.com",
+			expectedCode:   codes.OK,
+		},
+		{
+			name:           "user without org who has logged in",
+			existingOrg:    nil,
+			authProviderID: "google-oauth2|123",
+			expectedCode:   codes.AlreadyExists,
+		},
+		{
+			name:           "user in an org",
+			existingOrg:    utils.ProtoFromUUIDStrOrNil("9ba7b810-9dad-11d1-80b4-00c04fd430c8"),
+			authProviderID: "google-oauth2|123",
+			expectedCode:   codes.AlreadyExists,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockProfile := mock_profile.NewMockProfileServiceClient(ctrl)
+			mockOrg := mock_profile.NewMockOrgServiceClient(ctrl)
+
+			mockProfile.EXPECT().
+				GetUserByEmail(gomock.Any(), &profilepb.GetUserByEmailRequest{Email: "abc@test.com"}).
+				Return(&profilepb.UserInfo{ID: userPb, OrgID: test.existingOrg, AuthProviderID: test.authProviderID}, nil)
+			if test.expectedCode == codes.OK {
+				mockProfile.EXPECT().
+					UpdateUser(gomock.Any(), &profilepb.UpdateUserRequest{ID: userPb, OrgID: orgPb}).
+					Return(nil, nil)
+				mockProfile.EXPECT().
+					UpdateUser(gomock.Any(), &profilepb.UpdateUserRequest{
+						ID:         userPb,
+						IsApproved: &types.BoolValue{Value: true},
+					}).
+					Return(nil, nil)
+			}
+
+			env, err := authenv.New(mockProfile, mockOrg)
+			require.NoError(t, err)
+			s, err := controllers.NewServer(env, nil, nil)
+			require.NoError(t, err)
+
+			resp, err := s.ProvisionUser(createProvisionTestContext(provisionOrgID, srvutils.OrgRoleAdmin), &authpb.ProvisionUserRequest{
+				OrgID: orgPb,
+				Email: "abc@test.com",
+			})
+			assert.Equal(t, test.expectedCode, status.Code(err))
+			if test.expectedCode == codes.OK {
+				assert.Equal(t, userPb, resp.UserID)
+			}
+		})
+	}
+}
+
+func TestServer_ProvisionUser_PermissionDenied(t *testing.T) {
+	tests := []struct {
+		name  string
+		orgID string
+		role  string
+	}{
+		{
+			name:  "member",
+			orgID: provisionOrgID,
+			role:  srvutils.OrgRoleMember,
+		},
+		{
+			name:  "other org",
+			orgID: "9ba7b810-9dad-11d1-80b4-00c04fd430c8",
+			role:  srvutils.OrgRoleOwner,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := controllers.NewServer(nil, nil, nil)
+			require.NoError(t, err)
+
+			_, err = s.ProvisionUser(createProvisionTestContext(test.orgID, test.role), &authpb.ProvisionUserRequest{
+				OrgID: utils.ProtoFromUUIDStrOrNil(provisionOrgID),
+				Email: "abc@test.com",
+			})
+			assert.Equal(t, codes.PermissionDenied, status.Code(err))
+		})
+	}
+}
+
+func TestServer_DeprovisionUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	orgPb := utils.ProtoFromUUIDStrOrNil(provisionOrgID)
+	userPb := utils.ProtoFromUUIDStrOrNil(provisionUserID)
+
+	mockProfile := mock_profile.NewMockProfileServiceClient(ctrl)
+	mockOrg := mock_profile.NewMockOrgServiceClient(ctrl)
+	apiKeyMgr := mock_controllers.NewMockAPIKeyMgr(ctrl)
+
+	mockProfile.EXPECT().
+		GetUser(gomock.Any(), userPb).
+		Return(&profilepb.UserInfo{ID: userPb, OrgID: orgPb}, nil)
+	mockProfile.EXPECT().
+		UpdateUser(gomock.Any(), &profilepb.UpdateUserRequest{ID: userPb, OrgID: &uuidpb.UUID{}}).
+		Return(nil, nil)
+	apiKeyMgr.EXPECT().
+		DeleteAPIKeysForUser(gomock.Any(), uuid.FromStringOrNil(provisionOrgID), uuid.FromStringOrNil(provisionUserID)).
+		Return(nil)
+
+	env, err := authenv.New(mockProfile, mockOrg)
+	require.NoError(t, err)
+	s, err := controllers.NewServer(env, nil, apiKeyMgr)
+	require.NoError(t, err)
+
+	_, err = s.DeprovisionUser(createProvisionTestContext(provisionOrgID, srvutils.OrgRoleAdmin), &authpb.DeprovisionUserRequest{
+		UserID: userPb,
+	})
+	require.NoError(t, err)
+}
+
+func TestServer_DeprovisionUser_OtherOrg(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	userPb := utils.ProtoFromUUIDStrOrNil(provisionUserID)
+
+	mockProfile := mock_profile.NewMockProfileServiceClient(ctrl)
+	mockOrg := mock_profile.NewMockOrgServiceClient(ctrl)
+	apiKeyMgr := mock_controllers.NewMockAPIKeyMgr(ctrl)
+
+	mockProfile.EXPECT().
+		GetUser(gomock.Any(), userPb).
+		Return(&profilepb.UserInfo{ID: userPb, OrgID: utils.ProtoFromUUIDStrOrNil("9ba7b810-9dad-11d1-80b4-00c04fd430c8")}, nil)
+
+	env, err := authenv.New(mockProfile, mockOrg)
+	require.NoError(t, err)
+	s, err := controllers.NewServer(env, nil, apiKeyMgr)
+	require.NoError(t, err)
+
+	_, err = s.DeprovisionUser(createProvisionTestContext(provisionOrgID, srvutils.OrgRoleAdmin), &authpb.DeprovisionUserRequest{
+		UserID: userPb,
+	})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestServer_Login_ProvisionedUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	orgPb := utils.ProtoFromUUIDStrOrNil(provisionOrgID)
+	userPb := utils.ProtoFromUUIDStrOrNil(provisionUserID)
+	authProviderID := "github|abc123"
+
+	a := mock_controllers.NewMockAuthProvider(ctrl)
+	a.EXPECT().GetUserInfoFromAccessToken("tokenabc").Return(&controllers.UserInfo{
+		Email:            "abc@test.com",
+		EmailVerified:    true,
+		FirstName:        "first",
+		LastName:         "last",
+		Picture:          "something",
+		AuthProviderID:   authProviderID,
+		IdentityProvider: auth0IdentityProvider,
+	}, nil)
+
+	mockProfile := mock_profile.NewMockProfileServiceClient(ctrl)
+	mockOrg := mock_profile.NewMockOrgServiceClient(ctrl)
+
+	provisionedUser := &profilepb.UserInfo{
+		ID:             userPb,
+		OrgID:          orgPb,
+		Email:          "abc@test.com",
+		IsApproved:     true,
+		AuthProviderID: "scim|abc@test.com",
+		OrgRole:        srvutils.OrgRoleMember,
+	}
+	gomock.InOrder(
+		mockProfile.EXPECT().
+			GetUserByAuthProviderID(gomock.Any(), &profilepb.GetUserByAuthProviderIDRequest{AuthProviderID: authProviderID}).
+			Return(nil, status.Error(codes.NotFound, "no such user")),
+		mockProfile.EXPECT().
+			GetUserByEmail(gomock.Any(), &profilepb.GetUserByEmailRequest{Email: "abc@test.com"}).
+			Return(provisionedUser, nil),
+		mockProfile.EXPECT().
+			UpdateUser(gomock.Any(), &profilepb.UpdateUserRequest{
+				ID:               userPb,
+				IdentityProvider: &types.StringValue{Value: auth0IdentityProvider},
+				AuthProviderID:   &types.StringValue{Value: authProviderID},
+			}).
+			Return(provisionedUser, nil),
+		mockProfile.EXPECT().
+			GetUserByAuthProviderID(gomock.Any(), &profilepb.GetUserByAuthProviderIDRequest{AuthProviderID: authProviderID}).
+			Return(provisionedUser, nil),
+		mockProfile.EXPECT().
+			UpdateUser(gomock.Any(), &profilepb.UpdateUserRequest{
+				ID:             userPb,
+				DisplayPicture: &types.StringValue{Value: "something"},
+			}).
+			Return(nil, nil),
+	)
+
+	mockOrg.EXPECT().
+		GetOrg(gomock.Any(), orgPb).
+		Return(&profilepb.OrgInfo{
+			ID:         orgPb,
+			OrgName:    "test.com",
+			DomainName: &types.StringValue{Value: "test.com"},
+		}, nil)
+	mockOrg.EXPECT().
+		UpdateOrg(gomock.Any(), &profilepb.UpdateOrgRequest{
+			ID:         orgPb,
+			DomainName: &types.StringValue{Value: ""},
+		}).
+		Return(nil, nil)
+
+	viper.Set("jwt_signing_key", "jwtkey")
+	viper.Set("domain_name", "withpixie.ai")
+
+	env, err := authenv.New(mockProfile, mockOrg)
+	require.NoError(t, err)
+	s, err := controllers.NewServer(env, a, nil)
+	require.NoError(t, err)
+
+	resp, err := s.Login(getTestContext(), &authpb.LoginRequest{AccessToken: "tokenabc"})
+	require.NoError(t, err)
+	assert.False(t, resp.UserCreated)
+	assert.Equal(t, provisionOrgID, resp.OrgInfo.OrgID)
+	verifyToken(t, resp.Token, provisionUserID, provisionOrgID, resp.ExpiresAt, "jwtkey")
+}
@@ -24,11 +24,13 @@ import (
 	"github.com/gofrs/uuid"
 
 	"px.dev/pixie/src/cloud/auth/authenv"
+	"px.dev/pixie/src/cloud/auth/authpb"
 )
 
 // APIKeyMgr is the internal interface for managing API keys.
 type APIKeyMgr interface {
-	FetchOrgUserIDUsingAPIKey(ctx context.Context, key string) (uuid.UUID, uuid.UUID, error)
+	FetchAPIKeyForAuth(ctx context.Context, key string) (*authpb.APIKey, error)
+	DeleteAPIKeysForUser(ctx context.Context, orgID uuid.UUID, userID uuid.UUID) error
 }
 
 // UserInfo contains all the info about a user. It's not tied to any specific AuthProvider.
@@ -50,6 +52,13 @@ type UserInfo struct {
 	// HostedDomain is the name of an org that a user belongs to according to the IdentityProvider.
 	// If empty, the IdentityProvider does not consider the user as part of an org.
 	HostedDomain string
+	// OrgRole is the role of the user in their org according to the IdentityProvider. If empty, the
+	// role is managed in Pixie.
+	OrgRole string
+	// ManagedOrgs are the domain names of the orgs whose membership is managed by the IdentityProvider.
+	// Users who belong to one of these orgs, but who aren't in it according to the IdentityProvider,
+	// are removed from it when they log in.
+	ManagedOrgs []string
 }
 
 // CreateInviteLinkResponse contaions the InviteLink and any accompanying information.
@@ -0,0 +1,11 @@
+ALTER TABLE api_keys
+  DROP COLUMN last_used_at;
+
+ALTER TABLE api_keys
+  DROP COLUMN expires_at;
+
+ALTER TABLE api_keys
+  DROP COLUMN cluster_ids;
+
+ALTER TABLE api_keys
+  DROP COLUMN scopes;
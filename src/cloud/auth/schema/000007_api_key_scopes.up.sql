@@ -0,0 +1,15 @@
+-- Scopes restrict what the key can be used for. Keys without scopes are unrestricted.
+ALTER TABLE api_keys
+  ADD COLUMN scopes varchar(100)[] NOT NULL DEFAULT '{}';
+
+-- Cluster IDs restrict which clusters the key can access. Keys without cluster IDs can access all
+-- of the org's clusters.
+ALTER TABLE api_keys
+  ADD COLUMN cluster_ids UUID[] NOT NULL DEFAULT '{}';
+
+-- Keys without an expiry never expire.
+ALTER TABLE api_keys
+  ADD COLUMN expires_at TIMESTAMP;
+
+ALTER TABLE api_keys
+  ADD COLUMN last_used_at TIMESTAMP;
@@ -0,0 +1,311 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package controllers
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/blang/semver"
+	"github.com/gofrs/uuid"
+	"github.com/lib/pq"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"px.dev/pixie/src/cloud/plugin/pluginpb"
+	"px.dev/pixie/src/cloud/shared/audit"
+	"px.dev/pixie/src/shared/services/authcontext"
+	"px.dev/pixie/src/utils"
+)
+
+var (
+	// customPluginIDRegex matches the IDs which orgs give their custom plugins, such as "my-exporter".
+	customPluginIDRegex = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]{0,61}[a-z0-9])?$`)
+	// configurationNameRegex matches the names of plugin configurations. The configurations are sent
+	// as headers with the exported data, so the names must be valid header names.
+	configurationNameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+)
+
+// versionLess returns whether version a is older than version b. Versions which aren't valid semVer
+// are compared as strings.
+func versionLess(a, b string) bool {
+	va, errA := semver.Parse(a)
+	vb, errB := semver.Parse(b)
+	if errA != nil || errB != nil {
+		return a < b
+	}
+	return va.LT(vb)
+}
+
+// customPluginID returns the ID which a custom plugin is stored under. The IDs are scoped to the org
+// which registered the plugin, so that orgs can't claim each other's IDs or those of the catalog.
+func customPluginID(orgID uuid.UUID, id string) string {
+	return orgID.String() + "/" + id
+}
+
+// parseExportURLTemplate parses the template of a plugin's export URL.
+func parseExportURLTemplate(tmpl string) (*template.Template, error) {
+	return template.New("exportURL").Option("missingkey=zero").Parse(tmpl)
+}
+
+// renderExportURL fills in the org's configurations in the export URL template of a plugin. Export URLs
+// which aren't templates, such as those of the plugins in the catalog, are returned as is.
+func renderExportURL(tmpl string, configs map[string]string) string {
+	if !strings.Contains(tmpl, "{{") {
+		return tmpl
+	}
+	t, err := parseExportURLTemplate(tmpl)
+	if err != nil {
+		log.WithError(err).Error("Failed to parse export URL template")
+		return tmpl
+	}
+	var sb strings.Builder
+	if err := t.Execute(&sb, configs); err != nil {
+		log.WithError(err).Error("Failed to render export URL template")
+		return tmpl
+	}
+	return sb.String()
+}
+
+// validateOrgConfigurations checks that the org's configurations for a custom plugin follow the
+// plugin's schema.
+func validateOrgConfigurations(schema Configurations, required []string, configs map[string]string) error {
+	for k := range configs {
+		if _, ok := schema[k]; !ok {
+			return status.Errorf(codes.InvalidArgument, "unknown configuration %q", k)
+		}
+	}
+	for _, k := range required {
+		if configs[k] == "" {
+			return status.Errorf(codes.InvalidArgument, "configuration %q is required", k)
+		}
+	}
+	return nil
+}
+
+// validateRetentionPluginDefinition checks that the definition of a custom retention plugin is complete
+// and consistent.
+func validateRetentionPluginDefinition(req *pluginpb.RegisterRetentionPluginRequest) error {
+	if !customPluginIDRegex.MatchString(req.ID) {
+		return status.Error(codes.InvalidArgument, "plugin ID must consist of at most 63 lowercase alphanumeric characters or '-'")
+	}
+	if req.Name == "" {
+		return status.Error(codes.InvalidArgument, "plugin name cannot be empty")
+	}
+	if _, err := semver.Parse(req.Version); err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid version %q: %s", req.Version, err.Error())
+	}
+
+	for k := range req.Configurations {
+		if !configurationNameRegex.MatchString(k) {
+			return status.Errorf(codes.InvalidArgument, "invalid configuration name %q", k)
+		}
+	}
+	for _, k := range req.RequiredConfigurations {
+		if _, ok := req.Configurations[k]; !ok {
+			return status.Errorf(codes.InvalidArgument, "required configuration %q is not defined", k)
+		}
+	}
+
+	names := make(map[string]bool)
+	for _, p := range req.PresetScripts {
+		if p.Name == "" {
+			return status.Error(codes.InvalidArgument, "preset script name cannot be empty")
+		}
+		if names[p.Name] {
+			return status.Errorf(codes.InvalidArgument, "duplicate preset script %q", p.Name)
+		}
+		names[p.Name] = true
+		if strings.TrimSpace(p.Script) == "" {
+			return status.Errorf(codes.InvalidArgument, "preset script %q has no PxL", p.Name)
+		}
+		if p.DefaultFrequencyS <= 0 {
+			return status.Errorf(codes.InvalidArgument, "preset script %q must have a positive frequency", p.Name)
+		}
+	}
+
+	if req.ExportURLTemplate == "" {
+		if !req.AllowCustomExportURL {
+			return status.Error(codes.InvalidArgument, "export URL template cannot be empty unless custom export URLs are allowed")
+		}
+		return nil
+	}
+	return validateExportURLTemplate(req.ExportURLTemplate, req.Configurations)
+}
+
+// validateExportURLTemplate checks that the export URL template only references the plugin's
+// configurations, and renders to a valid URL.
+func validateExportURLTemplate(tmpl string, configurations map[string]string) error {
+	t, err := parseExportURLTemplate(tmpl)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid export URL template: %s", err.Error())
+	}
+	// Render the template with a placeholder for each configuration, failing on any reference to a
+	// configuration which isn't defined.
+	placeholders := make(map[string]string, len(configurations))
+	for k := range configurations {
+		placeholders[k] = "placeholder"
+	}
+	var sb strings.Builder
+	if err := t.Option("missingkey=error").Execute(&sb, placeholders); err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid export URL template: %s", err.Error())
+	}
+	u, err := url.Parse(sb.String())
+	if err != nil || u.Host == "" {
+		return status.Errorf(codes.InvalidArgument, "export URL template must render to a URL with a host, got %q", sb.String())
+	}
+	return nil
+}
+
+// customPluginAuditState is the state of a custom plugin release recorded in audit events.
+type customPluginAuditState struct {
+	Name           string   `json:"name"`
+	Version        string   `json:"version"`
+	Configurations []string `json:"configurations,omitempty"`
+	PresetScripts  []string `json:"presetScripts,omitempty"`
+	ExportURL      string   `json:"exportURL,omitempty"`
+}
+
+// RegisterRetentionPlugin registers a new version of a custom retention plugin for the org. Once
+// registered, the plugin is enabled and configured in the same way as the plugins in the catalog.
+func (s *Server) RegisterRetentionPlugin(ctx context.Context, req *pluginpb.RegisterRetentionPluginRequest) (*pluginpb.RegisterRetentionPluginResponse, error) {
+	orgID := utils.UUIDFromProtoOrNil(req.OrgID)
+	if orgID == uuid.Nil {
+		return nil, status.Error(codes.InvalidArgument, "Must specify OrgID")
+	}
+	if err := validateRetentionPluginDefinition(req); err != nil {
+		return nil, err
+	}
+
+	pluginID := customPluginID(orgID, req.ID)
+
+	var createdBy *uuid.UUID
+	if sCtx, err := authcontext.FromContext(ctx); err == nil {
+		if userID, err := uuid.FromString(sCtx.Claims.GetUserClaims().UserID); err == nil {
+			createdBy = &userID
+		}
+	}
+
+	txn, err := s.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Rollback()
+
+	// Serialize the registrations of each plugin ID, so that the checks below hold until commit.
+	_, err = txn.Exec(`SELECT pg_advisory_xact_lock(hashtext($1))`, pluginID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to register plugin")
+	}
+
+	query := `SELECT version, org_id FROM plugin_releases WHERE id=$1`
+	rows, err := txn.Queryx(query, pluginID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to fetch plugin")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var version string
+		var pluginOrgID *uuid.UUID
+		if err := rows.Scan(&version, &pluginOrgID); err != nil {
+			return nil, status.Error(codes.Internal, "failed to read plugin")
+		}
+		if pluginOrgID == nil || *pluginOrgID != orgID {
+			return nil, status.Errorf(codes.AlreadyExists, "plugin ID %q is already in use", pluginID)
+		}
+		if !versionLess(version, req.Version) {
+			return nil, status.Errorf(codes.InvalidArgument, "version must be newer than the existing version %s", version)
+		}
+	}
+	rows.Close()
+
+	query = `INSERT INTO plugin_releases (name, id, description, logo, version, updated_at, data_retention_enabled, org_id, created_by)
+		VALUES ($1, $2, $3, $4, $5, NOW(), true, $6, $7)`
+	_, err = txn.Exec(query, req.Name, pluginID, req.Description, req.Logo, req.Version, orgID, createdBy)
+	if err != nil {
+		log.WithError(err).Error("Failed to insert plugin release")
+		return nil, status.Error(codes.Internal, "failed to register plugin")
+	}
+
+	configurations := Configurations(req.Configurations)
+	if configurations == nil {
+		configurations = Configurations{}
+	}
+	presetScripts := make(PresetScripts, len(req.PresetScripts))
+	presetNames := make([]string, len(req.PresetScripts))
+	for i, p := range req.PresetScripts {
+		presetScripts[i] = &PresetScript{
+			Name:              p.Name,
+			Description:       p.Description,
+			DefaultFrequencyS: p.DefaultFrequencyS,
+			Script:            p.Script,
+			DefaultDisabled:   p.DefaultDisabled,
+		}
+		presetNames[i] = p.Name
+	}
+	query = `INSERT INTO data_retention_plugin_releases (plugin_id, version, configurations, preset_scripts, documentation_url,
+		default_export_url, allow_custom_export_url, allow_insecure_tls, required_configurations)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+	_, err = txn.Exec(query, pluginID, req.Version, configurations, presetScripts, req.DocumentationURL, req.ExportURLTemplate,
+		req.AllowCustomExportURL, req.AllowInsecureTLS, pq.StringArray(req.RequiredConfigurations))
+	if err != nil {
+		log.WithError(err).Error("Failed to insert retention plugin release")
+		return nil, status.Error(codes.Internal, "failed to register plugin")
+	}
+
+	if err := txn.Commit(); err != nil {
+		return nil, status.Error(codes.Internal, "failed to register plugin")
+	}
+
+	configNames := make([]string, 0, len(req.Configurations))
+	for k := range req.Configurations {
+		configNames = append(configNames, k)
+	}
+	sort.Strings(configNames)
+	audit.Emit(ctx, s.audit, audit.NewEvent(ctx, orgID, "retention_plugin.register", retentionPluginAuditResourceType, pluginID,
+		nil, &customPluginAuditState{
+			Name:           req.Name,
+			Version:        req.Version,
+			Configurations: configNames,
+			PresetScripts:  presetNames,
+			ExportURL:      req.ExportURLTemplate,
+		}))
+
+	return &pluginpb.RegisterRetentionPluginResponse{ID: pluginID}, nil
+}
+
+// GetPluginVersions gets the released versions of a plugin which is available to the org, most recent first.
+func (s *Server) GetPluginVersions(ctx context.Context, req *pluginpb.GetPluginVersionsRequest) (*pluginpb.GetPluginVersionsResponse, error) {
+	query := `SELECT version FROM plugin_releases WHERE id=$1 AND (org_id IS NULL OR org_id=$2)`
+	var versions []string
+	err := s.db.Select(&versions, query, req.ID, utils.UUIDFromProtoOrNil(req.OrgID))
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to fetch plugin versions")
+	}
+	if len(versions) == 0 {
+		return nil, status.Errorf(codes.NotFound, "plugin %q not found", req.ID)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versionLess(versions[j], versions[i]) })
+	return &pluginpb.GetPluginVersionsResponse{Versions: versions}, nil
+}
@@ -0,0 +1,293 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package controllers_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/types"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gopkg.in/yaml.v2"
+
+	"px.dev/pixie/src/api/proto/uuidpb"
+	"px.dev/pixie/src/cloud/cron_script/cronscriptpb"
+	mock_cronscriptpb "px.dev/pixie/src/cloud/cron_script/cronscriptpb/mock"
+	"px.dev/pixie/src/cloud/plugin/controllers"
+	"px.dev/pixie/src/cloud/plugin/pluginpb"
+	"px.dev/pixie/src/shared/scripts"
+	"px.dev/pixie/src/shared/services/authcontext"
+	srvutils "px.dev/pixie/src/shared/services/utils"
+	"px.dev/pixie/src/utils"
+)
+
+const (
+	testCustomPluginOrgID = "223e4567-e89b-12d3-a456-426655440000"
+	testOtherOrgID        = "223e4567-e89b-12d3-a456-426655440001"
+)
+
+func createOrgTestContext(orgID string) context.Context {
+	sCtx := authcontext.New()
+	sCtx.Claims = srvutils.GenerateJWTForUser("6ba7b810-9dad-11d1-80b4-00c04fd430c8", orgID, "test@test.com", time.Now(), "pixie")
+	return authcontext.NewContext(context.Background(), sCtx)
+}
+
+func newCustomPluginRequest(version string) *pluginpb.RegisterRetentionPluginRequest {
+	return &pluginpb.RegisterRetentionPluginRequest{
+		OrgID:       utils.ProtoFromUUIDStrOrNil(testCustomPluginOrgID),
+		ID:          "my-exporter",
+		Name:        "My Exporter",
+		Description: "Exports to our collector",
+		Version:     version,
+		Configurations: map[string]string{
+			"region":  "The region of the collector",
+			"api-key": "The API key for the collector",
+		},
+		RequiredConfigurations: []string{"api-key"},
+		PresetScripts: []*pluginpb.GetRetentionPluginConfigResponse_PresetScript{
+			{
+				Name:              "http spans",
+				Description:       "Exports HTTP spans",
+				DefaultFrequencyS: 10,
+				Script:            "import px",
+			},
+		},
+		ExportURLTemplate: "https://{{.region}}.collector.example.com:4317",
+	}
+}
+
+func TestServer_RegisterRetentionPlugin(t *testing.T) {
+	mustLoadTestData(db)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s := controllers.New(db, "test", mock_cronscriptpb.NewMockCronScriptServiceClient(ctrl))
+	ctx := createOrgTestContext(testCustomPluginOrgID)
+
+	registered, err := s.RegisterRetentionPlugin(ctx, newCustomPluginRequest("0.9.0"))
+	require.NoError(t, err)
+	pluginID := registered.ID
+	assert.Equal(t, testCustomPluginOrgID+"/my-exporter", pluginID)
+	registered, err = s.RegisterRetentionPlugin(ctx, newCustomPluginRequest("0.10.0"))
+	require.NoError(t, err)
+	assert.Equal(t, pluginID, registered.ID)
+
+	// Versions must increase.
+	_, err = s.RegisterRetentionPlugin(ctx, newCustomPluginRequest("0.10.0"))
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = s.RegisterRetentionPlugin(ctx, newCustomPluginRequest("0.2.0"))
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// IDs are scoped to the org, so other orgs may use the same ID, and custom plugins don't shadow
+	// the catalog.
+	req := newCustomPluginRequest("1.0.0")
+	req.OrgID = utils.ProtoFromUUIDStrOrNil(testOtherOrgID)
+	registered, err = s.RegisterRetentionPlugin(createOrgTestContext(testOtherOrgID), req)
+	require.NoError(t, err)
+	assert.Equal(t, testOtherOrgID+"/my-exporter", registered.ID)
+	req = newCustomPluginRequest("1.0.0")
+	req.ID = "test-plugin"
+	registered, err = s.RegisterRetentionPlugin(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, testCustomPluginOrgID+"/test-plugin", registered.ID)
+
+	// The plugin is only visible to the org which registered it.
+	resp, err := s.GetPlugins(ctx, &pluginpb.GetPluginsRequest{
+		OrgID: utils.ProtoFromUUIDStrOrNil(testCustomPluginOrgID),
+		Kind:  pluginpb.PLUGIN_KIND_RETENTION,
+	})
+	require.NoError(t, err)
+	assert.Contains(t, resp.Plugins, &pluginpb.Plugin{
+		Name:             "My Exporter",
+		ID:               pluginID,
+		Description:      "Exports to our collector",
+		LatestVersion:    "0.10.0",
+		RetentionEnabled: true,
+		Custom:           true,
+	})
+	ids := make([]string, len(resp.Plugins))
+	for i, p := range resp.Plugins {
+		ids[i] = p.ID
+		if p.ID == "test-plugin" {
+			assert.False(t, p.Custom)
+		}
+	}
+	assert.Contains(t, ids, "test-plugin")
+	assert.NotContains(t, ids, testOtherOrgID+"/my-exporter")
+	resp, err = s.GetPlugins(ctx, &pluginpb.GetPluginsRequest{OrgID: utils.ProtoFromUUIDStrOrNil(testOtherOrgID)})
+	require.NoError(t, err)
+	for _, p := range resp.Plugins {
+		assert.NotEqual(t, pluginID, p.ID)
+	}
+
+	versions, err := s.GetPluginVersions(ctx, &pluginpb.GetPluginVersionsRequest{
+		ID:    pluginID,
+		OrgID: utils.ProtoFromUUIDStrOrNil(testCustomPluginOrgID),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"0.10.0", "0.9.0"}, versions.Versions)
+	_, err = s.GetPluginVersions(ctx, &pluginpb.GetPluginVersionsRequest{
+		ID:    pluginID,
+		OrgID: utils.ProtoFromUUIDStrOrNil(testOtherOrgID),
+	})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	config, err := s.GetRetentionPluginConfig(ctx, &pluginpb.GetRetentionPluginConfigRequest{
+		ID:      pluginID,
+		Version: "0.10.0",
+		OrgID:   utils.ProtoFromUUIDStrOrNil(testCustomPluginOrgID),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "https://{{.region}}.collector.example.com:4317", config.DefaultExportURL)
+	assert.Equal(t, []string{"api-key"}, config.RequiredConfigurations)
+	require.Len(t, config.PresetScripts, 1)
+	assert.Equal(t, "http spans", config.PresetScripts[0].Name)
+	_, err = s.GetRetentionPluginConfig(ctx, &pluginpb.GetRetentionPluginConfigRequest{
+		ID:      pluginID,
+		Version: "0.10.0",
+		OrgID:   utils.ProtoFromUUIDStrOrNil(testOtherOrgID),
+	})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestServer_RegisterRetentionPlugin_Invalid(t *testing.T) {
+	mustLoadTestData(db)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s := controllers.New(db, "test", mock_cronscriptpb.NewMockCronScriptServiceClient(ctrl))
+
+	tests := []struct {
+		name   string
+		modify func(req *pluginpb.RegisterRetentionPluginRequest)
+	}{
+		{
+			name:   "invalid ID",
+			modify: func(req *pluginpb.RegisterRetentionPluginRequest) { req.ID = "My Exporter" },
+		},
+		{
+			name:   "missing name",
+			modify: func(req *pluginpb.RegisterRetentionPluginRequest) { req.Name = "" },
+		},
+		{
+			name:   "invalid version",
+			modify: func(req *pluginpb.RegisterRetentionPluginRequest) { req.Version = "latest" },
+		},
+		{
+			name:   "invalid configuration name",
+			modify: func(req *pluginpb.RegisterRetentionPluginRequest) { req.Configurations["api key"] = "The API key" },
+		},
+		{
+			name:   "undefined required configuration",
+			modify: func(req *pluginpb.RegisterRetentionPluginRequest) { req.RequiredConfigurations = []string{"token"} },
+		},
+		{
+			name:   "preset script without PxL",
+			modify: func(req *pluginpb.RegisterRetentionPluginRequest) { req.PresetScripts[0].Script = "" },
+		},
+		{
+			name:   "preset script without frequency",
+			modify: func(req *pluginpb.RegisterRetentionPluginRequest) { req.PresetScripts[0].DefaultFrequencyS = 0 },
+		},
+		{
+			name: "export URL references undefined configuration",
+			modify: func(req *pluginpb.RegisterRetentionPluginRequest) {
+				req.ExportURLTemplate = "https://{{.zone}}.collector.example.com:4317"
+			},
+		},
+		{
+			name:   "export URL isn't a URL",
+			modify: func(req *pluginpb.RegisterRetentionPluginRequest) { req.ExportURLTemplate = "{{.region}}" },
+		},
+		{
+			name:   "missing export URL",
+			modify: func(req *pluginpb.RegisterRetentionPluginRequest) { req.ExportURLTemplate = "" },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := newCustomPluginRequest("0.1.0")
+			test.modify(req)
+			_, err := s.RegisterRetentionPlugin(createOrgTestContext(testCustomPluginOrgID), req)
+			assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		})
+	}
+}
+
+func TestServer_UpdateRetentionConfigs_CustomPlugin(t *testing.T) {
+	mustLoadTestData(db)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockCSClient := mock_cronscriptpb.NewMockCronScriptServiceClient(ctrl)
+	s := controllers.New(db, "test", mockCSClient)
+	ctx := createOrgTestContext(testCustomPluginOrgID)
+
+	registered, err := s.RegisterRetentionPlugin(ctx, newCustomPluginRequest("0.1.0"))
+	require.NoError(t, err)
+
+	enableReq := func(orgID string, configs map[string]string) *pluginpb.UpdateOrgRetentionPluginConfigRequest {
+		return &pluginpb.UpdateOrgRetentionPluginConfigRequest{
+			OrgID:          utils.ProtoFromUUIDStrOrNil(orgID),
+			PluginID:       registered.ID,
+			Configurations: configs,
+			Enabled:        &types.BoolValue{Value: true},
+			Version:        &types.StringValue{Value: "0.1.0"},
+		}
+	}
+
+	// The configurations must follow the plugin's schema.
+	_, err = s.UpdateOrgRetentionPluginConfig(ctx, enableReq(testCustomPluginOrgID, map[string]string{"region": "eu"}))
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = s.UpdateOrgRetentionPluginConfig(ctx, enableReq(testCustomPluginOrgID, map[string]string{"api-key": "abc", "token": "def"}))
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// Other orgs can't enable the plugin.
+	_, err = s.UpdateOrgRetentionPluginConfig(createOrgTestContext(testOtherOrgID), enableReq(testOtherOrgID, map[string]string{"api-key": "abc"}))
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	// The preset scripts export to the URL rendered from the org's configurations.
+	configs := map[string]string{"api-key": "abc", "region": "eu"}
+	mConfig, err := yaml.Marshal(&scripts.Config{
+		OtelEndpointConfig: &scripts.OtelEndpointConfig{
+			URL:     "https://eu.collector.example.com:4317",
+			Headers: configs,
+		},
+	})
+	require.NoError(t, err)
+	mockCSClient.EXPECT().CreateScript(gomock.Any(), &cronscriptpb.CreateScriptRequest{
+		Script:     "import px",
+		ClusterIDs: make([]*uuidpb.UUID, 0),
+		Configs:    string(mConfig),
+		FrequencyS: 10,
+		OrgID:      utils.ProtoFromUUIDStrOrNil(testCustomPluginOrgID),
+	}).Return(&cronscriptpb.CreateScriptResponse{ID: utils.ProtoFromUUIDStrOrNil("323e4567-e89b-12d3-a456-426655440000")}, nil)
+
+	_, err = s.UpdateOrgRetentionPluginConfig(ctx, enableReq(testCustomPluginOrgID, configs))
+	require.NoError(t, err)
+}
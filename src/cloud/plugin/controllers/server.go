@@ -23,11 +23,13 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"sync"
 
 	"github.com/gofrs/uuid"
 	"github.com/gogo/protobuf/types"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	log "github.com/sirupsen/logrus"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
@@ -38,18 +40,23 @@ import (
 	"px.dev/pixie/src/api/proto/uuidpb"
 	"px.dev/pixie/src/cloud/cron_script/cronscriptpb"
 	"px.dev/pixie/src/cloud/plugin/pluginpb"
+	"px.dev/pixie/src/cloud/shared/audit"
 	"px.dev/pixie/src/shared/scripts"
 	"px.dev/pixie/src/shared/services/authcontext"
 	"px.dev/pixie/src/shared/services/events"
 	"px.dev/pixie/src/utils"
 )
 
+// retentionPluginAuditResourceType is the resource type of audit events for an org's retention plugins.
+const retentionPluginAuditResourceType = "retention_plugin"
+
 // Server is a bridge implementation of the pluginService.
 type Server struct {
 	db    *sqlx.DB
 	dbKey string
 
 	cronScriptClient cronscriptpb.CronScriptServiceClient
+	audit            audit.Recorder
 
 	done chan struct{}
 	once sync.Once
@@ -61,6 +68,7 @@ func New(db *sqlx.DB, dbKey string, cronScriptClient cronscriptpb.CronScriptServ
 		db:               db,
 		dbKey:            dbKey,
 		cronScriptClient: cronScriptClient,
+		audit:            audit.NewDatastore(db),
 		done:             make(chan struct{}),
 	}
 }
@@ -91,19 +99,20 @@ type Plugin struct {
 	Logo                 *string `db:"logo"`
 	Version              string  `db:"version"`
 	DataRetentionEnabled bool    `db:"data_retention_enabled" yaml:"dataRetentionEnabled"`
+	// OrgID is the org which registered the plugin, if it is a custom plugin.
+	OrgID *uuid.UUID `db:"org_id" yaml:"-"`
 }
 
 // GetPlugins fetches all of the available, latest plugins.
 func (s *Server) GetPlugins(ctx context.Context, req *pluginpb.GetPluginsRequest) (*pluginpb.GetPluginsResponse, error) {
-	query := `SELECT t1.name, t1.id, t1.description, t1.logo, t1.version, t1.data_retention_enabled FROM plugin_releases t1
-		JOIN (SELECT id, MAX(version) as version FROM plugin_releases GROUP BY id) t2
-	  	ON t1.id = t2.id AND t1.version = t2.version`
+	query := `SELECT name, id, description, logo, version, data_retention_enabled, org_id FROM plugin_releases
+		WHERE (org_id IS NULL OR org_id=$1)`
 
 	if req.Kind == pluginpb.PLUGIN_KIND_RETENTION {
-		query = fmt.Sprintf("%s %s", query, "WHERE data_retention_enabled='true'")
+		query = fmt.Sprintf("%s %s", query, "AND data_retention_enabled='true'")
 	}
 
-	rows, err := s.db.Queryx(query)
+	rows, err := s.db.Queryx(query, utils.UUIDFromProtoOrNil(req.OrgID))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return &pluginpb.GetPluginsResponse{Plugins: nil}, nil
@@ -112,18 +121,35 @@ func (s *Server) GetPlugins(ctx context.Context, req *pluginpb.GetPluginsRequest
 	}
 	defer rows.Close()
 
-	plugins := []*pluginpb.Plugin{}
+	// Find the latest release of each plugin. Versions are compared as semVer, so that 0.10.0 is
+	// newer than 0.9.0.
+	latest := map[string]*Plugin{}
 	for rows.Next() {
 		var p Plugin
 		err = rows.StructScan(&p)
 		if err != nil {
 			return nil, status.Error(codes.Internal, "failed to read plugins")
 		}
+		if l, ok := latest[p.ID]; !ok || versionLess(l.Version, p.Version) {
+			latest[p.ID] = &p
+		}
+	}
+
+	ids := make([]string, 0, len(latest))
+	for id := range latest {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	plugins := []*pluginpb.Plugin{}
+	for _, id := range ids {
+		p := latest[id]
 		ppb := &pluginpb.Plugin{
 			Name:             p.Name,
 			ID:               p.ID,
 			LatestVersion:    p.Version,
 			RetentionEnabled: p.DataRetentionEnabled,
+			Custom:           p.OrgID != nil,
 		}
 		if p.Description != nil {
 			ppb.Description = *p.Description
@@ -146,12 +172,16 @@ type RetentionPlugin struct {
 	AllowCustomExportURL bool           `db:"allow_custom_export_url" yaml:"allowCustomExportURL"`
 	AllowInsecureTLS     bool           `db:"allow_insecure_tls" yaml:"allowInsecureTLS"`
 	PresetScripts        PresetScripts  `db:"preset_scripts" yaml:"presetScripts"`
+	// RequiredConfigurations are the names of the configurations which must be set to enable the plugin.
+	RequiredConfigurations pq.StringArray `db:"required_configurations" yaml:"requiredConfigurations"`
 }
 
 // GetRetentionPluginConfig gets the config for a specific plugin release.
 func (s *Server) GetRetentionPluginConfig(ctx context.Context, req *pluginpb.GetRetentionPluginConfigRequest) (*pluginpb.GetRetentionPluginConfigResponse, error) {
-	query := `SELECT plugin_id, version, configurations, preset_scripts, documentation_url, default_export_url, allow_custom_export_url, allow_insecure_tls FROM data_retention_plugin_releases WHERE plugin_id=$1 AND version=$2`
-	rows, err := s.db.Queryx(query, req.ID, req.Version)
+	query := `SELECT d.plugin_id, d.version, d.configurations, d.preset_scripts, d.documentation_url, d.default_export_url, d.allow_custom_export_url, d.allow_insecure_tls, d.required_configurations
+		FROM data_retention_plugin_releases d JOIN plugin_releases r ON d.plugin_id = r.id AND d.version = r.version
+		WHERE d.plugin_id=$1 AND d.version=$2 AND (r.org_id IS NULL OR r.org_id=$3)`
+	rows, err := s.db.Queryx(query, req.ID, req.Version, utils.UUIDFromProtoOrNil(req.OrgID))
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "Failed to fetch plugin")
 	}
@@ -164,10 +194,11 @@ func (s *Server) GetRetentionPluginConfig(ctx context.Context, req *pluginpb.Get
 			return nil, status.Errorf(codes.Internal, "Failed to read plugin")
 		}
 		ppb := &pluginpb.GetRetentionPluginConfigResponse{
-			Configurations:       plugin.Configurations,
-			AllowCustomExportURL: plugin.AllowCustomExportURL,
-			AllowInsecureTLS:     plugin.AllowInsecureTLS,
-			PresetScripts:        []*pluginpb.GetRetentionPluginConfigResponse_PresetScript{},
+			Configurations:         plugin.Configurations,
+			AllowCustomExportURL:   plugin.AllowCustomExportURL,
+			AllowInsecureTLS:       plugin.AllowInsecureTLS,
+			PresetScripts:          []*pluginpb.GetRetentionPluginConfigResponse_PresetScript{},
+			RequiredConfigurations: plugin.RequiredConfigurations,
 		}
 		if plugin.DocumentationURL != nil {
 			ppb.DocumentationURL = *plugin.DocumentationURL
@@ -182,6 +213,7 @@ func (s *Server) GetRetentionPluginConfig(ctx context.Context, req *pluginpb.Get
 					Description:       p.Description,
 					DefaultFrequencyS: p.DefaultFrequencyS,
 					Script:            p.Script,
+					DefaultDisabled:   p.DefaultDisabled,
 				})
 			}
 		}
@@ -223,7 +255,7 @@ func (s *Server) GetRetentionPluginsForOrg(ctx context.Context, req *pluginpb.Ge
 
 // GetOrgRetentionPluginConfig gets the org's configuration for a plugin.
 func (s *Server) GetOrgRetentionPluginConfig(ctx context.Context, req *pluginpb.GetOrgRetentionPluginConfigRequest) (*pluginpb.GetOrgRetentionPluginConfigResponse, error) {
-	query := `SELECT PGP_SYM_DECRYPT(configurations, $1::text), PGP_SYM_DECRYPT(custom_export_url, $1::text), insecure_tls FROM org_data_retention_plugins WHERE org_id=$2 AND plugin_id=$3`
+	query := `SELECT PGP_SYM_DECRYPT(o.configurations, $1::text), PGP_SYM_DECRYPT(o.custom_export_url, $1::text), o.insecure_tls, r.default_export_url FROM org_data_retention_plugins o LEFT JOIN data_retention_plugin_releases r ON o.plugin_id=r.plugin_id AND o.version=r.version WHERE o.org_id=$2 AND o.plugin_id=$3`
 
 	orgID := utils.UUIDFromProtoOrNil(req.OrgID)
 	rows, err := s.db.Queryx(query, s.dbKey, orgID, req.PluginID)
@@ -236,9 +268,10 @@ func (s *Server) GetOrgRetentionPluginConfig(ctx context.Context, req *pluginpb.
 		var configurationJSON []byte
 		var exportURL *string
 		var insecureTLS bool
+		var defaultExportURL *string
 		var configMap map[string]string
 
-		err := rows.Scan(&configurationJSON, &exportURL, &insecureTLS)
+		err := rows.Scan(&configurationJSON, &exportURL, &insecureTLS, &defaultExportURL)
 		if err != nil {
 			return nil, status.Error(codes.Internal, "failed to read configs")
 		}
@@ -255,8 +288,12 @@ func (s *Server) GetOrgRetentionPluginConfig(ctx context.Context, req *pluginpb.
 			InsecureTLS:    insecureTLS,
 		}
 
+		if defaultExportURL != nil {
+			resp.ExportURL = renderExportURL(*defaultExportURL, configMap)
+		}
 		if exportURL != nil {
 			resp.CustomExportUrl = *exportURL
+			resp.ExportURL = *exportURL
 		}
 
 		return resp, nil
@@ -469,12 +506,21 @@ func (s *Server) updateOrgRetentionConfigs(ctx context.Context, txn *sqlx.Tx, or
 }
 
 func (s *Server) propagateConfigChangesToScripts(ctx context.Context, txn *sqlx.Tx, orgID uuid.UUID, pluginID string, version string, configurations []byte, customExportURL *string, insecureTLS bool) error {
+	var configMap map[string]string
+	if len(configurations) != 0 {
+		err := json.Unmarshal(configurations, &configMap)
+		if err != nil {
+			return status.Error(codes.Internal, "failed to read configs")
+		}
+	}
+
 	// Fetch default export URL for plugin.
-	pluginExportURL, _, _, err := s.getPluginConfigs(txn, orgID, pluginID)
+	exportURLTemplate, err := s.getDefaultExportURL(txn, orgID, pluginID)
 	if err != nil {
 		return err
 	}
 
+	pluginExportURL := renderExportURL(exportURLTemplate, configMap)
 	if customExportURL != nil {
 		pluginExportURL = *customExportURL
 	}
@@ -500,14 +546,6 @@ func (s *Server) propagateConfigChangesToScripts(ctx context.Context, txn *sqlx.
 	// For each script, update with the new config.
 	// TODO(michelle): This is a bit inefficient because we issue a call per script. We should consider adding an RPC method for updating multiple scripts.
 	for _, sc := range rScripts {
-		var configMap map[string]string
-		if len(configurations) != 0 {
-			err = json.Unmarshal(configurations, &configMap)
-			if err != nil {
-				return status.Error(codes.Internal, "failed to read configs")
-			}
-		}
-
 		exportURL := sc.ExportURL
 		if exportURL == "" {
 			exportURL = pluginExportURL
@@ -539,6 +577,25 @@ func (s *Server) propagateConfigChangesToScripts(ctx context.Context, txn *sqlx.
 	return nil
 }
 
+// retentionPluginAuditState is the state of an org's retention plugin recorded in audit events. Only the
+// names of the configurations are kept, since their values are usually credentials for the export.
+type retentionPluginAuditState struct {
+	Version        string   `json:"version"`
+	Configurations []string `json:"configurations,omitempty"`
+	InsecureTLS    bool     `json:"insecureTLS"`
+}
+
+func newRetentionPluginAuditState(version string, configurations []byte, insecureTLS bool) *retentionPluginAuditState {
+	var configs map[string]string
+	_ = json.Unmarshal(configurations, &configs)
+	names := make([]string, 0, len(configs))
+	for k := range configs {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return &retentionPluginAuditState{Version: version, Configurations: names, InsecureTLS: insecureTLS}
+}
+
 // UpdateOrgRetentionPluginConfig updates an org's configuration for a plugin.
 func (s *Server) UpdateOrgRetentionPluginConfig(ctx context.Context, req *pluginpb.UpdateOrgRetentionPluginConfigRequest) (*pluginpb.UpdateOrgRetentionPluginConfigResponse, error) {
 	if utils.IsNilUUIDProto(req.OrgID) {
@@ -592,11 +649,18 @@ func (s *Server) UpdateOrgRetentionPluginConfig(ctx context.Context, req *plugin
 	}
 	rows.Close()
 
+	var before *retentionPluginAuditState
+	if enabled {
+		before = newRetentionPluginAuditState(origVersion, origConfig, insecureTLS)
+	}
+
 	if version == "" {
 		version = origVersion
 	}
 
-	query = `SELECT allow_custom_export_url, allow_insecure_tls FROM data_retention_plugin_releases WHERE plugin_id=$1 AND version=$2`
+	query = `SELECT d.allow_custom_export_url, d.allow_insecure_tls, d.configurations, d.required_configurations, r.org_id
+		FROM data_retention_plugin_releases d JOIN plugin_releases r ON d.plugin_id = r.id AND d.version = r.version
+		WHERE d.plugin_id=$1 AND d.version=$2`
 	rows, err = txn.Queryx(query, req.PluginID, version)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "Failed to fetch plugin")
@@ -604,14 +668,39 @@ func (s *Server) UpdateOrgRetentionPluginConfig(ctx context.Context, req *plugin
 	defer rows.Close()
 	var allowCustomExportURL bool
 	var allowInsecureTLS bool
+	var configSchema Configurations
+	var requiredConfigs pq.StringArray
+	var pluginOrgID *uuid.UUID
 	if rows.Next() {
-		err := rows.Scan(&allowCustomExportURL, &allowInsecureTLS)
+		err := rows.Scan(&allowCustomExportURL, &allowInsecureTLS, &configSchema, &requiredConfigs, &pluginOrgID)
 		if err != nil {
 			return nil, status.Error(codes.Internal, "failed to read plugin")
 		}
 	}
 	rows.Close()
 
+	// Custom plugins are only available to the org which registered them, and the org's configurations
+	// must follow the plugin's schema.
+	if pluginOrgID != nil && *pluginOrgID != orgID {
+		return nil, status.Error(codes.NotFound, "plugin not found")
+	}
+	disabling := req.Enabled != nil && !req.Enabled.Value
+	if pluginOrgID != nil && !disabling {
+		newConfigs := configurations
+		if newConfigs == nil {
+			newConfigs = origConfig
+		}
+		var configMap map[string]string
+		if len(newConfigs) > 0 {
+			if err := json.Unmarshal(newConfigs, &configMap); err != nil {
+				return nil, status.Error(codes.Internal, "failed to read configs")
+			}
+		}
+		if err := validateOrgConfigurations(configSchema, requiredConfigs, configMap); err != nil {
+			return nil, err
+		}
+	}
+
 	if req.CustomExportUrl != nil && allowCustomExportURL {
 		customExportURL = &req.CustomExportUrl.Value
 	} else if !allowCustomExportURL {
@@ -649,7 +738,13 @@ func (s *Server) UpdateOrgRetentionPluginConfig(ctx context.Context, req *plugin
 				Set("version", req.Version),
 		})
 
-		return &pluginpb.UpdateOrgRetentionPluginConfigResponse{}, txn.Commit()
+		err = txn.Commit()
+		if err != nil {
+			return nil, err
+		}
+		audit.Emit(ctx, s.audit, audit.NewEvent(ctx, orgID, "retention_plugin.enable", retentionPluginAuditResourceType,
+			req.PluginID, nil, newRetentionPluginAuditState(version, configurations, insecureTLS)))
+		return &pluginpb.UpdateOrgRetentionPluginConfigResponse{}, nil
 	} else if enabled && req.Enabled != nil && !req.Enabled.Value { // Plugin was disabled, we should delete it.
 		err = s.disableOrgRetention(ctx, txn, orgID, req.PluginID)
 		if err != nil {
@@ -663,7 +758,14 @@ func (s *Server) UpdateOrgRetentionPluginConfig(ctx context.Context, req *plugin
 			Properties: analytics.NewProperties().
 				Set("plugin_id", req.PluginID),
 		})
-		return &pluginpb.UpdateOrgRetentionPluginConfigResponse{}, txn.Commit()
+
+		err = txn.Commit()
+		if err != nil {
+			return nil, err
+		}
+		audit.Emit(ctx, s.audit, audit.NewEvent(ctx, orgID, "retention_plugin.disable", retentionPluginAuditResourceType,
+			req.PluginID, before, nil))
+		return &pluginpb.UpdateOrgRetentionPluginConfigResponse{}, nil
 	} else if !enabled && req.Enabled != nil && !req.Enabled.Value {
 		// This is already disabled.
 		return &pluginpb.UpdateOrgRetentionPluginConfigResponse{}, nil
@@ -689,6 +791,8 @@ func (s *Server) UpdateOrgRetentionPluginConfig(ctx context.Context, req *plugin
 	if err != nil {
 		return nil, err
 	}
+	audit.Emit(ctx, s.audit, audit.NewEvent(ctx, orgID, "retention_plugin.update", retentionPluginAuditResourceType,
+		req.PluginID, before, newRetentionPluginAuditState(version, configurations, insecureTLS)))
 
 	return &pluginpb.UpdateOrgRetentionPluginConfigResponse{}, nil
 }
@@ -923,6 +1027,7 @@ func (s *Server) getPluginConfigs(txn *sqlx.Tx, orgID uuid.UUID, pluginID string
 		}
 	}
 
+	pluginExportURL = renderExportURL(pluginExportURL, configMap)
 	if customExportURL != nil {
 		pluginExportURL = *customExportURL
 	}
@@ -930,6 +1035,23 @@ func (s *Server) getPluginConfigs(txn *sqlx.Tx, orgID uuid.UUID, pluginID string
 	return pluginExportURL, configMap, insecureTLS, nil
 }
 
+// getDefaultExportURL gets the export URL template of the plugin release which the org has enabled.
+func (s *Server) getDefaultExportURL(txn *sqlx.Tx, orgID uuid.UUID, pluginID string) (string, error) {
+	query := `SELECT r.default_export_url FROM org_data_retention_plugins o, data_retention_plugin_releases r WHERE o.org_id=$1 AND r.plugin_id=$2 AND o.plugin_id=r.plugin_id AND r.version = o.version`
+	var exportURL *string
+	err := txn.QueryRowx(query, orgID, pluginID).Scan(&exportURL)
+	if err == sql.ErrNoRows {
+		return "", status.Error(codes.NotFound, "plugin is not enabled")
+	}
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "failed to fetch plugin")
+	}
+	if exportURL == nil {
+		return "", nil
+	}
+	return *exportURL, nil
+}
+
 func scriptConfigToYAML(configMap map[string]string, exportURL string, insecureTLS bool) (string, error) {
 	config := &scripts.Config{
 		OtelEndpointConfig: &scripts.OtelEndpointConfig{
@@ -314,6 +314,7 @@ func TestServer_GetOrgRetentionPluginConfig(t *testing.T) {
 			"license_key3": "hello",
 		},
 		CustomExportUrl: "https://localhost:8080",
+		ExportURL:       "https://localhost:8080",
 	}, resp)
 }
 
@@ -32,6 +32,7 @@ import (
 	"px.dev/pixie/src/cloud/plugin/controllers"
 	"px.dev/pixie/src/cloud/plugin/pluginpb"
 	"px.dev/pixie/src/cloud/plugin/schema"
+	"px.dev/pixie/src/cloud/shared/audit"
 	"px.dev/pixie/src/cloud/shared/pgmigrate"
 	"px.dev/pixie/src/shared/services"
 	"px.dev/pixie/src/shared/services/env"
@@ -76,6 +77,10 @@ func main() {
 	if err != nil {
 		log.WithError(err).Fatal("Failed to apply migrations")
 	}
+	err = audit.PerformMigrations(db)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to apply audit migrations")
+	}
 
 	dbKey := viper.GetString("database_key")
 	if dbKey == "" {
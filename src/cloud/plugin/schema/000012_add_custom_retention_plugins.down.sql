@@ -0,0 +1,6 @@
+ALTER TABLE data_retention_plugin_releases DROP COLUMN required_configurations;
+
+DROP INDEX IF EXISTS plugin_releases_org_id_idx;
+
+ALTER TABLE plugin_releases DROP COLUMN created_by;
+ALTER TABLE plugin_releases DROP COLUMN org_id;
@@ -0,0 +1,9 @@
+-- org_id is the org which registered the plugin, for custom plugins. Plugins from the catalog have no org.
+ALTER TABLE plugin_releases ADD org_id UUID;
+-- created_by is the user who registered the custom plugin release.
+ALTER TABLE plugin_releases ADD created_by UUID;
+
+CREATE INDEX plugin_releases_org_id_idx ON plugin_releases (org_id);
+
+-- required_configurations are the names of the configurations which must be set to enable the plugin.
+ALTER TABLE data_retention_plugin_releases ADD required_configurations varchar(1024)[];
@@ -38,6 +38,7 @@ import (
 	"px.dev/pixie/src/cloud/profile/profileenv"
 	"px.dev/pixie/src/cloud/profile/profilepb"
 	"px.dev/pixie/src/cloud/project_manager/projectmanagerpb"
+	"px.dev/pixie/src/cloud/shared/audit"
 	"px.dev/pixie/src/shared/services/authcontext"
 	claimsutils "px.dev/pixie/src/shared/services/utils"
 	"px.dev/pixie/src/utils"
@@ -46,6 +47,9 @@ import (
 // DefaultProjectName is the name of the default project we automatically assign to every org.
 const DefaultProjectName string = "default"
 
+// ideConfigAuditResourceType is the resource type of audit events for the IDE configs of an org.
+const ideConfigAuditResourceType = "org_ide_config"
+
 // UserDatastore is the interface used to the backing store for user profile information.
 type UserDatastore interface {
 	// CreateUser creates a new user.
@@ -115,6 +119,14 @@ type OrgSettingsDatastore interface {
 	GetIDEConfig(uuid.UUID, string) (*datastore.IDEConfig, error)
 }
 
+// AuditDatastore is the interface used as the backing store for the audit events of orgs.
+type AuditDatastore interface {
+	// Record stores an audit event.
+	Record(context.Context, *audit.Event) error
+	// ListEvents gets the audit events matching the filter.
+	ListEvents(context.Context, *audit.Filter) ([]*audit.Event, error)
+}
+
 // Server is an implementation of GRPC server for profile service.
 type Server struct {
 	env  profileenv.ProfileEnv
@@ -122,11 +134,12 @@ type Server struct {
 	usds UserSettingsDatastore
 	ods  OrgDatastore
 	osds OrgSettingsDatastore
+	ads  AuditDatastore
 }
 
 // NewServer creates a new GRPC profile server.
-func NewServer(env profileenv.ProfileEnv, uds UserDatastore, usds UserSettingsDatastore, ods OrgDatastore, osds OrgSettingsDatastore) *Server {
-	return &Server{env: env, uds: uds, usds: usds, ods: ods, osds: osds}
+func NewServer(env profileenv.ProfileEnv, uds UserDatastore, usds UserSettingsDatastore, ods OrgDatastore, osds OrgSettingsDatastore, ads AuditDatastore) *Server {
+	return &Server{env: env, uds: uds, usds: usds, ods: ods, osds: osds, ads: ads}
 }
 
 func userInfoToProto(u *datastore.UserInfo) *profilepb.UserInfo {
@@ -148,6 +161,7 @@ func userInfoToProto(u *datastore.UserInfo) *profilepb.UserInfo {
 		IsApproved:       u.IsApproved,
 		IdentityProvider: u.IdentityProvider,
 		AuthProviderID:   u.AuthProviderID,
+		OrgRole:          u.OrgRole,
 	}
 }
 
@@ -250,6 +264,7 @@ func (s *Server) CreateOrgAndUser(ctx context.Context, req *profilepb.CreateOrgA
 		// By default, the creating user is the owner and should be approved.
 		IsApproved:     true,
 		AuthProviderID: req.User.AuthProviderID,
+		OrgRole:        claimsutils.OrgRoleOwner,
 	}
 	if len(orgInfo.OrgName) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "invalid org name")
@@ -380,6 +395,8 @@ func (s *Server) UpdateUser(ctx context.Context, req *profilepb.UpdateUserReques
 		return nil, toExternalError(err)
 	}
 
+	prevOrgID := userInfo.OrgID
+	prevRole := userInfo.OrgRole
 	if req.OrgID != nil {
 		newOrgID := utils.UUIDFromProtoOrNil(req.OrgID)
 		if newOrgID == uuid.Nil {
@@ -387,6 +404,23 @@ func (s *Server) UpdateUser(ctx context.Context, req *profilepb.UpdateUserReques
 		} else {
 			userInfo.OrgID = &newOrgID
 		}
+		if !sameOrg(prevOrgID, userInfo.OrgID) {
+			userInfo.OrgRole = claimsutils.OrgRoleMember
+		}
+	}
+
+	if req.OrgRole != nil {
+		if !claimsutils.IsValidOrgRole(req.OrgRole.Value) {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid org role %q", req.OrgRole.Value)
+		}
+		userInfo.OrgRole = req.OrgRole.Value
+	}
+
+	if prevRole == claimsutils.OrgRoleOwner && prevOrgID != nil &&
+		(userInfo.OrgRole != claimsutils.OrgRoleOwner || !sameOrg(prevOrgID, userInfo.OrgID)) {
+		if err := s.checkOtherOwnerExists(*prevOrgID, userID); err != nil {
+			return nil, err
+		}
 	}
 
 	if req.DisplayPicture != nil {
@@ -397,6 +431,22 @@ func (s *Server) UpdateUser(ctx context.Context, req *profilepb.UpdateUserReques
 		userInfo.IsApproved = req.IsApproved.Value
 	}
 
+	if req.FirstName != nil {
+		userInfo.FirstName = req.FirstName.Value
+	}
+
+	if req.LastName != nil {
+		userInfo.LastName = req.LastName.Value
+	}
+
+	if req.IdentityProvider != nil {
+		userInfo.IdentityProvider = req.IdentityProvider.Value
+	}
+
+	if req.AuthProviderID != nil {
+		userInfo.AuthProviderID = req.AuthProviderID.Value
+	}
+
 	err = s.uds.UpdateUser(userInfo)
 	if err != nil {
 		return nil, toExternalError(err)
@@ -405,6 +455,28 @@ func (s *Server) UpdateUser(ctx context.Context, req *profilepb.UpdateUserReques
 	return userInfoToProto(userInfo), nil
 }
 
+func sameOrg(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// checkOtherOwnerExists makes sure that an org keeps at least one owner when the given user stops
+// being one.
+func (s *Server) checkOtherOwnerExists(orgID uuid.UUID, userID uuid.UUID) error {
+	users, err := s.ods.GetUsersInOrg(orgID)
+	if err != nil {
+		return toExternalError(err)
+	}
+	for _, u := range users {
+		if u.ID != userID && u.OrgRole == claimsutils.OrgRoleOwner {
+			return nil
+		}
+	}
+	return status.Error(codes.FailedPrecondition, "an org must have at least one owner")
+}
+
 // GetUserSettings gets the user settings for the given user.
 func (s *Server) GetUserSettings(ctx context.Context, req *profilepb.GetUserSettingsRequest) (*profilepb.GetUserSettingsResponse, error) {
 	userID := utils.UUIDFromProtoOrNil(req.ID)
@@ -497,6 +569,16 @@ func (s *Server) GetUsersInOrg(ctx context.Context, req *profilepb.GetUsersInOrg
 	}, nil
 }
 
+// orgAuditState is the state of an org recorded in audit events.
+type orgAuditState struct {
+	DomainName      string `json:"domainName,omitempty"`
+	EnableApprovals bool   `json:"enableApprovals"`
+}
+
+func newOrgAuditState(o *datastore.OrgInfo) *orgAuditState {
+	return &orgAuditState{DomainName: o.GetDomainName(), EnableApprovals: o.EnableApprovals}
+}
+
 // UpdateOrg updates an orgs info.
 func (s *Server) UpdateOrg(ctx context.Context, req *profilepb.UpdateOrgRequest) (*profilepb.OrgInfo, error) {
 	id := utils.UUIDFromProtoOrNil(req.ID)
@@ -523,6 +605,7 @@ func (s *Server) UpdateOrg(ctx context.Context, req *profilepb.UpdateOrgRequest)
 	if err != nil {
 		return nil, toExternalError(err)
 	}
+	before := newOrgAuditState(orgInfo)
 
 	var hasUpdate bool
 	if req.EnableApprovals != nil && orgInfo.EnableApprovals != req.EnableApprovals.Value {
@@ -550,6 +633,7 @@ func (s *Server) UpdateOrg(ctx context.Context, req *profilepb.UpdateOrgRequest)
 			return nil, toExternalError(err)
 		}
 	}
+	audit.Emit(ctx, s.ads, audit.NewEvent(ctx, id, "org.update", "org", id.String(), before, newOrgAuditState(orgInfo)))
 	return orgInfoToProto(orgInfo), nil
 }
 
@@ -565,6 +649,8 @@ func (s *Server) AddOrgIDEConfig(ctx context.Context, req *profilepb.AddOrgIDECo
 	if err != nil {
 		return nil, err
 	}
+	audit.Emit(ctx, s.ads, audit.NewEvent(ctx, orgID, "org_ide_config.add", ideConfigAuditResourceType,
+		req.Config.IDEName, nil, req.Config))
 
 	return &profilepb.AddOrgIDEConfigResponse{
 		Config: req.Config,
@@ -575,10 +661,18 @@ func (s *Server) AddOrgIDEConfig(ctx context.Context, req *profilepb.AddOrgIDECo
 func (s *Server) DeleteOrgIDEConfig(ctx context.Context, req *profilepb.DeleteOrgIDEConfigRequest) (*profilepb.DeleteOrgIDEConfigResponse, error) {
 	orgID := utils.UUIDFromProtoOrNil(req.OrgID)
 
+	// The config is only fetched to record it in the audit trail.
+	var before *profilepb.IDEConfig
+	if conf, err := s.osds.GetIDEConfig(orgID, req.IDEName); err == nil && conf != nil {
+		before = &profilepb.IDEConfig{IDEName: conf.Name, Path: conf.Path}
+	}
+
 	err := s.osds.DeleteIDEConfig(orgID, req.IDEName)
 	if err != nil {
 		return nil, err
 	}
+	audit.Emit(ctx, s.ads, audit.NewEvent(ctx, orgID, "org_ide_config.delete", ideConfigAuditResourceType,
+		req.IDEName, before, nil))
 
 	return &profilepb.DeleteOrgIDEConfigResponse{}, nil
 }
@@ -696,3 +790,61 @@ func (s *Server) VerifyInviteToken(ctx context.Context, req *profilepb.InviteTok
 
 	return &profilepb.VerifyInviteTokenResponse{Valid: true, OrgID: utils.ProtoFromUUID(orgID)}, nil
 }
+
+// GetAuditEvents gets the audit events of an org, newest first.
+func (s *Server) GetAuditEvents(ctx context.Context, req *profilepb.GetAuditEventsRequest) (*profilepb.GetAuditEventsResponse, error) {
+	orgID := utils.UUIDFromProtoOrNil(req.OrgID)
+	if orgID == uuid.Nil {
+		return nil, status.Error(codes.InvalidArgument, "org ID improperly formatted")
+	}
+	sCtx, err := authcontext.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if claimsutils.GetClaimsType(sCtx.Claims) == claimsutils.UserClaimType &&
+		uuid.FromStringOrNil(sCtx.Claims.GetUserClaims().OrgID) != orgID {
+		return nil, status.Error(codes.PermissionDenied, "user does not have permissions to view the org's audit events")
+	}
+
+	filter := &audit.Filter{
+		OrgID:        orgID,
+		ActorID:      req.ActorID,
+		ResourceType: req.ResourceType,
+		ResourceID:   req.ResourceID,
+		Limit:        int(req.Limit),
+	}
+	if req.StartTime != nil {
+		if filter.StartTime, err = types.TimestampFromProto(req.StartTime); err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid start time")
+		}
+	}
+	if req.EndTime != nil {
+		if filter.EndTime, err = types.TimestampFromProto(req.EndTime); err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid end time")
+		}
+	}
+
+	events, err := s.ads.ListEvents(ctx, filter)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to get audit events")
+	}
+	resp := &profilepb.GetAuditEventsResponse{
+		Events: make([]*profilepb.AuditEvent, len(events)),
+	}
+	for i, e := range events {
+		ts, _ := types.TimestampProto(e.Time)
+		resp.Events[i] = &profilepb.AuditEvent{
+			ID:           utils.ProtoFromUUID(e.ID),
+			Time:         ts,
+			OrgID:        utils.ProtoFromUUID(e.OrgID),
+			ActorType:    e.ActorType,
+			ActorID:      e.ActorID,
+			Action:       e.Action,
+			ResourceType: e.ResourceType,
+			ResourceID:   e.ResourceID,
+			Before:       e.Before,
+			After:        e.After,
+		}
+	}
+	return resp, nil
+}
@@ -42,6 +42,7 @@ import (
 	"px.dev/pixie/src/cloud/profile/profilepb"
 	"px.dev/pixie/src/cloud/project_manager/projectmanagerpb"
 	mock_projectmanager "px.dev/pixie/src/cloud/project_manager/projectmanagerpb/mock"
+	"px.dev/pixie/src/cloud/shared/audit"
 	"px.dev/pixie/src/shared/services/authcontext"
 	svcutils "px.dev/pixie/src/shared/services/utils"
 	"px.dev/pixie/src/utils"
@@ -196,7 +197,7 @@ func TestServer_CreateUser(t *testing.T) {
 
 	for _, tc := range createUsertests {
 		t.Run(tc.name, func(t *testing.T) {
-			s := controllers.NewServer(nil, uds, usds, ods, osds)
+			s := controllers.NewServer(nil, uds, usds, ods, osds, nil)
 			if utils.UUIDFromProtoOrNil(tc.userInfo.OrgID) != uuid.Nil {
 				ods.EXPECT().
 					GetOrg(testOrgUUID).
@@ -242,7 +243,7 @@ func TestServer_CreateOrg(t *testing.T) {
 	osds := mock_controllers.NewMockOrgSettingsDatastore(ctrl)
 
 	testOrgUUID := uuid.Must(uuid.NewV4())
-	s := controllers.NewServer(nil, nil, nil, ods, osds)
+	s := controllers.NewServer(nil, nil, nil, ods, osds, nil)
 	domain := "pixielabs.ai"
 	req := &datastore.OrgInfo{
 		OrgName:    "pixie",
@@ -270,7 +271,7 @@ func TestServer_CreateOrg_NilDomainName(t *testing.T) {
 	osds := mock_controllers.NewMockOrgSettingsDatastore(ctrl)
 
 	testOrgUUID := uuid.Must(uuid.NewV4())
-	s := controllers.NewServer(nil, nil, nil, ods, osds)
+	s := controllers.NewServer(nil, nil, nil, ods, osds, nil)
 	req := &datastore.OrgInfo{
 		OrgName: "pixie",
 	}
@@ -299,7 +300,7 @@ func TestServer_GetUser(t *testing.T) {
 
 	userUUID := uuid.Must(uuid.NewV4())
 	orgUUID := uuid.Must(uuid.NewV4())
-	s := controllers.NewServer(nil, uds, usds, ods, osds)
+	s := controllers.NewServer(nil, uds, usds, ods, osds, nil)
 
 	mockReply := &datastore.UserInfo{
 		ID:             userUUID,
@@ -335,7 +336,7 @@ func TestServer_GetUser_MissingUser(t *testing.T) {
 	osds := mock_controllers.NewMockOrgSettingsDatastore(ctrl)
 
 	userUUID := uuid.Must(uuid.NewV4())
-	s := controllers.NewServer(nil, uds, usds, ods, osds)
+	s := controllers.NewServer(nil, uds, usds, ods, osds, nil)
 	uds.EXPECT().
 		GetUser(userUUID).
 		Return(nil, nil)
@@ -357,7 +358,7 @@ func TestServer_GetUserByEmail(t *testing.T) {
 
 	userUUID := uuid.Must(uuid.NewV4())
 	orgUUID := uuid.Must(uuid.NewV4())
-	s := controllers.NewServer(nil, uds, usds, ods, osds)
+	s := controllers.NewServer(nil, uds, usds, ods, osds, nil)
 
 	mockReply := &datastore.UserInfo{
 		ID:               userUUID,
@@ -396,7 +397,7 @@ func TestServer_GetUserByAuthProviderID(t *testing.T) {
 
 	userUUID := uuid.Must(uuid.NewV4())
 	orgUUID := uuid.Must(uuid.NewV4())
-	s := controllers.NewServer(nil, uds, usds, ods, osds)
+	s := controllers.NewServer(nil, uds, usds, ods, osds, nil)
 
 	mockReply := &datastore.UserInfo{
 		ID:               userUUID,
@@ -433,7 +434,7 @@ func TestServer_GetUserByEmail_MissingEmail(t *testing.T) {
 	usds := mock_controllers.NewMockUserSettingsDatastore(ctrl)
 	osds := mock_controllers.NewMockOrgSettingsDatastore(ctrl)
 
-	s := controllers.NewServer(nil, uds, usds, ods, osds)
+	s := controllers.NewServer(nil, uds, usds, ods, osds, nil)
 
 	uds.EXPECT().
 		GetUserByEmail("foo@bar.com").
@@ -519,7 +520,7 @@ func TestServer_CreateOrgAndUser_SuccessCases(t *testing.T) {
 
 			env := profileenv.New(pm)
 
-			s := controllers.NewServer(env, uds, usds, ods, osds)
+			s := controllers.NewServer(env, uds, usds, ods, osds, nil)
 			exUserInfo := &datastore.UserInfo{
 				FirstName:        tc.req.User.FirstName,
 				LastName:         tc.req.User.LastName,
@@ -527,6 +528,7 @@ func TestServer_CreateOrgAndUser_SuccessCases(t *testing.T) {
 				IsApproved:       true,
 				IdentityProvider: tc.req.User.IdentityProvider,
 				AuthProviderID:   tc.req.User.AuthProviderID,
+				OrgRole:          "owner",
 			}
 			exOrg := &datastore.OrgInfo{
 				DomainName: &tc.req.Org.DomainName,
@@ -591,7 +593,7 @@ func TestServer_CreateOrgAndUser_InvalidArgumentCases(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			pm := mock_projectmanager.NewMockProjectManagerServiceClient(ctrl)
 			env := profileenv.New(pm)
-			s := controllers.NewServer(env, uds, usds, ods, osds)
+			s := controllers.NewServer(env, uds, usds, ods, osds, nil)
 			resp, err := s.CreateOrgAndUser(context.Background(), tc.req)
 			assert.NotNil(t, err)
 			assert.Nil(t, resp)
@@ -636,13 +638,14 @@ func TestServer_CreateOrgAndUser_CreateProjectFailed(t *testing.T) {
 		},
 	}
 
-	s := controllers.NewServer(env, uds, usds, ods, osds)
+	s := controllers.NewServer(env, uds, usds, ods, osds, nil)
 	exUserInfo := &datastore.UserInfo{
 		FirstName:        req.User.FirstName,
 		LastName:         req.User.LastName,
 		Email:            req.User.Email,
 		IsApproved:       true,
 		IdentityProvider: "github",
+		OrgRole:          "owner",
 	}
 	exOrg := &datastore.OrgInfo{
 		DomainName: &req.Org.DomainName,
@@ -671,7 +674,7 @@ func TestServer_GetOrg(t *testing.T) {
 	osds := mock_controllers.NewMockOrgSettingsDatastore(ctrl)
 
 	orgUUID := uuid.Must(uuid.NewV4())
-	s := controllers.NewServer(nil, uds, usds, ods, osds)
+	s := controllers.NewServer(nil, uds, usds, ods, osds, nil)
 
 	orgDomain := "my-org.com"
 	mockReply := &datastore.OrgInfo{
@@ -702,7 +705,7 @@ func TestServer_GetOrg_NilDomain(t *testing.T) {
 	osds := mock_controllers.NewMockOrgSettingsDatastore(ctrl)
 
 	orgUUID := uuid.Must(uuid.NewV4())
-	s := controllers.NewServer(nil, uds, usds, ods, osds)
+	s := controllers.NewServer(nil, uds, usds, ods, osds, nil)
 
 	mockReply := &datastore.OrgInfo{
 		ID:         orgUUID,
@@ -734,7 +737,7 @@ func TestServer_GetOrgs(t *testing.T) {
 	orgUUID := uuid.Must(uuid.NewV4())
 	org2UUID := uuid.Must(uuid.NewV4())
 
-	s := controllers.NewServer(nil, uds, usds, ods, osds)
+	s := controllers.NewServer(nil, uds, usds, ods, osds, nil)
 
 	org1Domain := "my-org.com"
 	org2Domain := "pixie.com"
@@ -777,7 +780,7 @@ func TestServer_GetOrg_MissingOrg(t *testing.T) {
 	osds := mock_controllers.NewMockOrgSettingsDatastore(ctrl)
 
 	orgUUID := uuid.Must(uuid.NewV4())
-	s := controllers.NewServer(nil, uds, usds, ods, osds)
+	s := controllers.NewServer(nil, uds, usds, ods, osds, nil)
 
 	ods.EXPECT().
 		GetOrg(orgUUID).
@@ -799,7 +802,7 @@ func TestServer_GetOrgByName(t *testing.T) {
 	osds := mock_controllers.NewMockOrgSettingsDatastore(ctrl)
 
 	orgUUID := uuid.Must(uuid.NewV4())
-	s := controllers.NewServer(nil, uds, usds, ods, osds)
+	s := controllers.NewServer(nil, uds, usds, ods, osds, nil)
 
 	orgDomain := "my-org.com"
 	mockReply := &datastore.OrgInfo{
@@ -831,7 +834,7 @@ func TestServer_GetOrgByName_MissingOrg(t *testing.T) {
 	usds := mock_controllers.NewMockUserSettingsDatastore(ctrl)
 	osds := mock_controllers.NewMockOrgSettingsDatastore(ctrl)
 
-	s := controllers.NewServer(nil, uds, usds, ods, osds)
+	s := controllers.NewServer(nil, uds, usds, ods, osds, nil)
 
 	ods.EXPECT().
 		GetOrgByName("my-org").
@@ -856,7 +859,7 @@ func TestServer_GetOrgByDomain(t *testing.T) {
 	osds := mock_controllers.NewMockOrgSettingsDatastore(ctrl)
 
 	orgUUID := uuid.Must(uuid.NewV4())
-	s := controllers.NewServer(nil, uds, usds, ods, osds)
+	s := controllers.NewServer(nil, uds, usds, ods, osds, nil)
 
 	orgDomain := "my-org.com"
 	mockReply := &datastore.OrgInfo{
@@ -888,7 +891,7 @@ func TestServer_GetOrgByDomain_MissingOrg(t *testing.T) {
 	usds := mock_controllers.NewMockUserSettingsDatastore(ctrl)
 	osds := mock_controllers.NewMockOrgSettingsDatastore(ctrl)
 
-	s := controllers.NewServer(nil, uds, usds, ods, osds)
+	s := controllers.NewServer(nil, uds, usds, ods, osds, nil)
 
 	ods.EXPECT().
 		GetOrgByDomain("my-org.com").
@@ -912,7 +915,7 @@ func TestServer_DeleteOrgAndUsers(t *testing.T) {
 	usds := mock_controllers.NewMockUserSettingsDatastore(ctrl)
 	osds := mock_controllers.NewMockOrgSettingsDatastore(ctrl)
 
-	s := controllers.NewServer(nil, uds, usds, ods, osds)
+	s := controllers.NewServer(nil, uds, usds, ods, osds, nil)
 
 	orgUUID := uuid.Must(uuid.NewV4())
 
@@ -938,7 +941,7 @@ func TestServer_DeleteOrgAndUsers_MissingOrg(t *testing.T) {
 	usds := mock_controllers.NewMockUserSettingsDatastore(ctrl)
 	osds := mock_controllers.NewMockOrgSettingsDatastore(ctrl)
 
-	s := controllers.NewServer(nil, uds, usds, ods, osds)
+	s := controllers.NewServer(nil, uds, usds, ods, osds, nil)
 
 	orgUUID := uuid.Must(uuid.NewV4())
 	ods.EXPECT().
@@ -1011,7 +1014,7 @@ func TestServer_UpdateUser(t *testing.T) {
 	for _, tc := range updateUserTest {
 		t.Run(tc.name, func(t *testing.T) {
 			ctx := CreateTestContext()
-			s := controllers.NewServer(nil, uds, usds, ods, osds)
+			s := controllers.NewServer(nil, uds, usds, ods, osds, nil)
 			userID := uuid.FromStringOrNil(tc.userID)
 			orgID := uuid.FromStringOrNil(tc.userOrg)
 
@@ -1056,6 +1059,8 @@ func TestServer_UpdateUser(t *testing.T) {
 				if newOrgID == uuid.Nil {
 					mockUpdateReq.OrgID = nil
 				}
+				// Users joining another org start out as members.
+				mockUpdateReq.OrgRole = "member"
 			}
 
 			uds.EXPECT().
@@ -1078,6 +1083,88 @@ func TestServer_UpdateUser(t *testing.T) {
 	}
 }
 
+func TestServer_UpdateUser_OrgRole(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	uds := mock_controllers.NewMockUserDatastore(ctrl)
+	ods := mock_controllers.NewMockOrgDatastore(ctrl)
+	usds := mock_controllers.NewMockUserSettingsDatastore(ctrl)
+	osds := mock_controllers.NewMockOrgSettingsDatastore(ctrl)
+	s := controllers.NewServer(nil, uds, usds, ods, osds, nil)
+
+	orgID := uuid.FromStringOrNil("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+	ownerID := uuid.FromStringOrNil("6ba7b810-9dad-11d1-80b4-00c04fd430c9")
+	otherID := uuid.FromStringOrNil("6ba7b810-9dad-11d1-80b4-00c04fd430c7")
+
+	t.Run("invalid role", func(t *testing.T) {
+		uds.EXPECT().
+			GetUser(otherID).
+			Return(&datastore.UserInfo{ID: otherID, OrgID: &orgID, OrgRole: "member"}, nil)
+
+		_, err := s.UpdateUser(CreateTestContext(), &profilepb.UpdateUserRequest{
+			ID:      utils.ProtoFromUUID(otherID),
+			OrgRole: &types.StringValue{Value: "superuser"},
+		})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("promote member", func(t *testing.T) {
+		uds.EXPECT().
+			GetUser(otherID).
+			Return(&datastore.UserInfo{ID: otherID, OrgID: &orgID, OrgRole: "member"}, nil)
+		uds.EXPECT().
+			UpdateUser(&datastore.UserInfo{ID: otherID, OrgID: &orgID, OrgRole: "admin"}).
+			Return(nil)
+
+		resp, err := s.UpdateUser(CreateTestContext(), &profilepb.UpdateUserRequest{
+			ID:      utils.ProtoFromUUID(otherID),
+			OrgRole: &types.StringValue{Value: "admin"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "admin", resp.OrgRole)
+	})
+
+	t.Run("demote last owner", func(t *testing.T) {
+		uds.EXPECT().
+			GetUser(ownerID).
+			Return(&datastore.UserInfo{ID: ownerID, OrgID: &orgID, OrgRole: "owner"}, nil)
+		ods.EXPECT().
+			GetUsersInOrg(orgID).
+			Return([]*datastore.UserInfo{
+				{ID: ownerID, OrgID: &orgID, OrgRole: "owner"},
+				{ID: otherID, OrgID: &orgID, OrgRole: "admin"},
+			}, nil)
+
+		_, err := s.UpdateUser(CreateTestContext(), &profilepb.UpdateUserRequest{
+			ID:      utils.ProtoFromUUID(ownerID),
+			OrgRole: &types.StringValue{Value: "admin"},
+		})
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+
+	t.Run("demote owner with another owner", func(t *testing.T) {
+		uds.EXPECT().
+			GetUser(ownerID).
+			Return(&datastore.UserInfo{ID: ownerID, OrgID: &orgID, OrgRole: "owner"}, nil)
+		ods.EXPECT().
+			GetUsersInOrg(orgID).
+			Return([]*datastore.UserInfo{
+				{ID: ownerID, OrgID: &orgID, OrgRole: "owner"},
+				{ID: otherID, OrgID: &orgID, OrgRole: "owner"},
+			}, nil)
+		uds.EXPECT().
+			UpdateUser(&datastore.UserInfo{ID: ownerID, OrgID: &orgID, OrgRole: "viewer"}).
+			Return(nil)
+
+		_, err := s.UpdateUser(CreateTestContext(), &profilepb.UpdateUserRequest{
+			ID:      utils.ProtoFromUUID(ownerID),
+			OrgRole: &types.StringValue{Value: "viewer"},
+		})
+		require.NoError(t, err)
+	})
+}
+
 func TestServer_UpdateOrg_EnableApprovals(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -1086,9 +1173,10 @@ func TestServer_UpdateOrg_EnableApprovals(t *testing.T) {
 	ods := mock_controllers.NewMockOrgDatastore(ctrl)
 	usds := mock_controllers.NewMockUserSettingsDatastore(ctrl)
 	osds := mock_controllers.NewMockOrgSettingsDatastore(ctrl)
+	ads := mock_controllers.NewMockAuditDatastore(ctrl)
 
 	orgID := uuid.FromStringOrNil("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
-	s := controllers.NewServer(nil, uds, usds, ods, osds)
+	s := controllers.NewServer(nil, uds, usds, ods, osds, ads)
 
 	mockReply := &datastore.OrgInfo{
 		ID:              orgID,
@@ -1108,6 +1196,17 @@ func TestServer_UpdateOrg_EnableApprovals(t *testing.T) {
 		UpdateOrg(mockUpdateReq).
 		Return(nil)
 
+	ads.EXPECT().
+		Record(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, e *audit.Event) error {
+			assert.Equal(t, "org.update", e.Action)
+			assert.Equal(t, orgID, e.OrgID)
+			assert.Equal(t, audit.ActorTypeUser, e.ActorType)
+			assert.JSONEq(t, `{"enableApprovals": false}`, e.Before)
+			assert.JSONEq(t, `{"enableApprovals": true}`, e.After)
+			return nil
+		})
+
 	resp, err := s.UpdateOrg(
 		CreateTestContext(),
 		&profilepb.UpdateOrgRequest{
@@ -1131,7 +1230,7 @@ func TestServer_UpdateOrg_DisableApprovals(t *testing.T) {
 	osds := mock_controllers.NewMockOrgSettingsDatastore(ctrl)
 
 	orgID := uuid.FromStringOrNil("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
-	s := controllers.NewServer(nil, uds, usds, ods, osds)
+	s := controllers.NewServer(nil, uds, usds, ods, osds, nil)
 
 	mockReply := &datastore.OrgInfo{
 		ID: orgID,
@@ -1182,7 +1281,7 @@ func TestServer_UpdateOrg_NoChangeInState(t *testing.T) {
 	osds := mock_controllers.NewMockOrgSettingsDatastore(ctrl)
 
 	orgID := uuid.FromStringOrNil("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
-	s := controllers.NewServer(nil, uds, usds, ods, osds)
+	s := controllers.NewServer(nil, uds, usds, ods, osds, nil)
 
 	mockReply := &datastore.OrgInfo{
 		ID:              orgID,
@@ -1216,7 +1315,7 @@ func TestServer_UpdateOrg_EnableApprovalsIsNull(t *testing.T) {
 	osds := mock_controllers.NewMockOrgSettingsDatastore(ctrl)
 
 	orgID := uuid.FromStringOrNil("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
-	s := controllers.NewServer(nil, uds, usds, ods, osds)
+	s := controllers.NewServer(nil, uds, usds, ods, osds, nil)
 
 	mockReply := &datastore.OrgInfo{
 		ID:              orgID,
@@ -1249,7 +1348,7 @@ func TestServer_UpdateOrg_DomainName(t *testing.T) {
 	osds := mock_controllers.NewMockOrgSettingsDatastore(ctrl)
 
 	orgID := uuid.FromStringOrNil("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
-	s := controllers.NewServer(nil, uds, usds, ods, osds)
+	s := controllers.NewServer(nil, uds, usds, ods, osds, nil)
 
 	mockReply := &datastore.OrgInfo{
 		ID:         orgID,
@@ -1298,7 +1397,7 @@ func TestServer_UpdateOrg_NilToEmptyDomainName(t *testing.T) {
 	osds := mock_controllers.NewMockOrgSettingsDatastore(ctrl)
 
 	orgID := uuid.FromStringOrNil("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
-	s := controllers.NewServer(nil, uds, usds, ods, osds)
+	s := controllers.NewServer(nil, uds, usds, ods, osds, nil)
 
 	mockReply := &datastore.OrgInfo{
 		ID:         orgID,
@@ -1348,7 +1447,7 @@ func TestServer_UpdateOrg_DomainName_EnableApprovals(t *testing.T) {
 	osds := mock_controllers.NewMockOrgSettingsDatastore(ctrl)
 
 	orgID := uuid.FromStringOrNil("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
-	s := controllers.NewServer(nil, uds, usds, ods, osds)
+	s := controllers.NewServer(nil, uds, usds, ods, osds, nil)
 
 	mockReply := &datastore.OrgInfo{
 		ID:              orgID,
@@ -1399,7 +1498,7 @@ func TestServer_UpdateOrg_RequestBlockedForUserOutsideOrg(t *testing.T) {
 	usds := mock_controllers.NewMockUserSettingsDatastore(ctrl)
 	osds := mock_controllers.NewMockOrgSettingsDatastore(ctrl)
 
-	s := controllers.NewServer(nil, uds, usds, ods, osds)
+	s := controllers.NewServer(nil, uds, usds, ods, osds, nil)
 	_, err := s.UpdateOrg(
 		CreateTestContext(),
 		&profilepb.UpdateOrgRequest{
@@ -1420,7 +1519,7 @@ func TestServer_GetUserAttributes(t *testing.T) {
 	usds := mock_controllers.NewMockUserSettingsDatastore(ctrl)
 	osds := mock_controllers.NewMockOrgSettingsDatastore(ctrl)
 
-	s := controllers.NewServer(nil, uds, usds, ods, osds)
+	s := controllers.NewServer(nil, uds, usds, ods, osds, nil)
 
 	userID := uuid.Must(uuid.NewV4())
 	tourSeen := true
@@ -1444,7 +1543,7 @@ func TestServer_SetUserAttributes(t *testing.T) {
 	usds := mock_controllers.NewMockUserSettingsDatastore(ctrl)
 	osds := mock_controllers.NewMockOrgSettingsDatastore(ctrl)
 
-	s := controllers.NewServer(nil, uds, usds, ods, osds)
+	s := controllers.NewServer(nil, uds, usds, ods, osds, nil)
 
 	userID := uuid.Must(uuid.NewV4())
 	tourSeen := true
@@ -1472,7 +1571,7 @@ func TestServer_GetUserSettings(t *testing.T) {
 	usds := mock_controllers.NewMockUserSettingsDatastore(ctrl)
 	osds := mock_controllers.NewMockOrgSettingsDatastore(ctrl)
 
-	s := controllers.NewServer(nil, uds, usds, ods, osds)
+	s := controllers.NewServer(nil, uds, usds, ods, osds, nil)
 
 	userID := uuid.Must(uuid.NewV4())
 	analyticsOptout := true
@@ -1496,7 +1595,7 @@ func TestServer_UpdateUserSettings(t *testing.T) {
 	usds := mock_controllers.NewMockUserSettingsDatastore(ctrl)
 	osds := mock_controllers.NewMockOrgSettingsDatastore(ctrl)
 
-	s := controllers.NewServer(nil, uds, usds, ods, osds)
+	s := controllers.NewServer(nil, uds, usds, ods, osds, nil)
 
 	userID := uuid.Must(uuid.NewV4())
 	analyticsOptout := true
@@ -1541,7 +1640,7 @@ func TestServer_GetUsersInOrg(t *testing.T) {
 	usds := mock_controllers.NewMockUserSettingsDatastore(ctrl)
 	osds := mock_controllers.NewMockOrgSettingsDatastore(ctrl)
 
-	s := controllers.NewServer(nil, uds, usds, ods, osds)
+	s := controllers.NewServer(nil, uds, usds, ods, osds, nil)
 
 	ods.EXPECT().
 		GetUsersInOrg(orgID).
@@ -1591,13 +1690,24 @@ func TestServer_AddOrgIDEConfig(t *testing.T) {
 	ods := mock_controllers.NewMockOrgDatastore(ctrl)
 	usds := mock_controllers.NewMockUserSettingsDatastore(ctrl)
 	osds := mock_controllers.NewMockOrgSettingsDatastore(ctrl)
+	ads := mock_controllers.NewMockAuditDatastore(ctrl)
 
-	s := controllers.NewServer(nil, uds, usds, ods, osds)
+	s := controllers.NewServer(nil, uds, usds, ods, osds, ads)
 
 	osds.EXPECT().
 		AddIDEConfig(orgID, &datastore.IDEConfig{Name: "test", Path: "test://path/{{symbol}}"}).
 		Return(nil)
 
+	ads.EXPECT().
+		Record(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, e *audit.Event) error {
+			assert.Equal(t, "org_ide_config.add", e.Action)
+			assert.Equal(t, "test", e.ResourceID)
+			assert.Equal(t, "", e.Before)
+			assert.JSONEq(t, `{"ide_name": "test", "path": "test://path/{{symbol}}"}`, e.After)
+			return nil
+		})
+
 	resp, err := s.AddOrgIDEConfig(ctx, &profilepb.AddOrgIDEConfigRequest{
 		OrgID: utils.ProtoFromUUID(orgID),
 		Config: &profilepb.IDEConfig{
@@ -1623,13 +1733,27 @@ func TestServer_DeleteOrgIDEConfig(t *testing.T) {
 	ods := mock_controllers.NewMockOrgDatastore(ctrl)
 	usds := mock_controllers.NewMockUserSettingsDatastore(ctrl)
 	osds := mock_controllers.NewMockOrgSettingsDatastore(ctrl)
+	ads := mock_controllers.NewMockAuditDatastore(ctrl)
+
+	s := controllers.NewServer(nil, uds, usds, ods, osds, ads)
 
-	s := controllers.NewServer(nil, uds, usds, ods, osds)
+	osds.EXPECT().
+		GetIDEConfig(orgID, "test").
+		Return(&datastore.IDEConfig{Name: "test", Path: "test://path/{{symbol}}"}, nil)
 
 	osds.EXPECT().
 		DeleteIDEConfig(orgID, "test").
 		Return(nil)
 
+	ads.EXPECT().
+		Record(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, e *audit.Event) error {
+			assert.Equal(t, "org_ide_config.delete", e.Action)
+			assert.JSONEq(t, `{"ide_name": "test", "path": "test://path/{{symbol}}"}`, e.Before)
+			assert.Equal(t, "", e.After)
+			return nil
+		})
+
 	resp, err := s.DeleteOrgIDEConfig(ctx, &profilepb.DeleteOrgIDEConfigRequest{
 		OrgID:   utils.ProtoFromUUID(orgID),
 		IDEName: "test",
@@ -1650,7 +1774,7 @@ func TestServer_GetOrgIDEConfigs_Single(t *testing.T) {
 	usds := mock_controllers.NewMockUserSettingsDatastore(ctrl)
 	osds := mock_controllers.NewMockOrgSettingsDatastore(ctrl)
 
-	s := controllers.NewServer(nil, uds, usds, ods, osds)
+	s := controllers.NewServer(nil, uds, usds, ods, osds, nil)
 
 	osds.EXPECT().
 		GetIDEConfig(orgID, "test").
@@ -1686,7 +1810,7 @@ func TestServer_GetOrgIDEConfigs_Multi(t *testing.T) {
 	usds := mock_controllers.NewMockUserSettingsDatastore(ctrl)
 	osds := mock_controllers.NewMockOrgSettingsDatastore(ctrl)
 
-	s := controllers.NewServer(nil, uds, usds, ods, osds)
+	s := controllers.NewServer(nil, uds, usds, ods, osds, nil)
 
 	osds.EXPECT().
 		GetIDEConfigs(orgID).
@@ -1731,7 +1855,7 @@ func TestServer_CreateInviteToken(t *testing.T) {
 	usds := mock_controllers.NewMockUserSettingsDatastore(ctrl)
 	osds := mock_controllers.NewMockOrgSettingsDatastore(ctrl)
 
-	s := controllers.NewServer(nil, uds, usds, ods, osds)
+	s := controllers.NewServer(nil, uds, usds, ods, osds, nil)
 
 	inviteSigningKey := "secret_jwt_key"
 	ods.EXPECT().
@@ -1765,7 +1889,7 @@ func TestServer_CreateInviteToken_NoSigningKey(t *testing.T) {
 	usds := mock_controllers.NewMockUserSettingsDatastore(ctrl)
 	osds := mock_controllers.NewMockOrgSettingsDatastore(ctrl)
 
-	s := controllers.NewServer(nil, uds, usds, ods, osds)
+	s := controllers.NewServer(nil, uds, usds, ods, osds, nil)
 
 	inviteSigningKey := "secret_jwt_key"
 	ods.EXPECT().
@@ -1802,7 +1926,7 @@ func TestServer_CreateInviteToken_BadOrg(t *testing.T) {
 	usds := mock_controllers.NewMockUserSettingsDatastore(ctrl)
 	osds := mock_controllers.NewMockOrgSettingsDatastore(ctrl)
 
-	s := controllers.NewServer(nil, uds, usds, ods, osds)
+	s := controllers.NewServer(nil, uds, usds, ods, osds, nil)
 
 	_, err := s.CreateInviteToken(ctx, &profilepb.CreateInviteTokenRequest{
 		OrgID: utils.ProtoFromUUID(uuid.Nil),
@@ -1822,7 +1946,7 @@ func TestServer_RevokeInvites(t *testing.T) {
 	usds := mock_controllers.NewMockUserSettingsDatastore(ctrl)
 	osds := mock_controllers.NewMockOrgSettingsDatastore(ctrl)
 
-	s := controllers.NewServer(nil, uds, usds, ods, osds)
+	s := controllers.NewServer(nil, uds, usds, ods, osds, nil)
 
 	ods.EXPECT().
 		CreateInviteSigningKey(orgID)
@@ -1841,7 +1965,7 @@ func TestServer_RevokeInvites_BadOrg(t *testing.T) {
 	usds := mock_controllers.NewMockUserSettingsDatastore(ctrl)
 	osds := mock_controllers.NewMockOrgSettingsDatastore(ctrl)
 
-	s := controllers.NewServer(nil, uds, usds, ods, osds)
+	s := controllers.NewServer(nil, uds, usds, ods, osds, nil)
 
 	_, err := s.RevokeAllInviteTokens(ctx, utils.ProtoFromUUID(uuid.Nil))
 	require.Error(t, err)
@@ -1859,7 +1983,7 @@ func TestServer_VerifyInvites_Good(t *testing.T) {
 	usds := mock_controllers.NewMockUserSettingsDatastore(ctrl)
 	osds := mock_controllers.NewMockOrgSettingsDatastore(ctrl)
 
-	s := controllers.NewServer(nil, uds, usds, ods, osds)
+	s := controllers.NewServer(nil, uds, usds, ods, osds, nil)
 
 	inviteSigningKey := "secret_jwt_key"
 	builder := jwt.NewBuilder().
@@ -1895,7 +2019,7 @@ func TestServer_VerifyInvites_Expired(t *testing.T) {
 	usds := mock_controllers.NewMockUserSettingsDatastore(ctrl)
 	osds := mock_controllers.NewMockOrgSettingsDatastore(ctrl)
 
-	s := controllers.NewServer(nil, uds, usds, ods, osds)
+	s := controllers.NewServer(nil, uds, usds, ods, osds, nil)
 
 	inviteSigningKey := "secret_jwt_key"
 	builder := jwt.NewBuilder().
@@ -1924,7 +2048,7 @@ func TestServer_VerifyInvites_BadSigningKey(t *testing.T) {
 	usds := mock_controllers.NewMockUserSettingsDatastore(ctrl)
 	osds := mock_controllers.NewMockOrgSettingsDatastore(ctrl)
 
-	s := controllers.NewServer(nil, uds, usds, ods, osds)
+	s := controllers.NewServer(nil, uds, usds, ods, osds, nil)
 
 	inviteSigningKey := "secret_jwt_key"
 	builder := jwt.NewBuilder().
@@ -1955,7 +2079,7 @@ func TestServer_VerifyInvites_BadOrg(t *testing.T) {
 	usds := mock_controllers.NewMockUserSettingsDatastore(ctrl)
 	osds := mock_controllers.NewMockOrgSettingsDatastore(ctrl)
 
-	s := controllers.NewServer(nil, uds, usds, ods, osds)
+	s := controllers.NewServer(nil, uds, usds, ods, osds, nil)
 
 	inviteSigningKey := "secret_jwt_key"
 	builder := jwt.NewBuilder().
@@ -1970,3 +2094,62 @@ func TestServer_VerifyInvites_BadOrg(t *testing.T) {
 	_, err = s.VerifyInviteToken(ctx, &profilepb.InviteToken{SignedClaims: string(signedClaims)})
 	require.Error(t, err)
 }
+
+func TestServer_GetAuditEvents(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ads := mock_controllers.NewMockAuditDatastore(ctrl)
+	s := controllers.NewServer(nil, nil, nil, nil, nil, ads)
+
+	orgID := uuid.FromStringOrNil("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+	eventID := uuid.Must(uuid.NewV4())
+	startTime := time.Unix(1000, 0).UTC()
+	eventTime := time.Unix(2000, 0).UTC()
+
+	ads.EXPECT().
+		ListEvents(gomock.Any(), &audit.Filter{
+			OrgID:        orgID,
+			StartTime:    startTime,
+			ResourceType: "deploy_key",
+		}).
+		Return([]*audit.Event{
+			{
+				ID:           eventID,
+				Time:         eventTime,
+				OrgID:        orgID,
+				ActorType:    audit.ActorTypeUser,
+				ActorID:      "6ba7b810-9dad-11d1-80b4-00c04fd430c9",
+				Action:       "deploy_key.delete",
+				ResourceType: "deploy_key",
+				ResourceID:   "key",
+				Before:       `{"desc": "test"}`,
+			},
+		}, nil)
+
+	startTimeProto, _ := types.TimestampProto(startTime)
+	resp, err := s.GetAuditEvents(CreateTestContext(), &profilepb.GetAuditEventsRequest{
+		OrgID:        utils.ProtoFromUUID(orgID),
+		StartTime:    startTimeProto,
+		ResourceType: "deploy_key",
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Events, 1)
+	eventTimeProto, _ := types.TimestampProto(eventTime)
+	assert.Equal(t, &profilepb.AuditEvent{
+		ID:           utils.ProtoFromUUID(eventID),
+		Time:         eventTimeProto,
+		OrgID:        utils.ProtoFromUUID(orgID),
+		ActorType:    audit.ActorTypeUser,
+		ActorID:      "6ba7b810-9dad-11d1-80b4-00c04fd430c9",
+		Action:       "deploy_key.delete",
+		ResourceType: "deploy_key",
+		ResourceID:   "key",
+		Before:       `{"desc": "test"}`,
+	}, resp.Events[0])
+
+	_, err = s.GetAuditEvents(CreateTestContext(), &profilepb.GetAuditEventsRequest{
+		OrgID: utils.ProtoFromUUIDStrOrNil("223e4567-e89b-12d3-a456-426655440000"),
+	})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
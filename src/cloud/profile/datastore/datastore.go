@@ -51,6 +51,7 @@ type UserInfo struct {
 	IsApproved       bool       `db:"is_approved"`
 	IdentityProvider string     `db:"identity_provider"`
 	AuthProviderID   string     `db:"auth_provider_id"`
+	OrgRole          string     `db:"org_role"`
 }
 
 // OrgInfo tracks information about an organization.
@@ -126,7 +127,7 @@ func (d *Datastore) CreateUser(userInfo *UserInfo) (uuid.UUID, error) {
 
 // GetUser gets user information by user ID.
 func (d *Datastore) GetUser(id uuid.UUID) (*UserInfo, error) {
-	query := `SELECT id, org_id, first_name, last_name, email, profile_picture, is_approved, identity_provider, auth_provider_id FROM users WHERE id=$1`
+	query := `SELECT id, org_id, first_name, last_name, email, profile_picture, is_approved, identity_provider, auth_provider_id, org_role FROM users WHERE id=$1`
 	rows, err := d.db.Queryx(query, id)
 	if err != nil {
 		return nil, err
@@ -315,7 +316,7 @@ func (d *Datastore) CreateInviteSigningKey(id uuid.UUID) (string, error) {
 
 // GetUserByEmail gets user info by email.
 func (d *Datastore) GetUserByEmail(email string) (*UserInfo, error) {
-	query := `SELECT id, org_id, first_name, last_name, email, profile_picture, is_approved, identity_provider, auth_provider_id FROM users WHERE email=$1`
+	query := `SELECT id, org_id, first_name, last_name, email, profile_picture, is_approved, identity_provider, auth_provider_id, org_role FROM users WHERE email=$1`
 	rows, err := d.db.Queryx(query, email)
 	if err != nil {
 		return nil, err
@@ -332,7 +333,7 @@ func (d *Datastore) GetUserByEmail(email string) (*UserInfo, error) {
 
 // GetUserByAuthProviderID gets userinfo by auth provider id.
 func (d *Datastore) GetUserByAuthProviderID(id string) (*UserInfo, error) {
-	query := `SELECT id, org_id, first_name, last_name, email, profile_picture, is_approved, identity_provider, auth_provider_id FROM users WHERE auth_provider_id=$1`
+	query := `SELECT id, org_id, first_name, last_name, email, profile_picture, is_approved, identity_provider, auth_provider_id, org_role FROM users WHERE auth_provider_id=$1`
 	rows, err := d.db.Queryx(query, id)
 	if err != nil {
 		return nil, err
@@ -369,7 +370,7 @@ func (d *Datastore) DeleteOrgAndUsers(orgID uuid.UUID) error {
 }
 
 func (d *Datastore) createUserUsingTxn(txn *sqlx.Tx, userInfo *UserInfo) (uuid.UUID, error) {
-	query := `INSERT INTO users (org_id, first_name, last_name, email, is_approved, identity_provider, auth_provider_id) VALUES (:org_id, :first_name, :last_name, :email, :is_approved, :identity_provider, :auth_provider_id) RETURNING id`
+	query := `INSERT INTO users (org_id, first_name, last_name, email, is_approved, identity_provider, auth_provider_id, org_role) VALUES (:org_id, :first_name, :last_name, :email, :is_approved, :identity_provider, :auth_provider_id, COALESCE(NULLIF(:org_role, ''), 'member')) RETURNING id`
 	rows, err := txn.NamedQuery(query, userInfo)
 	if err != nil {
 		return uuid.Nil, err
@@ -420,7 +421,7 @@ func (d *Datastore) createOrgUsingTxn(txn *sqlx.Tx, orgInfo *OrgInfo) (uuid.UUID
 
 // GetUsersInOrg gets all users in the given org.
 func (d *Datastore) GetUsersInOrg(orgID uuid.UUID) ([]*UserInfo, error) {
-	query := `SELECT id, org_id, first_name, last_name, email, profile_picture, is_approved, identity_provider, auth_provider_id FROM users WHERE org_id=$1 order by created_at desc`
+	query := `SELECT id, org_id, first_name, last_name, email, profile_picture, is_approved, identity_provider, auth_provider_id, org_role FROM users WHERE org_id=$1 order by created_at desc`
 	rows, err := d.db.Queryx(query, orgID)
 	if err != nil {
 		return nil, err
@@ -461,7 +462,10 @@ func (d *Datastore) NumUsersInOrg(orgID uuid.UUID) (int, error) {
 
 // UpdateUser updates the user in the database.
 func (d *Datastore) UpdateUser(userInfo *UserInfo) error {
-	query := `UPDATE users SET profile_picture = :profile_picture, is_approved = :is_approved, org_id = :org_id WHERE id = :id`
+	query := `UPDATE users SET profile_picture = :profile_picture, is_approved = :is_approved, org_id = :org_id, org_role = COALESCE(NULLIF(:org_role, ''), org_role),
+		first_name = COALESCE(NULLIF(:first_name, ''), first_name), last_name = COALESCE(NULLIF(:last_name, ''), last_name),
+		identity_provider = COALESCE(NULLIF(:identity_provider, ''), identity_provider), auth_provider_id = COALESCE(NULLIF(:auth_provider_id, ''), auth_provider_id)
+		WHERE id = :id`
 	_, err := d.db.NamedExec(query, userInfo)
 	return err
 }
@@ -112,6 +112,8 @@ func TestDatastore(t *testing.T) {
 		assert.Equal(t, userInfo.LastName, userInfoFetched.LastName)
 		assert.Equal(t, userInfo.Email, userInfoFetched.Email)
 		assert.Equal(t, userInfo.AuthProviderID, userInfoFetched.AuthProviderID)
+		// Users are members of their org unless another role is given.
+		assert.Equal(t, "member", userInfoFetched.OrgRole)
 
 		// Check value in DB.
 		query := `SELECT * from user_attributes WHERE user_id=$1`
@@ -494,6 +496,56 @@ func TestDatastore(t *testing.T) {
 		require.NotNil(t, userInfoFetched)
 		assert.Equal(t, "http://somepicture", *userInfoFetched.ProfilePicture)
 		assert.Equal(t, false, userInfoFetched.IsApproved)
+		assert.Equal(t, "first", userInfoFetched.FirstName)
+		assert.Equal(t, "last", userInfoFetched.LastName)
+	})
+
+	t.Run("update user identity", func(t *testing.T) {
+		mustLoadTestData(db)
+		d := datastore.NewDatastore(db, "test_key")
+
+		userID := uuid.FromStringOrNil("123e4567-e89b-12d3-a456-426655440001")
+		userInfo, err := d.GetUser(userID)
+		require.NoError(t, err)
+		userInfo.IdentityProvider = "github"
+		userInfo.AuthProviderID = "github|123"
+		require.NoError(t, d.UpdateUser(userInfo))
+
+		userInfoFetched, err := d.GetUser(userID)
+		require.NoError(t, err)
+		assert.Equal(t, "github", userInfoFetched.IdentityProvider)
+		assert.Equal(t, "github|123", userInfoFetched.AuthProviderID)
+
+		// Empty values leave the identity unchanged.
+		userInfo.IdentityProvider = ""
+		userInfo.AuthProviderID = ""
+		require.NoError(t, d.UpdateUser(userInfo))
+		userInfoFetched, err = d.GetUser(userID)
+		require.NoError(t, err)
+		assert.Equal(t, "github", userInfoFetched.IdentityProvider)
+		assert.Equal(t, "github|123", userInfoFetched.AuthProviderID)
+	})
+
+	t.Run("update user role", func(t *testing.T) {
+		mustLoadTestData(db)
+		d := datastore.NewDatastore(db, "test_key")
+
+		userID := uuid.FromStringOrNil("123e4567-e89b-12d3-a456-426655440001")
+		userInfo, err := d.GetUser(userID)
+		require.NoError(t, err)
+		userInfo.OrgRole = "viewer"
+		require.NoError(t, d.UpdateUser(userInfo))
+
+		userInfoFetched, err := d.GetUser(userID)
+		require.NoError(t, err)
+		assert.Equal(t, "viewer", userInfoFetched.OrgRole)
+
+		// An empty role leaves the role unchanged.
+		userInfo.OrgRole = ""
+		require.NoError(t, d.UpdateUser(userInfo))
+		userInfoFetched, err = d.GetUser(userID)
+		require.NoError(t, err)
+		assert.Equal(t, "viewer", userInfoFetched.OrgRole)
 	})
 
 	t.Run("update user org", func(t *testing.T) {
@@ -31,6 +31,7 @@ import (
 	"px.dev/pixie/src/cloud/profile/profileenv"
 	"px.dev/pixie/src/cloud/profile/profilepb"
 	"px.dev/pixie/src/cloud/profile/schema"
+	"px.dev/pixie/src/cloud/shared/audit"
 	"px.dev/pixie/src/cloud/shared/pgmigrate"
 	"px.dev/pixie/src/shared/services"
 	"px.dev/pixie/src/shared/services/healthz"
@@ -58,6 +59,10 @@ func main() {
 	if err != nil {
 		log.WithError(err).Fatal("Failed to apply migrations")
 	}
+	err = audit.PerformMigrations(db)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to apply audit migrations")
+	}
 
 	dbKey := viper.GetString("database_key")
 	if dbKey == "" {
@@ -70,7 +75,7 @@ func main() {
 		log.WithError(err).Fatal("Failed to set up profileenv")
 	}
 
-	svr := controllers.NewServer(env, datastore, datastore, datastore, datastore)
+	svr := controllers.NewServer(env, datastore, datastore, datastore, datastore, audit.NewDatastore(db))
 
 	serverOpts := &server.GRPCServerOptions{
 		DisableAuth: map[string]bool{
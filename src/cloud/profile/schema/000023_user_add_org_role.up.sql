@@ -0,0 +1,8 @@
+ALTER TABLE users
+ADD COLUMN org_role varchar(20) NOT NULL DEFAULT 'member';
+
+-- Existing users could previously do everything in their org, so they keep admin access. The
+-- first user in each org, who created it, becomes its owner.
+UPDATE users SET org_role = 'admin' WHERE org_id IS NOT NULL;
+UPDATE users SET org_role = 'owner'
+WHERE id IN (SELECT DISTINCT ON (org_id) id FROM users WHERE org_id IS NOT NULL ORDER BY org_id, created_at);
@@ -0,0 +1,215 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+// Package audit records the administrative actions taken in an org, such as creating API keys or
+// changing plugin configs, so that they can be reviewed later.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	bindata "github.com/golang-migrate/migrate/source/go_bindata"
+	"github.com/jmoiron/sqlx"
+	log "github.com/sirupsen/logrus"
+
+	"px.dev/pixie/src/cloud/shared/audit/schema"
+	"px.dev/pixie/src/cloud/shared/pgmigrate"
+	"px.dev/pixie/src/shared/services/authcontext"
+	srvutils "px.dev/pixie/src/shared/services/utils"
+)
+
+// The types of actors which can perform an audited action.
+const (
+	ActorTypeUser    = "user"
+	ActorTypeAPIKey  = "api_key"
+	ActorTypeService = "service"
+	ActorTypeCluster = "cluster"
+	ActorTypeUnknown = "unknown"
+)
+
+// defaultListLimit is the maximum number of events returned by ListEvents when no limit is given.
+const defaultListLimit = 100
+
+// Event is a single audited action.
+type Event struct {
+	ID   uuid.UUID `db:"id"`
+	Time time.Time `db:"created_at"`
+	// OrgID is the org which owns the resource that was acted upon.
+	OrgID     uuid.UUID `db:"org_id"`
+	ActorType string    `db:"actor_type"`
+	ActorID   string    `db:"actor_id"`
+	// Action describes what was done, for example "api_key.create".
+	Action       string `db:"action"`
+	ResourceType string `db:"resource_type"`
+	ResourceID   string `db:"resource_id"`
+	// Before and After are the JSON encoded states of the resource around the action. They are empty
+	// when the resource did not exist before, or no longer exists after, the action.
+	Before string `db:"before"`
+	After  string `db:"after"`
+}
+
+// NewEvent creates an event for an action taken on a resource by the caller in ctx. before and after
+// are encoded as JSON, and may be nil.
+func NewEvent(ctx context.Context, orgID uuid.UUID, action string, resourceType string, resourceID string, before interface{}, after interface{}) *Event {
+	actorType, actorID := actorFromContext(ctx)
+	return &Event{
+		OrgID:        orgID,
+		ActorType:    actorType,
+		ActorID:      actorID,
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		Before:       toJSON(before),
+		After:        toJSON(after),
+	}
+}
+
+func actorFromContext(ctx context.Context) (string, string) {
+	sCtx, err := authcontext.FromContext(ctx)
+	if err != nil || sCtx.Claims == nil {
+		return ActorTypeUnknown, ""
+	}
+	switch srvutils.GetClaimsType(sCtx.Claims) {
+	case srvutils.UserClaimType:
+		if sCtx.Claims.GetUserClaims().IsAPIUser {
+			// Tokens issued before the key's ID was added to the claims only identify the key's owner.
+			if keyID := sCtx.Claims.GetUserClaims().APIKeyID; keyID != "" {
+				return ActorTypeAPIKey, keyID
+			}
+			return ActorTypeAPIKey, sCtx.Claims.GetUserClaims().UserID
+		}
+		return ActorTypeUser, sCtx.Claims.GetUserClaims().UserID
+	case srvutils.ServiceClaimType:
+		return ActorTypeService, sCtx.Claims.GetServiceClaims().ServiceID
+	case srvutils.ClusterClaimType:
+		return ActorTypeCluster, sCtx.Claims.GetClusterClaims().ClusterID
+	default:
+		return ActorTypeUnknown, sCtx.Claims.Subject
+	}
+}
+
+func toJSON(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		log.WithError(err).Error("Failed to encode audit event state")
+		return ""
+	}
+	return string(b)
+}
+
+// Recorder persists audit events.
+type Recorder interface {
+	Record(ctx context.Context, e *Event) error
+}
+
+// Emit records the event using r. Events are emitted once the action has already taken effect, so
+// failures are logged rather than returned to the caller. A nil recorder drops the event.
+func Emit(ctx context.Context, r Recorder, e *Event) {
+	if r == nil {
+		return
+	}
+	if err := r.Record(ctx, e); err != nil {
+		log.WithError(err).WithField("action", e.Action).Error("Failed to record audit event")
+	}
+}
+
+// Filter selects the events returned by ListEvents. Unset fields don't restrict the results.
+type Filter struct {
+	OrgID        uuid.UUID
+	StartTime    time.Time
+	EndTime      time.Time
+	ActorID      string
+	ResourceType string
+	ResourceID   string
+	// Limit is the maximum number of events to return. Defaults to 100.
+	Limit int
+}
+
+// Datastore stores audit events in Postgres.
+type Datastore struct {
+	db *sqlx.DB
+}
+
+// NewDatastore creates a new audit event Datastore.
+func NewDatastore(db *sqlx.DB) *Datastore {
+	return &Datastore{db: db}
+}
+
+// PerformMigrations creates or updates the audit tables. Each service which records events runs
+// them, since the tables are shared.
+func PerformMigrations(db *sqlx.DB) error {
+	return pgmigrate.PerformMigrationsUsingBindata(db, "audit_migrations",
+		bindata.Resource(schema.AssetNames(), schema.Asset))
+}
+
+// Record stores the event.
+func (d *Datastore) Record(ctx context.Context, e *Event) error {
+	query := `INSERT INTO audit_events (org_id, actor_type, actor_id, action, resource_type, resource_id, before, after)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, '')::jsonb, NULLIF($8, '')::jsonb)
+		RETURNING id, created_at`
+	return d.db.QueryRowxContext(ctx, query, e.OrgID, e.ActorType, e.ActorID, e.Action, e.ResourceType, e.ResourceID,
+		e.Before, e.After).Scan(&e.ID, &e.Time)
+}
+
+// ListEvents returns the events matching the filter, newest first.
+func (d *Datastore) ListEvents(ctx context.Context, f *Filter) ([]*Event, error) {
+	conds := []string{"org_id = $1"}
+	args := []interface{}{f.OrgID}
+	addCond := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if !f.StartTime.IsZero() {
+		addCond("created_at >= $%d", f.StartTime)
+	}
+	if !f.EndTime.IsZero() {
+		addCond("created_at < $%d", f.EndTime)
+	}
+	if f.ActorID != "" {
+		addCond("actor_id = $%d", f.ActorID)
+	}
+	if f.ResourceType != "" {
+		addCond("resource_type = $%d", f.ResourceType)
+	}
+	if f.ResourceID != "" {
+		addCond("resource_id = $%d", f.ResourceID)
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	args = append(args, limit)
+
+	query := fmt.Sprintf(`SELECT id, created_at, org_id, actor_type, actor_id, action, resource_type, resource_id,
+		COALESCE(before::text, '') AS before, COALESCE(after::text, '') AS after
+		FROM audit_events WHERE %s ORDER BY created_at DESC LIMIT $%d`, strings.Join(conds, " AND "), len(args))
+
+	events := make([]*Event, 0)
+	if err := d.db.SelectContext(ctx, &events, query, args...); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
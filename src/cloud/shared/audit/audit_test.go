@@ -0,0 +1,152 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package audit_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	bindata "github.com/golang-migrate/migrate/source/go_bindata"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"px.dev/pixie/src/cloud/shared/audit"
+	"px.dev/pixie/src/cloud/shared/audit/schema"
+	"px.dev/pixie/src/shared/services/authcontext"
+	"px.dev/pixie/src/shared/services/pgtest"
+	srvutils "px.dev/pixie/src/shared/services/utils"
+)
+
+const (
+	testOrgID  = "123e4567-e89b-12d3-a456-426655440000"
+	testUserID = "123e4567-e89b-12d3-a456-426655440001"
+	testKeyID  = "123e4567-e89b-12d3-a456-426655440002"
+)
+
+func TestMain(m *testing.M) {
+	err := testMain(m)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Got error: %v\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+var db *sqlx.DB
+
+func testMain(m *testing.M) error {
+	s := bindata.Resource(schema.AssetNames(), schema.Asset)
+	testDB, teardown, err := pgtest.SetupTestDB(s)
+	if err != nil {
+		return fmt.Errorf("failed to start test database: %w", err)
+	}
+
+	defer teardown()
+	db = testDB
+
+	if c := m.Run(); c != 0 {
+		return fmt.Errorf("some tests failed with code: %d", c)
+	}
+	return nil
+}
+
+func createTestContext(apiUser bool) context.Context {
+	sCtx := authcontext.New()
+	if apiUser {
+		sCtx.Claims = srvutils.GenerateJWTForAPIUser(testUserID, testOrgID, time.Now().Add(time.Hour), "withpixie.ai")
+		sCtx.Claims.GetUserClaims().APIKeyID = testKeyID
+	} else {
+		sCtx.Claims = srvutils.GenerateJWTForUser(testUserID, testOrgID, "test@test.com", time.Now().Add(time.Hour), "withpixie.ai")
+	}
+	return authcontext.NewContext(context.Background(), sCtx)
+}
+
+type testResource struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+}
+
+func TestNewEvent_Actor(t *testing.T) {
+	orgID := uuid.FromStringOrNil(testOrgID)
+
+	e := audit.NewEvent(createTestContext(false), orgID, "plugin.update", "plugin", "test-plugin", nil, nil)
+	assert.Equal(t, audit.ActorTypeUser, e.ActorType)
+	assert.Equal(t, testUserID, e.ActorID)
+
+	// Actions taken with an API key are attributed to the key, not to its owner.
+	e = audit.NewEvent(createTestContext(true), orgID, "plugin.update", "plugin", "test-plugin", nil, nil)
+	assert.Equal(t, audit.ActorTypeAPIKey, e.ActorType)
+	assert.Equal(t, testKeyID, e.ActorID)
+
+	sCtx := authcontext.New()
+	sCtx.Claims = srvutils.GenerateJWTForAPIUser(testUserID, testOrgID, time.Now().Add(time.Hour), "withpixie.ai")
+	e = audit.NewEvent(authcontext.NewContext(context.Background(), sCtx), orgID, "plugin.update", "plugin", "test-plugin", nil, nil)
+	assert.Equal(t, audit.ActorTypeAPIKey, e.ActorType)
+	assert.Equal(t, testUserID, e.ActorID)
+}
+
+func TestDatastore_RecordAndList(t *testing.T) {
+	db.MustExec(`DELETE FROM audit_events`)
+	d := audit.NewDatastore(db)
+	orgID := uuid.FromStringOrNil(testOrgID)
+	otherOrgID := uuid.Must(uuid.NewV4())
+
+	e := audit.NewEvent(createTestContext(false), orgID, "plugin.update", "plugin", "test-plugin",
+		&testResource{Name: "test-plugin"}, &testResource{Name: "test-plugin", Enabled: true})
+	require.NoError(t, d.Record(context.Background(), e))
+	assert.NotEqual(t, uuid.Nil, e.ID)
+
+	require.NoError(t, d.Record(context.Background(),
+		audit.NewEvent(createTestContext(true), orgID, "deploy_key.delete", "deploy_key", "key-1", nil, nil)))
+	require.NoError(t, d.Record(context.Background(),
+		audit.NewEvent(context.Background(), otherOrgID, "deploy_key.delete", "deploy_key", "key-2", nil, nil)))
+
+	events, err := d.ListEvents(context.Background(), &audit.Filter{OrgID: orgID})
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, "deploy_key.delete", events[0].Action)
+	assert.Equal(t, audit.ActorTypeAPIKey, events[0].ActorType)
+	assert.Equal(t, testKeyID, events[0].ActorID)
+	assert.Equal(t, "", events[0].Before)
+
+	assert.Equal(t, "plugin.update", events[1].Action)
+	assert.Equal(t, audit.ActorTypeUser, events[1].ActorType)
+	assert.Equal(t, testUserID, events[1].ActorID)
+	assert.JSONEq(t, `{"name": "test-plugin", "enabled": false}`, events[1].Before)
+	assert.JSONEq(t, `{"name": "test-plugin", "enabled": true}`, events[1].After)
+
+	events, err = d.ListEvents(context.Background(), &audit.Filter{OrgID: orgID, ResourceType: "plugin"})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "test-plugin", events[0].ResourceID)
+
+	events, err = d.ListEvents(context.Background(), &audit.Filter{OrgID: orgID, StartTime: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	assert.Len(t, events, 0)
+
+	events, err = d.ListEvents(context.Background(), &audit.Filter{OrgID: otherOrgID})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, audit.ActorTypeUnknown, events[0].ActorType)
+}
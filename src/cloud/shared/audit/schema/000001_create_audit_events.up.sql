@@ -0,0 +1,20 @@
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+
+CREATE TABLE audit_events (
+  id UUID UNIQUE DEFAULT uuid_generate_v4(),
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  org_id UUID NOT NULL,
+  actor_type VARCHAR(64) NOT NULL,
+  actor_id VARCHAR(256) NOT NULL,
+  action VARCHAR(256) NOT NULL,
+  resource_type VARCHAR(256) NOT NULL,
+  resource_id VARCHAR(256) NOT NULL,
+  before JSONB,
+  after JSONB,
+
+  PRIMARY KEY (id)
+);
+
+CREATE INDEX audit_events_org_time_idx ON audit_events (org_id, created_at);
+CREATE INDEX audit_events_actor_idx ON audit_events (org_id, actor_id);
+CREATE INDEX audit_events_resource_idx ON audit_events (org_id, resource_type, resource_id);
@@ -0,0 +1,127 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gofrs/uuid"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"px.dev/pixie/src/cloud/shared/audit"
+	"px.dev/pixie/src/cloud/vzmgr/vzmgrpb"
+	"px.dev/pixie/src/utils"
+)
+
+// maxClusterTags is the maximum number of tags that a cluster can have.
+const maxClusterTags = 64
+
+// clusterTagsAuditState is the state of a Vizier recorded in the audit events for tag updates.
+type clusterTagsAuditState struct {
+	Tags map[string]string `json:"tags"`
+}
+
+// validateClusterTags checks that the tags are valid Kubernetes labels, so that they can be
+// matched by label selectors.
+func validateClusterTags(tags map[string]string) error {
+	for k, v := range tags {
+		if errs := validation.IsQualifiedName(k); len(errs) > 0 {
+			return status.Errorf(codes.InvalidArgument, "invalid tag key %q: %s", k, strings.Join(errs, "; "))
+		}
+		if errs := validation.IsValidLabelValue(v); len(errs) > 0 {
+			return status.Errorf(codes.InvalidArgument, "invalid value for tag %q: %s", k, strings.Join(errs, "; "))
+		}
+	}
+	return nil
+}
+
+// parseTagSelector parses a label selector on cluster tags. The empty selector matches all clusters.
+func parseTagSelector(selector string) (labels.Selector, error) {
+	sel, err := labels.Parse(selector)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid selector: %s", err.Error())
+	}
+	return sel, nil
+}
+
+// UpdateVizierTags adds, updates or removes the tags of the given Vizier.
+func (s *Server) UpdateVizierTags(ctx context.Context, req *vzmgrpb.UpdateVizierTagsRequest) (*vzmgrpb.UpdateVizierTagsResponse, error) {
+	if err := s.validateOrgOwnsCluster(ctx, req.VizierID); err != nil {
+		return nil, err
+	}
+	if err := validateClusterTags(req.Tags); err != nil {
+		return nil, err
+	}
+	for _, k := range req.RemovedTags {
+		if _, ok := req.Tags[k]; ok {
+			return nil, status.Errorf(codes.InvalidArgument, "tag %q can't be both updated and removed", k)
+		}
+	}
+
+	vizierID := utils.UUIDFromProtoOrNil(req.VizierID)
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to update tags")
+	}
+	defer tx.Rollback()
+
+	var orgID uuid.UUID
+	var prevTags ClusterTags
+	query := `SELECT org_id, tags FROM vizier_cluster WHERE id=$1 FOR UPDATE`
+	err = tx.QueryRowxContext(ctx, query, vizierID).Scan(&orgID, &prevTags)
+	if err != nil {
+		log.WithError(err).Error("Failed to fetch cluster tags")
+		return nil, status.Error(codes.Internal, "failed to update tags")
+	}
+
+	tags := make(ClusterTags, len(prevTags)+len(req.Tags))
+	for k, v := range prevTags {
+		tags[k] = v
+	}
+	for _, k := range req.RemovedTags {
+		delete(tags, k)
+	}
+	for k, v := range req.Tags {
+		tags[k] = v
+	}
+	if len(tags) > maxClusterTags {
+		return nil, status.Errorf(codes.InvalidArgument, "clusters can have at most %d tags", maxClusterTags)
+	}
+
+	query = `UPDATE vizier_cluster SET tags=$1 WHERE id=$2`
+	_, err = tx.ExecContext(ctx, query, tags, vizierID)
+	if err != nil {
+		log.WithError(err).Error("Failed to update cluster tags")
+		return nil, status.Error(codes.Internal, "failed to update tags")
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, status.Error(codes.Internal, "failed to update tags")
+	}
+
+	audit.Emit(ctx, s.audit, audit.NewEvent(ctx, orgID, "cluster.update_tags", clusterAuditResourceType, vizierID.String(),
+		&clusterTagsAuditState{Tags: prevTags}, &clusterTagsAuditState{Tags: tags}))
+
+	return &vzmgrpb.UpdateVizierTagsResponse{Tags: tags}, nil
+}
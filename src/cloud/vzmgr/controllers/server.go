@@ -41,8 +41,10 @@ import (
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"gopkg.in/segmentio/analytics-go.v3"
+	"k8s.io/apimachinery/pkg/labels"
 
 	"px.dev/pixie/src/api/proto/uuidpb"
+	"px.dev/pixie/src/cloud/shared/audit"
 	"px.dev/pixie/src/cloud/shared/messages"
 	"px.dev/pixie/src/cloud/shared/messagespb"
 	"px.dev/pixie/src/cloud/shared/vzshard"
@@ -62,6 +64,9 @@ const SaltLength int = 10
 // DefaultProjectName is the default project name to use for a vizier cluster that is created if none if provided.
 const DefaultProjectName = "default"
 
+// clusterAuditResourceType is the resource type of audit events for Vizier clusters.
+const clusterAuditResourceType = "cluster"
+
 // HandleNATSMessageFunc is the signature for a NATS message handler.
 type HandleNATSMessageFunc func(*cvmsgspb.V2CMessage)
 
@@ -71,6 +76,7 @@ type Server struct {
 	dbKey   string
 	nc      *nats.Conn
 	updater VzUpdater
+	audit   audit.Recorder
 
 	done chan struct{}
 	once sync.Once
@@ -91,6 +97,7 @@ func New(db *sqlx.DB, dbKey string, nc *nats.Conn, updater VzUpdater) *Server {
 		dbKey:   dbKey,
 		nc:      nc,
 		updater: updater,
+		audit:   audit.NewDatastore(db),
 		done:    make(chan struct{}),
 	}
 
@@ -170,7 +177,8 @@ func (s vizierStatus) ToProto() cvmsgspb.VizierStatus {
 	return cvmsgspb.VizierStatus(s)
 }
 
-func validateOrgID(ctx context.Context, providedOrgIDPB *uuidpb.UUID) error {
+// ValidateOrgID checks that the org ID is set, and is the org of the caller.
+func ValidateOrgID(ctx context.Context, providedOrgIDPB *uuidpb.UUID) error {
 	sCtx, err := authcontext.FromContext(ctx)
 	if err != nil {
 		return err
@@ -188,6 +196,12 @@ func validateOrgID(ctx context.Context, providedOrgIDPB *uuidpb.UUID) error {
 }
 
 func (s *Server) validateOrgOwnsCluster(ctx context.Context, clusterID *uuidpb.UUID) error {
+	return ValidateOrgOwnsCluster(ctx, s.db, clusterID)
+}
+
+// ValidateOrgOwnsCluster checks that the caller may access the cluster, and that the cluster belongs
+// to the org of the caller.
+func ValidateOrgOwnsCluster(ctx context.Context, db *sqlx.DB, clusterID *uuidpb.UUID) error {
 	clusterUUID := utils.UUIDFromProtoOrNil(clusterID)
 	if clusterUUID == uuid.Nil {
 		return status.Error(codes.InvalidArgument, "invalid cluster id")
@@ -198,11 +212,14 @@ func (s *Server) validateOrgOwnsCluster(ctx context.Context, clusterID *uuidpb.U
 		return err
 	}
 	orgIDstr := sCtx.Claims.GetUserClaims().OrgID
+	if !jwtutils.CanAccessCluster(sCtx.Claims, clusterUUID.String()) {
+		return status.Error(codes.PermissionDenied, "API key is not allowed to access cluster")
+	}
 
 	query := `SELECT EXISTS(SELECT 1 FROM vizier_cluster WHERE id=$1 AND org_id=$2)`
 
 	var exists bool
-	err = s.db.QueryRow(query, clusterUUID, orgIDstr).Scan(&exists)
+	err = db.QueryRow(query, clusterUUID, orgIDstr).Scan(&exists)
 	if err == sql.ErrNoRows || !exists {
 		return status.Error(codes.NotFound, "invalid cluster ID for org")
 	}
@@ -216,15 +233,28 @@ func (s *Server) CreateVizierCluster(ctx context.Context, req *vzmgrpb.CreateViz
 
 // GetViziersByOrg gets a list of viziers by organization.
 func (s *Server) GetViziersByOrg(ctx context.Context, orgID *uuidpb.UUID) (*vzmgrpb.GetViziersByOrgResponse, error) {
-	if err := validateOrgID(ctx, orgID); err != nil {
+	return s.getViziersByOrg(ctx, orgID, labels.Everything())
+}
+
+// GetViziersBySelector gets a list of the viziers in an organization whose tags match the selector.
+func (s *Server) GetViziersBySelector(ctx context.Context, req *vzmgrpb.GetViziersBySelectorRequest) (*vzmgrpb.GetViziersByOrgResponse, error) {
+	sel, err := parseTagSelector(req.Selector)
+	if err != nil {
+		return nil, err
+	}
+	return s.getViziersByOrg(ctx, req.OrgID, sel)
+}
+
+func (s *Server) getViziersByOrg(ctx context.Context, orgID *uuidpb.UUID, sel labels.Selector) (*vzmgrpb.GetViziersByOrgResponse, error) {
+	if err := ValidateOrgID(ctx, orgID); err != nil {
 		return nil, err
 	}
-	query := `SELECT id from vizier_cluster WHERE org_id=$1`
+	query := `SELECT id, tags from vizier_cluster WHERE org_id=$1`
 	parsedID := utils.UUIDFromProtoOrNil(orgID)
 	if parsedID == uuid.Nil {
 		return nil, status.Error(codes.InvalidArgument, "invalid org id")
 	}
-	rows, err := s.db.Queryx(query, utils.UUIDFromProtoOrNil(orgID))
+	rows, err := s.db.Queryx(query, parsedID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return &vzmgrpb.GetViziersByOrgResponse{VizierIDs: nil}, nil
@@ -236,10 +266,14 @@ func (s *Server) GetViziersByOrg(ctx context.Context, orgID *uuidpb.UUID) (*vzmg
 	ids := []*uuidpb.UUID{}
 	for rows.Next() {
 		var id uuid.UUID
-		err = rows.Scan(&id)
+		var tags ClusterTags
+		err = rows.Scan(&id, &tags)
 		if err != nil {
 			return nil, status.Error(codes.Internal, "failed to read ids")
 		}
+		if !sel.Matches(labels.Set(tags)) {
+			continue
+		}
 		ids = append(ids, utils.ProtoFromUUID(id))
 	}
 	return &vzmgrpb.GetViziersByOrgResponse{VizierIDs: ids}, nil
@@ -263,6 +297,7 @@ type VizierInfo struct {
 	OrgID                         uuid.UUID     `db:"org_id"`
 	PrevStatus                    *vizierStatus `db:"prev_status"`
 	PrevStatusTime                *time.Time    `db:"prev_status_time"`
+	Tags                          ClusterTags   `db:"tags"`
 }
 
 func vizierInfoToProto(vzInfo VizierInfo) *cvmsgspb.VizierInfo {
@@ -322,6 +357,7 @@ func vizierInfoToProto(vzInfo VizierInfo) *cvmsgspb.VizierInfo {
 		NumInstrumentedNodes:          vzInfo.NumInstrumentedNodes,
 		PreviousStatus:                prevStatus,
 		PreviousStatusTime:            prevStatusTime,
+		Tags:                          vzInfo.Tags,
 	}
 }
 
@@ -336,6 +372,10 @@ func (s *Server) GetVizierInfos(ctx context.Context, req *vzmgrpb.GetVizierInfos
 	if len(req.VizierIDs) == 0 {
 		return &vzmgrpb.GetVizierInfosResponse{}, nil
 	}
+	sel, err := parseTagSelector(req.Selector)
+	if err != nil {
+		return nil, err
+	}
 
 	ids := make([]uuid.UUID, len(req.VizierIDs))
 	for i, id := range req.VizierIDs {
@@ -345,7 +385,7 @@ func (s *Server) GetVizierInfos(ctx context.Context, req *vzmgrpb.GetVizierInfos
 	strQuery := `SELECT i.vizier_cluster_id, c.cluster_uid, c.cluster_name, i.cluster_version, i.operator_version, i.vizier_version,
 			  c.org_id, i.status, (EXTRACT(EPOCH FROM age(now(), i.last_heartbeat))*1E9)::bigint as last_heartbeat,
               i.control_plane_pod_statuses, i.unhealthy_data_plane_pod_statuses,
-							i.num_nodes, i.num_instrumented_nodes, i.status_message, i.prev_status, i.prev_status_time
+							i.num_nodes, i.num_instrumented_nodes, i.status_message, i.prev_status, i.prev_status_time, c.tags
               FROM vizier_cluster_info as i, vizier_cluster as c
               WHERE i.vizier_cluster_id=c.id AND i.vizier_cluster_id IN (?) AND c.org_id='%s'`
 	strQuery = fmt.Sprintf(strQuery, orgIDstr)
@@ -370,6 +410,9 @@ func (s *Server) GetVizierInfos(ctx context.Context, req *vzmgrpb.GetVizierInfos
 		if err != nil {
 			return nil, err
 		}
+		if !sel.Matches(labels.Set(vzInfo.Tags)) {
+			continue
+		}
 
 		vzInfoPb := vizierInfoToProto(vzInfo)
 		vzInfoMap[vzInfo.ID] = vzInfoPb
@@ -398,7 +441,7 @@ func (s *Server) GetVizierInfo(ctx context.Context, req *uuidpb.UUID) (*cvmsgspb
 	query := `SELECT i.vizier_cluster_id, c.cluster_uid, c.cluster_name, i.cluster_version, i.operator_version, i.vizier_version,
 			  i.status, (EXTRACT(EPOCH FROM age(now(), i.last_heartbeat))*1E9)::bigint as last_heartbeat,
               i.control_plane_pod_statuses, i.unhealthy_data_plane_pod_statuses,
-							i.num_nodes, i.num_instrumented_nodes, i.status_message, i.prev_status, i.prev_status_time
+							i.num_nodes, i.num_instrumented_nodes, i.status_message, i.prev_status, i.prev_status_time, c.tags
               from vizier_cluster_info as i, vizier_cluster as c
               WHERE i.vizier_cluster_id=$1 AND i.vizier_cluster_id=c.id`
 	vzInfo := VizierInfo{}
@@ -463,6 +506,12 @@ func (s *Server) GetVizierConnectionInfo(ctx context.Context, req *uuidpb.UUID)
 	// Generate a signed token for this cluster.
 	jwtKey := info.JWTSigningKey[SaltLength:]
 	claims := jwtutils.GenerateJWTForCluster("vizier_cluster", "vizier")
+	// The scopes of the caller's API key and their org role are passed on, so that Vizier can
+	// enforce them.
+	if sCtx, err := authcontext.FromContext(ctx); err == nil {
+		claims.GetClusterClaims().APIKeyScopes = sCtx.Claims.GetUserClaims().GetAPIKeyScopes()
+		claims.GetClusterClaims().OrgRole = sCtx.Claims.GetUserClaims().GetOrgRole()
+	}
 	tokenString, err := jwtutils.SignJWTClaims(claims, jwtKey)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to sign token: %s", err.Error())
@@ -688,17 +737,37 @@ func (s *Server) HandleVizierHeartbeat(v2cMsg *cvmsgspb.V2CMessage) {
 		return
 	}
 
-	if !req.DisableAutoUpdate && !s.updater.VersionUpToDate(info.Version) {
+	if !req.DisableAutoUpdate && !s.updater.VersionUpToDate(info.Version) && !s.inUpgradeCampaign(vizierID) {
 		s.updater.AddToUpdateQueue(vizierID)
 	}
 }
 
+// inUpgradeCampaign checks whether the Vizier is part of an upgrade campaign that is in progress.
+// These Viziers are upgraded by the campaign, so they shouldn't be auto-updated.
+func (s *Server) inUpgradeCampaign(vizierID uuid.UUID) bool {
+	query := `SELECT EXISTS(SELECT 1 FROM upgrade_campaign_clusters cc, upgrade_campaigns c
+		WHERE cc.campaign_id = c.id AND cc.vizier_cluster_id = $1 AND c.state IN ('RUNNING', 'PAUSED'))`
+	var exists bool
+	err := s.db.QueryRow(query, vizierID).Scan(&exists)
+	if err != nil {
+		log.WithError(err).Error("Failed to check whether vizier is in an upgrade campaign")
+		return false
+	}
+	return exists
+}
+
 // getServiceCredentials returns JWT credentials for inter-service requests.
 func getServiceCredentials(signingKey string) (string, error) {
 	claims := jwtutils.GenerateJWTForService("vzmgr Service", viper.GetString("domain_name"))
 	return jwtutils.SignJWTClaims(claims, signingKey)
 }
 
+// clusterUpdateAuditState is the state of a Vizier recorded in the audit events for updates.
+type clusterUpdateAuditState struct {
+	Version      string `json:"version"`
+	RedeployEtcd bool   `json:"redeployEtcd,omitempty"`
+}
+
 // UpdateOrInstallVizier updates or installs the given vizier cluster to the specified version.
 func (s *Server) UpdateOrInstallVizier(ctx context.Context, req *cvmsgspb.UpdateOrInstallVizierRequest) (*cvmsgspb.UpdateOrInstallVizierResponse, error) {
 	if err := s.validateOrgOwnsCluster(ctx, req.VizierID); err != nil {
@@ -707,11 +776,27 @@ func (s *Server) UpdateOrInstallVizier(ctx context.Context, req *cvmsgspb.Update
 
 	vizierID := utils.UUIDFromProtoOrNil(req.VizierID)
 
+	var orgID uuid.UUID
+	var prevVersion *string
+	query := `SELECT c.org_id, i.vizier_version FROM vizier_cluster c, vizier_cluster_info i
+		WHERE c.id = i.vizier_cluster_id AND c.id=$1`
+	err := s.db.QueryRowxContext(ctx, query, vizierID).Scan(&orgID, &prevVersion)
+	if err != nil {
+		log.WithError(err).Error("Failed to fetch current Vizier version")
+	}
+
 	v2cMsg, err := s.updater.UpdateOrInstallVizier(vizierID, req.Version, req.RedeployEtcd)
 	if err != nil {
 		return nil, err
 	}
 
+	var before *clusterUpdateAuditState
+	if prevVersion != nil {
+		before = &clusterUpdateAuditState{Version: *prevVersion}
+	}
+	audit.Emit(ctx, s.audit, audit.NewEvent(ctx, orgID, "cluster.update", clusterAuditResourceType, vizierID.String(),
+		before, &clusterUpdateAuditState{Version: req.Version, RedeployEtcd: req.RedeployEtcd}))
+
 	resp := &cvmsgspb.UpdateOrInstallVizierResponse{}
 	err = types.UnmarshalAny(v2cMsg.Msg, resp)
 	if err != nil {
@@ -872,6 +957,12 @@ func (s *Server) ProvisionOrClaimVizier(ctx context.Context, orgID uuid.UUID, us
 			return uuid.Nil, "", vzerrors.ErrInternalDB
 		}
 
+		// Provisioning is authorized with the deploy key of a user, rather than their credentials.
+		e := audit.NewEvent(ctx, orgID, "cluster.provision", clusterAuditResourceType, clusterID.String(), nil,
+			map[string]string{"clusterUID": clusterUID, "clusterName": finalName})
+		e.ActorType, e.ActorID = audit.ActorTypeUser, userID.String()
+		audit.Emit(ctx, s.audit, e)
+
 		events.Client().Enqueue(&analytics.Track{
 			UserId: clusterID.String(),
 			Event:  events.VizierCreated,
@@ -117,6 +117,7 @@ func testMain(m *testing.M) error {
 }
 
 func mustLoadTestData(db *sqlx.DB) {
+	db.MustExec(`DELETE FROM upgrade_campaigns`)
 	db.MustExec(`DELETE FROM vizier_cluster_info`)
 	db.MustExec(`DELETE FROM vizier_cluster`)
 
@@ -157,6 +158,8 @@ func mustLoadTestData(db *sqlx.DB) {
 		"", "", "", "{}", "{}", 4, 2, "", nil, nil)
 
 	db.MustExec(`UPDATE vizier_cluster SET cluster_name=NULL WHERE id=$1`, testDisconnectedClusterEmptyUID)
+	db.MustExec(`UPDATE vizier_cluster SET tags=$1 WHERE id=$2`, `{"env": "prod", "region": "us"}`, "123e4567-e89b-12d3-a456-426655440000")
+	db.MustExec(`UPDATE vizier_cluster SET tags=$1 WHERE id=$2`, `{"env": "staging"}`, "123e4567-e89b-12d3-a456-426655440001")
 }
 
 func CreateTestContext() context.Context {
@@ -199,6 +202,35 @@ func TestServer_GetViziersByOrg(t *testing.T) {
 		assert.Equal(t, ids, expected)
 	})
 
+	t.Run("selector", func(t *testing.T) {
+		resp, err := s.GetViziersBySelector(CreateTestContext(), &vzmgrpb.GetViziersBySelectorRequest{
+			OrgID:    utils.ProtoFromUUIDStrOrNil(testAuthOrgID),
+			Selector: "env in (prod, staging),region!=eu",
+		})
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+
+		var ids []string
+		for _, val := range resp.VizierIDs {
+			ids = append(ids, utils.UUIDFromProtoOrNil(val).String())
+		}
+		sort.Strings(ids)
+		assert.Equal(t, []string{
+			"123e4567-e89b-12d3-a456-426655440000",
+			"123e4567-e89b-12d3-a456-426655440001",
+		}, ids)
+	})
+
+	t.Run("invalid selector", func(t *testing.T) {
+		resp, err := s.GetViziersBySelector(CreateTestContext(), &vzmgrpb.GetViziersBySelectorRequest{
+			OrgID:    utils.ProtoFromUUIDStrOrNil(testAuthOrgID),
+			Selector: "env in prod",
+		})
+		require.NotNil(t, err)
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
 	t.Run("No such org id", func(t *testing.T) {
 		resp, err := s.GetViziersByOrg(CreateTestContext(), utils.ProtoFromUUIDStrOrNil("323e4567-e89b-12d3-a456-426655440000"))
 		require.NotNil(t, err)
@@ -291,6 +323,108 @@ func TestServer_GetVizierInfos(t *testing.T) {
 	assert.Equal(t, &cvmsgspb.VizierInfo{}, resp.VizierInfos[2])
 	assert.Equal(t, utils.ProtoFromUUIDStrOrNil("123e4567-e89b-12d3-a456-426655440000"), resp.VizierInfos[3].VizierID)
 	assert.Equal(t, "k8sID", resp.VizierInfos[3].ClusterUID)
+	assert.Equal(t, map[string]string{"env": "prod", "region": "us"}, resp.VizierInfos[3].Tags)
+
+	resp, err = s.GetVizierInfos(CreateTestContext(), &vzmgrpb.GetVizierInfosRequest{
+		VizierIDs: requestedIDs,
+		Selector:  "env=prod",
+	})
+	require.NoError(t, err)
+	require.NotNil(t, resp)
+
+	assert.Equal(t, 4, len(resp.VizierInfos))
+	assert.Equal(t, &cvmsgspb.VizierInfo{}, resp.VizierInfos[0])
+	assert.Equal(t, utils.ProtoFromUUIDStrOrNil("123e4567-e89b-12d3-a456-426655440000"), resp.VizierInfos[3].VizierID)
+}
+
+func TestServer_UpdateVizierTags(t *testing.T) {
+	mustLoadTestData(db)
+
+	s := controllers.New(db, "test", nil, nil)
+
+	t.Run("update and remove", func(t *testing.T) {
+		resp, err := s.UpdateVizierTags(CreateTestContext(), &vzmgrpb.UpdateVizierTagsRequest{
+			VizierID:    utils.ProtoFromUUIDStrOrNil("123e4567-e89b-12d3-a456-426655440000"),
+			Tags:        map[string]string{"env": "staging", "team": "infra"},
+			RemovedTags: []string{"region", "missing"},
+		})
+		require.NoError(t, err)
+		expectedTags := map[string]string{"env": "staging", "team": "infra"}
+		assert.Equal(t, expectedTags, resp.Tags)
+
+		info, err := s.GetVizierInfo(CreateTestContext(), utils.ProtoFromUUIDStrOrNil("123e4567-e89b-12d3-a456-426655440000"))
+		require.NoError(t, err)
+		assert.Equal(t, expectedTags, info.Tags)
+	})
+
+	t.Run("invalid tag", func(t *testing.T) {
+		resp, err := s.UpdateVizierTags(CreateTestContext(), &vzmgrpb.UpdateVizierTagsRequest{
+			VizierID: utils.ProtoFromUUIDStrOrNil("123e4567-e89b-12d3-a456-426655440000"),
+			Tags:     map[string]string{"env": "not a valid value"},
+		})
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("updated and removed", func(t *testing.T) {
+		resp, err := s.UpdateVizierTags(CreateTestContext(), &vzmgrpb.UpdateVizierTagsRequest{
+			VizierID:    utils.ProtoFromUUIDStrOrNil("123e4567-e89b-12d3-a456-426655440000"),
+			Tags:        map[string]string{"env": "prod"},
+			RemovedTags: []string{"env"},
+		})
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("cluster in another org", func(t *testing.T) {
+		resp, err := s.UpdateVizierTags(CreateTestContext(), &vzmgrpb.UpdateVizierTagsRequest{
+			VizierID: utils.ProtoFromUUIDStrOrNil("223e4567-e89b-12d3-a456-426655440003"),
+			Tags:     map[string]string{"env": "prod"},
+		})
+		assert.Nil(t, resp)
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+}
+
+func TestServer_GetVizierStatusHistory(t *testing.T) {
+	mustLoadTestData(db)
+
+	vizierID := "123e4567-e89b-12d3-a456-426655440001"
+	db.MustExec(`UPDATE vizier_cluster_info SET status='DEGRADED', status_message='2 PEMs are not running',
+		num_instrumented_nodes=7 WHERE vizier_cluster_id=$1`, vizierID)
+	// Heartbeats which don't change the status or the reason don't add to the history.
+	db.MustExec(`UPDATE vizier_cluster_info SET last_heartbeat=NOW() WHERE vizier_cluster_id=$1`, vizierID)
+	db.MustExec(`UPDATE vizier_cluster_info SET status='HEALTHY', status_message='', num_instrumented_nodes=9
+		WHERE vizier_cluster_id=$1`, vizierID)
+
+	s := controllers.New(db, "test", nil, nil)
+	resp, err := s.GetVizierStatusHistory(CreateTestContext(), &vzmgrpb.GetVizierStatusHistoryRequest{
+		VizierID: utils.ProtoFromUUIDStrOrNil(vizierID),
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Transitions, 3)
+	assert.Equal(t, cvmsgspb.VZ_ST_HEALTHY, resp.Transitions[0].Status)
+	assert.Equal(t, int32(9), resp.Transitions[0].NumInstrumentedNodes)
+	assert.Equal(t, cvmsgspb.VZ_ST_DEGRADED, resp.Transitions[1].Status)
+	assert.Equal(t, "2 PEMs are not running", resp.Transitions[1].StatusMessage)
+	assert.Equal(t, int32(12), resp.Transitions[1].NumNodes)
+	assert.Equal(t, int32(7), resp.Transitions[1].NumInstrumentedNodes)
+	assert.Equal(t, "vzVers", resp.Transitions[1].VizierVersion)
+	assert.Equal(t, cvmsgspb.VZ_ST_HEALTHY, resp.Transitions[2].Status)
+	assert.Equal(t, "This is a test", resp.Transitions[2].StatusMessage)
+
+	resp, err = s.GetVizierStatusHistory(CreateTestContext(), &vzmgrpb.GetVizierStatusHistoryRequest{
+		VizierID: utils.ProtoFromUUIDStrOrNil(vizierID),
+		Limit:    1,
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Transitions, 1)
+	assert.Equal(t, cvmsgspb.VZ_ST_HEALTHY, resp.Transitions[0].Status)
+
+	_, err = s.GetVizierStatusHistory(CreateTestContext(), &vzmgrpb.GetVizierStatusHistoryRequest{
+		VizierID: utils.ProtoFromUUIDStrOrNil("223e4567-e89b-12d3-a456-426655440003"),
+	})
+	assert.Equal(t, codes.NotFound, status.Code(err))
 }
 
 func TestServer_GetVizierConnectionInfo(t *testing.T) {
@@ -313,6 +447,29 @@ func TestServer_GetVizierConnectionInfo(t *testing.T) {
 	assert.Equal(t, []string{"cluster"}, srvutils.GetScopes(token))
 }
 
+func TestServer_GetVizierConnectionInfo_ScopedAPIKey(t *testing.T) {
+	mustLoadTestData(db)
+	viper.Set("domain_name", "withpixie.ai")
+
+	s := controllers.New(db, "test", nil, nil)
+	sCtx := authcontext.New()
+	sCtx.Claims = srvutils.GenerateJWTForAPIUser("123e4567-e89b-12d3-a456-426655440001", testAuthOrgID, time.Now().Add(time.Hour), "withpixie.ai")
+	sCtx.Claims.GetUserClaims().APIKeyScopes = []string{srvutils.APIKeyScopeScriptExecute}
+	sCtx.Claims.GetUserClaims().AllowedClusterIDs = []string{"123e4567-e89b-12d3-a456-426655440001"}
+	sCtx.Claims.GetUserClaims().OrgRole = srvutils.OrgRoleViewer
+	ctx := authcontext.NewContext(context.Background(), sCtx)
+
+	resp, err := s.GetVizierConnectionInfo(ctx, utils.ProtoFromUUIDStrOrNil("123e4567-e89b-12d3-a456-426655440001"))
+	require.NoError(t, err)
+	token, err := srvutils.ParseToken(resp.Token, "key0", "vizier")
+	require.NoError(t, err)
+	assert.Equal(t, []string{srvutils.APIKeyScopeScriptExecute}, srvutils.GetAPIKeyScopes(token))
+	assert.Equal(t, srvutils.OrgRoleViewer, srvutils.GetOrgRole(token))
+
+	_, err = s.GetVizierConnectionInfo(ctx, utils.ProtoFromUUIDStrOrNil("123e4567-e89b-12d3-a456-426655440002"))
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
 func TestServer_VizierConnectedHealthy(t *testing.T) {
 	mustLoadTestData(db)
 
@@ -580,6 +737,33 @@ func TestServer_HandleVizierHeartbeat(t *testing.T) {
 	}
 }
 
+func TestServer_HandleVizierHeartbeat_UpgradeCampaign(t *testing.T) {
+	mustLoadTestData(db)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	vizierID := uuid.FromStringOrNil("123e4567-e89b-12d3-a456-426655440001")
+	var campaignID uuid.UUID
+	err := db.Get(&campaignID, `INSERT INTO upgrade_campaigns(org_id, version, canary_count, wave_percentages,
+		soak_period_s, state, num_waves) VALUES ($1, '0.2.0', 0, '{100}', 600, 'RUNNING', 1) RETURNING id`, testAuthOrgID)
+	require.NoError(t, err)
+	db.MustExec(`INSERT INTO upgrade_campaign_clusters(campaign_id, vizier_cluster_id, wave, state)
+		VALUES ($1, $2, 0, 'PENDING')`, campaignID, vizierID)
+
+	// Viziers in an upgrade campaign are upgraded by the campaign, not queued for auto-update.
+	updater := mock_controllers.NewMockVzUpdater(ctrl)
+	updater.EXPECT().VersionUpToDate(gomock.Any()).Return(false)
+	s := controllers.New(db, "test", nil, updater)
+
+	nestedAny, err := types.MarshalAny(&cvmsgspb.VizierHeartbeat{
+		VizierID: utils.ProtoFromUUID(vizierID),
+		Status:   cvmsgspb.VZ_ST_HEALTHY,
+	})
+	require.NoError(t, err)
+	s.HandleVizierHeartbeat(&cvmsgspb.V2CMessage{Msg: nestedAny})
+}
+
 func TestServer_UpdateOrInstallVizier(t *testing.T) {
 	mustLoadTestData(db)
 
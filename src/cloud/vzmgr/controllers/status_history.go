@@ -0,0 +1,118 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/gogo/protobuf/types"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"px.dev/pixie/src/cloud/vzmgr/vzmgrpb"
+	"px.dev/pixie/src/utils"
+)
+
+const (
+	// defaultStatusHistoryLimit is the number of transitions returned when the request has no limit.
+	defaultStatusHistoryLimit = 100
+	// maxStatusHistoryLimit is the maximum number of transitions returned by a single request.
+	maxStatusHistoryLimit = 1000
+)
+
+// statusTransition is a row of the vizier_status_history table.
+type statusTransition struct {
+	Time                 time.Time    `db:"time"`
+	Status               vizierStatus `db:"status"`
+	StatusMessage        *string      `db:"status_message"`
+	NumNodes             int32        `db:"num_nodes"`
+	NumInstrumentedNodes int32        `db:"num_instrumented_nodes"`
+	VizierVersion        *string      `db:"vizier_version"`
+}
+
+func (t *statusTransition) toProto() *vzmgrpb.VizierStatusTransition {
+	ts, _ := types.TimestampProto(t.Time)
+	pb := &vzmgrpb.VizierStatusTransition{
+		Time:                 ts,
+		Status:               t.Status.ToProto(),
+		NumNodes:             t.NumNodes,
+		NumInstrumentedNodes: t.NumInstrumentedNodes,
+	}
+	if t.StatusMessage != nil {
+		pb.StatusMessage = *t.StatusMessage
+	}
+	if t.VizierVersion != nil {
+		pb.VizierVersion = *t.VizierVersion
+	}
+	return pb
+}
+
+// GetVizierStatusHistory gets the status transitions of the given Vizier, most recent first.
+// The transitions are recorded by a trigger on vizier_cluster_info, so they include the changes
+// made by heartbeats, the status monitor and updates alike.
+func (s *Server) GetVizierStatusHistory(ctx context.Context, req *vzmgrpb.GetVizierStatusHistoryRequest) (*vzmgrpb.GetVizierStatusHistoryResponse, error) {
+	if err := s.validateOrgOwnsCluster(ctx, req.VizierID); err != nil {
+		return nil, err
+	}
+
+	limit := int(req.Limit)
+	if limit < 0 {
+		return nil, status.Error(codes.InvalidArgument, "limit must not be negative")
+	}
+	if limit == 0 {
+		limit = defaultStatusHistoryLimit
+	}
+	if limit > maxStatusHistoryLimit {
+		limit = maxStatusHistoryLimit
+	}
+
+	startTime := time.Unix(0, 0)
+	if req.StartTime != nil {
+		t, err := types.TimestampFromProto(req.StartTime)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid start time")
+		}
+		startTime = t
+	}
+
+	query := `SELECT time, status, status_message, num_nodes, num_instrumented_nodes, vizier_version
+              FROM vizier_status_history
+              WHERE vizier_cluster_id=$1 AND time >= $2
+              ORDER BY time DESC
+              LIMIT $3`
+	rows, err := s.db.Queryx(query, utils.UUIDFromProtoOrNil(req.VizierID), startTime, limit)
+	if err != nil {
+		log.WithError(err).Error("Could not query Vizier status history")
+		return nil, status.Error(codes.Internal, "could not query status history")
+	}
+	defer rows.Close()
+
+	transitions := []*vzmgrpb.VizierStatusTransition{}
+	for rows.Next() {
+		var t statusTransition
+		if err := rows.StructScan(&t); err != nil {
+			log.WithError(err).Error("Could not read Vizier status history")
+			return nil, status.Error(codes.Internal, "could not query status history")
+		}
+		transitions = append(transitions, t.toProto())
+	}
+	return &vzmgrpb.GetVizierStatusHistoryResponse{Transitions: transitions}, nil
+}
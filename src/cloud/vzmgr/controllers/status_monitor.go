@@ -40,6 +40,10 @@ const (
 	// If a cluster is an UPDATING state, the amount of time since the last heartbeat at
 	// which we can consider it disconnected.
 	durationBeforeUpdateDisconnect = 15 * time.Minute
+	// How long to keep the status transitions of vizier clusters.
+	statusHistoryRetention = 30 * 24 * time.Hour
+	// How often to delete the expired status transitions.
+	pruneInterval = time.Hour
 )
 
 // StatusMonitor is responsible for maintaining status information of vizier clusters.
@@ -64,6 +68,8 @@ func (s *StatusMonitor) start() {
 	go func() {
 		tick := time.NewTicker(updateInterval)
 		defer tick.Stop()
+		pruneTick := time.NewTicker(pruneInterval)
+		defer pruneTick.Stop()
 
 		for {
 			select {
@@ -71,6 +77,8 @@ func (s *StatusMonitor) start() {
 				return
 			case <-tick.C:
 				s.UpdateDBEntries()
+			case <-pruneTick.C:
+				s.PruneStatusHistory()
 			}
 		}
 	}()
@@ -129,3 +137,16 @@ func (s *StatusMonitor) UpdateDBEntries() {
 		WithField("update_time", time.Since(start)).
 		Info("Heartbeat Update Complete")
 }
+
+// PruneStatusHistory deletes the status transitions which are older than the retention period.
+func (s *StatusMonitor) PruneStatusHistory() {
+	query := fmt.Sprintf(`DELETE FROM vizier_status_history WHERE time < NOW() - INTERVAL '%f seconds'`,
+		statusHistoryRetention.Seconds())
+	res, err := s.db.Exec(query)
+	if err != nil {
+		log.WithError(err).Error("Failed to prune status history, ignoring (will retry in next tick)")
+		return
+	}
+	deleted, _ := res.RowsAffected()
+	log.WithField("entries_deleted", deleted).Info("Status History Prune Complete")
+}
@@ -87,4 +87,29 @@ func TestStatusMonitor_Start(t *testing.T) {
 	err = db.Get(&vizInfo, query, uuid.FromStringOrNil("123e4567-e89b-12d3-a456-426655440002"))
 	require.NoError(t, err)
 	assert.Equal(t, vizInfo.Status, "DISCONNECTED")
+
+	// The disconnects are recorded in the status history.
+	var history []string
+	err = db.Select(&history, `SELECT status FROM vizier_status_history WHERE vizier_cluster_id=$1 ORDER BY time`,
+		uuid.FromStringOrNil("123e4567-e89b-12d3-a456-426655440000"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"HEALTHY", "DISCONNECTED"}, history)
+}
+
+func TestStatusMonitor_PruneStatusHistory(t *testing.T) {
+	mustLoadTestData(db)
+	mustLoadStatusMonitorTestData(db)
+
+	vizierID := uuid.FromStringOrNil("123e4567-e89b-12d3-a456-426655440000")
+	db.MustExec(`INSERT INTO vizier_status_history(vizier_cluster_id, time, status)
+		VALUES ($1, NOW() - INTERVAL '60 days', 'UNHEALTHY')`, vizierID)
+
+	sm := controllers.NewStatusMonitor(db)
+	defer sm.Stop()
+	sm.PruneStatusHistory()
+
+	var history []string
+	err := db.Select(&history, `SELECT status FROM vizier_status_history WHERE vizier_cluster_id=$1`, vizierID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"HEALTHY"}, history)
 }
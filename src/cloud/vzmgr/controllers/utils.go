@@ -54,3 +54,33 @@ func (p *PodStatuses) Scan(src interface{}) error {
 
 	return nil
 }
+
+// ClusterTags Type to use in sqlx for the map of user-defined cluster tags.
+type ClusterTags map[string]string
+
+// Value Returns a golang database/sql driver value for ClusterTags.
+func (t ClusterTags) Value() (driver.Value, error) {
+	if t == nil {
+		t = ClusterTags{}
+	}
+	res, err := json.Marshal(t)
+	if err != nil {
+		return res, err
+	}
+	return driver.Value(res), err
+}
+
+// Scan Scans the sqlx database type ([]bytes) into the ClusterTags type.
+func (t *ClusterTags) Scan(src interface{}) error {
+	switch jsonText := src.(type) {
+	case []byte:
+		err := json.Unmarshal(jsonText, t)
+		if err != nil {
+			return status.Error(codes.Internal, "could not unmarshal cluster tags")
+		}
+	default:
+		return status.Error(codes.Internal, "could not unmarshal cluster tags")
+	}
+
+	return nil
+}
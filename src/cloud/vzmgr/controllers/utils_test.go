@@ -74,3 +74,24 @@ func TestPodStatusScan(t *testing.T) {
 
 	assert.Equal(t, inputPodStatuses, outputPodStatuses)
 }
+
+func TestClusterTagsScan(t *testing.T) {
+	inputTags := controllers.ClusterTags{
+		"env":    "prod",
+		"region": "us-west",
+	}
+
+	var outputTags controllers.ClusterTags
+
+	serialized, err := inputTags.Value()
+	require.NoError(t, err)
+
+	err = outputTags.Scan(serialized)
+	require.NoError(t, err)
+
+	assert.Equal(t, inputTags, outputTags)
+
+	serialized, err = controllers.ClusterTags(nil).Value()
+	require.NoError(t, err)
+	assert.Equal(t, []byte("{}"), serialized)
+}
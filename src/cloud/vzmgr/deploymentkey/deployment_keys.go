@@ -32,6 +32,7 @@ import (
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
+	"px.dev/pixie/src/cloud/shared/audit"
 	"px.dev/pixie/src/cloud/vzmgr/vzerrors"
 	"px.dev/pixie/src/cloud/vzmgr/vzmgrpb"
 	"px.dev/pixie/src/utils"
@@ -40,12 +41,15 @@ import (
 const (
 	// deployKeyPrefox is applied to all deploy keys to make them easier to identify.
 	deployKeyPrefix = "px-dep-"
+	// auditResourceType is the resource type of audit events for deploy keys.
+	auditResourceType = "deploy_key"
 )
 
 // Service is used to provision and manage deployment keys.
 type Service struct {
 	db    *sqlx.DB
 	dbKey string
+	audit audit.Recorder
 }
 
 // New creates a new Service.
@@ -53,9 +57,16 @@ func New(db *sqlx.DB, dbKey string) *Service {
 	return &Service{
 		db:    db,
 		dbKey: dbKey,
+		audit: audit.NewDatastore(db),
 	}
 }
 
+// deployKeyAuditState is the state of a deploy key recorded in audit events. It never includes the key.
+type deployKeyAuditState struct {
+	UserID string `json:"userID"`
+	Desc   string `json:"desc"`
+}
+
 // Create a key with the org/user as an owner.
 func (s *Service) Create(ctx context.Context, req *vzmgrpb.CreateDeploymentKeyRequest) (*vzmgrpb.DeploymentKey, error) {
 	orgID, err := utils.UUIDFromProto(req.OrgID)
@@ -84,6 +95,8 @@ func (s *Service) Create(ctx context.Context, req *vzmgrpb.CreateDeploymentKeyRe
 		log.WithError(err).Error("Failed to insert deployment keys")
 		return nil, status.Error(codes.Internal, "Failed to insert deployment keys")
 	}
+	audit.Emit(ctx, s.audit, audit.NewEvent(ctx, orgID, "deploy_key.create", auditResourceType, id.String(),
+		nil, &deployKeyAuditState{UserID: userID.String(), Desc: req.Desc}))
 
 	tp, _ := types.TimestampProto(ts)
 	return &vzmgrpb.DeploymentKey{
@@ -187,23 +200,20 @@ func (s *Service) Delete(ctx context.Context, req *vzmgrpb.DeleteDeploymentKeyRe
 		return nil, status.Error(codes.InvalidArgument, "invalid org id format")
 	}
 
+	var before deployKeyAuditState
 	query := `DELETE FROM vizier_deployment_keys
-                WHERE org_id=$1 AND id=$2`
-	res, err := s.db.ExecContext(ctx, query, orgID, tokenID)
-	if err != nil {
-		log.WithError(err).Error("Failed to delete deployment token")
-		return nil, status.Error(codes.Internal, "failed to delete deployment token")
-	}
-
-	c, err := res.RowsAffected()
+                WHERE org_id=$1 AND id=$2
+              RETURNING user_id, description`
+	err = s.db.QueryRowxContext(ctx, query, orgID, tokenID).Scan(&before.UserID, &before.Desc)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, status.Error(codes.NotFound, "no such token to delete")
+		}
 		log.WithError(err).Error("Failed to delete deployment token")
 		return nil, status.Error(codes.Internal, "failed to delete deployment token")
 	}
-
-	if c == 0 {
-		return nil, status.Error(codes.NotFound, "no such token to delete")
-	}
+	audit.Emit(ctx, s.audit, audit.NewEvent(ctx, orgID, "deploy_key.delete", auditResourceType, tokenID.String(),
+		&before, nil))
 
 	return &types.Empty{}, nil
 }
@@ -35,6 +35,7 @@ import (
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
+	"px.dev/pixie/src/cloud/shared/audit"
 	"px.dev/pixie/src/cloud/vzmgr/schema"
 	"px.dev/pixie/src/cloud/vzmgr/vzerrors"
 	"px.dev/pixie/src/cloud/vzmgr/vzmgrpb"
@@ -78,6 +79,11 @@ func testMain(m *testing.M) error {
 	defer teardown()
 	db = testDB
 
+	err = audit.PerformMigrations(db)
+	if err != nil {
+		return fmt.Errorf("failed to apply audit migrations: %w", err)
+	}
+
 	if c := m.Run(); c != 0 {
 		return fmt.Errorf("some tests failed with code: %d", c)
 	}
@@ -98,6 +104,7 @@ func createTestAPIUserContext() context.Context {
 
 func mustLoadTestData(db *sqlx.DB) {
 	db.MustExec(`DELETE FROM vizier_deployment_keys`)
+	db.MustExec(`DELETE FROM audit_events`)
 
 	insertVizierDeploymentKeys := `INSERT INTO vizier_deployment_keys(id, org_id, user_id, hashed_key, encrypted_key, description)
                                      VALUES ($1, $2, $3, sha256($4), PGP_SYM_ENCRYPT($4::text, $5::text), $6)`
@@ -352,6 +359,14 @@ func TestDeploymentKeyService_Delete(t *testing.T) {
 				ID:    u,
 			})
 			assert.Equal(t, codes.NotFound, status.Code(err))
+
+			events, err := audit.NewDatastore(db).ListEvents(context.Background(), &audit.Filter{OrgID: testAuthOrgID})
+			require.NoError(t, err)
+			require.Len(t, events, 1)
+			assert.Equal(t, "deploy_key.delete", events[0].Action)
+			assert.Equal(t, testKey1ID.String(), events[0].ResourceID)
+			assert.Equal(t, testAuthUserID.String(), events[0].ActorID)
+			assert.JSONEq(t, `{"userID": "423e4567-e89b-12d3-a456-426655440000", "desc": "here is a desc"}`, events[0].Before)
 		})
 	}
 }
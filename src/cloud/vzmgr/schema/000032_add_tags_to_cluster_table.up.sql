@@ -0,0 +1,2 @@
+ALTER TABLE vizier_cluster
+  ADD COLUMN tags jsonb NOT NULL DEFAULT '{}';
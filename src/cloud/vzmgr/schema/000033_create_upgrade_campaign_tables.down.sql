@@ -0,0 +1,4 @@
+DROP TABLE upgrade_campaign_clusters;
+DROP TABLE upgrade_campaigns;
+DROP TYPE upgrade_campaign_cluster_state;
+DROP TYPE upgrade_campaign_state;
@@ -0,0 +1,42 @@
+CREATE TYPE upgrade_campaign_state AS ENUM ('UNKNOWN', 'RUNNING', 'PAUSED', 'COMPLETED', 'CANCELLED');
+CREATE TYPE upgrade_campaign_cluster_state AS ENUM ('UNKNOWN', 'PENDING', 'UPDATING', 'SOAKING', 'SUCCEEDED', 'FAILED');
+
+-- This table contains the campaigns that upgrade the viziers of an org in waves.
+CREATE TABLE upgrade_campaigns (
+  id UUID DEFAULT uuid_generate_v4(),
+  org_id UUID NOT NULL,
+  -- The version that the viziers are upgraded to.
+  version varchar(1000) NOT NULL,
+  -- The selector on the cluster tags which picked the viziers in the campaign.
+  selector varchar(1000) NOT NULL DEFAULT '',
+  canary_count integer NOT NULL,
+  -- The cumulative percentages of the remaining viziers upgraded by the end of each wave.
+  wave_percentages integer[] NOT NULL,
+  soak_period_s bigint NOT NULL,
+  state upgrade_campaign_state NOT NULL,
+  current_wave integer NOT NULL DEFAULT 0,
+  num_waves integer NOT NULL,
+  status_message text NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+  PRIMARY KEY(id)
+);
+
+-- An org can only have one campaign in progress at a time.
+CREATE UNIQUE INDEX upgrade_campaigns_active_org_idx ON upgrade_campaigns (org_id)
+  WHERE state IN ('RUNNING', 'PAUSED');
+
+-- This table contains the progress of each vizier in a campaign.
+CREATE TABLE upgrade_campaign_clusters (
+  campaign_id UUID NOT NULL REFERENCES upgrade_campaigns(id) ON DELETE CASCADE,
+  vizier_cluster_id UUID NOT NULL REFERENCES vizier_cluster(id) ON DELETE CASCADE,
+  wave integer NOT NULL,
+  state upgrade_campaign_cluster_state NOT NULL,
+  message text NOT NULL DEFAULT '',
+  updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+  PRIMARY KEY(campaign_id, vizier_cluster_id)
+);
+
+CREATE INDEX upgrade_campaign_clusters_vizier_idx ON upgrade_campaign_clusters (vizier_cluster_id);
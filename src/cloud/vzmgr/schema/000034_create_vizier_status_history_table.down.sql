@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS record_vizier_cluster_info_status_history ON vizier_cluster_info;
+DROP FUNCTION IF EXISTS record_status_history();
+DROP TABLE vizier_status_history;
@@ -0,0 +1,40 @@
+CREATE TABLE vizier_status_history (
+  vizier_cluster_id UUID NOT NULL REFERENCES vizier_cluster(id) ON DELETE CASCADE,
+  time TIMESTAMP NOT NULL DEFAULT NOW(),
+  status vizier_status,
+  status_message TEXT,
+  num_nodes INT NOT NULL DEFAULT 0,
+  num_instrumented_nodes INT NOT NULL DEFAULT 0,
+  vizier_version varchar(1000)
+);
+
+CREATE INDEX vizier_status_history_cluster_time_idx
+  ON vizier_status_history (vizier_cluster_id, time DESC);
+
+CREATE INDEX vizier_status_history_time_idx
+  ON vizier_status_history (time);
+
+-- Record a history entry whenever the status of a Vizier or the reason for it changes,
+-- regardless of whether the change came from a heartbeat, the status monitor or an update.
+CREATE OR REPLACE FUNCTION record_status_history()
+  RETURNS TRIGGER AS $$
+  BEGIN
+    IF TG_OP = 'UPDATE' THEN
+      IF NEW.status IS NOT DISTINCT FROM OLD.status AND
+         NEW.status_message IS NOT DISTINCT FROM OLD.status_message THEN
+        RETURN NULL;
+      END IF;
+    END IF;
+
+    INSERT INTO vizier_status_history
+      (vizier_cluster_id, status, status_message, num_nodes, num_instrumented_nodes, vizier_version)
+      VALUES (NEW.vizier_cluster_id, NEW.status, NEW.status_message,
+              COALESCE(NEW.num_nodes, 0), COALESCE(NEW.num_instrumented_nodes, 0), NEW.vizier_version);
+
+    RETURN NULL;
+  END;
+  $$ language 'plpgsql';
+
+CREATE TRIGGER record_vizier_cluster_info_status_history
+  AFTER INSERT OR UPDATE ON vizier_cluster_info
+  FOR EACH ROW EXECUTE PROCEDURE record_status_history();
@@ -0,0 +1,235 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package upgradecampaign
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/gogo/protobuf/types"
+	"github.com/jmoiron/sqlx"
+	log "github.com/sirupsen/logrus"
+
+	"px.dev/pixie/src/shared/cvmsgspb"
+)
+
+const (
+	// How often to advance the running campaigns.
+	runInterval = 10 * time.Second
+	// How long a Vizier has to become healthy on the new version after it is sent the update.
+	updateTimeout = 30 * time.Minute
+)
+
+// VizierUpdater sends updates to Viziers.
+type VizierUpdater interface {
+	UpdateOrInstallVizier(vizierID uuid.UUID, version string, redeployEtcd bool) (*cvmsgspb.V2CMessage, error)
+}
+
+// Runner periodically advances the running upgrade campaigns. Every step is a conditional update
+// in the database, so that the runners on all vzmgr replicas can run at the same time.
+type Runner struct {
+	db      *sqlx.DB
+	updater VizierUpdater
+	quitCh  chan struct{}
+	once    sync.Once
+}
+
+// NewRunner creates a new Runner and starts it.
+func NewRunner(db *sqlx.DB, updater VizierUpdater) *Runner {
+	r := &Runner{
+		db:      db,
+		updater: updater,
+		quitCh:  make(chan struct{}),
+	}
+	r.start()
+	return r
+}
+
+func (r *Runner) start() {
+	go func() {
+		tick := time.NewTicker(runInterval)
+		defer tick.Stop()
+
+		for {
+			select {
+			case <-r.quitCh:
+				return
+			case <-tick.C:
+				r.RunOnce()
+			}
+		}
+	}()
+}
+
+// Stop stops the runner.
+func (r *Runner) Stop() {
+	r.once.Do(func() {
+		close(r.quitCh)
+	})
+}
+
+type runningCampaign struct {
+	ID          uuid.UUID `db:"id"`
+	Version     string    `db:"version"`
+	SoakPeriodS int64     `db:"soak_period_s"`
+	CurrentWave int32     `db:"current_wave"`
+	NumWaves    int32     `db:"num_waves"`
+}
+
+// RunOnce advances each running campaign by one step. It returns once the updates for any newly
+// started waves have been sent.
+func (r *Runner) RunOnce() {
+	var campaigns []runningCampaign
+	query := `SELECT id, version, soak_period_s, current_wave, num_waves FROM upgrade_campaigns WHERE state='RUNNING'`
+	err := r.db.Select(&campaigns, query)
+	if err != nil {
+		log.WithError(err).Error("Failed to fetch running upgrade campaigns, ignoring (will retry in next tick)")
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, c := range campaigns {
+		if err := r.step(c, &wg); err != nil {
+			log.WithError(err).WithField("campaign_id", c.ID.String()).Error("Failed to advance upgrade campaign")
+		}
+	}
+	wg.Wait()
+}
+
+// step moves the Viziers of the campaign through their states, pauses the campaign if any of them
+// failed, and otherwise starts the next wave once all of the Viziers in the current one succeeded.
+func (r *Runner) step(c runningCampaign, wg *sync.WaitGroup) error {
+	// Variable substitution does not work for intervals, so the durations are formatted into the
+	// queries. They are constants or integers from the database, so this is safe.
+	transitions := []struct {
+		query string
+		args  []interface{}
+	}{
+		// Viziers which came back healthy on the new version start soaking.
+		{`UPDATE upgrade_campaign_clusters cc SET state='SOAKING', updated_at=NOW()
+		  FROM vizier_cluster_info i
+		  WHERE cc.vizier_cluster_id = i.vizier_cluster_id AND cc.campaign_id=$1 AND cc.state='UPDATING'
+		    AND i.status='HEALTHY' AND i.vizier_version=$2`, []interface{}{c.ID, c.Version}},
+		{`UPDATE upgrade_campaign_clusters cc SET state='FAILED', message='Vizier failed to update', updated_at=NOW()
+		  FROM vizier_cluster_info i
+		  WHERE cc.vizier_cluster_id = i.vizier_cluster_id AND cc.campaign_id=$1 AND cc.state='UPDATING'
+		    AND i.status='UPDATE_FAILED'`, []interface{}{c.ID}},
+		{fmt.Sprintf(`UPDATE upgrade_campaign_clusters SET state='FAILED',
+		    message='Timed out waiting for Vizier to become healthy on the new version', updated_at=NOW()
+		  WHERE campaign_id=$1 AND state='UPDATING' AND updated_at < NOW() - INTERVAL '%f seconds'`,
+			updateTimeout.Seconds()), []interface{}{c.ID}},
+		// Viziers which stop being healthy while soaking fail the health gate.
+		{`UPDATE upgrade_campaign_clusters cc
+		  SET state='FAILED', message='Vizier became ' || i.status || ' on ' || COALESCE(i.vizier_version, 'an unknown version')
+		    || ' during the soak period', updated_at=NOW()
+		  FROM vizier_cluster_info i
+		  WHERE cc.vizier_cluster_id = i.vizier_cluster_id AND cc.campaign_id=$1 AND cc.state='SOAKING'
+		    AND (i.status != 'HEALTHY' OR i.vizier_version IS DISTINCT FROM $2)`, []interface{}{c.ID, c.Version}},
+		{fmt.Sprintf(`UPDATE upgrade_campaign_clusters SET state='SUCCEEDED', updated_at=NOW()
+		  WHERE campaign_id=$1 AND state='SOAKING' AND updated_at < NOW() - INTERVAL '%d seconds'`,
+			c.SoakPeriodS), []interface{}{c.ID}},
+	}
+	for _, t := range transitions {
+		if _, err := r.db.Exec(t.query, t.args...); err != nil {
+			return err
+		}
+	}
+
+	var numFailed int
+	err := r.db.Get(&numFailed, `SELECT COUNT(*) FROM upgrade_campaign_clusters WHERE campaign_id=$1 AND state='FAILED'`, c.ID)
+	if err != nil {
+		return err
+	}
+	if numFailed > 0 {
+		query := `UPDATE upgrade_campaigns SET state='PAUSED', status_message=$1, updated_at=NOW()
+		  WHERE id=$2 AND state='RUNNING'`
+		msg := fmt.Sprintf("Paused because %d Vizier(s) failed to upgrade", numFailed)
+		_, err = r.db.Exec(query, msg, c.ID)
+		if err == nil {
+			log.WithField("campaign_id", c.ID.String()).Info(msg)
+		}
+		return err
+	}
+
+	var numUnfinished int
+	query := `SELECT COUNT(*) FROM upgrade_campaign_clusters WHERE campaign_id=$1 AND wave=$2 AND state != 'SUCCEEDED'`
+	err = r.db.Get(&numUnfinished, query, c.ID, c.CurrentWave)
+	if err != nil {
+		return err
+	}
+	if numUnfinished == 0 {
+		if c.CurrentWave+1 >= c.NumWaves {
+			query = `UPDATE upgrade_campaigns SET state='COMPLETED', status_message='', updated_at=NOW()
+			  WHERE id=$1 AND state='RUNNING' AND current_wave=$2`
+		} else {
+			query = `UPDATE upgrade_campaigns SET current_wave=current_wave+1, updated_at=NOW()
+			  WHERE id=$1 AND state='RUNNING' AND current_wave=$2`
+		}
+		// The next wave is started in the next tick.
+		_, err = r.db.Exec(query, c.ID, c.CurrentWave)
+		return err
+	}
+
+	// Claim the Viziers in the current wave which haven't been sent the update yet. The update only
+	// succeeds on one replica, so each Vizier is only sent the update once.
+	var vizierIDs []uuid.UUID
+	query = `UPDATE upgrade_campaign_clusters SET state='UPDATING', updated_at=NOW()
+	  WHERE campaign_id=$1 AND wave=$2 AND state='PENDING'
+	  RETURNING vizier_cluster_id`
+	err = r.db.Select(&vizierIDs, query, c.ID, c.CurrentWave)
+	if err != nil {
+		return err
+	}
+	for _, vizierID := range vizierIDs {
+		wg.Add(1)
+		go func(vizierID uuid.UUID) {
+			defer wg.Done()
+			r.sendUpdate(c, vizierID)
+		}(vizierID)
+	}
+	return nil
+}
+
+// sendUpdate sends the update to the Vizier, and fails the Vizier if it did not start the update.
+func (r *Runner) sendUpdate(c runningCampaign, vizierID uuid.UUID) {
+	log.WithField("campaign_id", c.ID.String()).WithField("vizier_id", vizierID.String()).
+		WithField("version", c.Version).Info("Upgrading Vizier in campaign")
+
+	var message string
+	v2cMsg, err := r.updater.UpdateOrInstallVizier(vizierID, c.Version, false)
+	if err != nil {
+		message = fmt.Sprintf("Failed to send update: %s", err.Error())
+	} else {
+		resp := &cvmsgspb.UpdateOrInstallVizierResponse{}
+		if err := types.UnmarshalAny(v2cMsg.Msg, resp); err != nil || !resp.UpdateStarted {
+			message = "Vizier did not start the update"
+		}
+	}
+	if message == "" {
+		return
+	}
+
+	query := `UPDATE upgrade_campaign_clusters SET state='FAILED', message=$1, updated_at=NOW()
+	  WHERE campaign_id=$2 AND vizier_cluster_id=$3 AND state='UPDATING'`
+	if _, err := r.db.Exec(query, message, c.ID, vizierID); err != nil {
+		log.WithError(err).Error("Failed to mark upgrade campaign cluster as failed")
+	}
+}
@@ -0,0 +1,172 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package upgradecampaign
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/gogo/protobuf/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"px.dev/pixie/src/cloud/vzmgr/vzmgrpb"
+	"px.dev/pixie/src/shared/cvmsgspb"
+	"px.dev/pixie/src/utils"
+)
+
+// fakeUpdater records the Viziers that were sent updates.
+type fakeUpdater struct {
+	mu      sync.Mutex
+	updated []uuid.UUID
+	failFor uuid.UUID
+}
+
+func (f *fakeUpdater) UpdateOrInstallVizier(vizierID uuid.UUID, version string, redeployEtcd bool) (*cvmsgspb.V2CMessage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if vizierID == f.failFor {
+		return nil, errors.New("could not reach vizier")
+	}
+	f.updated = append(f.updated, vizierID)
+	anyMsg, err := types.MarshalAny(&cvmsgspb.UpdateOrInstallVizierResponse{UpdateStarted: true})
+	if err != nil {
+		return nil, err
+	}
+	return &cvmsgspb.V2CMessage{Msg: anyMsg}, nil
+}
+
+func createRunnerTestCampaign(t *testing.T) *vzmgrpb.UpgradeCampaign {
+	s := New(db)
+	resp, err := s.Create(createTestContext(), &vzmgrpb.CreateUpgradeCampaignRequest{
+		OrgID:       utils.ProtoFromUUID(testAuthOrgID),
+		Version:     "0.2.0",
+		Selector:    "env=prod",
+		CanaryCount: 1,
+	})
+	require.NoError(t, err)
+	return resp
+}
+
+func getCampaign(t *testing.T, id uuid.UUID) *vzmgrpb.UpgradeCampaign {
+	resp, err := New(db).getCampaign(createTestContext(), testAuthOrgID, id)
+	require.NoError(t, err)
+	return resp
+}
+
+// markUpgraded simulates a Vizier reconnecting on the new version, and the soak period passing.
+func markUpgraded(vizierID uuid.UUID) {
+	db.MustExec(`UPDATE vizier_cluster_info SET status='HEALTHY', vizier_version='0.2.0' WHERE vizier_cluster_id=$1`, vizierID)
+}
+
+func expireSoakPeriod() {
+	db.MustExec(`UPDATE upgrade_campaign_clusters SET updated_at=NOW() - INTERVAL '1 hour' WHERE state='SOAKING'`)
+}
+
+func TestRunner_RunOnce(t *testing.T) {
+	mustLoadTestData(db)
+	campaign := createRunnerTestCampaign(t)
+	id := utils.UUIDFromProtoOrNil(campaign.ID)
+
+	updater := &fakeUpdater{}
+	r := &Runner{db: db, updater: updater}
+
+	// The canary wave is started.
+	r.RunOnce()
+	assert.Equal(t, []uuid.UUID{testClusterA}, updater.updated)
+	resp := getCampaign(t, id)
+	assert.Equal(t, vzmgrpb.UCCS_UPDATING, resp.Clusters[0].State)
+	assert.Equal(t, vzmgrpb.UCCS_PENDING, resp.Clusters[1].State)
+
+	// The canary stays in the current wave until it is healthy on the new version for the soak period.
+	r.RunOnce()
+	markUpgraded(testClusterA)
+	r.RunOnce()
+	resp = getCampaign(t, id)
+	assert.Equal(t, vzmgrpb.UCCS_SOAKING, resp.Clusters[0].State)
+	assert.Equal(t, int32(0), resp.CurrentWave)
+
+	expireSoakPeriod()
+	r.RunOnce()
+	resp = getCampaign(t, id)
+	assert.Equal(t, vzmgrpb.UCCS_SUCCEEDED, resp.Clusters[0].State)
+	assert.Equal(t, int32(1), resp.CurrentWave)
+
+	// The rest of the clusters are upgraded in the next wave.
+	r.RunOnce()
+	assert.ElementsMatch(t, []uuid.UUID{testClusterA, testClusterB, testClusterC}, updater.updated)
+	markUpgraded(testClusterB)
+	markUpgraded(testClusterC)
+	r.RunOnce()
+	expireSoakPeriod()
+	r.RunOnce()
+	r.RunOnce()
+
+	resp = getCampaign(t, id)
+	assert.Equal(t, vzmgrpb.UCS_COMPLETED, resp.State)
+	for _, c := range resp.Clusters {
+		assert.Equal(t, vzmgrpb.UCCS_SUCCEEDED, c.State)
+	}
+}
+
+func TestRunner_PausesOnFailure(t *testing.T) {
+	mustLoadTestData(db)
+	campaign := createRunnerTestCampaign(t)
+	id := utils.UUIDFromProtoOrNil(campaign.ID)
+
+	updater := &fakeUpdater{}
+	r := &Runner{db: db, updater: updater}
+
+	r.RunOnce()
+	markUpgraded(testClusterA)
+	r.RunOnce()
+
+	// The canary becomes unhealthy during the soak period.
+	db.MustExec(`UPDATE vizier_cluster_info SET status='UNHEALTHY' WHERE vizier_cluster_id=$1`, testClusterA)
+	r.RunOnce()
+
+	resp := getCampaign(t, id)
+	assert.Equal(t, vzmgrpb.UCS_PAUSED, resp.State)
+	assert.Equal(t, "Paused because 1 Vizier(s) failed to upgrade", resp.StatusMessage)
+	assert.Equal(t, vzmgrpb.UCCS_FAILED, resp.Clusters[0].State)
+	assert.Equal(t, "Vizier became UNHEALTHY on 0.2.0 during the soak period", resp.Clusters[0].Message)
+
+	// Paused campaigns don't make progress.
+	r.RunOnce()
+	assert.Equal(t, []uuid.UUID{testClusterA}, updater.updated)
+}
+
+func TestRunner_FailsOnSendError(t *testing.T) {
+	mustLoadTestData(db)
+	campaign := createRunnerTestCampaign(t)
+	id := utils.UUIDFromProtoOrNil(campaign.ID)
+
+	r := &Runner{db: db, updater: &fakeUpdater{failFor: testClusterA}}
+
+	r.RunOnce()
+	resp := getCampaign(t, id)
+	assert.Equal(t, vzmgrpb.UCCS_FAILED, resp.Clusters[0].State)
+	assert.Equal(t, "Failed to send update: could not reach vizier", resp.Clusters[0].Message)
+
+	r.RunOnce()
+	resp = getCampaign(t, id)
+	assert.Equal(t, vzmgrpb.UCS_PAUSED, resp.State)
+}
@@ -0,0 +1,472 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package upgradecampaign
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/gogo/protobuf/types"
+	"github.com/jackc/pgx"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"px.dev/pixie/src/cloud/shared/audit"
+	"px.dev/pixie/src/cloud/vzmgr/controllers"
+	"px.dev/pixie/src/cloud/vzmgr/vzmgrpb"
+	"px.dev/pixie/src/utils"
+)
+
+const (
+	// auditResourceType is the resource type of audit events for upgrade campaigns.
+	auditResourceType = "upgrade_campaign"
+	// defaultSoakPeriod is how long Viziers must stay healthy when the request doesn't specify it.
+	defaultSoakPeriod = 10 * time.Minute
+	// See https://www.postgresql.org/docs/current/errcodes-appendix.html
+	// Code for `unique_violation`
+	uniqueViolation = "23505"
+)
+
+// Service is used to create and manage upgrade campaigns. The campaigns are carried out by the Runner.
+type Service struct {
+	db    *sqlx.DB
+	audit audit.Recorder
+}
+
+// New creates a new Service.
+func New(db *sqlx.DB) *Service {
+	return &Service{
+		db:    db,
+		audit: audit.NewDatastore(db),
+	}
+}
+
+// campaignAuditState is the state of a campaign recorded in audit events.
+type campaignAuditState struct {
+	Version  string `json:"version"`
+	Selector string `json:"selector"`
+	State    string `json:"state"`
+}
+
+// campaignState converts between the state of a campaign in the proto and in the database.
+type campaignState vzmgrpb.UpgradeCampaignState
+
+func (s campaignState) Stringify() string {
+	return strings.TrimPrefix(vzmgrpb.UpgradeCampaignState(s).String(), "UCS_")
+}
+
+func (s *campaignState) fromString(str string) {
+	*s = campaignState(vzmgrpb.UpgradeCampaignState_value["UCS_"+str])
+}
+
+// clusterState converts between the state of a campaign's Vizier in the proto and in the database.
+type clusterState vzmgrpb.UpgradeCampaignClusterState
+
+func (s *clusterState) fromString(str string) {
+	*s = clusterState(vzmgrpb.UpgradeCampaignClusterState_value["UCCS_"+str])
+}
+
+// validateWavePercentages checks that the percentages are ascending, and end with 100.
+func validateWavePercentages(percentages []int32) error {
+	prev := int32(0)
+	for _, p := range percentages {
+		if p <= prev || p > 100 {
+			return status.Error(codes.InvalidArgument, "wave percentages must be ascending, between 1 and 100")
+		}
+		prev = p
+	}
+	if prev != 100 {
+		return status.Error(codes.InvalidArgument, "the last wave percentage must be 100")
+	}
+	return nil
+}
+
+// planWaves assigns each of the numClusters Viziers to a wave. The first canaryCount Viziers make up
+// the canary wave, and the remaining Viziers are split by the cumulative percentages. Waves which
+// would be empty are skipped, so that every wave upgrades at least one Vizier.
+func planWaves(numClusters int, canaryCount int, percentages []int32) []int {
+	waves := make([]int, 0, numClusters)
+	if canaryCount > numClusters {
+		canaryCount = numClusters
+	}
+	wave := 0
+	for i := 0; i < canaryCount; i++ {
+		waves = append(waves, wave)
+	}
+	if canaryCount > 0 {
+		wave++
+	}
+
+	remaining := numClusters - canaryCount
+	done := 0
+	for _, p := range percentages {
+		// Round up, so that small fleets still make progress in every wave.
+		end := (int(p)*remaining + 99) / 100
+		if end <= done {
+			continue
+		}
+		for ; done < end; done++ {
+			waves = append(waves, wave)
+		}
+		wave++
+	}
+	return waves
+}
+
+// campaignCluster is a Vizier which may be included in a new campaign.
+type campaignCluster struct {
+	ID            uuid.UUID      `db:"id"`
+	ClusterName   sql.NullString `db:"cluster_name"`
+	Tags          []byte         `db:"tags"`
+	Status        string         `db:"status"`
+	VizierVersion sql.NullString `db:"vizier_version"`
+}
+
+// Create starts an upgrade campaign over the connected Viziers of the org which match the selector.
+func (s *Service) Create(ctx context.Context, req *vzmgrpb.CreateUpgradeCampaignRequest) (*vzmgrpb.UpgradeCampaign, error) {
+	if err := controllers.ValidateOrgID(ctx, req.OrgID); err != nil {
+		return nil, err
+	}
+	orgID := utils.UUIDFromProtoOrNil(req.OrgID)
+	if req.Version == "" {
+		return nil, status.Error(codes.InvalidArgument, "version is required")
+	}
+	sel, err := labels.Parse(req.Selector)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid selector: %s", err.Error())
+	}
+	if req.CanaryCount < 0 {
+		return nil, status.Error(codes.InvalidArgument, "canary count can't be negative")
+	}
+	percentages := req.WavePercentages
+	if len(percentages) == 0 {
+		percentages = []int32{100}
+	}
+	if err := validateWavePercentages(percentages); err != nil {
+		return nil, err
+	}
+	if req.SoakPeriodS < 0 {
+		return nil, status.Error(codes.InvalidArgument, "soak period can't be negative")
+	}
+	soakPeriodS := req.SoakPeriodS
+	if soakPeriodS == 0 {
+		soakPeriodS = int64(defaultSoakPeriod.Seconds())
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to create upgrade campaign")
+	}
+	defer tx.Rollback()
+
+	query := `SELECT c.id, c.cluster_name, c.tags, i.status, i.vizier_version
+                FROM vizier_cluster c, vizier_cluster_info i
+                WHERE c.id = i.vizier_cluster_id AND c.org_id = $1
+                ORDER BY c.cluster_name, c.id`
+	var candidates []campaignCluster
+	err = tx.SelectContext(ctx, &candidates, query, orgID)
+	if err != nil {
+		log.WithError(err).Error("Failed to fetch clusters for upgrade campaign")
+		return nil, status.Error(codes.Internal, "failed to create upgrade campaign")
+	}
+
+	var clusterIDs []uuid.UUID
+	for _, c := range candidates {
+		if c.Status == "DISCONNECTED" || c.VizierVersion.String == req.Version {
+			continue
+		}
+		tags := make(map[string]string)
+		if len(c.Tags) > 0 {
+			if err := json.Unmarshal(c.Tags, &tags); err != nil {
+				log.WithError(err).Error("Failed to read cluster tags")
+				return nil, status.Error(codes.Internal, "failed to create upgrade campaign")
+			}
+		}
+		if !sel.Matches(labels.Set(tags)) {
+			continue
+		}
+		// API keys may be restricted to some of the org's clusters, and can only upgrade those.
+		if err := controllers.ValidateOrgOwnsCluster(ctx, s.db, utils.ProtoFromUUID(c.ID)); err != nil {
+			return nil, err
+		}
+		clusterIDs = append(clusterIDs, c.ID)
+	}
+	if len(clusterIDs) == 0 {
+		return nil, status.Error(codes.FailedPrecondition, "no connected clusters need to be upgraded")
+	}
+
+	waves := planWaves(len(clusterIDs), int(req.CanaryCount), percentages)
+	numWaves := waves[len(waves)-1] + 1
+
+	wavePercentages := make(pq.Int64Array, len(percentages))
+	for i, p := range percentages {
+		wavePercentages[i] = int64(p)
+	}
+
+	var id uuid.UUID
+	query = `INSERT INTO upgrade_campaigns(org_id, version, selector, canary_count, wave_percentages,
+                soak_period_s, state, num_waves)
+              VALUES($1, $2, $3, $4, $5, $6, 'RUNNING', $7)
+              RETURNING id`
+	err = tx.QueryRowxContext(ctx, query, orgID, req.Version, req.Selector, req.CanaryCount, wavePercentages,
+		soakPeriodS, numWaves).Scan(&id)
+	if err != nil {
+		if pgErr, ok := err.(pgx.PgError); ok && pgErr.Code == uniqueViolation {
+			return nil, status.Error(codes.FailedPrecondition, "org already has an upgrade campaign in progress")
+		}
+		log.WithError(err).Error("Failed to insert upgrade campaign")
+		return nil, status.Error(codes.Internal, "failed to create upgrade campaign")
+	}
+
+	query = `INSERT INTO upgrade_campaign_clusters(campaign_id, vizier_cluster_id, wave, state)
+              VALUES($1, $2, $3, 'PENDING')`
+	for i, clusterID := range clusterIDs {
+		_, err = tx.ExecContext(ctx, query, id, clusterID, waves[i])
+		if err != nil {
+			log.WithError(err).Error("Failed to insert upgrade campaign cluster")
+			return nil, status.Error(codes.Internal, "failed to create upgrade campaign")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, status.Error(codes.Internal, "failed to create upgrade campaign")
+	}
+
+	audit.Emit(ctx, s.audit, audit.NewEvent(ctx, orgID, "upgrade_campaign.create", auditResourceType, id.String(),
+		nil, &campaignAuditState{Version: req.Version, Selector: req.Selector, State: "RUNNING"}))
+
+	return s.getCampaign(ctx, orgID, id)
+}
+
+// campaignRow is an upgrade campaign as stored in the database.
+type campaignRow struct {
+	ID              uuid.UUID     `db:"id"`
+	OrgID           uuid.UUID     `db:"org_id"`
+	Version         string        `db:"version"`
+	Selector        string        `db:"selector"`
+	CanaryCount     int32         `db:"canary_count"`
+	WavePercentages pq.Int64Array `db:"wave_percentages"`
+	SoakPeriodS     int64         `db:"soak_period_s"`
+	State           string        `db:"state"`
+	CurrentWave     int32         `db:"current_wave"`
+	NumWaves        int32         `db:"num_waves"`
+	StatusMessage   string        `db:"status_message"`
+	CreatedAt       time.Time     `db:"created_at"`
+	UpdatedAt       time.Time     `db:"updated_at"`
+}
+
+const campaignColumns = `id, org_id, version, selector, canary_count, wave_percentages, soak_period_s,
+    state, current_wave, num_waves, status_message, created_at, updated_at`
+
+func (c *campaignRow) toProto() *vzmgrpb.UpgradeCampaign {
+	var state campaignState
+	state.fromString(c.State)
+	percentages := make([]int32, len(c.WavePercentages))
+	for i, p := range c.WavePercentages {
+		percentages[i] = int32(p)
+	}
+	createdAt, _ := types.TimestampProto(c.CreatedAt)
+	updatedAt, _ := types.TimestampProto(c.UpdatedAt)
+	return &vzmgrpb.UpgradeCampaign{
+		ID:              utils.ProtoFromUUID(c.ID),
+		OrgID:           utils.ProtoFromUUID(c.OrgID),
+		Version:         c.Version,
+		Selector:        c.Selector,
+		CanaryCount:     c.CanaryCount,
+		WavePercentages: percentages,
+		SoakPeriodS:     c.SoakPeriodS,
+		State:           vzmgrpb.UpgradeCampaignState(state),
+		CurrentWave:     c.CurrentWave,
+		NumWaves:        c.NumWaves,
+		StatusMessage:   c.StatusMessage,
+		CreatedAt:       createdAt,
+		UpdatedAt:       updatedAt,
+	}
+}
+
+// getCampaign returns the campaign, along with the progress of each of its Viziers.
+func (s *Service) getCampaign(ctx context.Context, orgID uuid.UUID, id uuid.UUID) (*vzmgrpb.UpgradeCampaign, error) {
+	var c campaignRow
+	query := `SELECT ` + campaignColumns + ` FROM upgrade_campaigns WHERE org_id=$1 AND id=$2`
+	err := s.db.QueryRowxContext(ctx, query, orgID, id).StructScan(&c)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, status.Error(codes.NotFound, "no such upgrade campaign")
+		}
+		log.WithError(err).Error("Failed to fetch upgrade campaign")
+		return nil, status.Error(codes.Internal, "failed to fetch upgrade campaign")
+	}
+	campaign := c.toProto()
+
+	var clusters []struct {
+		VizierID    uuid.UUID      `db:"vizier_cluster_id"`
+		ClusterName sql.NullString `db:"cluster_name"`
+		Wave        int32          `db:"wave"`
+		State       string         `db:"state"`
+		Message     string         `db:"message"`
+		UpdatedAt   time.Time      `db:"updated_at"`
+	}
+	query = `SELECT cc.vizier_cluster_id, c.cluster_name, cc.wave, cc.state, cc.message, cc.updated_at
+                FROM upgrade_campaign_clusters cc, vizier_cluster c
+                WHERE cc.vizier_cluster_id = c.id AND cc.campaign_id = $1
+                ORDER BY cc.wave, c.cluster_name, c.id`
+	err = s.db.SelectContext(ctx, &clusters, query, id)
+	if err != nil {
+		log.WithError(err).Error("Failed to fetch upgrade campaign clusters")
+		return nil, status.Error(codes.Internal, "failed to fetch upgrade campaign")
+	}
+	for _, cc := range clusters {
+		var state clusterState
+		state.fromString(cc.State)
+		updatedAt, _ := types.TimestampProto(cc.UpdatedAt)
+		campaign.Clusters = append(campaign.Clusters, &vzmgrpb.UpgradeCampaign_Cluster{
+			VizierID:    utils.ProtoFromUUID(cc.VizierID),
+			ClusterName: cc.ClusterName.String,
+			Wave:        cc.Wave,
+			State:       vzmgrpb.UpgradeCampaignClusterState(state),
+			Message:     cc.Message,
+			UpdatedAt:   updatedAt,
+		})
+	}
+	return campaign, nil
+}
+
+// Get returns the progress of a campaign owned by the org.
+func (s *Service) Get(ctx context.Context, req *vzmgrpb.GetUpgradeCampaignRequest) (*vzmgrpb.UpgradeCampaign, error) {
+	if err := controllers.ValidateOrgID(ctx, req.OrgID); err != nil {
+		return nil, err
+	}
+	orgID := utils.UUIDFromProtoOrNil(req.OrgID)
+	id, err := utils.UUIDFromProto(req.ID)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid id format")
+	}
+	return s.getCampaign(ctx, orgID, id)
+}
+
+// List returns the campaigns of an org, most recent first.
+func (s *Service) List(ctx context.Context, req *vzmgrpb.ListUpgradeCampaignsRequest) (*vzmgrpb.ListUpgradeCampaignsResponse, error) {
+	if err := controllers.ValidateOrgID(ctx, req.OrgID); err != nil {
+		return nil, err
+	}
+	orgID := utils.UUIDFromProtoOrNil(req.OrgID)
+
+	var rows []campaignRow
+	query := `SELECT ` + campaignColumns + ` FROM upgrade_campaigns WHERE org_id=$1 ORDER BY created_at DESC`
+	err := s.db.SelectContext(ctx, &rows, query, orgID)
+	if err != nil {
+		log.WithError(err).Error("Failed to fetch upgrade campaigns")
+		return nil, status.Error(codes.Internal, "failed to fetch upgrade campaigns")
+	}
+
+	campaigns := make([]*vzmgrpb.UpgradeCampaign, len(rows))
+	for i := range rows {
+		campaigns[i] = rows[i].toProto()
+	}
+	return &vzmgrpb.ListUpgradeCampaignsResponse{Campaigns: campaigns}, nil
+}
+
+// SetState pauses, resumes or cancels a campaign. Resuming a campaign retries its failed Viziers.
+func (s *Service) SetState(ctx context.Context, req *vzmgrpb.SetUpgradeCampaignStateRequest) (*vzmgrpb.UpgradeCampaign, error) {
+	if err := controllers.ValidateOrgID(ctx, req.OrgID); err != nil {
+		return nil, err
+	}
+	orgID := utils.UUIDFromProtoOrNil(req.OrgID)
+	id, err := utils.UUIDFromProto(req.ID)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid id format")
+	}
+
+	// The states that the campaign may be moved to the requested state from.
+	var fromStates []string
+	var message string
+	switch req.State {
+	case vzmgrpb.UCS_PAUSED:
+		fromStates = []string{"RUNNING"}
+		message = "Paused by user"
+	case vzmgrpb.UCS_RUNNING:
+		fromStates = []string{"PAUSED"}
+	case vzmgrpb.UCS_CANCELLED:
+		fromStates = []string{"RUNNING", "PAUSED"}
+		message = "Cancelled by user"
+	default:
+		return nil, status.Error(codes.InvalidArgument, "campaigns can only be paused, resumed or cancelled")
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to update upgrade campaign")
+	}
+	defer tx.Rollback()
+
+	var before campaignAuditState
+	query := `SELECT version, selector, state FROM upgrade_campaigns WHERE org_id=$1 AND id=$2 FOR UPDATE`
+	err = tx.QueryRowxContext(ctx, query, orgID, id).Scan(&before.Version, &before.Selector, &before.State)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, status.Error(codes.NotFound, "no such upgrade campaign")
+		}
+		log.WithError(err).Error("Failed to fetch upgrade campaign")
+		return nil, status.Error(codes.Internal, "failed to update upgrade campaign")
+	}
+
+	newState := campaignState(req.State).Stringify()
+	allowed := false
+	for _, st := range fromStates {
+		allowed = allowed || before.State == st
+	}
+	if !allowed {
+		return nil, status.Errorf(codes.FailedPrecondition, "can't move a %s campaign to %s",
+			strings.ToLower(before.State), strings.ToLower(newState))
+	}
+
+	query = `UPDATE upgrade_campaigns SET state=$1, status_message=$2, updated_at=NOW() WHERE id=$3`
+	_, err = tx.ExecContext(ctx, query, newState, message, id)
+	if err != nil {
+		log.WithError(err).Error("Failed to update upgrade campaign")
+		return nil, status.Error(codes.Internal, "failed to update upgrade campaign")
+	}
+	if req.State == vzmgrpb.UCS_RUNNING {
+		query = `UPDATE upgrade_campaign_clusters SET state='PENDING', message='', updated_at=NOW()
+                  WHERE campaign_id=$1 AND state='FAILED'`
+		_, err = tx.ExecContext(ctx, query, id)
+		if err != nil {
+			log.WithError(err).Error("Failed to reset failed upgrade campaign clusters")
+			return nil, status.Error(codes.Internal, "failed to update upgrade campaign")
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, status.Error(codes.Internal, "failed to update upgrade campaign")
+	}
+
+	after := before
+	after.State = newState
+	audit.Emit(ctx, s.audit, audit.NewEvent(ctx, orgID, "upgrade_campaign.set_state", auditResourceType, id.String(),
+		&before, &after))
+
+	return s.getCampaign(ctx, orgID, id)
+}
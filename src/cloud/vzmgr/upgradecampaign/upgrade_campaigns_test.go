@@ -0,0 +1,399 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package upgradecampaign
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	bindata "github.com/golang-migrate/migrate/source/go_bindata"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"px.dev/pixie/src/cloud/shared/audit"
+	"px.dev/pixie/src/cloud/vzmgr/schema"
+	"px.dev/pixie/src/cloud/vzmgr/vzmgrpb"
+	"px.dev/pixie/src/shared/services/authcontext"
+	"px.dev/pixie/src/shared/services/pgtest"
+	jwtutils "px.dev/pixie/src/shared/services/utils"
+	"px.dev/pixie/src/utils"
+)
+
+var (
+	testAuthOrgID    = uuid.FromStringOrNil("223e4567-e89b-12d3-a456-426655440000")
+	testAuthUserID   = uuid.FromStringOrNil("423e4567-e89b-12d3-a456-426655440000")
+	testNonAuthOrgID = uuid.FromStringOrNil("223e4567-e89b-12d3-a456-426655440001")
+
+	// Clusters of the test org, in the order of their names.
+	testClusterA            = uuid.FromStringOrNil("123e4567-e89b-12d3-a456-426655440000")
+	testClusterB            = uuid.FromStringOrNil("123e4567-e89b-12d3-a456-426655440001")
+	testClusterC            = uuid.FromStringOrNil("123e4567-e89b-12d3-a456-426655440002")
+	testClusterD            = uuid.FromStringOrNil("123e4567-e89b-12d3-a456-426655440003")
+	testClusterDisconnected = uuid.FromStringOrNil("123e4567-e89b-12d3-a456-426655440004")
+	testClusterUpToDate     = uuid.FromStringOrNil("123e4567-e89b-12d3-a456-426655440005")
+	testClusterOtherOrg     = uuid.FromStringOrNil("123e4567-e89b-12d3-a456-426655440006")
+)
+
+func TestMain(m *testing.M) {
+	err := testMain(m)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Got error: %v\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+var db *sqlx.DB
+
+func testMain(m *testing.M) error {
+	s := bindata.Resource(schema.AssetNames(), schema.Asset)
+	testDB, teardown, err := pgtest.SetupTestDB(s)
+	if err != nil {
+		return fmt.Errorf("failed to start test database: %w", err)
+	}
+
+	defer teardown()
+	db = testDB
+
+	err = audit.PerformMigrations(db)
+	if err != nil {
+		return fmt.Errorf("failed to apply audit migrations: %w", err)
+	}
+
+	if c := m.Run(); c != 0 {
+		return fmt.Errorf("some tests failed with code: %d", c)
+	}
+	return nil
+}
+
+func createTestContext() context.Context {
+	sCtx := authcontext.New()
+	sCtx.Claims = jwtutils.GenerateJWTForUser(testAuthUserID.String(), testAuthOrgID.String(), "test@test.com", time.Now(), "pixie")
+	return authcontext.NewContext(context.Background(), sCtx)
+}
+
+func createAPIKeyTestContext(allowedClusterIDs ...uuid.UUID) context.Context {
+	sCtx := authcontext.New()
+	sCtx.Claims = jwtutils.GenerateJWTForAPIUser(testAuthUserID.String(), testAuthOrgID.String(), time.Now(), "pixie")
+	for _, id := range allowedClusterIDs {
+		sCtx.Claims.GetUserClaims().AllowedClusterIDs = append(sCtx.Claims.GetUserClaims().AllowedClusterIDs, id.String())
+	}
+	return authcontext.NewContext(context.Background(), sCtx)
+}
+
+func mustLoadTestData(db *sqlx.DB) {
+	db.MustExec(`DELETE FROM upgrade_campaigns`)
+	db.MustExec(`DELETE FROM vizier_cluster_info`)
+	db.MustExec(`DELETE FROM vizier_cluster`)
+	db.MustExec(`DELETE FROM audit_events`)
+
+	insertCluster := `INSERT INTO vizier_cluster(org_id, id, cluster_uid, cluster_name, tags) VALUES ($1, $2, $3, $4, $5)`
+	insertClusterInfo := `INSERT INTO vizier_cluster_info(vizier_cluster_id, status, vizier_version, jwt_signing_key, last_heartbeat)
+                            VALUES ($1, $2, $3, 'key', NOW())`
+	clusters := []struct {
+		orgID   uuid.UUID
+		id      uuid.UUID
+		name    string
+		tags    string
+		status  string
+		version string
+	}{
+		{testAuthOrgID, testClusterA, "cluster_a", `{"env": "prod"}`, "HEALTHY", "0.1.0"},
+		{testAuthOrgID, testClusterB, "cluster_b", `{"env": "prod"}`, "HEALTHY", "0.1.0"},
+		{testAuthOrgID, testClusterC, "cluster_c", `{"env": "prod"}`, "UNHEALTHY", "0.1.0"},
+		{testAuthOrgID, testClusterD, "cluster_d", `{"env": "staging"}`, "HEALTHY", "0.1.0"},
+		{testAuthOrgID, testClusterDisconnected, "cluster_e", `{"env": "prod"}`, "DISCONNECTED", "0.1.0"},
+		{testAuthOrgID, testClusterUpToDate, "cluster_f", `{"env": "prod"}`, "HEALTHY", "0.2.0"},
+		{testNonAuthOrgID, testClusterOtherOrg, "cluster_g", `{"env": "prod"}`, "HEALTHY", "0.1.0"},
+	}
+	for _, c := range clusters {
+		db.MustExec(insertCluster, c.orgID, c.id, c.id.String(), c.name, c.tags)
+		db.MustExec(insertClusterInfo, c.id, c.status, c.version)
+	}
+}
+
+func TestPlanWaves(t *testing.T) {
+	tests := []struct {
+		name        string
+		numClusters int
+		canaryCount int
+		percentages []int32
+		expected    []int
+	}{
+		{"single wave", 3, 0, []int32{100}, []int{0, 0, 0}},
+		{"canary only", 1, 1, []int32{100}, []int{0}},
+		{"canary larger than fleet", 2, 5, []int32{100}, []int{0, 0}},
+		{"canary and waves", 9, 1, []int32{25, 50, 100}, []int{0, 1, 1, 2, 2, 3, 3, 3, 3}},
+		{"empty waves skipped", 3, 1, []int32{10, 20, 100}, []int{0, 1, 2}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, planWaves(test.numClusters, test.canaryCount, test.percentages))
+		})
+	}
+}
+
+func TestService_Create(t *testing.T) {
+	mustLoadTestData(db)
+
+	s := New(db)
+	resp, err := s.Create(createTestContext(), &vzmgrpb.CreateUpgradeCampaignRequest{
+		OrgID:           utils.ProtoFromUUID(testAuthOrgID),
+		Version:         "0.2.0",
+		Selector:        "env=prod",
+		CanaryCount:     1,
+		WavePercentages: []int32{50, 100},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, vzmgrpb.UCS_RUNNING, resp.State)
+	assert.Equal(t, "0.2.0", resp.Version)
+	assert.Equal(t, "env=prod", resp.Selector)
+	assert.Equal(t, []int32{50, 100}, resp.WavePercentages)
+	assert.Equal(t, int64(600), resp.SoakPeriodS)
+	assert.Equal(t, int32(0), resp.CurrentWave)
+	assert.Equal(t, int32(3), resp.NumWaves)
+
+	// Disconnected clusters, clusters on the version already and clusters which don't match the
+	// selector are excluded.
+	require.Len(t, resp.Clusters, 3)
+	expected := []struct {
+		id   uuid.UUID
+		wave int32
+	}{
+		{testClusterA, 0},
+		{testClusterB, 1},
+		{testClusterC, 2},
+	}
+	for i, e := range expected {
+		assert.Equal(t, utils.ProtoFromUUID(e.id), resp.Clusters[i].VizierID)
+		assert.Equal(t, e.wave, resp.Clusters[i].Wave)
+		assert.Equal(t, vzmgrpb.UCCS_PENDING, resp.Clusters[i].State)
+	}
+
+	var numEvents int
+	err = db.Get(&numEvents, `SELECT COUNT(*) FROM audit_events WHERE action='upgrade_campaign.create'`)
+	require.NoError(t, err)
+	assert.Equal(t, 1, numEvents)
+
+	// Only one campaign can be in progress at a time.
+	_, err = s.Create(createTestContext(), &vzmgrpb.CreateUpgradeCampaignRequest{
+		OrgID:   utils.ProtoFromUUID(testAuthOrgID),
+		Version: "0.2.0",
+	})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
+
+func TestService_Create_Invalid(t *testing.T) {
+	mustLoadTestData(db)
+
+	tests := []struct {
+		name         string
+		req          *vzmgrpb.CreateUpgradeCampaignRequest
+		expectedCode codes.Code
+	}{
+		{
+			name:         "missing version",
+			req:          &vzmgrpb.CreateUpgradeCampaignRequest{},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "invalid selector",
+			req:          &vzmgrpb.CreateUpgradeCampaignRequest{Version: "0.2.0", Selector: "env in (prod"},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "descending percentages",
+			req:          &vzmgrpb.CreateUpgradeCampaignRequest{Version: "0.2.0", WavePercentages: []int32{50, 25, 100}},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "percentages don't reach 100",
+			req:          &vzmgrpb.CreateUpgradeCampaignRequest{Version: "0.2.0", WavePercentages: []int32{50}},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "no matching clusters",
+			req:          &vzmgrpb.CreateUpgradeCampaignRequest{Version: "0.2.0", Selector: "env=dev"},
+			expectedCode: codes.FailedPrecondition,
+		},
+	}
+
+	s := New(db)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.req.OrgID = utils.ProtoFromUUID(testAuthOrgID)
+			_, err := s.Create(createTestContext(), test.req)
+			assert.Equal(t, test.expectedCode, status.Code(err))
+		})
+	}
+}
+
+func TestService_Create_Unauthorized(t *testing.T) {
+	tests := []struct {
+		name         string
+		ctx          context.Context
+		orgID        uuid.UUID
+		expectedCode codes.Code
+	}{
+		{
+			name:         "missing org",
+			ctx:          createTestContext(),
+			orgID:        uuid.Nil,
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "other org",
+			ctx:          createTestContext(),
+			orgID:        testNonAuthOrgID,
+			expectedCode: codes.PermissionDenied,
+		},
+		{
+			name:         "API key restricted to some of the clusters",
+			ctx:          createAPIKeyTestContext(testClusterA),
+			orgID:        testAuthOrgID,
+			expectedCode: codes.PermissionDenied,
+		},
+		{
+			name:         "API key restricted to all of the clusters",
+			ctx:          createAPIKeyTestContext(testClusterA, testClusterB, testClusterC),
+			orgID:        testAuthOrgID,
+			expectedCode: codes.OK,
+		},
+	}
+
+	s := New(db)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mustLoadTestData(db)
+
+			_, err := s.Create(test.ctx, &vzmgrpb.CreateUpgradeCampaignRequest{
+				OrgID:    utils.ProtoFromUUID(test.orgID),
+				Version:  "0.2.0",
+				Selector: "env=prod",
+			})
+			assert.Equal(t, test.expectedCode, status.Code(err))
+		})
+	}
+}
+
+func TestService_GetAndList(t *testing.T) {
+	mustLoadTestData(db)
+
+	s := New(db)
+	created, err := s.Create(createTestContext(), &vzmgrpb.CreateUpgradeCampaignRequest{
+		OrgID:   utils.ProtoFromUUID(testAuthOrgID),
+		Version: "0.2.0",
+	})
+	require.NoError(t, err)
+
+	resp, err := s.Get(createTestContext(), &vzmgrpb.GetUpgradeCampaignRequest{
+		ID:    created.ID,
+		OrgID: utils.ProtoFromUUID(testAuthOrgID),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, created, resp)
+
+	_, err = s.Get(createTestContext(), &vzmgrpb.GetUpgradeCampaignRequest{
+		ID:    created.ID,
+		OrgID: utils.ProtoFromUUID(testNonAuthOrgID),
+	})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = s.Get(createTestContext(), &vzmgrpb.GetUpgradeCampaignRequest{
+		ID:    utils.ProtoFromUUID(uuid.Must(uuid.NewV4())),
+		OrgID: utils.ProtoFromUUID(testAuthOrgID),
+	})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	list, err := s.List(createTestContext(), &vzmgrpb.ListUpgradeCampaignsRequest{OrgID: utils.ProtoFromUUID(testAuthOrgID)})
+	require.NoError(t, err)
+	require.Len(t, list.Campaigns, 1)
+	assert.Equal(t, created.ID, list.Campaigns[0].ID)
+	assert.Empty(t, list.Campaigns[0].Clusters)
+
+	_, err = s.List(createTestContext(), &vzmgrpb.ListUpgradeCampaignsRequest{OrgID: utils.ProtoFromUUID(testNonAuthOrgID)})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestService_SetState(t *testing.T) {
+	mustLoadTestData(db)
+
+	s := New(db)
+	created, err := s.Create(createTestContext(), &vzmgrpb.CreateUpgradeCampaignRequest{
+		OrgID:   utils.ProtoFromUUID(testAuthOrgID),
+		Version: "0.2.0",
+	})
+	require.NoError(t, err)
+	db.MustExec(`UPDATE upgrade_campaign_clusters SET state='FAILED' WHERE vizier_cluster_id=$1`, testClusterA)
+
+	setState := func(state vzmgrpb.UpgradeCampaignState) (*vzmgrpb.UpgradeCampaign, error) {
+		return s.SetState(createTestContext(), &vzmgrpb.SetUpgradeCampaignStateRequest{
+			ID:    created.ID,
+			OrgID: utils.ProtoFromUUID(testAuthOrgID),
+			State: state,
+		})
+	}
+
+	_, err = s.SetState(createTestContext(), &vzmgrpb.SetUpgradeCampaignStateRequest{
+		ID:    created.ID,
+		OrgID: utils.ProtoFromUUID(testNonAuthOrgID),
+		State: vzmgrpb.UCS_PAUSED,
+	})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = setState(vzmgrpb.UCS_RUNNING)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	_, err = setState(vzmgrpb.UCS_COMPLETED)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	resp, err := setState(vzmgrpb.UCS_PAUSED)
+	require.NoError(t, err)
+	assert.Equal(t, vzmgrpb.UCS_PAUSED, resp.State)
+
+	// Resuming retries the failed clusters.
+	resp, err = setState(vzmgrpb.UCS_RUNNING)
+	require.NoError(t, err)
+	assert.Equal(t, vzmgrpb.UCS_RUNNING, resp.State)
+	for _, c := range resp.Clusters {
+		assert.Equal(t, vzmgrpb.UCCS_PENDING, c.State)
+	}
+
+	resp, err = setState(vzmgrpb.UCS_CANCELLED)
+	require.NoError(t, err)
+	assert.Equal(t, vzmgrpb.UCS_CANCELLED, resp.State)
+
+	_, err = setState(vzmgrpb.UCS_RUNNING)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	// A new campaign can be created once the previous one is cancelled.
+	_, err = s.Create(createTestContext(), &vzmgrpb.CreateUpgradeCampaignRequest{
+		OrgID:   utils.ProtoFromUUID(testAuthOrgID),
+		Version: "0.2.0",
+	})
+	require.NoError(t, err)
+}
@@ -33,12 +33,14 @@ import (
 	"google.golang.org/grpc"
 
 	"px.dev/pixie/src/cloud/artifact_tracker/artifacttrackerpb"
+	"px.dev/pixie/src/cloud/shared/audit"
 	"px.dev/pixie/src/cloud/shared/pgmigrate"
 	"px.dev/pixie/src/cloud/shared/vzshard"
 	"px.dev/pixie/src/cloud/vzmgr/controllers"
 	"px.dev/pixie/src/cloud/vzmgr/deployment"
 	"px.dev/pixie/src/cloud/vzmgr/deploymentkey"
 	"px.dev/pixie/src/cloud/vzmgr/schema"
+	"px.dev/pixie/src/cloud/vzmgr/upgradecampaign"
 	"px.dev/pixie/src/cloud/vzmgr/vzmgrpb"
 	"px.dev/pixie/src/shared/services"
 	"px.dev/pixie/src/shared/services/env"
@@ -159,6 +161,10 @@ func main() {
 	if err != nil {
 		log.WithError(err).Fatal("Failed to apply migrations")
 	}
+	err = audit.PerformMigrations(db)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to apply audit migrations")
+	}
 
 	dbKey := viper.GetString("database_key")
 	if dbKey == "" {
@@ -184,12 +190,16 @@ func main() {
 	c := controllers.New(db, dbKey, nc, updater)
 	dks := deploymentkey.New(db, dbKey)
 	ds := deployment.New(dks, c)
+	ucs := upgradecampaign.New(db)
 
 	sm := controllers.NewStatusMonitor(db)
 	defer sm.Stop()
+	ucr := upgradecampaign.NewRunner(db, updater)
+	defer ucr.Stop()
 	vzmgrpb.RegisterVZMgrServiceServer(s.GRPCServer(), c)
 	vzmgrpb.RegisterVZDeploymentKeyServiceServer(s.GRPCServer(), dks)
 	vzmgrpb.RegisterVZDeploymentServiceServer(s.GRPCServer(), ds)
+	vzmgrpb.RegisterVZUpgradeCampaignServiceServer(s.GRPCServer(), ucs)
 
 	var mdr *controllers.MetadataReader
 	go func() {
@@ -18,4 +18,4 @@
 
 package vzmgrpb
 
-//go:generate mockgen -source=service.pb.go -destination=mock/vzmgr_mock.gen.go VZDeploymentKeyServiceClient,VZMgrServiceClient
+//go:generate mockgen -source=service.pb.go -destination=mock/vzmgr_mock.gen.go VZDeploymentKeyServiceClient,VZMgrServiceClient,VZUpgradeCampaignServiceClient
@@ -24,14 +24,17 @@ import (
 	"os"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/gofrs/uuid"
+	"github.com/gogo/protobuf/types"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"golang.org/x/term"
 
 	"px.dev/pixie/src/api/proto/cloudpb"
+	"px.dev/pixie/src/api/proto/uuidpb"
 	"px.dev/pixie/src/pixie_cli/pkg/auth"
 	"px.dev/pixie/src/pixie_cli/pkg/components"
 	"px.dev/pixie/src/pixie_cli/pkg/utils"
@@ -47,6 +50,10 @@ func init() {
 
 	CreateAPIKeyCmd.Flags().StringP("desc", "d", "", "A description for the API key")
 	CreateAPIKeyCmd.Flags().BoolP("short", "s", false, "Return only the created API key, for use to pipe to other tools")
+	CreateAPIKeyCmd.Flags().StringSlice("scopes", nil,
+		"Restrict the API key to these scopes: script:execute, cluster:admin, deploykey:admin, plugin:config, scim. Defaults to unrestricted")
+	CreateAPIKeyCmd.Flags().StringSlice("clusters", nil, "Restrict the API key to the clusters with these IDs. Defaults to all clusters")
+	CreateAPIKeyCmd.Flags().Duration("expires-in", 0, "How long until the API key expires, eg. 720h. Defaults to never")
 
 	DeleteAPIKeyCmd.Flags().StringP("id", "i", "", "The API key to delete")
 
@@ -77,8 +84,29 @@ var CreateAPIKeyCmd = &cobra.Command{
 		cloudAddr := viper.GetString("cloud_addr")
 		desc, _ := cmd.Flags().GetString("desc")
 		short, _ := cmd.Flags().GetBool("short")
+		scopes, _ := cmd.Flags().GetStringSlice("scopes")
+		clusters, _ := cmd.Flags().GetStringSlice("clusters")
+		expiresIn, _ := cmd.Flags().GetDuration("expires-in")
 
-		keyID, key, err := generateAPIKey(cloudAddr, desc)
+		req := &cloudpb.CreateAPIKeyRequest{
+			Desc:   desc,
+			Scopes: scopes,
+		}
+		for _, c := range clusters {
+			clusterID, err := uuid.FromString(c)
+			if err != nil {
+				utils.WithError(err).Fatalf("Invalid cluster ID %s", c)
+			}
+			req.ClusterIDs = append(req.ClusterIDs, utils2.ProtoFromUUID(clusterID))
+		}
+		if expiresIn < 0 {
+			utils.Fatal("--expires-in must be positive")
+		}
+		if expiresIn > 0 {
+			req.ExpiresAt, _ = types.TimestampProto(time.Now().Add(expiresIn))
+		}
+
+		keyID, key, err := generateAPIKey(cloudAddr, req)
 		if err != nil {
 			// Using log.Fatal rather than CLI log in order to track this unexpected error in Sentry.
 			log.WithError(err).Fatal("Failed to generate API key")
@@ -139,10 +167,10 @@ var ListAPIKeyCmd = &cobra.Command{
 		// Throw keys into table.
 		w := components.CreateStreamWriter(format, os.Stdout)
 		defer w.Finish()
-		w.SetHeader("api-keys", []string{"ID", "Key", "CreatedAt", "Description"})
+		w.SetHeader("api-keys", []string{"ID", "Key", "CreatedAt", "Description", "Scopes", "Clusters", "ExpiresAt", "LastUsedAt"})
 		for _, k := range keys {
 			_ = w.Write([]interface{}{utils2.UUIDFromProtoOrNil(k.ID), "<hidden>", k.CreatedAt,
-				k.Desc})
+				k.Desc, formatAPIKeyScopes(k.Scopes), formatAPIKeyClusters(k.ClusterIDs), k.ExpiresAt, k.LastUsedAt})
 		}
 	},
 }
@@ -173,9 +201,9 @@ var LookupAPIKeyCmd = &cobra.Command{
 		// Throw keys into table.
 		w := components.CreateStreamWriter(format, os.Stdout)
 		defer w.Finish()
-		w.SetHeader("api-keys", []string{"ID", "Key", "CreatedAt", "Description"})
+		w.SetHeader("api-keys", []string{"ID", "Key", "CreatedAt", "Description", "Scopes", "Clusters", "ExpiresAt", "LastUsedAt"})
 		_ = w.Write([]interface{}{utils2.UUIDFromProtoOrNil(k.ID), "<hidden>", k.CreatedAt,
-			k.Desc})
+			k.Desc, formatAPIKeyScopes(k.Scopes), formatAPIKeyClusters(k.ClusterIDs), k.ExpiresAt, k.LastUsedAt})
 	},
 }
 
@@ -204,9 +232,9 @@ var GetAPIKeyCmd = &cobra.Command{
 		// Throw keys into table.
 		w := components.CreateStreamWriter(format, os.Stdout)
 		defer w.Finish()
-		w.SetHeader("api-keys", []string{"ID", "Key", "CreatedAt", "Description"})
+		w.SetHeader("api-keys", []string{"ID", "Key", "CreatedAt", "Description", "Scopes", "Clusters", "ExpiresAt", "LastUsedAt"})
 		_ = w.Write([]interface{}{utils2.UUIDFromProtoOrNil(k.ID), k.Key, k.CreatedAt,
-			k.Desc})
+			k.Desc, formatAPIKeyScopes(k.Scopes), formatAPIKeyClusters(k.ClusterIDs), k.ExpiresAt, k.LastUsedAt})
 	},
 }
 
@@ -225,13 +253,31 @@ func getAPIKeyClientAndContext(cloudAddr string) (cloudpb.APIKeyManagerClient, c
 	return apiKeyMgr, ctxWithCreds, nil
 }
 
-func generateAPIKey(cloudAddr string, desc string) (string, string, error) {
+func formatAPIKeyScopes(scopes []string) string {
+	if len(scopes) == 0 {
+		return "all"
+	}
+	return strings.Join(scopes, ",")
+}
+
+func formatAPIKeyClusters(clusterIDs []*uuidpb.UUID) string {
+	if len(clusterIDs) == 0 {
+		return "all"
+	}
+	ids := make([]string, len(clusterIDs))
+	for i, id := range clusterIDs {
+		ids[i] = utils2.UUIDFromProtoOrNil(id).String()
+	}
+	return strings.Join(ids, ",")
+}
+
+func generateAPIKey(cloudAddr string, req *cloudpb.CreateAPIKeyRequest) (string, string, error) {
 	apiKeyMgr, ctxWithCreds, err := getAPIKeyClientAndContext(cloudAddr)
 	if err != nil {
 		return "", "", err
 	}
 
-	resp, err := apiKeyMgr.Create(ctxWithCreds, &cloudpb.CreateAPIKeyRequest{Desc: desc})
+	resp, err := apiKeyMgr.Create(ctxWithCreds, req)
 	if err != nil {
 		return "", "", err
 	}
@@ -29,10 +29,13 @@ import (
 	"github.com/blang/semver"
 	"github.com/dustin/go-humanize"
 	"github.com/gofrs/uuid"
+	"github.com/gogo/protobuf/types"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"px.dev/pixie/src/api/proto/cloudpb"
+	"px.dev/pixie/src/pixie_cli/pkg/auth"
 	"px.dev/pixie/src/pixie_cli/pkg/components"
 	cliUtils "px.dev/pixie/src/pixie_cli/pkg/utils"
 	"px.dev/pixie/src/pixie_cli/pkg/vizier"
@@ -48,8 +51,13 @@ func init() {
 	GetPEMsCmd.Flags().StringP("cluster", "c", "", "Run only on selected cluster")
 	GetPEMsCmd.Flags().MarkHidden("all-clusters")
 
+	GetViziersCmd.Flags().StringP("selector", "s", "", "Only get the viziers whose tags match this selector, eg. env=prod,region in (us, eu)")
+
 	GetClusterCmd.Flags().Bool("id", false, "Whether to only fetch the cluster ID from the cluster running in the current kubeconfig")
 	GetClusterCmd.Flags().Bool("cloud-addr", false, "Whether to only fetch the cloud address from the cluster running in the current kubeconfig")
+	GetClusterCmd.Flags().Bool("history", false, "Whether to show the timeline of the status changes of the cluster")
+	GetClusterCmd.Flags().StringP("cluster", "c", "", "ID of the cluster to show the history of. Defaults to the cluster in the current kubeconfig")
+	GetClusterCmd.Flags().Duration("since", 24*time.Hour, "How far back to show the history of the cluster")
 
 	GetCmd.AddCommand(GetPEMsCmd)
 	GetCmd.AddCommand(GetViziersCmd)
@@ -98,13 +106,14 @@ var GetViziersCmd = &cobra.Command{
 		cloudAddr := viper.GetString("cloud_addr")
 		format, _ := cmd.Flags().GetString("output")
 		format = strings.ToLower(format)
+		selector, _ := cmd.Flags().GetString("selector")
 
 		l, err := vizier.NewLister(cloudAddr)
 		if err != nil {
 			// Using log.Fatal rather than CLI log in order to track this unexpected error in Sentry.
 			log.WithError(err).Fatal("Failed to create Vizier lister")
 		}
-		vzs, err := l.GetViziersInfo()
+		vzs, err := l.GetViziersInfoBySelector(selector)
 		if err != nil {
 			// Using log.Fatal rather than CLI log in order to track this unexpected error in Sentry.
 			log.WithError(err).Fatalln("Failed to get vizier information")
@@ -114,7 +123,7 @@ var GetViziersCmd = &cobra.Command{
 
 		w := components.CreateStreamWriter(format, os.Stdout)
 		defer w.Finish()
-		w.SetHeader("viziers", []string{"ClusterName", "ID", "K8s Version", "Operator Version", "Vizier Version", "Last Heartbeat", "Status", "Status Message"})
+		w.SetHeader("viziers", []string{"ClusterName", "ID", "K8s Version", "Operator Version", "Vizier Version", "Last Heartbeat", "Status", "Status Message", "Tags"})
 
 		for _, vz := range vzs {
 			var lastHeartbeat interface{}
@@ -127,7 +136,7 @@ var GetViziersCmd = &cobra.Command{
 				}
 			}
 			_ = w.Write([]interface{}{vz.ClusterName, utils.UUIDFromProtoOrNil(vz.ID), vz.ClusterVersion,
-				prettyVersion(vz.OperatorVersion), prettyVersion(vz.VizierVersion), lastHeartbeat, vz.Status, vz.StatusMessage})
+				prettyVersion(vz.OperatorVersion), prettyVersion(vz.VizierVersion), lastHeartbeat, vz.Status, vz.StatusMessage, formatClusterTags(vz.Tags)})
 		}
 	},
 }
@@ -162,6 +171,12 @@ var GetClusterCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		id, _ := cmd.Flags().GetBool("id")
 		addr, _ := cmd.Flags().GetBool("cloud-addr")
+		history, _ := cmd.Flags().GetBool("history")
+
+		if history {
+			getClusterStatusHistory(cmd)
+			return
+		}
 
 		config := k8s.GetConfig()
 
@@ -187,6 +202,62 @@ var GetClusterCmd = &cobra.Command{
 	},
 }
 
+// getClusterStatusHistory prints the timeline of the status changes of a cluster, most recent first.
+func getClusterStatusHistory(cmd *cobra.Command) {
+	cloudAddr := viper.GetString("cloud_addr")
+	format, _ := cmd.Flags().GetString("output")
+	format = strings.ToLower(format)
+	selectedCluster, _ := cmd.Flags().GetString("cluster")
+	since, _ := cmd.Flags().GetDuration("since")
+
+	clusterID := uuid.FromStringOrNil(selectedCluster)
+	if selectedCluster != "" && clusterID == uuid.Nil {
+		cliUtils.Fatalf("Invalid cluster ID %s", selectedCluster)
+	}
+	if clusterID == uuid.Nil {
+		clusterID = vizier.GetClusterIDFromKubeConfig(k8s.GetConfig())
+		if clusterID == uuid.Nil {
+			cliUtils.Fatal("Unable to find Pixie cluster running in current kubeconfig, please specify one using --cluster")
+		}
+	}
+
+	startTime, err := types.TimestampProto(time.Now().Add(-since))
+	if err != nil {
+		cliUtils.Fatal("Invalid --since duration")
+	}
+
+	cloudConn, err := cliUtils.GetCloudClientConnection(cloudAddr)
+	if err != nil {
+		// Using log.Fatal rather than CLI log in order to track this unexpected error in Sentry.
+		log.WithError(err).Fatal("Failed to connect to cloud")
+	}
+	client := cloudpb.NewVizierClusterInfoClient(cloudConn)
+	resp, err := client.GetClusterStatusHistory(auth.CtxWithCreds(context.Background()), &cloudpb.GetClusterStatusHistoryRequest{
+		ID:        utils.ProtoFromUUID(clusterID),
+		StartTime: startTime,
+	})
+	if err != nil {
+		cliUtils.WithError(err).Fatal("Failed to get cluster status history")
+	}
+
+	w := components.CreateStreamWriter(format, os.Stdout)
+	defer w.Finish()
+	w.SetHeader("cluster-history", []string{"Time", "Status", "Duration", "PEMs", "Vizier Version", "Status Message"})
+
+	// Each status lasts until the next, more recent, transition.
+	until := time.Now()
+	for _, t := range resp.Transitions {
+		ts, err := types.TimestampFromProto(t.Time)
+		if err != nil {
+			continue
+		}
+		_ = w.Write([]interface{}{ts.Local().Format(time.RFC3339), strings.TrimPrefix(t.Status.String(), "CS_"),
+			until.Sub(ts).Round(time.Second), fmt.Sprintf("%d/%d", t.NumInstrumentedNodes, t.NumNodes),
+			prettyVersion(t.VizierVersion), t.StatusMessage})
+		until = ts
+	}
+}
+
 // GetCmd is the "get" command.
 var GetCmd = &cobra.Command{
 	Use:   "get",
@@ -0,0 +1,211 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"px.dev/pixie/src/api/proto/vizierpb"
+	"px.dev/pixie/src/pixie_cli/pkg/components"
+	"px.dev/pixie/src/pixie_cli/pkg/utils"
+	"px.dev/pixie/src/pixie_cli/pkg/vizier"
+)
+
+func init() {
+	K8sHistoryCmd.AddCommand(K8sHistoryObjectCmd)
+	K8sHistoryCmd.AddCommand(K8sHistoryPodByIPCmd)
+	K8sHistoryCmd.AddCommand(K8sHistoryDiffCmd)
+	K8sHistoryCmd.AddCommand(K8sHistoryRestartsCmd)
+	K8sHistoryCmd.PersistentFlags().StringP("cluster", "c", "", "Run only on selected cluster")
+
+	for _, c := range []*cobra.Command{K8sHistoryObjectCmd, K8sHistoryDiffCmd, K8sHistoryRestartsCmd} {
+		c.Flags().StringP("namespace", "n", "", "The namespace of the object")
+	}
+	K8sHistoryObjectCmd.Flags().Duration("since", time.Hour, "How far back to list versions of the object")
+	K8sHistoryObjectCmd.Flags().BoolP("json", "j", false, "Print the JSON of each version of the object")
+	K8sHistoryRestartsCmd.Flags().Duration("since", time.Hour, "How far back to list restarts of the object")
+	K8sHistoryPodByIPCmd.Flags().String("at", "", "The time to look up the IP at, in RFC3339 format. Defaults to now")
+	K8sHistoryDiffCmd.Flags().Int64("from", 0, "The version of the object to diff from")
+	K8sHistoryDiffCmd.Flags().Int64("to", 0, "The version of the object to diff to. Defaults to the latest version")
+}
+
+// K8sHistoryCmd queries the history of the K8s objects observed by Vizier.
+var K8sHistoryCmd = &cobra.Command{
+	Use:   "k8s-history",
+	Short: "Query the history of the Kubernetes objects observed by Pixie",
+}
+
+// K8sHistoryObjectCmd lists the versions of an object.
+var K8sHistoryObjectCmd = &cobra.Command{
+	Use:     "object <type> <name>",
+	Short:   "List the versions of a Kubernetes object, such as \"pods my-pod\"",
+	Example: "px k8s-history object deployments my-deployment -n default --since 2h",
+	Args:    cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		ns, _ := cmd.Flags().GetString("namespace")
+		since, _ := cmd.Flags().GetDuration("since")
+		printJSON, _ := cmd.Flags().GetBool("json")
+		resp := mustRunK8sHistoryRequest(cmd, &vizierpb.GetK8SHistoryRequest{
+			Query: &vizierpb.GetK8SHistoryRequest_ObjectHistory{
+				ObjectHistory: &vizierpb.GetK8SHistoryRequest_ObjectHistoryQuery{
+					ObjectType:  args[0],
+					Namespace:   ns,
+					Name:        args[1],
+					StartTimeNS: time.Now().Add(-since).UnixNano(),
+				},
+			},
+		})
+		if printJSON {
+			for _, v := range resp.Versions {
+				fmt.Println(v.ObjectJSON)
+			}
+			return
+		}
+		writeK8sObjectVersions("versions", resp.Versions)
+	},
+}
+
+// K8sHistoryPodByIPCmd gets the pod which owned an IP.
+var K8sHistoryPodByIPCmd = &cobra.Command{
+	Use:     "pod-by-ip <ip>",
+	Short:   "Get the pod which owned an IP at a given time",
+	Example: "px k8s-history pod-by-ip 10.8.0.12 --at 2021-03-01T15:04:05Z",
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		at, _ := cmd.Flags().GetString("at")
+		var timeNS int64
+		if at != "" {
+			t, err := time.Parse(time.RFC3339, at)
+			if err != nil {
+				utils.WithError(err).Fatal("Invalid time")
+			}
+			timeNS = t.UnixNano()
+		}
+		resp := mustRunK8sHistoryRequest(cmd, &vizierpb.GetK8SHistoryRequest{
+			Query: &vizierpb.GetK8SHistoryRequest_PodByIP{
+				PodByIP: &vizierpb.GetK8SHistoryRequest_PodByIPQuery{
+					IP:     args[0],
+					TimeNS: timeNS,
+				},
+			},
+		})
+		if len(resp.Versions) == 0 {
+			utils.Infof("No pod owned IP %s at that time", args[0])
+			return
+		}
+		writeK8sObjectVersions("pods", resp.Versions)
+	},
+}
+
+// K8sHistoryDiffCmd diffs two versions of an object.
+var K8sHistoryDiffCmd = &cobra.Command{
+	Use:     "diff <type> <name>",
+	Short:   "Show the differences between two versions of a Kubernetes object",
+	Example: "px k8s-history diff services my-service -n default --from 1200 --to 1350",
+	Args:    cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		ns, _ := cmd.Flags().GetString("namespace")
+		from, _ := cmd.Flags().GetInt64("from")
+		to, _ := cmd.Flags().GetInt64("to")
+		resp := mustRunK8sHistoryRequest(cmd, &vizierpb.GetK8SHistoryRequest{
+			Query: &vizierpb.GetK8SHistoryRequest_ObjectDiff{
+				ObjectDiff: &vizierpb.GetK8SHistoryRequest_ObjectDiffQuery{
+					ObjectType:  args[0],
+					Namespace:   ns,
+					Name:        args[1],
+					FromVersion: from,
+					ToVersion:   to,
+				},
+			},
+		})
+		if resp.Diff == "" {
+			utils.Info("The versions are identical")
+			return
+		}
+		fmt.Print(resp.Diff)
+	},
+}
+
+// K8sHistoryRestartsCmd lists the restarts of a pod or workload.
+var K8sHistoryRestartsCmd = &cobra.Command{
+	Use:     "restarts <type> <name>",
+	Short:   "List the restarts of a pod, deployment, statefulset or daemonset",
+	Example: "px k8s-history restarts deployments my-deployment -n default --since 1h",
+	Args:    cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		ns, _ := cmd.Flags().GetString("namespace")
+		since, _ := cmd.Flags().GetDuration("since")
+		resp := mustRunK8sHistoryRequest(cmd, &vizierpb.GetK8SHistoryRequest{
+			Query: &vizierpb.GetK8SHistoryRequest_ObjectRestarts{
+				ObjectRestarts: &vizierpb.GetK8SHistoryRequest_ObjectRestartsQuery{
+					ObjectType:  args[0],
+					Namespace:   ns,
+					Name:        args[1],
+					StartTimeNS: time.Now().Add(-since).UnixNano(),
+				},
+			},
+		})
+		writeK8sObjectVersions("restarts", resp.Versions)
+	},
+}
+
+func mustRunK8sHistoryRequest(cmd *cobra.Command, req *vizierpb.GetK8SHistoryRequest) *vizierpb.GetK8SHistoryResponse {
+	cloudAddr := viper.GetString("cloud_addr")
+	selectedCluster, _ := cmd.Flags().GetString("cluster")
+	clusterID := uuid.FromStringOrNil(selectedCluster)
+
+	var err error
+	if clusterID == uuid.Nil {
+		clusterID, err = vizier.GetCurrentVizier(cloudAddr)
+		if err != nil {
+			utils.WithError(err).Fatal("Could not fetch healthy vizier")
+		}
+	}
+
+	conn, err := vizier.ConnectionToVizierByID(cloudAddr, clusterID)
+	if err != nil {
+		utils.WithError(err).Fatal("Could not connect to vizier")
+	}
+
+	ctx, cleanup := utils.WithSignalCancellable(context.Background())
+	defer cleanup()
+	resp, err := conn.K8sHistoryRequest(ctx, req)
+	if err != nil {
+		utils.WithError(err).Fatal("Failed to query K8s history")
+	}
+	return resp
+}
+
+func writeK8sObjectVersions(name string, versions []*vizierpb.K8SObjectVersion) {
+	w := components.CreateStreamWriter("table", os.Stdout)
+	defer w.Finish()
+	w.SetHeader(name, []string{"Version", "Observed At", "Type", "Namespace", "Name"})
+	for _, v := range versions {
+		_ = w.Write([]interface{}{
+			v.UpdateVersion, time.Unix(0, v.TimestampNS), v.ObjectType, v.Namespace, v.Name,
+		})
+	}
+}
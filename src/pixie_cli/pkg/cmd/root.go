@@ -68,7 +68,11 @@ func init() {
 	RootCmd.AddCommand(CreateBundle)
 	RootCmd.AddCommand(DeployKeyCmd)
 	RootCmd.AddCommand(APIKeyCmd)
+	RootCmd.AddCommand(UserCmd)
 	RootCmd.AddCommand(DebugCmd)
+	RootCmd.AddCommand(K8sHistoryCmd)
+	RootCmd.AddCommand(TagCmd)
+	RootCmd.AddCommand(UpgradeCampaignCmd)
 
 	RootCmd.PersistentFlags().MarkHidden("cloud_addr")
 	RootCmd.PersistentFlags().MarkHidden("dev_cloud_namespace")
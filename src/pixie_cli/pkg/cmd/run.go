@@ -47,6 +47,7 @@ func init() {
 	RunCmd.Flags().StringP("cluster", "c", "", "ID of the cluster to run on. "+
 		"Use 'px get viziers', or visit Admin console: work.withpixie.ai/admin, to find the ID")
 	RunCmd.Flags().MarkHidden("all-clusters")
+	RunCmd.Flags().StringP("selector", "s", "", "Run on all healthy clusters whose tags match this selector, eg. env=prod,region in (us, eu)")
 
 	RunCmd.Flags().StringP("bundle", "b", "", "Path/URL to bundle file")
 
@@ -176,16 +177,24 @@ func createNewCobraCommand() *cobra.Command {
 
 			allClusters, _ := cmd.Flags().GetBool("all-clusters")
 			selectedCluster, _ := cmd.Flags().GetString("cluster")
+			selector, _ := cmd.Flags().GetString("selector")
 			clusterID := uuid.FromStringOrNil(selectedCluster)
 
-			if !allClusters && clusterID == uuid.Nil {
-				clusterID, err = vizier.GetCurrentVizier(cloudAddr)
-				if err != nil {
-					utils.WithError(err).Fatal("Could not fetch healthy vizier")
+			var conns []*vizier.Connector
+			if selector != "" {
+				if allClusters || selectedCluster != "" {
+					utils.Fatal("--selector can't be combined with --cluster or --all-clusters")
 				}
+				conns = vizier.MustConnectToViziersBySelector(cloudAddr, selector)
+			} else {
+				if !allClusters && clusterID == uuid.Nil {
+					clusterID, err = vizier.GetCurrentVizier(cloudAddr)
+					if err != nil {
+						utils.WithError(err).Fatal("Could not fetch healthy vizier")
+					}
+				}
+				conns = vizier.MustConnectHealthyDefaultVizier(cloudAddr, allClusters, clusterID)
 			}
-
-			conns := vizier.MustConnectHealthyDefaultVizier(cloudAddr, allClusters, clusterID)
 			useEncryption, _ := cmd.Flags().GetBool("e2e_encryption")
 
 			// Support Ctrl+C to cancel a query.
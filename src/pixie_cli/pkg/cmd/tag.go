@@ -0,0 +1,126 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gofrs/uuid"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"px.dev/pixie/src/api/proto/cloudpb"
+	"px.dev/pixie/src/pixie_cli/pkg/auth"
+	"px.dev/pixie/src/pixie_cli/pkg/utils"
+	"px.dev/pixie/src/pixie_cli/pkg/vizier"
+	utils2 "px.dev/pixie/src/utils"
+)
+
+func init() {
+	TagCmd.Flags().StringP("cluster", "c", "", "ID of the cluster to tag. Defaults to the cluster in the current kubeconfig")
+}
+
+// TagCmd is the "tag" command, which adds, updates or removes the tags of a cluster.
+var TagCmd = &cobra.Command{
+	Use:   "tag KEY=VALUE ... KEY- ...",
+	Short: "Add, update or remove the tags of a cluster",
+	Long: `Add, update or remove the tags of a cluster. Tags follow the rules of Kubernetes labels, and
+can be used to select clusters, eg. 'px get viziers --selector env=prod'.`,
+	Example: `  # Tag the cluster in the current kubeconfig.
+  px tag env=prod region=us-west
+
+  # Remove the team tag from a cluster.
+  px tag --cluster <cluster-id> team-`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cloudAddr := viper.GetString("cloud_addr")
+		selectedCluster, _ := cmd.Flags().GetString("cluster")
+
+		tags, removedTags, err := parseTagArgs(args)
+		if err != nil {
+			utils.WithError(err).Fatal("Invalid tags")
+		}
+
+		clusterID := uuid.FromStringOrNil(selectedCluster)
+		if selectedCluster != "" && clusterID == uuid.Nil {
+			utils.Fatalf("Invalid cluster ID %s", selectedCluster)
+		}
+		if clusterID == uuid.Nil {
+			clusterID, err = vizier.GetCurrentVizier(cloudAddr)
+			if err != nil {
+				utils.WithError(err).Fatal("Could not find the current cluster, please specify one using --cluster")
+			}
+		}
+
+		cloudConn, err := utils.GetCloudClientConnection(cloudAddr)
+		if err != nil {
+			// Using log.Fatal rather than CLI log in order to track this unexpected error in Sentry.
+			log.WithError(err).Fatal("Failed to connect to cloud")
+		}
+		client := cloudpb.NewVizierClusterInfoClient(cloudConn)
+
+		ctxWithCreds := auth.CtxWithCreds(context.Background())
+		resp, err := client.UpdateClusterTags(ctxWithCreds, &cloudpb.UpdateClusterTagsRequest{
+			ID:          utils2.ProtoFromUUID(clusterID),
+			Tags:        tags,
+			RemovedTags: removedTags,
+		})
+		if err != nil {
+			utils.WithError(err).Fatal("Failed to update cluster tags")
+		}
+
+		utils.Infof("Tags of cluster %s: %s", clusterID, formatClusterTags(resp.Tags))
+	},
+}
+
+// parseTagArgs parses arguments of the form KEY=VALUE into tags to set, and arguments of the form
+// KEY- into tags to remove.
+func parseTagArgs(args []string) (map[string]string, []string, error) {
+	tags := make(map[string]string)
+	var removedTags []string
+	for _, arg := range args {
+		if k, v, ok := strings.Cut(arg, "="); ok {
+			if k == "" {
+				return nil, nil, fmt.Errorf("missing key in %q", arg)
+			}
+			tags[k] = v
+			continue
+		}
+		if k := strings.TrimSuffix(arg, "-"); k != arg && k != "" {
+			removedTags = append(removedTags, k)
+			continue
+		}
+		return nil, nil, fmt.Errorf("expected KEY=VALUE or KEY-, got %q", arg)
+	}
+	return tags, removedTags, nil
+}
+
+// formatClusterTags formats the tags as a comma-separated list of KEY=VALUE, sorted by key.
+func formatClusterTags(tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+	for k, v := range tags {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", k, v))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
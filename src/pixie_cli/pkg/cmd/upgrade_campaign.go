@@ -0,0 +1,210 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"px.dev/pixie/src/api/proto/cloudpb"
+	"px.dev/pixie/src/pixie_cli/pkg/auth"
+	"px.dev/pixie/src/pixie_cli/pkg/components"
+	"px.dev/pixie/src/pixie_cli/pkg/utils"
+	utils2 "px.dev/pixie/src/utils"
+)
+
+func init() {
+	UpgradeCampaignCmd.AddCommand(CreateUpgradeCampaignCmd)
+	UpgradeCampaignCmd.AddCommand(ListUpgradeCampaignCmd)
+	UpgradeCampaignCmd.AddCommand(GetUpgradeCampaignCmd)
+	UpgradeCampaignCmd.AddCommand(newSetUpgradeCampaignStateCmd("pause", "Pause an upgrade campaign", cloudpb.UCS_PAUSED))
+	UpgradeCampaignCmd.AddCommand(newSetUpgradeCampaignStateCmd("resume", "Resume a paused upgrade campaign, retrying the clusters which failed", cloudpb.UCS_RUNNING))
+	UpgradeCampaignCmd.AddCommand(newSetUpgradeCampaignStateCmd("cancel", "Cancel an upgrade campaign", cloudpb.UCS_CANCELLED))
+
+	CreateUpgradeCampaignCmd.Flags().StringP("version", "v", "", "The version to upgrade the clusters to")
+	CreateUpgradeCampaignCmd.Flags().StringP("selector", "s", "", "Only upgrade the clusters whose tags match this selector, eg. 'env=prod'")
+	CreateUpgradeCampaignCmd.Flags().Int("canary", 1, "The number of clusters to upgrade in the canary wave. Set to 0 to skip the canary wave")
+	CreateUpgradeCampaignCmd.Flags().IntSlice("waves", []int{100}, "The cumulative percentages of the remaining clusters upgraded by the end of each wave, eg. 25,50,100")
+	CreateUpgradeCampaignCmd.Flags().Duration("soak", 10*time.Minute, "How long clusters must stay healthy on the new version before the next wave starts")
+
+	ListUpgradeCampaignCmd.Flags().StringP("output", "o", "", "Output format: one of: json|proto")
+	GetUpgradeCampaignCmd.Flags().StringP("output", "o", "", "Output format: one of: json|proto")
+}
+
+// UpgradeCampaignCmd is the upgrade-campaign sub-command of the CLI.
+var UpgradeCampaignCmd = &cobra.Command{
+	Use:   "upgrade-campaign",
+	Short: "Upgrade clusters in waves, waiting for each wave to stay healthy before starting the next",
+	Run: func(cmd *cobra.Command, args []string) {
+		utils.Info("Nothing here... Please execute one of the subcommands")
+		cmd.Help()
+	},
+}
+
+// CreateUpgradeCampaignCmd is the create sub-command of UpgradeCampaign.
+var CreateUpgradeCampaignCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Start an upgrade campaign",
+	Example: `  # Upgrade one prod cluster, then half of the rest, then all of them.
+  px upgrade-campaign create --version 0.14.0 --selector env=prod --canary 1 --waves 50,100`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cloudAddr := viper.GetString("cloud_addr")
+		version, _ := cmd.Flags().GetString("version")
+		selector, _ := cmd.Flags().GetString("selector")
+		canary, _ := cmd.Flags().GetInt("canary")
+		waves, _ := cmd.Flags().GetIntSlice("waves")
+		soak, _ := cmd.Flags().GetDuration("soak")
+
+		if version == "" {
+			utils.Fatal("Version must be specified using --version flag")
+		}
+		if soak < time.Second {
+			utils.Fatal("The soak period must be at least one second")
+		}
+		percentages := make([]int32, len(waves))
+		for i, p := range waves {
+			percentages[i] = int32(p)
+		}
+
+		client, ctx := getUpgradeCampaignClient(cloudAddr)
+		c, err := client.CreateUpgradeCampaign(ctx, &cloudpb.CreateUpgradeCampaignRequest{
+			Version:         version,
+			Selector:        selector,
+			CanaryCount:     int32(canary),
+			WavePercentages: percentages,
+			SoakPeriodS:     int64(soak.Seconds()),
+		})
+		if err != nil {
+			utils.WithError(err).Fatal("Failed to create upgrade campaign")
+		}
+		utils.Infof("Started upgrade campaign %s to upgrade %d cluster(s) to %s in %d wave(s)",
+			utils2.UUIDFromProtoOrNil(c.ID), len(c.Clusters), c.Version, c.NumWaves)
+	},
+}
+
+// ListUpgradeCampaignCmd is the list sub-command of UpgradeCampaign.
+var ListUpgradeCampaignCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the upgrade campaigns of the org",
+	Run: func(cmd *cobra.Command, args []string) {
+		cloudAddr := viper.GetString("cloud_addr")
+		format, _ := cmd.Flags().GetString("output")
+		format = strings.ToLower(format)
+
+		client, ctx := getUpgradeCampaignClient(cloudAddr)
+		resp, err := client.ListUpgradeCampaigns(ctx, &cloudpb.ListUpgradeCampaignsRequest{})
+		if err != nil {
+			utils.WithError(err).Fatal("Failed to list upgrade campaigns")
+		}
+
+		w := components.CreateStreamWriter(format, os.Stdout)
+		defer w.Finish()
+		w.SetHeader("upgrade-campaigns", []string{"ID", "Version", "Selector", "State", "Wave", "CreatedAt", "Message"})
+		for _, c := range resp.Campaigns {
+			_ = w.Write([]interface{}{utils2.UUIDFromProtoOrNil(c.ID), c.Version, c.Selector,
+				strings.TrimPrefix(c.State.String(), "UCS_"), formatCampaignWave(c), c.CreatedAt, c.StatusMessage})
+		}
+	},
+}
+
+// GetUpgradeCampaignCmd is the get sub-command of UpgradeCampaign.
+var GetUpgradeCampaignCmd = &cobra.Command{
+	Use:   "get ID",
+	Short: "Get the progress of each cluster in an upgrade campaign",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cloudAddr := viper.GetString("cloud_addr")
+		format, _ := cmd.Flags().GetString("output")
+		format = strings.ToLower(format)
+
+		id := mustParseUpgradeCampaignID(args[0])
+		client, ctx := getUpgradeCampaignClient(cloudAddr)
+		c, err := client.GetUpgradeCampaign(ctx, &cloudpb.GetUpgradeCampaignRequest{ID: utils2.ProtoFromUUID(id)})
+		if err != nil {
+			utils.WithError(err).Fatal("Failed to get upgrade campaign")
+		}
+
+		if format == "" {
+			utils.Infof("Campaign %s to %s is %s (wave %s)", id, c.Version,
+				strings.ToLower(strings.TrimPrefix(c.State.String(), "UCS_")), formatCampaignWave(c))
+			if c.StatusMessage != "" {
+				utils.Infof("%s", c.StatusMessage)
+			}
+		}
+		w := components.CreateStreamWriter(format, os.Stdout)
+		defer w.Finish()
+		w.SetHeader("upgrade-campaign-clusters", []string{"ClusterID", "ClusterName", "Wave", "State", "UpdatedAt", "Message"})
+		for _, cc := range c.Clusters {
+			_ = w.Write([]interface{}{utils2.UUIDFromProtoOrNil(cc.ClusterID), cc.ClusterName, cc.Wave + 1,
+				strings.TrimPrefix(cc.State.String(), "UCCS_"), cc.UpdatedAt, cc.Message})
+		}
+	},
+}
+
+func newSetUpgradeCampaignStateCmd(use string, short string, state cloudpb.UpgradeCampaignState) *cobra.Command {
+	return &cobra.Command{
+		Use:   use + " ID",
+		Short: short,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			cloudAddr := viper.GetString("cloud_addr")
+			id := mustParseUpgradeCampaignID(args[0])
+
+			client, ctx := getUpgradeCampaignClient(cloudAddr)
+			_, err := client.SetUpgradeCampaignState(ctx, &cloudpb.SetUpgradeCampaignStateRequest{
+				ID:    utils2.ProtoFromUUID(id),
+				State: state,
+			})
+			if err != nil {
+				utils.WithError(err).Fatalf("Failed to %s upgrade campaign", use)
+			}
+			utils.Infof("Upgrade campaign %s is now %s", id, strings.ToLower(strings.TrimPrefix(state.String(), "UCS_")))
+		},
+	}
+}
+
+// formatCampaignWave formats the current wave of the campaign as "current/total", counting from 1.
+func formatCampaignWave(c *cloudpb.UpgradeCampaign) string {
+	return fmt.Sprintf("%d/%d", c.CurrentWave+1, c.NumWaves)
+}
+
+func mustParseUpgradeCampaignID(s string) uuid.UUID {
+	id, err := uuid.FromString(s)
+	if err != nil {
+		utils.Fatal("Malformed upgrade campaign ID")
+	}
+	return id
+}
+
+func getUpgradeCampaignClient(cloudAddr string) (cloudpb.VizierUpgradeCampaignServiceClient, context.Context) {
+	cloudConn, err := utils.GetCloudClientConnection(cloudAddr)
+	if err != nil {
+		// Using log.Fatal rather than CLI log in order to track this unexpected error in Sentry.
+		log.WithError(err).Fatal("Failed to connect to cloud")
+	}
+	return cloudpb.NewVizierUpgradeCampaignServiceClient(cloudConn), auth.CtxWithCreds(context.Background())
+}
@@ -0,0 +1,143 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gogo/protobuf/types"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"px.dev/pixie/src/api/proto/cloudpb"
+	"px.dev/pixie/src/pixie_cli/pkg/auth"
+	"px.dev/pixie/src/pixie_cli/pkg/components"
+	"px.dev/pixie/src/pixie_cli/pkg/utils"
+	utils2 "px.dev/pixie/src/utils"
+)
+
+func init() {
+	UserCmd.AddCommand(ListUserCmd)
+	UserCmd.AddCommand(SetUserRoleCmd)
+
+	ListUserCmd.Flags().StringP("output", "o", "", "Output format: one of: json|proto")
+}
+
+// UserCmd is the user sub-command of the CLI.
+var UserCmd = &cobra.Command{
+	Use:   "user",
+	Short: "Manage the users in your Pixie org",
+	Run: func(cmd *cobra.Command, args []string) {
+		utils.Info("Nothing here... Please execute one of the subcommands")
+		cmd.Help()
+	},
+}
+
+// ListUserCmd is the List sub-command of User.
+var ListUserCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the users in your org and their roles",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		viper.BindPFlag("output", cmd.Flags().Lookup("output"))
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		cloudAddr := viper.GetString("cloud_addr")
+		format, _ := cmd.Flags().GetString("output")
+		format = strings.ToLower(format)
+
+		users, err := listOrgUsers(cloudAddr)
+		if err != nil {
+			// Using log.Fatal rather than CLI log in order to track this unexpected error in Sentry.
+			log.WithError(err).Fatal("Failed to list users")
+		}
+		w := components.CreateStreamWriter(format, os.Stdout)
+		defer w.Finish()
+		w.SetHeader("users", []string{"ID", "Name", "Email", "Role", "Approved"})
+		for _, u := range users {
+			_ = w.Write([]interface{}{utils2.UUIDFromProtoOrNil(u.ID), fmt.Sprintf("%s %s", u.FirstName, u.LastName),
+				u.Email, u.OrgRole, u.IsApproved})
+		}
+	},
+}
+
+// SetUserRoleCmd is the set-role sub-command of User.
+var SetUserRoleCmd = &cobra.Command{
+	Use:   "set-role <email> <owner|admin|member|viewer>",
+	Short: "Change the role of a user in your org",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		cloudAddr := viper.GetString("cloud_addr")
+		email, role := args[0], strings.ToLower(args[1])
+
+		users, err := listOrgUsers(cloudAddr)
+		if err != nil {
+			log.WithError(err).Fatal("Failed to list users")
+		}
+		var user *cloudpb.UserInfo
+		for _, u := range users {
+			if strings.EqualFold(u.Email, email) {
+				user = u
+				break
+			}
+		}
+		if user == nil {
+			utils.Fatalf("No user with email %s in your org", email)
+		}
+
+		client, ctx := getUserClientAndContext(cloudAddr)
+		_, err = client.UpdateUser(ctx, &cloudpb.UpdateUserRequest{
+			ID:      user.ID,
+			OrgRole: &types.StringValue{Value: role},
+		})
+		if err != nil {
+			utils.WithError(err).Fatal("Failed to update the user's role")
+		}
+		utils.Infof("%s is now a %s of the org", email, role)
+	},
+}
+
+func getUserClientAndContext(cloudAddr string) (cloudpb.UserServiceClient, context.Context) {
+	cloudConn, err := utils.GetCloudClientConnection(cloudAddr)
+	if err != nil {
+		// Using log.Fatal rather than CLI log in order to track this unexpected error in Sentry.
+		log.Fatalln(err)
+	}
+	return cloudpb.NewUserServiceClient(cloudConn), auth.CtxWithCreds(context.Background())
+}
+
+func listOrgUsers(cloudAddr string) ([]*cloudpb.UserInfo, error) {
+	cloudConn, err := utils.GetCloudClientConnection(cloudAddr)
+	if err != nil {
+		return nil, err
+	}
+	orgClient := cloudpb.NewOrganizationServiceClient(cloudConn)
+
+	authInfo := auth.MustLoadDefaultCredentials()
+	resp, err := orgClient.GetUsersInOrg(auth.CtxWithCreds(context.Background()), &cloudpb.GetUsersInOrgRequest{
+		OrgID: utils2.ProtoFromUUIDStrOrNil(authInfo.OrgID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Users, nil
+}
@@ -447,3 +447,10 @@ func (c *Connector) DebugPodsRequest(ctx context.Context) (chan *DebugPodsRespon
 	}()
 	return results, nil
 }
+
+// K8sHistoryRequest queries the history of the K8s objects observed by Vizier.
+func (c *Connector) K8sHistoryRequest(ctx context.Context, req *vizierpb.GetK8SHistoryRequest) (*vizierpb.GetK8SHistoryResponse, error) {
+	req.ClusterID = c.id.String()
+	ctx = auth.CtxWithCreds(ctx)
+	return c.vz.GetK8SHistory(ctx, req)
+}
@@ -44,9 +44,14 @@ func NewLister(cloudAddr string) (*Lister, error) {
 
 // GetViziersInfo returns information about connected viziers.
 func (l *Lister) GetViziersInfo() ([]*cloudpb.ClusterInfo, error) {
+	return l.GetViziersInfoBySelector("")
+}
+
+// GetViziersInfoBySelector returns information about connected viziers whose tags match the selector.
+func (l *Lister) GetViziersInfoBySelector(selector string) ([]*cloudpb.ClusterInfo, error) {
 	ctx := auth.CtxWithCreds(context.Background())
 
-	c, err := l.vc.GetClusterInfo(ctx, &cloudpb.GetClusterInfoRequest{})
+	c, err := l.vc.GetClusterInfo(ctx, &cloudpb.GetClusterInfoRequest{Selector: selector})
 	if err != nil {
 		return nil, err
 	}
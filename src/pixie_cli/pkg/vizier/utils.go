@@ -296,7 +296,42 @@ func ConnectToAllViziers(cloudAddr string) ([]*Connector, error) {
 	if err != nil {
 		return nil, err
 	}
+	return connectToHealthyViziers(cloudAddr, vzInfos)
+}
+
+// MustConnectToViziersBySelector connects to the healthy viziers whose tags match the selector, and
+// exits if there are none.
+func MustConnectToViziersBySelector(cloudAddr string, selector string) []*Connector {
+	c, err := ConnectToViziersBySelector(cloudAddr, selector)
+	if err != nil {
+		cliUtils.WithError(err).Fatal("Failed to connect to vizier")
+	}
+	return c
+}
+
+// ConnectToViziersBySelector connects to the healthy viziers whose tags match the selector.
+func ConnectToViziersBySelector(cloudAddr string, selector string) ([]*Connector, error) {
+	l, err := NewLister(cloudAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	vzInfos, err := l.GetViziersInfoBySelector(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	conns, err := connectToHealthyViziers(cloudAddr, vzInfos)
+	if err != nil {
+		return nil, err
+	}
+	if len(conns) == 0 {
+		return nil, fmt.Errorf("no healthy Viziers match the selector %q", selector)
+	}
+	return conns, nil
+}
 
+func connectToHealthyViziers(cloudAddr string, vzInfos []*cloudpb.ClusterInfo) ([]*Connector, error) {
 	var conns []*Connector
 	for _, vzInfo := range vzInfos {
 		if vzInfo.Status != cloudpb.CS_HEALTHY && vzInfo.Status != cloudpb.CS_DEGRADED {
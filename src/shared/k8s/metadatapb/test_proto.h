@@ -319,6 +319,50 @@ conditions: {
 }
 )";
 
+/*
+ *  Templates for a pod owned by a custom resource, through a replica set.
+ */
+const char* kRolloutOwnerObjectUpdatePbTxt = R"(
+uid: "rollout_uid"
+name: "rollout1"
+namespace: "pl"
+start_timestamp_ns: 101
+api_version: "argoproj.io/v1alpha1"
+kind: "Rollout"
+labels: {
+  key: "app"
+  value: "rollout1"
+}
+)";
+
+const char* kRolloutReplicaSetUpdatePbTxt = R"(
+uid: "rollout_rs_uid"
+name: "rollout1-rs"
+namespace: "pl"
+start_timestamp_ns: 101
+replicas: 1
+owner_references: {
+  kind: "Rollout"
+  name: "rollout1"
+  uid: "rollout_uid"
+}
+)";
+
+const char* kRolloutPodUpdatePbTxt = R"(
+uid: "rollout_pod_uid"
+name: "rollout1-rs-pod"
+namespace: "pl"
+start_timestamp_ns: 105
+node_name: "test_node"
+hostname: "test_host"
+phase: RUNNING
+owner_references: {
+  uid: "rollout_rs_uid"
+  name: "rollout1-rs"
+  kind: "ReplicaSet"
+}
+)";
+
 std::unique_ptr<px::shared::k8s::metadatapb::ResourceUpdate> CreateRunningPodUpdatePB() {
   auto update = std::make_unique<px::shared::k8s::metadatapb::ResourceUpdate>();
   auto update_proto = absl::Substitute(kResourceUpdateTmpl, "pod_update", kRunningPodUpdatePbTxt);
@@ -471,6 +515,31 @@ std::unique_ptr<px::shared::k8s::metadatapb::ResourceUpdate> CreateTerminatedDep
   return update;
 }
 
+std::unique_ptr<px::shared::k8s::metadatapb::ResourceUpdate> CreateRolloutOwnerObjectUpdatePB() {
+  auto update = std::make_unique<px::shared::k8s::metadatapb::ResourceUpdate>();
+  auto update_proto = absl::Substitute(kResourceUpdateTmpl, "owner_object_update",
+                                       kRolloutOwnerObjectUpdatePbTxt);
+  CHECK(google::protobuf::TextFormat::MergeFromString(update_proto, update.get()))
+      << "Failed to parse proto";
+  return update;
+}
+
+std::unique_ptr<px::shared::k8s::metadatapb::ResourceUpdate> CreateRolloutReplicaSetUpdatePB() {
+  auto update = std::make_unique<px::shared::k8s::metadatapb::ResourceUpdate>();
+  auto update_proto =
+      absl::Substitute(kResourceUpdateTmpl, "replica_set_update", kRolloutReplicaSetUpdatePbTxt);
+  CHECK(google::protobuf::TextFormat::MergeFromString(update_proto, update.get()))
+      << "Failed to parse proto";
+  return update;
+}
+
+std::unique_ptr<px::shared::k8s::metadatapb::ResourceUpdate> CreateRolloutPodUpdatePB() {
+  auto update = std::make_unique<px::shared::k8s::metadatapb::ResourceUpdate>();
+  auto update_proto = absl::Substitute(kResourceUpdateTmpl, "pod_update", kRolloutPodUpdatePbTxt);
+  CHECK(google::protobuf::TextFormat::MergeFromString(update_proto, update.get()))
+      << "Failed to parse proto";
+  return update;
+}
 }  // namespace testutils
 }  // namespace metadatapb
 }  // namespace px
@@ -22,8 +22,11 @@ import (
 	"fmt"
 
 	apps "k8s.io/api/apps/v1"
+	batch "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 
@@ -817,3 +820,310 @@ func DeploymentToProto(d *apps.Deployment) *metadatapb.Deployment {
 		Status:   DeploymentStatusToProto(&d.Status),
 	}
 }
+
+// timeToUnixNano converts an optional k8s time to unix nanoseconds, returning 0 if it is unset.
+func timeToUnixNano(t *metav1.Time) int64 {
+	if t == nil {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// StatefulSetSpecToProto converts apps.StatefulSetSpec to proto.
+func StatefulSetSpecToProto(s *apps.StatefulSetSpec) *metadatapb.StatefulSetSpec {
+	var replicas int32 = 1
+	if s.Replicas != nil {
+		replicas = *s.Replicas
+	}
+
+	return &metadatapb.StatefulSetSpec{
+		Replicas:            replicas,
+		Selector:            LabelSelectorToProto(s.Selector),
+		Template:            PodTemplateSpecToProto(s.Template),
+		ServiceName:         s.ServiceName,
+		PodManagementPolicy: string(s.PodManagementPolicy),
+		UpdateStrategy:      string(s.UpdateStrategy.Type),
+		MinReadySeconds:     s.MinReadySeconds,
+	}
+}
+
+// StatefulSetStatusToProto converts apps.StatefulSetStatus to proto.
+func StatefulSetStatusToProto(s *apps.StatefulSetStatus) *metadatapb.StatefulSetStatus {
+	return &metadatapb.StatefulSetStatus{
+		ObservedGeneration: s.ObservedGeneration,
+		Replicas:           s.Replicas,
+		ReadyReplicas:      s.ReadyReplicas,
+		CurrentReplicas:    s.CurrentReplicas,
+		UpdatedReplicas:    s.UpdatedReplicas,
+		AvailableReplicas:  s.AvailableReplicas,
+		CurrentRevision:    s.CurrentRevision,
+		UpdateRevision:     s.UpdateRevision,
+	}
+}
+
+// StatefulSetToProto converts apps.StatefulSet to proto.
+func StatefulSetToProto(s *apps.StatefulSet) *metadatapb.StatefulSet {
+	return &metadatapb.StatefulSet{
+		Metadata: ObjectMetadataToProto(&s.ObjectMeta),
+		Spec:     StatefulSetSpecToProto(&s.Spec),
+		Status:   StatefulSetStatusToProto(&s.Status),
+	}
+}
+
+// DaemonSetSpecToProto converts apps.DaemonSetSpec to proto.
+func DaemonSetSpecToProto(d *apps.DaemonSetSpec) *metadatapb.DaemonSetSpec {
+	return &metadatapb.DaemonSetSpec{
+		Selector:        LabelSelectorToProto(d.Selector),
+		Template:        PodTemplateSpecToProto(d.Template),
+		UpdateStrategy:  string(d.UpdateStrategy.Type),
+		MinReadySeconds: d.MinReadySeconds,
+	}
+}
+
+// DaemonSetStatusToProto converts apps.DaemonSetStatus to proto.
+func DaemonSetStatusToProto(d *apps.DaemonSetStatus) *metadatapb.DaemonSetStatus {
+	return &metadatapb.DaemonSetStatus{
+		CurrentNumberScheduled: d.CurrentNumberScheduled,
+		NumberMisscheduled:     d.NumberMisscheduled,
+		DesiredNumberScheduled: d.DesiredNumberScheduled,
+		NumberReady:            d.NumberReady,
+		ObservedGeneration:     d.ObservedGeneration,
+		UpdatedNumberScheduled: d.UpdatedNumberScheduled,
+		NumberAvailable:        d.NumberAvailable,
+		NumberUnavailable:      d.NumberUnavailable,
+	}
+}
+
+// DaemonSetToProto converts apps.DaemonSet to proto.
+func DaemonSetToProto(d *apps.DaemonSet) *metadatapb.DaemonSet {
+	return &metadatapb.DaemonSet{
+		Metadata: ObjectMetadataToProto(&d.ObjectMeta),
+		Spec:     DaemonSetSpecToProto(&d.Spec),
+		Status:   DaemonSetStatusToProto(&d.Status),
+	}
+}
+
+// JobSpecToProto converts batch.JobSpec to proto.
+func JobSpecToProto(j *batch.JobSpec) *metadatapb.JobSpec {
+	var parallelism, completions, backoffLimit int32
+	var activeDeadlineSeconds int64
+	if j.Parallelism != nil {
+		parallelism = *j.Parallelism
+	}
+	if j.Completions != nil {
+		completions = *j.Completions
+	}
+	if j.BackoffLimit != nil {
+		backoffLimit = *j.BackoffLimit
+	}
+	if j.ActiveDeadlineSeconds != nil {
+		activeDeadlineSeconds = *j.ActiveDeadlineSeconds
+	}
+
+	var completionMode string
+	if j.CompletionMode != nil {
+		completionMode = string(*j.CompletionMode)
+	}
+
+	var selector *metadatapb.LabelSelector
+	if j.Selector != nil {
+		selector = LabelSelectorToProto(j.Selector)
+	}
+
+	return &metadatapb.JobSpec{
+		Parallelism:           parallelism,
+		Completions:           completions,
+		ActiveDeadlineSeconds: activeDeadlineSeconds,
+		BackoffLimit:          backoffLimit,
+		Selector:              selector,
+		Template:              PodTemplateSpecToProto(j.Template),
+		CompletionMode:        completionMode,
+		Suspend:               j.Suspend != nil && *j.Suspend,
+	}
+}
+
+// JobStatusToProto converts batch.JobStatus to proto.
+func JobStatusToProto(j *batch.JobStatus) *metadatapb.JobStatus {
+	var conditions []*metadatapb.JobCondition
+	for _, c := range j.Conditions {
+		conditions = append(conditions, &metadatapb.JobCondition{
+			Type:                 string(c.Type),
+			Status:               conditionStatusObjToPbMap[c.Status],
+			LastProbeTimeNS:      c.LastProbeTime.UnixNano(),
+			LastTransitionTimeNS: c.LastTransitionTime.UnixNano(),
+			Reason:               c.Reason,
+			Message:              c.Message,
+		})
+	}
+
+	return &metadatapb.JobStatus{
+		StartTimeNS:      timeToUnixNano(j.StartTime),
+		CompletionTimeNS: timeToUnixNano(j.CompletionTime),
+		Active:           j.Active,
+		Succeeded:        j.Succeeded,
+		Failed:           j.Failed,
+		Conditions:       conditions,
+	}
+}
+
+// JobToProto converts batch.Job to proto.
+func JobToProto(j *batch.Job) *metadatapb.Job {
+	return &metadatapb.Job{
+		Metadata: ObjectMetadataToProto(&j.ObjectMeta),
+		Spec:     JobSpecToProto(&j.Spec),
+		Status:   JobStatusToProto(&j.Status),
+	}
+}
+
+// CronJobSpecToProto converts batch.CronJobSpec to proto.
+func CronJobSpecToProto(c *batch.CronJobSpec) *metadatapb.CronJobSpec {
+	var startingDeadlineSeconds int64
+	var successfulJobsHistoryLimit, failedJobsHistoryLimit int32
+	if c.StartingDeadlineSeconds != nil {
+		startingDeadlineSeconds = *c.StartingDeadlineSeconds
+	}
+	if c.SuccessfulJobsHistoryLimit != nil {
+		successfulJobsHistoryLimit = *c.SuccessfulJobsHistoryLimit
+	}
+	if c.FailedJobsHistoryLimit != nil {
+		failedJobsHistoryLimit = *c.FailedJobsHistoryLimit
+	}
+
+	return &metadatapb.CronJobSpec{
+		Schedule:                   c.Schedule,
+		ConcurrencyPolicy:          string(c.ConcurrencyPolicy),
+		Suspend:                    c.Suspend != nil && *c.Suspend,
+		StartingDeadlineSeconds:    startingDeadlineSeconds,
+		SuccessfulJobsHistoryLimit: successfulJobsHistoryLimit,
+		FailedJobsHistoryLimit:     failedJobsHistoryLimit,
+	}
+}
+
+// CronJobStatusToProto converts batch.CronJobStatus to proto.
+func CronJobStatusToProto(c *batch.CronJobStatus) *metadatapb.CronJobStatus {
+	active := make([]*metadatapb.ObjectReference, len(c.Active))
+	for i := range c.Active {
+		active[i] = ObjectReferenceToProto(&c.Active[i])
+	}
+
+	return &metadatapb.CronJobStatus{
+		Active:               active,
+		LastScheduleTimeNS:   timeToUnixNano(c.LastScheduleTime),
+		LastSuccessfulTimeNS: timeToUnixNano(c.LastSuccessfulTime),
+	}
+}
+
+// CronJobToProto converts batch.CronJob to proto.
+func CronJobToProto(c *batch.CronJob) *metadatapb.CronJob {
+	return &metadatapb.CronJob{
+		Metadata: ObjectMetadataToProto(&c.ObjectMeta),
+		Spec:     CronJobSpecToProto(&c.Spec),
+		Status:   CronJobStatusToProto(&c.Status),
+	}
+}
+
+// IngressBackendToProto converts networking.IngressBackend to proto. Only service backends are
+// supported, other backends are converted to nil.
+func IngressBackendToProto(b *networking.IngressBackend) *metadatapb.IngressBackend {
+	if b == nil || b.Service == nil {
+		return nil
+	}
+
+	port := b.Service.Port.Name
+	if port == "" {
+		port = fmt.Sprint(b.Service.Port.Number)
+	}
+	return &metadatapb.IngressBackend{
+		ServiceName: b.Service.Name,
+		ServicePort: port,
+	}
+}
+
+// IngressSpecToProto converts networking.IngressSpec to proto.
+func IngressSpecToProto(i *networking.IngressSpec) *metadatapb.IngressSpec {
+	var className string
+	if i.IngressClassName != nil {
+		className = *i.IngressClassName
+	}
+
+	tls := make([]*metadatapb.IngressTLS, len(i.TLS))
+	for j, t := range i.TLS {
+		tls[j] = &metadatapb.IngressTLS{
+			Hosts:      t.Hosts,
+			SecretName: t.SecretName,
+		}
+	}
+
+	rules := make([]*metadatapb.IngressRule, len(i.Rules))
+	for j, r := range i.Rules {
+		rule := &metadatapb.IngressRule{
+			Host: r.Host,
+		}
+		if r.HTTP != nil {
+			for k := range r.HTTP.Paths {
+				p := &r.HTTP.Paths[k]
+				var pathType string
+				if p.PathType != nil {
+					pathType = string(*p.PathType)
+				}
+				rule.Paths = append(rule.Paths, &metadatapb.HTTPIngressPath{
+					Path:     p.Path,
+					PathType: pathType,
+					Backend:  IngressBackendToProto(&p.Backend),
+				})
+			}
+		}
+		rules[j] = rule
+	}
+
+	return &metadatapb.IngressSpec{
+		IngressClassName: className,
+		DefaultBackend:   IngressBackendToProto(i.DefaultBackend),
+		TLS:              tls,
+		Rules:            rules,
+	}
+}
+
+// IngressStatusToProto converts networking.IngressStatus to proto.
+func IngressStatusToProto(i *networking.IngressStatus) *metadatapb.IngressStatus {
+	status := &metadatapb.IngressStatus{}
+	for _, lb := range i.LoadBalancer.Ingress {
+		if lb.IP != "" {
+			status.LoadBalancerIPs = append(status.LoadBalancerIPs, lb.IP)
+		}
+		if lb.Hostname != "" {
+			status.LoadBalancerHostnames = append(status.LoadBalancerHostnames, lb.Hostname)
+		}
+	}
+	return status
+}
+
+// IngressToProto converts networking.Ingress to proto.
+func IngressToProto(i *networking.Ingress) *metadatapb.Ingress {
+	return &metadatapb.Ingress{
+		Metadata: ObjectMetadataToProto(&i.ObjectMeta),
+		Spec:     IngressSpecToProto(&i.Spec),
+		Status:   IngressStatusToProto(&i.Status),
+	}
+}
+
+// OwnerObjectToProto converts an arbitrary k8s object, such as a custom resource, to proto. Only
+// the metadata needed to resolve the object as the owner of other objects is kept.
+func OwnerObjectToProto(u *unstructured.Unstructured) *metadatapb.OwnerObject {
+	objMeta := metav1.ObjectMeta{
+		Name:              u.GetName(),
+		Namespace:         u.GetNamespace(),
+		UID:               u.GetUID(),
+		ResourceVersion:   u.GetResourceVersion(),
+		CreationTimestamp: u.GetCreationTimestamp(),
+		DeletionTimestamp: u.GetDeletionTimestamp(),
+		Labels:            u.GetLabels(),
+		OwnerReferences:   u.GetOwnerReferences(),
+	}
+
+	return &metadatapb.OwnerObject{
+		Metadata:   ObjectMetadataToProto(&objMeta),
+		APIVersion: u.GetAPIVersion(),
+		Kind:       u.GetKind(),
+	}
+}
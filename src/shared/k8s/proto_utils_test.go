@@ -26,8 +26,11 @@ import (
 	"github.com/gogo/protobuf/proto"
 	"github.com/stretchr/testify/assert"
 	apps "k8s.io/api/apps/v1"
+	batch "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
 
 	"px.dev/pixie/src/shared/k8s"
@@ -1875,3 +1878,334 @@ func TestDeploymentToProto(t *testing.T) {
 	t.Logf("%v\n", expectedPb)
 	assert.Equal(t, expectedPb, oPb)
 }
+
+func TestStatefulSetToProto(t *testing.T) {
+	var replicas int32 = 3
+	o := apps.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "statefulset_1",
+			Namespace:         "a_namespace",
+			UID:               "ijkl",
+			CreationTimestamp: metav1.Unix(0, 4),
+		},
+		Spec: apps.StatefulSetSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"app": "db"},
+			},
+			ServiceName:         "db",
+			PodManagementPolicy: apps.OrderedReadyPodManagement,
+			UpdateStrategy: apps.StatefulSetUpdateStrategy{
+				Type: apps.RollingUpdateStatefulSetStrategyType,
+			},
+		},
+		Status: apps.StatefulSetStatus{
+			ObservedGeneration: 2,
+			Replicas:           3,
+			ReadyReplicas:      2,
+			CurrentReplicas:    3,
+			UpdatedReplicas:    1,
+			AvailableReplicas:  2,
+			CurrentRevision:    "db-1",
+			UpdateRevision:     "db-2",
+		},
+	}
+
+	oPb := k8s.StatefulSetToProto(&o)
+	assert.Equal(t, "statefulset_1", oPb.Metadata.Name)
+	assert.Equal(t, int64(4), oPb.Metadata.CreationTimestampNS)
+	assert.Equal(t, &metadatapb.LabelSelector{
+		MatchLabels:      map[string]string{"app": "db"},
+		MatchExpressions: []*metadatapb.LabelSelectorRequirement{},
+	}, oPb.Spec.Selector)
+	assert.Equal(t, int32(3), oPb.Spec.Replicas)
+	assert.Equal(t, "db", oPb.Spec.ServiceName)
+	assert.Equal(t, "OrderedReady", oPb.Spec.PodManagementPolicy)
+	assert.Equal(t, "RollingUpdate", oPb.Spec.UpdateStrategy)
+	assert.Equal(t, &metadatapb.StatefulSetStatus{
+		ObservedGeneration: 2,
+		Replicas:           3,
+		ReadyReplicas:      2,
+		CurrentReplicas:    3,
+		UpdatedReplicas:    1,
+		AvailableReplicas:  2,
+		CurrentRevision:    "db-1",
+		UpdateRevision:     "db-2",
+	}, oPb.Status)
+}
+
+func TestDaemonSetToProto(t *testing.T) {
+	o := apps.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "daemonset_1",
+			Namespace: "a_namespace",
+			UID:       "mnop",
+		},
+		Spec: apps.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"app": "agent"},
+			},
+			UpdateStrategy: apps.DaemonSetUpdateStrategy{
+				Type: apps.OnDeleteDaemonSetStrategyType,
+			},
+			MinReadySeconds: 5,
+		},
+		Status: apps.DaemonSetStatus{
+			CurrentNumberScheduled: 3,
+			NumberMisscheduled:     1,
+			DesiredNumberScheduled: 3,
+			NumberReady:            2,
+			ObservedGeneration:     4,
+			UpdatedNumberScheduled: 3,
+			NumberAvailable:        2,
+			NumberUnavailable:      1,
+		},
+	}
+
+	oPb := k8s.DaemonSetToProto(&o)
+	assert.Equal(t, "mnop", oPb.Metadata.UID)
+	assert.Equal(t, "OnDelete", oPb.Spec.UpdateStrategy)
+	assert.Equal(t, int32(5), oPb.Spec.MinReadySeconds)
+	assert.Equal(t, &metadatapb.DaemonSetStatus{
+		CurrentNumberScheduled: 3,
+		NumberMisscheduled:     1,
+		DesiredNumberScheduled: 3,
+		NumberReady:            2,
+		ObservedGeneration:     4,
+		UpdatedNumberScheduled: 3,
+		NumberAvailable:        2,
+		NumberUnavailable:      1,
+	}, oPb.Status)
+}
+
+func TestJobToProto(t *testing.T) {
+	var parallelism, completions int32 = 2, 4
+	startTime := metav1.Unix(0, 10)
+	completionTime := metav1.Unix(0, 20)
+	o := batch.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "job_1",
+			Namespace: "a_namespace",
+			UID:       "qrst",
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					Kind: "CronJob",
+					Name: "cronjob_1",
+					UID:  "uvwx",
+				},
+			},
+		},
+		Spec: batch.JobSpec{
+			Parallelism: &parallelism,
+			Completions: &completions,
+		},
+		Status: batch.JobStatus{
+			StartTime:      &startTime,
+			CompletionTime: &completionTime,
+			Succeeded:      4,
+			Conditions: []batch.JobCondition{
+				{
+					Type:               batch.JobComplete,
+					Status:             v1.ConditionTrue,
+					LastProbeTime:      metav1.Unix(0, 20),
+					LastTransitionTime: metav1.Unix(0, 20),
+				},
+			},
+		},
+	}
+
+	oPb := k8s.JobToProto(&o)
+	assert.Equal(t, []*metadatapb.OwnerReference{{Kind: "CronJob", Name: "cronjob_1", UID: "uvwx"}}, oPb.Metadata.OwnerReferences)
+	assert.Equal(t, int32(2), oPb.Spec.Parallelism)
+	assert.Equal(t, int32(4), oPb.Spec.Completions)
+	// An unset selector is left unset, rather than matching everything.
+	assert.Nil(t, oPb.Spec.Selector)
+	assert.False(t, oPb.Spec.Suspend)
+	assert.Equal(t, &metadatapb.JobStatus{
+		StartTimeNS:      10,
+		CompletionTimeNS: 20,
+		Succeeded:        4,
+		Conditions: []*metadatapb.JobCondition{
+			{
+				Type:                 "Complete",
+				Status:               metadatapb.CONDITION_STATUS_TRUE,
+				LastProbeTimeNS:      20,
+				LastTransitionTimeNS: 20,
+			},
+		},
+	}, oPb.Status)
+}
+
+func TestCronJobToProto(t *testing.T) {
+	suspend := true
+	var successfulJobsHistoryLimit int32 = 3
+	lastScheduleTime := metav1.Unix(0, 30)
+	o := batch.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "cronjob_1",
+			Namespace: "a_namespace",
+			UID:       "uvwx",
+		},
+		Spec: batch.CronJobSpec{
+			Schedule:                   "*/5 * * * *",
+			ConcurrencyPolicy:          batch.ForbidConcurrent,
+			Suspend:                    &suspend,
+			SuccessfulJobsHistoryLimit: &successfulJobsHistoryLimit,
+		},
+		Status: batch.CronJobStatus{
+			Active: []v1.ObjectReference{
+				{
+					Kind:      "Job",
+					Namespace: "a_namespace",
+					Name:      "job_1",
+					UID:       "qrst",
+				},
+			},
+			LastScheduleTime: &lastScheduleTime,
+		},
+	}
+
+	oPb := k8s.CronJobToProto(&o)
+	assert.Equal(t, &metadatapb.CronJobSpec{
+		Schedule:                   "*/5 * * * *",
+		ConcurrencyPolicy:          "Forbid",
+		Suspend:                    true,
+		SuccessfulJobsHistoryLimit: 3,
+	}, oPb.Spec)
+	assert.Equal(t, &metadatapb.CronJobStatus{
+		Active: []*metadatapb.ObjectReference{
+			{
+				Kind:      "Job",
+				Namespace: "a_namespace",
+				Name:      "job_1",
+				UID:       "qrst",
+			},
+		},
+		LastScheduleTimeNS: 30,
+	}, oPb.Status)
+}
+
+func TestIngressToProto(t *testing.T) {
+	className := "nginx"
+	pathType := networking.PathTypePrefix
+	o := networking.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "ingress_1",
+			Namespace: "a_namespace",
+			UID:       "yzab",
+		},
+		Spec: networking.IngressSpec{
+			IngressClassName: &className,
+			DefaultBackend: &networking.IngressBackend{
+				Service: &networking.IngressServiceBackend{
+					Name: "default",
+					Port: networking.ServiceBackendPort{Number: 80},
+				},
+			},
+			TLS: []networking.IngressTLS{
+				{
+					Hosts:      []string{"example.com"},
+					SecretName: "tls-secret",
+				},
+			},
+			Rules: []networking.IngressRule{
+				{
+					Host: "example.com",
+					IngressRuleValue: networking.IngressRuleValue{
+						HTTP: &networking.HTTPIngressRuleValue{
+							Paths: []networking.HTTPIngressPath{
+								{
+									Path:     "/api",
+									PathType: &pathType,
+									Backend: networking.IngressBackend{
+										Service: &networking.IngressServiceBackend{
+											Name: "api",
+											Port: networking.ServiceBackendPort{Name: "http"},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		Status: networking.IngressStatus{
+			LoadBalancer: v1.LoadBalancerStatus{
+				Ingress: []v1.LoadBalancerIngress{
+					{IP: "1.2.3.4"},
+					{Hostname: "lb.example.com"},
+				},
+			},
+		},
+	}
+
+	oPb := k8s.IngressToProto(&o)
+	assert.Equal(t, &metadatapb.IngressSpec{
+		IngressClassName: "nginx",
+		DefaultBackend: &metadatapb.IngressBackend{
+			ServiceName: "default",
+			ServicePort: "80",
+		},
+		TLS: []*metadatapb.IngressTLS{
+			{
+				Hosts:      []string{"example.com"},
+				SecretName: "tls-secret",
+			},
+		},
+		Rules: []*metadatapb.IngressRule{
+			{
+				Host: "example.com",
+				Paths: []*metadatapb.HTTPIngressPath{
+					{
+						Path:     "/api",
+						PathType: "Prefix",
+						Backend: &metadatapb.IngressBackend{
+							ServiceName: "api",
+							ServicePort: "http",
+						},
+					},
+				},
+			},
+		},
+	}, oPb.Spec)
+	assert.Equal(t, &metadatapb.IngressStatus{
+		LoadBalancerIPs:       []string{"1.2.3.4"},
+		LoadBalancerHostnames: []string{"lb.example.com"},
+	}, oPb.Status)
+}
+
+func TestOwnerObjectToProto(t *testing.T) {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion("argoproj.io/v1alpha1")
+	u.SetKind("Rollout")
+	u.SetName("rollout_1")
+	u.SetNamespace("a_namespace")
+	u.SetUID("abcd")
+	// Timestamps of unstructured objects only have second precision.
+	u.SetCreationTimestamp(metav1.Unix(4, 0))
+	u.SetLabels(map[string]string{"app": "web"})
+	u.SetAnnotations(map[string]string{"kubectl.kubernetes.io/last-applied-configuration": "{}"})
+	u.SetOwnerReferences([]metav1.OwnerReference{
+		{
+			Kind: "Application",
+			Name: "app_1",
+			UID:  "efgh",
+		},
+	})
+
+	assert.Equal(t, &metadatapb.OwnerObject{
+		Metadata: &metadatapb.ObjectMetadata{
+			Name:                "rollout_1",
+			Namespace:           "a_namespace",
+			UID:                 "abcd",
+			CreationTimestampNS: 4000000000,
+			Labels:              map[string]string{"app": "web"},
+			OwnerReferences: []*metadatapb.OwnerReference{
+				{Kind: "Application", Name: "app_1", UID: "efgh"},
+			},
+		},
+		APIVersion: "argoproj.io/v1alpha1",
+		Kind:       "Rollout",
+	}, k8s.OwnerObjectToProto(u))
+}
@@ -65,5 +65,40 @@ std::string DeploymentInfo::DebugString(int indent) const {
                           stop_time_ns());
 }
 
+std::string_view K8sObjectTypeName(K8sObjectType type) {
+  switch (type) {
+    case K8sObjectType::kPod:
+      return "Pod";
+    case K8sObjectType::kService:
+      return "Service";
+    case K8sObjectType::kNamespace:
+      return "Namespace";
+    case K8sObjectType::kReplicaSet:
+      return "ReplicaSet";
+    case K8sObjectType::kDeployment:
+      return "Deployment";
+    case K8sObjectType::kStatefulSet:
+      return "StatefulSet";
+    case K8sObjectType::kDaemonSet:
+      return "DaemonSet";
+    case K8sObjectType::kJob:
+      return "Job";
+    case K8sObjectType::kCronJob:
+      return "CronJob";
+    case K8sObjectType::kIngress:
+      return "Ingress";
+    case K8sObjectType::kOwnerObject:
+      return "OwnerObject";
+    default:
+      return "Unknown";
+  }
+}
+
+std::string WorkloadInfo::DebugString(int indent) const {
+  std::string state = stop_time_ns() != 0 ? "S" : "R";
+  return absl::Substitute("$0<$1:ns=$2:name=$3:uid=$4:state=$5:start=$6:stop=$7>", Indent(indent),
+                          kind(), ns(), name(), uid(), state, start_time_ns(), stop_time_ns());
+}
+
 }  // namespace md
 }  // namespace px
@@ -51,7 +51,39 @@ struct OwnerReference {
 /**
  * Enum with all the different metadata types.
  */
-enum class K8sObjectType { kUnknown, kPod, kService, kNamespace, kReplicaSet, kDeployment };
+enum class K8sObjectType {
+  kUnknown,
+  kPod,
+  kService,
+  kNamespace,
+  kReplicaSet,
+  kDeployment,
+  kStatefulSet,
+  kDaemonSet,
+  kJob,
+  kCronJob,
+  kIngress,
+  kOwnerObject,
+};
+
+/**
+ * IsWorkloadType returns whether objects of the given type are tracked as WorkloadInfo.
+ */
+inline bool IsWorkloadType(K8sObjectType type) {
+  switch (type) {
+    case K8sObjectType::kStatefulSet:
+    case K8sObjectType::kDaemonSet:
+    case K8sObjectType::kJob:
+    case K8sObjectType::kCronJob:
+    case K8sObjectType::kIngress:
+    case K8sObjectType::kOwnerObject:
+      return true;
+    default:
+      return false;
+  }
+}
+
+std::string_view K8sObjectTypeName(K8sObjectType type);
 
 /**
  * Base class for all K8s metadata objects.
@@ -747,5 +779,74 @@ class DeploymentInfo : public K8sMetadataObject {
   int32_t requested_replicas_;
   DeploymentConditions conditions_;
 };
+using K8sLabels = absl::flat_hash_map<std::string, std::string>;
+
+/**
+ * WorkloadInfo contains information about the K8s objects which are only tracked by their
+ * identity, labels and owners, such as stateful sets, daemon sets, jobs, cron jobs, ingresses and
+ * custom owner objects.
+ */
+class WorkloadInfo : public K8sMetadataObject {
+ public:
+  WorkloadInfo(K8sObjectType type, UID uid, std::string_view ns, std::string_view name,
+               int64_t start_timestamp_ns = 0, int64_t stop_timestamp_ns = 0)
+      : K8sMetadataObject(type, uid, ns, name, start_timestamp_ns, stop_timestamp_ns) {
+    DCHECK(IsWorkloadType(type));
+  }
+
+  virtual ~WorkloadInfo() = default;
+
+  const K8sLabels& labels() const { return labels_; }
+  void set_labels(K8sLabels labels) { labels_ = std::move(labels); }
+
+  // The kind of the workload, as used in owner references, such as "StatefulSet".
+  virtual std::string_view kind() const { return K8sObjectTypeName(type()); }
+
+  std::unique_ptr<K8sMetadataObject> Clone() const override {
+    return std::unique_ptr<WorkloadInfo>(new WorkloadInfo(*this));
+  }
+
+  std::string DebugString(int indent = 0) const override;
+
+ protected:
+  WorkloadInfo(const WorkloadInfo& other) = default;
+  WorkloadInfo& operator=(const WorkloadInfo& other) = delete;
+
+ private:
+  K8sLabels labels_;
+};
+
+/**
+ * OwnerObjectInfo contains information about an object of a kind that isn't built into K8s, such
+ * as a custom resource, which owns other objects.
+ */
+class OwnerObjectInfo : public WorkloadInfo {
+ public:
+  OwnerObjectInfo(UID uid, std::string_view ns, std::string_view name, std::string_view api_version,
+                  std::string_view kind, int64_t start_timestamp_ns = 0,
+                  int64_t stop_timestamp_ns = 0)
+      : WorkloadInfo(K8sObjectType::kOwnerObject, uid, ns, name, start_timestamp_ns,
+                     stop_timestamp_ns),
+        api_version_(api_version),
+        kind_(kind) {}
+
+  virtual ~OwnerObjectInfo() = default;
+
+  const std::string& api_version() const { return api_version_; }
+  std::string_view kind() const override { return kind_; }
+
+  std::unique_ptr<K8sMetadataObject> Clone() const override {
+    return std::unique_ptr<OwnerObjectInfo>(new OwnerObjectInfo(*this));
+  }
+
+ protected:
+  OwnerObjectInfo(const OwnerObjectInfo& other) = default;
+  OwnerObjectInfo& operator=(const OwnerObjectInfo& other) = delete;
+
+ private:
+  std::string api_version_;
+  std::string kind_;
+};
+
 }  // namespace md
 }  // namespace px
@@ -18,6 +18,7 @@
 
 #include <memory>
 #include <string>
+#include <type_traits>
 #include <utility>
 
 #include <absl/container/flat_hash_set.h>
@@ -68,6 +69,14 @@ const DeploymentInfo* K8sMetadataState::DeploymentInfoByID(UIDView deployment_id
   return static_cast<const DeploymentInfo*>(K8sMetadataObjectByID(deployment_id, type));
 }
 
+const WorkloadInfo* K8sMetadataState::WorkloadInfoByID(UIDView workload_id) const {
+  auto it = k8s_objects_by_id_.find(workload_id);
+  if (it == k8s_objects_by_id_.end() || !IsWorkloadType(it->second->type())) {
+    return nullptr;
+  }
+  return static_cast<const WorkloadInfo*>(it->second.get());
+}
+
 const ContainerInfo* K8sMetadataState::ContainerInfoByID(CIDView id) const {
   auto it = containers_by_id_.find(id);
 
@@ -118,11 +127,31 @@ UID K8sMetadataState::DeploymentIDByName(K8sNameIdentView deployment_name) const
   return (it == deployments_by_name_.end()) ? "" : it->second;
 }
 
+UID K8sMetadataState::WorkloadIDByName(K8sObjectType type, K8sNameIdentView workload_name) const {
+  auto type_it = workloads_by_name_.find(type);
+  if (type_it == workloads_by_name_.end()) {
+    return "";
+  }
+  auto it = type_it->second.find(workload_name);
+  return (it == type_it->second.end()) ? "" : it->second;
+}
+
+absl::flat_hash_set<UID> K8sMetadataState::ObjectIDsByLabel(std::string_view key,
+                                                             std::string_view value) const {
+  auto it = objects_by_label_.find(std::make_pair(std::string(key), std::string(value)));
+  if (it == objects_by_label_.end()) {
+    return {};
+  }
+  return it->second;
+}
+
 std::unique_ptr<K8sMetadataState> K8sMetadataState::Clone() const {
   auto other = std::make_unique<K8sMetadataState>();
 
   other->pod_cidrs_ = pod_cidrs_;
   other->service_cidr_ = service_cidr_;
+  other->allowed_namespaces_ = allowed_namespaces_;
+  other->denied_namespaces_ = denied_namespaces_;
 
   other->k8s_objects_by_id_.reserve(k8s_objects_by_id_.size());
   for (const auto& [k, v] : k8s_objects_by_id_) {
@@ -139,6 +168,8 @@ std::unique_ptr<K8sMetadataState> K8sMetadataState::Clone() const {
   other->namespaces_by_name_ = namespaces_by_name_;
   other->replica_sets_by_name_ = replica_sets_by_name_;
   other->deployments_by_name_ = deployments_by_name_;
+  other->workloads_by_name_ = workloads_by_name_;
+  other->objects_by_label_ = objects_by_label_;
   other->containers_by_name_ = containers_by_name_;
   other->pods_by_ip_ = pods_by_ip_;
   other->services_by_cluster_ip_ = services_by_cluster_ip_;
@@ -176,6 +207,12 @@ std::string K8sMetadataState::DebugString(int indent_level) const {
   for (const auto& [k, v] : deployments_by_name_) {
     str += absl::Substitute("deployment_id: $0, ns: $1, name: $2\n", v, k.first, k.second);
   }
+  for (const auto& [type, by_name] : workloads_by_name_) {
+    for (const auto& [k, v] : by_name) {
+      str += absl::Substitute("$0_id: $1, ns: $2, name: $3\n", K8sObjectTypeName(type), v, k.first,
+                              k.second);
+    }
+  }
   for (const auto& [k, v] : containers_by_name_) {
     str += absl::Substitute("cid: $0, name: $1\n", v, k);
   }
@@ -413,6 +450,90 @@ Status K8sMetadataState::HandleDeploymentUpdate(const DeploymentUpdate& update)
   return Status::OK();
 }
 
+void K8sMetadataState::IndexLabels(const UID& uid, const K8sLabels& old_labels,
+                                   const K8sLabels& new_labels) {
+  for (const auto& label : old_labels) {
+    auto it = objects_by_label_.find(label);
+    if (it == objects_by_label_.end()) {
+      continue;
+    }
+    it->second.erase(uid);
+    if (it->second.empty()) {
+      objects_by_label_.erase(it);
+    }
+  }
+  for (const auto& label : new_labels) {
+    objects_by_label_[label].insert(uid);
+  }
+}
+
+template <typename TUpdate>
+Status K8sMetadataState::HandleWorkloadUpdate(K8sObjectType type, const TUpdate& update) {
+  const UID& object_uid = update.uid();
+  const std::string& name = update.name();
+  const std::string& ns = update.namespace_();
+
+  auto it = k8s_objects_by_id_.find(object_uid);
+  if (it == k8s_objects_by_id_.end()) {
+    std::unique_ptr<WorkloadInfo> workload;
+    if constexpr (std::is_same_v<TUpdate, OwnerObjectUpdate>) {
+      workload = std::make_unique<OwnerObjectInfo>(object_uid, ns, name, update.api_version(),
+                                                   update.kind());
+    } else {
+      workload = std::make_unique<WorkloadInfo>(type, object_uid, ns, name);
+    }
+    VLOG(1) << absl::Substitute("Adding $0: $1", K8sObjectTypeName(type), workload->DebugString());
+    it = k8s_objects_by_id_.try_emplace(object_uid, std::move(workload)).first;
+  }
+  if (it->second->type() != type) {
+    return error::Internal("Object $0 is a $1, not a $2", object_uid,
+                           K8sObjectTypeName(it->second->type()), K8sObjectTypeName(type));
+  }
+  auto workload_info = static_cast<WorkloadInfo*>(it->second.get());
+
+  for (const auto& owner_ref : update.owner_references()) {
+    workload_info->AddOwnerReference(owner_ref.uid(), owner_ref.name(), owner_ref.kind());
+  }
+
+  K8sLabels labels(update.labels().begin(), update.labels().end());
+  IndexLabels(object_uid, workload_info->labels(), labels);
+  workload_info->set_labels(std::move(labels));
+
+  workload_info->set_start_time_ns(update.start_timestamp_ns());
+  workload_info->set_stop_time_ns(update.stop_timestamp_ns());
+
+  VLOG(1) << absl::Substitute("$0 update: $1", K8sObjectTypeName(type), name);
+
+  if (type != K8sObjectType::kOwnerObject) {
+    workloads_by_name_[type][{ns, name}] = object_uid;
+  }
+  return Status::OK();
+}
+
+Status K8sMetadataState::HandleStatefulSetUpdate(const StatefulSetUpdate& update) {
+  return HandleWorkloadUpdate(K8sObjectType::kStatefulSet, update);
+}
+
+Status K8sMetadataState::HandleDaemonSetUpdate(const DaemonSetUpdate& update) {
+  return HandleWorkloadUpdate(K8sObjectType::kDaemonSet, update);
+}
+
+Status K8sMetadataState::HandleJobUpdate(const JobUpdate& update) {
+  return HandleWorkloadUpdate(K8sObjectType::kJob, update);
+}
+
+Status K8sMetadataState::HandleCronJobUpdate(const CronJobUpdate& update) {
+  return HandleWorkloadUpdate(K8sObjectType::kCronJob, update);
+}
+
+Status K8sMetadataState::HandleIngressUpdate(const IngressUpdate& update) {
+  return HandleWorkloadUpdate(K8sObjectType::kIngress, update);
+}
+
+Status K8sMetadataState::HandleOwnerObjectUpdate(const OwnerObjectUpdate& update) {
+  return HandleWorkloadUpdate(K8sObjectType::kOwnerObject, update);
+}
+
 template <typename T>
 bool IsExpired(const T& obj, int64_t retention_time, int64_t now) {
   if (obj.stop_time_ns() == 0) {
@@ -460,6 +581,20 @@ Status K8sMetadataState::CleanupExpiredMetadata(int64_t retention_time_ns) {
           services_by_name_.erase({k8s_object->ns(), k8s_object->name()});
         }
         break;
+      case K8sObjectType::kStatefulSet:
+      case K8sObjectType::kDaemonSet:
+      case K8sObjectType::kJob:
+      case K8sObjectType::kCronJob:
+      case K8sObjectType::kIngress:
+      case K8sObjectType::kOwnerObject: {
+        auto& by_name = workloads_by_name_[k8s_object->type()];
+        auto it = by_name.find(std::make_pair(k8s_object->ns(), k8s_object->name()));
+        if (it != by_name.end() && it->second == k8s_object->uid()) {
+          by_name.erase(it);
+        }
+        IndexLabels(k8s_object->uid(), static_cast<WorkloadInfo*>(k8s_object.get())->labels(), {});
+        break;
+      }
       default:
         LOG(DFATAL) << absl::Substitute("Unexpected object type: $0",
                                         static_cast<int>(k8s_object->type()));
@@ -21,6 +21,7 @@
 #include <cstdint>
 #include <memory>
 #include <string>
+#include <string_view>
 #include <utility>
 #include <vector>
 
@@ -53,6 +54,12 @@ class K8sMetadataState : NotCopyable {
   using NodeUpdate = px::shared::k8s::metadatapb::NodeUpdate;
   using ReplicaSetUpdate = px::shared::k8s::metadatapb::ReplicaSetUpdate;
   using DeploymentUpdate = px::shared::k8s::metadatapb::DeploymentUpdate;
+  using StatefulSetUpdate = px::shared::k8s::metadatapb::StatefulSetUpdate;
+  using DaemonSetUpdate = px::shared::k8s::metadatapb::DaemonSetUpdate;
+  using JobUpdate = px::shared::k8s::metadatapb::JobUpdate;
+  using CronJobUpdate = px::shared::k8s::metadatapb::CronJobUpdate;
+  using IngressUpdate = px::shared::k8s::metadatapb::IngressUpdate;
+  using OwnerObjectUpdate = px::shared::k8s::metadatapb::OwnerObjectUpdate;
 
   // K8s names consist of both a namespace and name : <ns, name>.
   using K8sNameIdent = std::pair<std::string, std::string>;
@@ -103,6 +110,10 @@ class K8sMetadataState : NotCopyable {
   using DeploymentByNameMap = K8sEntityByNameMap;
   using NamespacesByNameMap = K8sEntityByNameMap;
   using ContainersByNameMap = absl::flat_hash_map<std::string, CID>;
+  using WorkloadsByNameMap = absl::flat_hash_map<K8sObjectType, K8sEntityByNameMap>;
+  // Labels are indexed by <key, value>.
+  using ObjectsByLabelMap =
+      absl::flat_hash_map<std::pair<std::string, std::string>, absl::flat_hash_set<UID>>;
   using PodsByPodIpMap = absl::flat_hash_map<std::string, UID>;
   using ServicesByServiceIpMap = absl::flat_hash_map<std::string, UID>;
 
@@ -118,6 +129,29 @@ class K8sMetadataState : NotCopyable {
 
   const std::vector<CIDRBlock>& pod_cidrs() const { return pod_cidrs_; }
 
+  /**
+   * Sets the namespaces watched by the metadata service. An empty allowlist means all namespaces
+   * that are not denied.
+   */
+  void set_namespace_scope(std::vector<std::string> allowed, std::vector<std::string> denied) {
+    allowed_namespaces_ = absl::flat_hash_set<std::string>(allowed.begin(), allowed.end());
+    denied_namespaces_ = absl::flat_hash_set<std::string>(denied.begin(), denied.end());
+  }
+
+  /**
+   * Returns true if objects in the namespace should be tracked. Cluster-scoped objects (empty
+   * namespace) are always in scope.
+   */
+  bool InNamespaceScope(std::string_view ns) const {
+    if (ns.empty()) {
+      return true;
+    }
+    if (!allowed_namespaces_.empty()) {
+      return allowed_namespaces_.contains(ns);
+    }
+    return !denied_namespaces_.contains(ns);
+  }
+
   const PodsByNameMap& pods_by_name() const { return pods_by_name_; }
 
   /**
@@ -223,6 +257,31 @@ class K8sMetadataState : NotCopyable {
    */
   UID DeploymentIDByName(K8sNameIdentView deployment_name) const;
 
+  /**
+   * WorkloadInfoByID gets an unowned pointer to the workload, such as a stateful set, job or custom
+   * owner object. This pointer will remain active for the lifetime of this metadata state instance.
+   * @param workload_id the id of the workload.
+   * @return Pointer to the WorkloadInfo, or nullptr if there is no workload with the id.
+   */
+  const WorkloadInfo* WorkloadInfoByID(UIDView workload_id) const;
+
+  /**
+   * WorkloadIDByName returns the ID of the workload of the given type and name. Custom owner objects
+   * can't be looked up by name, since objects of different kinds may share a name.
+   * @param type the type of the workload, such as K8sObjectType::kStatefulSet.
+   * @param workload_name the workload name
+   * @return the workload id or empty string if the workload does not exist.
+   */
+  UID WorkloadIDByName(K8sObjectType type, K8sNameIdentView workload_name) const;
+
+  /**
+   * ObjectIDsByLabel returns the IDs of the workloads with the given label.
+   * @param key the label key.
+   * @param value the label value.
+   * @return the IDs of the objects with the label, which is empty if there are none.
+   */
+  absl::flat_hash_set<UID> ObjectIDsByLabel(std::string_view key, std::string_view value) const;
+
   std::unique_ptr<K8sMetadataState> Clone() const;
 
   Status HandlePodUpdate(const PodUpdate& update);
@@ -232,6 +291,12 @@ class K8sMetadataState : NotCopyable {
   Status HandleNodeUpdate(const NodeUpdate& update);
   Status HandleReplicaSetUpdate(const ReplicaSetUpdate& update);
   Status HandleDeploymentUpdate(const DeploymentUpdate& update);
+  Status HandleStatefulSetUpdate(const StatefulSetUpdate& update);
+  Status HandleDaemonSetUpdate(const DaemonSetUpdate& update);
+  Status HandleJobUpdate(const JobUpdate& update);
+  Status HandleCronJobUpdate(const CronJobUpdate& update);
+  Status HandleIngressUpdate(const IngressUpdate& update);
+  Status HandleOwnerObjectUpdate(const OwnerObjectUpdate& update);
 
   Status CleanupExpiredMetadata(int64_t retention_time_ns);
 
@@ -241,12 +306,22 @@ class K8sMetadataState : NotCopyable {
  private:
   const K8sMetadataObject* K8sMetadataObjectByID(UIDView id, K8sObjectType type) const;
 
+  // Applies an update to a workload. All of the workload update protos share the fields used here.
+  template <typename TUpdate>
+  Status HandleWorkloadUpdate(K8sObjectType type, const TUpdate& update);
+
+  void IndexLabels(const UID& uid, const K8sLabels& old_labels, const K8sLabels& new_labels);
+
   // The CIDR block used for services inside the cluster.
   std::optional<CIDRBlock> service_cidr_;
 
   // The CIDRs used for pods inside the cluster.
   std::vector<CIDRBlock> pod_cidrs_;
 
+  // The namespaces watched by the metadata service.
+  absl::flat_hash_set<std::string> allowed_namespaces_;
+  absl::flat_hash_set<std::string> denied_namespaces_;
+
   // This stores K8s native objects (services, pods, etc).
   absl::flat_hash_map<UID, K8sMetadataObjectUPtr> k8s_objects_by_id_;
 
@@ -278,6 +353,16 @@ class K8sMetadataState : NotCopyable {
    */
   DeploymentByNameMap deployments_by_name_;
 
+  /**
+   * Mapping of workloads, such as stateful sets and jobs, by type and then name.
+   */
+  WorkloadsByNameMap workloads_by_name_;
+
+  /**
+   * Mapping of workloads by label.
+   */
+  ObjectsByLabelMap objects_by_label_;
+
   /**
    * Mapping of containers by name.
    */
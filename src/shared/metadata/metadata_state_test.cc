@@ -27,6 +27,7 @@ namespace px {
 namespace md {
 
 using ::google::protobuf::TextFormat;
+using ::testing::IsEmpty;
 using ::testing::UnorderedElementsAre;
 
 constexpr char kPod0UpdatePbTxt[] = R"(
@@ -250,6 +251,52 @@ constexpr char kDeploymentUpdatePbTxt02[] = R"(
   }
 )";
 
+constexpr char kStatefulSetUpdatePbTxt00[] = R"(
+  uid: "sts0_uid"
+  name: "sts0"
+  start_timestamp_ns: 101
+  stop_timestamp_ns: 0
+  namespace: "ns0"
+  replicas: 3
+  ready_replicas: 3
+  service_name: "sts0-svc"
+  labels: {
+    key: "app"
+    value: "db"
+  }
+  labels: {
+    key: "tier"
+    value: "backend"
+  }
+)";
+
+constexpr char kStatefulSetUpdatePbTxt01[] = R"(
+  uid: "sts0_uid"
+  name: "sts0"
+  start_timestamp_ns: 101
+  stop_timestamp_ns: 0
+  namespace: "ns0"
+  replicas: 3
+  ready_replicas: 3
+  service_name: "sts0-svc"
+  labels: {
+    key: "app"
+    value: "cache"
+  }
+)";
+
+constexpr char kJobUpdatePbTxt[] = R"(
+  uid: "job0_uid"
+  name: "job0"
+  start_timestamp_ns: 105
+  stop_timestamp_ns: 0
+  namespace: "ns0"
+  labels: {
+    key: "app"
+    value: "cache"
+  }
+)";
+
 TEST(K8sMetadataStateTest, CloneCopiedCIDR) {
   K8sMetadataState state;
 
@@ -519,6 +566,46 @@ TEST(K8sMetadataStateTest, HandleDeploymentUpdate) {
   EXPECT_EQ(ConditionStatus::kTrue, info->conditions()[DeploymentConditionType::kReplicaFailure]);
 }
 
+TEST(K8sMetadataStateTest, HandleWorkloadUpdates) {
+  K8sMetadataState state;
+
+  K8sMetadataState::StatefulSetUpdate sts_update;
+  ASSERT_TRUE(TextFormat::MergeFromString(kStatefulSetUpdatePbTxt00, &sts_update));
+  EXPECT_OK(state.HandleStatefulSetUpdate(sts_update));
+
+  const WorkloadInfo* info = state.WorkloadInfoByID("sts0_uid");
+  ASSERT_NE(nullptr, info);
+  EXPECT_EQ(K8sObjectType::kStatefulSet, info->type());
+  EXPECT_EQ("sts0", info->name());
+  EXPECT_EQ("ns0", info->ns());
+  EXPECT_EQ(101, info->start_time_ns());
+  EXPECT_EQ(2, info->labels().size());
+  EXPECT_EQ("sts0_uid", state.WorkloadIDByName(K8sObjectType::kStatefulSet, {"ns0", "sts0"}));
+  EXPECT_EQ("", state.WorkloadIDByName(K8sObjectType::kJob, {"ns0", "sts0"}));
+  EXPECT_THAT(state.ObjectIDsByLabel("app", "db"), UnorderedElementsAre("sts0_uid"));
+  EXPECT_THAT(state.ObjectIDsByLabel("tier", "backend"), UnorderedElementsAre("sts0_uid"));
+
+  K8sMetadataState::JobUpdate job_update;
+  ASSERT_TRUE(TextFormat::MergeFromString(kJobUpdatePbTxt, &job_update));
+  EXPECT_OK(state.HandleJobUpdate(job_update));
+  EXPECT_THAT(state.ObjectIDsByLabel("app", "cache"), UnorderedElementsAre("job0_uid"));
+
+  // Relabeling the stateful set moves it in the label index.
+  ASSERT_TRUE(TextFormat::MergeFromString(kStatefulSetUpdatePbTxt01, &sts_update));
+  EXPECT_OK(state.HandleStatefulSetUpdate(sts_update));
+  EXPECT_THAT(state.ObjectIDsByLabel("app", "db"), IsEmpty());
+  EXPECT_THAT(state.ObjectIDsByLabel("tier", "backend"), IsEmpty());
+  EXPECT_THAT(state.ObjectIDsByLabel("app", "cache"),
+              UnorderedElementsAre("sts0_uid", "job0_uid"));
+
+  // A UID can't change its kind.
+  K8sMetadataState::DaemonSetUpdate ds_update;
+  ds_update.set_uid("sts0_uid");
+  ds_update.set_name("sts0");
+  ds_update.set_namespace_("ns0");
+  EXPECT_NOT_OK(state.HandleDaemonSetUpdate(ds_update));
+}
+
 TEST(K8sMetadataStateTest, CleanupExpiredMetadata) {
   K8sMetadataState state;
 
@@ -17,6 +17,8 @@
  */
 
 #include <memory>
+#include <string>
+#include <string_view>
 #include <utility>
 #include <vector>
 
@@ -85,6 +87,18 @@ Status AgentMetadataStateManagerImpl::PerformMetadataStateUpdate() {
   // Get timestamp so all updates happen at the same timestamp.
   // TODO(zasgar): Change this to an injected clock.
   int64_t ts = CurrentTimeNS();
+
+  // Update the namespace scope before applying updates, so out of scope objects are dropped.
+  {
+    absl::base_internal::SpinLockHolder lock(&cidr_lock_);
+    if (namespace_scope_.has_value()) {
+      auto& [allowed, denied] = namespace_scope_.value();
+      shadow_state->k8s_metadata_state()->set_namespace_scope(std::move(allowed),
+                                                              std::move(denied));
+      namespace_scope_.reset();
+    }
+  }
+
   PL_RETURN_IF_ERROR(
       ApplyK8sUpdates(ts, shadow_state.get(), metadata_filter_, &incoming_k8s_updates_));
 
@@ -128,6 +142,41 @@ Status AgentMetadataStateManagerImpl::PerformMetadataStateUpdate() {
   return Status::OK();
 }
 
+namespace {
+
+// Returns the namespace of the object in the update, or an empty string for cluster-scoped
+// objects.
+std::string_view UpdateNamespace(const ResourceUpdate& update) {
+  switch (update.update_case()) {
+    case ResourceUpdate::kPodUpdate:
+      return update.pod_update().namespace_();
+    case ResourceUpdate::kContainerUpdate:
+      return update.container_update().namespace_();
+    case ResourceUpdate::kServiceUpdate:
+      return update.service_update().namespace_();
+    case ResourceUpdate::kReplicaSetUpdate:
+      return update.replica_set_update().namespace_();
+    case ResourceUpdate::kDeploymentUpdate:
+      return update.deployment_update().namespace_();
+    case ResourceUpdate::kStatefulSetUpdate:
+      return update.stateful_set_update().namespace_();
+    case ResourceUpdate::kDaemonSetUpdate:
+      return update.daemon_set_update().namespace_();
+    case ResourceUpdate::kJobUpdate:
+      return update.job_update().namespace_();
+    case ResourceUpdate::kCronJobUpdate:
+      return update.cron_job_update().namespace_();
+    case ResourceUpdate::kIngressUpdate:
+      return update.ingress_update().namespace_();
+    case ResourceUpdate::kOwnerObjectUpdate:
+      return update.owner_object_update().namespace_();
+    default:
+      return "";
+  }
+}
+
+}  // namespace
+
 Status ApplyK8sUpdates(
     int64_t ts, AgentMetadataState* state, AgentMetadataFilter* metadata_filter,
     moodycamel::BlockingConcurrentQueue<std::unique_ptr<ResourceUpdate>>* updates) {
@@ -136,6 +185,11 @@ Status ApplyK8sUpdates(
 
   // Returns false when no more items.
   while (updates->try_dequeue(update)) {
+    std::string_view ns = UpdateNamespace(*update);
+    if (!state->k8s_metadata_state()->InNamespaceScope(ns)) {
+      VLOG(2) << absl::Substitute("Dropping update for namespace $0, which is out of scope", ns);
+      continue;
+    }
     switch (update->update_case()) {
       case ResourceUpdate::kPodUpdate:
         PL_RETURN_IF_ERROR(HandlePodUpdate(update->pod_update(), state, metadata_filter));
@@ -162,6 +216,35 @@ Status ApplyK8sUpdates(
         PL_RETURN_IF_ERROR(
             HandleDeploymentUpdate(update->deployment_update(), state, metadata_filter));
         break;
+      case ResourceUpdate::kStatefulSetUpdate:
+        VLOG(2) << "Stateful Set Update: " << update->stateful_set_update().DebugString();
+        PL_RETURN_IF_ERROR(state->k8s_metadata_state()->HandleStatefulSetUpdate(
+            update->stateful_set_update()));
+        break;
+      case ResourceUpdate::kDaemonSetUpdate:
+        VLOG(2) << "Daemon Set Update: " << update->daemon_set_update().DebugString();
+        PL_RETURN_IF_ERROR(
+            state->k8s_metadata_state()->HandleDaemonSetUpdate(update->daemon_set_update()));
+        break;
+      case ResourceUpdate::kJobUpdate:
+        VLOG(2) << "Job Update: " << update->job_update().DebugString();
+        PL_RETURN_IF_ERROR(state->k8s_metadata_state()->HandleJobUpdate(update->job_update()));
+        break;
+      case ResourceUpdate::kCronJobUpdate:
+        VLOG(2) << "Cron Job Update: " << update->cron_job_update().DebugString();
+        PL_RETURN_IF_ERROR(
+            state->k8s_metadata_state()->HandleCronJobUpdate(update->cron_job_update()));
+        break;
+      case ResourceUpdate::kIngressUpdate:
+        VLOG(2) << "Ingress Update: " << update->ingress_update().DebugString();
+        PL_RETURN_IF_ERROR(
+            state->k8s_metadata_state()->HandleIngressUpdate(update->ingress_update()));
+        break;
+      case ResourceUpdate::kOwnerObjectUpdate:
+        VLOG(2) << "Owner Object Update: " << update->owner_object_update().DebugString();
+        PL_RETURN_IF_ERROR(
+            state->k8s_metadata_state()->HandleOwnerObjectUpdate(update->owner_object_update()));
+        break;
       default:
         LOG(ERROR) << "Unhandled Update Type: " << update->update_case() << " (ignoring)";
     }
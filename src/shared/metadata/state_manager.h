@@ -104,6 +104,15 @@ class AgentMetadataStateManager {
    */
   virtual void SetPodCIDR(std::vector<CIDRBlock> cidrs) = 0;
 
+  /**
+   * Sets the namespaces watched by the metadata service. Updates for objects outside of
+   * these namespaces are dropped.
+   * @param allowed the allowed namespaces, empty for all namespaces.
+   * @param denied the denied namespaces.
+   */
+  virtual void SetNamespaceScope(std::vector<std::string> allowed,
+                                 std::vector<std::string> denied) = 0;
+
   /**
    * Get the next pid status event. When no more events are available nullptr is returned.
    * @return unique_ptr with the PIDStatusEvent or nullptr.
@@ -150,6 +159,12 @@ class AgentMetadataStateManagerImpl : public AgentMetadataStateManager {
     pod_cidrs_ = std::move(cidrs);
   }
 
+  void SetNamespaceScope(std::vector<std::string> allowed,
+                         std::vector<std::string> denied) override {
+    absl::base_internal::SpinLockHolder lock(&cidr_lock_);
+    namespace_scope_ = std::make_pair(std::move(allowed), std::move(denied));
+  }
+
   std::unique_ptr<PIDStatusEvent> GetNextPIDStatusEvent() override;
 
  private:
@@ -179,6 +194,7 @@ class AgentMetadataStateManagerImpl : public AgentMetadataStateManager {
   absl::base_internal::SpinLock cidr_lock_;
   std::optional<CIDRBlock> service_cidr_;
   std::optional<std::vector<CIDRBlock>> pod_cidrs_;
+  std::optional<std::pair<std::vector<std::string>, std::vector<std::string>>> namespace_scope_;
 
   AgentMetadataFilter* metadata_filter_;
 };
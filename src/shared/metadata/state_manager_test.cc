@@ -437,5 +437,44 @@ TEST_F(AgentMetadataStateTest, cidr_test) {
   EXPECT_EQ(svc_cidr, md_svc_cidr.value());
 }
 
+TEST_F(AgentMetadataStateTest, namespace_scope_test) {
+  AgentMetadataStateManagerImpl mgr("test_host", /*asid*/ 0, /*pid*/ 987, "test_pod",
+                                    /*id*/ sole::uuid4(),
+                                    /*collects_data*/ false, px::system::Config::GetInstance(),
+                                    &md_filter_, /*vizier_id*/ sole::uuid4(), "test_vizier");
+
+  mgr.SetNamespaceScope({}, {"kube-system"});
+
+  constexpr char kDeniedServicePbtxt[] = R"(
+    service_update {
+      name: "kube-dns"
+      namespace: "kube-system"
+      uid: "service_id_denied"
+      start_timestamp_ns: 7
+    }
+  )";
+  constexpr char kAllowedServicePbtxt[] = R"(
+    service_update {
+      name: "service1"
+      namespace: "pl"
+      uid: "service_id_allowed"
+      start_timestamp_ns: 7
+    }
+  )";
+  auto denied = std::make_unique<ResourceUpdate>();
+  ASSERT_TRUE(google::protobuf::TextFormat::MergeFromString(kDeniedServicePbtxt, denied.get()));
+  auto allowed = std::make_unique<ResourceUpdate>();
+  ASSERT_TRUE(google::protobuf::TextFormat::MergeFromString(kAllowedServicePbtxt, allowed.get()));
+  EXPECT_OK(mgr.AddK8sUpdate(std::move(denied)));
+  EXPECT_OK(mgr.AddK8sUpdate(std::move(allowed)));
+
+  EXPECT_OK(mgr.PerformMetadataStateUpdate());
+  const auto& k8s_state = mgr.CurrentAgentMetadataState()->k8s_metadata_state();
+  EXPECT_EQ(nullptr, k8s_state.ServiceInfoByID("service_id_denied"));
+  EXPECT_NE(nullptr, k8s_state.ServiceInfoByID("service_id_allowed"));
+  EXPECT_TRUE(k8s_state.InNamespaceScope(""));
+  EXPECT_FALSE(k8s_state.InNamespaceScope("kube-system"));
+}
+
 }  // namespace md
 }  // namespace px
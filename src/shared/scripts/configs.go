@@ -21,6 +21,8 @@ package scripts
 // Config represents the configuration for a script. For example: which variables should be pulled in and how.
 type Config struct {
 	OtelEndpointConfig *OtelEndpointConfig `yaml:"otelEndpointConfig"`
+	// ExportConfigs are additional sinks that the script runner sends the script's output tables to.
+	ExportConfigs []*ExportConfig `yaml:"exportConfigs"`
 }
 
 // OtelEndpointConfig specifies values that should be filled in for all OTel endpoints in the script.
@@ -29,3 +31,38 @@ type OtelEndpointConfig struct {
 	Headers  map[string]string `yaml:"headers"`
 	Insecure bool              `yaml:"insecure"`
 }
+
+// ExportConfig specifies a sink that the output tables of a script are exported to. Exactly one of
+// Webhook, Kafka or File should be set.
+type ExportConfig struct {
+	Webhook *WebhookExportConfig `yaml:"webhook"`
+	Kafka   *KafkaExportConfig   `yaml:"kafka"`
+	File    *FileExportConfig    `yaml:"file"`
+	// Tables limits the export to the output tables with the given names. All tables are exported if empty.
+	Tables []string `yaml:"tables"`
+}
+
+// WebhookExportConfig specifies an HTTP endpoint that receives batches of rows as JSON.
+type WebhookExportConfig struct {
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"`
+	// BatchSize is the max number of rows sent in a single request.
+	BatchSize int `yaml:"batchSize"`
+	// MaxRetries is the number of times a failed request is retried.
+	MaxRetries int `yaml:"maxRetries"`
+	// TimeoutS is the timeout, in seconds, for a single request.
+	TimeoutS int `yaml:"timeoutS"`
+}
+
+// KafkaExportConfig specifies a Kafka topic that receives one message per row.
+type KafkaExportConfig struct {
+	Brokers []string `yaml:"brokers"`
+	Topic   string   `yaml:"topic"`
+	// MaxAttempts is the number of attempts the producer makes to deliver a batch of messages.
+	MaxAttempts int `yaml:"maxAttempts"`
+}
+
+// FileExportConfig specifies a local file that rows are appended to as newline-delimited JSON.
+type FileExportConfig struct {
+	Path string `yaml:"path"`
+}
@@ -131,3 +131,145 @@ func GenerateJWTForCluster(clusterID string, audience string) *jwtpb.JWTClaims {
 	}
 	return &pbClaims
 }
+
+const (
+	// APIKeyScopeScriptExecute allows an API key to execute read-only scripts, and to look up the
+	// clusters and scripts needed to do so.
+	APIKeyScopeScriptExecute = "script:execute"
+	// APIKeyScopeClusterAdmin allows an API key to manage clusters, and to execute scripts with mutations.
+	APIKeyScopeClusterAdmin = "cluster:admin"
+	// APIKeyScopeDeployKeyAdmin allows an API key to manage deployment keys.
+	APIKeyScopeDeployKeyAdmin = "deploykey:admin"
+	// APIKeyScopePluginConfig allows an API key to view and configure plugins.
+	APIKeyScopePluginConfig = "plugin:config"
+	// APIKeyScopeSCIM allows an API key to provision and deprovision the org's users through SCIM.
+	APIKeyScopeSCIM = "scim"
+)
+
+// APIKeyScopes are all of the scopes that an API key can be restricted to.
+var APIKeyScopes = []string{
+	APIKeyScopeScriptExecute,
+	APIKeyScopeClusterAdmin,
+	APIKeyScopeDeployKeyAdmin,
+	APIKeyScopePluginConfig,
+	APIKeyScopeSCIM,
+}
+
+// IsValidAPIKeyScope checks whether the given scope is one of the APIKeyScopes.
+func IsValidAPIKeyScope(scope string) bool {
+	for _, s := range APIKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// apiKeyScopes gets the API key scopes that the claims are restricted to. The claims are
+// unrestricted if there are no scopes.
+func apiKeyScopes(c *jwtpb.JWTClaims) []string {
+	switch GetClaimsType(c) {
+	case UserClaimType:
+		return c.GetUserClaims().APIKeyScopes
+	case ClusterClaimType:
+		return c.GetClusterClaims().APIKeyScopes
+	default:
+		return nil
+	}
+}
+
+// HasAPIKeyScope checks whether the claims are unrestricted, or are restricted to scopes which
+// include the given scope.
+func HasAPIKeyScope(c *jwtpb.JWTClaims, scope string) bool {
+	scopes := apiKeyScopes(c)
+	if len(scopes) == 0 {
+		return true
+	}
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAccessCluster checks whether the claims may access the given cluster. Only user claims for
+// API keys with a cluster allowlist are restricted.
+func CanAccessCluster(c *jwtpb.JWTClaims, clusterID string) bool {
+	allowed := c.GetUserClaims().GetAllowedClusterIDs()
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, id := range allowed {
+		if id == clusterID {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRestrictedAPIKey checks whether the claims belong to an API key which is restricted to a set of
+// scopes or clusters.
+func IsRestrictedAPIKey(c *jwtpb.JWTClaims) bool {
+	return len(apiKeyScopes(c)) > 0 || len(c.GetUserClaims().GetAllowedClusterIDs()) > 0
+}
+
+const (
+	// OrgRoleOwner can do everything an admin can, and can change the roles of admins and owners.
+	OrgRoleOwner = "owner"
+	// OrgRoleAdmin can manage the org's clusters, deploy keys, plugins, users and settings.
+	OrgRoleAdmin = "admin"
+	// OrgRoleMember can run scripts, including scripts with mutations.
+	OrgRoleMember = "member"
+	// OrgRoleViewer can view the org's resources and run read-only scripts.
+	OrgRoleViewer = "viewer"
+)
+
+// orgRoleRanks orders the org roles, where each role can do everything the roles below it can.
+var orgRoleRanks = map[string]int{
+	OrgRoleViewer: 1,
+	OrgRoleMember: 2,
+	OrgRoleAdmin:  3,
+	OrgRoleOwner:  4,
+}
+
+// IsValidOrgRole checks whether the given role is one of the org roles.
+func IsValidOrgRole(role string) bool {
+	_, ok := orgRoleRanks[role]
+	return ok
+}
+
+// OrgRoleAtLeast checks whether the role is the same as, or above, the minimum role.
+func OrgRoleAtLeast(role string, minRole string) bool {
+	return orgRoleRanks[role] >= orgRoleRanks[minRole]
+}
+
+// GetOrgRoleFromClaims returns the org role in the user or cluster claims.
+func GetOrgRoleFromClaims(c *jwtpb.JWTClaims) string {
+	switch GetClaimsType(c) {
+	case UserClaimType:
+		return c.GetUserClaims().OrgRole
+	case ClusterClaimType:
+		return c.GetClusterClaims().OrgRole
+	default:
+		return ""
+	}
+}
+
+// HasOrgRole checks whether the claims have at least the given org role. User and cluster claims
+// without an org role, such as claims which predate org roles, fail closed and only have the lowest
+// role. Service claims are not restricted.
+func HasOrgRole(c *jwtpb.JWTClaims, minRole string) bool {
+	switch GetClaimsType(c) {
+	case ServiceClaimType:
+		return true
+	case UserClaimType, ClusterClaimType:
+		role := GetOrgRoleFromClaims(c)
+		if role == "" {
+			role = OrgRoleViewer
+		}
+		return OrgRoleAtLeast(role, minRole)
+	default:
+		return false
+	}
+}
@@ -20,6 +20,7 @@ package utils_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -42,3 +43,78 @@ func TestGetClaimsType(t *testing.T) {
 
 	assert.Equal(t, utils.UserClaimType, utils.GetClaimsType(p))
 }
+
+func TestHasAPIKeyScope(t *testing.T) {
+	user := utils.GenerateJWTForUser("user_id", "org_id", "user@email.com", time.Now(), "withpixie.ai")
+	assert.True(t, utils.HasAPIKeyScope(user, utils.APIKeyScopeClusterAdmin))
+
+	apiUser := utils.GenerateJWTForAPIUser("user_id", "org_id", time.Now(), "withpixie.ai")
+	assert.True(t, utils.HasAPIKeyScope(apiUser, utils.APIKeyScopeClusterAdmin))
+	apiUser.GetUserClaims().APIKeyScopes = []string{utils.APIKeyScopeScriptExecute}
+	assert.True(t, utils.HasAPIKeyScope(apiUser, utils.APIKeyScopeScriptExecute))
+	assert.False(t, utils.HasAPIKeyScope(apiUser, utils.APIKeyScopeClusterAdmin))
+
+	cluster := utils.GenerateJWTForCluster("cluster_id", "withpixie.ai")
+	assert.True(t, utils.HasAPIKeyScope(cluster, utils.APIKeyScopeClusterAdmin))
+	cluster.GetClusterClaims().APIKeyScopes = []string{utils.APIKeyScopeScriptExecute}
+	assert.False(t, utils.HasAPIKeyScope(cluster, utils.APIKeyScopeClusterAdmin))
+}
+
+func TestCanAccessCluster(t *testing.T) {
+	apiUser := utils.GenerateJWTForAPIUser("user_id", "org_id", time.Now(), "withpixie.ai")
+	assert.True(t, utils.CanAccessCluster(apiUser, "cluster1"))
+	apiUser.GetUserClaims().AllowedClusterIDs = []string{"cluster1"}
+	assert.True(t, utils.CanAccessCluster(apiUser, "cluster1"))
+	assert.False(t, utils.CanAccessCluster(apiUser, "cluster2"))
+
+	svc := utils.GenerateJWTForService("service_id", "withpixie.ai")
+	assert.True(t, utils.CanAccessCluster(svc, "cluster2"))
+}
+
+func TestIsRestrictedAPIKey(t *testing.T) {
+	apiUser := utils.GenerateJWTForAPIUser("user_id", "org_id", time.Now(), "withpixie.ai")
+	assert.False(t, utils.IsRestrictedAPIKey(apiUser))
+	apiUser.GetUserClaims().AllowedClusterIDs = []string{"cluster1"}
+	assert.True(t, utils.IsRestrictedAPIKey(apiUser))
+
+	cluster := utils.GenerateJWTForCluster("vizier_id", "withpixie.ai")
+	cluster.GetClusterClaims().APIKeyScopes = []string{utils.APIKeyScopeScriptExecute}
+	assert.True(t, utils.IsRestrictedAPIKey(cluster))
+}
+
+func TestIsValidAPIKeyScope(t *testing.T) {
+	assert.True(t, utils.IsValidAPIKeyScope("deploykey:admin"))
+	assert.False(t, utils.IsValidAPIKeyScope("org:admin"))
+}
+
+func TestHasOrgRole(t *testing.T) {
+	user := utils.GenerateJWTForUser("user_id", "org_id", "test@test.com", time.Now(), "withpixie.ai")
+	// Claims which predate org roles only have the lowest role.
+	assert.True(t, utils.HasOrgRole(user, utils.OrgRoleViewer))
+	assert.False(t, utils.HasOrgRole(user, utils.OrgRoleMember))
+
+	user.GetUserClaims().OrgRole = utils.OrgRoleMember
+	assert.True(t, utils.HasOrgRole(user, utils.OrgRoleViewer))
+	assert.True(t, utils.HasOrgRole(user, utils.OrgRoleMember))
+	assert.False(t, utils.HasOrgRole(user, utils.OrgRoleAdmin))
+
+	user.GetUserClaims().OrgRole = "superuser"
+	assert.False(t, utils.HasOrgRole(user, utils.OrgRoleViewer))
+
+	cluster := utils.GenerateJWTForCluster("vizier_id", "withpixie.ai")
+	assert.False(t, utils.HasOrgRole(cluster, utils.OrgRoleMember))
+	cluster.GetClusterClaims().OrgRole = utils.OrgRoleViewer
+	assert.False(t, utils.HasOrgRole(cluster, utils.OrgRoleMember))
+
+	svc := utils.GenerateJWTForService("service_id", "withpixie.ai")
+	assert.True(t, utils.HasOrgRole(svc, utils.OrgRoleOwner))
+
+	assert.False(t, utils.HasOrgRole(&jwtpb.JWTClaims{}, utils.OrgRoleViewer))
+}
+
+func TestIsValidOrgRole(t *testing.T) {
+	assert.True(t, utils.IsValidOrgRole("admin"))
+	assert.False(t, utils.IsValidOrgRole(""))
+	assert.True(t, utils.OrgRoleAtLeast(utils.OrgRoleOwner, utils.OrgRoleAdmin))
+	assert.False(t, utils.OrgRoleAtLeast(utils.OrgRoleViewer, utils.OrgRoleMember))
+}
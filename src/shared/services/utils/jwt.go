@@ -62,10 +62,29 @@ func ProtoToToken(pb *jwtpb.JWTClaims) (jwt.Token, error) {
 			Claim("OrgID", m.UserClaims.OrgID).
 			Claim("Email", m.UserClaims.Email).
 			Claim("IsAPIUser", m.UserClaims.IsAPIUser)
+		// The API key restrictions are only added when set, to keep unrestricted tokens unchanged.
+		if len(m.UserClaims.APIKeyScopes) > 0 {
+			builder.Claim("APIKeyScopes", strings.Join(m.UserClaims.APIKeyScopes, ","))
+		}
+		if len(m.UserClaims.AllowedClusterIDs) > 0 {
+			builder.Claim("AllowedClusterIDs", strings.Join(m.UserClaims.AllowedClusterIDs, ","))
+		}
+		if m.UserClaims.OrgRole != "" {
+			builder.Claim("OrgRole", m.UserClaims.OrgRole)
+		}
+		if m.UserClaims.APIKeyID != "" {
+			builder.Claim("APIKeyID", m.UserClaims.APIKeyID)
+		}
 	case *jwtpb.JWTClaims_ServiceClaims:
 		builder.Claim("ServiceID", m.ServiceClaims.ServiceID)
 	case *jwtpb.JWTClaims_ClusterClaims:
 		builder.Claim("ClusterID", m.ClusterClaims.ClusterID)
+		if len(m.ClusterClaims.APIKeyScopes) > 0 {
+			builder.Claim("APIKeyScopes", strings.Join(m.ClusterClaims.APIKeyScopes, ","))
+		}
+		if m.ClusterClaims.OrgRole != "" {
+			builder.Claim("OrgRole", m.ClusterClaims.OrgRole)
+		}
 	default:
 		log.WithField("type", m).Error("Could not find claims type")
 	}
@@ -95,10 +114,14 @@ func TokenToProto(token jwt.Token) (*jwtpb.JWTClaims, error) {
 	case HasUserClaims(token):
 		p.CustomClaims = &jwtpb.JWTClaims_UserClaims{
 			UserClaims: &jwtpb.UserJWTClaims{
-				UserID:    GetUserID(token),
-				OrgID:     GetOrgID(token),
-				Email:     GetEmail(token),
-				IsAPIUser: GetIsAPIUser(token),
+				UserID:            GetUserID(token),
+				OrgID:             GetOrgID(token),
+				Email:             GetEmail(token),
+				IsAPIUser:         GetIsAPIUser(token),
+				APIKeyScopes:      GetAPIKeyScopes(token),
+				AllowedClusterIDs: GetAllowedClusterIDs(token),
+				OrgRole:           GetOrgRole(token),
+				APIKeyID:          GetAPIKeyID(token),
 			},
 		}
 	case HasServiceClaims(token):
@@ -110,7 +133,9 @@ func TokenToProto(token jwt.Token) (*jwtpb.JWTClaims, error) {
 	case HasClusterClaims(token):
 		p.CustomClaims = &jwtpb.JWTClaims_ClusterClaims{
 			ClusterClaims: &jwtpb.ClusterJWTClaims{
-				ClusterID: GetClusterID(token),
+				ClusterID:    GetClusterID(token),
+				APIKeyScopes: GetAPIKeyScopes(token),
+				OrgRole:      GetOrgRole(token),
 			},
 		}
 	}
@@ -204,6 +229,46 @@ func GetIsAPIUser(t jwt.Token) bool {
 	return isAPIUser.(bool)
 }
 
+// getStringListClaim fetches a comma separated list from the custom claims.
+func getStringListClaim(t jwt.Token, name string) []string {
+	claims := t.PrivateClaims()
+	list, ok := claims[name]
+	if !ok || list.(string) == "" {
+		return nil
+	}
+	return strings.Split(list.(string), ",")
+}
+
+// GetAPIKeyScopes fetches the APIKeyScopes from the custom claims.
+func GetAPIKeyScopes(t jwt.Token) []string {
+	return getStringListClaim(t, "APIKeyScopes")
+}
+
+// GetAllowedClusterIDs fetches the AllowedClusterIDs from the custom claims.
+func GetAllowedClusterIDs(t jwt.Token) []string {
+	return getStringListClaim(t, "AllowedClusterIDs")
+}
+
+// GetOrgRole fetches the OrgRole from the custom claims.
+func GetOrgRole(t jwt.Token) string {
+	claims := t.PrivateClaims()
+	orgRole, ok := claims["OrgRole"]
+	if !ok {
+		return ""
+	}
+	return orgRole.(string)
+}
+
+// GetAPIKeyID fetches the APIKeyID from the custom claims.
+func GetAPIKeyID(t jwt.Token) string {
+	claims := t.PrivateClaims()
+	apiKeyID, ok := claims["APIKeyID"]
+	if !ok {
+		return ""
+	}
+	return apiKeyID.(string)
+}
+
 // GetServiceID fetches the ServiceID from the custom claims.
 func GetServiceID(t jwt.Token) string {
 	claims := t.PrivateClaims()
@@ -126,6 +126,100 @@ func TestProtoToToken_Cluster(t *testing.T) {
 	assert.Equal(t, "cluster_id", utils.GetClusterID(token))
 }
 
+func TestProtoToToken_APIUserScopes(t *testing.T) {
+	p := getStandardClaimsPb()
+	p.Scopes = []string{"user"}
+	p.CustomClaims = &jwtpb.JWTClaims_UserClaims{
+		UserClaims: &jwtpb.UserJWTClaims{
+			UserID:            "user_id",
+			OrgID:             "org_id",
+			IsAPIUser:         true,
+			APIKeyScopes:      []string{utils.APIKeyScopeScriptExecute, utils.APIKeyScopePluginConfig},
+			AllowedClusterIDs: []string{"cluster1", "cluster2"},
+		},
+	}
+
+	token, err := utils.ProtoToToken(p)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"script:execute", "plugin:config"}, utils.GetAPIKeyScopes(token))
+	assert.Equal(t, []string{"cluster1", "cluster2"}, utils.GetAllowedClusterIDs(token))
+
+	pb, err := utils.TokenToProto(token)
+	require.NoError(t, err)
+	assert.Equal(t, p.GetUserClaims(), pb.GetUserClaims())
+}
+
+func TestProtoToToken_OrgRole(t *testing.T) {
+	p := getStandardClaimsPb()
+	p.CustomClaims = &jwtpb.JWTClaims_UserClaims{
+		UserClaims: &jwtpb.UserJWTClaims{
+			UserID:  "user_id",
+			OrgID:   "org_id",
+			OrgRole: utils.OrgRoleViewer,
+		},
+	}
+
+	token, err := utils.ProtoToToken(p)
+	require.NoError(t, err)
+	assert.Equal(t, "viewer", utils.GetOrgRole(token))
+
+	pb, err := utils.TokenToProto(token)
+	require.NoError(t, err)
+	assert.Equal(t, p.GetUserClaims(), pb.GetUserClaims())
+
+	p.CustomClaims = &jwtpb.JWTClaims_ClusterClaims{
+		ClusterClaims: &jwtpb.ClusterJWTClaims{
+			ClusterID: "cluster_id",
+			OrgRole:   utils.OrgRoleMember,
+		},
+	}
+	token, err = utils.ProtoToToken(p)
+	require.NoError(t, err)
+	pb, err = utils.TokenToProto(token)
+	require.NoError(t, err)
+	assert.Equal(t, p.GetClusterClaims(), pb.GetClusterClaims())
+}
+
+func TestProtoToToken_APIKeyID(t *testing.T) {
+	p := getStandardClaimsPb()
+	p.CustomClaims = &jwtpb.JWTClaims_UserClaims{
+		UserClaims: &jwtpb.UserJWTClaims{
+			UserID:    "user_id",
+			OrgID:     "org_id",
+			IsAPIUser: true,
+			APIKeyID:  "api_key_id",
+		},
+	}
+
+	token, err := utils.ProtoToToken(p)
+	require.NoError(t, err)
+	assert.Equal(t, "api_key_id", utils.GetAPIKeyID(token))
+
+	pb, err := utils.TokenToProto(token)
+	require.NoError(t, err)
+	assert.Equal(t, p.GetUserClaims(), pb.GetUserClaims())
+}
+
+func TestProtoToToken_UnrestrictedAPIUser(t *testing.T) {
+	p := getStandardClaimsPb()
+	p.CustomClaims = &jwtpb.JWTClaims_UserClaims{
+		UserClaims: &jwtpb.UserJWTClaims{
+			UserID:    "user_id",
+			IsAPIUser: true,
+		},
+	}
+
+	token, err := utils.ProtoToToken(p)
+	require.NoError(t, err)
+	_, ok := token.PrivateClaims()["APIKeyScopes"]
+	assert.False(t, ok)
+
+	pb, err := utils.TokenToProto(token)
+	require.NoError(t, err)
+	assert.Nil(t, pb.GetUserClaims().APIKeyScopes)
+	assert.Nil(t, pb.GetUserClaims().AllowedClusterIDs)
+}
+
 func TestTokenToProto_Standard(t *testing.T) {
 	builder := getStandardClaimsBuilder()
 
@@ -182,7 +276,8 @@ func TestTokenToProto_Service(t *testing.T) {
 func TestTokenToProto_Cluster(t *testing.T) {
 	builder := getStandardClaimsBuilder().
 		Claim("Scopes", "cluster").
-		Claim("ClusterID", "cluster_id")
+		Claim("ClusterID", "cluster_id").
+		Claim("APIKeyScopes", "script:execute")
 
 	token, err := builder.Build()
 	require.NoError(t, err)
@@ -192,6 +287,7 @@ func TestTokenToProto_Cluster(t *testing.T) {
 	assert.Equal(t, []string{"cluster"}, pb.Scopes)
 	customClaims := pb.GetClusterClaims()
 	assert.Equal(t, "cluster_id", customClaims.ClusterID)
+	assert.Equal(t, []string{"script:execute"}, customClaims.APIKeyScopes)
 }
 
 func TestTokenToProto_FailNoAudience(t *testing.T) {
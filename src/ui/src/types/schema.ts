@@ -88,6 +88,7 @@ export interface GQLUserInfo {
   orgName: string;
   orgID: string;
   isApproved: boolean;
+  orgRole: string;
 }
 
 export interface GQLIDEPath {
@@ -249,6 +250,12 @@ export interface GQLClusterInfo {
   statusMessage: string;
   previousStatus?: GQLClusterStatus;
   previousStatusTimeMs?: number;
+  tags: Array<GQLClusterTag>;
+}
+
+export interface GQLClusterTag {
+  key: string;
+  value: string;
 }
 
 export interface GQLUserInvite {
@@ -296,6 +303,7 @@ export interface GQLCLIArtifact {
 
 export interface GQLEditableUserPermissions {
   isApproved?: boolean;
+  orgRole?: string;
 }
 
 export interface GQLEditableOrgSettings {
@@ -419,6 +427,7 @@ export interface GQLResolver {
   K8sEvent?: GQLK8sEventTypeResolver;
   PodStatus?: GQLPodStatusTypeResolver;
   ClusterInfo?: GQLClusterInfoTypeResolver;
+  ClusterTag?: GQLClusterTagTypeResolver;
   UserInvite?: GQLUserInviteTypeResolver;
   LiveViewMetadata?: GQLLiveViewMetadataTypeResolver;
   LiveViewContents?: GQLLiveViewContentsTypeResolver;
@@ -516,8 +525,11 @@ export interface QueryToClusterByNameResolver<TParent = any, TResult = any> {
   (parent: TParent, args: QueryToClusterByNameArgs, context: any, info: GraphQLResolveInfo): TResult;
 }
 
+export interface QueryToClustersArgs {
+  selector?: string;
+}
 export interface QueryToClustersResolver<TParent = any, TResult = any> {
-  (parent: TParent, args: {}, context: any, info: GraphQLResolveInfo): TResult;
+  (parent: TParent, args: QueryToClustersArgs, context: any, info: GraphQLResolveInfo): TResult;
 }
 
 export interface QueryToCliArtifactArgs {
@@ -816,6 +828,7 @@ export interface GQLUserInfoTypeResolver<TParent = any> {
   orgName?: UserInfoToOrgNameResolver<TParent>;
   orgID?: UserInfoToOrgIDResolver<TParent>;
   isApproved?: UserInfoToIsApprovedResolver<TParent>;
+  orgRole?: UserInfoToOrgRoleResolver<TParent>;
 }
 
 export interface UserInfoToIdResolver<TParent = any, TResult = any> {
@@ -846,6 +859,10 @@ export interface UserInfoToIsApprovedResolver<TParent = any, TResult = any> {
   (parent: TParent, args: {}, context: any, info: GraphQLResolveInfo): TResult;
 }
 
+export interface UserInfoToOrgRoleResolver<TParent = any, TResult = any> {
+  (parent: TParent, args: {}, context: any, info: GraphQLResolveInfo): TResult;
+}
+
 export interface GQLIDEPathTypeResolver<TParent = any> {
   IDEName?: IDEPathToIDENameResolver<TParent>;
   path?: IDEPathToPathResolver<TParent>;
@@ -1183,6 +1200,7 @@ export interface GQLClusterInfoTypeResolver<TParent = any> {
   statusMessage?: ClusterInfoToStatusMessageResolver<TParent>;
   previousStatus?: ClusterInfoToPreviousStatusResolver<TParent>;
   previousStatusTimeMs?: ClusterInfoToPreviousStatusTimeMsResolver<TParent>;
+  tags?: ClusterInfoToTagsResolver<TParent>;
 }
 
 export interface ClusterInfoToIdResolver<TParent = any, TResult = any> {
@@ -1249,6 +1267,23 @@ export interface ClusterInfoToPreviousStatusTimeMsResolver<TParent = any, TResul
   (parent: TParent, args: {}, context: any, info: GraphQLResolveInfo): TResult;
 }
 
+export interface ClusterInfoToTagsResolver<TParent = any, TResult = any> {
+  (parent: TParent, args: {}, context: any, info: GraphQLResolveInfo): TResult;
+}
+
+export interface GQLClusterTagTypeResolver<TParent = any> {
+  key?: ClusterTagToKeyResolver<TParent>;
+  value?: ClusterTagToValueResolver<TParent>;
+}
+
+export interface ClusterTagToKeyResolver<TParent = any, TResult = any> {
+  (parent: TParent, args: {}, context: any, info: GraphQLResolveInfo): TResult;
+}
+
+export interface ClusterTagToValueResolver<TParent = any, TResult = any> {
+  (parent: TParent, args: {}, context: any, info: GraphQLResolveInfo): TResult;
+}
+
 export interface GQLUserInviteTypeResolver<TParent = any> {
   email?: UserInviteToEmailResolver<TParent>;
   inviteLink?: UserInviteToInviteLinkResolver<TParent>;
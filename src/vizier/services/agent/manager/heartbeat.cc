@@ -19,6 +19,7 @@
 #include "src/vizier/services/agent/manager/heartbeat.h"
 
 #include <memory>
+#include <string>
 #include <utility>
 #include <vector>
 
@@ -158,6 +159,12 @@ Status HeartbeatMessageHandler::HandleMessage(std::unique_ptr<messages::VizierMe
       }
     }
     mds_manager_->SetPodCIDR(std::move(pod_cidrs));
+
+    mds_manager_->SetNamespaceScope(
+        std::vector<std::string>(ack.update_info().allowed_namespaces().begin(),
+                                 ack.update_info().allowed_namespaces().end()),
+        std::vector<std::string>(ack.update_info().denied_namespaces().begin(),
+                                 ack.update_info().denied_namespaces().end()));
   }
 
   return Status::OK();
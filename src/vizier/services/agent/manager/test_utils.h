@@ -20,6 +20,7 @@
 
 #include <memory>
 #include <queue>
+#include <string>
 #include <utility>
 #include <vector>
 
@@ -85,6 +86,12 @@ class FakeAgentMetadataStateManager : public md::AgentMetadataStateManager {
 
   void SetPodCIDR(std::vector<CIDRBlock> cidrs) override { pod_cidr_ = cidrs; }
 
+  void SetNamespaceScope(std::vector<std::string> allowed,
+                         std::vector<std::string> denied) override {
+    allowed_namespaces_ = std::move(allowed);
+    denied_namespaces_ = std::move(denied);
+  }
+
   void AddPIDStatusEvent(std::unique_ptr<md::PIDStatusEvent> event) {
     pid_status_events_.push(std::move(event));
   }
@@ -105,6 +112,8 @@ class FakeAgentMetadataStateManager : public md::AgentMetadataStateManager {
   std::vector<std::unique_ptr<md::ResourceUpdate>> updates_;
   CIDRBlock cidr_;
   std::vector<CIDRBlock> pod_cidr_;
+  std::vector<std::string> allowed_namespaces_;
+  std::vector<std::string> denied_namespaces_;
   std::queue<std::unique_ptr<md::PIDStatusEvent>> pid_status_events_;
 };
 
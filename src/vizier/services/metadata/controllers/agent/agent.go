@@ -80,10 +80,13 @@ type Store interface {
 	GetAgentIDForHostnamePair(hnPair *HostnameIPPair) (string, error)
 }
 
-// CIDRInfoProvider is an interface that provides CIDRInfo for a given agent.
+// CIDRInfoProvider is an interface that provides CIDRInfo, and the namespaces which are observed, for
+// a given agent.
 type CIDRInfoProvider interface {
 	GetServiceCIDR() string
 	GetPodCIDRs() []string
+	GetAllowedNamespaces() []string
+	GetDeniedNamespaces() []string
 }
 
 // Update describes the update info for a given agent.
@@ -97,9 +100,15 @@ type Manager interface {
 	// RegisterAgent registers a new agent.
 	RegisterAgent(info *agentpb.Agent) (uint32, error)
 
-	// UpdateHeartbeat updates the agent heartbeat with the current time.
+	// UpdateHeartbeat updates the agent heartbeat with the current time, along with the agent's health.
 	UpdateHeartbeat(agentID uuid.UUID) error
 
+	// RecordQueryFailures records that the given agents took part in a failed query.
+	RecordQueryFailures(agentIDs []uuid.UUID)
+
+	// RecordTracepointFailure records that the given agent failed to deploy a tracepoint.
+	RecordTracepointFailure(agentID uuid.UUID)
+
 	// Delete agent deletes the agent.
 	DeleteAgent(uuid.UUID) error
 
@@ -137,6 +146,11 @@ type Manager interface {
 	GetServiceCIDR() string
 	// GetPodCIDRs returns the PodCIDRs for the cluster.
 	GetPodCIDRs() []string
+	// GetAllowedNamespaces returns the namespaces observed by Vizier, or nil if all namespaces which
+	// are not denied are observed.
+	GetAllowedNamespaces() []string
+	// GetDeniedNamespaces returns the namespaces which are never observed by Vizier.
+	GetDeniedNamespaces() []string
 }
 
 // agentUpdateTracker stores the updates (in order) for agents for GetAgentUpdates.
@@ -175,6 +189,12 @@ type ManagerImpl struct {
 
 	// Prometheus counter to keep track of agent registrations.
 	agentRegCounter *prometheus.CounterVec
+
+	// The signals which the health of each agent is computed from. These are only kept in memory, so
+	// agents start out healthy when the metadata service restarts.
+	agentHealth map[uuid.UUID]*agentHealth
+	// Protects agentHealth.
+	agentHealthMutex sync.Mutex
 }
 
 // NewManager creates a new agent manager.
@@ -187,6 +207,7 @@ func NewManager(agtStore Store, cidr CIDRInfoProvider, conn *nats.Conn) *Manager
 		conn:                conn,
 		agentUpdateTrackers: make(map[uuid.UUID]*agentUpdateTracker),
 		agentRegCounter:     agentRegCounter,
+		agentHealth:         make(map[uuid.UUID]*agentHealth),
 	}
 
 	return Manager
@@ -249,6 +270,10 @@ func (m *ManagerImpl) deleteAgentWrapper(agentID uuid.UUID) error {
 		return err
 	}
 
+	m.agentHealthMutex.Lock()
+	delete(m.agentHealth, agentID)
+	m.agentHealthMutex.Unlock()
+
 	m.agentUpdateTrackersMutex.Lock()
 	defer m.agentUpdateTrackersMutex.Unlock()
 
@@ -399,6 +424,9 @@ func (m *ManagerImpl) ApplyAgentUpdate(update *Update) error {
 	if !update.UpdateInfo.DoesUpdateSchema {
 		return nil
 	}
+	m.withAgentHealth(update.AgentID, func(h *agentHealth) {
+		h.recordSchemaUpdate(time.Now())
+	})
 	return m.updateAgentSchemaWrapper(update.AgentID, update.UpdateInfo.Schema)
 }
 
@@ -508,7 +536,23 @@ func (m *ManagerImpl) UpdateHeartbeat(agentID uuid.UUID) error {
 	}
 
 	// Update LastHeartbeatNS in AgentData.
-	agent.LastHeartbeatNS = time.Now().UnixNano()
+	now := time.Now()
+	agent.LastHeartbeatNS = now.UnixNano()
+
+	collectsData := agent.Info.Capabilities == nil || agent.Info.Capabilities.CollectsData
+	wasQuarantined := agent.Health.GetQuarantined()
+	m.withAgentHealth(agentID, func(h *agentHealth) {
+		h.recordHeartbeat(now)
+		agent.Health = h.update(now, collectsData)
+	})
+	if agent.Health.Quarantined != wasQuarantined {
+		logger := log.WithField("agent_id", agentID.String()).WithField("score", agent.Health.Score)
+		if agent.Health.Quarantined {
+			logger.Warn("Quarantining unhealthy agent")
+		} else {
+			logger.Info("Agent recovered from quarantine")
+		}
+	}
 
 	err = m.updateAgentWrapper(agentID, agent)
 	if err != nil {
@@ -518,6 +562,43 @@ func (m *ManagerImpl) UpdateHeartbeat(agentID uuid.UUID) error {
 	return nil
 }
 
+// withAgentHealth calls fn with the health of the given agent while holding the health lock,
+// starting to track the agent if it isn't tracked yet.
+func (m *ManagerImpl) withAgentHealth(agentID uuid.UUID, fn func(h *agentHealth)) {
+	m.agentHealthMutex.Lock()
+	defer m.agentHealthMutex.Unlock()
+	h, ok := m.agentHealth[agentID]
+	if !ok {
+		h = &agentHealth{}
+		m.agentHealth[agentID] = h
+	}
+	fn(h)
+}
+
+// RecordQueryFailures records that the given agents took part in a failed query. The failures are
+// reflected in the agents' health on their next heartbeat.
+func (m *ManagerImpl) RecordQueryFailures(agentIDs []uuid.UUID) {
+	now := time.Now()
+	m.agentHealthMutex.Lock()
+	defer m.agentHealthMutex.Unlock()
+	for _, agentID := range agentIDs {
+		// Agents are tracked from their first heartbeat, so this skips agents which have been deleted.
+		if h, ok := m.agentHealth[agentID]; ok {
+			h.recordQueryFailure(now)
+		}
+	}
+}
+
+// RecordTracepointFailure records that the given agent failed to deploy a tracepoint. The failure
+// is reflected in the agent's health on its next heartbeat.
+func (m *ManagerImpl) RecordTracepointFailure(agentID uuid.UUID) {
+	m.agentHealthMutex.Lock()
+	defer m.agentHealthMutex.Unlock()
+	if h, ok := m.agentHealth[agentID]; ok {
+		h.recordTracepointFailure(time.Now())
+	}
+}
+
 // GetActiveAgents gets all of the current active agents.
 func (m *ManagerImpl) GetActiveAgents() ([]*agentpb.Agent, error) {
 	var agents []*agentpb.Agent
@@ -707,3 +788,14 @@ func (m *ManagerImpl) GetServiceCIDR() string {
 func (m *ManagerImpl) GetPodCIDRs() []string {
 	return m.cidr.GetPodCIDRs()
 }
+
+// GetAllowedNamespaces returns the namespaces observed by Vizier, or nil if all namespaces which are
+// not denied are observed.
+func (m *ManagerImpl) GetAllowedNamespaces() []string {
+	return m.cidr.GetAllowedNamespaces()
+}
+
+// GetDeniedNamespaces returns the namespaces which are never observed by Vizier.
+func (m *ManagerImpl) GetDeniedNamespaces() []string {
+	return m.cidr.GetDeniedNamespaces()
+}
@@ -110,25 +110,19 @@ func (a *Datastore) CreateAgent(agentID uuid.UUID, agt *agentpb.Agent) error {
 		IP:       agt.Info.HostInfo.HostIP,
 	}
 
-	err = a.ds.Set(getHostnamePairAgentKey(hnPair), agentID.String())
-	if err != nil {
-		return err
-	}
-	err = a.ds.Set(getAgentKey(agentID), string(i))
-	if err != nil {
-		return err
-	}
-	err = a.ds.Set(getPodNameToAgentIDKey(agt.Info.HostInfo.PodName), agentID.String())
-	if err != nil {
-		return err
-	}
+	batch := a.ds.NewBatch()
+	batch.Set(getHostnamePairAgentKey(hnPair), agentID.String())
+	batch.Set(getAgentKey(agentID), string(i))
+	batch.Set(getPodNameToAgentIDKey(agt.Info.HostInfo.PodName), agentID.String())
 
 	collectsData := agt.Info.Capabilities == nil || agt.Info.Capabilities.CollectsData
 	if !collectsData {
-		err = a.ds.Set(getKelvinAgentKey(agentID), agentID.String())
-		if err != nil {
-			return err
-		}
+		batch.Set(getKelvinAgentKey(agentID), agentID.String())
+	}
+
+	err = batch.Commit()
+	if err != nil {
+		return err
 	}
 
 	log.WithField("hostname", hnPair.Hostname).WithField("HostIP", hnPair.IP).Info("Registering agent")
@@ -199,18 +193,19 @@ func (a *Datastore) DeleteAgent(agentID uuid.UUID) error {
 		delKeys = append(delKeys, getKelvinAgentKey(agentID))
 	}
 
-	err = a.ds.DeleteAll(delKeys)
-	if err != nil {
-		return err
-	}
-
 	// Deletes from the computedSchema
-	err = a.UpdateSchemas(agentID, []*storepb.TableInfo{})
+	computedSchema, err := a.updatedComputedSchema(agentID, []*storepb.TableInfo{})
 	if err != nil {
 		return err
 	}
 
-	return a.ds.DeleteWithPrefix(getAgentDataInfoKey(agentID))
+	batch := a.ds.NewBatch()
+	for _, k := range delKeys {
+		batch.Delete(k)
+	}
+	batch.Set(computedSchemaKey, string(computedSchema))
+	batch.DeleteWithPrefix(getAgentDataInfoKey(agentID))
+	return batch.Commit()
 }
 
 // GetAgents gets all of the current active agents.
@@ -399,6 +394,15 @@ func deleteAgentFromComputed(computedSchemaPb *storepb.ComputedSchema, tableName
 
 // UpdateSchemas updates the given schemas in the metadata store.
 func (a *Datastore) UpdateSchemas(agentID uuid.UUID, schemas []*storepb.TableInfo) error {
+	computedSchema, err := a.updatedComputedSchema(agentID, schemas)
+	if err != nil {
+		return err
+	}
+	return a.ds.Set(computedSchemaKey, string(computedSchema))
+}
+
+// updatedComputedSchema returns the marshaled computed schema, with the agent's tables replaced by the given schemas.
+func (a *Datastore) updatedComputedSchema(agentID uuid.UUID, schemas []*storepb.TableInfo) ([]byte, error) {
 	computedSchemaPb, err := a.GetComputedSchema()
 	// If there are no computed schemas, that means we have yet to set one.
 	if err == ErrNoComputedSchemas {
@@ -409,7 +413,7 @@ func (a *Datastore) UpdateSchemas(agentID uuid.UUID, schemas []*storepb.TableInf
 	// Other errors are still errors.
 	if err != nil {
 		log.WithError(err).Error("Could not get old schema.")
-		return err
+		return nil, err
 	}
 
 	// Make sure the computedSchema is non-nil and fields are non-nil.
@@ -488,17 +492,16 @@ func (a *Datastore) UpdateSchemas(agentID uuid.UUID, schemas []*storepb.TableInf
 		err := deleteAgentFromComputed(computedSchemaPb, tableName, agentIDPb)
 		if err != nil {
 			log.WithError(err).Errorf("Could not delete table to agent mapping %s -> %v", tableName, agentID)
-			return err
+			return nil, err
 		}
 	}
 
 	computedSchema, err := computedSchemaPb.Marshal()
 	if err != nil {
 		log.WithError(err).Error("Could not marshal computed schema update message.")
-		return err
+		return nil, err
 	}
-
-	return a.ds.Set(computedSchemaKey, string(computedSchema))
+	return computedSchema, nil
 }
 
 // PruneComputedSchema cleans any dead agents from the computed schema. This is a temporary fix, to address a larger
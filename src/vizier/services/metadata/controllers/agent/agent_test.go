@@ -254,6 +254,46 @@ func TestUpdateHeartbeat(t *testing.T) {
 	assert.Greater(t, agt.LastHeartbeatNS, now)
 }
 
+func TestUpdateHeartbeat_Quarantine(t *testing.T) {
+	ads, agtMgr, _, cleanup := setupManager(t)
+	defer cleanup()
+
+	pemID, err := uuid.FromString(testutils.ExistingAgentUUID)
+	require.NoError(t, err)
+	kelvinID, err := uuid.FromString(testutils.UnhealthyKelvinAgentUUID)
+	require.NoError(t, err)
+	newAgentID, err := uuid.FromString(testutils.NewAgentUUID)
+	require.NoError(t, err)
+
+	require.NoError(t, agtMgr.UpdateHeartbeat(pemID))
+	require.NoError(t, agtMgr.UpdateHeartbeat(kelvinID))
+
+	agt, err := ads.GetAgent(pemID)
+	require.NoError(t, err)
+	require.NotNil(t, agt.Health)
+	assert.Equal(t, int32(100), agt.Health.Score)
+	assert.False(t, agt.Health.Quarantined)
+
+	// Failures of agents which haven't sent a heartbeat are ignored.
+	for i := 0; i < 7; i++ {
+		agtMgr.RecordQueryFailures([]uuid.UUID{pemID, kelvinID, newAgentID})
+	}
+	require.NoError(t, agtMgr.UpdateHeartbeat(pemID))
+	require.NoError(t, agtMgr.UpdateHeartbeat(kelvinID))
+
+	agt, err = ads.GetAgent(pemID)
+	require.NoError(t, err)
+	assert.Equal(t, int32(7), agt.Health.FailedQueries)
+	assert.True(t, agt.Health.Quarantined)
+	assert.Less(t, agt.Health.Score, int32(40))
+
+	// Kelvins are never quarantined.
+	agt, err = ads.GetAgent(kelvinID)
+	require.NoError(t, err)
+	assert.Equal(t, int32(7), agt.Health.FailedQueries)
+	assert.False(t, agt.Health.Quarantined)
+}
+
 func TestUpdateHeartbeatForNonExistingAgent(t *testing.T) {
 	_, agtMgr, _, cleanup := setupManager(t)
 	defer cleanup()
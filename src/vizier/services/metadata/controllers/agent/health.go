@@ -0,0 +1,129 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package agent
+
+import (
+	"math"
+	"time"
+
+	"px.dev/pixie/src/vizier/services/shared/agentpb"
+)
+
+const (
+	// The interval at which agents are expected to send heartbeats.
+	expectedHeartbeatInterval = 5 * time.Second
+	// Query failures, schema updates and tracepoint failures only count against an agent's health
+	// for this long.
+	healthWindow = 10 * time.Minute
+	// The weight of the latest heartbeat interval in the moving average of the heartbeat jitter.
+	heartbeatJitterWeight = 0.2
+
+	// The most that heartbeat jitter can take off of the health score, reached when the jitter is as
+	// long as the expected heartbeat interval.
+	maxHeartbeatJitterPenalty = 30
+	queryFailurePenalty       = 10
+	tracepointFailurePenalty  = 10
+	// Agents update their schema on startup and when tracepoints are deployed, so only the schema
+	// updates past this many in the window are treated as churn.
+	allowedSchemaUpdates = 5
+	schemaUpdatePenalty  = 5
+
+	// Agents are quarantined when their score drops below quarantineScore, and stay quarantined
+	// until their score recovers to at least recoveryScore.
+	quarantineScore = 40
+	recoveryScore   = 70
+)
+
+// agentHealth tracks the signals which the health score of an agent is computed from.
+type agentHealth struct {
+	lastHeartbeat      time.Time
+	heartbeatJitterNS  float64
+	queryFailures      []time.Time
+	schemaUpdates      []time.Time
+	tracepointFailures []time.Time
+	quarantined        bool
+}
+
+// pruneEvents drops the events which happened before the start of the window.
+func pruneEvents(events []time.Time, now time.Time) []time.Time {
+	start := now.Add(-healthWindow)
+	i := 0
+	for i < len(events) && events[i].Before(start) {
+		i++
+	}
+	return events[i:]
+}
+
+func (h *agentHealth) recordHeartbeat(now time.Time) {
+	if !h.lastHeartbeat.IsZero() {
+		jitter := math.Abs(float64(now.Sub(h.lastHeartbeat) - expectedHeartbeatInterval))
+		h.heartbeatJitterNS = heartbeatJitterWeight*jitter + (1-heartbeatJitterWeight)*h.heartbeatJitterNS
+	}
+	h.lastHeartbeat = now
+}
+
+func (h *agentHealth) recordQueryFailure(now time.Time) {
+	h.queryFailures = append(pruneEvents(h.queryFailures, now), now)
+}
+
+func (h *agentHealth) recordSchemaUpdate(now time.Time) {
+	h.schemaUpdates = append(pruneEvents(h.schemaUpdates, now), now)
+}
+
+func (h *agentHealth) recordTracepointFailure(now time.Time) {
+	h.tracepointFailures = append(pruneEvents(h.tracepointFailures, now), now)
+}
+
+// update computes the health of the agent at the given time, quarantining or releasing the agent
+// if its score has crossed the thresholds. Only agents which collect data are quarantined, since
+// queries can't be planned without the agents which don't.
+func (h *agentHealth) update(now time.Time, collectsData bool) *agentpb.AgentHealth {
+	h.queryFailures = pruneEvents(h.queryFailures, now)
+	h.schemaUpdates = pruneEvents(h.schemaUpdates, now)
+	h.tracepointFailures = pruneEvents(h.tracepointFailures, now)
+
+	jitterPenalty := math.Min(1, h.heartbeatJitterNS/float64(expectedHeartbeatInterval)) * maxHeartbeatJitterPenalty
+	score := 100 - int(math.Round(jitterPenalty))
+	score -= queryFailurePenalty * len(h.queryFailures)
+	score -= tracepointFailurePenalty * len(h.tracepointFailures)
+	if churn := len(h.schemaUpdates) - allowedSchemaUpdates; churn > 0 {
+		score -= schemaUpdatePenalty * churn
+	}
+	if score < 0 {
+		score = 0
+	}
+
+	switch {
+	case !collectsData:
+		h.quarantined = false
+	case score < quarantineScore:
+		h.quarantined = true
+	case score >= recoveryScore:
+		h.quarantined = false
+	}
+
+	return &agentpb.AgentHealth{
+		Score:              int32(score),
+		Quarantined:        h.quarantined,
+		HeartbeatJitterNS:  int64(h.heartbeatJitterNS),
+		FailedQueries:      int32(len(h.queryFailures)),
+		SchemaUpdates:      int32(len(h.schemaUpdates)),
+		TracepointFailures: int32(len(h.tracepointFailures)),
+	}
+}
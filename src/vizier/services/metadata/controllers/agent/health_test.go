@@ -0,0 +1,146 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAgentHealth_Score(t *testing.T) {
+	start := time.Unix(1000, 0)
+
+	tests := []struct {
+		name               string
+		record             func(h *agentHealth)
+		collectsData       bool
+		expectedScore      int32
+		expectedQuarantine bool
+	}{
+		{
+			name: "regular heartbeats",
+			record: func(h *agentHealth) {
+				for i := 0; i < 5; i++ {
+					h.recordHeartbeat(start.Add(time.Duration(i) * expectedHeartbeatInterval))
+				}
+			},
+			collectsData:  true,
+			expectedScore: 100,
+		},
+		{
+			name: "jittery heartbeats",
+			record: func(h *agentHealth) {
+				h.recordHeartbeat(start)
+				h.recordHeartbeat(start.Add(2 * expectedHeartbeatInterval))
+			},
+			collectsData: true,
+			// The jitter is 0.2 of the heartbeat interval.
+			expectedScore: 94,
+		},
+		{
+			name: "query failures",
+			record: func(h *agentHealth) {
+				for i := 0; i < 3; i++ {
+					h.recordQueryFailure(start)
+				}
+			},
+			collectsData:  true,
+			expectedScore: 70,
+		},
+		{
+			name: "schema churn",
+			record: func(h *agentHealth) {
+				for i := 0; i < allowedSchemaUpdates+2; i++ {
+					h.recordSchemaUpdate(start)
+				}
+			},
+			collectsData:  true,
+			expectedScore: 90,
+		},
+		{
+			name: "failures outside of the window",
+			record: func(h *agentHealth) {
+				for i := 0; i < 10; i++ {
+					h.recordTracepointFailure(start.Add(-2 * healthWindow))
+				}
+			},
+			collectsData:  true,
+			expectedScore: 100,
+		},
+		{
+			name: "quarantined",
+			record: func(h *agentHealth) {
+				for i := 0; i < 4; i++ {
+					h.recordQueryFailure(start)
+					h.recordTracepointFailure(start)
+				}
+			},
+			collectsData:       true,
+			expectedScore:      20,
+			expectedQuarantine: true,
+		},
+		{
+			name: "kelvins are never quarantined",
+			record: func(h *agentHealth) {
+				for i := 0; i < 8; i++ {
+					h.recordQueryFailure(start)
+				}
+			},
+			collectsData:  false,
+			expectedScore: 20,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := &agentHealth{}
+			test.record(h)
+			health := h.update(start, test.collectsData)
+			assert.Equal(t, test.expectedScore, health.Score)
+			assert.Equal(t, test.expectedQuarantine, health.Quarantined)
+		})
+	}
+}
+
+func TestAgentHealth_Recovery(t *testing.T) {
+	start := time.Unix(1000, 0)
+	h := &agentHealth{}
+	for i := 0; i < 7; i++ {
+		h.recordQueryFailure(start)
+	}
+	health := h.update(start, true)
+	assert.Equal(t, int32(30), health.Score)
+	assert.True(t, health.Quarantined)
+	assert.Equal(t, int32(7), health.FailedQueries)
+
+	// The agent stays quarantined until its score reaches the recovery score.
+	for i := 0; i < 4; i++ {
+		h.recordQueryFailure(start.Add(healthWindow))
+	}
+	health = h.update(start.Add(healthWindow+time.Second), true)
+	assert.Equal(t, int32(60), health.Score)
+	assert.True(t, health.Quarantined)
+
+	health = h.update(start.Add(2*healthWindow+time.Second), true)
+	assert.Equal(t, int32(100), health.Score)
+	assert.False(t, health.Quarantined)
+	assert.Equal(t, int32(0), health.FailedQueries)
+}
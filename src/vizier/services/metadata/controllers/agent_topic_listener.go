@@ -289,6 +289,9 @@ func (a *AgentTopicListener) onAgentTracepointInfoUpdate(m *messagespb.Tracepoin
 	if err != nil {
 		log.WithError(err).Error("Could not update agent tracepoint status")
 	}
+	if m.State == statuspb.FAILED_STATE {
+		a.agtMgr.RecordTracepointFailure(utils.UUIDFromProtoOrNil(m.AgentID))
+	}
 }
 
 // Stop stops processing any agent messagespb.
@@ -425,7 +428,9 @@ func (ah *AgentHandler) onAgentRegisterRequest(m *messagespb.RegisterAgentReques
 		agentIDs := []uuid.UUID{agentID}
 
 		for _, tp := range tracepoints {
-			if tp.ExpectedState != statuspb.TERMINATED_STATE {
+			// Targeted tracepoints and tracepoints that are still being rolled out are only deployed to
+			// the agents chosen when they were registered.
+			if tp.ExpectedState != statuspb.TERMINATED_STATE && tracepoint.TargetsAllAgents(tp) {
 				err = ah.tpMgr.RegisterTracepoint(agentIDs, utils.UUIDFromProtoOrNil(tp.ID), tp.Tracepoint)
 				if err != nil {
 					log.WithError(err).Error("Failed to send RegisterTracepoint request")
@@ -462,8 +467,10 @@ func (ah *AgentHandler) onAgentHeartbeat(m *messagespb.Heartbeat) {
 			HeartbeatAck: &messagespb.HeartbeatAck{
 				Time: time.Now().UnixNano(),
 				UpdateInfo: &messagespb.MetadataUpdateInfo{
-					ServiceCIDR: ah.agtMgr.GetServiceCIDR(),
-					PodCIDRs:    ah.agtMgr.GetPodCIDRs(),
+					ServiceCIDR:       ah.agtMgr.GetServiceCIDR(),
+					PodCIDRs:          ah.agtMgr.GetPodCIDRs(),
+					AllowedNamespaces: ah.agtMgr.GetAllowedNamespaces(),
+					DeniedNamespaces:  ah.agtMgr.GetDeniedNamespaces(),
 				},
 				SequenceNumber: m.SequenceNumber,
 			},
@@ -423,6 +423,16 @@ func TestAgentHeartbeat(t *testing.T) {
 		GetPodCIDRs().
 		Return([]string{"10.64.4.0/21"})
 
+	mockAgtMgr.
+		EXPECT().
+		GetAllowedNamespaces().
+		Return([]string{"ns1"})
+
+	mockAgtMgr.
+		EXPECT().
+		GetDeniedNamespaces().
+		Return([]string{"kube-system"})
+
 	mockAgtMgr.
 		EXPECT().
 		UpdateHeartbeat(uuid.FromStringOrNil(testutils.UnhealthyKelvinAgentUUID)).
@@ -545,6 +555,11 @@ func TestAgentTracepointInfoUpdate(t *testing.T) {
 		}).
 		Return(nil)
 
+	mockTracepointStore.
+		EXPECT().
+		GetTracepoint(tpID).
+		Return(&storepb.TracepointInfo{ID: utils.ProtoFromUUID(tpID)}, nil)
+
 	req := &messagespb.VizierMessage{
 		Msg: &messagespb.VizierMessage_TracepointMessage{
 			TracepointMessage: &messagespb.TracepointMessage{
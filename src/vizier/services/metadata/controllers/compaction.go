@@ -0,0 +1,55 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"px.dev/pixie/src/vizier/services/metadata/metadatapb"
+	"px.dev/pixie/src/vizier/utils/datastore"
+)
+
+// CompactDatastore compacts the datastore, reclaiming the space used by deleted and overwritten keys.
+func (s *Server) CompactDatastore(ctx context.Context, req *metadatapb.CompactDatastoreRequest) (*metadatapb.CompactDatastoreResponse, error) {
+	compactor, ok := s.ds.(datastore.Compactor)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "the datastore does not support manual compaction")
+	}
+	sizer, hasSize := s.ds.(datastore.Sizer)
+
+	resp := &metadatapb.CompactDatastoreResponse{}
+	if hasSize {
+		resp.SizeBytesBefore = sizer.Size()
+	}
+	start := time.Now()
+	if err := compactor.Compact(); err != nil {
+		return nil, err
+	}
+	if hasSize {
+		resp.SizeBytesAfter = sizer.Size()
+	}
+	log.WithField("duration", time.Since(start)).WithField("sizeBefore", resp.SizeBytesBefore).
+		WithField("sizeAfter", resp.SizeBytesAfter).Info("Compacted datastore")
+	return resp, nil
+}
@@ -0,0 +1,46 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package controllers_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"px.dev/pixie/src/vizier/services/metadata/metadatapb"
+)
+
+func Test_Server_CompactDatastore(t *testing.T) {
+	client, db, ctx, cleanup := setupAdminServer(t)
+	defer cleanup()
+
+	require.NoError(t, db.Set("/agent/1", "agent1"))
+	require.NoError(t, db.Set("/agent/2", "agent2"))
+	require.NoError(t, db.Delete("/agent/1"))
+
+	resp, err := client.CompactDatastore(ctx, &metadatapb.CompactDatastoreRequest{})
+	require.NoError(t, err)
+	assert.Greater(t, resp.SizeBytesBefore, uint64(0))
+	assert.Greater(t, resp.SizeBytesAfter, uint64(0))
+
+	keys, _, err := db.GetWithPrefix("/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/agent/2"}, keys)
+}
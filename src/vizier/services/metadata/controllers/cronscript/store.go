@@ -111,11 +111,15 @@ func (t *Datastore) UpsertCronScript(script *cvmsgspb.CronScript) error {
 
 // DeleteCronScript deletes a cron script from the store by ID.
 func (t *Datastore) DeleteCronScript(id uuid.UUID) error {
-	err := t.ds.DeleteWithPrefix(getCronScriptKey(id))
-	if err != nil {
-		return err
-	}
-	return t.ds.DeleteWithPrefix(getCronScriptResultsKey(id))
+	batch := t.ds.NewBatch()
+	deleteCronScript(batch, id)
+	return batch.Commit()
+}
+
+// deleteCronScript adds the deletes for a cron script and its results to the batch.
+func deleteCronScript(batch datastore.Batch, id uuid.UUID) {
+	batch.DeleteWithPrefix(getCronScriptKey(id))
+	batch.DeleteWithPrefix(getCronScriptResultsKey(id))
 }
 
 // SetCronScripts sets the list of all cron scripts to match the given set of scripts.
@@ -132,34 +136,29 @@ func (t *Datastore) SetCronScripts(scripts []*cvmsgspb.CronScript) error {
 		removedScriptIDs[utils.UUIDFromProtoOrNil(es.ID)] = struct{}{}
 	}
 
-	// Update with new scripts.
-	// We may want to make this a MultiSetter at some point.
-	var lastError error
+	// Update with new scripts. All of the scripts are written in a single batch, so that the store
+	// never holds a mix of the old and new scripts.
+	batch := t.ds.NewBatch()
 	for _, s := range scripts {
-		err = t.UpsertCronScript(s)
+		val, err := s.Marshal()
 		if err != nil {
-			lastError = err
 			log.WithError(err).Error("Failed to add cron script")
+			return err
 		}
+		sID := utils.UUIDFromProtoOrNil(s.ID)
+		batch.Set(getCronScriptKey(sID), string(val))
 
 		// A script that appears here was not removed.
 		// It is safe to delete elements that don't exist in the original map.
-		delete(removedScriptIDs, utils.UUIDFromProtoOrNil(s.ID))
-	}
-
-	if lastError != nil {
-		return lastError
+		delete(removedScriptIDs, sID)
 	}
 
 	// Delete all results for removed scripts
 	for k := range removedScriptIDs {
-		err := t.DeleteCronScript(k)
-		if err != nil {
-			lastError = err
-		}
+		deleteCronScript(batch, k)
 	}
 
-	return lastError
+	return batch.Commit()
 }
 
 // GetCronScriptResults returns the results of past runs of a specific CronScript.
@@ -208,12 +207,11 @@ func (t *Datastore) RecordCronScriptResult(result *storepb.CronScriptResult) err
 	if err != nil {
 		return err
 	}
-	err = t.ds.Set(getCronScriptSpecificResultKey(scriptID, idx), string(val))
-	if err != nil {
-		return err
-	}
+	batch := t.ds.NewBatch()
+	batch.Set(getCronScriptSpecificResultKey(scriptID, idx), string(val))
 	// Increment the index.
-	return t.ds.Set(getCronScriptResultsIndexKey(scriptID), fmt.Sprint((idx+1)%maxResultsPerCronScript))
+	batch.Set(getCronScriptResultsIndexKey(scriptID), fmt.Sprint((idx+1)%maxResultsPerCronScript))
+	return batch.Commit()
 }
 
 // GetAllCronScriptResults returns all of the stored execution results for all scripts.
@@ -0,0 +1,136 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package k8smeta
+
+import (
+	"fmt"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/pmezard/go-difflib/difflib"
+
+	"px.dev/pixie/src/shared/k8s/metadatapb"
+	"px.dev/pixie/src/vizier/services/metadata/storepb"
+)
+
+// RestartableObjectTypes are the object types which restarts can be found for.
+var RestartableObjectTypes = map[string]bool{
+	"pods":         true,
+	"deployments":  true,
+	"statefulsets": true,
+	"daemonsets":   true,
+}
+
+// resourceObjectKey gets the object type, namespace and name of a resource. Containers are not
+// tracked as separate objects, since they are part of their pod.
+func resourceObjectKey(r *storepb.K8SResource) (string, *metadatapb.ObjectMetadata) {
+	switch r.Resource.(type) {
+	case *storepb.K8SResource_Pod:
+		return "pods", r.GetPod().GetMetadata()
+	case *storepb.K8SResource_Service:
+		return "services", r.GetService().GetMetadata()
+	case *storepb.K8SResource_Endpoints:
+		return "endpoints", r.GetEndpoints().GetMetadata()
+	case *storepb.K8SResource_Namespace:
+		return "namespaces", r.GetNamespace().GetMetadata()
+	case *storepb.K8SResource_Node:
+		return "nodes", r.GetNode().GetMetadata()
+	case *storepb.K8SResource_ReplicaSet:
+		return "replicasets", r.GetReplicaSet().GetMetadata()
+	case *storepb.K8SResource_Deployment:
+		return "deployments", r.GetDeployment().GetMetadata()
+	case *storepb.K8SResource_StatefulSet:
+		return "statefulsets", r.GetStatefulSet().GetMetadata()
+	case *storepb.K8SResource_DaemonSet:
+		return "daemonsets", r.GetDaemonSet().GetMetadata()
+	case *storepb.K8SResource_Job:
+		return "jobs", r.GetJob().GetMetadata()
+	case *storepb.K8SResource_CronJob:
+		return "cronjobs", r.GetCronJob().GetMetadata()
+	case *storepb.K8SResource_Ingress:
+		return "ingresses", r.GetIngress().GetMetadata()
+	case *storepb.K8SResource_OwnerObject:
+		return ownerObjectType, r.GetOwnerObject().GetMetadata()
+	}
+	return "", nil
+}
+
+// podTemplate gets the pod template of a workload, or nil if the resource is not a workload.
+func podTemplate(r *storepb.K8SResource) *metadatapb.PodTemplateSpec {
+	switch r.Resource.(type) {
+	case *storepb.K8SResource_Deployment:
+		return r.GetDeployment().GetSpec().GetTemplate()
+	case *storepb.K8SResource_StatefulSet:
+		return r.GetStatefulSet().GetSpec().GetTemplate()
+	case *storepb.K8SResource_DaemonSet:
+		return r.GetDaemonSet().GetSpec().GetTemplate()
+	}
+	return nil
+}
+
+// FindRestarts finds the entries at which an object was restarted, given its history ordered by
+// update version. Workloads are restarted when their pod template changes, such as by
+// `kubectl rollout restart`, and pods are restarted when their restart count increases.
+func FindRestarts(entries []*storepb.K8SResourceHistoryEntry) []*storepb.K8SResourceHistoryEntry {
+	var restarts []*storepb.K8SResourceHistoryEntry
+	for i := 1; i < len(entries); i++ {
+		prev := entries[i-1].Resource
+		curr := entries[i].Resource
+		if curr.GetPod() != nil {
+			if curr.GetPod().GetStatus().GetRestartCount() > prev.GetPod().GetStatus().GetRestartCount() {
+				restarts = append(restarts, entries[i])
+			}
+			continue
+		}
+		prevTemplate := podTemplate(prev)
+		currTemplate := podTemplate(curr)
+		if prevTemplate != nil && currTemplate != nil && !proto.Equal(prevTemplate, currTemplate) {
+			restarts = append(restarts, entries[i])
+		}
+	}
+	return restarts
+}
+
+// DiffEntries returns a unified diff of the text formats of two versions of an object.
+func DiffEntries(from *storepb.K8SResourceHistoryEntry, to *storepb.K8SResourceHistoryEntry) (string, error) {
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(proto.MarshalTextString(from.Resource)),
+		B:        difflib.SplitLines(proto.MarshalTextString(to.Resource)),
+		FromFile: fmt.Sprintf("version %d", from.UpdateVersion),
+		ToFile:   fmt.Sprintf("version %d", to.UpdateVersion),
+		Context:  3,
+	})
+}
+
+// podOwnedIP returns whether the pod in the given entry had its IP at the given time. Pods release
+// their IP once they are deleted or have finished running.
+func podOwnedIP(entry *storepb.K8SResourceHistoryEntry, timeNS int64) bool {
+	pod := entry.Resource.GetPod()
+	md := pod.GetMetadata()
+	if md.GetCreationTimestampNS() > timeNS {
+		return false
+	}
+	if md.GetDeletionTimestampNS() != 0 && md.GetDeletionTimestampNS() <= timeNS {
+		return false
+	}
+	switch pod.GetStatus().GetPhase() {
+	case metadatapb.SUCCEEDED, metadatapb.FAILED, metadatapb.TERMINATED:
+		return entry.TimestampNS > timeNS
+	}
+	return true
+}
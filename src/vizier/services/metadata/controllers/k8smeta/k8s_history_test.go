@@ -0,0 +1,123 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package k8smeta_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"px.dev/pixie/src/shared/k8s/metadatapb"
+	"px.dev/pixie/src/vizier/services/metadata/controllers/k8smeta"
+	"px.dev/pixie/src/vizier/services/metadata/storepb"
+)
+
+func deploymentVersion(uv int64, image string, replicas int32) *storepb.K8SResourceHistoryEntry {
+	return &storepb.K8SResourceHistoryEntry{
+		UpdateVersion: uv,
+		TimestampNS:   uv * 10,
+		Resource: &storepb.K8SResource{
+			Resource: &storepb.K8SResource_Deployment{
+				Deployment: &metadatapb.Deployment{
+					Metadata: &metadatapb.ObjectMetadata{Name: "web", Namespace: "ns"},
+					Spec: &metadatapb.DeploymentSpec{
+						Replicas: replicas,
+						Template: &metadatapb.PodTemplateSpec{
+							Metadata: &metadatapb.ObjectMetadata{
+								Annotations: map[string]string{"kubectl.kubernetes.io/restartedAt": image},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func podVersion(uv int64, restarts int64) *storepb.K8SResourceHistoryEntry {
+	return &storepb.K8SResourceHistoryEntry{
+		UpdateVersion: uv,
+		Resource: &storepb.K8SResource{
+			Resource: &storepb.K8SResource_Pod{
+				Pod: &metadatapb.Pod{
+					Metadata: &metadatapb.ObjectMetadata{Name: "web-0", Namespace: "ns"},
+					Status:   &metadatapb.PodStatus{RestartCount: restarts},
+				},
+			},
+		},
+	}
+}
+
+func TestFindRestarts(t *testing.T) {
+	tests := []struct {
+		name     string
+		entries  []*storepb.K8SResourceHistoryEntry
+		expected []int64
+	}{
+		{
+			name: "deployment template changes",
+			entries: []*storepb.K8SResourceHistoryEntry{
+				deploymentVersion(1, "a", 1),
+				// Scaling isn't a restart.
+				deploymentVersion(2, "a", 3),
+				deploymentVersion(3, "b", 3),
+				deploymentVersion(4, "b", 3),
+				deploymentVersion(5, "c", 3),
+			},
+			expected: []int64{3, 5},
+		},
+		{
+			name: "pod restart count increases",
+			entries: []*storepb.K8SResourceHistoryEntry{
+				podVersion(1, 0),
+				podVersion(2, 1),
+				podVersion(3, 1),
+				podVersion(4, 3),
+			},
+			expected: []int64{2, 4},
+		},
+		{
+			name:    "single version",
+			entries: []*storepb.K8SResourceHistoryEntry{deploymentVersion(1, "a", 1)},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var versions []int64
+			for _, e := range k8smeta.FindRestarts(test.entries) {
+				versions = append(versions, e.UpdateVersion)
+			}
+			assert.Equal(t, test.expected, versions)
+		})
+	}
+}
+
+func TestDiffEntries(t *testing.T) {
+	diff, err := k8smeta.DiffEntries(deploymentVersion(1, "a", 1), deploymentVersion(2, "a", 3))
+	require.NoError(t, err)
+	assert.Contains(t, diff, "--- version 1\n+++ version 2\n")
+	assert.Contains(t, diff, "-    replicas: 1\n")
+	assert.Contains(t, diff, "+    replicas: 3\n")
+
+	diff, err = k8smeta.DiffEntries(deploymentVersion(1, "a", 1), deploymentVersion(2, "a", 1))
+	require.NoError(t, err)
+	assert.Equal(t, "", diff)
+}
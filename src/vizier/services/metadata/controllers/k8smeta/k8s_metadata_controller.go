@@ -22,6 +22,9 @@ import (
 	"sync"
 	"time"
 
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 )
@@ -29,6 +32,8 @@ import (
 const (
 	// resourceUpdateTTL is how long the k8s update live in the DataStore.
 	resourceUpdateTTL = 24 * time.Hour
+	// informerResync is how often the informers resync all of the objects they watch.
+	informerResync = 12 * time.Hour
 )
 
 // Controller listens to any metadata updates from the K8s API and forwards them
@@ -40,13 +45,16 @@ type Controller struct {
 	watchers []watcher
 }
 
-// watcher watches a k8s resource type and forwards the updates to the given update channel.
+// watcher watches a k8s resource type and forwards the updates for objects in the given namespace
+// scope to the given update channel.
 type watcher interface {
-	StartWatcher(chan struct{})
+	StartWatcher(chan struct{}, *NamespaceScope)
 }
 
-// NewController creates a new Controller.
-func NewController(updateCh chan *K8sResourceMessage) (*Controller, error) {
+// NewController creates a new Controller. Objects of the kinds listed in the given ConfigMap in the
+// given namespace are also watched, so that they can be resolved as the owners of other objects.
+// Only objects in the given namespace scope are forwarded.
+func NewController(updateCh chan *K8sResourceMessage, namespace string, ownerKindsConfigMap string, scope *NamespaceScope) (*Controller, error) {
 	// There is a specific config for services running in the cluster.
 	kubeConfig, err := rest.InClusterConfig()
 	if err != nil {
@@ -59,26 +67,49 @@ func NewController(updateCh chan *K8sResourceMessage) (*Controller, error) {
 		return nil, err
 	}
 
+	dynamicClient, err := dynamic.NewForConfig(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+
 	quitCh := make(chan struct{})
 
 	// Create a watcher for each resource.
 	// The resource types we watch the K8s API for. These types are in a specific order:
 	// for example, nodes and namespaces must be synced before pods, since nodes/namespaces
 	// contain pods.
+	clusterFactory := informers.NewSharedInformerFactory(clientset, informerResync)
 	watchers := []watcher{
-		nodeWatcher("nodes", updateCh, clientset),
-		namespaceWatcher("namespaces", updateCh, clientset),
-		podWatcher("pods", updateCh, clientset),
-		endpointsWatcher("endpoints", updateCh, clientset),
-		serviceWatcher("services", updateCh, clientset),
-		replicaSetWatcher("replicasets", updateCh, clientset),
-		deploymentWatcher("deployments", updateCh, clientset),
+		nodeWatcher("nodes", updateCh, clusterFactory),
+		namespaceWatcher("namespaces", updateCh, clusterFactory),
+	}
+	// Namespaced resources are only listed in the namespaces in scope.
+	for _, factory := range scope.InformerFactories(clientset, informerResync) {
+		watchers = append(watchers,
+			podWatcher("pods", updateCh, factory),
+			endpointsWatcher("endpoints", updateCh, factory),
+			serviceWatcher("services", updateCh, factory),
+			replicaSetWatcher("replicasets", updateCh, factory),
+			deploymentWatcher("deployments", updateCh, factory),
+			statefulSetWatcher("statefulsets", updateCh, factory),
+			daemonSetWatcher("daemonsets", updateCh, factory),
+			jobWatcher("jobs", updateCh, factory),
+			cronJobWatcher("cronjobs", updateCh, factory),
+			ingressWatcher("ingresses", updateCh, factory),
+		)
+	}
+
+	// A misconfigured list of owner kinds shouldn't prevent the built-in resources from being watched.
+	customWatchers, err := ownerWatchers(updateCh, clientset, dynamicClient, namespace, ownerKindsConfigMap, scope)
+	if err != nil {
+		log.WithError(err).Error("Failed to create watchers for owner kinds")
 	}
+	watchers = append(watchers, customWatchers...)
 
 	mc := &Controller{quitCh: quitCh, updateCh: updateCh, watchers: watchers}
 
 	for _, w := range mc.watchers {
-		go w.StartWatcher(quitCh)
+		go w.StartWatcher(quitCh, scope)
 	}
 
 	return mc, nil
@@ -33,6 +33,7 @@ import (
 	"github.com/gogo/protobuf/types"
 	"github.com/nats-io/nats.go"
 	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/watch"
 
 	"px.dev/pixie/src/shared/cvmsgspb"
@@ -117,6 +118,28 @@ type PodLabelStore interface {
 	FetchPodsWithLabels(namespace string, labels map[string]string) ([]string, error)
 }
 
+// LabelStore handles storing the labels and annotations of k8s objects, and fetching the objects
+// whose labels match a label selector.
+type LabelStore interface {
+	PodLabelStore
+	// SetObjectLabels stores the labels and annotations of an object.
+	SetObjectLabels(obj *storepb.K8SObjectLabels) error
+	// DeleteObjectLabels deletes the labels and annotations of an object.
+	DeleteObjectLabels(objectType string, namespace string, name string) error
+	// FetchObjectsWithSelector gets the objects of the given type in the namespace whose labels match
+	// the selector. Objects in all namespaces are fetched if the namespace is empty.
+	FetchObjectsWithSelector(objectType string, namespace string, selector labels.Selector) ([]*storepb.K8SObjectLabels, error)
+}
+
+// LabelIndexedObjectTypes are the types of objects whose labels and annotations are stored in the
+// LabelStore.
+var LabelIndexedObjectTypes = map[string]bool{
+	"pods":        true,
+	"services":    true,
+	"deployments": true,
+	"namespaces":  true,
+}
+
 // An UpdateProcessor is responsible for processing an incoming update, such as determining what
 // updates should be persisted and sent to NATS.
 type UpdateProcessor interface {
@@ -146,6 +169,8 @@ type ProcessorState struct {
 	NodeToIP map[string]string
 	// A map from pod name to its IP.
 	PodToIP map[string]string
+	// A map from node name to its labels.
+	NodeLabels map[string]map[string]string
 }
 
 // Handler handles any incoming k8s updates. It saves the update to the store for persistence, and
@@ -155,10 +180,12 @@ type Handler struct {
 	updateCh <-chan *K8sResourceMessage
 	// The store where k8s resources are stored.
 	mds Store
-	// The store where pod label information is stored.
-	pls PodLabelStore
+	// The store where pod and other object label information is stored.
+	pls LabelStore
 	// The NATS connection on which to send messages on.
 	conn *nats.Conn
+	// The namespaces whose objects are tracked and sent to the agents.
+	scope *NamespaceScope
 	// Done channel, to stop processing metadata updates.
 	done chan struct{}
 	// A map from object type to the update processor that should handle that type.
@@ -166,16 +193,19 @@ type Handler struct {
 
 	// State that should be shared across all update processors.
 	state ProcessorState
-	once  sync.Once
+	// Guards updates to the state, which may be read outside of the update loop.
+	stateMu sync.RWMutex
+	once    sync.Once
 }
 
-// NewHandler creates a new Handler.
-func NewHandler(updateCh <-chan *K8sResourceMessage, mds Store, pls PodLabelStore, conn *nats.Conn) *Handler {
+// NewHandler creates a new Handler. Updates for objects outside of the given namespace scope are
+// dropped, and are never sent to the agents.
+func NewHandler(updateCh <-chan *K8sResourceMessage, mds Store, pls LabelStore, conn *nats.Conn, scope *NamespaceScope) *Handler {
 	done := make(chan struct{})
 	leaderMsgs := make(map[string]*metadatapb.Endpoints)
 	handlerMap := make(map[string]UpdateProcessor)
-	state := ProcessorState{LeaderMsgs: leaderMsgs, PodCIDRs: make([]string, 0), NodeToIP: make(map[string]string), PodToIP: make(map[string]string)}
-	mh := &Handler{updateCh: updateCh, mds: mds, pls: pls, conn: conn, done: done, processHandlerMap: handlerMap, state: state}
+	state := ProcessorState{LeaderMsgs: leaderMsgs, PodCIDRs: make([]string, 0), NodeToIP: make(map[string]string), PodToIP: make(map[string]string), NodeLabels: make(map[string]map[string]string)}
+	mh := &Handler{updateCh: updateCh, mds: mds, pls: pls, conn: conn, scope: scope, done: done, processHandlerMap: handlerMap, state: state}
 
 	// Register update processors.
 	mh.processHandlerMap["endpoints"] = &EndpointsUpdateProcessor{}
@@ -185,6 +215,9 @@ func NewHandler(updateCh <-chan *K8sResourceMessage, mds Store, pls PodLabelStor
 	mh.processHandlerMap["namespaces"] = &NamespaceUpdateProcessor{}
 	mh.processHandlerMap["replicasets"] = &ReplicaSetUpdateProcessor{}
 	mh.processHandlerMap["deployments"] = &DeploymentUpdateProcessor{}
+	for objectType := range workloadResources {
+		mh.processHandlerMap[objectType] = NewWorkloadUpdateProcessor(objectType)
+	}
 
 	go mh.processUpdates()
 	return mh
@@ -228,12 +261,17 @@ func (m *Handler) processUpdates() {
 			processor = p
 
 			update := msg.Object
+			if !m.scope.IncludesResource(update) {
+				continue
+			}
 			if msg.EventType == watch.Deleted {
 				processor.SetDeleted(update)
 			}
 
 			// Check that the update is valid and should be handled.
+			m.stateMu.Lock()
 			valid := processor.ValidateUpdate(update, &m.state)
+			m.stateMu.Unlock()
 			if !valid {
 				continue
 			}
@@ -244,6 +282,12 @@ func (m *Handler) processUpdates() {
 					log.WithError(err).Error("Failed to update pod labels state")
 				}
 			}
+			if LabelIndexedObjectTypes[msg.ObjectType] {
+				err := UpdateObjectLabelStore(msg.ObjectType, update, m.pls)
+				if err != nil {
+					log.WithError(err).Error("Failed to update object labels state")
+				}
+			}
 
 			// Persist the update in the data store.
 			updates := processor.GetStoredProtos(update)
@@ -258,6 +302,9 @@ func (m *Handler) processUpdates() {
 					UpdateVersion: currUV,
 				}
 
+				// The observed time is kept so that the update log can be queried as the history of
+				// the cluster.
+				u.TimestampNS = time.Now().UnixNano()
 				err := m.mds.AddFullResourceUpdate(currUV, u)
 				if err != nil {
 					log.WithError(err).Error("Failed to store resource update")
@@ -384,14 +431,18 @@ func (m *Handler) GetUpdatesForIP(ip string, from int64, to int64) ([]*metadatap
 		return nil, err
 	}
 
-	updates := make([]*metadatapb.ResourceUpdate, len(allUpdates))
+	updates := make([]*metadatapb.ResourceUpdate, 0, len(allUpdates))
 	var currVersion int64
 
-	// For each resource update, get the update(s) that should be sent over NATS.
-	for i, u := range allUpdates {
+	// For each resource update, get the update(s) that should be sent over NATS. Updates which were
+	// stored before the namespace scope changed may be out of scope, and are skipped.
+	for _, u := range allUpdates {
+		if !m.scope.IncludesUpdate(u.Update) {
+			continue
+		}
 		u.Update.PrevUpdateVersion = currVersion
 		currVersion = u.Update.UpdateVersion
-		updates[i] = u.Update
+		updates = append(updates, u.Update)
 	}
 
 	return updates, nil
@@ -405,11 +456,69 @@ func (m *Handler) GetServiceCIDR() string {
 	return ""
 }
 
+// GetNodeIPsWithLabels returns the internal IPs of the nodes whose labels match all of the given labels.
+func (m *Handler) GetNodeIPsWithLabels(labels map[string]string) []string {
+	m.stateMu.RLock()
+	defer m.stateMu.RUnlock()
+
+	var ips []string
+	for node, nodeLabels := range m.state.NodeLabels {
+		matches := true
+		for k, v := range labels {
+			if nodeLabels[k] != v {
+				matches = false
+				break
+			}
+		}
+		if !matches {
+			continue
+		}
+		if ip, ok := m.state.NodeToIP[node]; ok {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
+
+// GetHostIPsForPodsWithLabels returns the IPs of the hosts running pods in the namespace whose labels
+// match all of the given labels.
+func (m *Handler) GetHostIPsForPodsWithLabels(namespace string, labels map[string]string) ([]string, error) {
+	pods, err := m.pls.FetchPodsWithLabels(namespace, labels)
+	if err != nil {
+		return nil, err
+	}
+
+	m.stateMu.RLock()
+	defer m.stateMu.RUnlock()
+
+	seen := make(map[string]bool)
+	var ips []string
+	for _, pod := range pods {
+		ip, ok := m.state.PodToIP[fmt.Sprintf("%s/%s", namespace, pod)]
+		if !ok || ip == "" || seen[ip] {
+			continue
+		}
+		seen[ip] = true
+		ips = append(ips, ip)
+	}
+	return ips, nil
+}
+
 // GetPodCIDRs returns the PodCIDRs for the cluster.
 func (m *Handler) GetPodCIDRs() []string {
 	return m.state.PodCIDRs
 }
 
+// GetAllowedNamespaces returns the namespaces in the allowlist of the handler's namespace scope.
+func (m *Handler) GetAllowedNamespaces() []string {
+	return m.scope.Allowed()
+}
+
+// GetDeniedNamespaces returns the namespaces in the denylist of the handler's namespace scope.
+func (m *Handler) GetDeniedNamespaces() []string {
+	return m.scope.Denied()
+}
+
 func setDeleted(objMeta *metadatapb.ObjectMetadata) {
 	if objMeta.DeletionTimestampNS != 0 {
 		// Deletion timestamp already set.
@@ -768,6 +877,42 @@ func UpdatePodLabelStore(update *storepb.K8SResource, pls PodLabelStore) error {
 	return nil
 }
 
+// UpdateObjectLabelStore reads the resource update, and updates the labels and annotations of the
+// object in the store. The object's labels are deleted from the store if the object has been deleted
+// or, for pods, has finished.
+func UpdateObjectLabelStore(objectType string, update *storepb.K8SResource, ls LabelStore) error {
+	var md *metadatapb.ObjectMetadata
+	finished := false
+	switch {
+	case update.GetPod() != nil:
+		md = update.GetPod().Metadata
+		switch update.GetPod().GetStatus().GetPhase() {
+		case metadatapb.SUCCEEDED, metadatapb.FAILED, metadatapb.TERMINATED:
+			finished = true
+		}
+	case update.GetService() != nil:
+		md = update.GetService().Metadata
+	case update.GetDeployment() != nil:
+		md = update.GetDeployment().Metadata
+	case update.GetNamespace() != nil:
+		md = update.GetNamespace().Metadata
+	}
+	if md == nil {
+		return nil
+	}
+
+	if finished || md.DeletionTimestampNS != 0 {
+		return ls.DeleteObjectLabels(objectType, md.Namespace, md.Name)
+	}
+	return ls.SetObjectLabels(&storepb.K8SObjectLabels{
+		ObjectType:  objectType,
+		Namespace:   md.Namespace,
+		Name:        md.Name,
+		Labels:      md.Labels,
+		Annotations: md.Annotations,
+	})
+}
+
 // NodeUpdateProcessor is a processor for nodes.
 type NodeUpdateProcessor struct{}
 
@@ -797,9 +942,15 @@ func (p *NodeUpdateProcessor) ValidateUpdate(obj *storepb.K8SResource, state *Pr
 	// send updates to.
 	if n.Metadata.DeletionTimestampNS != 0 {
 		delete(state.NodeToIP, n.Metadata.Name)
+		delete(state.NodeLabels, n.Metadata.Name)
 		return true
 	}
 
+	if state.NodeLabels == nil {
+		state.NodeLabels = make(map[string]map[string]string)
+	}
+	state.NodeLabels[n.Metadata.Name] = n.Metadata.Labels
+
 	for _, addr := range n.Status.Addresses {
 		if addr.Type == metadatapb.NODE_ADDR_TYPE_INTERNAL_IP {
 			state.NodeToIP[n.Metadata.Name] = addr.Address
@@ -1004,6 +1155,132 @@ func (p *DeploymentUpdateProcessor) GetUpdatesToSend(updates []*StoredUpdate, st
 	}
 }
 
+// workloadResource describes how to handle a kind of resource which is sent to all agents as is.
+type workloadResource struct {
+	// getMetadata gets the metadata of the resource, or nil if the object is of a different kind.
+	getMetadata func(*storepb.K8SResource) *metadatapb.ObjectMetadata
+	// getResourceUpdate converts the resource to the update sent to the agents.
+	getResourceUpdate func(*storepb.K8SResource, int64) *metadatapb.ResourceUpdate
+}
+
+// workloadResources are the kinds of resources handled by WorkloadUpdateProcessor, by object type.
+var workloadResources = map[string]*workloadResource{
+	"statefulsets": {
+		getMetadata: func(obj *storepb.K8SResource) *metadatapb.ObjectMetadata {
+			return obj.GetStatefulSet().GetMetadata()
+		},
+		getResourceUpdate: func(obj *storepb.K8SResource, uv int64) *metadatapb.ResourceUpdate {
+			return getResourceUpdateFromStatefulSet(obj.GetStatefulSet(), uv)
+		},
+	},
+	"daemonsets": {
+		getMetadata: func(obj *storepb.K8SResource) *metadatapb.ObjectMetadata {
+			return obj.GetDaemonSet().GetMetadata()
+		},
+		getResourceUpdate: func(obj *storepb.K8SResource, uv int64) *metadatapb.ResourceUpdate {
+			return getResourceUpdateFromDaemonSet(obj.GetDaemonSet(), uv)
+		},
+	},
+	"jobs": {
+		getMetadata: func(obj *storepb.K8SResource) *metadatapb.ObjectMetadata {
+			return obj.GetJob().GetMetadata()
+		},
+		getResourceUpdate: func(obj *storepb.K8SResource, uv int64) *metadatapb.ResourceUpdate {
+			return getResourceUpdateFromJob(obj.GetJob(), uv)
+		},
+	},
+	"cronjobs": {
+		getMetadata: func(obj *storepb.K8SResource) *metadatapb.ObjectMetadata {
+			return obj.GetCronJob().GetMetadata()
+		},
+		getResourceUpdate: func(obj *storepb.K8SResource, uv int64) *metadatapb.ResourceUpdate {
+			return getResourceUpdateFromCronJob(obj.GetCronJob(), uv)
+		},
+	},
+	"ingresses": {
+		getMetadata: func(obj *storepb.K8SResource) *metadatapb.ObjectMetadata {
+			return obj.GetIngress().GetMetadata()
+		},
+		getResourceUpdate: func(obj *storepb.K8SResource, uv int64) *metadatapb.ResourceUpdate {
+			return getResourceUpdateFromIngress(obj.GetIngress(), uv)
+		},
+	},
+	ownerObjectType: {
+		getMetadata: func(obj *storepb.K8SResource) *metadatapb.ObjectMetadata {
+			return obj.GetOwnerObject().GetMetadata()
+		},
+		getResourceUpdate: func(obj *storepb.K8SResource, uv int64) *metadatapb.ResourceUpdate {
+			return getResourceUpdateFromOwnerObject(obj.GetOwnerObject(), uv)
+		},
+	},
+}
+
+// WorkloadUpdateProcessor is a processor for the resources which are stored and sent to all agents
+// as is, such as statefulsets, daemonsets, jobs, cronjobs, ingresses and custom owner objects.
+type WorkloadUpdateProcessor struct {
+	objectType string
+	resource   *workloadResource
+}
+
+// NewWorkloadUpdateProcessor creates a processor for the given object type, such as "statefulsets".
+// Returns nil if the object type isn't handled by WorkloadUpdateProcessor.
+func NewWorkloadUpdateProcessor(objectType string) *WorkloadUpdateProcessor {
+	r, ok := workloadResources[objectType]
+	if !ok {
+		return nil
+	}
+	return &WorkloadUpdateProcessor{objectType: objectType, resource: r}
+}
+
+// IsNodeScoped returns whether this update is scoped to specific nodes, or should be sent to all nodes.
+func (p *WorkloadUpdateProcessor) IsNodeScoped() bool {
+	return false
+}
+
+// SetDeleted sets the deletion timestamp for the object, if there is none already set.
+func (p *WorkloadUpdateProcessor) SetDeleted(obj *storepb.K8SResource) {
+	md := p.resource.getMetadata(obj)
+	if md == nil {
+		return
+	}
+	setDeleted(md)
+}
+
+// ValidateUpdate checks that the provided object is of the processor's kind.
+func (p *WorkloadUpdateProcessor) ValidateUpdate(obj *storepb.K8SResource, state *ProcessorState) bool {
+	if p.resource.getMetadata(obj) == nil {
+		log.WithField("object", obj).WithField("type", p.objectType).Trace("Received object of the wrong kind when handling metadata.")
+		return false
+	}
+
+	return true
+}
+
+// GetStoredProtos gets the update protos that should be persisted.
+func (p *WorkloadUpdateProcessor) GetStoredProtos(obj *storepb.K8SResource) []*storepb.K8SResource {
+	return []*storepb.K8SResource{obj}
+}
+
+// GetUpdatesToSend gets the resource updates that should be sent out to the agents, along with the agent IPs that the update should be sent to.
+func (p *WorkloadUpdateProcessor) GetUpdatesToSend(updates []*StoredUpdate, state *ProcessorState) []*OutgoingUpdate {
+	if len(updates) == 0 {
+		return nil
+	}
+
+	// Send the update to all PEMs + Kelvin.
+	agents := []string{KelvinUpdateTopic}
+	for _, ip := range state.NodeToIP {
+		agents = append(agents, ip)
+	}
+
+	return []*OutgoingUpdate{
+		{
+			Update: p.resource.getResourceUpdate(updates[0].Update, updates[0].UpdateVersion),
+			Topics: agents,
+		},
+	}
+}
+
 func formatContainerID(cid string) (metadatapb.ContainerType, string) {
 	// Strip prefixes like docker:// or containerd://
 	tokens := strings.SplitN(cid, "://", 2)
@@ -1199,6 +1476,155 @@ func getResourceUpdateFromDeployment(dep *metadatapb.Deployment, uv int64) *meta
 	}
 }
 
+func getResourceUpdateFromStatefulSet(ss *metadatapb.StatefulSet, uv int64) *metadatapb.ResourceUpdate {
+	return &metadatapb.ResourceUpdate{
+		UpdateVersion: uv,
+		Update: &metadatapb.ResourceUpdate_StatefulSetUpdate{
+			StatefulSetUpdate: &metadatapb.StatefulSetUpdate{
+				UID:               ss.Metadata.UID,
+				Name:              ss.Metadata.Name,
+				StartTimestampNS:  ss.Metadata.CreationTimestampNS,
+				StopTimestampNS:   ss.Metadata.DeletionTimestampNS,
+				Namespace:         ss.Metadata.Namespace,
+				Replicas:          ss.Status.Replicas,
+				ReadyReplicas:     ss.Status.ReadyReplicas,
+				CurrentReplicas:   ss.Status.CurrentReplicas,
+				UpdatedReplicas:   ss.Status.UpdatedReplicas,
+				AvailableReplicas: ss.Status.AvailableReplicas,
+				RequestedReplicas: ss.Spec.Replicas,
+				ServiceName:       ss.Spec.ServiceName,
+				OwnerReferences:   ss.Metadata.OwnerReferences,
+				Labels:            ss.Metadata.Labels,
+			},
+		},
+	}
+}
+
+func getResourceUpdateFromDaemonSet(ds *metadatapb.DaemonSet, uv int64) *metadatapb.ResourceUpdate {
+	return &metadatapb.ResourceUpdate{
+		UpdateVersion: uv,
+		Update: &metadatapb.ResourceUpdate_DaemonSetUpdate{
+			DaemonSetUpdate: &metadatapb.DaemonSetUpdate{
+				UID:                    ds.Metadata.UID,
+				Name:                   ds.Metadata.Name,
+				StartTimestampNS:       ds.Metadata.CreationTimestampNS,
+				StopTimestampNS:        ds.Metadata.DeletionTimestampNS,
+				Namespace:              ds.Metadata.Namespace,
+				CurrentNumberScheduled: ds.Status.CurrentNumberScheduled,
+				DesiredNumberScheduled: ds.Status.DesiredNumberScheduled,
+				NumberReady:            ds.Status.NumberReady,
+				NumberAvailable:        ds.Status.NumberAvailable,
+				NumberUnavailable:      ds.Status.NumberUnavailable,
+				OwnerReferences:        ds.Metadata.OwnerReferences,
+				Labels:                 ds.Metadata.Labels,
+			},
+		},
+	}
+}
+
+func getResourceUpdateFromJob(job *metadatapb.Job, uv int64) *metadatapb.ResourceUpdate {
+	return &metadatapb.ResourceUpdate{
+		UpdateVersion: uv,
+		Update: &metadatapb.ResourceUpdate_JobUpdate{
+			JobUpdate: &metadatapb.JobUpdate{
+				UID:              job.Metadata.UID,
+				Name:             job.Metadata.Name,
+				StartTimestampNS: job.Metadata.CreationTimestampNS,
+				StopTimestampNS:  job.Metadata.DeletionTimestampNS,
+				Namespace:        job.Metadata.Namespace,
+				Completions:      job.Spec.Completions,
+				Parallelism:      job.Spec.Parallelism,
+				Active:           job.Status.Active,
+				Succeeded:        job.Status.Succeeded,
+				Failed:           job.Status.Failed,
+				CompletionTimeNS: job.Status.CompletionTimeNS,
+				OwnerReferences:  job.Metadata.OwnerReferences,
+				Labels:           job.Metadata.Labels,
+			},
+		},
+	}
+}
+
+func getResourceUpdateFromCronJob(cj *metadatapb.CronJob, uv int64) *metadatapb.ResourceUpdate {
+	return &metadatapb.ResourceUpdate{
+		UpdateVersion: uv,
+		Update: &metadatapb.ResourceUpdate_CronJobUpdate{
+			CronJobUpdate: &metadatapb.CronJobUpdate{
+				UID:                cj.Metadata.UID,
+				Name:               cj.Metadata.Name,
+				StartTimestampNS:   cj.Metadata.CreationTimestampNS,
+				StopTimestampNS:    cj.Metadata.DeletionTimestampNS,
+				Namespace:          cj.Metadata.Namespace,
+				Schedule:           cj.Spec.Schedule,
+				Suspend:            cj.Spec.Suspend,
+				ActiveJobs:         int32(len(cj.Status.Active)),
+				LastScheduleTimeNS: cj.Status.LastScheduleTimeNS,
+				OwnerReferences:    cj.Metadata.OwnerReferences,
+				Labels:             cj.Metadata.Labels,
+			},
+		},
+	}
+}
+
+func getResourceUpdateFromIngress(ing *metadatapb.Ingress, uv int64) *metadatapb.ResourceUpdate {
+	var hosts []string
+	var serviceNames []string
+	seenServices := make(map[string]bool)
+	addService := func(b *metadatapb.IngressBackend) {
+		if b == nil || seenServices[b.ServiceName] {
+			return
+		}
+		seenServices[b.ServiceName] = true
+		serviceNames = append(serviceNames, b.ServiceName)
+	}
+
+	addService(ing.Spec.DefaultBackend)
+	for _, r := range ing.Spec.Rules {
+		if r.Host != "" {
+			hosts = append(hosts, r.Host)
+		}
+		for _, path := range r.Paths {
+			addService(path.Backend)
+		}
+	}
+
+	return &metadatapb.ResourceUpdate{
+		UpdateVersion: uv,
+		Update: &metadatapb.ResourceUpdate_IngressUpdate{
+			IngressUpdate: &metadatapb.IngressUpdate{
+				UID:              ing.Metadata.UID,
+				Name:             ing.Metadata.Name,
+				StartTimestampNS: ing.Metadata.CreationTimestampNS,
+				StopTimestampNS:  ing.Metadata.DeletionTimestampNS,
+				Namespace:        ing.Metadata.Namespace,
+				Hosts:            hosts,
+				ServiceNames:     serviceNames,
+				OwnerReferences:  ing.Metadata.OwnerReferences,
+				Labels:           ing.Metadata.Labels,
+			},
+		},
+	}
+}
+
+func getResourceUpdateFromOwnerObject(o *metadatapb.OwnerObject, uv int64) *metadatapb.ResourceUpdate {
+	return &metadatapb.ResourceUpdate{
+		UpdateVersion: uv,
+		Update: &metadatapb.ResourceUpdate_OwnerObjectUpdate{
+			OwnerObjectUpdate: &metadatapb.OwnerObjectUpdate{
+				UID:              o.Metadata.UID,
+				Name:             o.Metadata.Name,
+				StartTimestampNS: o.Metadata.CreationTimestampNS,
+				StopTimestampNS:  o.Metadata.DeletionTimestampNS,
+				Namespace:        o.Metadata.Namespace,
+				APIVersion:       o.APIVersion,
+				Kind:             o.Kind,
+				Labels:           o.Metadata.Labels,
+				OwnerReferences:  o.Metadata.OwnerReferences,
+			},
+		},
+	}
+}
+
 // Stop stops processing incoming k8s metadata updates.
 func (m *Handler) Stop() {
 	m.once.Do(func() {
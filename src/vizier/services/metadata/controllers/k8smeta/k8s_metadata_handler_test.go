@@ -28,6 +28,7 @@ import (
 	"github.com/nats-io/nats.go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/labels"
 
 	"px.dev/pixie/src/shared/k8s/metadatapb"
 	"px.dev/pixie/src/utils/testingutils"
@@ -399,7 +400,7 @@ func TestHandler_GetUpdatesForIP(t *testing.T) {
 	require.NoError(t, err)
 
 	updateCh := make(chan *k8smeta.K8sResourceMessage)
-	mdh := k8smeta.NewHandler(updateCh, mds, lps, nil)
+	mdh := k8smeta.NewHandler(updateCh, mds, lps, nil, nil)
 	defer mdh.Stop()
 	updates, err := mdh.GetUpdatesForIP("", 0, 0)
 	require.NoError(t, err)
@@ -430,6 +431,44 @@ func TestHandler_GetUpdatesForIP(t *testing.T) {
 	assert.Equal(t, nsUpdate, updates[0])
 }
 
+func TestHandler_GetUpdatesForIP_NamespaceScope(t *testing.T) {
+	mds := &InMemoryStore{
+		ResourceStoreByTopic: make(map[string]ResourceStore),
+		RVStore:              map[string]int64{k8smeta.KelvinUpdateTopic: 4},
+	}
+	lps := &testutils.InMemoryPodLabelStore{
+		Store: make(map[string]string),
+	}
+
+	namespaces := []string{"ns1", "ns2", "ns1"}
+	for i, ns := range namespaces {
+		uv := int64(i + 1)
+		err := mds.AddResourceUpdate(uv, &storepb.K8SResourceUpdate{
+			Update: &metadatapb.ResourceUpdate{
+				UpdateVersion: uv,
+				Update: &metadatapb.ResourceUpdate_ServiceUpdate{
+					ServiceUpdate: &metadatapb.ServiceUpdate{Name: "svc", Namespace: ns},
+				},
+			},
+		})
+		require.NoError(t, err)
+	}
+
+	scope, err := k8smeta.NewNamespaceScope([]string{"ns1"}, nil)
+	require.NoError(t, err)
+	mdh := k8smeta.NewHandler(make(chan *k8smeta.K8sResourceMessage), mds, lps, nil, scope)
+	defer mdh.Stop()
+
+	updates, err := mdh.GetUpdatesForIP("", 0, 0)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(updates))
+	assert.Equal(t, int64(1), updates[0].UpdateVersion)
+	assert.Equal(t, int64(0), updates[0].PrevUpdateVersion)
+	// The out of scope update is skipped, so the previous version must skip it too.
+	assert.Equal(t, int64(3), updates[1].UpdateVersion)
+	assert.Equal(t, int64(1), updates[1].PrevUpdateVersion)
+}
+
 func TestHandler_ProcessUpdates(t *testing.T) {
 	updateCh := make(chan *k8smeta.K8sResourceMessage)
 
@@ -448,7 +487,7 @@ func TestHandler_ProcessUpdates(t *testing.T) {
 	nc, natsCleanup := testingutils.MustStartTestNATS(t)
 	defer natsCleanup()
 
-	mdh := k8smeta.NewHandler(updateCh, mds, lps, nc)
+	mdh := k8smeta.NewHandler(updateCh, mds, lps, nc, nil)
 	defer mdh.Stop()
 
 	expectedNSMsg := &messagespb.VizierMessage{
@@ -551,7 +590,11 @@ func TestHandler_ProcessUpdates(t *testing.T) {
 
 	assert.Equal(t, int64(5), mds.RVStore[k8smeta.KelvinUpdateTopic])
 
-	// Full resource updates should be stored.
+	// Full resource updates should be stored with the time they were observed.
+	for _, uv := range []int64{4, 5} {
+		assert.NotZero(t, mds.FullResourceStore[uv].TimestampNS)
+		mds.FullResourceStore[uv].TimestampNS = 0
+	}
 	assert.Equal(t, &storepb.K8SResource{
 		Resource: &storepb.K8SResource_Namespace{
 			Namespace: &metadatapb.Namespace{
@@ -1088,6 +1131,65 @@ func TestPodUpdateProcessor_UpdatePodLabelStore(t *testing.T) {
 	assert.Empty(t, pods)
 }
 
+func TestUpdateObjectLabelStore(t *testing.T) {
+	ls := &testutils.InMemoryPodLabelStore{
+		Store: make(map[string]string),
+	}
+
+	podMetadata := &metadatapb.ObjectMetadata{
+		Name:        "pod1",
+		Namespace:   "ns",
+		Labels:      map[string]string{"app": "web", "env": "prod"},
+		Annotations: map[string]string{"owner": "team-a"},
+	}
+	pod := &storepb.K8SResource{
+		Resource: &storepb.K8SResource_Pod{
+			Pod: &metadatapb.Pod{
+				Metadata: podMetadata,
+				Status:   &metadatapb.PodStatus{Phase: metadatapb.RUNNING},
+			},
+		},
+	}
+	svc := &storepb.K8SResource{
+		Resource: &storepb.K8SResource_Service{
+			Service: &metadatapb.Service{
+				Metadata: &metadatapb.ObjectMetadata{
+					Name:      "svc1",
+					Namespace: "ns",
+					Labels:    map[string]string{"app": "web"},
+				},
+			},
+		},
+	}
+	require.NoError(t, k8smeta.UpdateObjectLabelStore("pods", pod, ls))
+	require.NoError(t, k8smeta.UpdateObjectLabelStore("services", svc, ls))
+	// Deleted objects are not stored.
+	require.NoError(t, k8smeta.UpdateObjectLabelStore("deployments", createDeploymentObject(), ls))
+
+	selector, err := labels.Parse("app in (web), env")
+	require.NoError(t, err)
+	pods, err := ls.FetchObjectsWithSelector("pods", "ns", selector)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(pods))
+	assert.Equal(t, "pod1", pods[0].Name)
+	assert.Equal(t, map[string]string{"owner": "team-a"}, pods[0].Annotations)
+
+	svcs, err := ls.FetchObjectsWithSelector("services", "", selector)
+	require.NoError(t, err)
+	assert.Empty(t, svcs)
+
+	deployments, err := ls.FetchObjectsWithSelector("deployments", "", labels.Everything())
+	require.NoError(t, err)
+	assert.Empty(t, deployments)
+
+	// Terminated pods are removed from the store.
+	pod.GetPod().Status.Phase = metadatapb.TERMINATED
+	require.NoError(t, k8smeta.UpdateObjectLabelStore("pods", pod, ls))
+	pods, err = ls.FetchObjectsWithSelector("pods", "ns", selector)
+	require.NoError(t, err)
+	assert.Empty(t, pods)
+}
+
 func TestNodeUpdateProcessor_SetDeleted(t *testing.T) {
 	// Construct pod object.
 	o := createNodeObject()
@@ -1112,10 +1214,17 @@ func TestNodeUpdateProcessor_ValidateUpdate(t *testing.T) {
 	assert.Equal(t, 0, len(state.NodeToIP))
 
 	o.GetNode().Metadata.DeletionTimestampNS = 0
+	o.GetNode().Metadata.Labels = map[string]string{"zone": "us-west1-a"}
 	resp = p.ValidateUpdate(o, state)
 	assert.True(t, resp)
 	assert.Equal(t, 1, len(state.NodeToIP))
 	assert.Equal(t, "127.0.0.1", state.NodeToIP["object_md"])
+	assert.Equal(t, map[string]string{"zone": "us-west1-a"}, state.NodeLabels["object_md"])
+
+	o.GetNode().Metadata.DeletionTimestampNS = 10
+	resp = p.ValidateUpdate(o, state)
+	assert.True(t, resp)
+	assert.Equal(t, 0, len(state.NodeLabels))
 }
 
 func TestNodeUpdateProcessor_GetStoredProtos(t *testing.T) {
@@ -1602,3 +1711,207 @@ func TestDeploymentUpdateProcessor_GetUpdatesToSend(t *testing.T) {
 	assert.Contains(t, updates[0].Topics, "127.0.0.1")
 	assert.Contains(t, updates[0].Topics, "127.0.0.2")
 }
+
+func TestWorkloadUpdateProcessors(t *testing.T) {
+	state := &k8smeta.ProcessorState{NodeToIP: map[string]string{
+		"node-1": "127.0.0.1",
+		"node-2": "127.0.0.2",
+	}}
+
+	tests := []struct {
+		name           string
+		processor      k8smeta.UpdateProcessor
+		pbText         string
+		msg            proto.Message
+		wrap           func(proto.Message) *storepb.K8SResource
+		getMetadata    func(*storepb.K8SResource) *metadatapb.ObjectMetadata
+		expectedUpdate *metadatapb.ResourceUpdate
+	}{
+		{
+			name:      "statefulset",
+			processor: k8smeta.NewWorkloadUpdateProcessor("statefulsets"),
+			pbText:    testutils.StatefulSetPb,
+			msg:       &metadatapb.StatefulSet{},
+			wrap: func(m proto.Message) *storepb.K8SResource {
+				return &storepb.K8SResource{Resource: &storepb.K8SResource_StatefulSet{StatefulSet: m.(*metadatapb.StatefulSet)}}
+			},
+			getMetadata: func(o *storepb.K8SResource) *metadatapb.ObjectMetadata { return o.GetStatefulSet().Metadata },
+			expectedUpdate: &metadatapb.ResourceUpdate{
+				UpdateVersion: 2,
+				Update: &metadatapb.ResourceUpdate_StatefulSetUpdate{
+					StatefulSetUpdate: &metadatapb.StatefulSetUpdate{
+						UID:               "ss_uid",
+						Name:              "statefulset_1",
+						StartTimestampNS:  4,
+						StopTimestampNS:   6,
+						Namespace:         "a_namespace",
+						Replicas:          3,
+						ReadyReplicas:     2,
+						CurrentReplicas:   3,
+						UpdatedReplicas:   1,
+						AvailableReplicas: 2,
+						RequestedReplicas: 3,
+						ServiceName:       "db",
+					},
+				},
+			},
+		},
+		{
+			name:      "daemonset",
+			processor: k8smeta.NewWorkloadUpdateProcessor("daemonsets"),
+			pbText:    testutils.DaemonSetPb,
+			msg:       &metadatapb.DaemonSet{},
+			wrap: func(m proto.Message) *storepb.K8SResource {
+				return &storepb.K8SResource{Resource: &storepb.K8SResource_DaemonSet{DaemonSet: m.(*metadatapb.DaemonSet)}}
+			},
+			getMetadata: func(o *storepb.K8SResource) *metadatapb.ObjectMetadata { return o.GetDaemonSet().Metadata },
+			expectedUpdate: &metadatapb.ResourceUpdate{
+				UpdateVersion: 2,
+				Update: &metadatapb.ResourceUpdate_DaemonSetUpdate{
+					DaemonSetUpdate: &metadatapb.DaemonSetUpdate{
+						UID:                    "ds_uid",
+						Name:                   "daemonset_1",
+						StartTimestampNS:       4,
+						StopTimestampNS:        6,
+						Namespace:              "a_namespace",
+						CurrentNumberScheduled: 3,
+						DesiredNumberScheduled: 3,
+						NumberReady:            2,
+						NumberAvailable:        2,
+						NumberUnavailable:      1,
+					},
+				},
+			},
+		},
+		{
+			name:      "job",
+			processor: k8smeta.NewWorkloadUpdateProcessor("jobs"),
+			pbText:    testutils.JobPb,
+			msg:       &metadatapb.Job{},
+			wrap: func(m proto.Message) *storepb.K8SResource {
+				return &storepb.K8SResource{Resource: &storepb.K8SResource_Job{Job: m.(*metadatapb.Job)}}
+			},
+			getMetadata: func(o *storepb.K8SResource) *metadatapb.ObjectMetadata { return o.GetJob().Metadata },
+			expectedUpdate: &metadatapb.ResourceUpdate{
+				UpdateVersion: 2,
+				Update: &metadatapb.ResourceUpdate_JobUpdate{
+					JobUpdate: &metadatapb.JobUpdate{
+						UID:              "job_uid",
+						Name:             "job_1",
+						StartTimestampNS: 4,
+						StopTimestampNS:  6,
+						Namespace:        "a_namespace",
+						Completions:      4,
+						Parallelism:      2,
+						Active:           2,
+						Succeeded:        1,
+						Failed:           1,
+						OwnerReferences: []*metadatapb.OwnerReference{
+							{Kind: "CronJob", Name: "cronjob_1", UID: "cj_uid"},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:      "cronjob",
+			processor: k8smeta.NewWorkloadUpdateProcessor("cronjobs"),
+			pbText:    testutils.CronJobPb,
+			msg:       &metadatapb.CronJob{},
+			wrap: func(m proto.Message) *storepb.K8SResource {
+				return &storepb.K8SResource{Resource: &storepb.K8SResource_CronJob{CronJob: m.(*metadatapb.CronJob)}}
+			},
+			getMetadata: func(o *storepb.K8SResource) *metadatapb.ObjectMetadata { return o.GetCronJob().Metadata },
+			expectedUpdate: &metadatapb.ResourceUpdate{
+				UpdateVersion: 2,
+				Update: &metadatapb.ResourceUpdate_CronJobUpdate{
+					CronJobUpdate: &metadatapb.CronJobUpdate{
+						UID:                "cj_uid",
+						Name:               "cronjob_1",
+						StartTimestampNS:   4,
+						StopTimestampNS:    6,
+						Namespace:          "a_namespace",
+						Schedule:           "*/5 * * * *",
+						ActiveJobs:         1,
+						LastScheduleTimeNS: 5,
+					},
+				},
+			},
+		},
+		{
+			name:      "ingress",
+			processor: k8smeta.NewWorkloadUpdateProcessor("ingresses"),
+			pbText:    testutils.IngressPb,
+			msg:       &metadatapb.Ingress{},
+			wrap: func(m proto.Message) *storepb.K8SResource {
+				return &storepb.K8SResource{Resource: &storepb.K8SResource_Ingress{Ingress: m.(*metadatapb.Ingress)}}
+			},
+			getMetadata: func(o *storepb.K8SResource) *metadatapb.ObjectMetadata { return o.GetIngress().Metadata },
+			expectedUpdate: &metadatapb.ResourceUpdate{
+				UpdateVersion: 2,
+				Update: &metadatapb.ResourceUpdate_IngressUpdate{
+					IngressUpdate: &metadatapb.IngressUpdate{
+						UID:              "ing_uid",
+						Name:             "ingress_1",
+						StartTimestampNS: 4,
+						StopTimestampNS:  6,
+						Namespace:        "a_namespace",
+						Hosts:            []string{"example.com"},
+						ServiceNames:     []string{"default", "api"},
+					},
+				},
+			},
+		},
+		{
+			name:      "owner object",
+			processor: k8smeta.NewWorkloadUpdateProcessor("ownerobjects"),
+			pbText:    testutils.OwnerObjectPb,
+			msg:       &metadatapb.OwnerObject{},
+			wrap: func(m proto.Message) *storepb.K8SResource {
+				return &storepb.K8SResource{Resource: &storepb.K8SResource_OwnerObject{OwnerObject: m.(*metadatapb.OwnerObject)}}
+			},
+			getMetadata: func(o *storepb.K8SResource) *metadatapb.ObjectMetadata { return o.GetOwnerObject().Metadata },
+			expectedUpdate: &metadatapb.ResourceUpdate{
+				UpdateVersion: 2,
+				Update: &metadatapb.ResourceUpdate_OwnerObjectUpdate{
+					OwnerObjectUpdate: &metadatapb.OwnerObjectUpdate{
+						UID:              "rollout_uid",
+						Name:             "rollout_1",
+						StartTimestampNS: 4,
+						StopTimestampNS:  6,
+						Namespace:        "a_namespace",
+						APIVersion:       "argoproj.io/v1alpha1",
+						Kind:             "Rollout",
+						Labels:           map[string]string{"app": "web"},
+					},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.NoError(t, proto.UnmarshalText(test.pbText, test.msg))
+			o := test.wrap(test.msg)
+			p := test.processor
+
+			assert.False(t, p.IsNodeScoped())
+			assert.True(t, p.ValidateUpdate(o, state))
+			assert.False(t, p.ValidateUpdate(createDeploymentObject(), state))
+			assert.Equal(t, []*storepb.K8SResource{o}, p.GetStoredProtos(o))
+
+			updates := p.GetUpdatesToSend([]*k8smeta.StoredUpdate{{Update: o, UpdateVersion: 2}}, state)
+			require.Equal(t, 1, len(updates))
+			assert.Equal(t, test.expectedUpdate, updates[0].Update)
+			assert.ElementsMatch(t, []string{k8smeta.KelvinUpdateTopic, "127.0.0.1", "127.0.0.2"}, updates[0].Topics)
+
+			// An existing deletion timestamp is preserved.
+			p.SetDeleted(o)
+			assert.Equal(t, int64(6), test.getMetadata(o).DeletionTimestampNS)
+
+			test.getMetadata(o).DeletionTimestampNS = 0
+			p.SetDeleted(o)
+			assert.NotEqual(t, int64(0), test.getMetadata(o).DeletionTimestampNS)
+		})
+	}
+}
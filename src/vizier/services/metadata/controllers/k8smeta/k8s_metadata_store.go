@@ -22,11 +22,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
 	"path"
 	"strconv"
 	"strings"
 
 	"github.com/gogo/protobuf/proto"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
 
 	"px.dev/pixie/src/vizier/services/metadata/storepb"
 	"px.dev/pixie/src/vizier/utils/datastore"
@@ -36,12 +39,19 @@ const (
 	fullResourceUpdatePrefix  = "/fullResourceUpdate"
 	topicResourceUpdatePrefix = "/resourceUpdate"
 	topicVersionPrefix        = "/topicVersion"
-	labelPodUpdatePrefix      = "/labelPodUpdate" // labelPodUpdatePrefix/<namespace>/<labelKey>/<podName> -> <labelValue>
-	podLabelUpdatePrefix      = "/podLabelUpdate" // podLabelUpdatePrefix/<namespace>/<podName> -> [<labelKeys>]
+	labelPodUpdatePrefix      = "/labelPodUpdate"   // labelPodUpdatePrefix/<namespace>/<labelKey>/<podName> -> <labelValue>
+	podLabelUpdatePrefix      = "/podLabelUpdate"   // podLabelUpdatePrefix/<namespace>/<podName> -> [<labelKeys>]
+	objectLabelsPrefix        = "/objectLabels"     // objectLabelsPrefix/<objectType>/<namespace>/<name> -> K8sObjectLabels
+	labelObjectIndexPrefix    = "/labelObjectIndex" // labelObjectIndexPrefix/<objectType>/<labelKey>/<namespace>/<name> -> <labelValue>
 	// The topic for partial resource updates, which are not specific to a particular node.
 	unscopedTopic = "unscoped"
 )
 
+// HistoryPrefixes are the prefixes of the keys which store the history of K8s objects. They are the
+// first keys to be evicted if the datastore grows too large, and end in the update version so that the
+// oldest updates are evicted first.
+var HistoryPrefixes = []string{fullResourceUpdatePrefix, topicResourceUpdatePrefix}
+
 // Datastore implements the Store interface on a given Datastore.
 type Datastore struct {
 	ds datastore.MultiGetterSetterDeleterCloser
@@ -79,6 +89,32 @@ func getNSPodUpdateKey(namespace string, podName string) string {
 	return path.Join(podLabelUpdatePrefix, namespace, podName)
 }
 
+// prefix/<objectType>/<namespace>/<name>. The namespace is empty for cluster scoped objects.
+func getObjectLabelsKey(objectType string, namespace string, name string) string {
+	return fmt.Sprintf("%s/%s/%s/%s", objectLabelsPrefix, objectType, namespace, name)
+}
+
+// prefix/<objectType>/[<namespace>/]
+func getObjectLabelsPrefixKey(objectType string, namespace string) string {
+	if namespace == "" {
+		return fmt.Sprintf("%s/%s/", objectLabelsPrefix, objectType)
+	}
+	return fmt.Sprintf("%s/%s/%s/", objectLabelsPrefix, objectType, namespace)
+}
+
+// prefix/<objectType>/<labelKey>/<namespace>/<name>. Label keys may contain a '/', so they are escaped.
+func getLabelObjectIndexKey(objectType string, labelKey string, namespace string, name string) string {
+	return fmt.Sprintf("%s%s/%s", getLabelObjectIndexPrefixKey(objectType, labelKey, ""), namespace, name)
+}
+
+// prefix/<objectType>/<labelKey>/[<namespace>/]
+func getLabelObjectIndexPrefixKey(objectType string, labelKey string, namespace string) string {
+	if namespace == "" {
+		return fmt.Sprintf("%s/%s/%s/", labelObjectIndexPrefix, objectType, url.PathEscape(labelKey))
+	}
+	return fmt.Sprintf("%s/%s/%s/%s/", labelObjectIndexPrefix, objectType, url.PathEscape(labelKey), namespace)
+}
+
 func labelPodUpdateKeyToPodName(updateKey string) string {
 	keys := strings.Split(updateKey, "/")
 	return keys[len(keys)-1]
@@ -359,3 +395,278 @@ func (m *Datastore) FetchPodsWithLabels(namespace string, labels map[string]stri
 
 	return result, nil
 }
+
+// getObjectLabels gets the stored labels and annotations of an object, or nil if there are none.
+func (m *Datastore) getObjectLabels(objectType string, namespace string, name string) (*storepb.K8SObjectLabels, error) {
+	val, err := m.ds.Get(getObjectLabelsKey(objectType, namespace, name))
+	if err != nil || val == nil {
+		return nil, err
+	}
+	obj := &storepb.K8SObjectLabels{}
+	if err := obj.Unmarshal(val); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// SetObjectLabels stores the labels and annotations of an object, and indexes the object by each of its
+// labels. Index entries for labels which the object no longer has are deleted.
+func (m *Datastore) SetObjectLabels(obj *storepb.K8SObjectLabels) error {
+	existing, err := m.getObjectLabels(obj.ObjectType, obj.Namespace, obj.Name)
+	if err != nil {
+		return err
+	}
+
+	for k, v := range obj.Labels {
+		err := m.ds.SetWithTTL(getLabelObjectIndexKey(obj.ObjectType, k, obj.Namespace, obj.Name), v, resourceUpdateTTL)
+		if err != nil {
+			return err
+		}
+	}
+
+	var keysToDelete []string
+	for k := range existing.GetLabels() {
+		if _, ok := obj.Labels[k]; ok {
+			continue
+		}
+		keysToDelete = append(keysToDelete, getLabelObjectIndexKey(obj.ObjectType, k, obj.Namespace, obj.Name))
+	}
+	if len(keysToDelete) > 0 {
+		if err := m.ds.DeleteAll(keysToDelete); err != nil {
+			return err
+		}
+	}
+
+	val, err := obj.Marshal()
+	if err != nil {
+		return err
+	}
+	return m.ds.SetWithTTL(getObjectLabelsKey(obj.ObjectType, obj.Namespace, obj.Name), string(val), resourceUpdateTTL)
+}
+
+// DeleteObjectLabels deletes the labels and annotations of an object, along with its label index entries.
+func (m *Datastore) DeleteObjectLabels(objectType string, namespace string, name string) error {
+	existing, err := m.getObjectLabels(objectType, namespace, name)
+	if err != nil {
+		return err
+	}
+
+	keysToDelete := []string{getObjectLabelsKey(objectType, namespace, name)}
+	for k := range existing.GetLabels() {
+		keysToDelete = append(keysToDelete, getLabelObjectIndexKey(objectType, k, namespace, name))
+	}
+	return m.ds.DeleteAll(keysToDelete)
+}
+
+// fetchObjectsWithLabel gets the namespace/name of the objects of the given type in the namespace which
+// satisfy the requirement, using the label index. The requirement must need the label to be present.
+func (m *Datastore) fetchObjectsWithLabel(objectType string, namespace string, req labels.Requirement) (map[string]bool, error) {
+	prefix := getLabelObjectIndexPrefixKey(objectType, req.Key(), namespace)
+	keys, vals, err := m.ds.GetWithPrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	objs := make(map[string]bool)
+	for i, k := range keys {
+		if req.Operator() != selection.Exists && !req.Values().Has(string(vals[i])) {
+			continue
+		}
+		if namespace == "" {
+			// The key ends with <namespace>/<name>.
+			objs[strings.TrimPrefix(k, prefix)] = true
+		} else {
+			objs[namespace+"/"+strings.TrimPrefix(k, prefix)] = true
+		}
+	}
+	return objs, nil
+}
+
+// FetchObjectsWithSelector gets the objects of the given type in the namespace whose labels match the
+// selector. Objects in all namespaces are fetched if the namespace is empty. The label index is used to
+// find the candidate objects when the selector requires any labels to be present, otherwise all of the
+// objects of the type are scanned.
+func (m *Datastore) FetchObjectsWithSelector(objectType string, namespace string, selector labels.Selector) ([]*storepb.K8SObjectLabels, error) {
+	reqs, selectable := selector.Requirements()
+	if !selectable {
+		return nil, nil
+	}
+
+	// Intersect the objects satisfying each of the requirements that can be answered from the index.
+	var candidates map[string]bool
+	for _, req := range reqs {
+		switch req.Operator() {
+		case selection.Equals, selection.DoubleEquals, selection.In, selection.Exists:
+		default:
+			continue
+		}
+		objs, err := m.fetchObjectsWithLabel(objectType, namespace, req)
+		if err != nil {
+			return nil, err
+		}
+		if candidates != nil {
+			for o := range candidates {
+				if !objs[o] {
+					delete(candidates, o)
+				}
+			}
+		} else {
+			candidates = objs
+		}
+		if len(candidates) == 0 {
+			return nil, nil
+		}
+	}
+
+	var vals [][]byte
+	if candidates == nil {
+		_, all, err := m.ds.GetWithPrefix(getObjectLabelsPrefixKey(objectType, namespace))
+		if err != nil {
+			return nil, err
+		}
+		vals = all
+	} else {
+		for o := range candidates {
+			val, err := m.ds.Get(fmt.Sprintf("%s/%s/%s", objectLabelsPrefix, objectType, o))
+			if err != nil {
+				return nil, err
+			}
+			if val != nil {
+				vals = append(vals, val)
+			}
+		}
+	}
+
+	// The remaining requirements, such as notin, are checked against the stored labels.
+	var objs []*storepb.K8SObjectLabels
+	for _, val := range vals {
+		obj := &storepb.K8SObjectLabels{}
+		if err := obj.Unmarshal(val); err != nil {
+			return nil, err
+		}
+		if selector.Matches(labels.Set(obj.Labels)) {
+			objs = append(objs, obj)
+		}
+	}
+	return objs, nil
+}
+
+// fetchResourceLog gets the entries of the full resource update log which match the filter,
+// ordered by update version.
+func (m *Datastore) fetchResourceLog(match func(*storepb.K8SResource) bool) ([]*storepb.K8SResourceHistoryEntry, error) {
+	keys, vals, err := m.ds.GetWithPrefix(fullResourceUpdatePrefix + "/")
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*storepb.K8SResourceHistoryEntry
+	for i, val := range vals {
+		resource := &storepb.K8SResource{}
+		if err := resource.Unmarshal(val); err != nil {
+			continue
+		}
+		if !match(resource) {
+			continue
+		}
+		version, err := strconv.ParseInt(path.Base(keys[i]), 10, 64)
+		if err != nil { // Malformed key, skip it.
+			continue
+		}
+		entries = append(entries, &storepb.K8SResourceHistoryEntry{
+			UpdateVersion: version,
+			TimestampNS:   resource.TimestampNS,
+			Resource:      resource,
+		})
+	}
+	return entries, nil
+}
+
+// fetchObjectLog gets every version of an object in the full resource update log. Containers are
+// not tracked as separate objects, since they are part of their pod.
+func (m *Datastore) fetchObjectLog(objectType string, namespace string, name string) ([]*storepb.K8SResourceHistoryEntry, error) {
+	return m.fetchResourceLog(func(r *storepb.K8SResource) bool {
+		t, md := resourceObjectKey(r)
+		return md != nil && t == objectType && md.Namespace == namespace && md.Name == name
+	})
+}
+
+// FetchResourceHistory gets the versions of an object observed between the start and end times,
+// ordered by update version. An end time of 0 is unbounded.
+func (m *Datastore) FetchResourceHistory(objectType string, namespace string, name string, startNS int64, endNS int64) ([]*storepb.K8SResourceHistoryEntry, error) {
+	entries, err := m.fetchObjectLog(objectType, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var inRange []*storepb.K8SResourceHistoryEntry
+	for _, entry := range entries {
+		if entry.TimestampNS < startNS || (endNS != 0 && entry.TimestampNS > endNS) {
+			continue
+		}
+		inRange = append(inRange, entry)
+	}
+	return inRange, nil
+}
+
+// FetchRestarts gets the versions at which an object was restarted between the start and end
+// times. An end time of 0 is unbounded.
+func (m *Datastore) FetchRestarts(objectType string, namespace string, name string, startNS int64, endNS int64) ([]*storepb.K8SResourceHistoryEntry, error) {
+	entries, err := m.fetchObjectLog(objectType, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// The last version before the start time is kept, since it is needed to tell whether the first
+	// version in the range is a restart.
+	first := 0
+	last := len(entries)
+	for i, entry := range entries {
+		if entry.TimestampNS < startNS {
+			first = i
+		}
+		if endNS != 0 && entry.TimestampNS > endNS {
+			last = i
+			break
+		}
+	}
+	if first >= last {
+		return nil, nil
+	}
+
+	var restarts []*storepb.K8SResourceHistoryEntry
+	for _, r := range FindRestarts(entries[first:last]) {
+		if r.TimestampNS >= startNS {
+			restarts = append(restarts, r)
+		}
+	}
+	return restarts, nil
+}
+
+// FetchPodByIP gets the latest version of the pod which owned the IP at the given time. If several
+// pods owned the IP, such as when the IP was reused before the old pod's deletion was observed, the
+// most recently created pod is returned. Returns nil if no pod owned the IP.
+func (m *Datastore) FetchPodByIP(ip string, timeNS int64) (*storepb.K8SResourceHistoryEntry, error) {
+	entries, err := m.fetchResourceLog(func(r *storepb.K8SResource) bool {
+		return r.GetPod().GetStatus().GetPodIP() == ip
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the latest version of each pod with the IP is considered.
+	latest := make(map[string]*storepb.K8SResourceHistoryEntry)
+	for _, entry := range entries {
+		latest[entry.Resource.GetPod().GetMetadata().GetUID()] = entry
+	}
+
+	var owner *storepb.K8SResourceHistoryEntry
+	for _, entry := range latest {
+		if !podOwnedIP(entry, timeNS) {
+			continue
+		}
+		if owner == nil || entry.Resource.GetPod().GetMetadata().GetCreationTimestampNS() > owner.Resource.GetPod().GetMetadata().GetCreationTimestampNS() {
+			owner = entry
+		}
+	}
+	return owner, nil
+}
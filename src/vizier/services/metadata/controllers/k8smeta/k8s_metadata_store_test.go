@@ -33,6 +33,7 @@ import (
 	"github.com/gogo/protobuf/proto"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/labels"
 
 	"px.dev/pixie/src/shared/k8s/metadatapb"
 	"px.dev/pixie/src/vizier/services/metadata/storepb"
@@ -446,3 +447,280 @@ func TestDataStore_FetchPodsWithLabels(t *testing.T) {
 	require.NoError(t, err)
 	assert.ElementsMatch(t, []string{"pod1", "pod2", "pod3"}, pods)
 }
+
+func TestDatastore_FetchObjectsWithSelector(t *testing.T) {
+	_, mds, cleanup := setupMDSTest(t)
+	defer cleanup()
+
+	objs := []*storepb.K8SObjectLabels{
+		{ObjectType: "pods", Namespace: "ns1", Name: "web", Labels: map[string]string{"app": "web", "env": "prod"}, Annotations: map[string]string{"owner": "team-a"}},
+		{ObjectType: "pods", Namespace: "ns1", Name: "db", Labels: map[string]string{"app": "db", "env": "staging"}},
+		{ObjectType: "pods", Namespace: "ns12", Name: "cache", Labels: map[string]string{"app": "cache", "env": "prod", "tier": "backend"}},
+		{ObjectType: "services", Namespace: "ns1", Name: "web", Labels: map[string]string{"app": "web"}},
+		{ObjectType: "namespaces", Name: "ns1", Labels: map[string]string{"team": "a"}},
+	}
+	for _, o := range objs {
+		require.NoError(t, mds.SetObjectLabels(o))
+	}
+
+	tests := []struct {
+		name       string
+		objectType string
+		namespace  string
+		selector   string
+		expected   []string
+	}{
+		{
+			name:       "equality",
+			objectType: "pods",
+			namespace:  "ns1",
+			selector:   "env=prod",
+			expected:   []string{"web"},
+		},
+		{
+			name:       "in across namespaces",
+			objectType: "pods",
+			selector:   "env in (prod, staging)",
+			expected:   []string{"web", "db", "cache"},
+		},
+		{
+			name:       "notin",
+			objectType: "pods",
+			selector:   "app notin (web, db)",
+			expected:   []string{"cache"},
+		},
+		{
+			name:       "exists",
+			objectType: "pods",
+			selector:   "tier",
+			expected:   []string{"cache"},
+		},
+		{
+			name:       "does not exist",
+			objectType: "pods",
+			namespace:  "ns12",
+			selector:   "!tier",
+			expected:   []string{},
+		},
+		{
+			name:       "empty selector",
+			objectType: "services",
+			selector:   "",
+			expected:   []string{"web"},
+		},
+		{
+			name:       "cluster scoped",
+			objectType: "namespaces",
+			selector:   "team=a",
+			expected:   []string{"ns1"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			selector, err := labels.Parse(test.selector)
+			require.NoError(t, err)
+			matches, err := mds.FetchObjectsWithSelector(test.objectType, test.namespace, selector)
+			require.NoError(t, err)
+			names := []string{}
+			for _, m := range matches {
+				names = append(names, m.Name)
+			}
+			assert.ElementsMatch(t, test.expected, names)
+		})
+	}
+
+	// Annotations are stored alongside the labels.
+	selector, err := labels.Parse("app=web")
+	require.NoError(t, err)
+	matches, err := mds.FetchObjectsWithSelector("pods", "ns1", selector)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(matches))
+	assert.Equal(t, map[string]string{"owner": "team-a"}, matches[0].Annotations)
+
+	require.NoError(t, mds.DeleteObjectLabels("pods", "ns1", "web"))
+	matches, err = mds.FetchObjectsWithSelector("pods", "ns1", selector)
+	require.NoError(t, err)
+	assert.Empty(t, matches)
+}
+
+func TestDatastore_SetObjectLabels_Index(t *testing.T) {
+	db, mds, cleanup := setupMDSTest(t)
+	defer cleanup()
+
+	require.NoError(t, mds.SetObjectLabels(&storepb.K8SObjectLabels{
+		ObjectType: "deployments",
+		Namespace:  "ns1",
+		Name:       "web",
+		Labels:     map[string]string{"app.kubernetes.io/name": "web", "env": "prod"},
+	}))
+
+	keys, _, err := db.GetWithPrefix(labelObjectIndexPrefix)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		"/labelObjectIndex/deployments/app.kubernetes.io%2Fname/ns1/web",
+		"/labelObjectIndex/deployments/env/ns1/web",
+	}, keys)
+
+	selector, err := labels.Parse("app.kubernetes.io/name=web")
+	require.NoError(t, err)
+	matches, err := mds.FetchObjectsWithSelector("deployments", "", selector)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(matches))
+	assert.Equal(t, "web", matches[0].Name)
+
+	// Relabeling the object removes the index entries of the labels it no longer has.
+	require.NoError(t, mds.SetObjectLabels(&storepb.K8SObjectLabels{
+		ObjectType: "deployments",
+		Namespace:  "ns1",
+		Name:       "web",
+		Labels:     map[string]string{"env": "staging"},
+	}))
+	keys, vals, err := db.GetWithPrefix(labelObjectIndexPrefix)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/labelObjectIndex/deployments/env/ns1/web"}, keys)
+	assert.Equal(t, "staging", string(vals[0]))
+
+	matches, err = mds.FetchObjectsWithSelector("deployments", "", selector)
+	require.NoError(t, err)
+	assert.Empty(t, matches)
+
+	require.NoError(t, mds.DeleteObjectLabels("deployments", "ns1", "web"))
+	keys, _, err = db.GetWithPrefix(labelObjectIndexPrefix)
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+}
+
+func historyPod(uid string, ip string, phase metadatapb.PodPhase, createdNS int64, deletedNS int64) *storepb.K8SResource {
+	return &storepb.K8SResource{
+		Resource: &storepb.K8SResource_Pod{
+			Pod: &metadatapb.Pod{
+				Metadata: &metadatapb.ObjectMetadata{
+					Name:                "pod-" + uid,
+					Namespace:           "ns",
+					UID:                 uid,
+					CreationTimestampNS: createdNS,
+					DeletionTimestampNS: deletedNS,
+				},
+				Status: &metadatapb.PodStatus{Phase: phase, PodIP: ip},
+			},
+		},
+	}
+}
+
+// addLogEntry adds a version of a resource, observed at the given time, to the full resource
+// update log.
+func addLogEntry(t *testing.T, mds *Datastore, uv int64, timeNS int64, r *storepb.K8SResource) {
+	r.TimestampNS = timeNS
+	require.NoError(t, mds.AddFullResourceUpdate(uv, r))
+}
+
+func TestDatastore_FetchResourceHistory(t *testing.T) {
+	_, mds, cleanup := setupMDSTest(t)
+	defer cleanup()
+
+	for i := int64(1); i <= 3; i++ {
+		addLogEntry(t, mds, i, i*10, historyPod("a", "", metadatapb.RUNNING, 0, 0))
+	}
+	addLogEntry(t, mds, 4, 40, historyPod("b", "", metadatapb.RUNNING, 0, 0))
+	// Containers aren't tracked as separate objects.
+	addLogEntry(t, mds, 5, 50, &storepb.K8SResource{
+		Resource: &storepb.K8SResource_Container{Container: &metadatapb.ContainerUpdate{Name: "c"}},
+	})
+
+	entries, err := mds.FetchResourceHistory("pods", "ns", "pod-a", 0, 0)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(entries))
+	for i, e := range entries {
+		assert.Equal(t, int64(i+1), e.UpdateVersion)
+		assert.Equal(t, int64(i+1)*10, e.TimestampNS)
+	}
+
+	entries, err = mds.FetchResourceHistory("pods", "ns", "pod-a", 15, 30)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(entries))
+	assert.Equal(t, int64(2), entries[0].UpdateVersion)
+	assert.Equal(t, int64(3), entries[1].UpdateVersion)
+
+	entries, err = mds.FetchResourceHistory("services", "ns", "pod-a", 0, 0)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestDatastore_FetchRestarts(t *testing.T) {
+	_, mds, cleanup := setupMDSTest(t)
+	defer cleanup()
+
+	// The pod restarts at versions 2 and 4.
+	for i, restartCount := range []int64{0, 1, 1, 2} {
+		pod := historyPod("a", "", metadatapb.RUNNING, 0, 0)
+		pod.GetPod().Status.RestartCount = restartCount
+		addLogEntry(t, mds, int64(i+1), int64(i+1)*10, pod)
+	}
+
+	tests := []struct {
+		name             string
+		startNS          int64
+		endNS            int64
+		expectedVersions []int64
+	}{
+		{name: "all", expectedVersions: []int64{2, 4}},
+		{name: "restart at start time", startNS: 20, expectedVersions: []int64{2, 4}},
+		{name: "after first restart", startNS: 25, expectedVersions: []int64{4}},
+		{name: "before second restart", endNS: 35, expectedVersions: []int64{2}},
+		{name: "no restarts", startNS: 25, endNS: 35},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			restarts, err := mds.FetchRestarts("pods", "ns", "pod-a", test.startNS, test.endNS)
+			require.NoError(t, err)
+			var versions []int64
+			for _, r := range restarts {
+				versions = append(versions, r.UpdateVersion)
+			}
+			assert.Equal(t, test.expectedVersions, versions)
+		})
+	}
+}
+
+func TestDatastore_FetchPodByIP(t *testing.T) {
+	_, mds, cleanup := setupMDSTest(t)
+	defer cleanup()
+
+	// Pod a owned the IP from 10 until it was deleted at 20, and pod b owned it from 30 until it
+	// finished running at 50.
+	addLogEntry(t, mds, 1, 10, historyPod("a", "10.0.0.1", metadatapb.RUNNING, 10, 0))
+	addLogEntry(t, mds, 2, 20, historyPod("a", "10.0.0.1", metadatapb.RUNNING, 10, 20))
+	addLogEntry(t, mds, 3, 30, historyPod("b", "10.0.0.1", metadatapb.RUNNING, 30, 0))
+	addLogEntry(t, mds, 4, 50, historyPod("b", "10.0.0.1", metadatapb.SUCCEEDED, 30, 0))
+	addLogEntry(t, mds, 5, 50, historyPod("c", "10.0.0.2", metadatapb.RUNNING, 30, 0))
+
+	tests := []struct {
+		name        string
+		ip          string
+		timeNS      int64
+		expectedUID string
+	}{
+		{name: "before any pod", ip: "10.0.0.1", timeNS: 5},
+		{name: "first pod", ip: "10.0.0.1", timeNS: 15, expectedUID: "a"},
+		{name: "between pods", ip: "10.0.0.1", timeNS: 25},
+		{name: "second pod", ip: "10.0.0.1", timeNS: 40, expectedUID: "b"},
+		{name: "after second pod finished", ip: "10.0.0.1", timeNS: 60},
+		{name: "other IP", ip: "10.0.0.2", timeNS: 60, expectedUID: "c"},
+		{name: "prefix of other IP", ip: "10.0.0", timeNS: 60},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entry, err := mds.FetchPodByIP(test.ip, test.timeNS)
+			require.NoError(t, err)
+			if test.expectedUID == "" {
+				assert.Nil(t, entry)
+				return
+			}
+			require.NotNil(t, entry)
+			assert.Equal(t, test.expectedUID, entry.Resource.GetPod().Metadata.UID)
+		})
+	}
+}
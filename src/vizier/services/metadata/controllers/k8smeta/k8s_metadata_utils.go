@@ -19,13 +19,12 @@
 package k8smeta
 
 import (
-	"time"
-
 	apps "k8s.io/api/apps/v1"
+	batch "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/informers"
-	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 
 	"px.dev/pixie/src/shared/k8s"
@@ -46,23 +45,30 @@ func (i *informerWatcher) send(msg *K8sResourceMessage, et watch.EventType) {
 	i.ch <- msg
 }
 
-// StartWatcher starts a watcher.
-func (i *informerWatcher) StartWatcher(quitCh chan struct{}) {
+// StartWatcher starts a watcher. Objects outside of the given namespace scope are dropped.
+func (i *informerWatcher) StartWatcher(quitCh chan struct{}, scope *NamespaceScope) {
+	convert := func(obj interface{}) *K8sResourceMessage {
+		msg := i.convert(obj)
+		if msg == nil || !scope.IncludesResource(msg.Object) {
+			return nil
+		}
+		return msg
+	}
 	i.inf.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
-			msg := i.convert(obj)
+			msg := convert(obj)
 			if msg != nil {
 				i.send(msg, watch.Added)
 			}
 		},
 		UpdateFunc: func(oldObj, newObj interface{}) {
-			msg := i.convert(newObj)
+			msg := convert(newObj)
 			if msg != nil {
 				i.send(msg, watch.Modified)
 			}
 		},
 		DeleteFunc: func(obj interface{}) {
-			msg := i.convert(obj)
+			msg := convert(obj)
 			if msg != nil {
 				i.send(msg, watch.Deleted)
 			}
@@ -71,8 +77,7 @@ func (i *informerWatcher) StartWatcher(quitCh chan struct{}) {
 	i.inf.Run(quitCh)
 }
 
-func podWatcher(resource string, ch chan *K8sResourceMessage, clientset *kubernetes.Clientset) *informerWatcher {
-	factory := informers.NewSharedInformerFactory(clientset, 12*time.Hour)
+func podWatcher(resource string, ch chan *K8sResourceMessage, factory informers.SharedInformerFactory) *informerWatcher {
 	return &informerWatcher{
 		convert: podConverter,
 		objType: resource,
@@ -81,8 +86,7 @@ func podWatcher(resource string, ch chan *K8sResourceMessage, clientset *kuberne
 	}
 }
 
-func serviceWatcher(resource string, ch chan *K8sResourceMessage, clientset *kubernetes.Clientset) *informerWatcher {
-	factory := informers.NewSharedInformerFactory(clientset, 12*time.Hour)
+func serviceWatcher(resource string, ch chan *K8sResourceMessage, factory informers.SharedInformerFactory) *informerWatcher {
 	return &informerWatcher{
 		convert: serviceConverter,
 		objType: resource,
@@ -91,8 +95,7 @@ func serviceWatcher(resource string, ch chan *K8sResourceMessage, clientset *kub
 	}
 }
 
-func namespaceWatcher(resource string, ch chan *K8sResourceMessage, clientset *kubernetes.Clientset) *informerWatcher {
-	factory := informers.NewSharedInformerFactory(clientset, 12*time.Hour)
+func namespaceWatcher(resource string, ch chan *K8sResourceMessage, factory informers.SharedInformerFactory) *informerWatcher {
 	return &informerWatcher{
 		convert: namespaceConverter,
 		objType: resource,
@@ -101,8 +104,7 @@ func namespaceWatcher(resource string, ch chan *K8sResourceMessage, clientset *k
 	}
 }
 
-func endpointsWatcher(resource string, ch chan *K8sResourceMessage, clientset *kubernetes.Clientset) *informerWatcher {
-	factory := informers.NewSharedInformerFactory(clientset, 12*time.Hour)
+func endpointsWatcher(resource string, ch chan *K8sResourceMessage, factory informers.SharedInformerFactory) *informerWatcher {
 	return &informerWatcher{
 		convert: endpointsConverter,
 		objType: resource,
@@ -111,8 +113,7 @@ func endpointsWatcher(resource string, ch chan *K8sResourceMessage, clientset *k
 	}
 }
 
-func nodeWatcher(resource string, ch chan *K8sResourceMessage, clientset *kubernetes.Clientset) *informerWatcher {
-	factory := informers.NewSharedInformerFactory(clientset, 12*time.Hour)
+func nodeWatcher(resource string, ch chan *K8sResourceMessage, factory informers.SharedInformerFactory) *informerWatcher {
 	return &informerWatcher{
 		convert: nodeConverter,
 		objType: resource,
@@ -121,8 +122,7 @@ func nodeWatcher(resource string, ch chan *K8sResourceMessage, clientset *kubern
 	}
 }
 
-func replicaSetWatcher(resource string, ch chan *K8sResourceMessage, clientset *kubernetes.Clientset) *informerWatcher {
-	factory := informers.NewSharedInformerFactory(clientset, 12*time.Hour)
+func replicaSetWatcher(resource string, ch chan *K8sResourceMessage, factory informers.SharedInformerFactory) *informerWatcher {
 	return &informerWatcher{
 		convert: replicaSetConverter,
 		objType: resource,
@@ -131,8 +131,7 @@ func replicaSetWatcher(resource string, ch chan *K8sResourceMessage, clientset *
 	}
 }
 
-func deploymentWatcher(resource string, ch chan *K8sResourceMessage, clientset *kubernetes.Clientset) *informerWatcher {
-	factory := informers.NewSharedInformerFactory(clientset, 12*time.Hour)
+func deploymentWatcher(resource string, ch chan *K8sResourceMessage, factory informers.SharedInformerFactory) *informerWatcher {
 	return &informerWatcher{
 		convert: deploymentConverter,
 		objType: resource,
@@ -141,6 +140,51 @@ func deploymentWatcher(resource string, ch chan *K8sResourceMessage, clientset *
 	}
 }
 
+func statefulSetWatcher(resource string, ch chan *K8sResourceMessage, factory informers.SharedInformerFactory) *informerWatcher {
+	return &informerWatcher{
+		convert: statefulSetConverter,
+		objType: resource,
+		ch:      ch,
+		inf:     factory.Apps().V1().StatefulSets().Informer(),
+	}
+}
+
+func daemonSetWatcher(resource string, ch chan *K8sResourceMessage, factory informers.SharedInformerFactory) *informerWatcher {
+	return &informerWatcher{
+		convert: daemonSetConverter,
+		objType: resource,
+		ch:      ch,
+		inf:     factory.Apps().V1().DaemonSets().Informer(),
+	}
+}
+
+func jobWatcher(resource string, ch chan *K8sResourceMessage, factory informers.SharedInformerFactory) *informerWatcher {
+	return &informerWatcher{
+		convert: jobConverter,
+		objType: resource,
+		ch:      ch,
+		inf:     factory.Batch().V1().Jobs().Informer(),
+	}
+}
+
+func cronJobWatcher(resource string, ch chan *K8sResourceMessage, factory informers.SharedInformerFactory) *informerWatcher {
+	return &informerWatcher{
+		convert: cronJobConverter,
+		objType: resource,
+		ch:      ch,
+		inf:     factory.Batch().V1().CronJobs().Informer(),
+	}
+}
+
+func ingressWatcher(resource string, ch chan *K8sResourceMessage, factory informers.SharedInformerFactory) *informerWatcher {
+	return &informerWatcher{
+		convert: ingressConverter,
+		objType: resource,
+		ch:      ch,
+		inf:     factory.Networking().V1().Ingresses().Informer(),
+	}
+}
+
 func podConverter(obj interface{}) *K8sResourceMessage {
 	o, ok := obj.(*v1.Pod)
 	if !ok {
@@ -245,3 +289,78 @@ func deploymentConverter(obj interface{}) *K8sResourceMessage {
 		},
 	}
 }
+
+func statefulSetConverter(obj interface{}) *K8sResourceMessage {
+	o, ok := obj.(*apps.StatefulSet)
+	if !ok {
+		return nil
+	}
+
+	return &K8sResourceMessage{
+		Object: &storepb.K8SResource{
+			Resource: &storepb.K8SResource_StatefulSet{
+				StatefulSet: k8s.StatefulSetToProto(o),
+			},
+		},
+	}
+}
+
+func daemonSetConverter(obj interface{}) *K8sResourceMessage {
+	o, ok := obj.(*apps.DaemonSet)
+	if !ok {
+		return nil
+	}
+
+	return &K8sResourceMessage{
+		Object: &storepb.K8SResource{
+			Resource: &storepb.K8SResource_DaemonSet{
+				DaemonSet: k8s.DaemonSetToProto(o),
+			},
+		},
+	}
+}
+
+func jobConverter(obj interface{}) *K8sResourceMessage {
+	o, ok := obj.(*batch.Job)
+	if !ok {
+		return nil
+	}
+
+	return &K8sResourceMessage{
+		Object: &storepb.K8SResource{
+			Resource: &storepb.K8SResource_Job{
+				Job: k8s.JobToProto(o),
+			},
+		},
+	}
+}
+
+func cronJobConverter(obj interface{}) *K8sResourceMessage {
+	o, ok := obj.(*batch.CronJob)
+	if !ok {
+		return nil
+	}
+
+	return &K8sResourceMessage{
+		Object: &storepb.K8SResource{
+			Resource: &storepb.K8SResource_CronJob{
+				CronJob: k8s.CronJobToProto(o),
+			},
+		},
+	}
+}
+
+func ingressConverter(obj interface{}) *K8sResourceMessage {
+	o, ok := obj.(*networking.Ingress)
+	if !ok {
+		return nil
+	}
+
+	return &K8sResourceMessage{
+		Object: &storepb.K8SResource{
+			Resource: &storepb.K8SResource_Ingress{
+				Ingress: k8s.IngressToProto(o),
+			},
+		},
+	}
+}
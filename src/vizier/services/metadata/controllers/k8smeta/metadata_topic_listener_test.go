@@ -26,6 +26,7 @@ import (
 	"github.com/nats-io/nats.go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/labels"
 
 	"px.dev/pixie/src/shared/k8s/metadatapb"
 	"px.dev/pixie/src/vizier/messages/messagespb"
@@ -95,6 +96,18 @@ func (s *FakeStore) FetchPodsWithLabels(namespace string, labels map[string]stri
 	return nil, nil
 }
 
+func (s *FakeStore) SetObjectLabels(obj *storepb.K8SObjectLabels) error {
+	return nil
+}
+
+func (s *FakeStore) DeleteObjectLabels(objectType string, namespace string, name string) error {
+	return nil
+}
+
+func (s *FakeStore) FetchObjectsWithSelector(objectType string, namespace string, selector labels.Selector) ([]*storepb.K8SObjectLabels, error) {
+	return nil, nil
+}
+
 func TestMetadataTopicListener_GetUpdatesInBatches(t *testing.T) {
 	tests := []struct {
 		name               string
@@ -132,7 +145,7 @@ func TestMetadataTopicListener_GetUpdatesInBatches(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			mds := &FakeStore{}
 			updateCh := make(chan *K8sResourceMessage)
-			mdh := NewHandler(updateCh, mds, mds, nil)
+			mdh := NewHandler(updateCh, mds, mds, nil, nil)
 			mdTL, err := NewMetadataTopicListener(mdh, func(topic string, b []byte) error {
 				return nil
 			})
@@ -172,7 +185,7 @@ func TestMetadataTopicListener_GetUpdatesInBatches(t *testing.T) {
 func TestMetadataTopicListener_ProcessAgentMessage(t *testing.T) {
 	mds := &FakeStore{}
 	updateCh := make(chan *K8sResourceMessage)
-	mdh := NewHandler(updateCh, mds, mds, nil)
+	mdh := NewHandler(updateCh, mds, mds, nil, nil)
 
 	sentUpdates := make([]*messagespb.VizierMessage, 0)
 	mdTL, err := NewMetadataTopicListener(mdh, func(topic string, b []byte) error {
@@ -0,0 +1,239 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package k8smeta
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+
+	"px.dev/pixie/src/shared/k8s/metadatapb"
+	"px.dev/pixie/src/vizier/services/metadata/storepb"
+)
+
+// NamespaceScope limits the namespaces whose objects are observed by Vizier. Cluster-scoped objects,
+// such as nodes, are always in scope. A nil NamespaceScope includes every namespace.
+type NamespaceScope struct {
+	// If non-empty, only these namespaces are in scope.
+	allowed map[string]bool
+	// These namespaces are never in scope.
+	denied map[string]bool
+}
+
+// NewNamespaceScope creates a NamespaceScope from an allowlist and a denylist of namespaces. An
+// empty allowlist allows all namespaces which are not denied.
+func NewNamespaceScope(allowed []string, denied []string) (*NamespaceScope, error) {
+	s := &NamespaceScope{
+		allowed: make(map[string]bool),
+		denied:  make(map[string]bool),
+	}
+	for _, ns := range allowed {
+		if ns == "" {
+			return nil, fmt.Errorf("allowed namespaces must not be empty")
+		}
+		s.allowed[ns] = true
+	}
+	for _, ns := range denied {
+		if ns == "" {
+			return nil, fmt.Errorf("denied namespaces must not be empty")
+		}
+		if s.allowed[ns] {
+			return nil, fmt.Errorf("namespace '%s' is both allowed and denied", ns)
+		}
+		s.denied[ns] = true
+	}
+	return s, nil
+}
+
+// Allowed returns the namespaces in the allowlist, in sorted order.
+func (s *NamespaceScope) Allowed() []string {
+	if s == nil {
+		return nil
+	}
+	return sortedKeys(s.allowed)
+}
+
+// Denied returns the namespaces in the denylist, in sorted order.
+func (s *NamespaceScope) Denied() []string {
+	if s == nil {
+		return nil
+	}
+	return sortedKeys(s.denied)
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// watchedNamespaces returns the namespaces to create informers for. All namespaces are watched by a
+// single informer, unless there is an allowlist.
+func (s *NamespaceScope) watchedNamespaces() []string {
+	if s == nil || len(s.allowed) == 0 {
+		return []string{metav1.NamespaceAll}
+	}
+	return s.Allowed()
+}
+
+// tweakListOptions excludes the objects in denied namespaces in the API server, so that they are never
+// sent to Vizier.
+func (s *NamespaceScope) tweakListOptions(opts *metav1.ListOptions) {
+	if s == nil || len(s.allowed) != 0 || len(s.denied) == 0 {
+		return
+	}
+	var selectors []fields.Selector
+	if opts.FieldSelector != "" {
+		selector, err := fields.ParseSelector(opts.FieldSelector)
+		if err == nil {
+			selectors = append(selectors, selector)
+		}
+	}
+	for _, ns := range s.Denied() {
+		selectors = append(selectors, fields.OneTermNotEqualSelector("metadata.namespace", ns))
+	}
+	opts.FieldSelector = fields.AndSelectors(selectors...).String()
+}
+
+// InformerFactories creates an informer factory for each of the namespaces watched for namespaced
+// resources. The informers only list the objects in scope, so Vizier needs no access to the objects
+// in other namespaces when there is an allowlist.
+func (s *NamespaceScope) InformerFactories(clientset kubernetes.Interface, resync time.Duration) []informers.SharedInformerFactory {
+	var factories []informers.SharedInformerFactory
+	for _, ns := range s.watchedNamespaces() {
+		factories = append(factories, informers.NewSharedInformerFactoryWithOptions(clientset, resync,
+			informers.WithNamespace(ns), informers.WithTweakListOptions(s.tweakListOptions)))
+	}
+	return factories
+}
+
+// DynamicInformerFactories is the same as InformerFactories, for dynamic informers.
+func (s *NamespaceScope) DynamicInformerFactories(client dynamic.Interface, resync time.Duration) []dynamicinformer.DynamicSharedInformerFactory {
+	var factories []dynamicinformer.DynamicSharedInformerFactory
+	for _, ns := range s.watchedNamespaces() {
+		factories = append(factories, dynamicinformer.NewFilteredDynamicSharedInformerFactory(client, resync, ns, s.tweakListOptions))
+	}
+	return factories
+}
+
+// Includes returns whether the given namespace is in scope.
+func (s *NamespaceScope) Includes(namespace string) bool {
+	if s == nil {
+		return true
+	}
+	if s.denied[namespace] {
+		return false
+	}
+	return len(s.allowed) == 0 || s.allowed[namespace]
+}
+
+// IncludesResource returns whether the given K8s resource is in scope.
+func (s *NamespaceScope) IncludesResource(r *storepb.K8SResource) bool {
+	if s == nil {
+		return true
+	}
+
+	var md *metadatapb.ObjectMetadata
+	switch r.Resource.(type) {
+	case *storepb.K8SResource_Node:
+		return true
+	case *storepb.K8SResource_Namespace:
+		return s.Includes(r.GetNamespace().GetMetadata().GetName())
+	case *storepb.K8SResource_Pod:
+		md = r.GetPod().GetMetadata()
+	case *storepb.K8SResource_Service:
+		md = r.GetService().GetMetadata()
+	case *storepb.K8SResource_Endpoints:
+		md = r.GetEndpoints().GetMetadata()
+	case *storepb.K8SResource_ReplicaSet:
+		md = r.GetReplicaSet().GetMetadata()
+	case *storepb.K8SResource_Deployment:
+		md = r.GetDeployment().GetMetadata()
+	case *storepb.K8SResource_StatefulSet:
+		md = r.GetStatefulSet().GetMetadata()
+	case *storepb.K8SResource_DaemonSet:
+		md = r.GetDaemonSet().GetMetadata()
+	case *storepb.K8SResource_Job:
+		md = r.GetJob().GetMetadata()
+	case *storepb.K8SResource_CronJob:
+		md = r.GetCronJob().GetMetadata()
+	case *storepb.K8SResource_Ingress:
+		md = r.GetIngress().GetMetadata()
+	case *storepb.K8SResource_OwnerObject:
+		md = r.GetOwnerObject().GetMetadata()
+	case *storepb.K8SResource_Container:
+		return s.Includes(r.GetContainer().GetNamespace())
+	}
+	// Custom owner objects may be cluster-scoped.
+	if md == nil || md.Namespace == "" {
+		return true
+	}
+	return s.Includes(md.Namespace)
+}
+
+// IncludesUpdate returns whether the given resource update, as sent to the agents, is in scope.
+func (s *NamespaceScope) IncludesUpdate(u *metadatapb.ResourceUpdate) bool {
+	if s == nil {
+		return true
+	}
+
+	var ns string
+	switch u.Update.(type) {
+	case *metadatapb.ResourceUpdate_NodeUpdate:
+		return true
+	case *metadatapb.ResourceUpdate_NamespaceUpdate:
+		return s.Includes(u.GetNamespaceUpdate().Name)
+	case *metadatapb.ResourceUpdate_PodUpdate:
+		ns = u.GetPodUpdate().Namespace
+	case *metadatapb.ResourceUpdate_ContainerUpdate:
+		ns = u.GetContainerUpdate().Namespace
+	case *metadatapb.ResourceUpdate_ServiceUpdate:
+		ns = u.GetServiceUpdate().Namespace
+	case *metadatapb.ResourceUpdate_ReplicaSetUpdate:
+		ns = u.GetReplicaSetUpdate().Namespace
+	case *metadatapb.ResourceUpdate_DeploymentUpdate:
+		ns = u.GetDeploymentUpdate().Namespace
+	case *metadatapb.ResourceUpdate_StatefulSetUpdate:
+		ns = u.GetStatefulSetUpdate().Namespace
+	case *metadatapb.ResourceUpdate_DaemonSetUpdate:
+		ns = u.GetDaemonSetUpdate().Namespace
+	case *metadatapb.ResourceUpdate_JobUpdate:
+		ns = u.GetJobUpdate().Namespace
+	case *metadatapb.ResourceUpdate_CronJobUpdate:
+		ns = u.GetCronJobUpdate().Namespace
+	case *metadatapb.ResourceUpdate_IngressUpdate:
+		ns = u.GetIngressUpdate().Namespace
+	case *metadatapb.ResourceUpdate_OwnerObjectUpdate:
+		ns = u.GetOwnerObjectUpdate().Namespace
+	}
+	if ns == "" {
+		return true
+	}
+	return s.Includes(ns)
+}
@@ -0,0 +1,221 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package k8smeta_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"px.dev/pixie/src/shared/k8s/metadatapb"
+	"px.dev/pixie/src/vizier/services/metadata/controllers/k8smeta"
+	"px.dev/pixie/src/vizier/services/metadata/storepb"
+)
+
+func TestNamespaceScope_Includes(t *testing.T) {
+	tests := []struct {
+		name     string
+		allowed  []string
+		denied   []string
+		expected map[string]bool
+	}{
+		{
+			name:     "unscoped",
+			expected: map[string]bool{"ns1": true, "ns2": true},
+		},
+		{
+			name:     "allowlist",
+			allowed:  []string{"ns1"},
+			expected: map[string]bool{"ns1": true, "ns2": false},
+		},
+		{
+			name:     "denylist",
+			denied:   []string{"ns1"},
+			expected: map[string]bool{"ns1": false, "ns2": true},
+		},
+		{
+			name:     "allowlist and denylist",
+			allowed:  []string{"ns1", "ns2"},
+			denied:   []string{"ns3"},
+			expected: map[string]bool{"ns1": true, "ns2": true, "ns3": false, "ns4": false},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := k8smeta.NewNamespaceScope(test.allowed, test.denied)
+			require.NoError(t, err)
+			for ns, expected := range test.expected {
+				assert.Equal(t, expected, s.Includes(ns), ns)
+			}
+		})
+	}
+}
+
+func TestNamespaceScope_Invalid(t *testing.T) {
+	_, err := k8smeta.NewNamespaceScope([]string{"ns1"}, []string{"ns1"})
+	assert.Error(t, err)
+	_, err = k8smeta.NewNamespaceScope([]string{""}, nil)
+	assert.Error(t, err)
+}
+
+func TestNamespaceScope_IncludesResource(t *testing.T) {
+	s, err := k8smeta.NewNamespaceScope([]string{"ns1"}, nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		resource *storepb.K8SResource
+		expected bool
+	}{
+		{
+			name: "pod in scope",
+			resource: &storepb.K8SResource{
+				Resource: &storepb.K8SResource_Pod{
+					Pod: &metadatapb.Pod{Metadata: &metadatapb.ObjectMetadata{Name: "pod", Namespace: "ns1"}},
+				},
+			},
+			expected: true,
+		},
+		{
+			name: "service out of scope",
+			resource: &storepb.K8SResource{
+				Resource: &storepb.K8SResource_Service{
+					Service: &metadatapb.Service{Metadata: &metadatapb.ObjectMetadata{Name: "svc", Namespace: "ns2"}},
+				},
+			},
+			expected: false,
+		},
+		{
+			name: "namespace out of scope",
+			resource: &storepb.K8SResource{
+				Resource: &storepb.K8SResource_Namespace{
+					Namespace: &metadatapb.Namespace{Metadata: &metadatapb.ObjectMetadata{Name: "ns2"}},
+				},
+			},
+			expected: false,
+		},
+		{
+			name: "node",
+			resource: &storepb.K8SResource{
+				Resource: &storepb.K8SResource_Node{
+					Node: &metadatapb.Node{Metadata: &metadatapb.ObjectMetadata{Name: "node"}},
+				},
+			},
+			expected: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, s.IncludesResource(test.resource))
+		})
+	}
+}
+
+func TestNamespaceScope_IncludesUpdate(t *testing.T) {
+	s, err := k8smeta.NewNamespaceScope(nil, []string{"kube-system"})
+	require.NoError(t, err)
+
+	assert.False(t, s.IncludesUpdate(&metadatapb.ResourceUpdate{
+		Update: &metadatapb.ResourceUpdate_PodUpdate{PodUpdate: &metadatapb.PodUpdate{Namespace: "kube-system"}},
+	}))
+	assert.False(t, s.IncludesUpdate(&metadatapb.ResourceUpdate{
+		Update: &metadatapb.ResourceUpdate_NamespaceUpdate{NamespaceUpdate: &metadatapb.NamespaceUpdate{Name: "kube-system"}},
+	}))
+	assert.True(t, s.IncludesUpdate(&metadatapb.ResourceUpdate{
+		Update: &metadatapb.ResourceUpdate_ContainerUpdate{ContainerUpdate: &metadatapb.ContainerUpdate{Namespace: "default"}},
+	}))
+	assert.True(t, s.IncludesUpdate(&metadatapb.ResourceUpdate{
+		Update: &metadatapb.ResourceUpdate_NodeUpdate{NodeUpdate: &metadatapb.NodeUpdate{Name: "node"}},
+	}))
+
+	// A nil scope includes everything.
+	var unscoped *k8smeta.NamespaceScope
+	assert.True(t, unscoped.IncludesUpdate(&metadatapb.ResourceUpdate{
+		Update: &metadatapb.ResourceUpdate_PodUpdate{PodUpdate: &metadatapb.PodUpdate{Namespace: "kube-system"}},
+	}))
+}
+
+func TestNamespaceScope_InformerFactories(t *testing.T) {
+	tests := []struct {
+		name               string
+		allowed            []string
+		denied             []string
+		expectedNamespaces []string
+		expectedFields     string
+	}{
+		{
+			name:               "unscoped",
+			expectedNamespaces: []string{""},
+		},
+		{
+			name:               "allowlist",
+			allowed:            []string{"ns2", "ns1"},
+			expectedNamespaces: []string{"ns1", "ns2"},
+		},
+		{
+			name:               "denylist",
+			denied:             []string{"ns2", "ns1"},
+			expectedNamespaces: []string{""},
+			expectedFields:     "metadata.namespace!=ns1,metadata.namespace!=ns2",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := k8smeta.NewNamespaceScope(test.allowed, test.denied)
+			require.NoError(t, err)
+
+			var mu sync.Mutex
+			var namespaces []string
+			var fieldSelectors []string
+			clientset := fake.NewSimpleClientset()
+			clientset.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				namespaces = append(namespaces, action.GetNamespace())
+				fieldSelectors = append(fieldSelectors, action.(k8stesting.ListAction).GetListRestrictions().Fields.String())
+				return true, &v1.PodList{}, nil
+			})
+
+			stopCh := make(chan struct{})
+			defer close(stopCh)
+			for _, factory := range s.InformerFactories(clientset, time.Hour) {
+				factory.Core().V1().Pods().Informer()
+				factory.Start(stopCh)
+				factory.WaitForCacheSync(stopCh)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			assert.ElementsMatch(t, test.expectedNamespaces, namespaces)
+			for _, f := range fieldSelectors {
+				assert.Equal(t, test.expectedFields, f)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package k8smeta
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/restmapper"
+
+	"px.dev/pixie/src/shared/k8s"
+	"px.dev/pixie/src/vizier/services/metadata/storepb"
+)
+
+const (
+	// ownerKindsConfigMapKey is the key in the owner kinds ConfigMap which lists the kinds to watch.
+	ownerKindsConfigMapKey = "kinds"
+	// ownerObjectType is the object type of updates for owner objects.
+	ownerObjectType = "ownerobjects"
+)
+
+// ParseOwnerKinds parses a list of GroupVersionKinds, with one "group/version/Kind" per line. Kinds in
+// the core group are written as "version/Kind". Empty lines and lines starting with '#' are ignored.
+func ParseOwnerKinds(s string) ([]schema.GroupVersionKind, error) {
+	var gvks []schema.GroupVersionKind
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		idx := strings.LastIndex(line, "/")
+		if idx <= 0 || idx == len(line)-1 {
+			return nil, fmt.Errorf("invalid kind '%s': expected group/version/Kind", line)
+		}
+		gv, err := schema.ParseGroupVersion(line[:idx])
+		if err != nil {
+			return nil, fmt.Errorf("invalid kind '%s': %v", line, err)
+		}
+		gvks = append(gvks, gv.WithKind(line[idx+1:]))
+	}
+	return gvks, nil
+}
+
+// loadOwnerKinds reads the kinds to watch from the given ConfigMap. No kinds are watched if the
+// ConfigMap does not exist.
+func loadOwnerKinds(clientset kubernetes.Interface, namespace string, name string) ([]schema.GroupVersionKind, error) {
+	if name == "" {
+		return nil, nil
+	}
+
+	cm, err := clientset.CoreV1().ConfigMaps(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ParseOwnerKinds(cm.Data[ownerKindsConfigMapKey])
+}
+
+// ownerWatchers creates a dynamic watcher for each of the kinds listed in the given ConfigMap.
+// Kinds which are not served by the API server, such as those of CRDs which are not installed, are
+// skipped. Namespaced kinds are only listed in the namespaces in scope.
+func ownerWatchers(ch chan *K8sResourceMessage, clientset *kubernetes.Clientset, dynamicClient dynamic.Interface, namespace string, configMap string, scope *NamespaceScope) ([]watcher, error) {
+	gvks, err := loadOwnerKinds(clientset, namespace, configMap)
+	if err != nil || len(gvks) == 0 {
+		return nil, err
+	}
+
+	apiGroupResources, err := restmapper.GetAPIGroupResources(clientset.Discovery())
+	if err != nil {
+		return nil, err
+	}
+	rm := restmapper.NewDiscoveryRESTMapper(apiGroupResources)
+
+	clusterFactory := dynamicinformer.NewDynamicSharedInformerFactory(dynamicClient, informerResync)
+	factories := scope.DynamicInformerFactories(dynamicClient, informerResync)
+	var watchers []watcher
+	for _, gvk := range gvks {
+		mapping, err := rm.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			log.WithError(err).WithField("kind", gvk.String()).Warn("Failed to find resource for owner kind, skipping")
+			continue
+		}
+		log.WithField("kind", gvk.String()).Info("Watching owner kind")
+
+		kindFactories := factories
+		if mapping.Scope.Name() == meta.RESTScopeNameRoot {
+			kindFactories = []dynamicinformer.DynamicSharedInformerFactory{clusterFactory}
+		}
+		for _, factory := range kindFactories {
+			watchers = append(watchers, &informerWatcher{
+				convert: ownerObjectConverter,
+				objType: ownerObjectType,
+				ch:      ch,
+				inf:     factory.ForResource(mapping.Resource).Informer(),
+			})
+		}
+	}
+	return watchers, nil
+}
+
+func ownerObjectConverter(obj interface{}) *K8sResourceMessage {
+	o, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil
+	}
+
+	return &K8sResourceMessage{
+		Object: &storepb.K8SResource{
+			Resource: &storepb.K8SResource_OwnerObject{
+				OwnerObject: k8s.OwnerObjectToProto(o),
+			},
+		},
+	}
+}
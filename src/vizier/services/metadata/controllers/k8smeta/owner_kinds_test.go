@@ -0,0 +1,85 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package k8smeta_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"px.dev/pixie/src/vizier/services/metadata/controllers/k8smeta"
+)
+
+func TestParseOwnerKinds(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    []schema.GroupVersionKind
+		expectError bool
+	}{
+		{
+			name: "valid",
+			input: `
+# Argo Rollouts.
+argoproj.io/v1alpha1/Rollout
+  serving.knative.dev/v1/Revision
+v1/ReplicationController
+`,
+			expected: []schema.GroupVersionKind{
+				{Group: "argoproj.io", Version: "v1alpha1", Kind: "Rollout"},
+				{Group: "serving.knative.dev", Version: "v1", Kind: "Revision"},
+				{Group: "", Version: "v1", Kind: "ReplicationController"},
+			},
+		},
+		{
+			name:     "empty",
+			input:    "",
+			expected: nil,
+		},
+		{
+			name:        "missing kind",
+			input:       "argoproj.io/v1alpha1/",
+			expectError: true,
+		},
+		{
+			name:        "missing version",
+			input:       "Rollout",
+			expectError: true,
+		},
+		{
+			name:        "too many parts",
+			input:       "argoproj.io/v1alpha1/extra/Rollout",
+			expectError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gvks, err := k8smeta.ParseOwnerKinds(test.input)
+			if test.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, gvks)
+		})
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -34,12 +35,16 @@ import (
 	log "github.com/sirupsen/logrus"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/labels"
 
 	"px.dev/pixie/src/carnot/planner/distributedpb"
+	"px.dev/pixie/src/carnot/planner/dynamic_tracing/ir/logicalpb"
 	"px.dev/pixie/src/common/base/statuspb"
+	"px.dev/pixie/src/shared/services/authcontext"
 	"px.dev/pixie/src/table_store/schemapb"
 	"px.dev/pixie/src/utils"
 	"px.dev/pixie/src/vizier/services/metadata/controllers/agent"
+	"px.dev/pixie/src/vizier/services/metadata/controllers/k8smeta"
 	"px.dev/pixie/src/vizier/services/metadata/controllers/tracepoint"
 	"px.dev/pixie/src/vizier/services/metadata/metadataenv"
 	"px.dev/pixie/src/vizier/services/metadata/metadatapb"
@@ -52,12 +57,30 @@ import (
 // its last heartbeat is greater than this value.
 const UnhealthyAgentThreshold = 30 * time.Second
 
+// ObjectLabelFetcher fetches the K8s objects whose labels match a label selector.
+type ObjectLabelFetcher interface {
+	FetchObjectsWithSelector(objectType string, namespace string, selector labels.Selector) ([]*storepb.K8SObjectLabels, error)
+}
+
+// K8sHistoryFetcher fetches the observed versions of K8s objects.
+type K8sHistoryFetcher interface {
+	FetchResourceHistory(objectType string, namespace string, name string, startNS int64, endNS int64) ([]*storepb.K8SResourceHistoryEntry, error)
+	FetchRestarts(objectType string, namespace string, name string, startNS int64, endNS int64) ([]*storepb.K8SResourceHistoryEntry, error)
+	FetchPodByIP(ip string, timeNS int64) (*storepb.K8SResourceHistoryEntry, error)
+}
+
 // Server defines an gRPC server type.
 type Server struct {
 	env    metadataenv.MetadataEnv
 	ds     datastore.MultiGetterSetterDeleterCloser
 	agtMgr agent.Manager
 	tpMgr  *tracepoint.Manager
+	// Resolves the hosts selected by tracepoint selectors.
+	hosts tracepoint.HostResolver
+	// Fetches K8s objects by label selector.
+	labels ObjectLabelFetcher
+	// Fetches the history of K8s objects.
+	history K8sHistoryFetcher
 	// The current cursor that is actively running the GetAgentsUpdate stream. Only one GetAgentsUpdate
 	// stream should be running at a time.
 	getAgentsCursor uuid.UUID
@@ -65,12 +88,15 @@ type Server struct {
 }
 
 // NewServer creates GRPC handlers.
-func NewServer(env metadataenv.MetadataEnv, ds datastore.MultiGetterSetterDeleterCloser, agtMgr agent.Manager, tpMgr *tracepoint.Manager) *Server {
+func NewServer(env metadataenv.MetadataEnv, ds datastore.MultiGetterSetterDeleterCloser, agtMgr agent.Manager, tpMgr *tracepoint.Manager, hosts tracepoint.HostResolver, labels ObjectLabelFetcher, history K8sHistoryFetcher) *Server {
 	return &Server{
-		env:    env,
-		ds:     ds,
-		agtMgr: agtMgr,
-		tpMgr:  tpMgr,
+		env:     env,
+		ds:      ds,
+		agtMgr:  agtMgr,
+		tpMgr:   tpMgr,
+		hosts:   hosts,
+		labels:  labels,
+		history: history,
 	}
 }
 
@@ -165,6 +191,8 @@ func (s *Server) GetAgentInfo(ctx context.Context, req *metadatapb.AgentInfoRequ
 		timeSinceLastHb := currentTime.Sub(time.Unix(0, agt.LastHeartbeatNS))
 		if timeSinceLastHb > UnhealthyAgentThreshold {
 			state = agentpb.AGENT_STATE_UNRESPONSIVE
+		} else if agt.Health.GetQuarantined() {
+			state = agentpb.AGENT_STATE_QUARANTINED
 		}
 
 		resp := metadatapb.AgentMetadata{
@@ -184,6 +212,21 @@ func (s *Server) GetAgentInfo(ctx context.Context, req *metadatapb.AgentInfoRequ
 	return &resp, nil
 }
 
+// RecordAgentQueryFailures records the agents which took part in a failed query, so that the failures
+// count against the agents' health.
+func (s *Server) RecordAgentQueryFailures(ctx context.Context, req *metadatapb.RecordAgentQueryFailuresRequest) (*metadatapb.RecordAgentQueryFailuresResponse, error) {
+	agentIDs := make([]uuid.UUID, len(req.AgentIDs))
+	for i, agentID := range req.AgentIDs {
+		id, err := utils.UUIDFromProto(agentID)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "Invalid agent ID")
+		}
+		agentIDs[i] = id
+	}
+	s.agtMgr.RecordQueryFailures(agentIDs)
+	return &metadatapb.RecordAgentQueryFailuresResponse{}, nil
+}
+
 // GetAgentUpdates streams agent updates to the requestor periodically as they come in.
 // It first sends the complete initial agent state in the beginning of the request, and then deltas after that.
 // Note that as it is currently designed, it can only handle one stream at a time (to a single metadata server).
@@ -222,7 +265,7 @@ func (s *Server) GetAgentUpdates(req *metadatapb.AgentUpdatesRequest, srv metada
 		}
 
 		updates, newComputedSchema, err := s.agtMgr.GetAgentUpdates(cursor)
-		if err == agent.ErrNoComputedSchemas {
+		if errors.Is(err, agent.ErrNoComputedSchemas) {
 			// We need to wait until we have computed schemas
 			time.Sleep(agentUpdatePeriod)
 			continue
@@ -346,21 +389,199 @@ func (s *Server) GetWithPrefixKey(ctx context.Context, req *metadatapb.WithPrefi
 	return resp, nil
 }
 
-// RegisterTracepoint is a request to register the tracepoints specified in the TracepointDeployment on all agents.
+// GetObjectsWithLabelSelector fetches the K8s objects of the given type whose labels match the label
+// selector. The selector uses the K8s label selector syntax, such as "app in (a, b), env, !canary".
+func (s *Server) GetObjectsWithLabelSelector(ctx context.Context, req *metadatapb.ObjectsWithLabelSelectorRequest) (*metadatapb.ObjectsWithLabelSelectorResponse, error) {
+	if _, ok := k8smeta.LabelIndexedObjectTypes[req.ObjectType]; !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "Labels are not indexed for object type '%s'", req.ObjectType)
+	}
+	selector, err := labels.Parse(req.Selector)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid label selector: %v", err)
+	}
+	if s.labels == nil {
+		return nil, status.Error(codes.Unavailable, "Label store is not available")
+	}
+
+	objects, err := s.labels.FetchObjectsWithSelector(req.ObjectType, req.Namespace, selector)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	sort.Slice(objects, func(i, j int) bool {
+		if objects[i].Namespace != objects[j].Namespace {
+			return objects[i].Namespace < objects[j].Namespace
+		}
+		return objects[i].Name < objects[j].Name
+	})
+	return &metadatapb.ObjectsWithLabelSelectorResponse{Objects: objects}, nil
+}
+
+// GetK8SObjectHistory lists the versions of a K8s object observed within a time range.
+func (s *Server) GetK8SObjectHistory(ctx context.Context, req *metadatapb.K8SObjectHistoryRequest) (*metadatapb.K8SObjectHistoryResponse, error) {
+	if req.ObjectType == "" || req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "Object type and name must be specified")
+	}
+	if s.history == nil {
+		return nil, status.Error(codes.Unavailable, "K8s history is not available")
+	}
+
+	entries, err := s.history.FetchResourceHistory(req.ObjectType, req.Namespace, req.Name, req.StartTimeNS, req.EndTimeNS)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &metadatapb.K8SObjectHistoryResponse{Entries: entries}, nil
+}
+
+// GetPodByIP gets the pod which owned an IP at a given time.
+func (s *Server) GetPodByIP(ctx context.Context, req *metadatapb.PodByIPRequest) (*metadatapb.PodByIPResponse, error) {
+	if req.IP == "" {
+		return nil, status.Error(codes.InvalidArgument, "IP must be specified")
+	}
+	if s.history == nil {
+		return nil, status.Error(codes.Unavailable, "K8s history is not available")
+	}
+
+	timeNS := req.TimeNS
+	if timeNS == 0 {
+		timeNS = time.Now().UnixNano()
+	}
+	pod, err := s.history.FetchPodByIP(req.IP, timeNS)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &metadatapb.PodByIPResponse{Pod: pod}, nil
+}
+
+// GetK8SObjectDiff gets the differences between two versions of a K8s object.
+func (s *Server) GetK8SObjectDiff(ctx context.Context, req *metadatapb.K8SObjectDiffRequest) (*metadatapb.K8SObjectDiffResponse, error) {
+	resp, err := s.GetK8SObjectHistory(ctx, &metadatapb.K8SObjectHistoryRequest{
+		ObjectType: req.ObjectType,
+		Namespace:  req.Namespace,
+		Name:       req.Name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Entries) == 0 {
+		return nil, status.Errorf(codes.NotFound, "No history for %s '%s'", req.ObjectType, req.Name)
+	}
+
+	var from, to *storepb.K8SResourceHistoryEntry
+	for _, e := range resp.Entries {
+		if e.UpdateVersion == req.FromVersion {
+			from = e
+		}
+		if e.UpdateVersion == req.ToVersion {
+			to = e
+		}
+	}
+	if req.ToVersion == 0 {
+		to = resp.Entries[len(resp.Entries)-1]
+	}
+	if from == nil {
+		return nil, status.Errorf(codes.NotFound, "Version %d of %s '%s' does not exist", req.FromVersion, req.ObjectType, req.Name)
+	}
+	if to == nil {
+		return nil, status.Errorf(codes.NotFound, "Version %d of %s '%s' does not exist", req.ToVersion, req.ObjectType, req.Name)
+	}
+
+	diff, err := k8smeta.DiffEntries(from, to)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &metadatapb.K8SObjectDiffResponse{From: from, To: to, Diff: diff}, nil
+}
+
+// GetK8SObjectRestarts lists the restarts of a K8s object within a time range.
+func (s *Server) GetK8SObjectRestarts(ctx context.Context, req *metadatapb.K8SObjectRestartsRequest) (*metadatapb.K8SObjectRestartsResponse, error) {
+	if !k8smeta.RestartableObjectTypes[req.ObjectType] {
+		return nil, status.Errorf(codes.InvalidArgument, "Restarts are not tracked for object type '%s'", req.ObjectType)
+	}
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "Object name must be specified")
+	}
+	if s.history == nil {
+		return nil, status.Error(codes.Unavailable, "K8s history is not available")
+	}
+
+	restarts, err := s.history.FetchRestarts(req.ObjectType, req.Namespace, req.Name, req.StartTimeNS, req.EndTimeNS)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &metadatapb.K8SObjectRestartsResponse{Restarts: restarts}, nil
+}
+
+// userIDFromContext returns the ID of the user making the request, or an empty string if the request
+// was not made on behalf of a user.
+func userIDFromContext(ctx context.Context) string {
+	aCtx, err := authcontext.FromContext(ctx)
+	if err != nil {
+		return ""
+	}
+	return aCtx.Claims.GetUserClaims().GetUserID()
+}
+
+func (s *Server) getTracepointUsage(owner string) (*metadatapb.TracepointUsage, error) {
+	usage, err := s.tpMgr.GetUsage(owner)
+	if err != nil {
+		return nil, err
+	}
+	policy := s.tpMgr.GetPolicy()
+
+	var maxTTL *types.Duration
+	if policy.MaxTTL > 0 {
+		maxTTL = types.DurationProto(policy.MaxTTL)
+	}
+	return &metadatapb.TracepointUsage{
+		ActiveTracepoints:     int64(usage.Active),
+		MaxTracepoints:        int64(policy.MaxTracepoints),
+		UserActiveTracepoints: int64(usage.UserActive),
+		MaxTracepointsPerUser: int64(policy.MaxTracepointsPerUser),
+		MaxTTL:                maxTTL,
+	}, nil
+}
+
+// RegisterTracepoint is a request to register the tracepoints specified in the TracepointDeployment on all agents
+// matching the tracepoint's selector, optionally in stages.
 func (s *Server) RegisterTracepoint(ctx context.Context, req *metadatapb.RegisterTracepointRequest) (*metadatapb.RegisterTracepointResponse, error) {
 	responses := make([]*metadatapb.RegisterTracepointResponse_TracepointStatus, len(req.Requests))
 
+	for _, tp := range req.Requests {
+		if err := tracepoint.ValidateRolloutStages(tp.RolloutStages); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	owner := userIDFromContext(ctx)
+	respStatus := &statuspb.Status{
+		ErrCode: statuspb.OK,
+	}
+
 	// Create tracepoint.
 	for i, tp := range req.Requests {
 		ttl, err := types.DurationFromProto(tp.TTL)
 		if err != nil {
 			return nil, err
 		}
-		tracepointID, err := s.tpMgr.CreateTracepoint(tp.Name, tp.TracepointDeployment, ttl)
-		if err != nil && err != tracepoint.ErrTracepointAlreadyExists {
+		tracepointID, err := s.tpMgr.CreateTracepoint(tp.Name, tp.TracepointDeployment, ttl, owner, tp.Selector, tp.RolloutStages)
+		if policyStatus := tracepoint.PolicyErrorStatus(err); policyStatus != nil {
+			responses[i] = &metadatapb.RegisterTracepointResponse_TracepointStatus{
+				Status: policyStatus,
+				Name:   tp.Name,
+			}
+			// Surface the first policy violation as the overall status.
+			if respStatus.ErrCode == statuspb.OK {
+				respStatus = &statuspb.Status{
+					ErrCode: policyStatus.ErrCode,
+					Msg:     fmt.Sprintf("tracepoint '%s': %s", tp.Name, policyStatus.Msg),
+				}
+			}
+			continue
+		}
+		if err != nil && !errors.Is(err, tracepoint.ErrTracepointAlreadyExists) {
 			return nil, err
 		}
-		if err == tracepoint.ErrTracepointAlreadyExists {
+		if errors.Is(err, tracepoint.ErrTracepointAlreadyExists) {
 			responses[i] = &metadatapb.RegisterTracepointResponse_TracepointStatus{
 				ID: utils.ProtoFromUUID(*tracepointID),
 				Status: &statuspb.Status{
@@ -379,18 +600,7 @@ func (s *Server) RegisterTracepoint(ctx context.Context, req *metadatapb.Registe
 			Name: tp.Name,
 		}
 
-		// Get all agents currently running.
-		agents, err := s.agtMgr.GetActiveAgents()
-		if err != nil {
-			return nil, err
-		}
-		agentIDs := make([]uuid.UUID, len(agents))
-		for i, agt := range agents {
-			agentIDs[i] = utils.UUIDFromProtoOrNil(agt.Info.AgentID)
-		}
-
-		// Register tracepoint on all agents.
-		err = s.tpMgr.RegisterTracepoint(agentIDs, *tracepointID, tp.TracepointDeployment)
+		err = s.deployTracepointToActiveAgents(*tracepointID, tp.TracepointDeployment, tp.Selector, tp.RolloutStages)
 		if err != nil {
 			return nil, err
 		}
@@ -398,14 +608,94 @@ func (s *Server) RegisterTracepoint(ctx context.Context, req *metadatapb.Registe
 
 	resp := &metadatapb.RegisterTracepointResponse{
 		Tracepoints: responses,
-		Status: &statuspb.Status{
-			ErrCode: statuspb.OK,
-		},
+		Status:      respStatus,
 	}
 
 	return resp, nil
 }
 
+// deployTracepointToActiveAgents deploys the tracepoint to the currently running agents that match
+// the selector, in the given rollout stages.
+func (s *Server) deployTracepointToActiveAgents(tracepointID uuid.UUID, tracepointDeployment *logicalpb.TracepointDeployment, selector *storepb.TracepointSelector, stages []*storepb.TracepointRolloutStage) error {
+	agents, err := s.agtMgr.GetActiveAgents()
+	if err != nil {
+		return err
+	}
+	agentIDs, err := tracepoint.SelectAgents(agents, selector, s.hosts)
+	if err != nil {
+		return err
+	}
+	return s.tpMgr.DeployTracepoint(tracepointID, tracepointDeployment, agentIDs, selector, stages)
+}
+
+// GetTracepointVersions is a request to list the retained versions of the named tracepoint.
+func (s *Server) GetTracepointVersions(ctx context.Context, req *metadatapb.GetTracepointVersionsRequest) (*metadatapb.GetTracepointVersionsResponse, error) {
+	versions, err := s.tpMgr.GetTracepointVersions(req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &metadatapb.GetTracepointVersionsResponse{
+		Versions: versions,
+	}, nil
+}
+
+// RollbackTracepoint is a request to redeploy a previous version of the named tracepoint, with the
+// selector and rollout stages it was deployed with.
+func (s *Server) RollbackTracepoint(ctx context.Context, req *metadatapb.RollbackTracepointRequest) (*metadatapb.RollbackTracepointResponse, error) {
+	ttl, err := types.DurationFromProto(req.TTL)
+	if err != nil {
+		return nil, err
+	}
+
+	tpInfo, target, err := s.tpMgr.RollbackTracepoint(req.Name, req.Version, ttl, userIDFromContext(ctx))
+	if policyStatus := tracepoint.PolicyErrorStatus(err); policyStatus != nil {
+		return &metadatapb.RollbackTracepointResponse{
+			Status: policyStatus,
+		}, nil
+	}
+	switch {
+	case errors.Is(err, tracepoint.ErrTracepointVersionNotFound):
+		return &metadatapb.RollbackTracepointResponse{
+			Status: &statuspb.Status{
+				ErrCode: statuspb.NOT_FOUND,
+				Msg:     err.Error(),
+			},
+		}, nil
+	case errors.Is(err, tracepoint.ErrTracepointSchemaMismatch):
+		return &metadatapb.RollbackTracepointResponse{
+			Status: &statuspb.Status{
+				ErrCode: statuspb.FAILED_PRECONDITION,
+				Msg:     err.Error(),
+			},
+		}, nil
+	case errors.Is(err, tracepoint.ErrTracepointAlreadyExists):
+		return &metadatapb.RollbackTracepointResponse{
+			Status: &statuspb.Status{
+				ErrCode: statuspb.ALREADY_EXISTS,
+			},
+			ID:      tpInfo.ID,
+			Version: tpInfo.Version,
+		}, nil
+	case err != nil:
+		return nil, err
+	}
+
+	// Deploy the restored version the same way it was originally deployed.
+	err = s.deployTracepointToActiveAgents(utils.UUIDFromProtoOrNil(tpInfo.ID), tpInfo.Tracepoint, target.Selector, target.RolloutStages)
+	if err != nil {
+		return nil, err
+	}
+
+	return &metadatapb.RollbackTracepointResponse{
+		Status: &statuspb.Status{
+			ErrCode: statuspb.OK,
+		},
+		ID:      tpInfo.ID,
+		Version: tpInfo.Version,
+	}, nil
+}
+
 // GetTracepointInfo is a request to check the status for the given tracepoint.
 func (s *Server) GetTracepointInfo(ctx context.Context, req *metadatapb.GetTracepointInfoRequest) (*metadatapb.GetTracepointInfoResponse, error) {
 	var tracepointInfos []*storepb.TracepointInfo
@@ -459,11 +749,18 @@ func (s *Server) GetTracepointInfo(ctx context.Context, req *metadatapb.GetTrace
 			Name:          tp.Name,
 			ExpectedState: tp.ExpectedState,
 			SchemaNames:   schemas,
+			Rollout:       tp.Rollout,
 		}
 	}
 
+	usage, err := s.getTracepointUsage(userIDFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
 	return &metadatapb.GetTracepointInfoResponse{
 		Tracepoints: tracepointState,
+		Usage:       usage,
 	}, nil
 }
 
@@ -36,8 +36,10 @@ import (
 	"github.com/stretchr/testify/require"
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
 	grpc_metadata "google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"google.golang.org/grpc/test/bufconn"
 
 	"px.dev/pixie/src/api/proto/uuidpb"
@@ -45,6 +47,7 @@ import (
 	"px.dev/pixie/src/carnot/planner/dynamic_tracing/ir/logicalpb"
 	"px.dev/pixie/src/common/base/statuspb"
 	"px.dev/pixie/src/shared/bloomfilterpb"
+	k8smetadatapb "px.dev/pixie/src/shared/k8s/metadatapb"
 	sharedmetadatapb "px.dev/pixie/src/shared/metadatapb"
 	"px.dev/pixie/src/shared/services/env"
 	"px.dev/pixie/src/shared/services/server"
@@ -54,6 +57,7 @@ import (
 	"px.dev/pixie/src/vizier/messages/messagespb"
 	"px.dev/pixie/src/vizier/services/metadata/controllers"
 	mock_agent "px.dev/pixie/src/vizier/services/metadata/controllers/agent/mock"
+	"px.dev/pixie/src/vizier/services/metadata/controllers/k8smeta"
 	"px.dev/pixie/src/vizier/services/metadata/controllers/testutils"
 	"px.dev/pixie/src/vizier/services/metadata/controllers/tracepoint"
 	mock_tracepoint "px.dev/pixie/src/vizier/services/metadata/controllers/tracepoint/mock"
@@ -164,7 +168,7 @@ func TestGetAgentInfo(t *testing.T) {
 		t.Fatal("Failed to create api environment.")
 	}
 
-	s := controllers.NewServer(env, nil, mockAgtMgr, nil)
+	s := controllers.NewServer(env, nil, mockAgtMgr, nil, nil, nil, nil)
 
 	req := metadatapb.AgentInfoRequest{}
 
@@ -210,7 +214,7 @@ func TestGetAgentInfoGetActiveAgentsFailed(t *testing.T) {
 		t.Fatal("Failed to create api environment.")
 	}
 
-	s := controllers.NewServer(env, nil, mockAgtMgr, nil)
+	s := controllers.NewServer(env, nil, mockAgtMgr, nil, nil, nil, nil)
 
 	req := metadatapb.AgentInfoRequest{}
 
@@ -239,7 +243,7 @@ func TestGetSchemas(t *testing.T) {
 		t.Fatal("Failed to create api environment.")
 	}
 
-	s := controllers.NewServer(env, nil, mockAgtMgr, nil)
+	s := controllers.NewServer(env, nil, mockAgtMgr, nil, nil, nil, nil)
 
 	req := metadatapb.SchemaRequest{}
 
@@ -320,34 +324,26 @@ func Test_Server_RegisterTracepoint(t *testing.T) {
 	var tpID uuid.UUID
 	mockTracepointStore.
 		EXPECT().
-		UpsertTracepoint(gomock.Any(), gomock.Any()).
-		DoAndReturn(func(tracepointID uuid.UUID, tracepointInfo *storepb.TracepointInfo) error {
+		CreateTracepointVersion(gomock.Any(), time.Second*5, gomock.Any()).
+		DoAndReturn(func(tracepointInfo *storepb.TracepointInfo, ttl time.Duration, version *storepb.TracepointVersion) error {
 			assert.Equal(t, program, tracepointInfo.Tracepoint)
-			tpID = tracepointID
+			tpID = utils.UUIDFromProtoOrNil(tracepointInfo.ID)
 			assert.Equal(t, "test_tracepoint", tracepointInfo.Name)
+			assert.Equal(t, tracepointInfo.ID, version.ID)
 			return nil
 		})
 	mockTracepointStore.
 		EXPECT().
-		SetTracepointWithName("test_tracepoint", gomock.Any()).
-		DoAndReturn(func(tpName string, id uuid.UUID) error {
-			assert.Equal(t, tpID, id)
-			return nil
-		})
-	mockTracepointStore.
-		EXPECT().
-		SetTracepointTTL(gomock.Any(), time.Second*5).
-		DoAndReturn(func(id uuid.UUID, ttl time.Duration) error {
-			assert.Equal(t, tpID, id)
-			return nil
-		})
+		GetTracepointVersions("test_tracepoint").
+		Return(nil, nil)
+
 	// Set up server.
 	env, err := metadataenv.New("vizier")
 	if err != nil {
 		t.Fatal("Failed to create api environment.")
 	}
 
-	s := controllers.NewServer(env, nil, mockAgtMgr, tracepointMgr)
+	s := controllers.NewServer(env, nil, mockAgtMgr, tracepointMgr, nil, nil, nil)
 
 	reqs := []*metadatapb.RegisterTracepointRequest_TracepointRequest{
 		{
@@ -373,6 +369,48 @@ func Test_Server_RegisterTracepoint(t *testing.T) {
 	assert.Equal(t, statuspb.OK, resp.Status.ErrCode)
 }
 
+func Test_Server_RegisterTracepoint_PolicyViolation(t *testing.T) {
+	// Set up mock.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockAgtMgr := mock_agent.NewMockManager(ctrl)
+	mockTracepointStore := mock_tracepoint.NewMockStore(ctrl)
+
+	tracepointMgr := tracepoint.NewManager(mockTracepointStore, mockAgtMgr, 5*time.Second)
+	tracepointMgr.SetPolicy(&tracepoint.Policy{
+		MaxTTL: time.Second,
+	})
+
+	// Set up server.
+	env, err := metadataenv.New("vizier")
+	if err != nil {
+		t.Fatal("Failed to create api environment.")
+	}
+
+	s := controllers.NewServer(env, nil, mockAgtMgr, tracepointMgr, nil, nil, nil)
+
+	req := metadatapb.RegisterTracepointRequest{
+		Requests: []*metadatapb.RegisterTracepointRequest_TracepointRequest{
+			{
+				TracepointDeployment: &logicalpb.TracepointDeployment{},
+				Name:                 "test_tracepoint",
+				TTL: &types.Duration{
+					Seconds: 5,
+				},
+			},
+		},
+	}
+
+	resp, err := s.RegisterTracepoint(context.Background(), &req)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, len(resp.Tracepoints))
+	assert.Nil(t, resp.Tracepoints[0].ID)
+	assert.Equal(t, statuspb.INVALID_ARGUMENT, resp.Tracepoints[0].Status.ErrCode)
+	assert.Equal(t, statuspb.INVALID_ARGUMENT, resp.Status.ErrCode)
+	assert.Contains(t, resp.Status.Msg, "test_tracepoint")
+}
+
 func Test_Server_RegisterTracepoint_Exists(t *testing.T) {
 	// Set up mock.
 	ctrl := gomock.NewController(t)
@@ -444,27 +482,19 @@ func Test_Server_RegisterTracepoint_Exists(t *testing.T) {
 	var tpID uuid.UUID
 	mockTracepointStore.
 		EXPECT().
-		UpsertTracepoint(gomock.Any(), gomock.Any()).
-		DoAndReturn(func(tracepointID uuid.UUID, tracepointInfo *storepb.TracepointInfo) error {
+		CreateTracepointVersion(gomock.Any(), time.Second*5, gomock.Any()).
+		DoAndReturn(func(tracepointInfo *storepb.TracepointInfo, ttl time.Duration, version *storepb.TracepointVersion) error {
 			assert.Equal(t, program, tracepointInfo.Tracepoint)
-			tpID = tracepointID
+			tpID = utils.UUIDFromProtoOrNil(tracepointInfo.ID)
 			assert.Equal(t, "test_tracepoint", tracepointInfo.Name)
+			assert.Equal(t, tracepointInfo.ID, version.ID)
 			return nil
 		})
+
 	mockTracepointStore.
 		EXPECT().
-		SetTracepointWithName("test_tracepoint", gomock.Any()).
-		DoAndReturn(func(tpName string, id uuid.UUID) error {
-			assert.Equal(t, tpID, id)
-			return nil
-		})
-	mockTracepointStore.
-		EXPECT().
-		SetTracepointTTL(gomock.Any(), time.Second*5).
-		DoAndReturn(func(id uuid.UUID, ttl time.Duration) error {
-			assert.Equal(t, tpID, id)
-			return nil
-		})
+		GetTracepointVersions("test_tracepoint").
+		Return(nil, nil)
 
 	// Set up server.
 	env, err := metadataenv.New("vizier")
@@ -472,7 +502,7 @@ func Test_Server_RegisterTracepoint_Exists(t *testing.T) {
 		t.Fatal("Failed to create api environment.")
 	}
 
-	s := controllers.NewServer(env, nil, mockAgtMgr, tracepointMgr)
+	s := controllers.NewServer(env, nil, mockAgtMgr, tracepointMgr, nil, nil, nil)
 
 	reqs := []*metadatapb.RegisterTracepointRequest_TracepointRequest{
 		{
@@ -497,6 +527,435 @@ func Test_Server_RegisterTracepoint_Exists(t *testing.T) {
 	assert.Equal(t, statuspb.OK, resp.Tracepoints[0].Status.ErrCode)
 }
 
+func Test_Server_RollbackTracepoint(t *testing.T) {
+	program := &logicalpb.TracepointDeployment{
+		Programs: []*logicalpb.TracepointDeployment_TracepointProgram{
+			{
+				TableName: "table1",
+				Spec: &logicalpb.TracepointSpec{
+					Outputs: []*logicalpb.Output{
+						{
+							Name:   "table1",
+							Fields: []string{"abc"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name            string
+		version         *storepb.TracepointVersion
+		expectedErrCode statuspb.Code
+	}{
+		{
+			name: "rollback",
+			version: &storepb.TracepointVersion{
+				Name:       "test_tracepoint",
+				Version:    1,
+				Tracepoint: program,
+			},
+			expectedErrCode: statuspb.OK,
+		},
+		{
+			name:            "version not found",
+			expectedErrCode: statuspb.NOT_FOUND,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockAgtMgr := mock_agent.NewMockManager(ctrl)
+			mockTracepointStore := mock_tracepoint.NewMockStore(ctrl)
+
+			tracepointMgr := tracepoint.NewManager(mockTracepointStore, mockAgtMgr, 5*time.Second)
+			defer tracepointMgr.Close()
+
+			mockTracepointStore.
+				EXPECT().
+				GetTracepointVersion("test_tracepoint", int64(1)).
+				Return(test.version, nil)
+
+			var tpID uuid.UUID
+			if test.version != nil {
+				mockTracepointStore.
+					EXPECT().
+					GetTracepointsWithNames([]string{"test_tracepoint"}).
+					Return([]*uuid.UUID{nil}, nil).
+					Times(2)
+				mockTracepointStore.
+					EXPECT().
+					GetTracepointVersions("test_tracepoint").
+					Return([]*storepb.TracepointVersion{test.version}, nil)
+				mockTracepointStore.
+					EXPECT().
+					CreateTracepointVersion(gomock.Any(), time.Second*5, gomock.Any()).
+					DoAndReturn(func(tracepointInfo *storepb.TracepointInfo, ttl time.Duration, version *storepb.TracepointVersion) error {
+						assert.Equal(t, program, tracepointInfo.Tracepoint)
+						assert.Equal(t, int64(2), tracepointInfo.Version)
+						tpID = utils.UUIDFromProtoOrNil(tracepointInfo.ID)
+						return nil
+					})
+				mockAgtMgr.
+					EXPECT().
+					GetActiveAgents().
+					Return([]*agentpb.Agent{}, nil)
+				mockAgtMgr.
+					EXPECT().
+					MessageAgents([]uuid.UUID{}, gomock.Any()).
+					Return(nil)
+			}
+
+			// Set up server.
+			env, err := metadataenv.New("vizier")
+			if err != nil {
+				t.Fatal("Failed to create api environment.")
+			}
+
+			s := controllers.NewServer(env, nil, mockAgtMgr, tracepointMgr, nil, nil, nil)
+
+			resp, err := s.RollbackTracepoint(context.Background(), &metadatapb.RollbackTracepointRequest{
+				Name:    "test_tracepoint",
+				Version: 1,
+				TTL: &types.Duration{
+					Seconds: 5,
+				},
+			})
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedErrCode, resp.Status.ErrCode)
+			if test.version != nil {
+				assert.Equal(t, utils.ProtoFromUUID(tpID), resp.ID)
+				assert.Equal(t, int64(2), resp.Version)
+			}
+		})
+	}
+}
+
+type fakeHostResolver struct {
+	nodeIPs []string
+}
+
+func (f *fakeHostResolver) GetNodeIPsWithLabels(labels map[string]string) []string {
+	return f.nodeIPs
+}
+
+func (f *fakeHostResolver) GetHostIPsForPodsWithLabels(namespace string, labels map[string]string) ([]string, error) {
+	return nil, nil
+}
+
+func Test_Server_RegisterTracepoint_Staged(t *testing.T) {
+	// Set up mock.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockAgtMgr := mock_agent.NewMockManager(ctrl)
+	mockTracepointStore := mock_tracepoint.NewMockStore(ctrl)
+
+	tracepointMgr := tracepoint.NewManager(mockTracepointStore, mockAgtMgr, 5*time.Second)
+	defer tracepointMgr.Close()
+
+	program := &logicalpb.TracepointDeployment{
+		Programs: []*logicalpb.TracepointDeployment_TracepointProgram{
+			{
+				TableName: "table1",
+			},
+		},
+	}
+
+	agentIDs := []uuid.UUID{uuid.Must(uuid.NewV4()), uuid.Must(uuid.NewV4()), uuid.Must(uuid.NewV4())}
+	agents := make([]*agentpb.Agent, len(agentIDs))
+	for i, id := range agentIDs {
+		agents[i] = &agentpb.Agent{
+			Info: &agentpb.AgentInfo{
+				AgentID:  utils.ProtoFromUUID(id),
+				HostInfo: &agentpb.HostInfo{HostIP: fmt.Sprintf("10.0.0.%d", i)},
+			},
+		}
+	}
+	selector := &storepb.TracepointSelector{NodeLabels: map[string]string{"canary": "true"}}
+	stages := []*storepb.TracepointRolloutStage{{NumAgents: 1}}
+
+	mockAgtMgr.
+		EXPECT().
+		GetActiveAgents().
+		Return(agents, nil)
+
+	mockTracepointStore.
+		EXPECT().
+		GetTracepointsWithNames([]string{"test_tracepoint"}).
+		Return([]*uuid.UUID{nil}, nil)
+	mockTracepointStore.
+		EXPECT().
+		GetTracepointVersions("test_tracepoint").
+		Return(nil, nil)
+
+	var tpInfo *storepb.TracepointInfo
+	mockTracepointStore.
+		EXPECT().
+		CreateTracepointVersion(gomock.Any(), time.Second*5, gomock.Any()).
+		DoAndReturn(func(tracepointInfo *storepb.TracepointInfo, ttl time.Duration, version *storepb.TracepointVersion) error {
+			tpInfo = tracepointInfo
+			return nil
+		})
+	mockTracepointStore.
+		EXPECT().
+		UpsertTracepoint(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(tracepointID uuid.UUID, tracepointInfo *storepb.TracepointInfo) error {
+			tpInfo = tracepointInfo
+			return nil
+		})
+	mockTracepointStore.
+		EXPECT().
+		GetTracepoint(gomock.Any()).
+		DoAndReturn(func(tracepointID uuid.UUID) (*storepb.TracepointInfo, error) {
+			return tpInfo, nil
+		})
+
+	// Only the first selected agent is part of the first stage.
+	mockAgtMgr.
+		EXPECT().
+		MessageAgents([]uuid.UUID{agentIDs[1]}, gomock.Any()).
+		Return(nil)
+
+	// Set up server.
+	env, err := metadataenv.New("vizier")
+	if err != nil {
+		t.Fatal("Failed to create api environment.")
+	}
+
+	s := controllers.NewServer(env, nil, mockAgtMgr, tracepointMgr, &fakeHostResolver{nodeIPs: []string{"10.0.0.1", "10.0.0.2"}}, nil, nil)
+
+	req := metadatapb.RegisterTracepointRequest{
+		Requests: []*metadatapb.RegisterTracepointRequest_TracepointRequest{
+			{
+				TracepointDeployment: program,
+				Name:                 "test_tracepoint",
+				TTL: &types.Duration{
+					Seconds: 5,
+				},
+				Selector:      selector,
+				RolloutStages: stages,
+			},
+		},
+	}
+
+	resp, err := s.RegisterTracepoint(context.Background(), &req)
+	require.NoError(t, err)
+	assert.Equal(t, 1, len(resp.Tracepoints))
+	assert.Equal(t, statuspb.OK, resp.Tracepoints[0].Status.ErrCode)
+
+	assert.Equal(t, selector, tpInfo.Selector)
+	assert.Equal(t, storepb.ROLLOUT_STATE_IN_PROGRESS, tpInfo.Rollout.State)
+	assert.Equal(t, []*uuidpb.UUID{utils.ProtoFromUUID(agentIDs[1]), utils.ProtoFromUUID(agentIDs[2])}, tpInfo.Rollout.AgentIDs)
+}
+
+func Test_Server_RegisterTracepoint_InvalidRolloutStages(t *testing.T) {
+	env, err := metadataenv.New("vizier")
+	if err != nil {
+		t.Fatal("Failed to create api environment.")
+	}
+
+	s := controllers.NewServer(env, nil, nil, nil, nil, nil, nil)
+
+	req := metadatapb.RegisterTracepointRequest{
+		Requests: []*metadatapb.RegisterTracepointRequest_TracepointRequest{
+			{
+				Name:          "test_tracepoint",
+				RolloutStages: []*storepb.TracepointRolloutStage{{NumAgents: 1, Percent: 10}},
+			},
+		},
+	}
+
+	_, err = s.RegisterTracepoint(context.Background(), &req)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func Test_Server_GetObjectsWithLabelSelector(t *testing.T) {
+	env, err := metadataenv.New("vizier")
+	require.NoError(t, err)
+
+	ls := &testutils.InMemoryPodLabelStore{}
+	objs := []*storepb.K8SObjectLabels{
+		{ObjectType: "pods", Namespace: "ns2", Name: "web-1", Labels: map[string]string{"app": "web", "env": "prod"}},
+		{ObjectType: "pods", Namespace: "ns1", Name: "web-0", Labels: map[string]string{"app": "web", "env": "prod"}},
+		{ObjectType: "pods", Namespace: "ns1", Name: "db-0", Labels: map[string]string{"app": "db"}},
+		{ObjectType: "services", Namespace: "ns1", Name: "web", Labels: map[string]string{"app": "web"}},
+	}
+	for _, o := range objs {
+		require.NoError(t, ls.SetObjectLabels(o))
+	}
+	s := controllers.NewServer(env, nil, nil, nil, nil, ls, nil)
+
+	tests := []struct {
+		name          string
+		req           *metadatapb.ObjectsWithLabelSelectorRequest
+		expectedNames []string
+		expectedCode  codes.Code
+	}{
+		{
+			name:          "all namespaces",
+			req:           &metadatapb.ObjectsWithLabelSelectorRequest{ObjectType: "pods", Selector: "app in (web), env"},
+			expectedNames: []string{"web-0", "web-1"},
+		},
+		{
+			name:          "single namespace",
+			req:           &metadatapb.ObjectsWithLabelSelectorRequest{ObjectType: "pods", Namespace: "ns1", Selector: "app notin (web)"},
+			expectedNames: []string{"db-0"},
+		},
+		{
+			name:          "services",
+			req:           &metadatapb.ObjectsWithLabelSelectorRequest{ObjectType: "services", Selector: "app"},
+			expectedNames: []string{"web"},
+		},
+		{
+			name:         "unindexed type",
+			req:          &metadatapb.ObjectsWithLabelSelectorRequest{ObjectType: "nodes", Selector: "app"},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "invalid selector",
+			req:          &metadatapb.ObjectsWithLabelSelectorRequest{ObjectType: "pods", Selector: "app in ("},
+			expectedCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp, err := s.GetObjectsWithLabelSelector(context.Background(), test.req)
+			if test.expectedCode != codes.OK {
+				assert.Equal(t, test.expectedCode, status.Code(err))
+				return
+			}
+			require.NoError(t, err)
+			names := make([]string, len(resp.Objects))
+			for i, o := range resp.Objects {
+				names[i] = o.Name
+			}
+			assert.Equal(t, test.expectedNames, names)
+		})
+	}
+}
+
+type fakeK8sHistory struct {
+	entries []*storepb.K8SResourceHistoryEntry
+	pod     *storepb.K8SResourceHistoryEntry
+}
+
+func (f *fakeK8sHistory) FetchResourceHistory(objectType string, namespace string, name string, startNS int64, endNS int64) ([]*storepb.K8SResourceHistoryEntry, error) {
+	var entries []*storepb.K8SResourceHistoryEntry
+	for _, e := range f.entries {
+		if e.TimestampNS >= startNS && (endNS == 0 || e.TimestampNS <= endNS) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+func (f *fakeK8sHistory) FetchRestarts(objectType string, namespace string, name string, startNS int64, endNS int64) ([]*storepb.K8SResourceHistoryEntry, error) {
+	var restarts []*storepb.K8SResourceHistoryEntry
+	for _, r := range k8smeta.FindRestarts(f.entries) {
+		if r.TimestampNS >= startNS && (endNS == 0 || r.TimestampNS <= endNS) {
+			restarts = append(restarts, r)
+		}
+	}
+	return restarts, nil
+}
+
+func (f *fakeK8sHistory) FetchPodByIP(ip string, timeNS int64) (*storepb.K8SResourceHistoryEntry, error) {
+	return f.pod, nil
+}
+
+func deploymentHistoryEntry(uv int64, restartedAt string) *storepb.K8SResourceHistoryEntry {
+	return &storepb.K8SResourceHistoryEntry{
+		UpdateVersion: uv,
+		TimestampNS:   uv * 10,
+		Resource: &storepb.K8SResource{
+			Resource: &storepb.K8SResource_Deployment{
+				Deployment: &k8smetadatapb.Deployment{
+					Metadata: &k8smetadatapb.ObjectMetadata{Name: "web", Namespace: "ns"},
+					Spec: &k8smetadatapb.DeploymentSpec{
+						Template: &k8smetadatapb.PodTemplateSpec{
+							Metadata: &k8smetadatapb.ObjectMetadata{
+								Annotations: map[string]string{"kubectl.kubernetes.io/restartedAt": restartedAt},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func Test_Server_K8SHistory(t *testing.T) {
+	env, err := metadataenv.New("vizier")
+	require.NoError(t, err)
+
+	history := &fakeK8sHistory{
+		entries: []*storepb.K8SResourceHistoryEntry{
+			deploymentHistoryEntry(1, "a"),
+			deploymentHistoryEntry(2, "b"),
+			deploymentHistoryEntry(3, "b"),
+			deploymentHistoryEntry(4, "c"),
+		},
+	}
+	s := controllers.NewServer(env, nil, nil, nil, nil, nil, history)
+	ctx := context.Background()
+
+	histResp, err := s.GetK8SObjectHistory(ctx, &metadatapb.K8SObjectHistoryRequest{
+		ObjectType:  "deployments",
+		Namespace:   "ns",
+		Name:        "web",
+		StartTimeNS: 20,
+		EndTimeNS:   30,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, history.entries[1:3], histResp.Entries)
+
+	_, err = s.GetK8SObjectHistory(ctx, &metadatapb.K8SObjectHistoryRequest{ObjectType: "deployments"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// The restart at version 2 is found by comparing it with version 1, which is before the start time.
+	restartsResp, err := s.GetK8SObjectRestarts(ctx, &metadatapb.K8SObjectRestartsRequest{
+		ObjectType:  "deployments",
+		Namespace:   "ns",
+		Name:        "web",
+		StartTimeNS: 20,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []*storepb.K8SResourceHistoryEntry{history.entries[1], history.entries[3]}, restartsResp.Restarts)
+
+	_, err = s.GetK8SObjectRestarts(ctx, &metadatapb.K8SObjectRestartsRequest{ObjectType: "services", Name: "web"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	diffResp, err := s.GetK8SObjectDiff(ctx, &metadatapb.K8SObjectDiffRequest{
+		ObjectType:  "deployments",
+		Namespace:   "ns",
+		Name:        "web",
+		FromVersion: 1,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), diffResp.From.UpdateVersion)
+	assert.Equal(t, int64(4), diffResp.To.UpdateVersion)
+	assert.Contains(t, diffResp.Diff, "+++ version 4")
+
+	_, err = s.GetK8SObjectDiff(ctx, &metadatapb.K8SObjectDiffRequest{
+		ObjectType:  "deployments",
+		Namespace:   "ns",
+		Name:        "web",
+		FromVersion: 1,
+		ToVersion:   7,
+	})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = s.GetPodByIP(ctx, &metadatapb.PodByIPRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	podResp, err := s.GetPodByIP(ctx, &metadatapb.PodByIPRequest{IP: "10.0.0.1"})
+	require.NoError(t, err)
+	assert.Nil(t, podResp.Pod)
+}
+
 func Test_Server_GetTracepointInfo(t *testing.T) {
 	tests := []struct {
 		name             string
@@ -632,13 +1091,23 @@ func Test_Server_GetTracepointInfo(t *testing.T) {
 					EXPECT().
 					GetTracepointsForIDs([]uuid.UUID{tID}).
 					Return([]*storepb.TracepointInfo{nil}, nil)
+				// Used to compute the tracepoint usage.
+				mockTracepointStore.
+					EXPECT().
+					GetTracepoints().
+					Return(nil, nil)
 			} else {
 				if test.expectAll {
 					mockTracepointStore.
 						EXPECT().
 						GetTracepoints().
-						Return([]*storepb.TracepointInfo{{ID: utils.ProtoFromUUID(tID), Tracepoint: program, ExpectedState: statuspb.RUNNING_STATE}}, nil)
+						Return([]*storepb.TracepointInfo{{ID: utils.ProtoFromUUID(tID), Tracepoint: program, ExpectedState: statuspb.RUNNING_STATE}}, nil).
+						Times(2)
 				} else {
+					mockTracepointStore.
+						EXPECT().
+						GetTracepoints().
+						Return([]*storepb.TracepointInfo{{ID: utils.ProtoFromUUID(tID), Tracepoint: program, ExpectedState: statuspb.RUNNING_STATE}}, nil)
 					mockTracepointStore.
 						EXPECT().
 						GetTracepointsForIDs([]uuid.UUID{tID}).
@@ -657,7 +1126,7 @@ func Test_Server_GetTracepointInfo(t *testing.T) {
 				t.Fatal("Failed to create api environment.")
 			}
 
-			s := controllers.NewServer(env, nil, mockAgtMgr, tracepointMgr)
+			s := controllers.NewServer(env, nil, mockAgtMgr, tracepointMgr, nil, nil, nil)
 			req := metadatapb.GetTracepointInfoRequest{
 				IDs: []*uuidpb.UUID{utils.ProtoFromUUID(tID)},
 			}
@@ -680,11 +1149,48 @@ func Test_Server_GetTracepointInfo(t *testing.T) {
 			if test.tracepointExists {
 				assert.Equal(t, statuspb.RUNNING_STATE, resp.Tracepoints[0].ExpectedState)
 				assert.Equal(t, []string{"table1", "test"}, resp.Tracepoints[0].SchemaNames)
+				assert.Equal(t, int64(1), resp.Usage.ActiveTracepoints)
 			}
 		})
 	}
 }
 
+func Test_Server_RecordAgentQueryFailures(t *testing.T) {
+	// Set up mock.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockAgtMgr := mock_agent.NewMockManager(ctrl)
+
+	agentID1 := uuid.Must(uuid.NewV4())
+	agentID2 := uuid.Must(uuid.NewV4())
+
+	mockAgtMgr.
+		EXPECT().
+		RecordQueryFailures([]uuid.UUID{agentID1, agentID2})
+
+	// Set up server.
+	env, err := metadataenv.New("vizier")
+	if err != nil {
+		t.Fatal("Failed to create api environment.")
+	}
+
+	s := controllers.NewServer(env, nil, mockAgtMgr, nil, nil, nil, nil)
+
+	req := metadatapb.RecordAgentQueryFailuresRequest{
+		QueryID:  utils.ProtoFromUUID(uuid.Must(uuid.NewV4())),
+		AgentIDs: []*uuidpb.UUID{utils.ProtoFromUUID(agentID1), utils.ProtoFromUUID(agentID2)},
+	}
+
+	resp, err := s.RecordAgentQueryFailures(context.Background(), &req)
+	require.NoError(t, err)
+	assert.NotNil(t, resp)
+
+	_, err = s.RecordAgentQueryFailures(context.Background(), &metadatapb.RecordAgentQueryFailuresRequest{
+		AgentIDs: []*uuidpb.UUID{{}},
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
 func Test_Server_RemoveTracepoint(t *testing.T) {
 	// Set up mock.
 	ctrl := gomock.NewController(t)
@@ -715,7 +1221,7 @@ func Test_Server_RemoveTracepoint(t *testing.T) {
 		t.Fatal("Failed to create api environment.")
 	}
 
-	s := controllers.NewServer(env, nil, mockAgtMgr, tracepointMgr)
+	s := controllers.NewServer(env, nil, mockAgtMgr, tracepointMgr, nil, nil, nil)
 
 	req := metadatapb.RemoveTracepointRequest{
 		Names: []string{"test1", "test2"},
@@ -901,7 +1407,7 @@ func TestGetAgentUpdates(t *testing.T) {
 		t.Fatal("Failed to create api environment.")
 	}
 
-	srv := controllers.NewServer(mdEnv, nil, mockAgtMgr, nil)
+	srv := controllers.NewServer(mdEnv, nil, mockAgtMgr, nil, nil, nil, nil)
 
 	env := env.New("withpixie.ai")
 	s := server.CreateGRPCServer(env, &server.GRPCServerOptions{})
@@ -1062,7 +1568,7 @@ func Test_Server_UpdateConfig(t *testing.T) {
 		t.Fatal("Failed to create api environment.")
 	}
 
-	s := controllers.NewServer(env, nil, mockAgtMgr, tracepointMgr)
+	s := controllers.NewServer(env, nil, mockAgtMgr, tracepointMgr, nil, nil, nil)
 
 	req := metadatapb.UpdateConfigRequest{
 		AgentPodName: "pl/pem-1234",
@@ -0,0 +1,124 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package controllers
+
+import (
+	"bufio"
+	"errors"
+	"io"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"px.dev/pixie/src/vizier/services/metadata/metadatapb"
+	"px.dev/pixie/src/vizier/utils/datastore/snapshot"
+)
+
+// snapshotChunkSize is the maximum size of each chunk of a streamed snapshot archive.
+const snapshotChunkSize = 1024 * 1024
+
+// snapshotChunkWriter sends each write as a chunk of the archive.
+type snapshotChunkWriter struct {
+	srv metadatapb.MetadataAdminService_CreateSnapshotServer
+}
+
+func (w *snapshotChunkWriter) Write(p []byte) (int, error) {
+	data := make([]byte, len(p))
+	copy(data, p)
+	if err := w.srv.Send(&metadatapb.CreateSnapshotResponse{Data: data}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// snapshotChunkReader reads the chunks of an archive from the stream.
+type snapshotChunkReader struct {
+	srv   metadatapb.MetadataAdminService_RestoreSnapshotServer
+	first *metadatapb.RestoreSnapshotRequest
+	buf   []byte
+}
+
+func (r *snapshotChunkReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.first != nil {
+			r.buf = r.first.Data
+			r.first = nil
+			continue
+		}
+		req, err := r.srv.Recv()
+		if err != nil {
+			return 0, err
+		}
+		r.buf = req.Data
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func snapshotErrorToStatus(err error) error {
+	if errors.Is(err, snapshot.ErrInvalidArchive) || errors.Is(err, snapshot.ErrUnsupportedVersion) ||
+		errors.Is(err, snapshot.ErrChecksumMismatch) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return err
+}
+
+// CreateSnapshot streams a snapshot archive of the keys in the datastore.
+func (s *Server) CreateSnapshot(req *metadatapb.CreateSnapshotRequest, srv metadatapb.MetadataAdminService_CreateSnapshotServer) error {
+	prefixes := req.Prefixes
+	if len(prefixes) == 0 {
+		prefixes = snapshot.DefaultPrefixes
+	}
+
+	w := bufio.NewWriterSize(&snapshotChunkWriter{srv: srv}, snapshotChunkSize)
+	manifest, err := snapshot.Write(w, s.ds, prefixes)
+	if err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	log.WithField("entries", manifest.Entries).WithField("checksum", manifest.Checksum).Info("Created datastore snapshot")
+	return srv.Send(&metadatapb.CreateSnapshotResponse{Manifest: manifest.ToProto()})
+}
+
+// RestoreSnapshot verifies a streamed snapshot archive. The server keeps state in memory, such as
+// the agents and tracepoints it manages, which would not match a datastore restored under it. So only
+// dry runs are accepted, and archives are restored with md_snapshot while the service is stopped.
+func (s *Server) RestoreSnapshot(srv metadatapb.MetadataAdminService_RestoreSnapshotServer) error {
+	first, err := srv.Recv()
+	if err == io.EOF {
+		return status.Error(codes.InvalidArgument, "empty snapshot archive")
+	}
+	if err != nil {
+		return err
+	}
+	if !first.DryRun {
+		return status.Error(codes.FailedPrecondition,
+			"a running metadata service can't be restored, stop it and restore its datastore with md_snapshot")
+	}
+
+	manifest, err := snapshot.Verify(&snapshotChunkReader{srv: srv, first: first})
+	if err != nil {
+		return snapshotErrorToStatus(err)
+	}
+	return srv.SendAndClose(&metadatapb.RestoreSnapshotResponse{Manifest: manifest.ToProto()})
+}
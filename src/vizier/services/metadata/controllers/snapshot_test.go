@@ -0,0 +1,162 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package controllers_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	grpc_metadata "google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"px.dev/pixie/src/shared/services/env"
+	"px.dev/pixie/src/shared/services/server"
+	"px.dev/pixie/src/utils/testingutils"
+	"px.dev/pixie/src/vizier/services/metadata/controllers"
+	"px.dev/pixie/src/vizier/services/metadata/metadataenv"
+	"px.dev/pixie/src/vizier/services/metadata/metadatapb"
+	"px.dev/pixie/src/vizier/utils/datastore/pebbledb"
+)
+
+func setupAdminServer(t *testing.T) (metadatapb.MetadataAdminServiceClient, *pebbledb.DataStore, context.Context, func()) {
+	viper.Set("jwt_signing_key", "jwtkey")
+
+	memFS := vfs.NewMem()
+	c, err := pebble.Open("test", &pebble.Options{
+		FS: memFS,
+	})
+	if err != nil {
+		t.Fatal("failed to initialize a pebbledb")
+	}
+	db := pebbledb.New(c, 3*time.Second)
+
+	mdEnv, err := metadataenv.New("test")
+	if err != nil {
+		t.Fatal("Failed to create api environment.")
+	}
+	srv := controllers.NewServer(mdEnv, db, nil, nil, nil, nil, nil)
+
+	s := server.CreateGRPCServer(env.New("withpixie.ai"), &server.GRPCServerOptions{})
+	metadatapb.RegisterMetadataAdminServiceServer(s, srv)
+	lis := bufconn.Listen(1024 * 1024)
+
+	eg := errgroup.Group{}
+	eg.Go(func() error { return s.Serve(lis) })
+
+	ctx := context.Background()
+	conn, err := grpc.DialContext(ctx, "bufnet", grpc.WithContextDialer(createDialer(lis)), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to dial bufnet: %v", err)
+	}
+
+	validTestToken := testingutils.GenerateTestJWTToken(t, viper.GetString("jwt_signing_key"))
+	ctx = grpc_metadata.AppendToOutgoingContext(ctx, "authorization",
+		fmt.Sprintf("bearer %s", validTestToken))
+
+	return metadatapb.NewMetadataAdminServiceClient(conn), db, ctx, func() {
+		conn.Close()
+		s.GracefulStop()
+		err := eg.Wait()
+		if err != nil {
+			t.Fatalf("failed to start server: %v", err)
+		}
+		db.Close()
+	}
+}
+
+func createSnapshot(ctx context.Context, t *testing.T, client metadatapb.MetadataAdminServiceClient) ([]byte, *metadatapb.SnapshotManifest) {
+	stream, err := client.CreateSnapshot(ctx, &metadatapb.CreateSnapshotRequest{})
+	require.NoError(t, err)
+
+	var archive []byte
+	var manifest *metadatapb.SnapshotManifest
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		archive = append(archive, resp.Data...)
+		if resp.Manifest != nil {
+			manifest = resp.Manifest
+		}
+	}
+	return archive, manifest
+}
+
+func restoreSnapshot(ctx context.Context, t *testing.T, client metadatapb.MetadataAdminServiceClient, archive []byte, req *metadatapb.RestoreSnapshotRequest) (*metadatapb.RestoreSnapshotResponse, error) {
+	stream, err := client.RestoreSnapshot(ctx)
+	require.NoError(t, err)
+
+	// Split the archive across messages to exercise reassembly.
+	req.Data = archive[:len(archive)/2]
+	require.NoError(t, stream.Send(req))
+	require.NoError(t, stream.Send(&metadatapb.RestoreSnapshotRequest{Data: archive[len(archive)/2:]}))
+	return stream.CloseAndRecv()
+}
+
+func Test_Server_SnapshotRestore(t *testing.T) {
+	client, db, ctx, cleanup := setupAdminServer(t)
+	defer cleanup()
+
+	require.NoError(t, db.Set("/agent/1", "agent1"))
+	require.NoError(t, db.Set("/cronScript/1", "script1"))
+	// Keys with a TTL are restored with their remaining TTL.
+	require.NoError(t, db.SetWithTTL("/tracepointTTL/1", "", time.Hour))
+
+	archive, manifest := createSnapshot(ctx, t, client)
+	require.NotNil(t, manifest)
+	assert.Equal(t, int64(3), manifest.Entries)
+	assert.Equal(t, []*metadatapb.SnapshotManifest_Section{{Prefix: "/", Entries: 3}}, manifest.Sections)
+
+	require.NoError(t, db.DeleteWithPrefix("/"))
+	require.NoError(t, db.Set("/agent/2", "stale"))
+
+	// A dry run verifies the archive without restoring it.
+	resp, err := restoreSnapshot(ctx, t, client, archive, &metadatapb.RestoreSnapshotRequest{DryRun: true})
+	require.NoError(t, err)
+	assert.Equal(t, manifest, resp.Manifest)
+	keys, _, err := db.GetWithPrefix("/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/agent/2"}, keys)
+
+	// The running service refuses to be restored.
+	_, err = restoreSnapshot(ctx, t, client, archive, &metadatapb.RestoreSnapshotRequest{Clear: true})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	keys, _, err = db.GetWithPrefix("/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/agent/2"}, keys)
+
+	archive[len(archive)/2] ^= 0xff
+	_, err = restoreSnapshot(ctx, t, client, archive, &metadatapb.RestoreSnapshotRequest{DryRun: true})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
@@ -400,6 +400,8 @@ heartbeat_ack {
   update_info {
     service_cidr: "10.64.4.0/22"
     pod_cidrs: "10.64.4.0/21"
+    allowed_namespaces: "ns1"
+    denied_namespaces: "kube-system"
   }
 }
 `
@@ -906,3 +908,164 @@ status {
 	}
 }
 `
+
+// StatefulSetPb is a protobuf for a StatefulSet object.
+const StatefulSetPb = `
+metadata {
+	name: "statefulset_1"
+	namespace: "a_namespace"
+	uid: "ss_uid"
+	creation_timestamp_ns: 4
+	deletion_timestamp_ns: 6
+}
+spec {
+	replicas: 3
+	selector {
+		match_labels {
+			key: "app"
+			value: "db"
+		}
+	}
+	service_name: "db"
+	pod_management_policy: "OrderedReady"
+	update_strategy: "RollingUpdate"
+}
+status {
+	observed_generation: 2
+	replicas: 3
+	ready_replicas: 2
+	current_replicas: 3
+	updated_replicas: 1
+	available_replicas: 2
+}
+`
+
+// DaemonSetPb is a protobuf for a DaemonSet object.
+const DaemonSetPb = `
+metadata {
+	name: "daemonset_1"
+	namespace: "a_namespace"
+	uid: "ds_uid"
+	creation_timestamp_ns: 4
+	deletion_timestamp_ns: 6
+}
+spec {
+	selector {
+		match_labels {
+			key: "app"
+			value: "agent"
+		}
+	}
+	update_strategy: "RollingUpdate"
+}
+status {
+	current_number_scheduled: 3
+	desired_number_scheduled: 3
+	number_ready: 2
+	number_available: 2
+	number_unavailable: 1
+}
+`
+
+// JobPb is a protobuf for a Job object owned by a CronJob.
+const JobPb = `
+metadata {
+	name: "job_1"
+	namespace: "a_namespace"
+	uid: "job_uid"
+	creation_timestamp_ns: 4
+	deletion_timestamp_ns: 6
+	owner_references {
+		kind: "CronJob"
+		name: "cronjob_1"
+		uid: "cj_uid"
+	}
+}
+spec {
+	parallelism: 2
+	completions: 4
+}
+status {
+	start_time_ns: 5
+	active: 2
+	succeeded: 1
+	failed: 1
+}
+`
+
+// CronJobPb is a protobuf for a CronJob object.
+const CronJobPb = `
+metadata {
+	name: "cronjob_1"
+	namespace: "a_namespace"
+	uid: "cj_uid"
+	creation_timestamp_ns: 4
+	deletion_timestamp_ns: 6
+}
+spec {
+	schedule: "*/5 * * * *"
+	concurrency_policy: "Forbid"
+}
+status {
+	active {
+		kind: "Job"
+		namespace: "a_namespace"
+		name: "job_1"
+		uid: "job_uid"
+	}
+	last_schedule_time_ns: 5
+}
+`
+
+// IngressPb is a protobuf for an Ingress object.
+const IngressPb = `
+metadata {
+	name: "ingress_1"
+	namespace: "a_namespace"
+	uid: "ing_uid"
+	creation_timestamp_ns: 4
+	deletion_timestamp_ns: 6
+}
+spec {
+	default_backend {
+		service_name: "default"
+		service_port: "80"
+	}
+	rules {
+		host: "example.com"
+		paths {
+			path: "/api"
+			path_type: "Prefix"
+			backend {
+				service_name: "api"
+				service_port: "http"
+			}
+		}
+		paths {
+			path: "/"
+			path_type: "Prefix"
+			backend {
+				service_name: "default"
+				service_port: "80"
+			}
+		}
+	}
+}
+`
+
+// OwnerObjectPb is a protobuf for a custom resource which owns other objects.
+const OwnerObjectPb = `
+metadata {
+	name: "rollout_1"
+	namespace: "a_namespace"
+	uid: "rollout_uid"
+	creation_timestamp_ns: 4
+	deletion_timestamp_ns: 6
+	labels {
+		key: "app"
+		value: "web"
+	}
+}
+api_version: "argoproj.io/v1alpha1"
+kind: "Rollout"
+`
@@ -21,11 +21,17 @@ package testutils
 import (
 	"path"
 	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+
+	"px.dev/pixie/src/vizier/services/metadata/storepb"
 )
 
-// InMemoryPodLabelStore implements the PodLabelStore interface for testing.
+// InMemoryPodLabelStore implements the LabelStore interface for testing.
 type InMemoryPodLabelStore struct {
 	Store map[string]string
+	// Objects contains the labels of each object, keyed by <objectType>/<namespace>/<name>.
+	Objects map[string]*storepb.K8SObjectLabels
 }
 
 const (
@@ -92,3 +98,33 @@ func (s *InMemoryPodLabelStore) FetchPodsWithLabels(namespace string, labels map
 	}
 	return result, nil
 }
+
+// SetObjectLabels stores the labels and annotations of an object.
+func (s *InMemoryPodLabelStore) SetObjectLabels(obj *storepb.K8SObjectLabels) error {
+	if s.Objects == nil {
+		s.Objects = make(map[string]*storepb.K8SObjectLabels)
+	}
+	s.Objects[path.Join(obj.ObjectType, obj.Namespace, obj.Name)] = obj
+	return nil
+}
+
+// DeleteObjectLabels deletes the labels and annotations of an object.
+func (s *InMemoryPodLabelStore) DeleteObjectLabels(objectType string, namespace string, name string) error {
+	delete(s.Objects, path.Join(objectType, namespace, name))
+	return nil
+}
+
+// FetchObjectsWithSelector gets the objects of the given type in the namespace whose labels match the
+// selector. Objects in all namespaces are fetched if the namespace is empty.
+func (s *InMemoryPodLabelStore) FetchObjectsWithSelector(objectType string, namespace string, selector labels.Selector) ([]*storepb.K8SObjectLabels, error) {
+	var result []*storepb.K8SObjectLabels
+	for _, obj := range s.Objects {
+		if obj.ObjectType != objectType || (namespace != "" && obj.Namespace != namespace) {
+			continue
+		}
+		if selector.Matches(labels.Set(obj.Labels)) {
+			result = append(result, obj)
+		}
+	}
+	return result, nil
+}
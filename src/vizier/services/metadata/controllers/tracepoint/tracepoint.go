@@ -19,6 +19,7 @@
 package tracepoint
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sync"
@@ -26,6 +27,7 @@ import (
 
 	"github.com/gofrs/uuid"
 	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
 	log "github.com/sirupsen/logrus"
 
 	"px.dev/pixie/src/api/proto/uuidpb"
@@ -40,6 +42,11 @@ var (
 	// ErrTracepointAlreadyExists is produced if a tracepoint already exists with the given name
 	// and does not have a matching schema.
 	ErrTracepointAlreadyExists = errors.New("TracepointDeployment already exists")
+	// ErrTracepointVersionNotFound is produced if a rollback targets a version that is not retained.
+	ErrTracepointVersionNotFound = errors.New("TracepointDeployment version not found")
+	// ErrTracepointSchemaMismatch is produced if a rollback targets a version whose output tables
+	// are incompatible with the output tables of the currently deployed version.
+	ErrTracepointSchemaMismatch = errors.New("TracepointDeployment output table schema mismatch")
 )
 
 // agentMessenger is a controller that lets us message all agents and all active agents.
@@ -63,6 +70,15 @@ type Store interface {
 	DeleteTracepoint(uuid.UUID) error
 	DeleteTracepointsForAgent(uuid.UUID) error
 	GetTracepointTTLs() ([]uuid.UUID, []time.Time, error)
+	CreateTracepointVersion(*storepb.TracepointInfo, time.Duration, *storepb.TracepointVersion) error
+	GetTracepointVersions(string) ([]*storepb.TracepointVersion, error)
+	GetTracepointVersion(string, int64) (*storepb.TracepointVersion, error)
+}
+
+// ttlWatcher is a Store which can stream the deletes of tracepoint TTLs, so that expired tracepoints
+// are terminated without polling.
+type ttlWatcher interface {
+	WatchTracepointTTLs(ctx context.Context) (<-chan uuid.UUID, error)
 }
 
 // Manager manages the tracepoints deployed in the cluster.
@@ -70,6 +86,17 @@ type Manager struct {
 	ts     Store
 	agtMgr agentMessenger
 
+	policy   *Policy
+	policyMu sync.RWMutex
+	// createMu serializes tracepoint creation, so that the quota is checked against the tracepoints
+	// that exist when the new one is created.
+	createMu sync.Mutex
+
+	// tracepointLocks serializes the read-modify-write updates of each tracepoint, such as rollout
+	// progress reported by several agents at once.
+	tracepointLocks   map[uuid.UUID]*sync.Mutex
+	tracepointLocksMu sync.Mutex
+
 	done chan struct{}
 	once sync.Once
 }
@@ -77,28 +104,96 @@ type Manager struct {
 // NewManager creates a new tracepoint manager.
 func NewManager(ts Store, agtMgr agentMessenger, ttlReaperDuration time.Duration) *Manager {
 	tm := &Manager{
-		ts:     ts,
-		agtMgr: agtMgr,
-		done:   make(chan struct{}),
+		ts:              ts,
+		agtMgr:          agtMgr,
+		tracepointLocks: make(map[uuid.UUID]*sync.Mutex),
+		done:            make(chan struct{}),
 	}
 
 	go tm.watchForTracepointExpiry(ttlReaperDuration)
 	return tm
 }
 
+// watchForTracepointExpiry terminates tracepoints once their TTLs expire. If the store can be
+// watched, tracepoints are terminated as soon as their TTL keys are deleted, and the store is only
+// swept when the watch starts, to catch expiries which happened while it wasn't running. Otherwise,
+// the store is swept every ttlReaperDuration.
 func (m *Manager) watchForTracepointExpiry(ttlReaperDuration time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
 	ticker := time.NewTicker(ttlReaperDuration)
 	defer ticker.Stop()
+
+	expired := m.watchTracepointTTLs(ctx)
+	if expired != nil {
+		m.terminateExpiredTracepoints()
+	}
 	for {
 		select {
 		case <-m.done:
 			return
+		case id, ok := <-expired:
+			if !ok {
+				expired = nil
+				continue
+			}
+			m.terminateExpiredTracepoint(id)
 		case <-ticker.C:
+			if expired != nil {
+				continue
+			}
+			expired = m.watchTracepointTTLs(ctx)
 			m.terminateExpiredTracepoints()
 		}
 	}
 }
 
+// watchTracepointTTLs starts a watch on the tracepoint TTLs, or returns nil if the store can't be
+// watched.
+func (m *Manager) watchTracepointTTLs(ctx context.Context) <-chan uuid.UUID {
+	w, ok := m.ts.(ttlWatcher)
+	if !ok {
+		return nil
+	}
+	expired, err := w.WatchTracepointTTLs(ctx)
+	if err != nil {
+		log.WithError(err).Warn("Failed to watch tracepoint TTLs")
+		return nil
+	}
+	return expired
+}
+
+// terminateExpiredTracepoint terminates the tracepoint whose TTL was deleted, unless it is already
+// terminated or its TTL was renewed.
+func (m *Manager) terminateExpiredTracepoint(id uuid.UUID) {
+	tp, err := m.ts.GetTracepoint(id)
+	if err != nil {
+		log.WithError(err).Warn("error encountered when trying to terminating expired tracepoints")
+		return
+	}
+	if tp == nil || tp.ExpectedState == statuspb.TERMINATED_STATE {
+		return
+	}
+
+	ttlKeys, ttlVals, err := m.ts.GetTracepointTTLs()
+	if err != nil {
+		log.WithError(err).Warn("error encountered when trying to terminating expired tracepoints")
+		return
+	}
+	now := time.Now()
+	for i, tpID := range ttlKeys {
+		if tpID == id && ttlVals[i].After(now) {
+			return
+		}
+	}
+
+	err = m.terminateTracepoint(id)
+	if err != nil {
+		log.WithError(err).Warn("error encountered when trying to terminating expired tracepoints")
+	}
+}
+
 func (m *Manager) terminateExpiredTracepoints() {
 	tps, err := m.ts.GetTracepoints()
 	if err != nil {
@@ -137,7 +232,29 @@ func (m *Manager) terminateExpiredTracepoints() {
 	}
 }
 
+// lockTracepoint locks the tracepoint with the given ID for a read-modify-write, and returns the
+// function that unlocks it.
+func (m *Manager) lockTracepoint(id uuid.UUID) func() {
+	m.tracepointLocksMu.Lock()
+	l, ok := m.tracepointLocks[id]
+	if !ok {
+		l = &sync.Mutex{}
+		m.tracepointLocks[id] = l
+	}
+	m.tracepointLocksMu.Unlock()
+
+	l.Lock()
+	return l.Unlock
+}
+
 func (m *Manager) terminateTracepoint(id uuid.UUID) error {
+	unlock := m.lockTracepoint(id)
+	defer unlock()
+	return m.terminateTracepointLocked(id)
+}
+
+// terminateTracepointLocked terminates the tracepoint. The caller must hold the tracepoint's lock.
+func (m *Manager) terminateTracepointLocked(id uuid.UUID) error {
 	// Update state in datastore to terminated.
 	tp, err := m.ts.GetTracepoint(id)
 	if err != nil {
@@ -175,11 +292,63 @@ func (m *Manager) terminateTracepoint(id uuid.UUID) error {
 }
 
 func (m *Manager) deleteTracepoint(id uuid.UUID) error {
-	return m.ts.DeleteTracepoint(id)
+	err := m.ts.DeleteTracepoint(id)
+	if err != nil {
+		return err
+	}
+	m.tracepointLocksMu.Lock()
+	delete(m.tracepointLocks, id)
+	m.tracepointLocksMu.Unlock()
+	return nil
+}
+
+// getActiveTracepointWithName gets the tracepoint with the given name, if one exists and is not terminated.
+func (m *Manager) getActiveTracepointWithName(tracepointName string) (*storepb.TracepointInfo, error) {
+	resp, err := m.ts.GetTracepointsWithNames([]string{tracepointName})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp) != 1 {
+		return nil, errors.New("Could not fetch tracepoint")
+	}
+	if resp[0] == nil {
+		return nil, nil
+	}
+
+	tp, err := m.ts.GetTracepoint(*resp[0])
+	if err != nil {
+		return nil, err
+	}
+	if tp == nil || tp.ExpectedState == statuspb.TERMINATED_STATE {
+		return nil, nil
+	}
+	return tp, nil
+}
+
+// CreateTracepoint creates and stores info about the given tracepoint, registered by the given owner.
+// The selector and rollout stages are recorded with the new version, so that rollbacks deploy the
+// tracepoint the same way.
+func (m *Manager) CreateTracepoint(tracepointName string, tracepointDeployment *logicalpb.TracepointDeployment, ttl time.Duration, owner string, selector *storepb.TracepointSelector, stages []*storepb.TracepointRolloutStage) (*uuid.UUID, error) {
+	tp, err := m.createTracepoint(tracepointName, tracepointDeployment, ttl, owner, selector, stages, 0)
+	if tp == nil {
+		return nil, err
+	}
+	tpID := utils.UUIDFromProtoOrNil(tp.ID)
+	return &tpID, err
 }
 
-// CreateTracepoint creates and stores info about the given tracepoint.
-func (m *Manager) CreateTracepoint(tracepointName string, tracepointDeployment *logicalpb.TracepointDeployment, ttl time.Duration) (*uuid.UUID, error) {
+// createTracepoint creates the tracepoint and records a new version for it. rollbackOfVersion is
+// the version being rolled back to, or 0 if this is not a rollback.
+func (m *Manager) createTracepoint(tracepointName string, tracepointDeployment *logicalpb.TracepointDeployment, ttl time.Duration, owner string, selector *storepb.TracepointSelector, stages []*storepb.TracepointRolloutStage, rollbackOfVersion int64) (*storepb.TracepointInfo, error) {
+	m.createMu.Lock()
+	defer m.createMu.Unlock()
+
+	err := m.checkPolicy(owner, tracepointName, tracepointDeployment, ttl)
+	if err != nil {
+		return nil, err
+	}
+
 	// Check to see if a tracepoint with the matching name already exists.
 	resp, err := m.ts.GetTracepointsWithNames([]string{tracepointName})
 	if err != nil {
@@ -191,8 +360,9 @@ func (m *Manager) CreateTracepoint(tracepointName string, tracepointDeployment *
 	}
 	prevTracepointID := resp[0]
 
+	var prevTracepoint *storepb.TracepointInfo
 	if prevTracepointID != nil { // Existing tracepoint already exists.
-		prevTracepoint, err := m.ts.GetTracepoint(*prevTracepointID)
+		prevTracepoint, err = m.ts.GetTracepoint(*prevTracepointID)
 		if err != nil {
 			return nil, err
 		}
@@ -223,7 +393,8 @@ func (m *Manager) CreateTracepoint(tracepointName string, tracepointDeployment *
 				if err != nil {
 					return nil, err
 				}
-				return prevTracepointID, ErrTracepointAlreadyExists
+				prevTracepoint.ID = utils.ProtoFromUUID(*prevTracepointID)
+				return prevTracepoint, ErrTracepointAlreadyExists
 			}
 
 			// Something has changed, so trigger termination of the old tracepoint.
@@ -234,6 +405,22 @@ func (m *Manager) CreateTracepoint(tracepointName string, tracepointDeployment *
 		}
 	}
 
+	// The new version is diffed against the latest retained version. Tracepoints created before
+	// versions were tracked have no versions, so fall back to the previous deployment.
+	versions, err := m.ts.GetTracepointVersions(tracepointName)
+	if err != nil {
+		return nil, err
+	}
+	version := int64(1)
+	var prevPrograms []*logicalpb.TracepointDeployment_TracepointProgram
+	if len(versions) > 0 {
+		latest := versions[len(versions)-1]
+		version = latest.Version + 1
+		prevPrograms = latest.Tracepoint.GetPrograms()
+	} else if prevTracepoint != nil {
+		prevPrograms = prevTracepoint.Tracepoint.GetPrograms()
+	}
+
 	tpID, err := uuid.NewV4()
 	if err != nil {
 		return nil, err
@@ -243,20 +430,127 @@ func (m *Manager) CreateTracepoint(tracepointName string, tracepointDeployment *
 		Tracepoint:    tracepointDeployment,
 		Name:          tracepointName,
 		ExpectedState: statuspb.RUNNING_STATE,
+		Version:       version,
+		Selector:      selector,
+		Owner:         owner,
 	}
-	err = m.ts.UpsertTracepoint(tpID, newTracepoint)
+	err = m.ts.CreateTracepointVersion(newTracepoint, ttl, &storepb.TracepointVersion{
+		Name:              tracepointName,
+		Version:           version,
+		ID:                utils.ProtoFromUUID(tpID),
+		Tracepoint:        tracepointDeployment,
+		CreateTime:        types.TimestampNow(),
+		Diff:              diffTracepointPrograms(prevPrograms, tracepointDeployment.GetPrograms()),
+		RollbackOfVersion: rollbackOfVersion,
+		Selector:          selector,
+		RolloutStages:     stages,
+	})
 	if err != nil {
 		return nil, err
 	}
-	err = m.ts.SetTracepointTTL(tpID, ttl)
+	return newTracepoint, nil
+}
+
+// diffTracepointPrograms describes the changes between two sets of programs, keyed by output table.
+func diffTracepointPrograms(prev, next []*logicalpb.TracepointDeployment_TracepointProgram) []*storepb.TracepointProgramDiff {
+	prevByTable := make(map[string]*logicalpb.TracepointDeployment_TracepointProgram)
+	for _, p := range prev {
+		prevByTable[p.TableName] = p
+	}
+
+	var diff []*storepb.TracepointProgramDiff
+	nextTables := make(map[string]bool)
+	for _, p := range next {
+		nextTables[p.TableName] = true
+		prevProgram, ok := prevByTable[p.TableName]
+		if !ok {
+			diff = append(diff, &storepb.TracepointProgramDiff{TableName: p.TableName, Change: storepb.TP_PROGRAM_ADDED})
+		} else if !proto.Equal(p, prevProgram) {
+			diff = append(diff, &storepb.TracepointProgramDiff{TableName: p.TableName, Change: storepb.TP_PROGRAM_MODIFIED})
+		}
+	}
+	for _, p := range prev {
+		if !nextTables[p.TableName] {
+			diff = append(diff, &storepb.TracepointProgramDiff{TableName: p.TableName, Change: storepb.TP_PROGRAM_REMOVED})
+		}
+	}
+	return diff
+}
+
+// outputFields gets the fields of the output that a PxL tracepoint program writes to its table.
+// The schema of bpftrace programs is only known once they are deployed, so it cannot be checked.
+func outputFields(p *logicalpb.TracepointDeployment_TracepointProgram) ([]string, bool) {
+	if p.Spec == nil {
+		return nil, false
+	}
+	for _, o := range p.Spec.Outputs {
+		if o.Name == p.TableName {
+			return o.Fields, true
+		}
+	}
+	return nil, false
+}
+
+// checkOutputSchemasCompatible checks that every output table shared between the two sets of
+// programs has the same schema, since agents cannot change the schema of an existing table.
+func checkOutputSchemasCompatible(current, target []*logicalpb.TracepointDeployment_TracepointProgram) error {
+	currentByTable := make(map[string]*logicalpb.TracepointDeployment_TracepointProgram)
+	for _, p := range current {
+		currentByTable[p.TableName] = p
+	}
+
+	for _, p := range target {
+		currentProgram, ok := currentByTable[p.TableName]
+		if !ok {
+			continue
+		}
+		targetFields, targetOk := outputFields(p)
+		currentFields, currentOk := outputFields(currentProgram)
+		if !targetOk || !currentOk {
+			continue
+		}
+		if len(targetFields) != len(currentFields) {
+			return fmt.Errorf("%w: table %s", ErrTracepointSchemaMismatch, p.TableName)
+		}
+		for i := range targetFields {
+			if targetFields[i] != currentFields[i] {
+				return fmt.Errorf("%w: table %s", ErrTracepointSchemaMismatch, p.TableName)
+			}
+		}
+	}
+	return nil
+}
+
+// GetTracepointVersions gets the retained versions of the named tracepoint, ordered from oldest to newest.
+func (m *Manager) GetTracepointVersions(tracepointName string) ([]*storepb.TracepointVersion, error) {
+	return m.ts.GetTracepointVersions(tracepointName)
+}
+
+// RollbackTracepoint replaces the named tracepoint with the deployment from the given version.
+// The rollback is recorded as a new version. The returned tracepoint still needs to be deployed to
+// the agents, using the selector and rollout stages of the returned target version.
+func (m *Manager) RollbackTracepoint(tracepointName string, version int64, ttl time.Duration, owner string) (*storepb.TracepointInfo, *storepb.TracepointVersion, error) {
+	target, err := m.ts.GetTracepointVersion(tracepointName, version)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	if target == nil {
+		return nil, nil, ErrTracepointVersionNotFound
 	}
-	err = m.ts.SetTracepointWithName(tracepointName, tpID)
+
+	current, err := m.getActiveTracepointWithName(tracepointName)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return &tpID, nil
+	if current != nil {
+		err = checkOutputSchemasCompatible(current.Tracepoint.GetPrograms(), target.Tracepoint.GetPrograms())
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	tp, err := m.createTracepoint(tracepointName, target.Tracepoint, ttl, owner, target.Selector, target.RolloutStages, version)
+	return tp, target, err
 }
 
 // GetAllTracepoints gets all the tracepoints currently tracked by the metadata service.
@@ -292,7 +586,12 @@ func (m *Manager) UpdateAgentTracepointStatus(tracepointID *uuidpb.UUID, agentID
 		AgentID: agentID,
 	}
 
-	return m.ts.UpdateTracepointState(tracepointState)
+	err := m.ts.UpdateTracepointState(tracepointState)
+	if err != nil {
+		return err
+	}
+
+	return m.advanceRollout(utils.UUIDFromProtoOrNil(tracepointID), state, status)
 }
 
 // RegisterTracepoint sends requests to the given agents to register the specified tracepoint.
@@ -351,9 +650,14 @@ func (m *Manager) RemoveTracepoints(names []string) error {
 	return m.ts.DeleteTracepointTTLs(ids)
 }
 
-// DeleteAgent deletes tracepoints on the given agent.
+// DeleteAgent deletes tracepoints on the given agent, and removes the agent from any rollouts that
+// are waiting on it.
 func (m *Manager) DeleteAgent(agentID uuid.UUID) error {
-	return m.ts.DeleteTracepointsForAgent(agentID)
+	err := m.ts.DeleteTracepointsForAgent(agentID)
+	if err != nil {
+		return err
+	}
+	return m.removeAgentFromRollouts(agentID)
 }
 
 // Close cleans up the goroutines created and renders this no longer useable.
@@ -0,0 +1,238 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package tracepoint
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"px.dev/pixie/src/carnot/planner/dynamic_tracing/ir/logicalpb"
+	"px.dev/pixie/src/common/base/statuspb"
+	"px.dev/pixie/src/vizier/services/metadata/storepb"
+)
+
+var (
+	// ErrTracepointQuotaExceeded is produced if registering a tracepoint would exceed the maximum
+	// number of active tracepoints.
+	ErrTracepointQuotaExceeded = errors.New("Tracepoint quota exceeded")
+	// ErrTracepointTTLExceeded is produced if a tracepoint's TTL is longer than the maximum TTL.
+	ErrTracepointTTLExceeded = errors.New("Tracepoint TTL exceeds the maximum TTL")
+	// ErrTracepointDenied is produced if a tracepoint probes a denied binary or symbol.
+	ErrTracepointDenied = errors.New("Tracepoint probes a denied target")
+)
+
+// Policy limits the tracepoints that can be registered. Zero values are unlimited.
+type Policy struct {
+	// MaxTracepoints is the maximum number of active tracepoints in the cluster.
+	MaxTracepoints int
+	// MaxTracepointsPerUser is the maximum number of active tracepoints registered by a single user.
+	MaxTracepointsPerUser int
+	// MaxTTL is the maximum TTL of a tracepoint.
+	MaxTTL time.Duration
+	// DeniedBinaries are the names of binaries and shared libraries that may not be probed. Names
+	// are matched exactly against the name of the binary, or against its full path if the name
+	// contains a '/'. A trailing '*' matches any suffix.
+	DeniedBinaries []string
+	// DeniedSymbols are the symbols that may not be probed. A trailing '*' matches any suffix.
+	DeniedSymbols []string
+}
+
+// Usage is the number of active tracepoints in the cluster and for a single user.
+type Usage struct {
+	Active     int
+	UserActive int
+}
+
+// PolicyErrorStatus converts an error produced by a policy check into a status, or returns nil
+// if the error was not produced by a policy check.
+func PolicyErrorStatus(err error) *statuspb.Status {
+	var code statuspb.Code
+	switch {
+	case errors.Is(err, ErrTracepointQuotaExceeded):
+		code = statuspb.RESOURCE_UNAVAILABLE
+	case errors.Is(err, ErrTracepointTTLExceeded):
+		code = statuspb.INVALID_ARGUMENT
+	case errors.Is(err, ErrTracepointDenied):
+		code = statuspb.PERMISSION_DENIED
+	default:
+		return nil
+	}
+	return &statuspb.Status{
+		ErrCode: code,
+		Msg:     err.Error(),
+	}
+}
+
+func matchesDenied(pattern string, s string) bool {
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(s, strings.TrimSuffix(pattern, "*"))
+	}
+	return pattern == s
+}
+
+var (
+	// bpftraceTokenRegex matches the identifiers and paths in a bpftrace program, such as the binary
+	// and the function in the probe "uprobe:/usr/lib/libssl.so:SSL_write".
+	bpftraceTokenRegex = regexp.MustCompile(`[A-Za-z0-9_./+-]+`)
+	// bpftraceStringRegex matches the string literals in a bpftrace program.
+	bpftraceStringRegex = regexp.MustCompile(`"(\\.|[^"\\])*"`)
+)
+
+// bpftraceTokens splits a bpftrace program into its identifiers and paths, ignoring string literals.
+// Denied targets are matched against whole tokens, so that a denied name does not match names which
+// contain it.
+func bpftraceTokens(program string) []string {
+	return bpftraceTokenRegex.FindAllString(bpftraceStringRegex.ReplaceAllString(program, " "), -1)
+}
+
+// matchesDeniedBinary checks whether the path or name of a binary matches the denied pattern. Patterns
+// without a '/' are matched against the name of the binary.
+func matchesDeniedBinary(pattern string, binary string) bool {
+	if !strings.Contains(pattern, "/") {
+		binary = path.Base(binary)
+	}
+	return matchesDenied(pattern, binary)
+}
+
+// checkTTL checks the TTL against the policy.
+func (p *Policy) checkTTL(ttl time.Duration) error {
+	if p.MaxTTL > 0 && ttl > p.MaxTTL {
+		return fmt.Errorf("%w: %s > %s", ErrTracepointTTLExceeded, ttl, p.MaxTTL)
+	}
+	return nil
+}
+
+// checkTargets checks that the deployment does not probe any denied binaries or symbols.
+func (p *Policy) checkTargets(tracepointDeployment *logicalpb.TracepointDeployment) error {
+	spec := tracepointDeployment.GetDeploymentSpec()
+	for _, b := range p.DeniedBinaries {
+		if so := spec.GetSharedObject(); so != nil && matchesDeniedBinary(b, so.Name) {
+			return fmt.Errorf("%w: binary '%s' is denied", ErrTracepointDenied, so.Name)
+		}
+		if pp := spec.GetPodProcess(); pp != nil && pp.Process != "" && matchesDeniedBinary(b, pp.Process) {
+			return fmt.Errorf("%w: binary '%s' is denied", ErrTracepointDenied, pp.Process)
+		}
+	}
+
+	for _, prog := range tracepointDeployment.GetPrograms() {
+		symbol := prog.GetSpec().GetProbe().GetTracepoint().GetSymbol()
+		for _, s := range p.DeniedSymbols {
+			if symbol != "" && matchesDenied(s, symbol) {
+				return fmt.Errorf("%w: symbol '%s' is denied", ErrTracepointDenied, symbol)
+			}
+		}
+
+		for _, token := range bpftraceTokens(prog.GetBPFTrace().GetProgram()) {
+			for _, s := range p.DeniedSymbols {
+				if matchesDenied(s, token) {
+					return fmt.Errorf("%w: symbol '%s' is denied", ErrTracepointDenied, token)
+				}
+			}
+			for _, b := range p.DeniedBinaries {
+				if matchesDeniedBinary(b, token) {
+					return fmt.Errorf("%w: binary '%s' is denied", ErrTracepointDenied, token)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkQuota checks that registering one more tracepoint does not exceed the policy's limits.
+func (p *Policy) checkQuota(usage *Usage, owner string) error {
+	if p.MaxTracepoints > 0 && usage.Active >= p.MaxTracepoints {
+		return fmt.Errorf("%w: cluster has %d of %d active tracepoints", ErrTracepointQuotaExceeded, usage.Active, p.MaxTracepoints)
+	}
+	if owner != "" && p.MaxTracepointsPerUser > 0 && usage.UserActive >= p.MaxTracepointsPerUser {
+		return fmt.Errorf("%w: user has %d of %d active tracepoints", ErrTracepointQuotaExceeded, usage.UserActive, p.MaxTracepointsPerUser)
+	}
+	return nil
+}
+
+// SetPolicy sets the policy that newly registered tracepoints are checked against.
+func (m *Manager) SetPolicy(policy *Policy) {
+	m.policyMu.Lock()
+	defer m.policyMu.Unlock()
+	m.policy = policy
+}
+
+// GetPolicy returns the policy that newly registered tracepoints are checked against.
+func (m *Manager) GetPolicy() *Policy {
+	m.policyMu.RLock()
+	defer m.policyMu.RUnlock()
+	if m.policy == nil {
+		return &Policy{}
+	}
+	return m.policy
+}
+
+// GetUsage returns the number of active tracepoints in the cluster, and registered by the given owner.
+func (m *Manager) GetUsage(owner string) (*Usage, error) {
+	tps, err := m.ts.GetTracepoints()
+	if err != nil {
+		return nil, err
+	}
+	return usageForTracepoints(tps, owner, ""), nil
+}
+
+// usageForTracepoints counts the active tracepoints by name, skipping any with the excluded name.
+// Tracepoints being replaced are excluded so that updating a tracepoint does not count against the
+// quota, and replaced versions awaiting termination are only counted once.
+func usageForTracepoints(tps []*storepb.TracepointInfo, owner string, excludeName string) *Usage {
+	usage := &Usage{}
+	seen := make(map[string]bool)
+	for _, tp := range tps {
+		if tp == nil || tp.ExpectedState == statuspb.TERMINATED_STATE || (excludeName != "" && tp.Name == excludeName) {
+			continue
+		}
+		if seen[tp.Name] {
+			continue
+		}
+		seen[tp.Name] = true
+		usage.Active++
+		if owner != "" && tp.Owner == owner {
+			usage.UserActive++
+		}
+	}
+	return usage
+}
+
+// checkPolicy checks that the given tracepoint, registered by the owner, is allowed by the policy.
+func (m *Manager) checkPolicy(owner string, tracepointName string, tracepointDeployment *logicalpb.TracepointDeployment, ttl time.Duration) error {
+	policy := m.GetPolicy()
+	if err := policy.checkTTL(ttl); err != nil {
+		return err
+	}
+	if err := policy.checkTargets(tracepointDeployment); err != nil {
+		return err
+	}
+	if policy.MaxTracepoints == 0 && policy.MaxTracepointsPerUser == 0 {
+		return nil
+	}
+
+	tps, err := m.ts.GetTracepoints()
+	if err != nil {
+		return err
+	}
+	return policy.checkQuota(usageForTracepoints(tps, owner, tracepointName), owner)
+}
@@ -0,0 +1,218 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package tracepoint
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"px.dev/pixie/src/carnot/planner/dynamic_tracing/ir/logicalpb"
+	"px.dev/pixie/src/common/base/statuspb"
+)
+
+func deploymentWithSymbol(symbol string) *logicalpb.TracepointDeployment {
+	return &logicalpb.TracepointDeployment{
+		Programs: []*logicalpb.TracepointDeployment_TracepointProgram{
+			{
+				TableName: "table1",
+				Spec: &logicalpb.TracepointSpec{
+					Probe: &logicalpb.Probe{
+						Tracepoint: &logicalpb.Tracepoint{Symbol: symbol},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestPolicy_CheckTargets(t *testing.T) {
+	policy := &Policy{
+		DeniedBinaries: []string{"libssl*", "vault"},
+		DeniedSymbols:  []string{"crypto/tls.*", "main.secret"},
+	}
+
+	tests := []struct {
+		name       string
+		deployment *logicalpb.TracepointDeployment
+		denied     bool
+	}{
+		{
+			name:       "allowed symbol",
+			deployment: deploymentWithSymbol("main.handler"),
+		},
+		{
+			name:       "denied symbol",
+			deployment: deploymentWithSymbol("main.secret"),
+			denied:     true,
+		},
+		{
+			name:       "denied symbol prefix",
+			deployment: deploymentWithSymbol("crypto/tls.(*Conn).Write"),
+			denied:     true,
+		},
+		{
+			name: "denied shared object",
+			deployment: &logicalpb.TracepointDeployment{
+				DeploymentSpec: &logicalpb.DeploymentSpec{
+					TargetOneof: &logicalpb.DeploymentSpec_SharedObject_{
+						SharedObject: &logicalpb.DeploymentSpec_SharedObject{Name: "libssl"},
+					},
+				},
+			},
+			denied: true,
+		},
+		{
+			name: "denied bpftrace",
+			deployment: &logicalpb.TracepointDeployment{
+				Programs: []*logicalpb.TracepointDeployment_TracepointProgram{
+					{BPFTrace: &logicalpb.BPFTrace{Program: "uprobe:/usr/lib/libssl.so:SSL_write { }"}},
+				},
+			},
+			denied: true,
+		},
+		{
+			name: "denied bpftrace symbol",
+			deployment: &logicalpb.TracepointDeployment{
+				Programs: []*logicalpb.TracepointDeployment_TracepointProgram{
+					{BPFTrace: &logicalpb.BPFTrace{Program: "uprobe:/app/server:main.secret { @[tid] = count(); }"}},
+				},
+			},
+			denied: true,
+		},
+		{
+			name: "bpftrace with names containing denied names",
+			deployment: &logicalpb.TracepointDeployment{
+				Programs: []*logicalpb.TracepointDeployment_TracepointProgram{
+					{BPFTrace: &logicalpb.BPFTrace{Program: "uprobe:/app/vaulted:main.secretive { printf(\"vault\"); }"}},
+				},
+			},
+		},
+		{
+			name: "denied pod process",
+			deployment: &logicalpb.TracepointDeployment{
+				DeploymentSpec: &logicalpb.DeploymentSpec{
+					TargetOneof: &logicalpb.DeploymentSpec_PodProcess_{
+						PodProcess: &logicalpb.DeploymentSpec_PodProcess{Process: "/usr/bin/vault"},
+					},
+				},
+			},
+			denied: true,
+		},
+		{
+			name: "pod process containing a denied name",
+			deployment: &logicalpb.TracepointDeployment{
+				DeploymentSpec: &logicalpb.DeploymentSpec{
+					TargetOneof: &logicalpb.DeploymentSpec_PodProcess_{
+						PodProcess: &logicalpb.DeploymentSpec_PodProcess{Process: "vault-agent-injector"},
+					},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := policy.checkTargets(test.deployment)
+			if test.denied {
+				assert.True(t, errors.Is(err, ErrTracepointDenied))
+				assert.Equal(t, statuspb.PERMISSION_DENIED, PolicyErrorStatus(err).ErrCode)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestManager_CreateTracepointWithPolicy(t *testing.T) {
+	_, ts, cleanup := setupTest(t)
+	defer cleanup()
+
+	tpMgr := NewManager(ts, nil, time.Hour)
+	defer tpMgr.Close()
+	tpMgr.SetPolicy(&Policy{
+		MaxTracepoints:        3,
+		MaxTracepointsPerUser: 2,
+		MaxTTL:                time.Hour,
+	})
+
+	_, err := tpMgr.CreateTracepoint("tp1", deploymentWithSymbol("main.a"), 2*time.Hour, "user1", nil, nil)
+	assert.True(t, errors.Is(err, ErrTracepointTTLExceeded))
+	assert.Equal(t, statuspb.INVALID_ARGUMENT, PolicyErrorStatus(err).ErrCode)
+
+	_, err = tpMgr.CreateTracepoint("tp1", deploymentWithSymbol("main.a"), time.Minute, "user1", nil, nil)
+	require.NoError(t, err)
+	_, err = tpMgr.CreateTracepoint("tp2", deploymentWithSymbol("main.b"), time.Minute, "user1", nil, nil)
+	require.NoError(t, err)
+
+	// Replacing an existing tracepoint does not count against the quota.
+	_, err = tpMgr.CreateTracepoint("tp2", deploymentWithSymbol("main.c"), time.Minute, "user1", nil, nil)
+	require.NoError(t, err)
+
+	_, err = tpMgr.CreateTracepoint("tp3", deploymentWithSymbol("main.d"), time.Minute, "user1", nil, nil)
+	assert.True(t, errors.Is(err, ErrTracepointQuotaExceeded))
+	assert.Equal(t, statuspb.RESOURCE_UNAVAILABLE, PolicyErrorStatus(err).ErrCode)
+
+	_, err = tpMgr.CreateTracepoint("tp3", deploymentWithSymbol("main.d"), time.Minute, "user2", nil, nil)
+	require.NoError(t, err)
+	_, err = tpMgr.CreateTracepoint("tp4", deploymentWithSymbol("main.e"), time.Minute, "user2", nil, nil)
+	assert.True(t, errors.Is(err, ErrTracepointQuotaExceeded))
+
+	usage, err := tpMgr.GetUsage("user1")
+	require.NoError(t, err)
+	assert.Equal(t, &Usage{Active: 3, UserActive: 2}, usage)
+
+	assert.Nil(t, PolicyErrorStatus(errors.New("other")))
+}
+
+func TestManager_CreateTracepointQuotaIsAtomic(t *testing.T) {
+	_, ts, cleanup := setupTest(t)
+	defer cleanup()
+
+	tpMgr := NewManager(ts, nil, time.Hour)
+	defer tpMgr.Close()
+	tpMgr.SetPolicy(&Policy{MaxTracepoints: 2})
+
+	// Only two of the concurrently registered tracepoints fit in the quota.
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = tpMgr.CreateTracepoint(fmt.Sprintf("tp%d", i), deploymentWithSymbol(fmt.Sprintf("main.f%d", i)), time.Minute, "", nil, nil)
+		}(i)
+	}
+	wg.Wait()
+
+	created := 0
+	for _, err := range errs {
+		if err == nil {
+			created++
+			continue
+		}
+		assert.True(t, errors.Is(err, ErrTracepointQuotaExceeded))
+	}
+	assert.Equal(t, 2, created)
+}
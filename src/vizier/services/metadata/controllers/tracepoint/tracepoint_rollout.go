@@ -0,0 +1,370 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package tracepoint
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/gofrs/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"px.dev/pixie/src/api/proto/uuidpb"
+	"px.dev/pixie/src/carnot/planner/dynamic_tracing/ir/logicalpb"
+	"px.dev/pixie/src/common/base/statuspb"
+	"px.dev/pixie/src/utils"
+	"px.dev/pixie/src/vizier/services/metadata/storepb"
+	"px.dev/pixie/src/vizier/services/shared/agentpb"
+)
+
+// ErrNoHostResolver is produced if a tracepoint selector is specified, but there is no way to resolve it.
+var ErrNoHostResolver = errors.New("Tracepoint selectors are not supported")
+
+// HostResolver resolves label selectors to the IPs of the hosts they match.
+type HostResolver interface {
+	// GetNodeIPsWithLabels returns the IPs of the nodes whose labels match all of the given labels.
+	GetNodeIPsWithLabels(labels map[string]string) []string
+	// GetHostIPsForPodsWithLabels returns the IPs of the hosts running pods in the namespace whose
+	// labels match all of the given labels.
+	GetHostIPsForPodsWithLabels(namespace string, labels map[string]string) ([]string, error)
+}
+
+// SelectAgents returns the IDs of the agents that are selected by the given selector. If the selector
+// is empty, all agents are selected.
+func SelectAgents(agents []*agentpb.Agent, selector *storepb.TracepointSelector, hosts HostResolver) ([]uuid.UUID, error) {
+	var hostIPs map[string]bool
+	if len(selector.GetNodeLabels()) > 0 || len(selector.GetPodLabels()) > 0 {
+		if hosts == nil {
+			return nil, ErrNoHostResolver
+		}
+
+		var nodeIPs map[string]bool
+		if len(selector.NodeLabels) > 0 {
+			nodeIPs = make(map[string]bool)
+			for _, ip := range hosts.GetNodeIPsWithLabels(selector.NodeLabels) {
+				nodeIPs[ip] = true
+			}
+		}
+
+		var podHostIPs map[string]bool
+		if len(selector.PodLabels) > 0 {
+			namespace := selector.Namespace
+			if namespace == "" {
+				namespace = "default"
+			}
+			ips, err := hosts.GetHostIPsForPodsWithLabels(namespace, selector.PodLabels)
+			if err != nil {
+				return nil, err
+			}
+			podHostIPs = make(map[string]bool)
+			for _, ip := range ips {
+				podHostIPs[ip] = true
+			}
+		}
+
+		// The agent must be on a host matching both the node and pod labels.
+		hostIPs = nodeIPs
+		if hostIPs == nil {
+			hostIPs = podHostIPs
+		} else if podHostIPs != nil {
+			for ip := range hostIPs {
+				if !podHostIPs[ip] {
+					delete(hostIPs, ip)
+				}
+			}
+		}
+	}
+
+	agentIDs := make([]uuid.UUID, 0, len(agents))
+	for _, agt := range agents {
+		if hostIPs != nil && !hostIPs[agt.GetInfo().GetHostInfo().GetHostIP()] {
+			continue
+		}
+		agentIDs = append(agentIDs, utils.UUIDFromProtoOrNil(agt.Info.AgentID))
+	}
+	return agentIDs, nil
+}
+
+// ValidateRolloutStages checks that each of the given rollout stages specifies exactly one of a
+// number of agents or a percentage of agents.
+func ValidateRolloutStages(stages []*storepb.TracepointRolloutStage) error {
+	for i, s := range stages {
+		if (s.NumAgents > 0) == (s.Percent > 0) {
+			return fmt.Errorf("rollout stage %d must specify exactly one of num_agents or percent", i)
+		}
+		if s.NumAgents < 0 || s.Percent < 0 || s.Percent > 100 {
+			return fmt.Errorf("rollout stage %d is out of range", i)
+		}
+	}
+	return nil
+}
+
+// TargetsAllAgents returns whether the tracepoint should be deployed to every agent, including
+// agents that register after the tracepoint was created.
+func TargetsAllAgents(tp *storepb.TracepointInfo) bool {
+	if len(tp.Selector.GetNodeLabels()) > 0 || len(tp.Selector.GetPodLabels()) > 0 {
+		return false
+	}
+	return tp.Rollout == nil || tp.Rollout.State == storepb.ROLLOUT_STATE_COMPLETE
+}
+
+// rolloutStageSize returns the number of agents the tracepoint should be running on once the given
+// stage is deployed. Stage sizes never shrink, and the final, implicit stage covers all agents.
+func rolloutStageSize(rollout *storepb.TracepointRollout, stage int) int {
+	total := len(rollout.AgentIDs)
+	size := 0
+	for i := 0; i <= stage; i++ {
+		if i >= len(rollout.Stages) {
+			return total
+		}
+		n := int(rollout.Stages[i].NumAgents)
+		if n == 0 {
+			n = int(math.Ceil(rollout.Stages[i].Percent * float64(total) / 100))
+		}
+		if n > size {
+			size = n
+		}
+	}
+	if size < 1 {
+		size = 1
+	}
+	if size > total {
+		size = total
+	}
+	return size
+}
+
+func rolloutAgentIDs(ids []*uuidpb.UUID) []uuid.UUID {
+	agentIDs := make([]uuid.UUID, len(ids))
+	for i, id := range ids {
+		agentIDs[i] = utils.UUIDFromProtoOrNil(id)
+	}
+	return agentIDs
+}
+
+// DeployTracepoint deploys the tracepoint to the given agents. If rollout stages are specified, the
+// tracepoint is first deployed to the agents in the first stage, and each following stage is deployed
+// once the tracepoint is running on all agents in the previous stage.
+func (m *Manager) DeployTracepoint(tracepointID uuid.UUID, tracepointDeployment *logicalpb.TracepointDeployment, agentIDs []uuid.UUID, selector *storepb.TracepointSelector, stages []*storepb.TracepointRolloutStage) error {
+	if selector == nil && len(stages) == 0 {
+		return m.RegisterTracepoint(agentIDs, tracepointID, tracepointDeployment)
+	}
+
+	err := ValidateRolloutStages(stages)
+	if err != nil {
+		return err
+	}
+
+	unlock := m.lockTracepoint(tracepointID)
+	defer unlock()
+
+	tp, err := m.ts.GetTracepoint(tracepointID)
+	if err != nil {
+		return err
+	}
+	if tp == nil {
+		return nil
+	}
+
+	tp.Selector = selector
+	stageAgentIDs := agentIDs
+	if len(stages) > 0 {
+		ids := make([]*uuidpb.UUID, len(agentIDs))
+		for i, id := range agentIDs {
+			ids[i] = utils.ProtoFromUUID(id)
+		}
+		tp.Rollout = &storepb.TracepointRollout{
+			Stages:   stages,
+			AgentIDs: ids,
+			State:    storepb.ROLLOUT_STATE_IN_PROGRESS,
+		}
+		if len(agentIDs) == 0 {
+			tp.Rollout.State = storepb.ROLLOUT_STATE_COMPLETE
+		}
+		stageAgentIDs = agentIDs[:rolloutStageSize(tp.Rollout, 0)]
+	}
+
+	err = m.ts.UpsertTracepoint(tracepointID, tp)
+	if err != nil {
+		return err
+	}
+	return m.RegisterTracepoint(stageAgentIDs, tracepointID, tracepointDeployment)
+}
+
+// advanceRollout moves the rollout of the tracepoint on to the next stage if the tracepoint is running
+// on all agents in the current stage, and halts the rollout if the tracepoint failed on an agent.
+// Agents that fail with NOT_FOUND, such as when the probed binary is not on the host, do not halt
+// the rollout.
+func (m *Manager) advanceRollout(tracepointID uuid.UUID, state statuspb.LifeCycleState, status *statuspb.Status) error {
+	if state != statuspb.RUNNING_STATE && state != statuspb.FAILED_STATE {
+		return nil
+	}
+
+	unlock := m.lockTracepoint(tracepointID)
+	defer unlock()
+
+	tp, err := m.getRolloutInProgress(tracepointID)
+	if err != nil || tp == nil {
+		return err
+	}
+
+	if state == statuspb.FAILED_STATE && status.GetErrCode() != statuspb.NOT_FOUND {
+		log.WithField("tracepoint", tp.Name).Info("Halting tracepoint rollout after agent failure")
+		tp.Rollout.State = storepb.ROLLOUT_STATE_HALTED
+		err = m.ts.UpsertTracepoint(tracepointID, tp)
+		if err != nil {
+			return err
+		}
+		err = m.ts.DeleteTracepointTTLs([]uuid.UUID{tracepointID})
+		if err != nil {
+			return err
+		}
+		return m.terminateTracepointLocked(tracepointID)
+	}
+
+	return m.advanceRolloutStage(tracepointID, tp)
+}
+
+// getRolloutInProgress gets the tracepoint, if it is being rolled out. The caller must hold the
+// tracepoint's lock.
+func (m *Manager) getRolloutInProgress(tracepointID uuid.UUID) (*storepb.TracepointInfo, error) {
+	tp, err := m.ts.GetTracepoint(tracepointID)
+	if err != nil {
+		return nil, err
+	}
+	if tp == nil || tp.Rollout == nil || tp.Rollout.State != storepb.ROLLOUT_STATE_IN_PROGRESS ||
+		tp.ExpectedState == statuspb.TERMINATED_STATE {
+		return nil, nil
+	}
+	return tp, nil
+}
+
+// advanceRolloutStage deploys the next stage of the rollout if every agent in the current stage has
+// settled. Agents which were removed during the rollout count as settled. The caller must hold the
+// tracepoint's lock.
+func (m *Manager) advanceRolloutStage(tracepointID uuid.UUID, tp *storepb.TracepointInfo) error {
+	states, err := m.ts.GetTracepointStates(tracepointID)
+	if err != nil {
+		return err
+	}
+	settled := make(map[uuid.UUID]bool)
+	for _, s := range states {
+		if s.State == statuspb.RUNNING_STATE || (s.State == statuspb.FAILED_STATE && s.Status.GetErrCode() == statuspb.NOT_FOUND) {
+			settled[utils.UUIDFromProtoOrNil(s.AgentID)] = true
+		}
+	}
+	removed := make(map[uuid.UUID]bool)
+	for _, id := range rolloutAgentIDs(tp.Rollout.RemovedAgentIDs) {
+		removed[id] = true
+	}
+
+	agentIDs := rolloutAgentIDs(tp.Rollout.AgentIDs)
+	stage := int(tp.Rollout.CurrentStage)
+	deployed := rolloutStageSize(tp.Rollout, stage)
+	for _, id := range agentIDs[:deployed] {
+		if !settled[id] && !removed[id] {
+			return nil
+		}
+	}
+
+	// Skip over any stages that would not deploy to additional agents.
+	lastStage := len(tp.Rollout.Stages)
+	next := deployed
+	for stage < lastStage && next == deployed {
+		stage++
+		next = rolloutStageSize(tp.Rollout, stage)
+	}
+	tp.Rollout.CurrentStage = int64(stage)
+	if stage == lastStage {
+		tp.Rollout.State = storepb.ROLLOUT_STATE_COMPLETE
+	}
+
+	err = m.ts.UpsertTracepoint(tracepointID, tp)
+	if err != nil {
+		return err
+	}
+
+	var nextAgentIDs []uuid.UUID
+	for _, id := range agentIDs[deployed:next] {
+		if !removed[id] {
+			nextAgentIDs = append(nextAgentIDs, id)
+		}
+	}
+	if len(nextAgentIDs) == 0 {
+		// Every agent in the next stage was removed, so it is already settled.
+		if tp.Rollout.State == storepb.ROLLOUT_STATE_IN_PROGRESS {
+			return m.advanceRolloutStage(tracepointID, tp)
+		}
+		return nil
+	}
+	return m.RegisterTracepoint(nextAgentIDs, tracepointID, tp.Tracepoint)
+}
+
+// removeAgentFromRollouts marks the agent as removed from any rollouts that target it, so that the
+// rollouts don't wait for an agent that will never report back.
+func (m *Manager) removeAgentFromRollouts(agentID uuid.UUID) error {
+	tps, err := m.ts.GetTracepoints()
+	if err != nil {
+		return err
+	}
+	for _, tp := range tps {
+		if tp == nil || tp.Rollout == nil || tp.Rollout.State != storepb.ROLLOUT_STATE_IN_PROGRESS {
+			continue
+		}
+		err := m.removeAgentFromRollout(utils.UUIDFromProtoOrNil(tp.ID), agentID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Manager) removeAgentFromRollout(tracepointID uuid.UUID, agentID uuid.UUID) error {
+	unlock := m.lockTracepoint(tracepointID)
+	defer unlock()
+
+	tp, err := m.getRolloutInProgress(tracepointID)
+	if err != nil || tp == nil {
+		return err
+	}
+
+	targeted := false
+	for _, id := range rolloutAgentIDs(tp.Rollout.AgentIDs) {
+		if id == agentID {
+			targeted = true
+			break
+		}
+	}
+	for _, id := range rolloutAgentIDs(tp.Rollout.RemovedAgentIDs) {
+		if id == agentID {
+			return nil
+		}
+	}
+	if !targeted {
+		return nil
+	}
+
+	tp.Rollout.RemovedAgentIDs = append(tp.Rollout.RemovedAgentIDs, utils.ProtoFromUUID(agentID))
+	err = m.ts.UpsertTracepoint(tracepointID, tp)
+	if err != nil {
+		return err
+	}
+	return m.advanceRolloutStage(tracepointID, tp)
+}
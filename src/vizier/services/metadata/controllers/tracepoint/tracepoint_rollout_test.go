@@ -0,0 +1,266 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package tracepoint
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"px.dev/pixie/src/api/proto/uuidpb"
+	"px.dev/pixie/src/carnot/planner/dynamic_tracing/ir/logicalpb"
+	"px.dev/pixie/src/common/base/statuspb"
+	"px.dev/pixie/src/utils"
+	mock_agent "px.dev/pixie/src/vizier/services/metadata/controllers/agent/mock"
+	"px.dev/pixie/src/vizier/services/metadata/storepb"
+	"px.dev/pixie/src/vizier/services/shared/agentpb"
+)
+
+type fakeHostResolver struct {
+	nodeIPs map[string][]string
+	podIPs  map[string][]string
+}
+
+func (f *fakeHostResolver) GetNodeIPsWithLabels(labels map[string]string) []string {
+	return f.nodeIPs[labels["zone"]]
+}
+
+func (f *fakeHostResolver) GetHostIPsForPodsWithLabels(namespace string, labels map[string]string) ([]string, error) {
+	return f.podIPs[namespace+"/"+labels["app"]], nil
+}
+
+func TestSelectAgents(t *testing.T) {
+	agentIDs := make([]uuid.UUID, 3)
+	agents := make([]*agentpb.Agent, 3)
+	for i := range agents {
+		agentIDs[i] = uuid.Must(uuid.NewV4())
+		agents[i] = &agentpb.Agent{
+			Info: &agentpb.AgentInfo{
+				AgentID:  utils.ProtoFromUUID(agentIDs[i]),
+				HostInfo: &agentpb.HostInfo{HostIP: []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}[i]},
+			},
+		}
+	}
+	hosts := &fakeHostResolver{
+		nodeIPs: map[string][]string{"a": {"10.0.0.1", "10.0.0.2"}},
+		podIPs:  map[string][]string{"default/web": {"10.0.0.2", "10.0.0.3"}, "prod/web": {"10.0.0.3"}},
+	}
+
+	tests := []struct {
+		name     string
+		selector *storepb.TracepointSelector
+		expected []uuid.UUID
+	}{
+		{
+			name:     "no selector",
+			expected: agentIDs,
+		},
+		{
+			name:     "node labels",
+			selector: &storepb.TracepointSelector{NodeLabels: map[string]string{"zone": "a"}},
+			expected: agentIDs[:2],
+		},
+		{
+			name:     "pod labels",
+			selector: &storepb.TracepointSelector{Namespace: "prod", PodLabels: map[string]string{"app": "web"}},
+			expected: agentIDs[2:],
+		},
+		{
+			name: "node and pod labels",
+			selector: &storepb.TracepointSelector{
+				NodeLabels: map[string]string{"zone": "a"},
+				PodLabels:  map[string]string{"app": "web"},
+			},
+			expected: agentIDs[1:2],
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			selected, err := SelectAgents(agents, test.selector, hosts)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, selected)
+		})
+	}
+
+	_, err := SelectAgents(agents, &storepb.TracepointSelector{NodeLabels: map[string]string{"zone": "a"}}, nil)
+	assert.Equal(t, ErrNoHostResolver, err)
+}
+
+func TestRolloutStageSize(t *testing.T) {
+	rollout := &storepb.TracepointRollout{
+		Stages: []*storepb.TracepointRolloutStage{
+			{NumAgents: 1},
+			{Percent: 10},
+			{Percent: 50},
+		},
+		AgentIDs: make([]*uuidpb.UUID, 30),
+	}
+
+	assert.Equal(t, 1, rolloutStageSize(rollout, 0))
+	assert.Equal(t, 3, rolloutStageSize(rollout, 1))
+	assert.Equal(t, 15, rolloutStageSize(rollout, 2))
+	assert.Equal(t, 30, rolloutStageSize(rollout, 3))
+
+	rollout.AgentIDs = make([]*uuidpb.UUID, 2)
+	assert.Equal(t, 1, rolloutStageSize(rollout, 0))
+	assert.Equal(t, 1, rolloutStageSize(rollout, 1))
+	assert.Equal(t, 1, rolloutStageSize(rollout, 2))
+	assert.Equal(t, 2, rolloutStageSize(rollout, 3))
+}
+
+func TestValidateRolloutStages(t *testing.T) {
+	assert.NoError(t, ValidateRolloutStages([]*storepb.TracepointRolloutStage{{NumAgents: 1}, {Percent: 10}}))
+	assert.Error(t, ValidateRolloutStages([]*storepb.TracepointRolloutStage{{}}))
+	assert.Error(t, ValidateRolloutStages([]*storepb.TracepointRolloutStage{{NumAgents: 1, Percent: 10}}))
+	assert.Error(t, ValidateRolloutStages([]*storepb.TracepointRolloutStage{{Percent: 150}}))
+}
+
+func setupRolloutTest(t *testing.T, numAgents int) (*Manager, *Datastore, *mock_agent.MockManager, uuid.UUID, []uuid.UUID, func()) {
+	ctrl := gomock.NewController(t)
+	mockAgtMgr := mock_agent.NewMockManager(ctrl)
+	_, ts, cleanup := setupTest(t)
+
+	tpID := uuid.Must(uuid.NewV4())
+	err := ts.UpsertTracepoint(tpID, &storepb.TracepointInfo{
+		ID:            utils.ProtoFromUUID(tpID),
+		Name:          "test_tracepoint",
+		Tracepoint:    &logicalpb.TracepointDeployment{},
+		ExpectedState: statuspb.RUNNING_STATE,
+	})
+	require.NoError(t, err)
+
+	agentIDs := make([]uuid.UUID, numAgents)
+	for i := range agentIDs {
+		agentIDs[i] = uuid.Must(uuid.NewV4())
+	}
+
+	tpMgr := NewManager(ts, mockAgtMgr, time.Hour)
+	return tpMgr, ts, mockAgtMgr, tpID, agentIDs, func() {
+		tpMgr.Close()
+		cleanup()
+		ctrl.Finish()
+	}
+}
+
+func TestManager_StagedRollout(t *testing.T) {
+	tpMgr, ts, mockAgtMgr, tpID, agentIDs, cleanup := setupRolloutTest(t, 4)
+	defer cleanup()
+
+	mockAgtMgr.EXPECT().MessageAgents(agentIDs[:1], gomock.Any()).Return(nil)
+	err := tpMgr.DeployTracepoint(tpID, &logicalpb.TracepointDeployment{}, agentIDs, nil, []*storepb.TracepointRolloutStage{
+		{NumAgents: 1},
+		{Percent: 50},
+	})
+	require.NoError(t, err)
+
+	tp, err := ts.GetTracepoint(tpID)
+	require.NoError(t, err)
+	assert.Equal(t, storepb.ROLLOUT_STATE_IN_PROGRESS, tp.Rollout.State)
+	assert.Equal(t, int64(0), tp.Rollout.CurrentStage)
+	assert.False(t, TargetsAllAgents(tp))
+
+	// The second stage is only deployed once the first agent is running.
+	err = tpMgr.UpdateAgentTracepointStatus(utils.ProtoFromUUID(tpID), utils.ProtoFromUUID(agentIDs[0]), statuspb.PENDING_STATE, nil)
+	require.NoError(t, err)
+
+	mockAgtMgr.EXPECT().MessageAgents(agentIDs[1:2], gomock.Any()).Return(nil)
+	err = tpMgr.UpdateAgentTracepointStatus(utils.ProtoFromUUID(tpID), utils.ProtoFromUUID(agentIDs[0]), statuspb.RUNNING_STATE, nil)
+	require.NoError(t, err)
+
+	mockAgtMgr.EXPECT().MessageAgents(agentIDs[2:], gomock.Any()).Return(nil)
+	err = tpMgr.UpdateAgentTracepointStatus(utils.ProtoFromUUID(tpID), utils.ProtoFromUUID(agentIDs[1]), statuspb.FAILED_STATE, &statuspb.Status{
+		ErrCode: statuspb.NOT_FOUND,
+	})
+	require.NoError(t, err)
+
+	tp, err = ts.GetTracepoint(tpID)
+	require.NoError(t, err)
+	assert.Equal(t, storepb.ROLLOUT_STATE_COMPLETE, tp.Rollout.State)
+	assert.Equal(t, int64(2), tp.Rollout.CurrentStage)
+	assert.True(t, TargetsAllAgents(tp))
+}
+
+func TestManager_StagedRolloutHaltsOnFailure(t *testing.T) {
+	tpMgr, ts, mockAgtMgr, tpID, agentIDs, cleanup := setupRolloutTest(t, 4)
+	defer cleanup()
+
+	mockAgtMgr.EXPECT().MessageAgents(agentIDs[:1], gomock.Any()).Return(nil)
+	err := tpMgr.DeployTracepoint(tpID, &logicalpb.TracepointDeployment{}, agentIDs, nil, []*storepb.TracepointRolloutStage{
+		{NumAgents: 1},
+	})
+	require.NoError(t, err)
+
+	mockAgtMgr.EXPECT().MessageActiveAgents(gomock.Any()).Return(nil)
+	err = tpMgr.UpdateAgentTracepointStatus(utils.ProtoFromUUID(tpID), utils.ProtoFromUUID(agentIDs[0]), statuspb.FAILED_STATE, &statuspb.Status{
+		ErrCode: statuspb.INTERNAL,
+	})
+	require.NoError(t, err)
+
+	tp, err := ts.GetTracepoint(tpID)
+	require.NoError(t, err)
+	assert.Equal(t, storepb.ROLLOUT_STATE_HALTED, tp.Rollout.State)
+	assert.Equal(t, statuspb.TERMINATED_STATE, tp.ExpectedState)
+}
+
+func TestManager_StagedRolloutSkipsDeletedAgents(t *testing.T) {
+	tpMgr, ts, mockAgtMgr, tpID, agentIDs, cleanup := setupRolloutTest(t, 4)
+	defer cleanup()
+
+	mockAgtMgr.EXPECT().MessageAgents(agentIDs[:2], gomock.Any()).Return(nil)
+	err := tpMgr.DeployTracepoint(tpID, &logicalpb.TracepointDeployment{}, agentIDs, nil, []*storepb.TracepointRolloutStage{
+		{NumAgents: 2},
+		{NumAgents: 3},
+	})
+	require.NoError(t, err)
+
+	err = tpMgr.UpdateAgentTracepointStatus(utils.ProtoFromUUID(tpID), utils.ProtoFromUUID(agentIDs[0]), statuspb.RUNNING_STATE, nil)
+	require.NoError(t, err)
+
+	// The second agent dies before it reports back, and the third agent, which is the only agent in
+	// the next stage, dies before it is deployed to. The rollout moves on to the final stage.
+	mockAgtMgr.EXPECT().MessageAgents(agentIDs[3:], gomock.Any()).Return(nil)
+	require.NoError(t, tpMgr.DeleteAgent(agentIDs[2]))
+	require.NoError(t, tpMgr.DeleteAgent(agentIDs[1]))
+
+	tp, err := ts.GetTracepoint(tpID)
+	require.NoError(t, err)
+	assert.Equal(t, storepb.ROLLOUT_STATE_COMPLETE, tp.Rollout.State)
+	assert.ElementsMatch(t, []*uuidpb.UUID{utils.ProtoFromUUID(agentIDs[2]), utils.ProtoFromUUID(agentIDs[1])}, tp.Rollout.RemovedAgentIDs)
+}
+
+func TestManager_DeployTracepointWithSelector(t *testing.T) {
+	tpMgr, ts, mockAgtMgr, tpID, agentIDs, cleanup := setupRolloutTest(t, 2)
+	defer cleanup()
+
+	selector := &storepb.TracepointSelector{NodeLabels: map[string]string{"zone": "a"}}
+	mockAgtMgr.EXPECT().MessageAgents(agentIDs, gomock.Any()).Return(nil)
+	err := tpMgr.DeployTracepoint(tpID, &logicalpb.TracepointDeployment{}, agentIDs, selector, nil)
+	require.NoError(t, err)
+
+	tp, err := ts.GetTracepoint(tpID)
+	require.NoError(t, err)
+	assert.Equal(t, selector, tp.Selector)
+	assert.Nil(t, tp.Rollout)
+	assert.False(t, TargetsAllAgents(tp))
+}
@@ -19,7 +19,10 @@
 package tracepoint
 
 import (
+	"context"
+	"fmt"
 	"path"
+	"sort"
 	"strings"
 	"time"
 
@@ -34,10 +37,13 @@ import (
 )
 
 const (
-	tracepointsPrefix      = "/tracepoint/"
-	tracepointStatesPrefix = "/tracepointStates/"
-	tracepointTTLsPrefix   = "/tracepointTTL/"
-	tracepointNamesPrefix  = "/tracepointName/"
+	tracepointsPrefix        = "/tracepoint/"
+	tracepointStatesPrefix   = "/tracepointStates/"
+	tracepointTTLsPrefix     = "/tracepointTTL/"
+	tracepointNamesPrefix    = "/tracepointName/"
+	tracepointVersionsPrefix = "/tracepointVersion/"
+	// maxTracepointVersions is the number of versions retained for each named tracepoint.
+	maxTracepointVersions = 20
 )
 
 // Datastore implements the TracepointStore interface on a given Datastore.
@@ -70,6 +76,16 @@ func getTracepointTTLKey(tracepointID uuid.UUID) string {
 	return path.Join(tracepointTTLsPrefix, tracepointID.String())
 }
 
+func getTracepointVersionsKey(tracepointName string) string {
+	// The trailing slash prevents a prefix lookup from matching other names that share the prefix.
+	return path.Join(tracepointVersionsPrefix, tracepointName) + "/"
+}
+
+func getTracepointVersionKey(tracepointName string, version int64) string {
+	// Versions are zero-padded so that the keys sort in version order.
+	return path.Join(tracepointVersionsPrefix, tracepointName, fmt.Sprintf("%020d", version))
+}
+
 // GetTracepointsWithNames gets which tracepoint is associated with the given name.
 func (t *Datastore) GetTracepointsWithNames(tracepointNames []string) ([]*uuid.UUID, error) {
 	eg := errgroup.Group{}
@@ -125,12 +141,10 @@ func (t *Datastore) UpsertTracepoint(tracepointID uuid.UUID, tracepointInfo *sto
 
 // DeleteTracepoint deletes the tracepoint from the store.
 func (t *Datastore) DeleteTracepoint(tracepointID uuid.UUID) error {
-	err := t.ds.DeleteAll([]string{getTracepointKey(tracepointID)})
-	if err != nil {
-		return err
-	}
-
-	return t.ds.DeleteWithPrefix(getTracepointStatesKey(tracepointID))
+	batch := t.ds.NewBatch()
+	batch.Delete(getTracepointKey(tracepointID))
+	batch.DeleteWithPrefix(getTracepointStatesKey(tracepointID))
+	return batch.Commit()
 }
 
 // GetTracepoint gets the tracepoint info from the store, if it exists.
@@ -255,6 +269,36 @@ func (t *Datastore) DeleteTracepointTTLs(ids []uuid.UUID) error {
 	return t.ds.DeleteAll(keys)
 }
 
+// WatchTracepointTTLs streams the IDs of the tracepoints whose TTLs are deleted, either because they
+// expired or because the tracepoint was terminated. The channel is closed once the context is
+// cancelled or the watch fails.
+func (t *Datastore) WatchTracepointTTLs(ctx context.Context) (<-chan uuid.UUID, error) {
+	events, err := t.ds.Watch(ctx, tracepointTTLsPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make(chan uuid.UUID)
+	go func() {
+		defer close(ids)
+		for e := range events {
+			if e.Type != datastore.EventTypeDelete {
+				continue
+			}
+			id, err := uuid.FromString(path.Base(e.Key))
+			if err != nil {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case ids <- id:
+			}
+		}
+	}()
+	return ids, nil
+}
+
 // DeleteTracepointsForAgent deletes the tracepoints for a given agent.
 // Note this only purges the combo tracepointID+agentID keys. Said
 // tracepoints might still be valid and deployed on other agents.
@@ -307,3 +351,85 @@ func (t *Datastore) GetTracepointTTLs() ([]uuid.UUID, []time.Time, error) {
 
 	return ids, expirations, nil
 }
+
+// CreateTracepointVersion stores a new tracepoint, its TTL, its name and the version it was created
+// as in a single batch, so that a failure can't leave a tracepoint without its version. Only the
+// most recent maxTracepointVersions versions of each tracepoint are retained.
+func (t *Datastore) CreateTracepointVersion(tracepointInfo *storepb.TracepointInfo, ttl time.Duration, version *storepb.TracepointVersion) error {
+	tracepointID := utils.UUIDFromProtoOrNil(tracepointInfo.ID)
+	infoVal, err := tracepointInfo.Marshal()
+	if err != nil {
+		return err
+	}
+	nameVal, err := tracepointInfo.ID.Marshal()
+	if err != nil {
+		return err
+	}
+	encodedExpiry, err := time.Now().Add(ttl).MarshalBinary()
+	if err != nil {
+		return err
+	}
+	versionVal, err := version.Marshal()
+	if err != nil {
+		return err
+	}
+
+	keys, _, err := t.ds.GetWithPrefix(getTracepointVersionsKey(version.Name))
+	if err != nil {
+		return err
+	}
+	versionKey := getTracepointVersionKey(version.Name, version.Version)
+	if i := sort.SearchStrings(keys, versionKey); i == len(keys) || keys[i] != versionKey {
+		keys = append(keys, versionKey)
+		sort.Strings(keys)
+	}
+
+	batch := t.ds.NewBatch()
+	batch.Set(getTracepointKey(tracepointID), string(infoVal))
+	batch.SetWithTTL(getTracepointTTLKey(tracepointID), string(encodedExpiry), ttl)
+	batch.Set(getTracepointWithNameKey(tracepointInfo.Name), string(nameVal))
+	batch.Set(versionKey, string(versionVal))
+	if len(keys) > maxTracepointVersions {
+		for _, k := range keys[:len(keys)-maxTracepointVersions] {
+			batch.Delete(k)
+		}
+	}
+	return batch.Commit()
+}
+
+// GetTracepointVersions gets the retained versions of the named tracepoint, ordered from oldest to newest.
+func (t *Datastore) GetTracepointVersions(tracepointName string) ([]*storepb.TracepointVersion, error) {
+	_, vals, err := t.ds.GetWithPrefix(getTracepointVersionsKey(tracepointName))
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]*storepb.TracepointVersion, 0, len(vals))
+	for _, val := range vals {
+		pb := &storepb.TracepointVersion{}
+		err := proto.Unmarshal(val, pb)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, pb)
+	}
+	return versions, nil
+}
+
+// GetTracepointVersion gets the given version of the named tracepoint, if it exists.
+func (t *Datastore) GetTracepointVersion(tracepointName string, version int64) (*storepb.TracepointVersion, error) {
+	resp, err := t.ds.Get(getTracepointVersionKey(tracepointName, version))
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil {
+		return nil, nil
+	}
+
+	versionPb := &storepb.TracepointVersion{}
+	err = proto.Unmarshal(resp, versionPb)
+	if err != nil {
+		return nil, err
+	}
+	return versionPb, nil
+}
@@ -362,3 +362,62 @@ func TestTracepointStore_GetTracepointTTLs(t *testing.T) {
 	assert.Contains(t, tracepoints, s1ID)
 	assert.Contains(t, tracepoints, s2ID)
 }
+
+func TestTracepointStore_TracepointVersions(t *testing.T) {
+	db, ts, cleanup := setupTest(t)
+	defer cleanup()
+
+	var latestID uuid.UUID
+	for i := int64(1); i <= maxTracepointVersions+2; i++ {
+		latestID = uuid.Must(uuid.NewV4())
+		err := ts.CreateTracepointVersion(&storepb.TracepointInfo{
+			ID:      utils.ProtoFromUUID(latestID),
+			Name:    "test",
+			Version: i,
+		}, 5*time.Second, &storepb.TracepointVersion{
+			Name:    "test",
+			Version: i,
+			ID:      utils.ProtoFromUUID(latestID),
+		})
+		require.NoError(t, err)
+	}
+	// A tracepoint whose name shares a prefix should not be returned.
+	err := ts.CreateTracepointVersion(&storepb.TracepointInfo{
+		ID:   utils.ProtoFromUUID(uuid.Must(uuid.NewV4())),
+		Name: "test2",
+	}, 5*time.Second, &storepb.TracepointVersion{Name: "test2", Version: 1})
+	require.NoError(t, err)
+
+	// The tracepoint, its name and its TTL are written with the version.
+	tp, err := ts.GetTracepoint(latestID)
+	require.NoError(t, err)
+	require.NotNil(t, tp)
+	assert.Equal(t, int64(maxTracepointVersions+2), tp.Version)
+	ids, err := ts.GetTracepointsWithNames([]string{"test"})
+	require.NoError(t, err)
+	assert.Equal(t, latestID, *ids[0])
+	ttlIDs, _, err := ts.GetTracepointTTLs()
+	require.NoError(t, err)
+	assert.Contains(t, ttlIDs, latestID)
+
+	versions, err := ts.GetTracepointVersions("test")
+	require.NoError(t, err)
+	require.Equal(t, maxTracepointVersions, len(versions))
+	for i, v := range versions {
+		assert.Equal(t, "test", v.Name)
+		assert.Equal(t, int64(i+3), v.Version)
+	}
+
+	// The oldest versions should have been pruned.
+	val, err := db.Get(getTracepointVersionKey("test", 1))
+	require.NoError(t, err)
+	assert.Nil(t, val)
+
+	version, err := ts.GetTracepointVersion("test", 5)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), version.Version)
+
+	version, err = ts.GetTracepointVersion("test", 1)
+	require.NoError(t, err)
+	assert.Nil(t, version)
+}
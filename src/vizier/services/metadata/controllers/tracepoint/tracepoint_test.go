@@ -19,10 +19,13 @@
 package tracepoint_test
 
 import (
+	"errors"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/vfs"
 	"github.com/gofrs/uuid"
 	"github.com/gogo/protobuf/proto"
 	"github.com/golang/mock/gomock"
@@ -37,6 +40,7 @@ import (
 	"px.dev/pixie/src/vizier/services/metadata/controllers/tracepoint"
 	mock_tracepoint "px.dev/pixie/src/vizier/services/metadata/controllers/tracepoint/mock"
 	"px.dev/pixie/src/vizier/services/metadata/storepb"
+	"px.dev/pixie/src/vizier/utils/datastore/pebbledb"
 )
 
 func TestCreateTracepoint(t *testing.T) {
@@ -263,31 +267,25 @@ func TestCreateTracepoint(t *testing.T) {
 			if !test.expectError && !test.expectTTLUpdateOnly {
 				mockTracepointStore.
 					EXPECT().
-					UpsertTracepoint(gomock.Any(), gomock.Any()).
-					DoAndReturn(func(id uuid.UUID, tpInfo *storepb.TracepointInfo) error {
-						newID = id
+					GetTracepointVersions("test_tracepoint").
+					Return(nil, nil)
+
+				mockTracepointStore.
+					EXPECT().
+					CreateTracepointVersion(gomock.Any(), time.Second*5, gomock.Any()).
+					DoAndReturn(func(tpInfo *storepb.TracepointInfo, ttl time.Duration, version *storepb.TracepointVersion) error {
+						newID = utils.UUIDFromProtoOrNil(tpInfo.ID)
 						assert.Equal(t, &storepb.TracepointInfo{
 							Tracepoint:    test.newTracepoint,
 							Name:          "test_tracepoint",
-							ID:            utils.ProtoFromUUID(id),
+							ID:            utils.ProtoFromUUID(newID),
 							ExpectedState: statuspb.RUNNING_STATE,
+							Version:       1,
 						}, tpInfo)
-						return nil
-					})
-
-				mockTracepointStore.
-					EXPECT().
-					SetTracepointWithName("test_tracepoint", gomock.Any()).
-					DoAndReturn(func(name string, id uuid.UUID) error {
-						assert.Equal(t, newID, id)
-						return nil
-					})
-
-				mockTracepointStore.
-					EXPECT().
-					SetTracepointTTL(gomock.Any(), time.Second*5).
-					DoAndReturn(func(id uuid.UUID, ttl time.Duration) error {
-						assert.Equal(t, newID, id)
+						assert.Equal(t, "test_tracepoint", version.Name)
+						assert.Equal(t, int64(1), version.Version)
+						assert.Equal(t, utils.ProtoFromUUID(newID), version.ID)
+						assert.Equal(t, test.newTracepoint, version.Tracepoint)
 						return nil
 					})
 			}
@@ -296,7 +294,7 @@ func TestCreateTracepoint(t *testing.T) {
 			tracepointMgr := tracepoint.NewManager(mockTracepointStore, mockAgtMgr, 5*time.Second)
 			defer tracepointMgr.Close()
 
-			actualTpID, err := tracepointMgr.CreateTracepoint("test_tracepoint", test.newTracepoint, time.Second*5)
+			actualTpID, err := tracepointMgr.CreateTracepoint("test_tracepoint", test.newTracepoint, time.Second*5, "", nil, nil)
 			if test.expectError || test.expectTTLUpdateOnly {
 				assert.Equal(t, tracepoint.ErrTracepointAlreadyExists, err)
 			} else {
@@ -472,6 +470,11 @@ func TestUpdateAgentTracepointStatus(t *testing.T) {
 		UpdateTracepointState(expectedTracepointState).
 		Return(nil)
 
+	mockTracepointStore.
+		EXPECT().
+		GetTracepoint(tpID).
+		Return(&storepb.TracepointInfo{ID: utils.ProtoFromUUID(tpID)}, nil)
+
 	err := tracepointMgr.UpdateAgentTracepointStatus(utils.ProtoFromUUID(tpID), utils.ProtoFromUUID(agentUUID1), statuspb.RUNNING_STATE, nil)
 	require.NoError(t, err)
 }
@@ -605,6 +608,51 @@ func TestTTLExpiration(t *testing.T) {
 	assert.Contains(t, seenDeletions, tpID3.String())
 }
 
+func TestTTLExpiration_Watch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockAgtMgr := mock_agent.NewMockManager(ctrl)
+
+	c, err := pebble.Open("test", &pebble.Options{FS: vfs.NewMem()})
+	require.NoError(t, err)
+	db := pebbledb.New(c, 10*time.Millisecond)
+	defer db.Close()
+	ts := tracepoint.NewDatastore(db)
+
+	tpID := uuid.Must(uuid.NewV4())
+	require.NoError(t, ts.UpsertTracepoint(tpID, &storepb.TracepointInfo{ID: utils.ProtoFromUUID(tpID)}))
+
+	removed := make(chan string, 1)
+	mockAgtMgr.
+		EXPECT().
+		MessageActiveAgents(gomock.Any()).
+		DoAndReturn(func(msg []byte) error {
+			vzMsg := &messagespb.VizierMessage{}
+			require.NoError(t, proto.Unmarshal(msg, vzMsg))
+			removed <- utils.ProtoToUUIDStr(vzMsg.GetTracepointMessage().GetRemoveTracepointRequest().ID)
+			return nil
+		})
+
+	require.NoError(t, ts.SetTracepointTTL(tpID, 100*time.Millisecond))
+
+	// The TTL is still active when the manager starts, and the sweep period is long enough that the
+	// tracepoint can only be terminated by the watch.
+	start := time.Now()
+	tracepointMgr := tracepoint.NewManager(ts, mockAgtMgr, time.Hour)
+	defer tracepointMgr.Close()
+
+	select {
+	case id := <-removed:
+		assert.Equal(t, tpID.String(), id)
+		assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Tracepoint was not terminated after its TTL expired")
+	}
+	tp, err := ts.GetTracepoint(tpID)
+	require.NoError(t, err)
+	assert.Equal(t, statuspb.TERMINATED_STATE, tp.ExpectedState)
+}
+
 func TestUpdateAgentTracepointStatus_RemoveTracepoints(t *testing.T) {
 	// Set up mock.
 	ctrl := gomock.NewController(t)
@@ -633,3 +681,132 @@ func TestUpdateAgentTracepointStatus_RemoveTracepoints(t *testing.T) {
 	err := tracepointMgr.RemoveTracepoints([]string{"test1", "test2"})
 	require.NoError(t, err)
 }
+
+func TestRollbackTracepoint(t *testing.T) {
+	programWithFields := func(symbol string, fields ...string) *logicalpb.TracepointDeployment {
+		return &logicalpb.TracepointDeployment{
+			Programs: []*logicalpb.TracepointDeployment_TracepointProgram{
+				{
+					TableName: "table1",
+					Spec: &logicalpb.TracepointSpec{
+						Outputs: []*logicalpb.Output{
+							{
+								Name:   "table1",
+								Fields: fields,
+							},
+						},
+						Probe: &logicalpb.Probe{
+							Tracepoint: &logicalpb.Tracepoint{Symbol: symbol},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name              string
+		targetVersion     *logicalpb.TracepointDeployment
+		currentTracepoint *logicalpb.TracepointDeployment
+		expectedErr       error
+	}{
+		{
+			name:              "rollback",
+			targetVersion:     programWithFields("main.v1", "abc"),
+			currentTracepoint: programWithFields("main.v2", "abc"),
+		},
+		{
+			name:        "version not found",
+			expectedErr: tracepoint.ErrTracepointVersionNotFound,
+		},
+		{
+			name:              "schema mismatch",
+			targetVersion:     programWithFields("main.v1", "abc"),
+			currentTracepoint: programWithFields("main.v2", "abc", "def"),
+			expectedErr:       tracepoint.ErrTracepointSchemaMismatch,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockTracepointStore := mock_tracepoint.NewMockStore(ctrl)
+			mockAgtMgr := mock_agent.NewMockManager(ctrl)
+
+			origID := uuid.Must(uuid.NewV4())
+			var target *storepb.TracepointVersion
+			if test.targetVersion != nil {
+				target = &storepb.TracepointVersion{
+					Name:       "test_tracepoint",
+					Version:    1,
+					Tracepoint: test.targetVersion,
+				}
+			}
+
+			mockTracepointStore.
+				EXPECT().
+				GetTracepointVersion("test_tracepoint", int64(1)).
+				Return(target, nil)
+
+			if test.currentTracepoint != nil {
+				mockTracepointStore.
+					EXPECT().
+					GetTracepointsWithNames([]string{"test_tracepoint"}).
+					Return([]*uuid.UUID{&origID}, nil).
+					AnyTimes()
+				mockTracepointStore.
+					EXPECT().
+					GetTracepoint(origID).
+					Return(&storepb.TracepointInfo{
+						ID:            utils.ProtoFromUUID(origID),
+						ExpectedState: statuspb.RUNNING_STATE,
+						Tracepoint:    test.currentTracepoint,
+						Version:       2,
+					}, nil).
+					AnyTimes()
+			}
+
+			var newID uuid.UUID
+			if test.expectedErr == nil {
+				mockTracepointStore.
+					EXPECT().
+					DeleteTracepointTTLs([]uuid.UUID{origID}).
+					Return(nil)
+				mockTracepointStore.
+					EXPECT().
+					GetTracepointVersions("test_tracepoint").
+					Return([]*storepb.TracepointVersion{
+						target,
+						{Name: "test_tracepoint", Version: 2, Tracepoint: test.currentTracepoint},
+					}, nil)
+				mockTracepointStore.
+					EXPECT().
+					CreateTracepointVersion(gomock.Any(), time.Second*5, gomock.Any()).
+					DoAndReturn(func(tpInfo *storepb.TracepointInfo, ttl time.Duration, version *storepb.TracepointVersion) error {
+						newID = utils.UUIDFromProtoOrNil(tpInfo.ID)
+						assert.Equal(t, int64(3), tpInfo.Version)
+						assert.Equal(t, test.targetVersion, tpInfo.Tracepoint)
+						assert.Equal(t, int64(3), version.Version)
+						assert.Equal(t, int64(1), version.RollbackOfVersion)
+						assert.Equal(t, []*storepb.TracepointProgramDiff{
+							{TableName: "table1", Change: storepb.TP_PROGRAM_MODIFIED},
+						}, version.Diff)
+						return nil
+					})
+			}
+
+			tracepointMgr := tracepoint.NewManager(mockTracepointStore, mockAgtMgr, 5*time.Second)
+			defer tracepointMgr.Close()
+
+			tp, _, err := tracepointMgr.RollbackTracepoint("test_tracepoint", 1, 5*time.Second, "")
+			if test.expectedErr != nil {
+				assert.True(t, errors.Is(err, test.expectedErr))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, newID, utils.UUIDFromProtoOrNil(tp.ID))
+			assert.Equal(t, int64(3), tp.Version)
+		})
+	}
+}
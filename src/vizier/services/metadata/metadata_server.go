@@ -30,6 +30,7 @@ import (
 
 	"github.com/cockroachdb/pebble"
 	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
@@ -73,6 +74,16 @@ func init() {
 	pflag.String("pod_namespace", "pl", "The namespace this pod runs in. Used for leader elections")
 	pflag.String("nats_url", "pl-nats", "The URL of NATS")
 	pflag.Bool("use_etcd_operator", false, "Whether the etcd operator should be used instead of the persistent version.")
+	pflag.Int("max_tracepoints", 0, "The maximum number of active tracepoints in the cluster. 0 is unlimited.")
+	pflag.Int("max_tracepoints_per_user", 0, "The maximum number of active tracepoints per user. 0 is unlimited.")
+	pflag.Duration("max_tracepoint_ttl", 0, "The maximum TTL of a tracepoint. 0 is unlimited.")
+	pflag.StringSlice("denied_tracepoint_binaries", []string{}, "Names or paths of binaries and shared libraries that tracepoints may not probe. A trailing '*' matches any suffix.")
+	pflag.StringSlice("denied_tracepoint_symbols", []string{}, "Symbols that tracepoints may not probe. A trailing '*' matches any suffix.")
+	pflag.String("owner_kinds_configmap", "pl-owner-kinds", "The ConfigMap listing the custom resource kinds, one group/version/Kind per line under the 'kinds' key, to watch as owners of other objects. Read at startup.")
+	pflag.StringSlice("allowed_namespaces", []string{}, "If set, only objects in these namespaces are observed. Cluster-scoped objects, such as nodes, are always observed.")
+	pflag.StringSlice("denied_namespaces", []string{}, "Objects in these namespaces are never observed.")
+	pflag.Uint64("pebble_soft_size_limit", 0, "The size in bytes past which the pebble datastore is reported as over its limit. 0 is unlimited.")
+	pflag.Uint64("pebble_hard_size_limit", 0, "The size in bytes past which the oldest K8s history is evicted from the pebble datastore. 0 is unlimited.")
 
 	// Metadata flags are set using the env vars in pl-cluster-config.
 	// We historically set PL_ETCD_OPERATOR_ENABLED but not PL_USE_ETCD_OPERATOR in the configmap.
@@ -140,7 +151,14 @@ func mustInitPebbleDatastore() *pebbledb.DataStore {
 	if err != nil {
 		log.WithError(err).Fatal("Failed to open pebble database. If out of space, increase the storage size of the `metadata-pv-claim` PersistentVolumeClaim and restart the vizier-metadata pod")
 	}
-	return pebbledb.New(pebbleDb, pebbledbTTLDuration)
+	ds := pebbledb.New(pebbleDb, pebbledbTTLDuration)
+	ds.SetSizeLimits(&pebbledb.SizeLimits{
+		SoftLimitBytes:   viper.GetUint64("pebble_soft_size_limit"),
+		HardLimitBytes:   viper.GetUint64("pebble_hard_size_limit"),
+		EvictionPrefixes: k8smeta.HistoryPrefixes,
+	})
+	prometheus.MustRegister(pebbledb.NewMetricsCollector(ds))
+	return ds
 }
 
 func etcdTLSConfig() (*tls.Config, error) {
@@ -230,12 +248,17 @@ func main() {
 	}
 	defer dataStore.Close()
 
+	nsScope, err := k8smeta.NewNamespaceScope(viper.GetStringSlice("allowed_namespaces"), viper.GetStringSlice("denied_namespaces"))
+	if err != nil {
+		log.WithError(err).Fatal("Invalid namespace scope")
+	}
+
 	k8sMds := k8smeta.NewDatastore(dataStore)
 	// Listen for K8s metadata updates.
 	updateCh := make(chan *k8smeta.K8sResourceMessage)
-	mdh := k8smeta.NewHandler(updateCh, k8sMds, k8sMds, nc)
+	mdh := k8smeta.NewHandler(updateCh, k8sMds, k8sMds, nc, nsScope)
 
-	k8sMc, err := k8smeta.NewController(updateCh)
+	k8sMc, err := k8smeta.NewController(updateCh, viper.GetString("pod_namespace"), viper.GetString("owner_kinds_configmap"), nsScope)
 	defer k8sMc.Stop()
 
 	ads := agent.NewDatastore(dataStore, 24*time.Hour)
@@ -263,6 +286,13 @@ func main() {
 	// Initialize tracepoint handler.
 	tracepointMgr := tracepoint.NewManager(tds, agtMgr, 30*time.Second)
 	defer tracepointMgr.Close()
+	tracepointMgr.SetPolicy(&tracepoint.Policy{
+		MaxTracepoints:        viper.GetInt("max_tracepoints"),
+		MaxTracepointsPerUser: viper.GetInt("max_tracepoints_per_user"),
+		MaxTTL:                viper.GetDuration("max_tracepoint_ttl"),
+		DeniedBinaries:        viper.GetStringSlice("denied_tracepoint_binaries"),
+		DeniedSymbols:         viper.GetStringSlice("denied_tracepoint_symbols"),
+	})
 
 	mc, err := controllers.NewMessageBusController(nc, agtMgr, tracepointMgr,
 		mdh, &isLeader)
@@ -281,7 +311,7 @@ func main() {
 	healthz.RegisterDefaultChecks(mux)
 	metrics.MustRegisterMetricsHandlerNoDefaultMetrics(mux)
 
-	svr := controllers.NewServer(env, dataStore, agtMgr, tracepointMgr)
+	svr := controllers.NewServer(env, dataStore, agtMgr, tracepointMgr, mdh, k8sMds, k8sMds)
 
 	csDs := cronscript.NewDatastore(dataStore)
 	cronScriptSvr := cronscript.New(csDs)
@@ -298,6 +328,7 @@ func main() {
 	metadatapb.RegisterMetadataTracepointServiceServer(s.GRPCServer(), svr)
 	metadatapb.RegisterMetadataConfigServiceServer(s.GRPCServer(), svr)
 	metadatapb.RegisterCronScriptStoreServiceServer(s.GRPCServer(), cronScriptSvr)
+	metadatapb.RegisterMetadataAdminServiceServer(s.GRPCServer(), svr)
 
 	s.Start()
 	s.StopOnInterrupt()
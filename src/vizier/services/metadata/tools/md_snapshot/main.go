@@ -0,0 +1,255 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+// md_snapshot creates, verifies and restores snapshots of the metadata service's datastore.
+//
+//	md_snapshot create --file=snapshot.pxs
+//	md_snapshot verify --file=snapshot.pxs
+//	md_snapshot restore --file=snapshot.pxs --clear
+//
+// By default, the tool creates snapshots with the metadata service's admin RPCs. If --pebble_dir or
+// --etcd_server is set, the tool operates directly on that datastore instead. Restoring always operates
+// directly on a datastore, which must not be in use by a running metadata service, since the service
+// keeps state in memory that would not match the restored keys. Scale the metadata service down before
+// restoring, and back up once the restore is done. Together these allow migrating from etcd to pebble
+// by creating a snapshot from an etcd backed metadata service and restoring it into the pebble datastore.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"go.etcd.io/etcd/client/pkg/v3/transport"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"px.dev/pixie/src/shared/services"
+	"px.dev/pixie/src/shared/services/utils"
+	"px.dev/pixie/src/vizier/services/metadata/metadatapb"
+	"px.dev/pixie/src/vizier/utils/datastore"
+	"px.dev/pixie/src/vizier/utils/datastore/etcd"
+	"px.dev/pixie/src/vizier/utils/datastore/pebbledb"
+	"px.dev/pixie/src/vizier/utils/datastore/snapshot"
+)
+
+func init() {
+	pflag.String("mds_addr", "vizier-metadata-svc:50400", "The address of the metadata service.")
+	pflag.String("pebble_dir", "", "If set, operate directly on the pebble datastore in this directory instead of the metadata service.")
+	pflag.String("etcd_server", "", "If set, operate directly on the etcd datastore at this address instead of the metadata service.")
+	pflag.String("file", "", "The snapshot archive to write to, or read from. Defaults to stdout or stdin.")
+	pflag.StringSlice("prefixes", nil, "The key prefixes to include in the snapshot. Defaults to all of the metadata service's keys.")
+	pflag.Bool("clear", false, "Delete the existing keys with each prefix in the snapshot before restoring it.")
+}
+
+func printManifest(m *metadatapb.SnapshotManifest) {
+	log.WithField("version", m.Version).
+		WithField("entries", m.Entries).
+		WithField("checksum", m.Checksum).
+		Info("Snapshot")
+	for _, s := range m.Sections {
+		log.WithField("prefix", s.Prefix).WithField("entries", s.Entries).Info("Section")
+	}
+}
+
+func openArchive(create bool) (*os.File, error) {
+	path := viper.GetString("file")
+	if path == "" || path == "-" {
+		if create {
+			return os.Stdout, nil
+		}
+		return os.Stdin, nil
+	}
+	if create {
+		return os.Create(path)
+	}
+	return os.Open(path)
+}
+
+// snapshotDatastore is a datastore that snapshots can be created from and restored to.
+type snapshotDatastore interface {
+	datastore.Scanner
+	snapshot.Target
+	datastore.Closer
+}
+
+// mustOpenDatastore opens the datastore set by --pebble_dir or --etcd_server, if any.
+func mustOpenDatastore() snapshotDatastore {
+	if viper.GetString("pebble_dir") != "" {
+		db, err := pebble.Open(viper.GetString("pebble_dir"), &pebble.Options{})
+		if err != nil {
+			log.WithError(err).Fatal("Failed to open pebble database")
+		}
+		return pebbledb.New(db, time.Minute)
+	}
+	if viper.GetString("etcd_server") == "" {
+		return nil
+	}
+
+	var tlsConfig *tls.Config
+	if !viper.GetBool("disable_ssl") {
+		tlsInfo := transport.TLSInfo{
+			CertFile:      viper.GetString("client_tls_cert"),
+			KeyFile:       viper.GetString("client_tls_key"),
+			TrustedCAFile: viper.GetString("tls_ca_cert"),
+		}
+		var err error
+		tlsConfig, err = tlsInfo.ClientConfig()
+		if err != nil {
+			log.WithError(err).Fatal("Failed to load SSL for etcd")
+		}
+	}
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   []string{viper.GetString("etcd_server")},
+		DialTimeout: 5 * time.Second,
+		TLS:         tlsConfig,
+	})
+	if err != nil {
+		log.WithError(err).Fatal("Failed to connect to etcd")
+	}
+	return etcd.New(client)
+}
+
+func mustConnectMetadata() (metadatapb.MetadataAdminServiceClient, context.Context) {
+	if viper.GetString("jwt_signing_key") == "" {
+		log.Fatal("Flag --jwt_signing_key or ENV PL_JWT_SIGNING_KEY is required to connect to the metadata service")
+	}
+
+	dialOpts, err := services.GetGRPCClientDialOpts()
+	if err != nil {
+		log.WithError(err).Fatal("Failed to get dial options")
+	}
+	conn, err := grpc.Dial(viper.GetString("mds_addr"), dialOpts...)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to connect to metadata service")
+	}
+
+	claims := utils.GenerateJWTForService("md_snapshot", "vizier")
+	token, err := utils.SignJWTClaims(claims, viper.GetString("jwt_signing_key"))
+	if err != nil {
+		log.WithError(err).Fatal("Failed to sign JWT")
+	}
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization",
+		fmt.Sprintf("bearer %s", token))
+	return metadatapb.NewMetadataAdminServiceClient(conn), ctx
+}
+
+func create(out io.Writer) (*metadatapb.SnapshotManifest, error) {
+	prefixes := viper.GetStringSlice("prefixes")
+	if ds := mustOpenDatastore(); ds != nil {
+		defer ds.Close()
+		if len(prefixes) == 0 {
+			prefixes = snapshot.DefaultPrefixes
+		}
+		m, err := snapshot.Write(out, ds, prefixes)
+		if err != nil {
+			return nil, err
+		}
+		return m.ToProto(), nil
+	}
+
+	client, ctx := mustConnectMetadata()
+	stream, err := client.CreateSnapshot(ctx, &metadatapb.CreateSnapshotRequest{Prefixes: prefixes})
+	if err != nil {
+		return nil, err
+	}
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := out.Write(resp.Data); err != nil {
+			return nil, err
+		}
+		if resp.Manifest != nil {
+			return resp.Manifest, nil
+		}
+	}
+}
+
+func verify(in io.Reader) (*metadatapb.SnapshotManifest, error) {
+	m, err := snapshot.Verify(in)
+	if err != nil {
+		return nil, err
+	}
+	return m.ToProto(), nil
+}
+
+func restore(in io.Reader) (*metadatapb.SnapshotManifest, error) {
+	ds := mustOpenDatastore()
+	if ds == nil {
+		log.Fatal("Restoring requires --pebble_dir or --etcd_server, since a running metadata service can't be restored")
+	}
+	defer ds.Close()
+	m, err := snapshot.Restore(in, ds, &snapshot.RestoreOptions{Clear: viper.GetBool("clear")})
+	if err != nil {
+		return nil, err
+	}
+	log.Info("Restored snapshot, the metadata service can now be started")
+	return m.ToProto(), nil
+}
+
+func main() {
+	services.SetupSSLClientFlags()
+	services.PostFlagSetupAndParse()
+	services.CheckSSLClientFlags()
+
+	if pflag.NArg() != 1 {
+		log.Fatal("Expected exactly one command: create, verify or restore")
+	}
+
+	command := pflag.Arg(0)
+	var manifest *metadatapb.SnapshotManifest
+	var err error
+	switch command {
+	case "create":
+		f, openErr := openArchive(true)
+		if openErr != nil {
+			log.WithError(openErr).Fatal("Failed to open snapshot archive")
+		}
+		manifest, err = create(f)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	case "verify", "restore":
+		f, openErr := openArchive(false)
+		if openErr != nil {
+			log.WithError(openErr).Fatal("Failed to open snapshot archive")
+		}
+		if command == "verify" {
+			manifest, err = verify(f)
+		} else {
+			manifest, err = restore(f)
+		}
+		f.Close()
+	default:
+		log.Fatalf("Unknown command '%s': expected create, verify or restore", command)
+	}
+	if err != nil {
+		log.WithError(err).Fatalf("Failed to %s snapshot", command)
+	}
+	printManifest(manifest)
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"px.dev/pixie/src/api/proto/vizierpb"
+)
+
+const (
+	// defaultOTelPort is the port which OTLP/gRPC endpoints listen on, if the export URL doesn't have one.
+	defaultOTelPort = "4317"
+	// exportEndpointTimeout is how long connecting to the export endpoint may take during a dry run.
+	exportEndpointTimeout = 5 * time.Second
+)
+
+// exportEndpointAddress gets the host and port to connect to for an export URL. Export URLs are
+// usually given as host:port, but may also have a scheme.
+func exportEndpointAddress(exportURL string) (string, error) {
+	addr := exportURL
+	if strings.Contains(exportURL, "://") {
+		u, err := url.Parse(exportURL)
+		if err != nil {
+			return "", err
+		}
+		addr = u.Host
+		if u.Port() == "" {
+			switch u.Scheme {
+			case "https":
+				addr = net.JoinHostPort(u.Hostname(), "443")
+			case "http":
+				addr = net.JoinHostPort(u.Hostname(), "80")
+			default:
+				addr = net.JoinHostPort(u.Hostname(), defaultOTelPort)
+			}
+		}
+	} else if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(strings.TrimSuffix(addr, "/"), defaultOTelPort)
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+	if host == "" {
+		return "", fmt.Errorf("export URL %q has no host", exportURL)
+	}
+	return addr, nil
+}
+
+// checkExportEndpoint checks whether a connection can be opened to an export URL from the Vizier,
+// which is where the data would be exported from.
+func checkExportEndpoint(ctx context.Context, exportURL string) *vizierpb.ExportEndpointStatus {
+	endpointStatus := &vizierpb.ExportEndpointStatus{URL: exportURL}
+	addr, err := exportEndpointAddress(exportURL)
+	if err != nil {
+		log.WithError(err).WithField("url", exportURL).Info("Invalid export endpoint")
+		return endpointStatus
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, exportEndpointTimeout)
+	defer cancel()
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		log.WithError(err).WithField("url", exportURL).Info("Export endpoint is unreachable")
+		return endpointStatus
+	}
+	_ = conn.Close()
+	endpointStatus.Reachable = true
+	return endpointStatus
+}
@@ -0,0 +1,130 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gogo/protobuf/jsonpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"px.dev/pixie/src/api/proto/vizierpb"
+	"px.dev/pixie/src/shared/services/authcontext"
+	"px.dev/pixie/src/vizier/services/metadata/metadatapb"
+	"px.dev/pixie/src/vizier/services/metadata/storepb"
+)
+
+// GetK8SHistory queries the history of the K8s objects observed by the metadata service.
+func (s *Server) GetK8SHistory(ctx context.Context, req *vizierpb.GetK8SHistoryRequest) (*vizierpb.GetK8SHistoryResponse, error) {
+	if s.mdk8s == nil {
+		return nil, status.Error(codes.Unavailable, "K8s history is not available")
+	}
+	aCtx, err := authcontext.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", fmt.Sprintf("bearer %s", aCtx.AuthToken))
+
+	switch q := req.Query.(type) {
+	case *vizierpb.GetK8SHistoryRequest_ObjectHistory:
+		h := q.ObjectHistory
+		resp, err := s.mdk8s.GetK8SObjectHistory(ctx, &metadatapb.K8SObjectHistoryRequest{
+			ObjectType:  h.ObjectType,
+			Namespace:   h.Namespace,
+			Name:        h.Name,
+			StartTimeNS: h.StartTimeNS,
+			EndTimeNS:   h.EndTimeNS,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return historyResponse(h.ObjectType, h.Namespace, h.Name, resp.Entries...)
+	case *vizierpb.GetK8SHistoryRequest_PodByIP:
+		resp, err := s.mdk8s.GetPodByIP(ctx, &metadatapb.PodByIPRequest{
+			IP:     q.PodByIP.IP,
+			TimeNS: q.PodByIP.TimeNS,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if resp.Pod == nil {
+			return &vizierpb.GetK8SHistoryResponse{}, nil
+		}
+		md := resp.Pod.GetResource().GetPod().GetMetadata()
+		return historyResponse("pods", md.GetNamespace(), md.GetName(), resp.Pod)
+	case *vizierpb.GetK8SHistoryRequest_ObjectDiff:
+		d := q.ObjectDiff
+		resp, err := s.mdk8s.GetK8SObjectDiff(ctx, &metadatapb.K8SObjectDiffRequest{
+			ObjectType:  d.ObjectType,
+			Namespace:   d.Namespace,
+			Name:        d.Name,
+			FromVersion: d.FromVersion,
+			ToVersion:   d.ToVersion,
+		})
+		if err != nil {
+			return nil, err
+		}
+		hResp, err := historyResponse(d.ObjectType, d.Namespace, d.Name, resp.From, resp.To)
+		if err != nil {
+			return nil, err
+		}
+		hResp.Diff = resp.Diff
+		return hResp, nil
+	case *vizierpb.GetK8SHistoryRequest_ObjectRestarts:
+		r := q.ObjectRestarts
+		resp, err := s.mdk8s.GetK8SObjectRestarts(ctx, &metadatapb.K8SObjectRestartsRequest{
+			ObjectType:  r.ObjectType,
+			Namespace:   r.Namespace,
+			Name:        r.Name,
+			StartTimeNS: r.StartTimeNS,
+			EndTimeNS:   r.EndTimeNS,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return historyResponse(r.ObjectType, r.Namespace, r.Name, resp.Restarts...)
+	}
+	return nil, status.Error(codes.InvalidArgument, "K8s history query must be specified")
+}
+
+// historyResponse converts versions of an object stored by the metadata service into a response.
+func historyResponse(objectType, namespace, name string, entries ...*storepb.K8SResourceHistoryEntry) (*vizierpb.GetK8SHistoryResponse, error) {
+	m := jsonpb.Marshaler{}
+	resp := &vizierpb.GetK8SHistoryResponse{
+		Versions: make([]*vizierpb.K8SObjectVersion, len(entries)),
+	}
+	for i, e := range entries {
+		objectJSON, err := m.MarshalToString(e.Resource)
+		if err != nil {
+			return nil, err
+		}
+		resp.Versions[i] = &vizierpb.K8SObjectVersion{
+			UpdateVersion: e.UpdateVersion,
+			TimestampNS:   e.TimestampNS,
+			ObjectType:    objectType,
+			Namespace:     namespace,
+			Name:          name,
+			ObjectJSON:    objectJSON,
+		}
+	}
+	return resp, nil
+}
@@ -23,6 +23,7 @@ import (
 	"fmt"
 
 	"github.com/gofrs/uuid"
+	"github.com/gogo/protobuf/types"
 	log "github.com/sirupsen/logrus"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
@@ -109,6 +110,9 @@ func (m *MutationExecutorImpl) Execute(ctx context.Context, req *vizierpb.Execut
 		// No mutations to apply.
 		return nil, nil
 	}
+	if err := checkMutationPermissions(ctx); err != nil {
+		return nil, err
+	}
 
 	registerTracepointsReq := &metadatapb.RegisterTracepointRequest{
 		Requests: make([]*metadatapb.RegisterTracepointRequest_TracepointRequest, 0),
@@ -134,6 +138,10 @@ func (m *MutationExecutorImpl) Execute(ctx context.Context, req *vizierpb.Execut
 					})
 
 				if _, ok := m.activeTracepoints[name]; ok {
+					// Stop tracking the tracepoints from this request that were never registered.
+					for _, tp := range registerTracepointsReq.Requests[:len(registerTracepointsReq.Requests)-1] {
+						delete(m.activeTracepoints, tp.Name)
+					}
 					return nil, fmt.Errorf("tracepoint with name '%s', already used", name)
 				}
 				for _, tracepoint := range mut.Trace.Programs {
@@ -166,20 +174,35 @@ func (m *MutationExecutorImpl) Execute(ctx context.Context, req *vizierpb.Execut
 		if err != nil {
 			log.WithError(err).
 				Errorf("Failed to register tracepoints")
+			for _, tp := range registerTracepointsReq.Requests {
+				delete(m.activeTracepoints, tp.Name)
+			}
 			return nil, ErrTracepointRegistrationFailed
 		}
-		if resp.Status != nil && resp.Status.ErrCode != statuspb.OK {
-			log.WithField("status", resp.Status.String()).
-				Errorf("Failed to register tracepoints with bad status")
-
-			return resp.Status, ErrTracepointRegistrationFailed
-		}
 
-		// Update the internal stat of the tracepoints.
+		// Update the internal stat of the tracepoints. Tracepoints which weren't registered, such as
+		// ones that violate the tracepoint policy, are no longer tracked.
+		registered := make(map[string]bool)
 		for _, tp := range resp.Tracepoints {
+			if tp == nil || tp.ID == nil {
+				continue
+			}
 			id := utils.UUIDFromProtoOrNil(tp.ID)
 			m.activeTracepoints[tp.Name].ID = id
 			m.activeTracepoints[tp.Name].Status = tp.Status
+			registered[tp.Name] = true
+		}
+		for _, tp := range registerTracepointsReq.Requests {
+			if !registered[tp.Name] {
+				delete(m.activeTracepoints, tp.Name)
+			}
+		}
+
+		if resp.Status != nil && resp.Status.ErrCode != statuspb.OK {
+			log.WithField("status", resp.Status.String()).
+				Errorf("Failed to register tracepoints with bad status")
+			// Return the status so that the reason, such as a quota or policy violation, is surfaced to the user.
+			return resp.Status, nil
 		}
 	}
 	if len(deleteTracepointsReq.Names) > 0 {
@@ -239,6 +262,20 @@ func (m *MutationExecutorImpl) MutationInfo(ctx context.Context) (*vizierpb.Muta
 		Status: &vizierpb.Status{Code: 0},
 		States: make([]*vizierpb.MutationInfo_MutationState, len(resp.Tracepoints)),
 	}
+	if u := resp.Usage; u != nil {
+		mutationInfo.TracepointUsage = &vizierpb.TracepointUsage{
+			ActiveTracepoints:     u.ActiveTracepoints,
+			MaxTracepoints:        u.MaxTracepoints,
+			UserActiveTracepoints: u.UserActiveTracepoints,
+			MaxTracepointsPerUser: u.MaxTracepointsPerUser,
+		}
+		if u.MaxTTL != nil {
+			maxTTL, err := types.DurationFromProto(u.MaxTTL)
+			if err == nil {
+				mutationInfo.TracepointUsage.MaxTTLNS = maxTTL.Nanoseconds()
+			}
+		}
+	}
 
 	ready := true
 	for idx, tp := range resp.Tracepoints {
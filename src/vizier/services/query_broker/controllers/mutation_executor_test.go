@@ -19,12 +19,25 @@
 package controllers_test
 
 import (
+	"context"
 	"testing"
 
 	"github.com/gogo/protobuf/proto"
 	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
+	"px.dev/pixie/src/api/proto/vizierpb"
 	"px.dev/pixie/src/carnot/planner/distributedpb"
+	"px.dev/pixie/src/carnot/planner/plannerpb"
+	"px.dev/pixie/src/shared/services/authcontext"
+	serviceUtils "px.dev/pixie/src/shared/services/utils"
+	"px.dev/pixie/src/vizier/services/metadata/metadatapb"
+	mock_metadatapb "px.dev/pixie/src/vizier/services/metadata/metadatapb/mock"
+	"px.dev/pixie/src/vizier/services/query_broker/controllers"
+	mock_controllers "px.dev/pixie/src/vizier/services/query_broker/controllers/mock"
 )
 
 func TestMutationExecutor_Execute(t *testing.T) {
@@ -39,3 +52,73 @@ func TestMutationExecutor_Execute(t *testing.T) {
 
 	t.Skip("These tests are incomplete and need to be done")
 }
+
+func TestMutationExecutor_Permissions(t *testing.T) {
+	deleteTracepoint := &plannerpb.CompileMutationsResponse{
+		Mutations: []*plannerpb.CompileMutation{
+			{
+				Mutation: &plannerpb.CompileMutation_DeleteTracepoint{
+					DeleteTracepoint: &plannerpb.DeleteTracepoint{Name: "tp"},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		role      string
+		scopes    []string
+		mutations *plannerpb.CompileMutationsResponse
+		allowed   bool
+	}{
+		{name: "no role", mutations: deleteTracepoint},
+		{name: "viewer", role: serviceUtils.OrgRoleViewer, mutations: deleteTracepoint},
+		{name: "viewer without mutations", role: serviceUtils.OrgRoleViewer, mutations: &plannerpb.CompileMutationsResponse{}, allowed: true},
+		{name: "member", role: serviceUtils.OrgRoleMember, mutations: deleteTracepoint, allowed: true},
+		{
+			name:      "member script execute",
+			role:      serviceUtils.OrgRoleMember,
+			scopes:    []string{serviceUtils.APIKeyScopeScriptExecute},
+			mutations: deleteTracepoint,
+		},
+		{
+			name:      "member cluster admin",
+			role:      serviceUtils.OrgRoleMember,
+			scopes:    []string{serviceUtils.APIKeyScopeClusterAdmin},
+			mutations: deleteTracepoint,
+			allowed:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			planner := mock_controllers.NewMockPlanner(ctrl)
+			planner.EXPECT().CompileMutations(gomock.Any(), gomock.Any()).Return(test.mutations, nil)
+			mdtp := mock_metadatapb.NewMockMetadataTracepointServiceClient(ctrl)
+			if test.allowed && len(test.mutations.Mutations) > 0 {
+				mdtp.EXPECT().RemoveTracepoint(gomock.Any(), &metadatapb.RemoveTracepointRequest{Names: []string{"tp"}}).
+					Return(&metadatapb.RemoveTracepointResponse{}, nil)
+			}
+
+			auth := authcontext.New()
+			auth.Claims = serviceUtils.GenerateJWTForCluster("vizier_cluster", "vizier")
+			auth.Claims.GetClusterClaims().OrgRole = test.role
+			auth.Claims.GetClusterClaims().APIKeyScopes = test.scopes
+			ctx := authcontext.NewContext(context.Background(), auth)
+
+			// The mutation flag of the request doesn't affect whether the mutations are allowed.
+			req := &vizierpb.ExecuteScriptRequest{QueryStr: "import pxtrace"}
+			m := controllers.NewMutationExecutor(planner, mdtp, nil, &distributedpb.DistributedState{})
+			s, err := m.Execute(ctx, req, nil)
+			if test.allowed {
+				require.NoError(t, err)
+				assert.Nil(t, s)
+			} else {
+				assert.Equal(t, codes.PermissionDenied, status.Code(err))
+			}
+		})
+	}
+}
@@ -153,6 +153,7 @@ func convertConfigs(config *vizierpb.Configs) *plannerpb.Configs {
 			EndTimeNs:   config.PluginConfig.EndTimeNs,
 		}
 	}
+	c.DryRunExports = config.DryRunExports
 
 	return c
 }
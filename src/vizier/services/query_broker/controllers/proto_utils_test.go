@@ -70,6 +70,7 @@ configs {
 	plugin_config {
 		start_time_ns: 10
 	}
+	dry_run_exports: true
 }
 `
 
@@ -102,6 +103,7 @@ configs {
 	plugin_config {
 		start_time_ns: 10
 	}
+	dry_run_exports: true
 }
 `
 
@@ -351,6 +351,7 @@ func (q *QueryExecutorImpl) compilePlan(ctx context.Context, resultCh chan<- *vi
 		OTelEndpointConfig:  otelConfig,
 		PluginConfig:        pluginConfig,
 		DebugInfo:           debugInfo,
+		DryRunExports:       req.Configs.GetDryRunExports(),
 	}
 
 	// Compile the query plan.
@@ -474,6 +475,17 @@ func (q *QueryExecutorImpl) prepareScript(ctx context.Context, resultCh chan<- *
 		return err
 	}
 
+	// Dry runs check the default export endpoint, since it isn't connected to while exporting.
+	if endpointURL := req.Configs.GetOTelEndpointConfig().GetURL(); req.Configs.GetDryRunExports() && endpointURL != "" {
+		resp := &vizierpb.ExecuteScriptResponse{
+			QueryID:              q.queryID.String(),
+			ExportEndpointStatus: checkExportEndpoint(ctx, endpointURL),
+		}
+		if err := q.sendResponse(ctx, resultCh, resp); err != nil {
+			return err
+		}
+	}
+
 	planMap, err := q.buildAgentPlanMap(plan)
 	if err != nil {
 		return err
@@ -492,7 +504,12 @@ func (q *QueryExecutorImpl) prepareScript(ctx context.Context, resultCh chan<- *
 		return err
 	}
 
-	err = q.resultForwarder.RegisterQuery(q.queryID, tableNameToIDMap, q.compilationTimeNs, queryPlanOpts, q.queryName)
+	// The agents which are missing from the final exec stats did not finish their part of the query.
+	agentIDs := make([]uuid.UUID, 0, len(planMap))
+	for agentID := range planMap {
+		agentIDs = append(agentIDs, agentID)
+	}
+	err = q.resultForwarder.RegisterQuery(q.queryID, tableNameToIDMap, q.compilationTimeNs, queryPlanOpts, q.queryName, agentIDs)
 	if err != nil {
 		return err
 	}
@@ -21,6 +21,7 @@ package controllers_test
 import (
 	"context"
 	"fmt"
+	"net"
 	"testing"
 
 	"github.com/gofrs/uuid"
@@ -271,6 +272,8 @@ func (f *fakeAgentsTracker) GetAgentInfo() tracker.AgentsInfo {
 	return f.agentsInfo
 }
 
+func (f *fakeAgentsTracker) ReportQueryFailures(queryID uuid.UUID, agentIDs []uuid.UUID) {}
+
 type fakeResultForwarder struct {
 	// Variables to pass in for ExecuteScript testing.
 	ClientResultsToSend []*vizierpb.ExecuteScriptResponse
@@ -282,6 +285,7 @@ type fakeResultForwarder struct {
 	QueryDeleted          uuid.UUID
 	QueryStreamed         uuid.UUID
 	StreamedQueryPlanOpts *controllers.QueryPlanOpts
+	RegisteredAgentIDs    []uuid.UUID
 
 	// Variables to set/use for TransferResultChunk testing.
 	ClientStreamClosed   bool
@@ -292,10 +296,11 @@ type fakeResultForwarder struct {
 // RegisterQuery registers a query.
 func (f *fakeResultForwarder) RegisterQuery(queryID uuid.UUID, tableIDMap map[string]string,
 	compilationTimeNs int64,
-	queryPlanOpts *controllers.QueryPlanOpts, queryName string) error {
+	queryPlanOpts *controllers.QueryPlanOpts, queryName string, agentIDs []uuid.UUID) error {
 	f.QueryRegistered = queryID
 	f.TableIDMap = tableIDMap
 	f.StreamedQueryPlanOpts = queryPlanOpts
+	f.RegisteredAgentIDs = agentIDs
 	return nil
 }
 
@@ -348,6 +353,7 @@ type queryExecTestCase struct {
 	StreamResultsErr           error
 	StreamResultsCallExpected  bool
 	MutExecFactory             controllers.MutationExecFactory
+	ExpectedEndpointStatus     *vizierpb.ExportEndpointStatus
 }
 
 type testConsumer struct {
@@ -416,7 +422,19 @@ func runTestCase(t *testing.T, test *queryExecTestCase) {
 	assert.Equal(t, test.QueryExecExpectedRunError, queryExec.Run(context.Background(), test.Req, consumer))
 	assert.Equal(t, test.QueryExecExpectedWaitError, queryExec.Wait())
 
-	require.Equalf(t, len(test.ExpectedResps)+len(test.TableNames), len(consumer.results), "query executor sent incorrect number of results to consumer")
+	numExpected := len(test.ExpectedResps) + len(test.TableNames)
+	if test.ExpectedEndpointStatus != nil {
+		numExpected++
+	}
+	require.Equalf(t, numExpected, len(consumer.results), "query executor sent incorrect number of results to consumer")
+
+	var endpointStatus *vizierpb.ExportEndpointStatus
+	for _, result := range consumer.results {
+		if result.ExportEndpointStatus != nil {
+			endpointStatus = result.ExportEndpointStatus
+		}
+	}
+	assert.Equal(t, test.ExpectedEndpointStatus, endpointStatus)
 
 	actualTableNames := make(map[string]bool)
 	for _, result := range consumer.results {
@@ -439,6 +457,11 @@ func runTestCase(t *testing.T, test *queryExecTestCase) {
 		}
 	}
 
+	// Every agent the query was launched on is tracked, whether or not the query is analyzed.
+	if rf.QueryRegistered != uuid.Nil {
+		assert.NotEmpty(t, rf.RegisteredAgentIDs)
+	}
+
 	if test.StreamResultsCallExpected {
 		assert.NotEqualf(t, uuid.Nil, rf.QueryStreamed, "Expected StreamResults to be called but it wasn't")
 	} else {
@@ -457,6 +480,7 @@ func TestQueryExecutor(t *testing.T) {
 		buildResumeQueryTestCase(t),
 		buildResumeQueryBadQueryIDTestCase(t),
 		buildMutationFailedQueryTestCase(t),
+		buildDryRunExportsTestCase(t),
 	}
 
 	for _, test := range tests {
@@ -529,6 +553,36 @@ func buildSimpleSuccessTestCase(t *testing.T) queryExecTestCase {
 	}
 }
 
+func buildDryRunExportsTestCase(t *testing.T) queryExecTestCase {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { lis.Close() })
+	endpointURL := lis.Addr().String()
+
+	plannerState := buildPlannerState(t, singleAgentDistributedState)
+	plannerState.OTelEndpointConfig = &distributedpb.OTelEndpointConfig{URL: endpointURL}
+	plannerState.DryRunExports = true
+
+	return queryExecTestCase{
+		Name: "dry run of exports",
+		Req: &vizierpb.ExecuteScriptRequest{
+			QueryStr: testQuery,
+			Configs: &vizierpb.Configs{
+				OTelEndpointConfig: &vizierpb.Configs_OTelEndpointConfig{URL: endpointURL},
+				DryRunExports:      true,
+			},
+		},
+		TableNames:                []string{"agent1_table", "agent2_table"},
+		PlannerState:              plannerState,
+		ExpectedPlannerResult:     buildPlannerResult(t, expectedPlannerResult),
+		StreamResultsCallExpected: true,
+		ExpectedEndpointStatus: &vizierpb.ExportEndpointStatus{
+			URL:       endpointURL,
+			Reachable: true,
+		},
+	}
+}
+
 func buildPlannerErrorTestCase(t *testing.T) queryExecTestCase {
 	errResp := &vizierpb.ExecuteScriptResponse{
 		Status: &vizierpb.Status{
@@ -66,6 +66,11 @@ type QueryPlanOpts struct {
 	PlanMap map[uuid.UUID]*planpb.Plan
 }
 
+// AgentFailureReporter reports the agents which failed to take part in a query.
+type AgentFailureReporter interface {
+	ReportQueryFailures(queryID uuid.UUID, agentIDs []uuid.UUID)
+}
+
 // The deadline for all sinks in a given query to initialize.
 const defaultResultSinkInitializationTimeout = 30 * time.Second
 
@@ -165,11 +170,17 @@ type activeQuery struct {
 
 	// Name used for labeling metrics recorded for this query.
 	queryName string
+
+	// The agents which the query was launched on. Those which are missing from the final exec stats
+	// are reported to agentFailureReporter, if it is set.
+	agentIDs             []uuid.UUID
+	agentFailureReporter AgentFailureReporter
 }
 
 func newActiveQuery(producerCtx context.Context, tableIDMap map[string]string,
 	compilationTimeNs int64,
-	queryPlanOpts *QueryPlanOpts, watchdogCancel context.CancelFunc, queryName string) *activeQuery {
+	queryPlanOpts *QueryPlanOpts, watchdogCancel context.CancelFunc, queryName string,
+	agentIDs []uuid.UUID, agentFailureReporter AgentFailureReporter) *activeQuery {
 	aq := &activeQuery{
 		queryResultCh: make(chan *carnotpb.TransferResultChunkRequest, activeQueryBufferSize),
 		tableIDMap:    tableIDMap,
@@ -192,6 +203,9 @@ func newActiveQuery(producerCtx context.Context, tableIDMap map[string]string,
 		producerCtx:     producerCtx,
 
 		queryName: queryName,
+
+		agentIDs:             agentIDs,
+		agentFailureReporter: agentFailureReporter,
 	}
 
 	for tableName := range tableIDMap {
@@ -322,6 +336,7 @@ func (a *activeQuery) handleRequest(ctx context.Context, queryID uuid.UUID, msg
 	// Optionally send the query plan (which requires the exec stats).
 	if execStats := msg.GetExecutionAndTimingInfo(); execStats != nil {
 		a.agentExecStats = &(execStats.AgentExecutionStats)
+		a.reportMissingAgents(queryID, execStats.AgentExecutionStats)
 	}
 
 	// If the query is complete and we need to send the query plan, send it before the final
@@ -366,6 +381,29 @@ func (a *activeQuery) handleRequest(ctx context.Context, queryID uuid.UUID, msg
 	return nil
 }
 
+// reportMissingAgents reports the agents which the query was launched on, but which are missing from
+// the final exec stats. These agents did not finish their part of the query.
+func (a *activeQuery) reportMissingAgents(queryID uuid.UUID, agentStats []*queryresultspb.AgentExecutionStats) {
+	if a.agentFailureReporter == nil || len(a.agentIDs) == 0 {
+		return
+	}
+	reported := make(map[uuid.UUID]bool, len(agentStats))
+	for _, stats := range agentStats {
+		reported[utils.UUIDFromProtoOrNil(stats.AgentID)] = true
+	}
+	var missing []uuid.UUID
+	for _, agentID := range a.agentIDs {
+		if !reported[agentID] {
+			missing = append(missing, agentID)
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+	log.WithField("query_id", queryID.String()).Infof("%d agents did not report exec stats", len(missing))
+	go a.agentFailureReporter.ReportQueryFailures(queryID, missing)
+}
+
 func (a *activeQuery) consumerHealthcheck(ctx context.Context) {
 	select {
 	case <-ctx.Done():
@@ -395,7 +433,7 @@ func (a *activeQuery) cancelQuery(err error) {
 type QueryResultForwarder interface {
 	RegisterQuery(queryID uuid.UUID, tableIDMap map[string]string,
 		compilationTimeNs int64,
-		queryPlanOpts *QueryPlanOpts, queryName string) error
+		queryPlanOpts *QueryPlanOpts, queryName string, agentIDs []uuid.UUID) error
 
 	// Streams results from the agent stream to the client stream.
 	// Blocks until the stream (& the agent stream) has completed, been cancelled, or experienced an error.
@@ -421,6 +459,8 @@ type QueryResultForwarderImpl struct {
 
 	consumerTimeout time.Duration
 	producerTimeout time.Duration
+
+	agentFailureReporter AgentFailureReporter
 }
 
 // QueryResultForwarderOption allows specifying options for new QueryResultForwarders.
@@ -447,6 +487,13 @@ func WithConsumerTimeout(timeout time.Duration) QueryResultForwarderOption {
 	}
 }
 
+// WithAgentFailureReporter sets the reporter for the agents which fail to take part in queries.
+func WithAgentFailureReporter(reporter AgentFailureReporter) QueryResultForwarderOption {
+	return func(rf *QueryResultForwarderImpl) {
+		rf.agentFailureReporter = reporter
+	}
+}
+
 // NewQueryResultForwarder creates a new QueryResultForwarder.
 func NewQueryResultForwarder() QueryResultForwarder {
 	return NewQueryResultForwarderWithOptions()
@@ -472,7 +519,8 @@ func NewQueryResultForwarderWithOptions(opts ...QueryResultForwarderOption) Quer
 func (f *QueryResultForwarderImpl) RegisterQuery(queryID uuid.UUID, tableIDMap map[string]string,
 	compilationTimeNs int64,
 	queryPlanOpts *QueryPlanOpts,
-	queryName string) error {
+	queryName string,
+	agentIDs []uuid.UUID) error {
 	f.activeQueriesMutex.Lock()
 	defer f.activeQueriesMutex.Unlock()
 
@@ -481,7 +529,8 @@ func (f *QueryResultForwarderImpl) RegisterQuery(queryID uuid.UUID, tableIDMap m
 	}
 	watchdogCtx, watchdogCancel := context.WithCancel(context.Background())
 	producerCtx, producerCancel := context.WithCancel(context.Background())
-	aq := newActiveQuery(producerCtx, tableIDMap, compilationTimeNs, queryPlanOpts, watchdogCancel, queryName)
+	aq := newActiveQuery(producerCtx, tableIDMap, compilationTimeNs, queryPlanOpts, watchdogCancel, queryName,
+		agentIDs, f.agentFailureReporter)
 	f.activeQueries[queryID] = aq
 
 	deleteQuery := func() {
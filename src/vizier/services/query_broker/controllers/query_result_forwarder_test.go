@@ -162,7 +162,7 @@ func TestStreamResultsSimple(t *testing.T) {
 	}()
 	var err error
 
-	assert.Nil(t, f.RegisterQuery(queryID, expectedTables, 350, nil, ""))
+	assert.Nil(t, f.RegisterQuery(queryID, expectedTables, 350, nil, "", nil))
 
 	go func() {
 		err = f.StreamResults(consumerCtx, queryID, resultCh)
@@ -229,7 +229,7 @@ func TestStreamResultsAgentCancel(t *testing.T) {
 	}()
 	var err error
 
-	assert.Nil(t, f.RegisterQuery(queryID, expectedTables, 350, nil, ""))
+	assert.Nil(t, f.RegisterQuery(queryID, expectedTables, 350, nil, "", nil))
 
 	go func() {
 		err = f.StreamResults(consumerCtx, queryID, resultCh)
@@ -296,7 +296,7 @@ func TestStreamResultsClientContextCancel(t *testing.T) {
 	}()
 	errCh := make(chan error)
 
-	assert.Nil(t, f.RegisterQuery(queryID, expectedTables, 350, nil, ""))
+	assert.Nil(t, f.RegisterQuery(queryID, expectedTables, 350, nil, "", nil))
 
 	go func() {
 		err := f.StreamResults(consumerCtx, queryID, resultCh)
@@ -364,7 +364,7 @@ func TestStreamResultsQueryPlan(t *testing.T) {
 		Plan:    plan,
 		PlanMap: planMap,
 	}
-	assert.Nil(t, f.RegisterQuery(queryID, expectedTables, 350, queryPlanOpts, ""))
+	assert.Nil(t, f.RegisterQuery(queryID, expectedTables, 350, queryPlanOpts, "", nil))
 
 	go func() {
 		err = f.StreamResults(consumerCtx, queryID, resultCh)
@@ -446,7 +446,7 @@ func TestStreamResultsWrongQueryID(t *testing.T) {
 	}()
 	var err error
 
-	assert.Nil(t, f.RegisterQuery(queryID, expectedTables, 350, nil, ""))
+	assert.Nil(t, f.RegisterQuery(queryID, expectedTables, 350, nil, "", nil))
 
 	go func() {
 		err = f.StreamResults(consumerCtx, queryID, resultCh)
@@ -509,7 +509,7 @@ func TestStreamResultsResultsBeforeInitialization(t *testing.T) {
 	}()
 	var err error
 
-	assert.Nil(t, f.RegisterQuery(queryID, expectedTables, 350, nil, ""))
+	assert.Nil(t, f.RegisterQuery(queryID, expectedTables, 350, nil, "", nil))
 
 	go func() {
 		err = f.StreamResults(consumerCtx, queryID, resultCh)
@@ -558,7 +558,7 @@ func TestStreamResultsNeverInitializedTable(t *testing.T) {
 	}()
 	var err error
 
-	assert.Nil(t, f.RegisterQuery(queryID, expectedTables, 350, nil, ""))
+	assert.Nil(t, f.RegisterQuery(queryID, expectedTables, 350, nil, "", nil))
 
 	go func() {
 		err = f.StreamResults(consumerCtx, queryID, resultCh)
@@ -622,7 +622,7 @@ func TestStreamResultsProducerTimeout(t *testing.T) {
 	}()
 	var err error
 
-	assert.Nil(t, f.RegisterQuery(queryID, expectedTables, 350, nil, ""))
+	assert.Nil(t, f.RegisterQuery(queryID, expectedTables, 350, nil, "", nil))
 
 	go func() {
 		err = f.StreamResults(consumerCtx, queryID, resultCh)
@@ -681,7 +681,7 @@ func TestStreamResultsNewConsumer(t *testing.T) {
 	}()
 	var consumer1Err error
 
-	assert.Nil(t, f.RegisterQuery(queryID, expectedTables, 350, nil, ""))
+	assert.Nil(t, f.RegisterQuery(queryID, expectedTables, 350, nil, "", nil))
 
 	go func() {
 		consumer1Err = f.StreamResults(consumer1Ctx, queryID, resultCh1)
@@ -785,7 +785,7 @@ func TestStreamResultsMultipleProducers(t *testing.T) {
 	}()
 	var err error
 
-	assert.Nil(t, f.RegisterQuery(queryID, expectedTables, 350, nil, ""))
+	assert.Nil(t, f.RegisterQuery(queryID, expectedTables, 350, nil, "", nil))
 
 	go func() {
 		err = f.StreamResults(consumerCtx, queryID, resultCh)
@@ -971,7 +971,7 @@ func TestStreamResultsReceiveExecutionError(t *testing.T) {
 			}()
 			var err error
 
-			assert.Nil(t, f.RegisterQuery(queryID, expectedTables, 350, nil, ""))
+			assert.Nil(t, f.RegisterQuery(queryID, expectedTables, 350, nil, "", nil))
 
 			go func() {
 				err = f.StreamResults(consumerCtx, queryID, resultCh)
@@ -1022,7 +1022,7 @@ func TestStreamErrorWithMultipleProducers(t *testing.T) {
 	}()
 	var err error
 
-	assert.Nil(t, f.RegisterQuery(queryID, expectedTables, 350, nil, ""))
+	assert.Nil(t, f.RegisterQuery(queryID, expectedTables, 350, nil, "", nil))
 
 	go func() {
 		err = f.StreamResults(consumerCtx, queryID, resultCh)
@@ -1069,3 +1069,58 @@ func TestStreamErrorWithMultipleProducers(t *testing.T) {
 	assert.Equal(t, expected0, results[0].GetData().Batch)
 	assert.Equal(t, controllers.StatusToVizierStatus(errorStatus), results[1].GetStatus())
 }
+
+type fakeAgentFailureReporter struct {
+	queryID  uuid.UUID
+	agentIDs []uuid.UUID
+	reported chan struct{}
+}
+
+func (r *fakeAgentFailureReporter) ReportQueryFailures(queryID uuid.UUID, agentIDs []uuid.UUID) {
+	r.queryID = queryID
+	r.agentIDs = agentIDs
+	close(r.reported)
+}
+
+func TestStreamResultsReportsMissingAgents(t *testing.T) {
+	queryID := uuid.Must(uuid.NewV4())
+	agentID1 := uuid.Must(uuid.NewV4())
+	agentID2 := uuid.Must(uuid.NewV4())
+
+	reporter := &fakeAgentFailureReporter{reported: make(chan struct{})}
+	f := controllers.NewQueryResultForwarderWithOptions(
+		controllers.WithResultSinkTimeout(1*time.Second),
+		controllers.WithAgentFailureReporter(reporter))
+
+	resultCh := make(chan *vizierpb.ExecuteScriptResponse, 10)
+	producerCtx, cancelProducer := context.WithCancel(context.Background())
+	defer cancelProducer()
+
+	tables := map[string]string{"foo": "123"}
+	assert.Nil(t, f.RegisterQuery(queryID, tables, 350, nil, "", []uuid.UUID{agentID1, agentID2}))
+
+	errCh := make(chan error)
+	go func() {
+		errCh <- f.StreamResults(context.Background(), queryID, resultCh)
+	}()
+
+	_, in0 := makeRowBatchResult(t, queryID, "foo", "123" /*eos*/, true)
+	_, in1 := makeExecStatsResult(t, queryID)
+	// Only the first agent finished its part of the query.
+	in1.GetExecutionAndTimingInfo().AgentExecutionStats = []*queryresultspb.AgentExecutionStats{
+		{AgentID: utils.ProtoFromUUID(agentID1)},
+	}
+
+	assert.Nil(t, f.ForwardQueryResult(producerCtx, makeInitiateConnectionRequest(queryID)))
+	assert.Nil(t, f.ForwardQueryResult(producerCtx, in0))
+	assert.Nil(t, f.ForwardQueryResult(producerCtx, in1))
+	require.NoError(t, <-errCh)
+
+	select {
+	case <-reporter.reported:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the missing agents to be reported")
+	}
+	assert.Equal(t, queryID, reporter.queryID)
+	assert.Equal(t, []uuid.UUID{agentID2}, reporter.agentIDs)
+}
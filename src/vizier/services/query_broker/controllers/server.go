@@ -41,6 +41,7 @@ import (
 	"px.dev/pixie/src/carnot/planner/distributedpb"
 	"px.dev/pixie/src/carnot/planner/plannerpb"
 	"px.dev/pixie/src/carnot/udfspb"
+	"px.dev/pixie/src/shared/services/authcontext"
 	serviceUtils "px.dev/pixie/src/shared/services/utils"
 	"px.dev/pixie/src/utils"
 	funcs "px.dev/pixie/src/vizier/funcs/go"
@@ -68,6 +69,8 @@ type Planner interface {
 // AgentsTracker is the interface for the background agent information tracker.
 type AgentsTracker interface {
 	GetAgentInfo() tracker.AgentsInfo
+	// ReportQueryFailures reports the agents which failed to take part in a query.
+	ReportQueryFailures(queryID uuid.UUID, agentIDs []uuid.UUID)
 }
 
 // Server defines an gRPC server type.
@@ -83,6 +86,7 @@ type Server struct {
 
 	mdtp            metadatapb.MetadataTracepointServiceClient
 	mdconf          metadatapb.MetadataConfigServiceClient
+	mdk8s           metadatapb.MetadataServiceClient
 	resultForwarder QueryResultForwarder
 
 	planner Planner
@@ -96,7 +100,7 @@ type QueryExecutorFactory func(*Server, MutationExecFactory) QueryExecutor
 // NewServer creates GRPC handlers.
 func NewServer(env querybrokerenv.QueryBrokerEnv, agentsTracker AgentsTracker, dataPrivacy DataPrivacy,
 	mds metadatapb.MetadataTracepointServiceClient, mdconf metadatapb.MetadataConfigServiceClient,
-	natsConn *nats.Conn, queryExecFactory QueryExecutorFactory) (*Server, error) {
+	mdk8s metadatapb.MetadataServiceClient, natsConn *nats.Conn, queryExecFactory QueryExecutorFactory) (*Server, error) {
 	var udfInfo udfspb.UDFInfo
 	if err := loadUDFInfo(&udfInfo); err != nil {
 		return nil, err
@@ -106,8 +110,9 @@ func NewServer(env querybrokerenv.QueryBrokerEnv, agentsTracker AgentsTracker, d
 		return nil, err
 	}
 
-	return NewServerWithForwarderAndPlanner(env, agentsTracker, dataPrivacy, NewQueryResultForwarder(), mds, mdconf,
-		natsConn, c, queryExecFactory)
+	rf := NewQueryResultForwarderWithOptions(WithAgentFailureReporter(agentsTracker))
+	return NewServerWithForwarderAndPlanner(env, agentsTracker, dataPrivacy, rf, mds, mdconf,
+		mdk8s, natsConn, c, queryExecFactory)
 }
 
 // NewServerWithForwarderAndPlanner is NewServer with a QueryResultForwarder and a planner generating func.
@@ -117,6 +122,7 @@ func NewServerWithForwarderAndPlanner(env querybrokerenv.QueryBrokerEnv,
 	resultForwarder QueryResultForwarder,
 	mds metadatapb.MetadataTracepointServiceClient,
 	mdconf metadatapb.MetadataConfigServiceClient,
+	mdk8s metadatapb.MetadataServiceClient,
 	natsConn *nats.Conn,
 	planner Planner,
 	queryExecFactory QueryExecutorFactory) (*Server, error) {
@@ -128,6 +134,7 @@ func NewServerWithForwarderAndPlanner(env querybrokerenv.QueryBrokerEnv,
 		natsConn:          natsConn,
 		mdtp:              mds,
 		mdconf:            mdconf,
+		mdk8s:             mdk8s,
 		planner:           planner,
 		queryExecFactory:  queryExecFactory,
 		healthcheckQuitCh: make(chan struct{}),
@@ -307,6 +314,9 @@ func (e *encryptConsumer) Consume(result *vizierpb.ExecuteScriptResponse) error
 
 // ExecuteScript executes the script and sends results through the gRPC stream.
 func (s *Server) ExecuteScript(req *vizierpb.ExecuteScriptRequest, srv vizierpb.VizierService_ExecuteScriptServer) error {
+	if err := checkAPIKeyScopes(srv.Context()); err != nil {
+		return err
+	}
 	ctx := context.WithValue(srv.Context(), execStartKey, time.Now())
 
 	var consumer QueryResultConsumer
@@ -329,6 +339,39 @@ func (s *Server) ExecuteScript(req *vizierpb.ExecuteScriptRequest, srv vizierpb.
 	return queryExec.Wait()
 }
 
+// checkAPIKeyScopes rejects scripts which are outside of the scopes of the API key that the request
+// was made with. Running a script requires the script:execute or cluster:admin scope.
+func checkAPIKeyScopes(ctx context.Context) error {
+	aCtx, err := authcontext.FromContext(ctx)
+	if err != nil || aCtx.Claims == nil {
+		return nil
+	}
+	if serviceUtils.HasAPIKeyScope(aCtx.Claims, serviceUtils.APIKeyScopeClusterAdmin) {
+		return nil
+	}
+	if !serviceUtils.HasAPIKeyScope(aCtx.Claims, serviceUtils.APIKeyScopeScriptExecute) {
+		return status.Error(codes.PermissionDenied, "API key is not scoped to run scripts")
+	}
+	return nil
+}
+
+// checkMutationPermissions rejects scripts which compile to mutations, unless the request was made by
+// a user with at least the member org role. API keys also need the cluster:admin scope. This is checked
+// against the compiled mutations rather than the mutation flag of the request, which the client sets.
+func checkMutationPermissions(ctx context.Context) error {
+	aCtx, err := authcontext.FromContext(ctx)
+	if err != nil || aCtx.Claims == nil {
+		return nil
+	}
+	if !serviceUtils.HasAPIKeyScope(aCtx.Claims, serviceUtils.APIKeyScopeClusterAdmin) {
+		return status.Error(codes.PermissionDenied, "API key is not scoped to run scripts with mutations")
+	}
+	if !serviceUtils.HasOrgRole(aCtx.Claims, serviceUtils.OrgRoleMember) {
+		return status.Error(codes.PermissionDenied, "the member role is required to run scripts with mutations")
+	}
+	return nil
+}
+
 // GenerateOTelScript generates an OTel script for the given DataFrame script.
 func (s *Server) GenerateOTelScript(ctx context.Context, req *vizierpb.GenerateOTelScriptRequest) (*vizierpb.GenerateOTelScriptResponse, error) {
 	info := s.agentsTracker.GetAgentInfo()
@@ -29,6 +29,8 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"px.dev/pixie/src/api/proto/vizierpb"
 	mock_vizierpb "px.dev/pixie/src/api/proto/vizierpb/mock"
@@ -36,10 +38,15 @@ import (
 	mock_carnotpb "px.dev/pixie/src/carnot/carnotpb/mock"
 	"px.dev/pixie/src/carnot/planner/distributedpb"
 	"px.dev/pixie/src/carnot/queryresultspb"
+	k8smetadatapb "px.dev/pixie/src/shared/k8s/metadatapb"
 	"px.dev/pixie/src/shared/services/authcontext"
+	serviceUtils "px.dev/pixie/src/shared/services/utils"
 	"px.dev/pixie/src/table_store/schemapb"
 	"px.dev/pixie/src/utils"
 	"px.dev/pixie/src/utils/testingutils"
+	"px.dev/pixie/src/vizier/services/metadata/metadatapb"
+	mock_metadatapb "px.dev/pixie/src/vizier/services/metadata/metadatapb/mock"
+	"px.dev/pixie/src/vizier/services/metadata/storepb"
 	"px.dev/pixie/src/vizier/services/query_broker/controllers"
 	"px.dev/pixie/src/vizier/services/query_broker/querybrokerenv"
 	"px.dev/pixie/src/vizier/services/query_broker/tracker"
@@ -267,7 +274,7 @@ func TestCheckHealth(t *testing.T) {
 			}
 
 			dp := &fakeDataPrivacy{}
-			s, err := controllers.NewServerWithForwarderAndPlanner(nil, nil, dp, nil, nil, nil, nil, nil, queryExecFactory)
+			s, err := controllers.NewServerWithForwarderAndPlanner(nil, nil, dp, nil, nil, nil, nil, nil, nil, queryExecFactory)
 			require.NoError(t, err)
 
 			err = s.CheckHealth(context.Background())
@@ -392,7 +399,7 @@ func TestExecuteScript(t *testing.T) {
 			}
 
 			dp := &fakeDataPrivacy{}
-			s, err := controllers.NewServerWithForwarderAndPlanner(nil, nil, dp, nil, nil, nil, nil, nil, queryExecFactory)
+			s, err := controllers.NewServerWithForwarderAndPlanner(nil, nil, dp, nil, nil, nil, nil, nil, nil, queryExecFactory)
 			require.NoError(t, err)
 
 			// Set up mocks.
@@ -431,6 +438,48 @@ func TestExecuteScript(t *testing.T) {
 	}
 }
 
+func TestExecuteScript_APIKeyScopes(t *testing.T) {
+	tests := []struct {
+		name    string
+		scopes  []string
+		allowed bool
+	}{
+		{name: "unscoped", allowed: true},
+		{name: "script execute", scopes: []string{serviceUtils.APIKeyScopeScriptExecute}, allowed: true},
+		{name: "cluster admin", scopes: []string{serviceUtils.APIKeyScopeClusterAdmin}, allowed: true},
+		{name: "plugin config", scopes: []string{serviceUtils.APIKeyScopePluginConfig}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			qe := &fakeQueryExecutor{}
+			queryExecFactory := func(*controllers.Server, controllers.MutationExecFactory) controllers.QueryExecutor {
+				return qe
+			}
+			s, err := controllers.NewServerWithForwarderAndPlanner(nil, nil, &fakeDataPrivacy{}, nil, nil, nil, nil, nil, nil, queryExecFactory)
+			require.NoError(t, err)
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			srv := mock_vizierpb.NewMockVizierService_ExecuteScriptServer(ctrl)
+			auth := authcontext.New()
+			auth.Claims = serviceUtils.GenerateJWTForCluster("vizier_cluster", "vizier")
+			auth.Claims.GetClusterClaims().APIKeyScopes = test.scopes
+			srv.EXPECT().Context().Return(authcontext.NewContext(context.Background(), auth)).AnyTimes()
+
+			req := &vizierpb.ExecuteScriptRequest{QueryStr: "px.display(1)"}
+			err = s.ExecuteScript(req, srv)
+			if test.allowed {
+				require.NoError(t, err)
+				assert.Equal(t, req, qe.ReqReceived)
+			} else {
+				assert.Equal(t, codes.PermissionDenied, status.Code(err))
+				assert.Nil(t, qe.ReqReceived)
+			}
+		})
+	}
+}
+
 func TestTransferResultChunk_AgentStreamComplete(t *testing.T) {
 	nc, cleanup := testingutils.MustStartTestNATS(t)
 	defer cleanup()
@@ -456,7 +505,7 @@ func TestTransferResultChunk_AgentStreamComplete(t *testing.T) {
 	}
 
 	dp := &fakeDataPrivacy{}
-	s, err := controllers.NewServerWithForwarderAndPlanner(env, &at, dp, &rf, nil, nil, nc, nil, nil)
+	s, err := controllers.NewServerWithForwarderAndPlanner(env, &at, dp, &rf, nil, nil, nil, nc, nil, nil)
 	require.NoError(t, err)
 	defer s.Close()
 
@@ -547,7 +596,7 @@ func TestTransferResultChunk_AgentClosedPrematurely(t *testing.T) {
 	}
 
 	dp := &fakeDataPrivacy{}
-	s, err := controllers.NewServerWithForwarderAndPlanner(env, &at, dp, &rf, nil, nil, nc, nil, nil)
+	s, err := controllers.NewServerWithForwarderAndPlanner(env, &at, dp, &rf, nil, nil, nil, nc, nil, nil)
 	require.NoError(t, err)
 	defer s.Close()
 
@@ -631,7 +680,7 @@ func TestTransferResultChunk_AgentStreamFailed(t *testing.T) {
 	}
 
 	dp := &fakeDataPrivacy{}
-	s, err := controllers.NewServerWithForwarderAndPlanner(env, &at, dp, &rf, nil, nil, nc, nil, nil)
+	s, err := controllers.NewServerWithForwarderAndPlanner(env, &at, dp, &rf, nil, nil, nil, nc, nil, nil)
 	require.NoError(t, err)
 	defer s.Close()
 
@@ -709,7 +758,7 @@ func TestTransferResultChunk_ClientStreamCancelled(t *testing.T) {
 	}
 
 	dp := &fakeDataPrivacy{}
-	s, err := controllers.NewServerWithForwarderAndPlanner(env, &at, dp, &rf, nil, nil, nc, nil, nil)
+	s, err := controllers.NewServerWithForwarderAndPlanner(env, &at, dp, &rf, nil, nil, nil, nc, nil, nil)
 	require.NoError(t, err)
 	defer s.Close()
 
@@ -754,3 +803,72 @@ func TestTransferResultChunk_ClientStreamCancelled(t *testing.T) {
 	assert.NotNil(t, rf.ClientStreamError)
 	assert.Equal(t, 0, len(rf.ReceivedAgentResults))
 }
+
+func TestGetK8SHistory(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mds := mock_metadatapb.NewMockMetadataServiceClient(ctrl)
+	s, err := controllers.NewServerWithForwarderAndPlanner(nil, nil, &fakeDataPrivacy{}, nil, nil, nil, mds, nil, nil, nil)
+	require.NoError(t, err)
+	defer s.Close()
+
+	ctx := authcontext.NewContext(context.Background(), authcontext.New())
+
+	pod := func(version int64) *storepb.K8SResourceHistoryEntry {
+		return &storepb.K8SResourceHistoryEntry{
+			UpdateVersion: version,
+			TimestampNS:   version * 10,
+			Resource: &storepb.K8SResource{
+				Resource: &storepb.K8SResource_Pod{
+					Pod: &k8smetadatapb.Pod{
+						Metadata: &k8smetadatapb.ObjectMetadata{Name: "pod-1", Namespace: "pl"},
+					},
+				},
+			},
+		}
+	}
+
+	mds.EXPECT().
+		GetPodByIP(gomock.Any(), &metadatapb.PodByIPRequest{IP: "10.0.0.1", TimeNS: 5}).
+		Return(&metadatapb.PodByIPResponse{Pod: pod(1)}, nil)
+	resp, err := s.GetK8SHistory(ctx, &vizierpb.GetK8SHistoryRequest{
+		Query: &vizierpb.GetK8SHistoryRequest_PodByIP{
+			PodByIP: &vizierpb.GetK8SHistoryRequest_PodByIPQuery{IP: "10.0.0.1", TimeNS: 5},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Versions, 1)
+	assert.Equal(t, "pods", resp.Versions[0].ObjectType)
+	assert.Equal(t, "pl", resp.Versions[0].Namespace)
+	assert.Equal(t, "pod-1", resp.Versions[0].Name)
+	assert.Equal(t, int64(10), resp.Versions[0].TimestampNS)
+	assert.Contains(t, resp.Versions[0].ObjectJSON, `"name":"pod-1"`)
+
+	mds.EXPECT().
+		GetK8SObjectDiff(gomock.Any(), &metadatapb.K8SObjectDiffRequest{
+			ObjectType:  "pods",
+			Namespace:   "pl",
+			Name:        "pod-1",
+			FromVersion: 1,
+		}).
+		Return(&metadatapb.K8SObjectDiffResponse{From: pod(1), To: pod(2), Diff: "a diff"}, nil)
+	resp, err = s.GetK8SHistory(ctx, &vizierpb.GetK8SHistoryRequest{
+		Query: &vizierpb.GetK8SHistoryRequest_ObjectDiff{
+			ObjectDiff: &vizierpb.GetK8SHistoryRequest_ObjectDiffQuery{
+				ObjectType:  "pods",
+				Namespace:   "pl",
+				Name:        "pod-1",
+				FromVersion: 1,
+			},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Versions, 2)
+	assert.Equal(t, int64(1), resp.Versions[0].UpdateVersion)
+	assert.Equal(t, int64(2), resp.Versions[1].UpdateVersion)
+	assert.Equal(t, "a diff", resp.Diff)
+
+	_, err = s.GetK8SHistory(ctx, &vizierpb.GetK8SHistoryRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
@@ -197,6 +197,21 @@ func (s *PassThroughProxy) runRequest(reqState *RequestState, msg *cvmsgspb.C2VA
 		})
 		s.sendMessage(reqState.requestID, formatStatusMessage(reqState.requestID, codes.OK, ""))
 		return
+	case *cvmsgspb.C2VAPIStreamRequest_K8sHistoryReq:
+		resp, err := s.vzClient.GetK8SHistory(reqState.ctx, msg.GetK8sHistoryReq())
+		if err != nil {
+			v2cResp := formatStatusMessage(reqState.requestID, status.Code(err), err.Error())
+			s.sendMessage(reqState.requestID, v2cResp)
+			return
+		}
+		s.sendMessage(reqState.requestID, &cvmsgspb.V2CAPIStreamResponse{
+			RequestID: reqState.requestID,
+			Msg: &cvmsgspb.V2CAPIStreamResponse_K8sHistoryResp{
+				K8sHistoryResp: resp,
+			},
+		})
+		s.sendMessage(reqState.requestID, formatStatusMessage(reqState.requestID, codes.OK, ""))
+		return
 	default:
 		s.sendMessage(reqState.requestID, formatStatusMessage(reqState.requestID, codes.InvalidArgument, fmt.Sprintf("Unknown request type %s", reflect.TypeOf(msg.Msg))))
 		log.Error("Unhandled message type")
@@ -109,6 +109,22 @@ func (m *MockVzServer) GenerateOTelScript(ctx context.Context, req *vizierpb.Gen
 	}, nil
 }
 
+func (m *MockVzServer) GetK8SHistory(ctx context.Context, req *vizierpb.GetK8SHistoryRequest) (*vizierpb.GetK8SHistoryResponse, error) {
+	if req.GetPodByIP().GetIP() == "error" {
+		return nil, errors.New("Failed")
+	}
+	return &vizierpb.GetK8SHistoryResponse{
+		Versions: []*vizierpb.K8SObjectVersion{
+			{
+				UpdateVersion: 1,
+				ObjectType:    "pods",
+				Namespace:     "pl",
+				Name:          "pod-1",
+			},
+		},
+	}, nil
+}
+
 type testState struct {
 	t        *testing.T
 	lis      *bufconn.Listener
@@ -338,6 +354,68 @@ func TestPassThroughProxy(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:      "k8s history: success",
+			requestID: "1",
+			request: &cvmsgspb.C2VAPIStreamRequest{
+				Msg: &cvmsgspb.C2VAPIStreamRequest_K8sHistoryReq{
+					K8sHistoryReq: &vizierpb.GetK8SHistoryRequest{
+						Query: &vizierpb.GetK8SHistoryRequest_PodByIP{
+							PodByIP: &vizierpb.GetK8SHistoryRequest_PodByIPQuery{IP: "10.0.0.1"},
+						},
+					},
+				},
+			},
+			expectedResps: []*cvmsgspb.V2CAPIStreamResponse{
+				{
+					RequestID: "1",
+					Msg: &cvmsgspb.V2CAPIStreamResponse_K8sHistoryResp{
+						K8sHistoryResp: &vizierpb.GetK8SHistoryResponse{
+							Versions: []*vizierpb.K8SObjectVersion{
+								{
+									UpdateVersion: 1,
+									ObjectType:    "pods",
+									Namespace:     "pl",
+									Name:          "pod-1",
+								},
+							},
+						},
+					},
+				},
+				{
+					RequestID: "1",
+					Msg: &cvmsgspb.V2CAPIStreamResponse_Status{
+						Status: &vizierpb.Status{
+							Code: int32(codes.OK),
+						},
+					},
+				},
+			},
+		},
+		{
+			name:      "k8s history: grpc error",
+			requestID: "1",
+			request: &cvmsgspb.C2VAPIStreamRequest{
+				Msg: &cvmsgspb.C2VAPIStreamRequest_K8sHistoryReq{
+					K8sHistoryReq: &vizierpb.GetK8SHistoryRequest{
+						Query: &vizierpb.GetK8SHistoryRequest_PodByIP{
+							PodByIP: &vizierpb.GetK8SHistoryRequest_PodByIPQuery{IP: "error"},
+						},
+					},
+				},
+			},
+			expectedResps: []*cvmsgspb.V2CAPIStreamResponse{
+				{
+					RequestID: "1",
+					Msg: &cvmsgspb.V2CAPIStreamResponse_Status{
+						Status: &vizierpb.Status{
+							Code:    int32(codes.Unknown),
+							Message: "rpc error: code = Unknown desc = Failed",
+						},
+					},
+				},
+			},
+		},
 		{
 			name:      "unknown message type",
 			requestID: "1",
@@ -0,0 +1,472 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package scriptrunner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/segmentio/kafka-go"
+	log "github.com/sirupsen/logrus"
+
+	"px.dev/pixie/src/api/go/pxapi/utils"
+	"px.dev/pixie/src/api/proto/vizierpb"
+	"px.dev/pixie/src/shared/scripts"
+)
+
+const (
+	defaultWebhookBatchSize   = 1000
+	defaultWebhookMaxRetries  = 3
+	defaultWebhookTimeout     = 10 * time.Second
+	defaultWebhookRetryPeriod = 500 * time.Millisecond
+	defaultKafkaMaxAttempts   = 10
+	// exportQueueSize is the number of row batches which may be waiting to be exported. Once the
+	// queue is full, reading the script's results waits for the sinks to catch up.
+	exportQueueSize = 64
+)
+
+// exportRow is a single row of an output table, keyed by column name.
+type exportRow map[string]interface{}
+
+// exportSink is a destination for the output tables of a cron script.
+type exportSink interface {
+	// Export sends the given rows, which all belong to the named table.
+	Export(ctx context.Context, table string, rows []exportRow) error
+	Close() error
+}
+
+// tableFilteredSink only forwards the tables it was configured with.
+type tableFilteredSink struct {
+	exportSink
+	tables map[string]bool
+}
+
+func (s *tableFilteredSink) accepts(table string) bool {
+	return len(s.tables) == 0 || s.tables[table]
+}
+
+// newExportSink creates the sink described by the given config.
+func newExportSink(c *scripts.ExportConfig) (*tableFilteredSink, error) {
+	var sink exportSink
+	var err error
+	switch {
+	case c.Webhook != nil:
+		sink, err = newWebhookSink(c.Webhook)
+	case c.Kafka != nil:
+		sink, err = newKafkaSink(c.Kafka)
+	case c.File != nil:
+		sink, err = newFileSink(c.File)
+	default:
+		return nil, errors.New("export config must specify a webhook, kafka or file sink")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tables := make(map[string]bool)
+	for _, t := range c.Tables {
+		tables[t] = true
+	}
+	return &tableFilteredSink{exportSink: sink, tables: tables}, nil
+}
+
+// newExportSinks creates the sinks described by the given configs. Configs which are invalid are
+// logged and skipped.
+func newExportSinks(configs []*scripts.ExportConfig) []*tableFilteredSink {
+	var sinks []*tableFilteredSink
+	for _, c := range configs {
+		sink, err := newExportSink(c)
+		if err != nil {
+			log.WithError(err).Error("Failed to create export sink, skipping...")
+			continue
+		}
+		sinks = append(sinks, sink)
+	}
+	return sinks
+}
+
+// closeExportSinks closes all of the given sinks.
+func closeExportSinks(sinks []*tableFilteredSink) {
+	for _, sink := range sinks {
+		if err := sink.Close(); err != nil {
+			log.WithError(err).Error("Failed to close export sink")
+		}
+	}
+}
+
+type pendingBatch struct {
+	table *vizierpb.QueryMetadata
+	batch *vizierpb.RowBatchData
+}
+
+// batchExporter exports the row batches of a single script execution in the background, so that
+// slow sinks don't hold up reading the results until the queue fills up. Batches are exported in
+// the order they are added, and are given up on once the exporter's context is done.
+type batchExporter struct {
+	ctx     context.Context
+	sinks   []*tableFilteredSink
+	batches chan *pendingBatch
+	done    chan struct{}
+	once    sync.Once
+
+	mu         sync.Mutex
+	totalRows  int64
+	failedRows int64
+	firstErr   error
+}
+
+func newBatchExporter(ctx context.Context, sinks []*tableFilteredSink) *batchExporter {
+	e := &batchExporter{
+		ctx:     ctx,
+		sinks:   sinks,
+		batches: make(chan *pendingBatch, exportQueueSize),
+		done:    make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+// add queues the batch for export to all sinks that accept its table. If the queue is full, add
+// blocks until there is room, or until the exporter's context is done, in which case the batch is
+// counted as a failed export.
+func (e *batchExporter) add(table *vizierpb.QueryMetadata, batch *vizierpb.RowBatchData) {
+	if batch.NumRows == 0 || !e.accepts(table.Name) {
+		return
+	}
+	select {
+	case e.batches <- &pendingBatch{table: table, batch: batch}:
+		e.record(batch.NumRows, 0, nil)
+	case <-e.ctx.Done():
+		log.WithField("table", table.Name).Error("Export timed out, dropping row batch")
+		e.record(batch.NumRows, batch.NumRows, fmt.Errorf("export of table %s did not finish in time: %w", table.Name, e.ctx.Err()))
+	}
+}
+
+func (e *batchExporter) accepts(table string) bool {
+	for _, sink := range e.sinks {
+		if sink.accepts(table) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *batchExporter) run() {
+	defer close(e.done)
+	for b := range e.batches {
+		if err := e.export(b.table, b.batch); err != nil {
+			log.WithError(err).WithField("table", b.table.Name).Error("Failed to export rows")
+			e.record(0, b.batch.NumRows, err)
+		}
+	}
+}
+
+func (e *batchExporter) export(table *vizierpb.QueryMetadata, batch *vizierpb.RowBatchData) error {
+	if err := e.ctx.Err(); err != nil {
+		return err
+	}
+	rows, err := rowsFromBatch(table.Relation, batch)
+	if err != nil {
+		return err
+	}
+	for _, sink := range e.sinks {
+		if !sink.accepts(table.Name) {
+			continue
+		}
+		if err := sink.Export(e.ctx, table.Name, rows); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *batchExporter) record(totalRows int64, failedRows int64, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.totalRows += totalRows
+	e.failedRows += failedRows
+	if err != nil && e.firstErr == nil {
+		e.firstErr = err
+	}
+}
+
+// wait waits for all queued batches to be exported, and returns an error describing the failed
+// exports, if any. No batches may be added after wait is called.
+func (e *batchExporter) wait() error {
+	e.once.Do(func() {
+		close(e.batches)
+	})
+	<-e.done
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.firstErr == nil {
+		return nil
+	}
+	return fmt.Errorf("failed to export %d of %d rows: %w", e.failedRows, e.totalRows, e.firstErr)
+}
+
+// rowsFromBatch converts a row batch into rows keyed by the column names in the relation.
+func rowsFromBatch(relation *vizierpb.Relation, batch *vizierpb.RowBatchData) ([]exportRow, error) {
+	if len(relation.Columns) != len(batch.Cols) {
+		return nil, fmt.Errorf("relation has %d columns but batch has %d", len(relation.Columns), len(batch.Cols))
+	}
+
+	rows := make([]exportRow, batch.NumRows)
+	for i := range rows {
+		rows[i] = make(exportRow, len(batch.Cols))
+	}
+	for c, col := range batch.Cols {
+		name := relation.Columns[c].ColumnName
+		for i := range rows {
+			v, err := utils.ColumnValue(col, i)
+			if err != nil {
+				return nil, err
+			}
+			rows[i][name] = v
+		}
+	}
+	return rows, nil
+}
+
+// webhookSink POSTs batches of rows as JSON to an HTTP endpoint. Requests that fail with a
+// connection error, a 429 or a 5xx are retried with exponential backoff, any other non-2xx
+// response is treated as permanent.
+type webhookSink struct {
+	url         string
+	headers     map[string]string
+	batchSize   int
+	maxRetries  int
+	retryPeriod time.Duration
+	client      *http.Client
+}
+
+type webhookPayload struct {
+	Table string      `json:"table"`
+	Rows  []exportRow `json:"rows"`
+}
+
+func newWebhookSink(c *scripts.WebhookExportConfig) (*webhookSink, error) {
+	if c.URL == "" {
+		return nil, errors.New("webhook export requires a url")
+	}
+	s := &webhookSink{
+		url:         c.URL,
+		headers:     c.Headers,
+		batchSize:   c.BatchSize,
+		maxRetries:  c.MaxRetries,
+		retryPeriod: defaultWebhookRetryPeriod,
+		client:      &http.Client{Timeout: defaultWebhookTimeout},
+	}
+	if s.batchSize <= 0 {
+		s.batchSize = defaultWebhookBatchSize
+	}
+	if s.maxRetries <= 0 {
+		s.maxRetries = defaultWebhookMaxRetries
+	}
+	if c.TimeoutS > 0 {
+		s.client.Timeout = time.Duration(c.TimeoutS) * time.Second
+	}
+	return s, nil
+}
+
+func (s *webhookSink) Export(ctx context.Context, table string, rows []exportRow) error {
+	for start := 0; start < len(rows); start += s.batchSize {
+		end := start + s.batchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		body, err := json.Marshal(&webhookPayload{Table: table, Rows: rows[start:end]})
+		if err != nil {
+			return err
+		}
+
+		expBackoff := backoff.NewExponentialBackOff()
+		expBackoff.InitialInterval = s.retryPeriod
+		bo := backoff.WithContext(backoff.WithMaxRetries(expBackoff, uint64(s.maxRetries)), ctx)
+		err = backoff.Retry(func() error {
+			return s.post(ctx, body)
+		}, bo)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *webhookSink) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return backoff.Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	default:
+		return backoff.Permanent(fmt.Errorf("webhook returned status %d", resp.StatusCode))
+	}
+}
+
+func (s *webhookSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// kafkaWriter is the subset of kafka.Writer used by the kafka sink.
+type kafkaWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// kafkaSink produces one message per row, keyed by the table name. Retries are left to the
+// producer, which retries retriable broker errors up to MaxAttempts times.
+type kafkaSink struct {
+	writer kafkaWriter
+}
+
+func newKafkaSink(c *scripts.KafkaExportConfig) (*kafkaSink, error) {
+	if len(c.Brokers) == 0 || c.Topic == "" {
+		return nil, errors.New("kafka export requires brokers and a topic")
+	}
+	maxAttempts := c.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultKafkaMaxAttempts
+	}
+	return &kafkaSink{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(c.Brokers...),
+			Topic:        c.Topic,
+			Balancer:     &kafka.Hash{},
+			MaxAttempts:  maxAttempts,
+			RequiredAcks: kafka.RequireAll,
+		},
+	}, nil
+}
+
+func (s *kafkaSink) Export(ctx context.Context, table string, rows []exportRow) error {
+	msgs := make([]kafka.Message, len(rows))
+	for i, r := range rows {
+		b, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		msgs[i] = kafka.Message{Key: []byte(table), Value: b}
+	}
+	return s.writer.WriteMessages(ctx, msgs...)
+}
+
+func (s *kafkaSink) Close() error {
+	return s.writer.Close()
+}
+
+// fileSink appends rows as newline-delimited JSON to a local file, such as one on a mounted volume.
+// If a write fails, the file is reopened and the write is retried once, to handle volumes that
+// are remounted underneath the runner.
+type fileSink struct {
+	path string
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+type fileRecord struct {
+	Table string    `json:"table"`
+	Row   exportRow `json:"row"`
+}
+
+func newFileSink(c *scripts.FileExportConfig) (*fileSink, error) {
+	if c.Path == "" {
+		return nil, errors.New("file export requires a path")
+	}
+	return &fileSink{path: c.Path}, nil
+}
+
+func (s *fileSink) open() error {
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	s.f = f
+	return nil
+}
+
+func (s *fileSink) Export(ctx context.Context, table string, rows []exportRow) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, r := range rows {
+		if err := enc.Encode(&fileRecord{Table: table, Row: r}); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.f == nil {
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+	if _, err := s.f.Write(buf.Bytes()); err == nil {
+		return nil
+	}
+
+	s.f.Close()
+	if err := s.open(); err != nil {
+		s.f = nil
+		return err
+	}
+	_, err := s.f.Write(buf.Bytes())
+	return err
+}
+
+func (s *fileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
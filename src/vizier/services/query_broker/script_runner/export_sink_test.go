@@ -0,0 +1,405 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package scriptrunner
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"px.dev/pixie/src/api/proto/vizierpb"
+	"px.dev/pixie/src/common/base/statuspb"
+	"px.dev/pixie/src/shared/cvmsgspb"
+	"px.dev/pixie/src/shared/scripts"
+	"px.dev/pixie/src/utils"
+	"px.dev/pixie/src/vizier/services/metadata/metadatapb"
+)
+
+var testRelation = &vizierpb.Relation{
+	Columns: []*vizierpb.Relation_ColumnInfo{
+		{ColumnName: "time_", ColumnType: vizierpb.TIME64NS},
+		{ColumnName: "service", ColumnType: vizierpb.STRING},
+		{ColumnName: "count", ColumnType: vizierpb.INT64},
+	},
+}
+
+var testBatch = &vizierpb.RowBatchData{
+	TableID: "table1",
+	NumRows: 3,
+	Cols: []*vizierpb.Column{
+		{ColData: &vizierpb.Column_Time64NsData{Time64NsData: &vizierpb.Time64NSColumn{Data: []int64{0, 1000, 2000}}}},
+		{ColData: &vizierpb.Column_StringData{StringData: &vizierpb.StringColumn{Data: [][]byte{[]byte("a"), []byte("b"), []byte("c")}}}},
+		{ColData: &vizierpb.Column_Int64Data{Int64Data: &vizierpb.Int64Column{Data: []int64{1, 2, 3}}}},
+	},
+}
+
+var testRows = []exportRow{
+	{"time_": "1970-01-01T00:00:00Z", "service": "a", "count": int64(1)},
+	{"time_": "1970-01-01T00:00:00.000001Z", "service": "b", "count": int64(2)},
+	{"time_": "1970-01-01T00:00:00.000002Z", "service": "c", "count": int64(3)},
+}
+
+func TestRowsFromBatch(t *testing.T) {
+	rows, err := rowsFromBatch(testRelation, testBatch)
+	require.NoError(t, err)
+	assert.Equal(t, testRows, rows)
+
+	_, err = rowsFromBatch(&vizierpb.Relation{}, testBatch)
+	assert.Error(t, err)
+}
+
+func TestNewExportSink(t *testing.T) {
+	_, err := newExportSink(&scripts.ExportConfig{})
+	assert.Error(t, err)
+
+	_, err = newExportSink(&scripts.ExportConfig{Webhook: &scripts.WebhookExportConfig{}})
+	assert.Error(t, err)
+
+	_, err = newExportSink(&scripts.ExportConfig{Kafka: &scripts.KafkaExportConfig{Topic: "t"}})
+	assert.Error(t, err)
+
+	sink, err := newExportSink(&scripts.ExportConfig{File: &scripts.FileExportConfig{Path: "/tmp/out"}, Tables: []string{"http"}})
+	require.NoError(t, err)
+	assert.True(t, sink.accepts("http"))
+	assert.False(t, sink.accepts("dns"))
+}
+
+func TestWebhookSink_Export(t *testing.T) {
+	tests := []struct {
+		name          string
+		statusCodes   []int
+		expectedCalls int
+		expectErr     bool
+	}{
+		{
+			name:          "success",
+			statusCodes:   []int{http.StatusOK, http.StatusOK},
+			expectedCalls: 2,
+		},
+		{
+			name:          "retries server errors",
+			statusCodes:   []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK, http.StatusOK},
+			expectedCalls: 4,
+		},
+		{
+			name:          "does not retry client errors",
+			statusCodes:   []int{http.StatusBadRequest},
+			expectedCalls: 1,
+			expectErr:     true,
+		},
+		{
+			name:          "gives up after max retries",
+			statusCodes:   []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError},
+			expectedCalls: 3,
+			expectErr:     true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var mu sync.Mutex
+			calls := 0
+			var payloads []webhookPayload
+			// The handler runs on the server's goroutine, so it reports errors on a channel instead of
+			// failing the test directly.
+			handlerErrs := make(chan error, len(test.statusCodes))
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				if h := r.Header.Get("X-Token"); h != "secret" {
+					handlerErrs <- fmt.Errorf("unexpected X-Token header %q", h)
+				}
+				code := test.statusCodes[calls]
+				calls++
+				if code == http.StatusOK {
+					var p webhookPayload
+					b, err := io.ReadAll(r.Body)
+					if err == nil {
+						err = json.Unmarshal(b, &p)
+					}
+					if err != nil {
+						handlerErrs <- err
+						w.WriteHeader(http.StatusBadRequest)
+						return
+					}
+					payloads = append(payloads, p)
+				}
+				w.WriteHeader(code)
+			}))
+			defer srv.Close()
+
+			sink, err := newWebhookSink(&scripts.WebhookExportConfig{
+				URL:        srv.URL,
+				Headers:    map[string]string{"X-Token": "secret"},
+				BatchSize:  2,
+				MaxRetries: 2,
+			})
+			require.NoError(t, err)
+			sink.retryPeriod = time.Millisecond
+			defer sink.Close()
+
+			err = sink.Export(context.Background(), "http_events", testRows)
+			if test.expectErr {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Len(t, payloads, 2)
+				assert.Equal(t, "http_events", payloads[0].Table)
+				assert.Len(t, payloads[0].Rows, 2)
+				assert.Len(t, payloads[1].Rows, 1)
+				assert.Equal(t, "c", payloads[1].Rows[0]["service"])
+			}
+			assert.Equal(t, test.expectedCalls, calls)
+
+			close(handlerErrs)
+			for err := range handlerErrs {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+type fakeKafkaWriter struct {
+	msgs   []kafka.Message
+	err    error
+	closed bool
+}
+
+func (w *fakeKafkaWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.msgs = append(w.msgs, msgs...)
+	return nil
+}
+
+func (w *fakeKafkaWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+func TestKafkaSink_Export(t *testing.T) {
+	w := &fakeKafkaWriter{}
+	sink := &kafkaSink{writer: w}
+
+	require.NoError(t, sink.Export(context.Background(), "http_events", testRows))
+	require.Len(t, w.msgs, 3)
+	for i, m := range w.msgs {
+		assert.Equal(t, "http_events", string(m.Key))
+		var row map[string]interface{}
+		require.NoError(t, json.Unmarshal(m.Value, &row))
+		assert.Equal(t, testRows[i]["service"], row["service"])
+	}
+
+	w.err = errors.New("broker unavailable")
+	assert.Error(t, sink.Export(context.Background(), "http_events", testRows))
+
+	require.NoError(t, sink.Close())
+	assert.True(t, w.closed)
+}
+
+// blockingSink holds up exports until it is released.
+type blockingSink struct {
+	release chan struct{}
+
+	mu   sync.Mutex
+	rows int
+}
+
+func (s *blockingSink) Export(ctx context.Context, table string, rows []exportRow) error {
+	select {
+	case <-s.release:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rows += len(rows)
+	return nil
+}
+
+func (s *blockingSink) Close() error {
+	return nil
+}
+
+func TestBatchExporter_WaitsForSlowSinks(t *testing.T) {
+	sink := &blockingSink{release: make(chan struct{})}
+	exporter := newBatchExporter(context.Background(), []*tableFilteredSink{{exportSink: sink}})
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		close(sink.release)
+	}()
+	// More batches than fit in the queue are added, and none of them are dropped.
+	table := &vizierpb.QueryMetadata{ID: "table1", Name: "table", Relation: testRelation}
+	for i := 0; i < exportQueueSize+2; i++ {
+		exporter.add(table, testBatch)
+	}
+	require.NoError(t, exporter.wait())
+	assert.Equal(t, (exportQueueSize+2)*int(testBatch.NumRows), sink.rows)
+}
+
+func TestBatchExporter_StopsWhenContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	sink := &blockingSink{release: make(chan struct{})}
+	exporter := newBatchExporter(ctx, []*tableFilteredSink{{exportSink: sink}})
+
+	table := &vizierpb.QueryMetadata{ID: "table1", Name: "table", Relation: testRelation}
+	for i := 0; i < exportQueueSize+2; i++ {
+		exporter.add(table, testBatch)
+	}
+	err := exporter.wait()
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Contains(t, err.Error(), fmt.Sprintf("failed to export %d of %d rows", (exportQueueSize+2)*3, (exportQueueSize+2)*3))
+	assert.Equal(t, 0, sink.rows)
+}
+
+func readFileRecords(t *testing.T, path string) []fileRecord {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var records []fileRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r fileRecord
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &r))
+		records = append(records, r)
+	}
+	require.NoError(t, scanner.Err())
+	return records
+}
+
+func TestFileSink_Export(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "export.ndjson")
+	sink, err := newFileSink(&scripts.FileExportConfig{Path: path})
+	require.NoError(t, err)
+
+	require.NoError(t, sink.Export(context.Background(), "http_events", testRows))
+	require.NoError(t, sink.Export(context.Background(), "dns_events", testRows[:1]))
+
+	// Removing the file underneath the sink should not lose subsequent writes.
+	require.NoError(t, sink.Close())
+	require.NoError(t, os.Remove(path))
+	require.NoError(t, sink.Export(context.Background(), "http_events", testRows[2:]))
+	require.NoError(t, sink.Close())
+
+	records := readFileRecords(t, path)
+	require.Len(t, records, 1)
+	assert.Equal(t, "http_events", records[0].Table)
+	assert.Equal(t, "c", records[0].Row["service"])
+}
+
+func TestScriptRunner_ExportsToSinks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "export.ndjson")
+	receivedResultRequestCh := make(chan *metadatapb.RecordExecutionResultRequest)
+	fcs := &fakeCronStore{scripts: make(map[uuid.UUID]*cvmsgspb.CronScript), receivedResultRequestCh: receivedResultRequestCh}
+
+	script := &cvmsgspb.CronScript{
+		ID:         utils.ProtoFromUUIDStrOrNil("223e4567-e89b-12d3-a456-426655440000"),
+		Script:     "px.display()",
+		Configs:    "exportConfigs:\n- file:\n    path: " + path + "\n  tables: [\"table\"]\n",
+		FrequencyS: 1,
+	}
+	responses := []*vizierpb.ExecuteScriptResponse{
+		{Result: &vizierpb.ExecuteScriptResponse_MetaData{MetaData: &vizierpb.QueryMetadata{ID: "table1", Name: "table", Relation: testRelation}}},
+		{Result: &vizierpb.ExecuteScriptResponse_MetaData{MetaData: &vizierpb.QueryMetadata{ID: "table2", Name: "other", Relation: testRelation}}},
+		{Result: &vizierpb.ExecuteScriptResponse_Data{Data: &vizierpb.QueryData{Batch: testBatch}}},
+		{Result: &vizierpb.ExecuteScriptResponse_Data{Data: &vizierpb.QueryData{Batch: &vizierpb.RowBatchData{
+			TableID: "table2",
+			NumRows: testBatch.NumRows,
+			Cols:    testBatch.Cols,
+		}}}},
+		{Result: &vizierpb.ExecuteScriptResponse_Data{Data: &vizierpb.QueryData{ExecutionStats: &vizierpb.QueryExecutionStats{
+			Timing: &vizierpb.QueryTimingInfo{},
+		}}}},
+	}
+
+	id := uuid.FromStringOrNil("223e4567-e89b-12d3-a456-426655440000")
+	fvs := &fakeVizierServiceClient{responses: responses}
+	runner := newRunner(script, fvs, "test", id, fcs)
+	runner.start()
+
+	select {
+	case req := <-receivedResultRequestCh:
+		assert.NotNil(t, req.GetExecutionStats())
+	case <-time.After(time.Second * 10):
+		t.Fatal("Failed to receive a valid result")
+	}
+	runner.stop()
+
+	records := readFileRecords(t, path)
+	require.Len(t, records, 3)
+	for i, r := range records {
+		assert.Equal(t, "table", r.Table)
+		assert.Equal(t, testRows[i]["service"], r.Row["service"])
+	}
+}
+
+func TestScriptRunner_RecordsExportFailures(t *testing.T) {
+	receivedResultRequestCh := make(chan *metadatapb.RecordExecutionResultRequest)
+	fcs := &fakeCronStore{scripts: make(map[uuid.UUID]*cvmsgspb.CronScript), receivedResultRequestCh: receivedResultRequestCh}
+
+	// The parent of the file doesn't exist, so all writes to it fail.
+	path := filepath.Join(t.TempDir(), "missing", "export.ndjson")
+	script := &cvmsgspb.CronScript{
+		ID:         utils.ProtoFromUUIDStrOrNil("223e4567-e89b-12d3-a456-426655440000"),
+		Script:     "px.display()",
+		Configs:    "exportConfigs:\n- file:\n    path: " + path + "\n",
+		FrequencyS: 1,
+	}
+	responses := []*vizierpb.ExecuteScriptResponse{
+		{Result: &vizierpb.ExecuteScriptResponse_MetaData{MetaData: &vizierpb.QueryMetadata{ID: "table1", Name: "table", Relation: testRelation}}},
+		{Result: &vizierpb.ExecuteScriptResponse_Data{Data: &vizierpb.QueryData{Batch: testBatch}}},
+		{Result: &vizierpb.ExecuteScriptResponse_Data{Data: &vizierpb.QueryData{ExecutionStats: &vizierpb.QueryExecutionStats{
+			Timing: &vizierpb.QueryTimingInfo{},
+		}}}},
+	}
+
+	id := uuid.FromStringOrNil("223e4567-e89b-12d3-a456-426655440000")
+	runner := newRunner(script, &fakeVizierServiceClient{responses: responses}, "test", id, fcs)
+	runner.start()
+	defer runner.stop()
+
+	select {
+	case req := <-receivedResultRequestCh:
+		require.NotNil(t, req.GetError())
+		assert.Equal(t, statuspb.INTERNAL, req.GetError().ErrCode)
+		assert.Contains(t, req.GetError().Msg, "failed to export 3 of 3 rows")
+	case <-time.After(time.Second * 10):
+		t.Fatal("Failed to receive a valid result")
+	}
+}
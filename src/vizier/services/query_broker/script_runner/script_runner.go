@@ -485,8 +485,19 @@ func (r *runner) start() {
 	ticker := time.NewTicker(scriptPeriod)
 	r.lastRun = time.Now()
 
+	// runCtx is canceled when the runner is stopped, so that executions and exports which are still
+	// in progress are abandoned.
+	runCtx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-r.done
+		cancel()
+	}()
+
 	go func() {
 		defer ticker.Stop()
+		// The sinks live as long as the runner, and are shared by all of its executions.
+		sinks := newExportSinks(r.config.ExportConfigs)
+		defer closeExportSinks(sinks)
 		for {
 			select {
 			case <-r.done:
@@ -495,8 +506,7 @@ func (r *runner) start() {
 				claims := svcutils.GenerateJWTForService("query_broker", "vizier")
 				token, _ := svcutils.SignJWTClaims(claims, r.signingKey)
 
-				ctx := context.Background()
-				ctx = metadata.AppendToOutgoingContext(ctx, "authorization",
+				ctx := metadata.AppendToOutgoingContext(runCtx, "authorization",
 					fmt.Sprintf("bearer %s", token))
 
 				var otelEndpoint *vizierpb.Configs_OTelEndpointConfig
@@ -527,6 +537,11 @@ func (r *runner) start() {
 				if err != nil {
 					log.WithError(err).Error("Failed to execute cronscript")
 				}
+				tables := make(map[string]*vizierpb.QueryMetadata)
+				// Exports must finish before the next execution is due, so that a slow or unavailable
+				// sink can't hold up the runner and skip execution windows.
+				exportCtx, exportCancel := context.WithTimeout(runCtx, scriptPeriod)
+				exporter := newBatchExporter(exportCtx, sinks)
 				for {
 					resp, err := execScriptClient.Recv()
 					if err == io.EOF {
@@ -584,7 +599,18 @@ func (r *runner) start() {
 						}
 						break
 					}
+					if md := resp.GetMetaData(); md != nil {
+						tables[md.ID] = md
+						continue
+					}
 					if data := resp.GetData(); data != nil {
+						if data.Batch != nil {
+							if table, ok := tables[data.Batch.TableID]; ok {
+								exporter.add(table, data.Batch)
+							} else {
+								log.WithField("table_id", data.Batch.TableID).Error("Received row batch for unknown table")
+							}
+						}
 						tsPb, err := types.TimestampProto(startTime)
 						if err != nil {
 							log.WithError(err).Error("Error while creating timestamp proto")
@@ -593,7 +619,7 @@ func (r *runner) start() {
 						if stats == nil {
 							continue
 						}
-						_, err = r.csClient.RecordExecutionResult(ctx, &metadatapb.RecordExecutionResultRequest{
+						result := &metadatapb.RecordExecutionResultRequest{
 							ScriptID:  utils.ProtoFromUUID(r.scriptID),
 							Timestamp: tsPb,
 							Result: &metadatapb.RecordExecutionResultRequest_ExecutionStats{
@@ -604,7 +630,17 @@ func (r *runner) start() {
 									RecordsProcessed:  stats.RecordsProcessed,
 								},
 							},
-						})
+						}
+						// The script only succeeded if all of its output was exported.
+						if err := exporter.wait(); err != nil {
+							result.Result = &metadatapb.RecordExecutionResultRequest_Error{
+								Error: &statuspb.Status{
+									ErrCode: statuspb.INTERNAL,
+									Msg:     err.Error(),
+								},
+							}
+						}
+						_, err = r.csClient.RecordExecutionResult(ctx, result)
 						if err != nil {
 							grpcStatus, ok := status.FromError(err)
 							if !ok || grpcStatus.Code() != codes.Unavailable {
@@ -614,6 +650,10 @@ func (r *runner) start() {
 						break
 					}
 				}
+				// Wait for the remaining exports, if the execution ended without stats. Failed exports
+				// have already been logged.
+				_ = exporter.wait()
+				exportCancel()
 			}
 		}
 	}()
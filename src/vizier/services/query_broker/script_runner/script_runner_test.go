@@ -805,6 +805,10 @@ func (vs *fakeVizierServiceClient) GenerateOTelScript(ctx context.Context, req *
 	return nil, errors.New("Not implemented")
 }
 
+func (vs *fakeVizierServiceClient) GetK8SHistory(ctx context.Context, req *vizierpb.GetK8SHistoryRequest, opts ...grpc.CallOption) (*vizierpb.GetK8SHistoryResponse, error) {
+	return nil, errors.New("Not implemented")
+}
+
 func TestScriptRunner_StoreResults(t *testing.T) {
 	marshalMust := func(a *types.Any, _ error) *types.Any {
 		return a
@@ -24,17 +24,21 @@ import (
 	"sync"
 	"time"
 
+	"github.com/gofrs/uuid"
 	"github.com/gogo/protobuf/types"
 	log "github.com/sirupsen/logrus"
 	"google.golang.org/grpc/metadata"
 
-	"px.dev/pixie/src/shared/services/utils"
+	"px.dev/pixie/src/api/proto/uuidpb"
+	srvutils "px.dev/pixie/src/shared/services/utils"
+	"px.dev/pixie/src/utils"
 	"px.dev/pixie/src/vizier/services/metadata/metadatapb"
 )
 
 const (
 	updateIntervalSeconds = 5
 	maxUpdatesPerResponse = 100
+	reportTimeout         = 5 * time.Second
 )
 
 // Agents tracks the current state of running agent in the system.
@@ -101,6 +105,29 @@ func (a *Agents) GetAgentInfo() AgentsInfo {
 	return a.agentsInfo
 }
 
+// ReportQueryFailures reports the agents which took part in a failed query to the metadata service,
+// so that the failures count against the agents' health.
+func (a *Agents) ReportQueryFailures(queryID uuid.UUID, agentIDs []uuid.UUID) {
+	claims := srvutils.GenerateJWTForService("metadata_tracker", "vizier")
+	token, _ := srvutils.SignJWTClaims(claims, a.signingKey)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization",
+		fmt.Sprintf("bearer %s", token))
+	ctx, cancel := context.WithTimeout(ctx, reportTimeout)
+	defer cancel()
+
+	req := &metadatapb.RecordAgentQueryFailuresRequest{
+		QueryID:  utils.ProtoFromUUID(queryID),
+		AgentIDs: make([]*uuidpb.UUID, len(agentIDs)),
+	}
+	for i, agentID := range agentIDs {
+		req.AgentIDs[i] = utils.ProtoFromUUID(agentID)
+	}
+	if _, err := a.mdsClient.RecordAgentQueryFailures(ctx, req); err != nil {
+		log.WithError(err).Error("Failed to report agents of failed query")
+	}
+}
+
 func (a *Agents) runLoop() (bool, error) {
 	respCh, cancel, err := a.streamUpdates()
 	if err != nil {
@@ -142,8 +169,8 @@ type updateOrError struct {
 func (a *Agents) streamUpdates() (chan updateOrError, func(), error) {
 	log.Trace("Streaming agent state.")
 
-	claims := utils.GenerateJWTForService("metadata_tracker", "vizier")
-	token, _ := utils.SignJWTClaims(claims, a.signingKey)
+	claims := srvutils.GenerateJWTForService("metadata_tracker", "vizier")
+	token, _ := srvutils.SignJWTClaims(claims, a.signingKey)
 
 	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization",
 		fmt.Sprintf("bearer %s", token))
@@ -50,6 +50,9 @@ type AgentsInfoImpl struct {
 	dsMutex sync.Mutex
 
 	pendingDs *distributedpb.DistributedState
+	// The agents in pendingDs which are quarantined by the metadata service. These are left out of
+	// the distributed state when it is promoted, so that queries aren't planned on them.
+	pendingQuarantined map[uuid.UUID]bool
 }
 
 // NewAgentsInfo creates an empty agents info.
@@ -60,6 +63,7 @@ func NewAgentsInfo() AgentsInfo {
 			SchemaInfo: []*distributedpb.SchemaInfo{},
 			CarnotInfo: []*distributedpb.CarnotInfo{},
 		},
+		pendingQuarantined: make(map[uuid.UUID]bool),
 	}
 }
 
@@ -78,6 +82,7 @@ func (a *AgentsInfoImpl) ClearPendingState() {
 		SchemaInfo: []*distributedpb.SchemaInfo{},
 		CarnotInfo: []*distributedpb.CarnotInfo{},
 	}
+	a.pendingQuarantined = make(map[uuid.UUID]bool)
 }
 
 // UpdateAgentsInfo creates a new agent info.
@@ -130,6 +135,11 @@ func (a *AgentsInfoImpl) UpdateAgentsInfo(update *metadatapb.AgentUpdatesRespons
 				kelvinGRPCAddress := agent.Info.IPAddress
 				carnotInfoMap[agentUUID] = makeKelvinCarnotInfo(agentUUID, kelvinGRPCAddress, agent.ASID)
 			}
+			if agent.Health.GetQuarantined() {
+				a.pendingQuarantined[agentUUID] = true
+			} else {
+				delete(a.pendingQuarantined, agentUUID)
+			}
 		}
 		// case 2: agent data info update
 		dataInfo := agentUpdate.GetDataInfo()
@@ -151,6 +161,7 @@ func (a *AgentsInfoImpl) UpdateAgentsInfo(update *metadatapb.AgentUpdatesRespons
 		if agentUpdate.GetDeleted() {
 			deletedAgents++
 			delete(carnotInfoMap, agentUUID)
+			delete(a.pendingQuarantined, agentUUID)
 		}
 	}
 
@@ -167,8 +178,19 @@ func (a *AgentsInfoImpl) UpdateAgentsInfo(update *metadatapb.AgentUpdatesRespons
 	// If we have reached the end of version, promote the pending DistributedState to the current external-facing
 	// distributed state accessible by clients of `Agents`.
 	if update.EndOfVersion {
+		ds := *(a.pendingDs)
+		ds.CarnotInfo = []*distributedpb.CarnotInfo{}
+		for _, carnotInfo := range a.pendingDs.CarnotInfo {
+			if a.pendingQuarantined[utils.UUIDFromProtoOrNil(carnotInfo.AgentID)] {
+				continue
+			}
+			ds.CarnotInfo = append(ds.CarnotInfo, carnotInfo)
+		}
+		if len(a.pendingQuarantined) > 0 {
+			log.Tracef("Skipping %d quarantined agents", len(a.pendingQuarantined))
+		}
 		a.dsMutex.Lock()
-		a.ds = *(a.pendingDs)
+		a.ds = ds
 		a.dsMutex.Unlock()
 	}
 
@@ -350,3 +350,55 @@ func TestAgentsInfo_UpdateAgentsInfo(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, 0, len(agentsInfo.DistributedState().SchemaInfo))
 }
+
+func TestAgentsInfo_QuarantinedAgents(t *testing.T) {
+	viper.Set("pod_namespace", "pl")
+	uuidpbs := makeTestAgentIDs(t)
+	agents := makeTestAgents(t)
+
+	agentsInfo := tracker.NewAgentsInfo()
+
+	quarantinedAgent := &agentpb.Agent{
+		LastHeartbeatNS: agents[2].LastHeartbeatNS,
+		CreateTimeNS:    agents[2].CreateTimeNS,
+		Info:            agents[2].Info,
+		ASID:            agents[2].ASID,
+		Health: &agentpb.AgentHealth{
+			Score:       20,
+			Quarantined: true,
+		},
+	}
+
+	err := agentsInfo.UpdateAgentsInfo(&metadatapb.AgentUpdatesResponse{
+		AgentUpdates: []*metadatapb.AgentUpdate{
+			{
+				AgentID: uuidpbs[0],
+				Update:  &metadatapb.AgentUpdate_Agent{Agent: agents[0]},
+			},
+			{
+				AgentID: uuidpbs[2],
+				Update:  &metadatapb.AgentUpdate_Agent{Agent: quarantinedAgent},
+			},
+		},
+		EndOfVersion: true,
+	})
+	require.NoError(t, err)
+
+	// The quarantined agent shouldn't be planned on.
+	carnotInfos := agentsInfo.DistributedState().CarnotInfo
+	require.Equal(t, 1, len(carnotInfos))
+	assert.Equal(t, uuidpbs[0], carnotInfos[0].AgentID)
+
+	// Once the agent has recovered, it should be planned on again.
+	err = agentsInfo.UpdateAgentsInfo(&metadatapb.AgentUpdatesResponse{
+		AgentUpdates: []*metadatapb.AgentUpdate{
+			{
+				AgentID: uuidpbs[2],
+				Update:  &metadatapb.AgentUpdate_Agent{Agent: agents[2]},
+			},
+		},
+		EndOfVersion: true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, len(agentsInfo.DistributedState().CarnotInfo))
+}
@@ -18,7 +18,10 @@
 
 package datastore
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 // Getter is a datastore that implements a simple way to get values.
 type Getter interface {
@@ -44,6 +47,28 @@ type TTLSetter interface {
 	SetWithTTL(key string, value string, ttl time.Duration) error
 }
 
+// TTLGetter is a datastore that can report the remaining TTL of keys set with SetWithTTL.
+// The returned bool is false if the key has no TTL.
+type TTLGetter interface {
+	GetTTL(key string) (time.Duration, bool, error)
+}
+
+// KeyValue is a key in the datastore, along with its value and remaining TTL.
+type KeyValue struct {
+	Key   string
+	Value []byte
+	// TTL is the remaining TTL of the key, or zero if the key has no TTL.
+	TTL time.Duration
+}
+
+// Scanner is a datastore that can iterate over the keys with a prefix without loading all of them
+// into memory at once.
+type Scanner interface {
+	// Scan calls fn with each key with the given prefix in key order, skipping keys which have expired.
+	// Scanning stops at the first error returned by fn, which is returned by Scan.
+	Scan(prefix string, fn func(kv *KeyValue) error) error
+}
+
 // Deleter is a datastore that implements a simple way to delete values.
 type Deleter interface {
 	Delete(key string) error
@@ -61,10 +86,72 @@ type Closer interface {
 	Close() error
 }
 
-// MultiGetterSetterDeleterCloser combines MultiGetter, TTLSetter, MultiDeleter, and Closer.
+// Batch accumulates writes to a datastore, which are applied atomically when the batch is committed.
+// The writes in a batch should not overlap, since some datastores reject batches that write the same
+// key more than once.
+type Batch interface {
+	Set(key string, value string)
+	SetWithTTL(key string, value string, ttl time.Duration)
+	Delete(key string)
+	DeleteWithPrefix(prefix string)
+	// Commit applies the writes in the batch. Either all of the writes are applied, or none are,
+	// unless the batch is larger than the datastore can write at once and has to be split.
+	// The batch should not be used after it has been committed.
+	Commit() error
+}
+
+// Batcher is a datastore that can apply multiple writes atomically.
+type Batcher interface {
+	NewBatch() Batch
+}
+
+// EventType is the type of change made to a key in the datastore.
+type EventType int
+
+const (
+	// EventTypePut is the type of event for keys which are set.
+	EventTypePut EventType = iota
+	// EventTypeDelete is the type of event for keys which are deleted, including keys deleted when
+	// their TTL expires.
+	EventTypeDelete
+)
+
+// Event is a change made to a key in the datastore.
+type Event struct {
+	Type  EventType
+	Key   string
+	Value []byte
+	// The revision of the datastore at which the change was made. Revisions increase with each write
+	// to the datastore, and all of the changes made by a single write (such as a Batch) share a revision.
+	Revision int64
+}
+
+// Watcher is a datastore that can stream the changes made to keys.
+type Watcher interface {
+	// Watch streams the changes made to keys with the given prefix, in the order that they were made,
+	// starting from the time of the call. The returned channel is closed once the context is cancelled,
+	// or if the watch fails, such as when the watcher falls too far behind the writes to the datastore.
+	Watch(ctx context.Context, prefix string) (<-chan *Event, error)
+}
+
+// Compactor is a datastore that can compact its storage on demand, reclaiming the space used by
+// deleted and overwritten keys.
+type Compactor interface {
+	Compact() error
+}
+
+// Sizer is a datastore that can report how much space it uses on disk.
+type Sizer interface {
+	Size() uint64
+}
+
+// MultiGetterSetterDeleterCloser combines MultiGetter, Scanner, TTLSetter, MultiDeleter, Batcher, Watcher, and Closer.
 type MultiGetterSetterDeleterCloser interface {
 	MultiGetter
+	Scanner
 	TTLSetter
 	MultiDeleter
+	Batcher
+	Watcher
 	Closer
 }
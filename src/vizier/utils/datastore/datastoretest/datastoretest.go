@@ -0,0 +1,318 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+// Package datastoretest contains the conformance tests which every datastore implementation should pass.
+package datastoretest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"px.dev/pixie/src/vizier/utils/datastore"
+)
+
+func setupDatastore(t *testing.T, db datastore.MultiGetterSetterDeleterCloser) {
+	// Clear out the keys written by earlier tests.
+	for _, prefix := range []string{"jam", "key", "lim", "watched/", "unwatched/"} {
+		err := db.DeleteWithPrefix(prefix)
+		require.NoError(t, err)
+	}
+
+	err := db.Set("jam1", "neg")
+	require.NoError(t, err)
+	err = db.Set("key1", "val1")
+	require.NoError(t, err)
+	err = db.Set("key2", "val2")
+	require.NoError(t, err)
+	err = db.Set("key3", "val3")
+	require.NoError(t, err)
+	err = db.Set("key9", "val9")
+	require.NoError(t, err)
+	err = db.Set("lim1", "inf")
+	require.NoError(t, err)
+}
+
+// Run runs the conformance tests against the given datastore, and closes it once they are done.
+// Testing TTL expiry takes several seconds, so it is only tested if runTTLTests is set.
+func Run(t *testing.T, db datastore.MultiGetterSetterDeleterCloser, runTTLTests bool) {
+	t.Run("Set/Get", func(t *testing.T) {
+		setupDatastore(t, db)
+		v, err := db.Get("key1")
+		require.NoError(t, err)
+		assert.Equal(t, "val1", string(v))
+
+		v, err = db.Get("key2")
+		require.NoError(t, err)
+		assert.Equal(t, "val2", string(v))
+
+		err = db.Set("key1", "val1.1")
+		require.NoError(t, err)
+
+		v, err = db.Get("key1")
+		require.NoError(t, err)
+		assert.Equal(t, "val1.1", string(v))
+
+		v, err = db.Get("nonexistent")
+		require.NoError(t, err)
+		assert.Nil(t, v)
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		setupDatastore(t, db)
+		t.Run("Range", func(t *testing.T) {
+			keys, vals, err := db.GetWithRange("key1", "key1.1")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"key1"}, keys)
+			assert.Equal(t, [][]byte{[]byte("val1")}, vals)
+
+			keys, vals, err = db.GetWithRange("key1", "key2")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"key1"}, keys)
+			assert.Equal(t, [][]byte{[]byte("val1")}, vals)
+
+			keys, vals, err = db.GetWithRange("key1", "key4")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"key1", "key2", "key3"}, keys)
+			assert.Equal(t, [][]byte{[]byte("val1"), []byte("val2"), []byte("val3")}, vals)
+
+			keys, vals, err = db.GetWithRange("nonexistent", "nonexistent2")
+			require.NoError(t, err)
+			assert.Nil(t, keys)
+			assert.Nil(t, vals)
+		})
+
+		t.Run("Prefix", func(t *testing.T) {
+			keys, vals, err := db.GetWithPrefix("key")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"key1", "key2", "key3", "key9"}, keys)
+			assert.Equal(t, [][]byte{[]byte("val1"), []byte("val2"), []byte("val3"), []byte("val9")}, vals)
+
+			keys, vals, err = db.GetWithPrefix("nonexistent")
+			require.NoError(t, err)
+			assert.Nil(t, keys)
+			assert.Nil(t, vals)
+		})
+	})
+
+	t.Run("Scan", func(t *testing.T) {
+		setupDatastore(t, db)
+		err := db.SetWithTTL("key5", "val5", time.Hour)
+		require.NoError(t, err)
+
+		var kvs []*datastore.KeyValue
+		err = db.Scan("key", func(kv *datastore.KeyValue) error {
+			kvs = append(kvs, kv)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Len(t, kvs, 5)
+		keys := make([]string, len(kvs))
+		for i, kv := range kvs {
+			keys[i] = kv.Key
+		}
+		assert.Equal(t, []string{"key1", "key2", "key3", "key5", "key9"}, keys)
+		assert.Equal(t, "val1", string(kvs[0].Value))
+		assert.Equal(t, time.Duration(0), kvs[0].TTL)
+		assert.InDelta(t, time.Hour.Seconds(), kvs[3].TTL.Seconds(), 60)
+
+		errStop := errors.New("stop")
+		n := 0
+		err = db.Scan("key", func(kv *datastore.KeyValue) error {
+			n++
+			return errStop
+		})
+		assert.Equal(t, errStop, err)
+		assert.Equal(t, 1, n)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		setupDatastore(t, db)
+		err := db.Delete("key2")
+		require.NoError(t, err)
+		v, err := db.Get("key2")
+		require.NoError(t, err)
+		assert.Nil(t, v)
+
+		// No error when deleting nonexistent keys.
+		err = db.Delete("nonexistent")
+		require.NoError(t, err)
+
+		v, err = db.Get("key1")
+		require.NoError(t, err)
+		assert.Equal(t, "val1", string(v))
+	})
+
+	t.Run("DeleteAll", func(t *testing.T) {
+		setupDatastore(t, db)
+		err := db.DeleteAll([]string{"key1", "key3", "nonexistent"})
+		require.NoError(t, err)
+		v, err := db.Get("key1")
+		require.NoError(t, err)
+		assert.Nil(t, v)
+
+		v, err = db.Get("key2")
+		require.NoError(t, err)
+		assert.Equal(t, "val2", string(v))
+	})
+
+	t.Run("DeletePrefix", func(t *testing.T) {
+		setupDatastore(t, db)
+		err := db.DeleteWithPrefix("key")
+
+		require.NoError(t, err)
+		v, err := db.Get("key1")
+		require.NoError(t, err)
+		assert.Nil(t, v)
+
+		v, err = db.Get("key2")
+		require.NoError(t, err)
+		assert.Nil(t, v)
+
+		v, err = db.Get("jam1")
+		require.NoError(t, err)
+		assert.Equal(t, "neg", string(v))
+
+		// No error when deleting nonexistent keys.
+		err = db.DeleteWithPrefix("nonexistent")
+		require.NoError(t, err)
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		setupDatastore(t, db)
+		batch := db.NewBatch()
+		batch.Set("key1", "val1.2")
+		batch.Set("key4", "val4")
+		batch.Delete("key2")
+		batch.DeleteWithPrefix("lim")
+		// Writes to a key replace the earlier writes to it in the batch.
+		batch.Set("key5", "val5")
+		batch.Set("key5", "val5.1")
+
+		// The writes shouldn't be applied until the batch is committed.
+		v, err := db.Get("key4")
+		require.NoError(t, err)
+		assert.Nil(t, v)
+
+		require.NoError(t, batch.Commit())
+
+		keys, vals, err := db.GetWithPrefix("key")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"key1", "key3", "key4", "key5", "key9"}, keys)
+		assert.Equal(t, [][]byte{[]byte("val1.2"), []byte("val3"), []byte("val4"), []byte("val5.1"), []byte("val9")}, vals)
+
+		v, err = db.Get("lim1")
+		require.NoError(t, err)
+		assert.Nil(t, v)
+
+		// Empty batches are fine to commit.
+		require.NoError(t, db.NewBatch().Commit())
+	})
+
+	t.Run("Watch", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		ch, err := db.Watch(ctx, "watched/")
+		require.NoError(t, err)
+
+		require.NoError(t, db.Set("watched/1", "val1"))
+		require.NoError(t, db.Set("unwatched/1", "val1"))
+		require.NoError(t, db.Delete("watched/1"))
+
+		var events []*datastore.Event
+		for len(events) < 2 {
+			select {
+			case e := <-ch:
+				events = append(events, e)
+			case <-time.After(10 * time.Second):
+				t.Fatal("Timed out waiting for watch events")
+			}
+		}
+		assert.Equal(t, datastore.EventTypePut, events[0].Type)
+		assert.Equal(t, "watched/1", events[0].Key)
+		assert.Equal(t, []byte("val1"), events[0].Value)
+		assert.Equal(t, datastore.EventTypeDelete, events[1].Type)
+		assert.Equal(t, "watched/1", events[1].Key)
+		assert.Greater(t, events[1].Revision, events[0].Revision)
+	})
+
+	if runTTLTests {
+		t.Run("SetWithTTL", func(t *testing.T) {
+			now := time.Now()
+			ttl := 3 * time.Second
+
+			err := db.SetWithTTL("/timed1", "limited1", ttl)
+			require.NoError(t, err)
+			// Set and reset TTL
+			err = db.SetWithTTL("timed2", "limited2", ttl)
+			require.NoError(t, err)
+			err = db.SetWithTTL("timed2", "limited2", 1*time.Hour)
+			require.NoError(t, err)
+
+			ttlGetter, ok := db.(datastore.TTLGetter)
+			require.True(t, ok)
+			remaining, hasTTL, err := ttlGetter.GetTTL("timed2")
+			require.NoError(t, err)
+			assert.True(t, hasTTL)
+			assert.InDelta(t, time.Hour.Seconds(), remaining.Seconds(), 60)
+			_, hasTTL, err = ttlGetter.GetTTL("key1")
+			require.NoError(t, err)
+			assert.False(t, hasTTL)
+
+			timedOut := time.After(60 * time.Second)
+			ticker := time.NewTicker(1 * time.Second)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-timedOut:
+					// Log but don't fail since this is flaky on CPU constrained
+					// environments.
+					t.Log("WARNING: set with TTL timed out and key still exists")
+					return
+				case <-ticker.C:
+					v, err := db.Get("/timed1")
+					require.NoError(t, err)
+					if time.Since(now) < ttl {
+						assert.Equal(t, "limited1", string(v))
+					} else if v == nil {
+						// Key timed1 was deleted some time after TTL passed.
+
+						// Key timed2 should still exist since a longer TTL was set on it.
+						v, err = db.Get("timed2")
+						require.NoError(t, err)
+						assert.Equal(t, "limited2", string(v))
+
+						return
+					}
+				}
+			}
+		})
+	}
+
+	err := db.Close()
+	assert.NoError(t, err)
+
+	// Calling close repeatedly should be fine
+	err = db.Close()
+	assert.NoError(t, err)
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package etcd
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"px.dev/pixie/src/vizier/utils/datastore"
+)
+
+// ErrBatchTooLarge is returned when a write in a batch is larger than fits in an etcd transaction.
+var ErrBatchTooLarge = errors.New("batch is too large for a single etcd transaction")
+
+type batchOp struct {
+	key    string
+	value  string
+	ttl    time.Duration
+	delete bool
+	prefix bool
+}
+
+// Batch accumulates writes which are applied in as few etcd transactions as possible on Commit.
+type Batch struct {
+	client *clientv3.Client
+	ops    []*batchOp
+	// The index of the last op for each key, so that repeated writes to a key replace each other.
+	// etcd rejects transactions which write the same key more than once.
+	opIdx map[string]int
+}
+
+// NewBatch creates a new batch of writes to the datastore.
+func (w *DataStore) NewBatch() datastore.Batch {
+	return &Batch{
+		client: w.client,
+		opIdx:  make(map[string]int),
+	}
+}
+
+func (b *Batch) add(op *batchOp) {
+	if op.prefix {
+		b.ops = append(b.ops, op)
+		return
+	}
+	if i, ok := b.opIdx[op.key]; ok {
+		b.ops[i] = op
+		return
+	}
+	b.opIdx[op.key] = len(b.ops)
+	b.ops = append(b.ops, op)
+}
+
+// Set adds a write of the given key and value to the batch.
+func (b *Batch) Set(key string, value string) {
+	b.add(&batchOp{key: key, value: value})
+}
+
+// SetWithTTL adds a write of the given key and value with a TTL to the batch.
+func (b *Batch) SetWithTTL(key string, value string, ttl time.Duration) {
+	b.add(&batchOp{key: key, value: value, ttl: ttl})
+}
+
+// Delete adds a delete of the given key to the batch.
+func (b *Batch) Delete(key string) {
+	b.add(&batchOp{key: key, delete: true})
+}
+
+// DeleteWithPrefix adds a delete of all keys with the given prefix to the batch.
+func (b *Batch) DeleteWithPrefix(prefix string) {
+	b.add(&batchOp{key: prefix, delete: true, prefix: true})
+}
+
+// Commit applies all of the writes in the batch. Batches which don't fit in a single etcd transaction
+// are split into several transactions which are applied in order, so only the writes within each
+// transaction are applied atomically. Returns ErrBatchTooLarge if a single write doesn't fit in a
+// transaction.
+func (b *Batch) Commit() error {
+	ctx := context.Background()
+	for _, op := range b.ops {
+		if len(op.value) > maxNumBytes {
+			return ErrBatchTooLarge
+		}
+	}
+
+	// Keys with the same TTL share a lease. Leases are granted outside of the transaction. If the
+	// transaction fails, the lease just expires without being attached to any keys.
+	leases := make(map[time.Duration]clientv3.LeaseID)
+	ops := make([]clientv3.Op, len(b.ops))
+	for i, op := range b.ops {
+		switch {
+		case op.delete && op.prefix:
+			ops[i] = clientv3.OpDelete(op.key, clientv3.WithPrefix())
+		case op.delete:
+			ops[i] = clientv3.OpDelete(op.key)
+		case op.ttl > 0:
+			lease, ok := leases[op.ttl]
+			if !ok {
+				resp, err := b.client.Grant(ctx, int64(op.ttl.Seconds()))
+				if err != nil {
+					return err
+				}
+				lease = resp.ID
+				leases[op.ttl] = lease
+			}
+			ops[i] = clientv3.OpPut(op.key, op.value, clientv3.WithLease(lease))
+		default:
+			ops[i] = clientv3.OpPut(op.key, op.value)
+		}
+	}
+
+	_, err := batchOps(ctx, b.client, ops)
+	return err
+}
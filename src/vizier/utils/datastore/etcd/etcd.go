@@ -25,8 +25,13 @@ import (
 
 	"go.etcd.io/etcd/api/v3/mvccpb"
 	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"px.dev/pixie/src/vizier/utils/datastore"
 )
 
+// The number of keys read in each request by Scan.
+const scanPageSize = 1000
+
 // DataStore wraps a clientv3 datastore.
 type DataStore struct {
 	client *clientv3.Client
@@ -69,6 +74,22 @@ func (w *DataStore) Get(key string) ([]byte, error) {
 	return resp.Kvs[0].Value, nil
 }
 
+// GetTTL gets the remaining TTL for the given key, from the lease attached to the key.
+func (w *DataStore) GetTTL(key string) (time.Duration, bool, error) {
+	resp, err := w.client.Get(context.Background(), key, clientv3.WithSerializable())
+	if err != nil {
+		return 0, false, err
+	}
+	if len(resp.Kvs) == 0 || resp.Kvs[0].Lease == 0 {
+		return 0, false, nil
+	}
+	ttlResp, err := w.client.TimeToLive(context.Background(), clientv3.LeaseID(resp.Kvs[0].Lease))
+	if err != nil {
+		return 0, false, err
+	}
+	return time.Duration(ttlResp.TTL) * time.Second, true, nil
+}
+
 func kvsToSlices(kvs []*mvccpb.KeyValue) ([]string, [][]byte, error) {
 	if len(kvs) == 0 {
 		return nil, nil, nil
@@ -106,6 +127,65 @@ func (w *DataStore) GetWithPrefix(prefix string) ([]string, [][]byte, error) {
 	return kvsToSlices(resp.Kvs)
 }
 
+// Scan calls fn with each key with the given prefix in key order. The keys are read in pages of
+// scanPageSize keys, all at the revision of the first page. The remaining TTL of each lease is fetched
+// once, no matter how many keys are attached to it.
+func (w *DataStore) Scan(prefix string, fn func(kv *datastore.KeyValue) error) error {
+	ctx := context.Background()
+	end := clientv3.GetPrefixRangeEnd(prefix)
+	leaseTTLs := make(map[clientv3.LeaseID]time.Duration)
+
+	from := prefix
+	var rev int64
+	for {
+		opts := []clientv3.OpOption{
+			clientv3.WithRange(end),
+			clientv3.WithLimit(scanPageSize),
+			clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend),
+		}
+		if rev != 0 {
+			opts = append(opts, clientv3.WithRev(rev))
+		}
+		resp, err := w.client.Get(ctx, from, opts...)
+		if err != nil {
+			return err
+		}
+		if rev == 0 {
+			rev = resp.Header.Revision
+		}
+
+		for _, kv := range resp.Kvs {
+			out := &datastore.KeyValue{Key: string(kv.Key), Value: kv.Value}
+			if kv.Lease != 0 {
+				leaseID := clientv3.LeaseID(kv.Lease)
+				ttl, ok := leaseTTLs[leaseID]
+				if !ok {
+					ttlResp, err := w.client.TimeToLive(ctx, leaseID)
+					if err != nil {
+						return err
+					}
+					ttl = time.Duration(ttlResp.TTL) * time.Second
+					leaseTTLs[leaseID] = ttl
+				}
+				// A TTL of -1 means that the lease has expired, and the key is about to be deleted.
+				if ttl <= 0 {
+					continue
+				}
+				out.TTL = ttl
+			}
+			if err := fn(out); err != nil {
+				return err
+			}
+		}
+
+		if !resp.More || len(resp.Kvs) == 0 {
+			return nil
+		}
+		// Continue from the key right after the last key in the page.
+		from = string(resp.Kvs[len(resp.Kvs)-1].Key) + "\x00"
+	}
+}
+
 // Delete deletes the value for the given key from the datastore.
 func (w *DataStore) Delete(key string) error {
 	_, err := w.client.Delete(context.Background(), key)
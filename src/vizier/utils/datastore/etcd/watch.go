@@ -0,0 +1,64 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package etcd
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"px.dev/pixie/src/vizier/utils/datastore"
+)
+
+// Watch streams the changes made to keys with the given prefix, using an etcd watch. The revisions
+// of the events are the etcd revisions at which the keys were modified.
+func (w *DataStore) Watch(ctx context.Context, prefix string) (<-chan *datastore.Event, error) {
+	// The etcd client closes the watch channel once the context is cancelled.
+	watchCh := w.client.Watch(clientv3.WithRequireLeader(ctx), prefix, clientv3.WithPrefix())
+	ch := make(chan *datastore.Event)
+	go func() {
+		defer close(ch)
+		for resp := range watchCh {
+			if err := resp.Err(); err != nil {
+				log.WithError(err).WithField("prefix", prefix).Error("etcd watch failed")
+				return
+			}
+			for _, ev := range resp.Events {
+				e := &datastore.Event{
+					Type:     datastore.EventTypePut,
+					Key:      string(ev.Kv.Key),
+					Value:    ev.Kv.Value,
+					Revision: ev.Kv.ModRevision,
+				}
+				if ev.Type == mvccpb.DELETE {
+					e.Type = datastore.EventTypeDelete
+					e.Value = nil
+				}
+				select {
+				case ch <- e:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return ch, nil
+}
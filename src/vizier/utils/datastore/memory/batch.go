@@ -0,0 +1,119 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package memory
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"px.dev/pixie/src/vizier/utils/datastore"
+)
+
+type batchOp struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+	delete    bool
+	prefix    bool
+}
+
+// Batch accumulates writes which are applied to the datastore atomically on Commit.
+type Batch struct {
+	ds  *DataStore
+	ops []*batchOp
+}
+
+// NewBatch creates a new batch of writes to the datastore.
+func (w *DataStore) NewBatch() datastore.Batch {
+	return &Batch{ds: w}
+}
+
+// Set adds a write of the given key and value to the batch.
+func (b *Batch) Set(key string, value string) {
+	b.ops = append(b.ops, &batchOp{key: key, value: []byte(value)})
+}
+
+// SetWithTTL adds a write of the given key and value with a TTL to the batch.
+func (b *Batch) SetWithTTL(key string, value string, ttl time.Duration) {
+	b.ops = append(b.ops, &batchOp{key: key, value: []byte(value), expiresAt: time.Now().Add(ttl)})
+}
+
+// Delete adds a delete of the given key to the batch.
+func (b *Batch) Delete(key string) {
+	b.ops = append(b.ops, &batchOp{key: key, delete: true})
+}
+
+// DeleteWithPrefix adds a delete of all keys with the given prefix to the batch.
+func (b *Batch) DeleteWithPrefix(prefix string) {
+	b.ops = append(b.ops, &batchOp{key: prefix, delete: true, prefix: true})
+}
+
+// Commit applies all of the writes in the batch to the datastore atomically.
+func (b *Batch) Commit() error {
+	w := b.ds
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return ErrClosed
+	}
+
+	w.revision++
+	var events []*datastore.Event
+	for _, op := range b.ops {
+		switch {
+		case op.prefix:
+			var keys []string
+			for k := range w.entries {
+				if strings.HasPrefix(k, op.key) {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				delete(w.entries, k)
+				events = append(events, &datastore.Event{
+					Type:     datastore.EventTypeDelete,
+					Key:      k,
+					Revision: w.revision,
+				})
+			}
+		case op.delete:
+			if _, ok := w.entries[op.key]; !ok {
+				continue
+			}
+			delete(w.entries, op.key)
+			events = append(events, &datastore.Event{
+				Type:     datastore.EventTypeDelete,
+				Key:      op.key,
+				Revision: w.revision,
+			})
+		default:
+			w.entries[op.key] = &entry{value: op.value, expiresAt: op.expiresAt}
+			events = append(events, &datastore.Event{
+				Type:     datastore.EventTypePut,
+				Key:      op.key,
+				Value:    copyBytes(op.value),
+				Revision: w.revision,
+			})
+		}
+	}
+	w.publish(events)
+	return nil
+}
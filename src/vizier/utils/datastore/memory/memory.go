@@ -0,0 +1,342 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package memory
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"px.dev/pixie/src/vizier/utils/datastore"
+)
+
+// The number of events buffered for each watcher. Watchers which fall further behind than this are closed.
+const watchBufferSize = 1024
+
+// ErrClosed is returned by operations on a datastore which has been closed.
+var ErrClosed = errors.New("datastore is closed")
+
+type entry struct {
+	value []byte
+	// The time at which the entry expires. Zero if the entry has no TTL.
+	expiresAt time.Time
+}
+
+func (e *entry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+type watcher struct {
+	prefix string
+	ch     chan *datastore.Event
+}
+
+// DataStore is a thread-safe datastore which keeps all of its keys in memory. It is meant for tests
+// and small clusters, where the contents of the datastore don't need to survive restarts.
+type DataStore struct {
+	mu       sync.Mutex
+	entries  map[string]*entry
+	revision int64
+	watchers map[*watcher]struct{}
+	closed   bool
+
+	done chan struct{}
+	once sync.Once
+}
+
+// New creates a new in-memory datastore. Keys with a TTL are deleted every ttlReaperDuration once
+// they have expired, and are treated as deleted by reads in the meantime.
+func New(ttlReaperDuration time.Duration) *DataStore {
+	w := &DataStore{
+		entries:  make(map[string]*entry),
+		watchers: make(map[*watcher]struct{}),
+		done:     make(chan struct{}),
+	}
+
+	go w.ttlWatcher(ttlReaperDuration)
+
+	return w
+}
+
+func (w *DataStore) ttlWatcher(ttlReaperDuration time.Duration) {
+	ticker := time.NewTicker(ttlReaperDuration)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			w.deleteExpired(time.Now())
+		}
+	}
+}
+
+func (w *DataStore) deleteExpired(now time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+
+	var keys []string
+	for k, e := range w.entries {
+		if e.expired(now) {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+
+	sort.Strings(keys)
+	w.revision++
+	events := make([]*datastore.Event, len(keys))
+	for i, k := range keys {
+		delete(w.entries, k)
+		events[i] = &datastore.Event{
+			Type:     datastore.EventTypeDelete,
+			Key:      k,
+			Revision: w.revision,
+		}
+	}
+	w.publish(events)
+}
+
+// get returns the entry for the key, if it exists and hasn't expired. Must be called with mu held.
+func (w *DataStore) get(key string, now time.Time) *entry {
+	e, ok := w.entries[key]
+	if !ok || e.expired(now) {
+		return nil
+	}
+	return e
+}
+
+// Get gets the value for the given key from the datastore.
+func (w *DataStore) Get(key string) ([]byte, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil, ErrClosed
+	}
+	e := w.get(key, time.Now())
+	if e == nil {
+		return nil, nil
+	}
+	return copyBytes(e.value), nil
+}
+
+// GetTTL gets the remaining TTL for the given key.
+func (w *DataStore) GetTTL(key string) (time.Duration, bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, false, ErrClosed
+	}
+	now := time.Now()
+	e := w.get(key, now)
+	if e == nil || e.expiresAt.IsZero() {
+		return 0, false, nil
+	}
+	return e.expiresAt.Sub(now), true, nil
+}
+
+// GetWithRange gets all keys and values within the given range.
+// Treats this as [from, to) i.e. includes the key from, but excludes the key to.
+func (w *DataStore) GetWithRange(from string, to string) ([]string, [][]byte, error) {
+	return w.getMatching(func(key string) bool {
+		return key >= from && key < to
+	})
+}
+
+// GetWithPrefix gets all keys and values with the given prefix.
+func (w *DataStore) GetWithPrefix(prefix string) ([]string, [][]byte, error) {
+	return w.getMatching(func(key string) bool {
+		return strings.HasPrefix(key, prefix)
+	})
+}
+
+func (w *DataStore) getMatching(match func(key string) bool) ([]string, [][]byte, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil, nil, ErrClosed
+	}
+
+	now := time.Now()
+	var keys []string
+	for k, e := range w.entries {
+		if match(k) && !e.expired(now) {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, nil, nil
+	}
+
+	sort.Strings(keys)
+	values := make([][]byte, len(keys))
+	for i, k := range keys {
+		values[i] = copyBytes(w.entries[k].value)
+	}
+	return keys, values, nil
+}
+
+// Scan calls fn with each key with the given prefix in key order. The keys are read at the time of the
+// call, so fn may write to the datastore.
+func (w *DataStore) Scan(prefix string, fn func(kv *datastore.KeyValue) error) error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return ErrClosed
+	}
+	now := time.Now()
+	var kvs []*datastore.KeyValue
+	for k, e := range w.entries {
+		if !strings.HasPrefix(k, prefix) || e.expired(now) {
+			continue
+		}
+		kv := &datastore.KeyValue{Key: k, Value: copyBytes(e.value)}
+		if !e.expiresAt.IsZero() {
+			kv.TTL = e.expiresAt.Sub(now)
+		}
+		kvs = append(kvs, kv)
+	}
+	w.mu.Unlock()
+
+	sort.Slice(kvs, func(i, j int) bool { return kvs[i].Key < kvs[j].Key })
+	for _, kv := range kvs {
+		if err := fn(kv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Set puts the given key and value in the datastore.
+func (w *DataStore) Set(key string, value string) error {
+	batch := w.NewBatch()
+	batch.Set(key, value)
+	return batch.Commit()
+}
+
+// SetWithTTL puts the given key and value into the datastore with a TTL.
+// Once the TTL expires the datastore is expected to delete the given key and value.
+func (w *DataStore) SetWithTTL(key string, value string, ttl time.Duration) error {
+	batch := w.NewBatch()
+	batch.SetWithTTL(key, value, ttl)
+	return batch.Commit()
+}
+
+// Delete deletes the value for the given key from the datastore.
+func (w *DataStore) Delete(key string) error {
+	batch := w.NewBatch()
+	batch.Delete(key)
+	return batch.Commit()
+}
+
+// DeleteAll deletes all of the given keys and corresponding values in the datastore if they exist.
+func (w *DataStore) DeleteAll(keys []string) error {
+	batch := w.NewBatch()
+	for _, key := range keys {
+		batch.Delete(key)
+	}
+	return batch.Commit()
+}
+
+// DeleteWithPrefix deletes all keys and values with the given prefix.
+func (w *DataStore) DeleteWithPrefix(prefix string) error {
+	batch := w.NewBatch()
+	batch.DeleteWithPrefix(prefix)
+	return batch.Commit()
+}
+
+// Watch streams the changes made to keys with the given prefix.
+func (w *DataStore) Watch(ctx context.Context, prefix string) (<-chan *datastore.Event, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil, ErrClosed
+	}
+
+	wt := &watcher{
+		prefix: prefix,
+		ch:     make(chan *datastore.Event, watchBufferSize),
+	}
+	w.watchers[wt] = struct{}{}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-w.done:
+		}
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		w.removeWatcher(wt)
+	}()
+	return wt.ch, nil
+}
+
+// removeWatcher closes the watcher, if it hasn't already been closed. Must be called with mu held.
+func (w *DataStore) removeWatcher(wt *watcher) {
+	if _, ok := w.watchers[wt]; !ok {
+		return
+	}
+	delete(w.watchers, wt)
+	close(wt.ch)
+}
+
+// publish sends the events to the watchers. Must be called with mu held.
+func (w *DataStore) publish(events []*datastore.Event) {
+	for _, e := range events {
+		for wt := range w.watchers {
+			if !strings.HasPrefix(e.Key, wt.prefix) {
+				continue
+			}
+			select {
+			case wt.ch <- e:
+			default:
+				log.WithField("prefix", wt.prefix).Warn("Closing datastore watcher which has fallen behind")
+				w.removeWatcher(wt)
+			}
+		}
+	}
+}
+
+// Close stops the TTL watcher and closes the watchers. All other operations will fail after calling Close.
+func (w *DataStore) Close() error {
+	w.once.Do(func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		w.closed = true
+		w.entries = nil
+		close(w.done)
+	})
+	return nil
+}
+
+func copyBytes(b []byte) []byte {
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
+}
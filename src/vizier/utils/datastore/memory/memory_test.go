@@ -0,0 +1,62 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package memory_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"px.dev/pixie/src/vizier/utils/datastore"
+	"px.dev/pixie/src/vizier/utils/datastore/datastoretest"
+	"px.dev/pixie/src/vizier/utils/datastore/memory"
+)
+
+func TestDataStore(t *testing.T) {
+	datastoretest.Run(t, memory.New(100*time.Millisecond), true)
+}
+
+func TestDataStore_WatchTTLExpiry(t *testing.T) {
+	db := memory.New(10 * time.Millisecond)
+	defer db.Close()
+
+	ch, err := db.Watch(context.Background(), "/timed")
+	require.NoError(t, err)
+	require.NoError(t, db.SetWithTTL("/timed", "v", time.Millisecond))
+
+	var events []*datastore.Event
+	for len(events) < 2 {
+		select {
+		case e := <-ch:
+			events = append(events, e)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for watch events")
+		}
+	}
+	assert.Equal(t, &datastore.Event{Type: datastore.EventTypePut, Key: "/timed", Value: []byte("v"), Revision: 1}, events[0])
+	assert.Equal(t, &datastore.Event{Type: datastore.EventTypeDelete, Key: "/timed", Revision: 2}, events[1])
+
+	// Operations fail once the datastore is closed.
+	require.NoError(t, db.Close())
+	_, err = db.Get("/timed")
+	assert.Equal(t, memory.ErrClosed, err)
+}
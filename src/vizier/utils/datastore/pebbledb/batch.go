@@ -0,0 +1,100 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package pebbledb
+
+import (
+	"time"
+
+	"github.com/cockroachdb/pebble"
+
+	"px.dev/pixie/src/vizier/utils/datastore"
+)
+
+// batchOp is a write in a batch, which is published to the watchers of the datastore once the
+// batch is committed.
+type batchOp struct {
+	key    string
+	value  []byte
+	delete bool
+	prefix bool
+}
+
+// Batch wraps a pebble batch. The writes in the batch are applied atomically on Commit.
+type Batch struct {
+	ds  *DataStore
+	b   *pebble.Batch
+	ops []*batchOp
+	// The first error encountered while adding writes to the batch. This is returned by Commit.
+	err error
+}
+
+func (w *DataStore) newBatch() *Batch {
+	return &Batch{ds: w, b: w.db.NewBatch()}
+}
+
+// NewBatch creates a new batch of writes to the datastore.
+func (w *DataStore) NewBatch() datastore.Batch {
+	return w.newBatch()
+}
+
+func (b *Batch) setErr(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// Set adds a write of the given key and value to the batch.
+func (b *Batch) Set(key string, value string) {
+	b.setErr(b.b.Set([]byte(key), []byte(value), nil))
+	b.ops = append(b.ops, &batchOp{key: key, value: []byte(value)})
+}
+
+// SetWithTTL adds a write of the given key and value with a TTL to the batch.
+func (b *Batch) SetWithTTL(key string, value string, ttl time.Duration) {
+	expiresAt := time.Now().Add(ttl)
+	encodedExpiry, err := expiresAt.MarshalBinary()
+	if err != nil {
+		b.setErr(err)
+		return
+	}
+	b.Set(key, value)
+	b.setErr(b.b.Set([]byte(getKeyForTTLByKey(key)), encodedExpiry, nil))
+	b.setErr(b.b.Set([]byte(getKeyForTTLByTime(key, expiresAt)), nil, nil))
+}
+
+// Delete adds a delete of the given key to the batch.
+func (b *Batch) Delete(key string) {
+	b.setErr(b.b.Delete([]byte(key), nil))
+	b.ops = append(b.ops, &batchOp{key: key, delete: true})
+}
+
+// DeleteWithPrefix adds a delete of all keys with the given prefix to the batch.
+func (b *Batch) DeleteWithPrefix(prefix string) {
+	b.setErr(b.b.DeleteRange([]byte(prefix), keyUpperBound([]byte(prefix)), nil))
+	b.ops = append(b.ops, &batchOp{key: prefix, delete: true, prefix: true})
+}
+
+// Commit applies all of the writes in the batch to the datastore atomically.
+func (b *Batch) Commit() error {
+	defer b.b.Close()
+	if b.err != nil {
+		return b.err
+	}
+	return b.ds.feed.commit(b)
+}
@@ -0,0 +1,129 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package pebbledb
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type metricsCollector struct {
+	ds *DataStore
+
+	diskUsageDesc      *prometheus.Desc
+	compactionDebtDesc *prometheus.Desc
+	compactionsDesc    *prometheus.Desc
+	flushesDesc        *prometheus.Desc
+	memTableSizeDesc   *prometheus.Desc
+	walSizeDesc        *prometheus.Desc
+	levelFilesDesc     *prometheus.Desc
+	levelSizeDesc      *prometheus.Desc
+	prefixKeysDesc     *prometheus.Desc
+	prefixBytesDesc    *prometheus.Desc
+	sizeLimitDesc      *prometheus.Desc
+	overSizeLimitDesc  *prometheus.Desc
+	evictedKeysDesc    *prometheus.Desc
+}
+
+// NewMetricsCollector creates a prometheus collector for the internals of the pebble database, and
+// for the keys in the datastore.
+func NewMetricsCollector(ds *DataStore) prometheus.Collector {
+	return &metricsCollector{
+		ds: ds,
+		diskUsageDesc: prometheus.NewDesc("pebble_disk_usage_bytes",
+			"Estimated space used by the pebble datastore on disk", nil, nil),
+		compactionDebtDesc: prometheus.NewDesc("pebble_compaction_estimated_debt_bytes",
+			"Estimated bytes which need to be compacted for the pebble LSM to reach a stable state", nil, nil),
+		compactionsDesc: prometheus.NewDesc("pebble_compactions_total",
+			"Number of compactions run by pebble", nil, nil),
+		flushesDesc: prometheus.NewDesc("pebble_flushes_total",
+			"Number of memtable flushes run by pebble", nil, nil),
+		memTableSizeDesc: prometheus.NewDesc("pebble_memtable_size_bytes",
+			"Bytes allocated by the pebble memtables", nil, nil),
+		walSizeDesc: prometheus.NewDesc("pebble_wal_size_bytes",
+			"Size of the live data in the pebble WAL files", nil, nil),
+		levelFilesDesc: prometheus.NewDesc("pebble_level_files",
+			"Number of sstables in each level of the pebble LSM", []string{"level"}, nil),
+		levelSizeDesc: prometheus.NewDesc("pebble_level_size_bytes",
+			"Size of the sstables in each level of the pebble LSM", []string{"level"}, nil),
+		prefixKeysDesc: prometheus.NewDesc("pebble_prefix_keys",
+			"Number of keys with each prefix in the pebble datastore", []string{"prefix"}, nil),
+		prefixBytesDesc: prometheus.NewDesc("pebble_prefix_bytes",
+			"Bytes of the keys and values with each prefix in the pebble datastore", []string{"prefix"}, nil),
+		sizeLimitDesc: prometheus.NewDesc("pebble_size_limit_bytes",
+			"The soft and hard size limits of the pebble datastore. 0 is unlimited", []string{"limit"}, nil),
+		overSizeLimitDesc: prometheus.NewDesc("pebble_over_size_limit",
+			"Whether the pebble datastore is over its soft or hard size limit", []string{"limit"}, nil),
+		evictedKeysDesc: prometheus.NewDesc("pebble_evicted_keys_total",
+			"Number of keys evicted to keep the pebble datastore under its hard size limit", nil, nil),
+	}
+}
+
+// Describe implements Collector.
+func (c *metricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.diskUsageDesc
+	ch <- c.compactionDebtDesc
+	ch <- c.compactionsDesc
+	ch <- c.flushesDesc
+	ch <- c.memTableSizeDesc
+	ch <- c.walSizeDesc
+	ch <- c.levelFilesDesc
+	ch <- c.levelSizeDesc
+	ch <- c.prefixKeysDesc
+	ch <- c.prefixBytesDesc
+	ch <- c.sizeLimitDesc
+	ch <- c.overSizeLimitDesc
+	ch <- c.evictedKeysDesc
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Collect implements Collector.
+func (c *metricsCollector) Collect(ch chan<- prometheus.Metric) {
+	m := c.ds.db.Metrics()
+	ch <- prometheus.MustNewConstMetric(c.diskUsageDesc, prometheus.GaugeValue, float64(c.ds.Size()))
+	ch <- prometheus.MustNewConstMetric(c.compactionDebtDesc, prometheus.GaugeValue, float64(m.Compact.EstimatedDebt))
+	ch <- prometheus.MustNewConstMetric(c.compactionsDesc, prometheus.CounterValue, float64(m.Compact.Count))
+	ch <- prometheus.MustNewConstMetric(c.flushesDesc, prometheus.CounterValue, float64(m.Flush.Count))
+	ch <- prometheus.MustNewConstMetric(c.memTableSizeDesc, prometheus.GaugeValue, float64(m.MemTable.Size))
+	ch <- prometheus.MustNewConstMetric(c.walSizeDesc, prometheus.GaugeValue, float64(m.WAL.Size))
+	for i, l := range m.Levels {
+		level := strconv.Itoa(i)
+		ch <- prometheus.MustNewConstMetric(c.levelFilesDesc, prometheus.GaugeValue, float64(l.NumFiles), level)
+		ch <- prometheus.MustNewConstMetric(c.levelSizeDesc, prometheus.GaugeValue, float64(l.Size), level)
+	}
+
+	c.ds.mu.Lock()
+	defer c.ds.mu.Unlock()
+	for p, s := range c.ds.stats.prefixes {
+		ch <- prometheus.MustNewConstMetric(c.prefixKeysDesc, prometheus.GaugeValue, float64(s.keys), p)
+		ch <- prometheus.MustNewConstMetric(c.prefixBytesDesc, prometheus.GaugeValue, float64(s.bytes), p)
+	}
+	ch <- prometheus.MustNewConstMetric(c.sizeLimitDesc, prometheus.GaugeValue, float64(c.ds.limits.SoftLimitBytes), "soft")
+	ch <- prometheus.MustNewConstMetric(c.sizeLimitDesc, prometheus.GaugeValue, float64(c.ds.limits.HardLimitBytes), "hard")
+	ch <- prometheus.MustNewConstMetric(c.overSizeLimitDesc, prometheus.GaugeValue, boolToFloat(c.ds.stats.overSoftLimit), "soft")
+	ch <- prometheus.MustNewConstMetric(c.overSizeLimitDesc, prometheus.GaugeValue, boolToFloat(c.ds.stats.overHardLimit), "hard")
+	ch <- prometheus.MustNewConstMetric(c.evictedKeysDesc, prometheus.CounterValue, float64(c.ds.stats.evictedKeys))
+}
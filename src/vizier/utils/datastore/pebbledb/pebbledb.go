@@ -25,6 +25,8 @@ import (
 	"time"
 
 	"github.com/cockroachdb/pebble"
+
+	"px.dev/pixie/src/vizier/utils/datastore"
 )
 
 const (
@@ -40,8 +42,11 @@ func getKeyForTTLByTime(key string, expiresAt time.Time) string {
 	return fmt.Sprintf("%s/%20d/%s", ttlByTimePrefix, expiresAt.Unix(), key)
 }
 
+// getRangeForTTLByTime returns the range of TTLByTime keys which expired before the current second.
+// Keys which expire later in the current second are left for the next pass of the reaper, since the
+// TTLByTime keys in the range are deleted.
 func getRangeForTTLByTime(now time.Time) (string, string) {
-	return fmt.Sprintf("%s/", ttlByTimePrefix), fmt.Sprintf("%s/%20d/", ttlByTimePrefix, now.Unix()+1)
+	return fmt.Sprintf("%s/", ttlByTimePrefix), fmt.Sprintf("%s/%20d/", ttlByTimePrefix, now.Unix())
 }
 
 func getKeyToDeleteFromTTLByTime(ttlByTimeKey string) (string, error) {
@@ -57,9 +62,17 @@ func getKeyToDeleteFromTTLByTime(ttlByTimeKey string) (string, error) {
 
 // DataStore wraps a pebbledb datastore.
 type DataStore struct {
-	db *pebble.DB
+	db   *pebble.DB
+	feed *changeFeed
+
+	// Guards the size limits and the stats computed by the maintenance loop.
+	mu     sync.Mutex
+	limits *SizeLimits
+	stats  *maintenanceStats
 
 	done chan struct{}
+	// Tracks the TTL watcher and the maintenance loop, so that Close can wait for them to stop.
+	wg   sync.WaitGroup
 	once sync.Once
 }
 
@@ -67,15 +80,22 @@ type DataStore struct {
 func New(db *pebble.DB, ttlReaperDuration time.Duration) *DataStore {
 	wrap := &DataStore{
 		db:   db,
+		feed: newChangeFeed(),
 		done: make(chan struct{}),
+
+		limits: &SizeLimits{},
+		stats:  &maintenanceStats{},
 	}
 
+	wrap.wg.Add(2)
 	go wrap.ttlWatcher(ttlReaperDuration)
+	go wrap.maintenanceWatcher(maintenanceInterval)
 
 	return wrap
 }
 
 func (w *DataStore) ttlWatcher(ttlReaperDuration time.Duration) {
+	defer w.wg.Done()
 	ticker := time.NewTicker(ttlReaperDuration)
 	defer ticker.Stop()
 	for {
@@ -128,55 +148,32 @@ func (w *DataStore) ttlWatcher(ttlReaperDuration time.Duration) {
 					deleteKeys = append(deleteKeys, keyToCheck)
 				}
 			}
-			err := w.DeleteAll(deleteKeys)
-			if err != nil {
-				continue
-			}
-			// Delete the ttlByTime keys that have expired.
-			err = w.db.DeleteRange([]byte(from), []byte(to), pebble.Sync)
-			if err != nil {
-				continue
-			}
 			iter.Close()
+
+			batch := w.newBatch()
+			for _, k := range deleteKeys {
+				batch.Delete(k)
+			}
+			// Delete the ttlByTime keys that have expired, along with the keys themselves.
+			batch.setErr(batch.b.DeleteRange([]byte(from), []byte(to), nil))
+			_ = batch.Commit()
 		}
 	}
 }
 
 // Set puts the given key and value in the datastore.
 func (w *DataStore) Set(key string, value string) error {
-	return w.db.Set([]byte(key), []byte(value), pebble.Sync)
+	batch := w.NewBatch()
+	batch.Set(key, value)
+	return batch.Commit()
 }
 
 // SetWithTTL puts the given key and value into the datastore with a TTL.
 // Once the TTL expires the datastore is expected to delete the given key and value.
 func (w *DataStore) SetWithTTL(key string, value string, ttl time.Duration) error {
-	batch := w.db.NewBatch()
-	expiresAt := time.Now().Add(ttl)
-	encodedExpiry, err := expiresAt.MarshalBinary()
-	if err != nil {
-		batch.Close()
-		return err
-	}
-	err = batch.Set([]byte(key), []byte(value), pebble.Sync)
-	if err != nil {
-		batch.Close()
-		return err
-	}
-
-	ttlByKey := getKeyForTTLByKey(key)
-	ttlByTime := getKeyForTTLByTime(key, expiresAt)
-
-	err = batch.Set([]byte(ttlByKey), encodedExpiry, pebble.Sync)
-	if err != nil {
-		batch.Close()
-		return err
-	}
-	err = batch.Set([]byte(ttlByTime), nil, pebble.Sync)
-	if err != nil {
-		batch.Close()
-		return err
-	}
-	return batch.Commit(pebble.Sync)
+	batch := w.NewBatch()
+	batch.SetWithTTL(key, value, ttl)
+	return batch.Commit()
 }
 
 // Get gets the value for the given key from the datastore.
@@ -190,6 +187,34 @@ func (w *DataStore) Get(key string) ([]byte, error) {
 	return value, closer.Close()
 }
 
+// getExpiresAt reads the time at which the given key expires. The returned bool is false if the key
+// has no TTL.
+func getExpiresAt(r pebble.Reader, key string) (time.Time, bool, error) {
+	var expiresAt time.Time
+	v, closer, err := r.Get([]byte(getKeyForTTLByKey(key)))
+	if err == pebble.ErrNotFound {
+		return expiresAt, false, nil
+	}
+	if err != nil {
+		return expiresAt, false, err
+	}
+	defer closer.Close()
+	if err := expiresAt.UnmarshalBinary(v); err != nil {
+		return expiresAt, false, err
+	}
+	return expiresAt, true, nil
+}
+
+// GetTTL gets the remaining TTL for the given key. The TTL may be negative if the key has
+// expired, but has not yet been deleted.
+func (w *DataStore) GetTTL(key string) (time.Duration, bool, error) {
+	expiresAt, hasTTL, err := getExpiresAt(w.db, key)
+	if err != nil || !hasTTL {
+		return 0, false, err
+	}
+	return time.Until(expiresAt), true, nil
+}
+
 // GetWithRange gets all keys and values within the given range.
 // Treats this as [from, to) i.e. includes the key from, but excludes the key to.
 func (w *DataStore) GetWithRange(from string, to string) ([]string, [][]byte, error) {
@@ -220,33 +245,71 @@ func (w *DataStore) GetWithPrefix(prefix string) ([]string, [][]byte, error) {
 	return w.GetWithRange(prefix, string(keyUpperBound([]byte(prefix))))
 }
 
+// Scan calls fn with each key with the given prefix in key order, reading the keys from a consistent
+// snapshot of the datastore.
+func (w *DataStore) Scan(prefix string, fn func(kv *datastore.KeyValue) error) error {
+	snap := w.db.NewSnapshot()
+	defer snap.Close()
+
+	iter := snap.NewIter(&pebble.IterOptions{
+		LowerBound: []byte(prefix),
+		UpperBound: keyUpperBound([]byte(prefix)),
+	})
+	defer iter.Close()
+
+	now := time.Now()
+	for iter.First(); iter.Valid(); iter.Next() {
+		kv := &datastore.KeyValue{Key: string(iter.Key())}
+		expiresAt, hasTTL, err := getExpiresAt(snap, kv.Key)
+		if err != nil {
+			return err
+		}
+		if hasTTL {
+			kv.TTL = expiresAt.Sub(now)
+			if kv.TTL <= 0 {
+				continue
+			}
+		}
+		v := iter.Value()
+		kv.Value = make([]byte, len(v))
+		copy(kv.Value, v)
+		if err := fn(kv); err != nil {
+			return err
+		}
+	}
+	return iter.Error()
+}
+
 // Delete deletes the value for the given key from the datastore.
 func (w *DataStore) Delete(key string) error {
-	return w.db.Delete([]byte(key), pebble.Sync)
+	batch := w.NewBatch()
+	batch.Delete(key)
+	return batch.Commit()
 }
 
 // DeleteAll deletes all of the given keys and corresponding values in the datastore if they exist.
 func (w *DataStore) DeleteAll(keys []string) error {
-	batch := w.db.NewBatch()
+	batch := w.NewBatch()
 	for _, key := range keys {
-		err := batch.Delete([]byte(key), pebble.Sync)
-		if err != nil {
-			return err
-		}
+		batch.Delete(key)
 	}
-	return batch.Commit(pebble.Sync)
+	return batch.Commit()
 }
 
 // DeleteWithPrefix deletes all keys and values with the given prefix.
 func (w *DataStore) DeleteWithPrefix(prefix string) error {
-	return w.db.DeleteRange([]byte(prefix), keyUpperBound([]byte(prefix)), pebble.Sync)
+	batch := w.NewBatch()
+	batch.DeleteWithPrefix(prefix)
+	return batch.Commit()
 }
 
-// Close stops the TTL watcher, and closes the underlying datastore.
+// Close stops the TTL watcher and the maintenance loop, and closes the underlying datastore.
 // All other operations will fail after calling Close.
 func (w *DataStore) Close() error {
 	w.once.Do(func() {
 		close(w.done)
+		// Wait for the background loops to stop, so that they don't use the db after it is closed.
+		w.wg.Wait()
 	})
 
 	if w.db == nil {
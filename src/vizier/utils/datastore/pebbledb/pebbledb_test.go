@@ -0,0 +1,60 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package pebbledb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"px.dev/pixie/src/vizier/utils/datastore/datastoretest"
+)
+
+func TestDataStore_Conformance(t *testing.T) {
+	db, err := pebble.Open("test", &pebble.Options{
+		FS: vfs.NewMem(),
+	})
+	require.NoError(t, err)
+	datastoretest.Run(t, New(db, 100*time.Millisecond), true)
+}
+
+func TestDataStore_TTLMarkersDeleted(t *testing.T) {
+	ds := setupDataStore(t)
+
+	require.NoError(t, ds.SetWithTTL("timed1", "limited1", time.Second))
+	require.NoError(t, ds.SetWithTTL("timed2", "limited2", time.Hour))
+
+	require.Eventually(t, func() bool {
+		v, err := ds.Get("timed1")
+		return err == nil && v == nil
+	}, 10*time.Second, 100*time.Millisecond)
+
+	// The TTL markers of the expired key should be deleted along with it.
+	keys, _, err := ds.GetWithPrefix(ttlByKeyPrefix)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"___ttl___/timed2"}, keys)
+
+	keys, _, err = ds.GetWithPrefix(ttlByTimePrefix)
+	require.NoError(t, err)
+	assert.Len(t, keys, 1)
+}
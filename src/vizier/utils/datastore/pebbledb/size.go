@@ -0,0 +1,237 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package pebbledb
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	log "github.com/sirupsen/logrus"
+)
+
+// maintenanceInterval is how often the key stats are recomputed and the size limits are enforced.
+const maintenanceInterval = 5 * time.Minute
+
+// SizeLimits bounds the size of the datastore on disk. A limit of 0 is unlimited.
+type SizeLimits struct {
+	// Once the datastore is larger than SoftLimitBytes, it is reported as over its limit in the logs
+	// and metrics, so that it can be alerted on.
+	SoftLimitBytes uint64
+	// Once the datastore is larger than HardLimitBytes, keys are evicted until the datastore is back
+	// under the soft limit, or under the hard limit if there is no soft limit.
+	HardLimitBytes uint64
+	// The prefixes of the keys which may be evicted. The last path segment of each of these keys must
+	// be a version which orders the keys by when they were written, zero-padded so that the versions
+	// sort as strings, such as /resourceUpdate/<topic>/<version>. The keys with the oldest versions
+	// are evicted first, across all of the prefixes.
+	EvictionPrefixes []string
+}
+
+// prefixStats are the number of keys and the bytes of the keys and values with a prefix.
+type prefixStats struct {
+	keys  int64
+	bytes int64
+}
+
+// maintenanceStats are the stats computed by the last pass of the maintenance loop.
+type maintenanceStats struct {
+	// The stats of the keys, grouped by the first segment of their path.
+	prefixes map[string]*prefixStats
+	// Whether the datastore was over its soft or hard limit.
+	overSoftLimit bool
+	overHardLimit bool
+	// The total number of keys evicted to enforce the hard limit.
+	evictedKeys int64
+}
+
+// SetSizeLimits sets the size limits of the datastore, which are enforced by the maintenance loop.
+func (w *DataStore) SetSizeLimits(limits *SizeLimits) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.limits = limits
+}
+
+// Size returns an estimate of the space used by the datastore on disk.
+func (w *DataStore) Size() uint64 {
+	m := w.db.Metrics()
+	return uint64(m.Total().Size) + m.WAL.Size
+}
+
+// Compact compacts all of the keys in the datastore, reclaiming the space used by deleted and
+// overwritten keys.
+func (w *DataStore) Compact() error {
+	// All of the keys in the datastore are printable strings, so they sort before 0xff.
+	return w.db.Compact([]byte{}, []byte{0xff})
+}
+
+func (w *DataStore) maintenanceWatcher(interval time.Duration) {
+	defer w.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			if err := w.maintain(); err != nil {
+				log.WithError(err).Error("Failed to run pebble datastore maintenance")
+			}
+		}
+	}
+}
+
+// keyPathPrefix returns the first segment of the path of the key, which is used to group the key stats.
+func keyPathPrefix(key string) string {
+	if len(key) == 0 {
+		return key
+	}
+	if i := strings.Index(key[1:], "/"); i >= 0 {
+		return key[:i+1]
+	}
+	return key
+}
+
+// maintain recomputes the key stats and enforces the size limits of the datastore.
+func (w *DataStore) maintain() error {
+	prefixes := make(map[string]*prefixStats)
+	iter := w.db.NewIter(nil)
+	for iter.First(); iter.Valid(); iter.Next() {
+		p := keyPathPrefix(string(iter.Key()))
+		s, ok := prefixes[p]
+		if !ok {
+			s = &prefixStats{}
+			prefixes[p] = s
+		}
+		s.keys++
+		s.bytes += int64(len(iter.Key()) + len(iter.Value()))
+	}
+	if err := iter.Close(); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	limits := w.limits
+	w.stats.prefixes = prefixes
+	w.mu.Unlock()
+
+	size := w.Size()
+	overSoftLimit := limits.SoftLimitBytes > 0 && size > limits.SoftLimitBytes
+	overHardLimit := limits.HardLimitBytes > 0 && size > limits.HardLimitBytes
+	if overSoftLimit {
+		log.WithField("size", size).WithField("limit", limits.SoftLimitBytes).
+			Warn("Pebble datastore is over its soft size limit")
+	}
+
+	var evicted int
+	if overHardLimit {
+		target := limits.HardLimitBytes
+		if limits.SoftLimitBytes > 0 && limits.SoftLimitBytes < target {
+			target = limits.SoftLimitBytes
+		}
+		var freed uint64
+		var err error
+		evicted, freed, err = w.evict(limits.EvictionPrefixes, size-target)
+		if err != nil {
+			return err
+		}
+		log.WithField("size", size).WithField("limit", limits.HardLimitBytes).WithField("evictedKeys", evicted).
+			WithField("freedBytes", freed).Error("Pebble datastore is over its hard size limit, evicted keys")
+		if evicted > 0 {
+			if err := w.Compact(); err != nil {
+				return err
+			}
+		}
+	}
+
+	w.mu.Lock()
+	w.stats.overSoftLimit = overSoftLimit
+	w.stats.overHardLimit = overHardLimit
+	w.stats.evictedKeys += int64(evicted)
+	w.mu.Unlock()
+	return nil
+}
+
+// evictionCandidate is a key which may be evicted, along with the bytes that evicting it frees.
+type evictionCandidate struct {
+	key     string
+	version string
+	bytes   uint64
+}
+
+// evict deletes keys with the given prefixes, oldest version first, until the keys and values deleted
+// add up to at least the given number of bytes. The space used on disk is only reclaimed once the
+// datastore is compacted.
+func (w *DataStore) evict(prefixes []string, bytes uint64) (int, uint64, error) {
+	var candidates []*evictionCandidate
+	for _, prefix := range prefixes {
+		iter := w.db.NewIter(&pebble.IterOptions{
+			LowerBound: []byte(prefix),
+			UpperBound: keyUpperBound([]byte(prefix)),
+		})
+		for iter.First(); iter.Valid(); iter.Next() {
+			key := string(iter.Key())
+			candidates = append(candidates, &evictionCandidate{
+				key:     key,
+				version: path.Base(key),
+				bytes:   uint64(len(iter.Key()) + len(iter.Value())),
+			})
+		}
+		if err := iter.Close(); err != nil {
+			return 0, 0, err
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].version != candidates[j].version {
+			return candidates[i].version < candidates[j].version
+		}
+		return candidates[i].key < candidates[j].key
+	})
+
+	batch := w.newBatch()
+	evicted := 0
+	var freed uint64
+	for _, c := range candidates {
+		if freed >= bytes {
+			break
+		}
+		batch.Delete(c.key)
+		// Also delete the TTL of the key, so that the TTL reaper doesn't try to delete it again.
+		ttlByKey := getKeyForTTLByKey(c.key)
+		v, err := w.Get(ttlByKey)
+		if err != nil {
+			return 0, 0, err
+		}
+		if v != nil {
+			var expiresAt time.Time
+			if err := expiresAt.UnmarshalBinary(v); err == nil {
+				batch.setErr(batch.b.Delete([]byte(getKeyForTTLByTime(c.key, expiresAt)), nil))
+			}
+			batch.setErr(batch.b.Delete([]byte(ttlByKey), nil))
+		}
+		evicted++
+		freed += c.bytes
+	}
+	if err := batch.Commit(); err != nil {
+		return 0, 0, err
+	}
+	return evicted, freed, nil
+}
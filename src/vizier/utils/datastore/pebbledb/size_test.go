@@ -0,0 +1,157 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package pebbledb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDataStore_MaintainStats(t *testing.T) {
+	ds := setupDataStore(t)
+	require.NoError(t, ds.Set("/agent/a", "abc"))
+	require.NoError(t, ds.Set("/agent/b", "de"))
+	require.NoError(t, ds.Set("/topicVersion", "1"))
+
+	require.NoError(t, ds.maintain())
+
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	assert.Equal(t, map[string]*prefixStats{
+		"/agent":        {keys: 2, bytes: 21},
+		"/topicVersion": {keys: 1, bytes: 14},
+	}, ds.stats.prefixes)
+	assert.False(t, ds.stats.overSoftLimit)
+	assert.False(t, ds.stats.overHardLimit)
+}
+
+func TestDataStore_Evict(t *testing.T) {
+	ds := setupDataStore(t)
+	require.NoError(t, ds.SetWithTTL("/resourceUpdate/b/00000000000000000003", "333", time.Minute))
+	require.NoError(t, ds.SetWithTTL("/fullResourceUpdate/00000000000000000001", "111", 3*time.Hour))
+	require.NoError(t, ds.SetWithTTL("/resourceUpdate/a/00000000000000000002", "222", 2*time.Hour))
+	require.NoError(t, ds.Set("/fullResourceUpdate/00000000000000000004", "444"))
+	require.NoError(t, ds.SetWithTTL("/agent/a", "agent", time.Minute))
+
+	// Evicting a single byte evicts the key with the oldest version, even though it expires last.
+	evicted, freed, err := ds.evict([]string{"/fullResourceUpdate", "/resourceUpdate"}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, evicted)
+	assert.Equal(t, uint64(len("/fullResourceUpdate/00000000000000000001")+3), freed)
+
+	// The next oldest keys are evicted across the prefixes, whether or not they have a TTL.
+	evicted, _, err = ds.evict([]string{"/fullResourceUpdate", "/resourceUpdate"},
+		uint64(len("/resourceUpdate/a/00000000000000000002")+4))
+	require.NoError(t, err)
+	assert.Equal(t, 2, evicted)
+
+	keys, _, err := ds.GetWithPrefix("/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/agent/a", "/fullResourceUpdate/00000000000000000004"}, keys)
+
+	_, ok, err := ds.GetTTL("/resourceUpdate/a/00000000000000000002")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ttlByTimeKeys, _, err := ds.GetWithPrefix(ttlByTimePrefix)
+	require.NoError(t, err)
+	// Only the TTLByTime key of the agent is left.
+	assert.Len(t, ttlByTimeKeys, 1)
+}
+
+func TestDataStore_MaintainSizeLimits(t *testing.T) {
+	ds := setupDataStore(t)
+	require.NoError(t, ds.SetWithTTL("/fullResourceUpdate/00000000000000000001", "111", time.Hour))
+	require.NoError(t, ds.SetWithTTL("/resourceUpdate/a/00000000000000000001", "222", time.Hour))
+	require.NoError(t, ds.Set("/agent/a", "agent"))
+
+	ds.SetSizeLimits(&SizeLimits{
+		SoftLimitBytes:   1,
+		HardLimitBytes:   2,
+		EvictionPrefixes: []string{"/fullResourceUpdate", "/resourceUpdate"},
+	})
+	require.NoError(t, ds.maintain())
+
+	keys, _, err := ds.GetWithPrefix("/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/agent/a"}, keys)
+
+	ds.mu.Lock()
+	assert.True(t, ds.stats.overSoftLimit)
+	assert.True(t, ds.stats.overHardLimit)
+	assert.Equal(t, int64(2), ds.stats.evictedKeys)
+	ds.mu.Unlock()
+
+	ds.SetSizeLimits(&SizeLimits{})
+	require.NoError(t, ds.maintain())
+	ds.mu.Lock()
+	assert.False(t, ds.stats.overSoftLimit)
+	assert.False(t, ds.stats.overHardLimit)
+	ds.mu.Unlock()
+}
+
+func TestDataStore_Compact(t *testing.T) {
+	ds := setupDataStore(t)
+	require.NoError(t, ds.Set("/agent/a", "abc"))
+	require.NoError(t, ds.Delete("/agent/a"))
+	require.NoError(t, ds.Set("/agent/b", "def"))
+	require.NoError(t, ds.Compact())
+
+	v, err := ds.Get("/agent/b")
+	require.NoError(t, err)
+	assert.Equal(t, "def", string(v))
+	assert.Greater(t, ds.Size(), uint64(0))
+}
+
+func TestMetricsCollector(t *testing.T) {
+	ds := setupDataStore(t)
+	require.NoError(t, ds.Set("/agent/a", "abc"))
+	ds.SetSizeLimits(&SizeLimits{SoftLimitBytes: 10, HardLimitBytes: 20})
+	require.NoError(t, ds.maintain())
+
+	reg := prometheus.NewRegistry()
+	require.NoError(t, reg.Register(NewMetricsCollector(ds)))
+	families, err := reg.Gather()
+	require.NoError(t, err)
+
+	values := make(map[string]float64)
+	for _, f := range families {
+		for _, m := range f.Metric {
+			name := f.GetName()
+			for _, l := range m.Label {
+				name += "/" + l.GetValue()
+			}
+			if m.Gauge != nil {
+				values[name] = m.Gauge.GetValue()
+			} else {
+				values[name] = m.Counter.GetValue()
+			}
+		}
+	}
+	assert.Equal(t, float64(1), values["pebble_prefix_keys//agent"])
+	assert.Equal(t, float64(11), values["pebble_prefix_bytes//agent"])
+	assert.Equal(t, float64(10), values["pebble_size_limit_bytes/soft"])
+	assert.Equal(t, float64(20), values["pebble_size_limit_bytes/hard"])
+	assert.Equal(t, float64(1), values["pebble_over_size_limit/hard"])
+	assert.Contains(t, values, "pebble_compaction_estimated_debt_bytes")
+	assert.Contains(t, values, "pebble_level_files/0")
+}
@@ -0,0 +1,212 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package pebbledb
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/cockroachdb/pebble"
+	log "github.com/sirupsen/logrus"
+
+	"px.dev/pixie/src/vizier/utils/datastore"
+)
+
+// The number of events buffered for each watcher. Watchers which fall further behind than this are closed.
+const watchBufferSize = 1024
+
+type watcher struct {
+	prefix string
+	ch     chan *datastore.Event
+}
+
+// changeFeed publishes the writes made to a DataStore to its watchers. Pebble doesn't track
+// revisions, so the revisions of the events only increase for the lifetime of the DataStore.
+type changeFeed struct {
+	// mu is held exclusively while batches are committed if there are any watchers, so that the
+	// events are published in the same order as the writes. Without watchers, batches are committed
+	// concurrently under the read lock. Adding a watcher waits for those commits to finish.
+	mu       sync.RWMutex
+	revision int64
+	watchers map[*watcher]struct{}
+}
+
+func newChangeFeed() *changeFeed {
+	return &changeFeed{watchers: make(map[*watcher]struct{})}
+}
+
+func isInternalKey(key string) bool {
+	return strings.HasPrefix(key, ttlByKeyPrefix) || strings.HasPrefix(key, ttlByTimePrefix)
+}
+
+// watched returns whether any watcher is interested in keys with the given prefix.
+func (f *changeFeed) watched(prefix string) bool {
+	for wt := range f.watchers {
+		if strings.HasPrefix(prefix, wt.prefix) || strings.HasPrefix(wt.prefix, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// events converts the writes in the batch into events. Prefix deletes are expanded into a delete
+// event for each key with the prefix, so this has to be called before the batch is committed.
+func (f *changeFeed) events(db *pebble.DB, ops []*batchOp) ([]*datastore.Event, error) {
+	var events []*datastore.Event
+	for _, op := range ops {
+		switch {
+		case op.prefix:
+			if !f.watched(op.key) {
+				continue
+			}
+			iter := db.NewIter(&pebble.IterOptions{
+				LowerBound: []byte(op.key),
+				UpperBound: keyUpperBound([]byte(op.key)),
+			})
+			for iter.First(); iter.Valid(); iter.Next() {
+				events = append(events, &datastore.Event{
+					Type: datastore.EventTypeDelete,
+					Key:  string(iter.Key()),
+				})
+			}
+			if err := iter.Close(); err != nil {
+				return nil, err
+			}
+		case op.delete:
+			events = append(events, &datastore.Event{
+				Type: datastore.EventTypeDelete,
+				Key:  op.key,
+			})
+		default:
+			events = append(events, &datastore.Event{
+				Type:  datastore.EventTypePut,
+				Key:   op.key,
+				Value: op.value,
+			})
+		}
+	}
+	return events, nil
+}
+
+// commitUnwatched commits the batch without publishing it, if there are no watchers. Returns false
+// if the batch has to be published.
+func (f *changeFeed) commitUnwatched(b *Batch) (bool, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if len(f.watchers) > 0 {
+		return false, nil
+	}
+	if err := b.b.Commit(pebble.Sync); err != nil {
+		return true, err
+	}
+	f.nextRevision(b)
+	return true, nil
+}
+
+// nextRevision advances the revision for a committed batch, and returns the new revision.
+func (f *changeFeed) nextRevision(b *Batch) int64 {
+	// Batches which only write the internal TTL keys don't count as a revision.
+	if len(b.ops) > 0 {
+		return atomic.AddInt64(&f.revision, 1)
+	}
+	return atomic.LoadInt64(&f.revision)
+}
+
+func (f *changeFeed) commit(b *Batch) error {
+	if done, err := f.commitUnwatched(b); done {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var events []*datastore.Event
+	if len(f.watchers) > 0 {
+		var err error
+		events, err = f.events(b.ds.db, b.ops)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := b.b.Commit(pebble.Sync); err != nil {
+		return err
+	}
+	revision := f.nextRevision(b)
+
+	for _, e := range events {
+		if isInternalKey(e.Key) {
+			continue
+		}
+		e.Revision = revision
+		for wt := range f.watchers {
+			if !strings.HasPrefix(e.Key, wt.prefix) {
+				continue
+			}
+			select {
+			case wt.ch <- e:
+			default:
+				log.WithField("prefix", wt.prefix).Warn("Closing datastore watcher which has fallen behind")
+				f.removeWatcherLocked(wt)
+			}
+		}
+	}
+	return nil
+}
+
+func (f *changeFeed) addWatcher(prefix string) *watcher {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	wt := &watcher{
+		prefix: prefix,
+		ch:     make(chan *datastore.Event, watchBufferSize),
+	}
+	f.watchers[wt] = struct{}{}
+	return wt
+}
+
+func (f *changeFeed) removeWatcher(wt *watcher) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.removeWatcherLocked(wt)
+}
+
+func (f *changeFeed) removeWatcherLocked(wt *watcher) {
+	if _, ok := f.watchers[wt]; !ok {
+		return
+	}
+	delete(f.watchers, wt)
+	close(wt.ch)
+}
+
+// Watch streams the changes made to keys with the given prefix. The events are sent once the
+// writes have been committed.
+func (w *DataStore) Watch(ctx context.Context, prefix string) (<-chan *datastore.Event, error) {
+	wt := w.feed.addWatcher(prefix)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-w.done:
+		}
+		w.feed.removeWatcher(wt)
+	}()
+	return wt.ch, nil
+}
@@ -0,0 +1,125 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package pebbledb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"px.dev/pixie/src/vizier/utils/datastore"
+)
+
+func setupDataStore(t *testing.T) *DataStore {
+	db, err := pebble.Open("test", &pebble.Options{
+		FS: vfs.NewMem(),
+	})
+	require.NoError(t, err)
+	ds := New(db, 100*time.Millisecond)
+	t.Cleanup(func() {
+		_ = ds.Close()
+	})
+	return ds
+}
+
+func receiveEvents(t *testing.T, ch <-chan *datastore.Event, n int) []*datastore.Event {
+	var events []*datastore.Event
+	for i := 0; i < n; i++ {
+		select {
+		case e := <-ch:
+			events = append(events, e)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for event %d", i)
+		}
+	}
+	return events
+}
+
+func TestDataStore_Watch(t *testing.T) {
+	ds := setupDataStore(t)
+	require.NoError(t, ds.Set("/a/1", "before"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := ds.Watch(ctx, "/a/")
+	require.NoError(t, err)
+
+	require.NoError(t, ds.Set("/a/2", "v2"))
+	// Keys outside of the prefix shouldn't be sent.
+	require.NoError(t, ds.Set("/b/1", "v1"))
+	batch := ds.NewBatch()
+	batch.Set("/a/3", "v3")
+	batch.Delete("/a/2")
+	require.NoError(t, batch.Commit())
+	require.NoError(t, ds.DeleteWithPrefix("/a/"))
+
+	events := receiveEvents(t, ch, 5)
+	assert.Equal(t, &datastore.Event{Type: datastore.EventTypePut, Key: "/a/2", Value: []byte("v2"), Revision: 2}, events[0])
+	// The writes in a batch share a revision.
+	assert.Equal(t, &datastore.Event{Type: datastore.EventTypePut, Key: "/a/3", Value: []byte("v3"), Revision: 4}, events[1])
+	assert.Equal(t, &datastore.Event{Type: datastore.EventTypeDelete, Key: "/a/2", Revision: 4}, events[2])
+	// Prefix deletes send an event for each of the deleted keys.
+	assert.Equal(t, &datastore.Event{Type: datastore.EventTypeDelete, Key: "/a/1", Revision: 5}, events[3])
+	assert.Equal(t, &datastore.Event{Type: datastore.EventTypeDelete, Key: "/a/3", Revision: 5}, events[4])
+
+	cancel()
+	select {
+	case _, ok := <-ch:
+		assert.False(t, ok)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watch channel wasn't closed after the context was cancelled")
+	}
+}
+
+func TestDataStore_WatchTTL(t *testing.T) {
+	ds := setupDataStore(t)
+	ch, err := ds.Watch(context.Background(), "")
+	require.NoError(t, err)
+
+	require.NoError(t, ds.SetWithTTL("/timed", "v", time.Millisecond))
+
+	// The internal TTL keys shouldn't be sent to the watchers.
+	events := receiveEvents(t, ch, 2)
+	assert.Equal(t, datastore.EventTypePut, events[0].Type)
+	assert.Equal(t, "/timed", events[0].Key)
+	assert.Equal(t, datastore.EventTypeDelete, events[1].Type)
+	assert.Equal(t, "/timed", events[1].Key)
+}
+
+func TestDataStore_WatchSlowWatcher(t *testing.T) {
+	ds := setupDataStore(t)
+	ch, err := ds.Watch(context.Background(), "/a/")
+	require.NoError(t, err)
+
+	for i := 0; i <= watchBufferSize; i++ {
+		require.NoError(t, ds.Set("/a/1", "v"))
+	}
+
+	// The watcher should be closed once it has fallen too far behind.
+	for i := 0; i < watchBufferSize; i++ {
+		_, ok := <-ch
+		require.True(t, ok)
+	}
+	_, ok := <-ch
+	assert.False(t, ok)
+}
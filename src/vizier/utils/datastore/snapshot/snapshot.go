@@ -0,0 +1,519 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+// Package snapshot writes the contents of a datastore to a versioned archive, and restores
+// datastores from those archives.
+//
+// An archive is a header, followed by one section per key prefix and a footer:
+//
+//	header:  "PXMDSNAP" | uint32 version
+//	section: 'R' | prefix | ('E' | key | value | ttl ms)* | 'C' | entry count | crc32c of entries
+//	footer:  'F' | section count | entry count | sha256 of all preceding bytes
+//
+// Strings and byte slices are prefixed with their uvarint encoded length, and counts are uvarints.
+package snapshot
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"time"
+
+	"px.dev/pixie/src/vizier/services/metadata/metadatapb"
+	"px.dev/pixie/src/vizier/utils/datastore"
+)
+
+const (
+	// Version is the version of the archive format written by Write.
+	Version uint32 = 1
+
+	magic = "PXMDSNAP"
+
+	recordSection    byte = 'R'
+	recordEntry      byte = 'E'
+	recordSectionEnd byte = 'C'
+	recordFooter     byte = 'F'
+
+	// Limit the size of keys and values read from an archive, so that a corrupt length does not
+	// cause a huge allocation.
+	maxFieldSize = 64 * 1024 * 1024
+
+	// The maximum number of writes, and bytes of values, in each batch written by Restore. These fit in
+	// a single etcd transaction.
+	maxBatchOps   = 128
+	maxBatchBytes = 1024 * 1024
+)
+
+// DefaultPrefixes are the key prefixes that contain all of the metadata service's state.
+var DefaultPrefixes = []string{"/"}
+
+var (
+	// ErrInvalidArchive is produced if the archive is malformed.
+	ErrInvalidArchive = errors.New("invalid snapshot archive")
+	// ErrUnsupportedVersion is produced if the archive was written with an unsupported format version.
+	ErrUnsupportedVersion = errors.New("unsupported snapshot archive version")
+	// ErrChecksumMismatch is produced if the contents of the archive do not match its checksums.
+	ErrChecksumMismatch = errors.New("snapshot archive checksum mismatch")
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Section describes the keys with a single prefix in an archive.
+type Section struct {
+	Prefix  string
+	Entries int64
+}
+
+// Manifest describes the contents of an archive.
+type Manifest struct {
+	Version  uint32
+	Sections []*Section
+	Entries  int64
+	// Checksum is the hex encoded sha256 checksum of the archive.
+	Checksum string
+}
+
+// ToProto converts the manifest to the proto returned by the metadata service's snapshot RPCs.
+func (m *Manifest) ToProto() *metadatapb.SnapshotManifest {
+	pb := &metadatapb.SnapshotManifest{
+		Version:  m.Version,
+		Sections: make([]*metadatapb.SnapshotManifest_Section, len(m.Sections)),
+		Entries:  m.Entries,
+		Checksum: m.Checksum,
+	}
+	for i, s := range m.Sections {
+		pb.Sections[i] = &metadatapb.SnapshotManifest_Section{
+			Prefix:  s.Prefix,
+			Entries: s.Entries,
+		}
+	}
+	return pb
+}
+
+// Target is a datastore that an archive can be restored to.
+type Target interface {
+	datastore.Batcher
+}
+
+// RestoreOptions configures how an archive is restored.
+type RestoreOptions struct {
+	// Clear deletes the existing keys with each prefix in the archive before restoring its entries.
+	Clear bool
+}
+
+type entry struct {
+	key   string
+	value []byte
+	ttl   time.Duration
+}
+
+// writer writes archive records, tracking the checksum of everything written.
+type writer struct {
+	w    *bufio.Writer
+	hash hash.Hash
+	buf  [binary.MaxVarintLen64]byte
+}
+
+func (w *writer) write(b []byte) error {
+	w.hash.Write(b)
+	_, err := w.w.Write(b)
+	return err
+}
+
+func (w *writer) writeUvarint(v uint64) error {
+	n := binary.PutUvarint(w.buf[:], v)
+	return w.write(w.buf[:n])
+}
+
+func (w *writer) writeBytes(b []byte) error {
+	if err := w.writeUvarint(uint64(len(b))); err != nil {
+		return err
+	}
+	return w.write(b)
+}
+
+// Write writes the keys with each of the given prefixes in the datastore to the archive. The keys are
+// scanned one at a time and written as they are read, so the datastore is never loaded into memory at
+// once. Each key is written with its remaining TTL, and keys that have already expired are skipped.
+func Write(out io.Writer, ds datastore.Scanner, prefixes []string) (*Manifest, error) {
+	w := &writer{w: bufio.NewWriter(out), hash: sha256.New()}
+
+	if err := w.write([]byte(magic)); err != nil {
+		return nil, err
+	}
+	var version [4]byte
+	binary.BigEndian.PutUint32(version[:], Version)
+	if err := w.write(version[:]); err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{Version: Version}
+	for _, prefix := range prefixes {
+		if err := w.write([]byte{recordSection}); err != nil {
+			return nil, err
+		}
+		if err := w.writeBytes([]byte(prefix)); err != nil {
+			return nil, err
+		}
+
+		crc := crc32.New(crcTable)
+		section := &Section{Prefix: prefix}
+		err := ds.Scan(prefix, func(kv *datastore.KeyValue) error {
+			// TTLs are written in milliseconds, and a zero TTL means the key has none.
+			ttl := kv.TTL
+			if ttl > 0 && ttl < time.Millisecond {
+				ttl = time.Millisecond
+			}
+			b := encodeEntry(&entry{key: kv.Key, value: kv.Value, ttl: ttl})
+			crc.Write(b)
+			section.Entries++
+			return w.write(b)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if err := w.write([]byte{recordSectionEnd}); err != nil {
+			return nil, err
+		}
+		if err := w.writeUvarint(uint64(section.Entries)); err != nil {
+			return nil, err
+		}
+		var sum [4]byte
+		binary.BigEndian.PutUint32(sum[:], crc.Sum32())
+		if err := w.write(sum[:]); err != nil {
+			return nil, err
+		}
+
+		manifest.Sections = append(manifest.Sections, section)
+		manifest.Entries += section.Entries
+	}
+
+	if err := w.write([]byte{recordFooter}); err != nil {
+		return nil, err
+	}
+	if err := w.writeUvarint(uint64(len(manifest.Sections))); err != nil {
+		return nil, err
+	}
+	if err := w.writeUvarint(uint64(manifest.Entries)); err != nil {
+		return nil, err
+	}
+	checksum := w.hash.Sum(nil)
+	if _, err := w.w.Write(checksum); err != nil {
+		return nil, err
+	}
+	manifest.Checksum = hex.EncodeToString(checksum)
+	return manifest, w.w.Flush()
+}
+
+// encodeEntry encodes an entry record. The section checksum covers the encoded entries.
+func encodeEntry(e *entry) []byte {
+	var b bytes.Buffer
+	var buf [binary.MaxVarintLen64]byte
+	b.WriteByte(recordEntry)
+	b.Write(buf[:binary.PutUvarint(buf[:], uint64(len(e.key)))])
+	b.WriteString(e.key)
+	b.Write(buf[:binary.PutUvarint(buf[:], uint64(len(e.value)))])
+	b.Write(e.value)
+	b.Write(buf[:binary.PutUvarint(buf[:], uint64(e.ttl.Milliseconds()))])
+	return b.Bytes()
+}
+
+// reader reads archive records, tracking the checksum of everything read.
+type reader struct {
+	r    *bufio.Reader
+	hash hash.Hash
+}
+
+func (r *reader) ReadByte() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	r.hash.Write([]byte{b})
+	return b, nil
+}
+
+func (r *reader) readFull(b []byte) error {
+	if _, err := io.ReadFull(r.r, b); err != nil {
+		return err
+	}
+	r.hash.Write(b)
+	return nil
+}
+
+func (r *reader) readUvarint() (uint64, error) {
+	return binary.ReadUvarint(r)
+}
+
+func (r *reader) readBytes() ([]byte, error) {
+	n, err := r.readUvarint()
+	if err != nil {
+		return nil, err
+	}
+	if n > maxFieldSize {
+		return nil, fmt.Errorf("%w: field of %d bytes is too large", ErrInvalidArchive, n)
+	}
+	b := make([]byte, n)
+	return b, r.readFull(b)
+}
+
+func unexpected(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: unexpected end of archive", ErrInvalidArchive)
+	}
+	return err
+}
+
+// read reads the archive, and calls the given function with the prefix and entries of each section once
+// the section's checksum has been verified. The archive's checksum is only verified once the entire
+// archive has been read.
+func read(in io.Reader, fn func(prefix string, entries []*entry) error) (*Manifest, error) {
+	r := &reader{r: bufio.NewReader(in), hash: sha256.New()}
+
+	header := make([]byte, len(magic)+4)
+	if err := r.readFull(header); err != nil {
+		return nil, unexpected(err)
+	}
+	if string(header[:len(magic)]) != magic {
+		return nil, fmt.Errorf("%w: missing header", ErrInvalidArchive)
+	}
+	manifest := &Manifest{Version: binary.BigEndian.Uint32(header[len(magic):])}
+	if manifest.Version != Version {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, manifest.Version)
+	}
+
+	for {
+		record, err := r.ReadByte()
+		if err != nil {
+			return nil, unexpected(err)
+		}
+		switch record {
+		case recordSection:
+			section, err := readSection(r, fn)
+			if err != nil {
+				return nil, unexpected(err)
+			}
+			manifest.Sections = append(manifest.Sections, section)
+			manifest.Entries += section.Entries
+		case recordFooter:
+			return manifest, readFooter(r, manifest)
+		default:
+			return nil, fmt.Errorf("%w: unknown record type %q", ErrInvalidArchive, record)
+		}
+	}
+}
+
+func readSection(r *reader, fn func(prefix string, entries []*entry) error) (*Section, error) {
+	prefix, err := r.readBytes()
+	if err != nil {
+		return nil, err
+	}
+	section := &Section{Prefix: string(prefix)}
+
+	var entries []*entry
+	crc := crc32.New(crcTable)
+	for {
+		record, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if record == recordSectionEnd {
+			break
+		}
+		if record != recordEntry {
+			return nil, fmt.Errorf("%w: unknown record type %q", ErrInvalidArchive, record)
+		}
+		key, err := r.readBytes()
+		if err != nil {
+			return nil, err
+		}
+		value, err := r.readBytes()
+		if err != nil {
+			return nil, err
+		}
+		ttlMs, err := r.readUvarint()
+		if err != nil {
+			return nil, err
+		}
+		e := &entry{key: string(key), value: value, ttl: time.Duration(ttlMs) * time.Millisecond}
+		crc.Write(encodeEntry(e))
+		entries = append(entries, e)
+	}
+
+	count, err := r.readUvarint()
+	if err != nil {
+		return nil, err
+	}
+	var sum [4]byte
+	if err := r.readFull(sum[:]); err != nil {
+		return nil, err
+	}
+	if count != uint64(len(entries)) || binary.BigEndian.Uint32(sum[:]) != crc.Sum32() {
+		return nil, fmt.Errorf("%w: section '%s'", ErrChecksumMismatch, section.Prefix)
+	}
+	section.Entries = int64(len(entries))
+
+	if fn != nil {
+		if err := fn(section.Prefix, entries); err != nil {
+			return nil, err
+		}
+	}
+	return section, nil
+}
+
+func readFooter(r *reader, manifest *Manifest) error {
+	numSections, err := r.readUvarint()
+	if err != nil {
+		return unexpected(err)
+	}
+	numEntries, err := r.readUvarint()
+	if err != nil {
+		return unexpected(err)
+	}
+	expected := r.hash.Sum(nil)
+	checksum := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(r.r, checksum); err != nil {
+		return unexpected(err)
+	}
+	if numSections != uint64(len(manifest.Sections)) || numEntries != uint64(manifest.Entries) || !bytes.Equal(expected, checksum) {
+		return ErrChecksumMismatch
+	}
+	if _, err := r.r.ReadByte(); err != io.EOF {
+		return fmt.Errorf("%w: unexpected data after footer", ErrInvalidArchive)
+	}
+	manifest.Checksum = hex.EncodeToString(checksum)
+	return nil
+}
+
+// Verify reads the entire archive and checks that it is well formed and matches its checksums.
+func Verify(in io.Reader) (*Manifest, error) {
+	return read(in, nil)
+}
+
+// restoreBatch writes the entries of an archive to the datastore in batches which are small enough
+// for any datastore to commit.
+type restoreBatch struct {
+	ds    Target
+	batch datastore.Batch
+	ops   int
+	bytes int
+}
+
+func (b *restoreBatch) add(e *entry) error {
+	if b.batch != nil && (b.ops == maxBatchOps || b.bytes+len(e.value) > maxBatchBytes) {
+		if err := b.flush(); err != nil {
+			return err
+		}
+	}
+	if b.batch == nil {
+		b.batch = b.ds.NewBatch()
+	}
+	if e.ttl > 0 {
+		b.batch.SetWithTTL(e.key, string(e.value), e.ttl)
+	} else {
+		b.batch.Set(e.key, string(e.value))
+	}
+	b.ops++
+	b.bytes += len(e.value)
+	return nil
+}
+
+func (b *restoreBatch) flush() error {
+	if b.batch == nil {
+		return nil
+	}
+	err := b.batch.Commit()
+	b.batch = nil
+	b.ops = 0
+	b.bytes = 0
+	return err
+}
+
+// Restore restores the entries in the archive to the datastore. The entire archive is verified before
+// anything is written, so a corrupt archive leaves the datastore untouched. If the archive can't be
+// rewound, it is first copied to a temporary file. The entries are then written in batches, so a
+// failure of the datastore part way through the restore may leave it partially restored.
+//
+// Restore must not be used on the datastore of a running metadata service, which keeps state in
+// memory that would not match the restored keys.
+func Restore(in io.Reader, ds Target, opts *RestoreOptions) (*Manifest, error) {
+	rs, ok := in.(io.ReadSeeker)
+	if !ok {
+		f, err := os.CreateTemp("", "md_snapshot")
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(f.Name())
+		defer f.Close()
+		if _, err := io.Copy(f, in); err != nil {
+			return nil, err
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		rs = f
+	}
+
+	start, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := Verify(rs)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := rs.Seek(start, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	if opts != nil && opts.Clear {
+		// The deletes are committed on their own, since datastores may reject batches which both delete
+		// a prefix and write keys with that prefix.
+		batch := ds.NewBatch()
+		for _, s := range manifest.Sections {
+			batch.DeleteWithPrefix(s.Prefix)
+		}
+		if err := batch.Commit(); err != nil {
+			return nil, err
+		}
+	}
+
+	b := &restoreBatch{ds: ds}
+	_, err = read(rs, func(prefix string, entries []*entry) error {
+		for _, e := range entries {
+			if err := b.add(e); err != nil {
+				return err
+			}
+		}
+		// Sections may overlap, so flush between them to avoid writing a key twice in a batch.
+		return b.flush()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
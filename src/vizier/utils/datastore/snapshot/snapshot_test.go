@@ -0,0 +1,221 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package snapshot_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"px.dev/pixie/src/vizier/services/metadata/metadatapb"
+	"px.dev/pixie/src/vizier/utils/datastore/pebbledb"
+	"px.dev/pixie/src/vizier/utils/datastore/snapshot"
+)
+
+func setupTest(t *testing.T) (*pebbledb.DataStore, func()) {
+	memFS := vfs.NewMem()
+	c, err := pebble.Open("test", &pebble.Options{
+		FS: memFS,
+	})
+	if err != nil {
+		t.Fatal("failed to initialize a pebbledb")
+	}
+
+	db := pebbledb.New(c, 3*time.Second)
+	return db, func() {
+		err := db.Close()
+		if err != nil {
+			t.Fatal("Failed to close db")
+		}
+	}
+}
+
+func writeSnapshot(t *testing.T) ([]byte, *snapshot.Manifest) {
+	db, cleanup := setupTest(t)
+	defer cleanup()
+
+	require.NoError(t, db.Set("/agent/1", "agent1"))
+	require.NoError(t, db.Set("/agent/2", "agent2"))
+	require.NoError(t, db.Set("/tracepoint/1", "tp1"))
+	require.NoError(t, db.SetWithTTL("/tracepointTTL/1", "", time.Hour))
+	require.NoError(t, db.Set("/other/1", "other"))
+
+	var b bytes.Buffer
+	manifest, err := snapshot.Write(&b, db, []string{"/agent/", "/tracepoint"})
+	require.NoError(t, err)
+	return b.Bytes(), manifest
+}
+
+func TestWriteRestore(t *testing.T) {
+	archive, manifest := writeSnapshot(t)
+	assert.Equal(t, snapshot.Version, manifest.Version)
+	assert.Equal(t, int64(4), manifest.Entries)
+	assert.Equal(t, []*snapshot.Section{
+		{Prefix: "/agent/", Entries: 2},
+		{Prefix: "/tracepoint", Entries: 2},
+	}, manifest.Sections)
+
+	verified, err := snapshot.Verify(bytes.NewReader(archive))
+	require.NoError(t, err)
+	assert.Equal(t, manifest, verified)
+
+	db, cleanup := setupTest(t)
+	defer cleanup()
+	require.NoError(t, db.Set("/agent/3", "stale"))
+	require.NoError(t, db.Set("/other/2", "other"))
+
+	restored, err := snapshot.Restore(bytes.NewReader(archive), db, &snapshot.RestoreOptions{Clear: true})
+	require.NoError(t, err)
+	assert.Equal(t, manifest, restored)
+
+	keys, values, err := db.GetWithPrefix("/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/agent/1", "/agent/2", "/other/2", "/tracepoint/1", "/tracepointTTL/1"}, keys)
+	assert.Equal(t, "agent1", string(values[0]))
+	assert.Equal(t, "tp1", string(values[3]))
+
+	ttl, hasTTL, err := db.GetTTL("/tracepointTTL/1")
+	require.NoError(t, err)
+	assert.True(t, hasTTL)
+	assert.InDelta(t, time.Hour.Seconds(), ttl.Seconds(), 60)
+	_, hasTTL, err = db.GetTTL("/tracepoint/1")
+	require.NoError(t, err)
+	assert.False(t, hasTTL)
+}
+
+func TestRestore_Corrupt(t *testing.T) {
+	archive, _ := writeSnapshot(t)
+	// Corrupt the footer, so that every section's checksum is still valid.
+	corrupt := append([]byte{}, archive...)
+	corrupt[len(corrupt)-1] ^= 0xff
+
+	db, cleanup := setupTest(t)
+	defer cleanup()
+	require.NoError(t, db.Set("/agent/3", "existing"))
+
+	_, err := snapshot.Restore(io.MultiReader(bytes.NewReader(corrupt)), db, &snapshot.RestoreOptions{Clear: true})
+	assert.True(t, errors.Is(err, snapshot.ErrChecksumMismatch), "unexpected error: %v", err)
+
+	// Nothing is cleared or written if the archive is corrupt.
+	keys, _, err := db.GetWithPrefix("/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/agent/3"}, keys)
+}
+
+func TestRestore_Batches(t *testing.T) {
+	src, cleanup := setupTest(t)
+	defer cleanup()
+	for i := 0; i < 300; i++ {
+		require.NoError(t, src.Set(fmt.Sprintf("/agent/%03d", i), "agent"))
+	}
+	var b bytes.Buffer
+	manifest, err := snapshot.Write(&b, src, snapshot.DefaultPrefixes)
+	require.NoError(t, err)
+	assert.Equal(t, int64(300), manifest.Entries)
+
+	db, cleanup := setupTest(t)
+	defer cleanup()
+	// The archive isn't seekable, so Restore has to buffer it before verifying it.
+	_, err = snapshot.Restore(io.MultiReader(&b), db, nil)
+	require.NoError(t, err)
+
+	keys, _, err := db.GetWithPrefix("/agent/")
+	require.NoError(t, err)
+	assert.Len(t, keys, 300)
+}
+
+func TestVerify_Corrupt(t *testing.T) {
+	archive, _ := writeSnapshot(t)
+
+	tests := []struct {
+		name     string
+		archive  func() []byte
+		expected error
+	}{
+		{
+			name: "modified entry",
+			archive: func() []byte {
+				b := append([]byte{}, archive...)
+				i := bytes.Index(b, []byte("agent1"))
+				b[i] = 'X'
+				return b
+			},
+			expected: snapshot.ErrChecksumMismatch,
+		},
+		{
+			name: "truncated",
+			archive: func() []byte {
+				return archive[:len(archive)-10]
+			},
+			expected: snapshot.ErrInvalidArchive,
+		},
+		{
+			name: "bad header",
+			archive: func() []byte {
+				return []byte("not a snapshot")
+			},
+			expected: snapshot.ErrInvalidArchive,
+		},
+		{
+			name: "unsupported version",
+			archive: func() []byte {
+				b := append([]byte{}, archive...)
+				b[11] = 99
+				return b
+			},
+			expected: snapshot.ErrUnsupportedVersion,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := snapshot.Verify(bytes.NewReader(test.archive()))
+			assert.True(t, errors.Is(err, test.expected), "unexpected error: %v", err)
+		})
+	}
+}
+
+func TestManifest_ToProto(t *testing.T) {
+	m := &snapshot.Manifest{
+		Version: 1,
+		Sections: []*snapshot.Section{
+			{Prefix: "/agent/", Entries: 2},
+			{Prefix: "/tracepoint/", Entries: 1},
+		},
+		Entries:  3,
+		Checksum: "abcd",
+	}
+	assert.Equal(t, &metadatapb.SnapshotManifest{
+		Version: 1,
+		Sections: []*metadatapb.SnapshotManifest_Section{
+			{Prefix: "/agent/", Entries: 2},
+			{Prefix: "/tracepoint/", Entries: 1},
+		},
+		Entries:  3,
+		Checksum: "abcd",
+	}, m.ToProto())
+}
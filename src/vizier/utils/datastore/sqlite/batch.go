@@ -0,0 +1,155 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package sqlite
+
+import (
+	"database/sql"
+	"time"
+
+	"px.dev/pixie/src/vizier/utils/datastore"
+)
+
+type batchOp struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+	delete    bool
+	prefix    bool
+}
+
+// Batch accumulates writes which are applied to the datastore atomically on Commit.
+type Batch struct {
+	ds  *DataStore
+	ops []*batchOp
+}
+
+// NewBatch creates a new batch of writes to the datastore.
+func (w *DataStore) NewBatch() datastore.Batch {
+	return &Batch{ds: w}
+}
+
+// Set adds a write of the given key and value to the batch.
+func (b *Batch) Set(key string, value string) {
+	b.ops = append(b.ops, &batchOp{key: key, value: []byte(value)})
+}
+
+// SetWithTTL adds a write of the given key and value with a TTL to the batch.
+func (b *Batch) SetWithTTL(key string, value string, ttl time.Duration) {
+	b.ops = append(b.ops, &batchOp{key: key, value: []byte(value), expiresAt: time.Now().Add(ttl)})
+}
+
+// Delete adds a delete of the given key to the batch.
+func (b *Batch) Delete(key string) {
+	b.ops = append(b.ops, &batchOp{key: key, delete: true})
+}
+
+// DeleteWithPrefix adds a delete of all keys with the given prefix to the batch.
+func (b *Batch) DeleteWithPrefix(prefix string) {
+	b.ops = append(b.ops, &batchOp{key: prefix, delete: true, prefix: true})
+}
+
+// Commit applies all of the writes in the batch to the datastore in a single transaction.
+func (b *Batch) Commit() error {
+	w := b.ds
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return ErrClosed
+	}
+
+	tx, err := w.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	revision := w.revision + 1
+	var events []*datastore.Event
+	for _, op := range b.ops {
+		opEvents, err := op.apply(tx, revision)
+		if err != nil {
+			return err
+		}
+		events = append(events, opEvents...)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	w.revision = revision
+	w.publish(events)
+	return nil
+}
+
+// apply runs the write in the transaction, and returns the events for the keys which it changed.
+func (op *batchOp) apply(tx *sql.Tx, revision int64) ([]*datastore.Event, error) {
+	switch {
+	case op.prefix:
+		cond, args := prefixCondition(op.key)
+		keys, err := queryKeys(tx, `SELECT key FROM kv WHERE `+cond+` ORDER BY key`, args...)
+		if err != nil || len(keys) == 0 {
+			return nil, err
+		}
+		if _, err := tx.Exec(`DELETE FROM kv WHERE `+cond, args...); err != nil {
+			return nil, err
+		}
+		events := make([]*datastore.Event, len(keys))
+		for i, k := range keys {
+			events[i] = &datastore.Event{
+				Type:     datastore.EventTypeDelete,
+				Key:      k,
+				Revision: revision,
+			}
+		}
+		return events, nil
+	case op.delete:
+		res, err := tx.Exec(`DELETE FROM kv WHERE key = ?`, op.key)
+		if err != nil {
+			return nil, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil || n == 0 {
+			return nil, err
+		}
+		return []*datastore.Event{{
+			Type:     datastore.EventTypeDelete,
+			Key:      op.key,
+			Revision: revision,
+		}}, nil
+	default:
+		var expiresAt sql.NullInt64
+		if !op.expiresAt.IsZero() {
+			expiresAt = sql.NullInt64{Int64: op.expiresAt.UnixNano(), Valid: true}
+		}
+		_, err := tx.Exec(`INSERT INTO kv (key, value, expires_at) VALUES (?, ?, ?)
+			ON CONFLICT (key) DO UPDATE SET value = excluded.value, expires_at = excluded.expires_at`,
+			op.key, op.value, expiresAt)
+		if err != nil {
+			return nil, err
+		}
+		value := make([]byte, len(op.value))
+		copy(value, op.value)
+		return []*datastore.Event{{
+			Type:     datastore.EventTypePut,
+			Key:      op.key,
+			Value:    value,
+			Revision: revision,
+		}}, nil
+	}
+}
@@ -0,0 +1,424 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	// Registers the pure Go "sqlite" driver, so that the datastore can be built without cgo.
+	_ "modernc.org/sqlite"
+
+	"px.dev/pixie/src/vizier/utils/datastore"
+)
+
+// The number of events buffered for each watcher. Watchers which fall further behind than this are closed.
+const watchBufferSize = 1024
+
+const schema = `
+CREATE TABLE IF NOT EXISTS kv (
+	key TEXT PRIMARY KEY,
+	value BLOB NOT NULL,
+	expires_at INTEGER
+);
+CREATE INDEX IF NOT EXISTS kv_expires_at ON kv (expires_at) WHERE expires_at IS NOT NULL;
+`
+
+// Keys with a TTL are treated as deleted once they have expired, even before the TTL reaper runs.
+const notExpired = `(expires_at IS NULL OR expires_at > ?)`
+
+// ErrClosed is returned by operations on a datastore which has been closed.
+var ErrClosed = errors.New("datastore is closed")
+
+type watcher struct {
+	prefix string
+	ch     chan *datastore.Event
+}
+
+// DataStore is a datastore which keeps its keys in a SQLite database. It is meant for tests and small
+// clusters, which don't need the write throughput of pebble but want the datastore to survive restarts.
+type DataStore struct {
+	db *sql.DB
+
+	// mu serializes writes, so that watchers see the changes in revision order.
+	mu       sync.Mutex
+	revision int64
+	watchers map[*watcher]struct{}
+	closed   bool
+
+	done chan struct{}
+	once sync.Once
+}
+
+// New opens the SQLite database at the given path, creating it if it doesn't exist. The path may be
+// ":memory:" for a database which isn't persisted. Keys with a TTL are deleted every ttlReaperDuration
+// once they have expired, and are treated as deleted by reads in the meantime.
+func New(path string, ttlReaperDuration time.Duration) (*DataStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// Every connection to ":memory:" opens a separate database, and SQLite only allows a single writer
+	// at a time anyway, so use a single connection.
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	w := &DataStore{
+		db:       db,
+		watchers: make(map[*watcher]struct{}),
+		done:     make(chan struct{}),
+	}
+
+	go w.ttlWatcher(ttlReaperDuration)
+
+	return w, nil
+}
+
+func (w *DataStore) ttlWatcher(ttlReaperDuration time.Duration) {
+	ticker := time.NewTicker(ttlReaperDuration)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			if err := w.deleteExpired(time.Now()); err != nil {
+				log.WithError(err).Error("Failed to delete expired keys")
+			}
+		}
+	}
+}
+
+func (w *DataStore) deleteExpired(now time.Time) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+
+	tx, err := w.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	keys, err := queryKeys(tx, `SELECT key FROM kv WHERE expires_at <= ? ORDER BY key`, now.UnixNano())
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	if _, err := tx.Exec(`DELETE FROM kv WHERE expires_at <= ?`, now.UnixNano()); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	w.revision++
+	events := make([]*datastore.Event, len(keys))
+	for i, k := range keys {
+		events[i] = &datastore.Event{
+			Type:     datastore.EventTypeDelete,
+			Key:      k,
+			Revision: w.revision,
+		}
+	}
+	w.publish(events)
+	return nil
+}
+
+// isClosed returns whether the datastore has been closed.
+func (w *DataStore) isClosed() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.closed
+}
+
+// Get gets the value for the given key from the datastore.
+func (w *DataStore) Get(key string) ([]byte, error) {
+	if w.isClosed() {
+		return nil, ErrClosed
+	}
+	var value []byte
+	err := w.db.QueryRow(`SELECT value FROM kv WHERE key = ? AND `+notExpired, key, time.Now().UnixNano()).Scan(&value)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// GetTTL gets the remaining TTL for the given key.
+func (w *DataStore) GetTTL(key string) (time.Duration, bool, error) {
+	if w.isClosed() {
+		return 0, false, ErrClosed
+	}
+	now := time.Now()
+	var expiresAt sql.NullInt64
+	err := w.db.QueryRow(`SELECT expires_at FROM kv WHERE key = ? AND `+notExpired, key, now.UnixNano()).Scan(&expiresAt)
+	if err == sql.ErrNoRows || (err == nil && !expiresAt.Valid) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return time.Unix(0, expiresAt.Int64).Sub(now), true, nil
+}
+
+// GetWithRange gets all keys and values within the given range.
+// Treats this as [from, to) i.e. includes the key from, but excludes the key to.
+func (w *DataStore) GetWithRange(from string, to string) ([]string, [][]byte, error) {
+	kvs, err := w.query(`SELECT key, value, expires_at FROM kv WHERE key >= ? AND key < ? AND `+notExpired+` ORDER BY key`,
+		from, to, time.Now().UnixNano())
+	if err != nil {
+		return nil, nil, err
+	}
+	return splitKeyValues(kvs)
+}
+
+// GetWithPrefix gets all keys and values with the given prefix.
+func (w *DataStore) GetWithPrefix(prefix string) ([]string, [][]byte, error) {
+	kvs, err := w.queryPrefix(prefix)
+	if err != nil {
+		return nil, nil, err
+	}
+	return splitKeyValues(kvs)
+}
+
+// Scan calls fn with each key with the given prefix in key order. The keys are read at the time of the
+// call, so fn may write to the datastore.
+func (w *DataStore) Scan(prefix string, fn func(kv *datastore.KeyValue) error) error {
+	kvs, err := w.queryPrefix(prefix)
+	if err != nil {
+		return err
+	}
+	for _, kv := range kvs {
+		if err := fn(kv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *DataStore) queryPrefix(prefix string) ([]*datastore.KeyValue, error) {
+	query, args := prefixCondition(prefix)
+	return w.query(`SELECT key, value, expires_at FROM kv WHERE `+query+` AND `+notExpired+` ORDER BY key`,
+		append(args, time.Now().UnixNano())...)
+}
+
+// query reads all of the rows returned by the query, so that the connection is free again once it returns.
+func (w *DataStore) query(query string, args ...interface{}) ([]*datastore.KeyValue, error) {
+	if w.isClosed() {
+		return nil, ErrClosed
+	}
+	now := time.Now()
+	rows, err := w.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var kvs []*datastore.KeyValue
+	for rows.Next() {
+		kv := &datastore.KeyValue{}
+		var expiresAt sql.NullInt64
+		if err := rows.Scan(&kv.Key, &kv.Value, &expiresAt); err != nil {
+			return nil, err
+		}
+		if expiresAt.Valid {
+			kv.TTL = time.Unix(0, expiresAt.Int64).Sub(now)
+		}
+		kvs = append(kvs, kv)
+	}
+	return kvs, rows.Err()
+}
+
+// Set puts the given key and value in the datastore.
+func (w *DataStore) Set(key string, value string) error {
+	batch := w.NewBatch()
+	batch.Set(key, value)
+	return batch.Commit()
+}
+
+// SetWithTTL puts the given key and value into the datastore with a TTL.
+// Once the TTL expires the datastore is expected to delete the given key and value.
+func (w *DataStore) SetWithTTL(key string, value string, ttl time.Duration) error {
+	batch := w.NewBatch()
+	batch.SetWithTTL(key, value, ttl)
+	return batch.Commit()
+}
+
+// Delete deletes the value for the given key from the datastore.
+func (w *DataStore) Delete(key string) error {
+	batch := w.NewBatch()
+	batch.Delete(key)
+	return batch.Commit()
+}
+
+// DeleteAll deletes all of the given keys and corresponding values in the datastore if they exist.
+func (w *DataStore) DeleteAll(keys []string) error {
+	batch := w.NewBatch()
+	for _, key := range keys {
+		batch.Delete(key)
+	}
+	return batch.Commit()
+}
+
+// DeleteWithPrefix deletes all keys and values with the given prefix.
+func (w *DataStore) DeleteWithPrefix(prefix string) error {
+	batch := w.NewBatch()
+	batch.DeleteWithPrefix(prefix)
+	return batch.Commit()
+}
+
+// Watch streams the changes made to keys with the given prefix.
+func (w *DataStore) Watch(ctx context.Context, prefix string) (<-chan *datastore.Event, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil, ErrClosed
+	}
+
+	wt := &watcher{
+		prefix: prefix,
+		ch:     make(chan *datastore.Event, watchBufferSize),
+	}
+	w.watchers[wt] = struct{}{}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-w.done:
+		}
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		w.removeWatcher(wt)
+	}()
+	return wt.ch, nil
+}
+
+// removeWatcher closes the watcher, if it hasn't already been closed. Must be called with mu held.
+func (w *DataStore) removeWatcher(wt *watcher) {
+	if _, ok := w.watchers[wt]; !ok {
+		return
+	}
+	delete(w.watchers, wt)
+	close(wt.ch)
+}
+
+// publish sends the events to the watchers. Must be called with mu held.
+func (w *DataStore) publish(events []*datastore.Event) {
+	for _, e := range events {
+		for wt := range w.watchers {
+			if !strings.HasPrefix(e.Key, wt.prefix) {
+				continue
+			}
+			select {
+			case wt.ch <- e:
+			default:
+				log.WithField("prefix", wt.prefix).Warn("Closing datastore watcher which has fallen behind")
+				w.removeWatcher(wt)
+			}
+		}
+	}
+}
+
+// Close stops the TTL watcher, closes the watchers and closes the database. All other operations will
+// fail after calling Close.
+func (w *DataStore) Close() error {
+	var err error
+	w.once.Do(func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		w.closed = true
+		close(w.done)
+		err = w.db.Close()
+	})
+	return err
+}
+
+// prefixCondition returns a condition which matches the keys with the given prefix, and can use the
+// primary key index.
+func prefixCondition(prefix string) (string, []interface{}) {
+	end := prefixEnd(prefix)
+	if end == "" {
+		return `key >= ?`, []interface{}{prefix}
+	}
+	return `key >= ? AND key < ?`, []interface{}{prefix, end}
+}
+
+// prefixEnd returns the smallest key which is greater than every key with the given prefix, or ""
+// if there is no such key.
+func prefixEnd(prefix string) string {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return string(end[:i+1])
+		}
+	}
+	return ""
+}
+
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func queryKeys(q queryer, query string, args ...interface{}) ([]string, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var k string
+		if err := rows.Scan(&k); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+func splitKeyValues(kvs []*datastore.KeyValue) ([]string, [][]byte, error) {
+	if len(kvs) == 0 {
+		return nil, nil, nil
+	}
+	keys := make([]string, len(kvs))
+	values := make([][]byte, len(kvs))
+	for i, kv := range kvs {
+		keys[i] = kv.Key
+		values[i] = kv.Value
+	}
+	return keys, values, nil
+}
@@ -0,0 +1,113 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package sqlite_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"px.dev/pixie/src/vizier/utils/datastore"
+	"px.dev/pixie/src/vizier/utils/datastore/datastoretest"
+	"px.dev/pixie/src/vizier/utils/datastore/sqlite"
+)
+
+func TestDataStore(t *testing.T) {
+	db, err := sqlite.New(filepath.Join(t.TempDir(), "datastore.db"), 100*time.Millisecond)
+	require.NoError(t, err)
+	datastoretest.Run(t, db, true)
+}
+
+func TestDataStore_InMemory(t *testing.T) {
+	db, err := sqlite.New(":memory:", 100*time.Millisecond)
+	require.NoError(t, err)
+	datastoretest.Run(t, db, false)
+}
+
+func TestDataStore_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "datastore.db")
+	db, err := sqlite.New(path, time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, db.Set("/persisted", "v"))
+	require.NoError(t, db.SetWithTTL("/timed", "v", time.Hour))
+	require.NoError(t, db.Close())
+
+	db, err = sqlite.New(path, time.Hour)
+	require.NoError(t, err)
+	defer db.Close()
+
+	v, err := db.Get("/persisted")
+	require.NoError(t, err)
+	assert.Equal(t, "v", string(v))
+
+	ttl, hasTTL, err := db.GetTTL("/timed")
+	require.NoError(t, err)
+	assert.True(t, hasTTL)
+	assert.InDelta(t, time.Hour.Seconds(), ttl.Seconds(), 60)
+}
+
+func TestDataStore_WatchTTLExpiry(t *testing.T) {
+	db, err := sqlite.New(":memory:", 10*time.Millisecond)
+	require.NoError(t, err)
+	defer db.Close()
+
+	ch, err := db.Watch(context.Background(), "/timed")
+	require.NoError(t, err)
+	require.NoError(t, db.SetWithTTL("/timed", "v", time.Millisecond))
+
+	var events []*datastore.Event
+	for len(events) < 2 {
+		select {
+		case e := <-ch:
+			events = append(events, e)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for watch events")
+		}
+	}
+	assert.Equal(t, &datastore.Event{Type: datastore.EventTypePut, Key: "/timed", Value: []byte("v"), Revision: 1}, events[0])
+	assert.Equal(t, &datastore.Event{Type: datastore.EventTypeDelete, Key: "/timed", Revision: 2}, events[1])
+
+	// Operations fail once the datastore is closed.
+	require.NoError(t, db.Close())
+	_, err = db.Get("/timed")
+	assert.Equal(t, sqlite.ErrClosed, err)
+}
+
+func TestDataStore_PrefixEnd(t *testing.T) {
+	db, err := sqlite.New(":memory:", time.Hour)
+	require.NoError(t, err)
+	defer db.Close()
+
+	require.NoError(t, db.Set("a\xff", "1"))
+	require.NoError(t, db.Set("a\xff\xff", "2"))
+	require.NoError(t, db.Set("b", "3"))
+
+	keys, _, err := db.GetWithPrefix("a\xff")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a\xff", "a\xff\xff"}, keys)
+
+	require.NoError(t, db.DeleteWithPrefix("a"))
+	keys, _, err = db.GetWithPrefix("")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b"}, keys)
+}